@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 
@@ -32,13 +33,23 @@ func newConfigShowCmd() *cobra.Command {
 		Use:   "show",
 		Short: "Show current configuration",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			cfg, _, _, err := config.LoadConfig()
+			cfg, _, projectDir, err := config.LoadConfig()
 			if err != nil {
 				return err
 			}
 
 			fmt.Printf("Config file: %s\n\n", config.ConfigPath())
 
+			if len(cfg.Include) > 0 {
+				results, err := config.EvaluateIncludes(cfg.Include, projectDir)
+				if err != nil {
+					return err
+				}
+				homeDir, _ := os.UserHomeDir()
+				printIncludeResults(os.Stdout, results, homeDir)
+				fmt.Println()
+			}
+
 			fmt.Println("[proxy]")
 			fmt.Printf("  enabled = %v\n", cfg.Proxy.Enabled)
 			fmt.Printf("  port = %d\n", cfg.Proxy.Port)
@@ -57,6 +68,9 @@ func newConfigShowCmd() *cobra.Command {
 				if cfg.Proxy.Filter.CacheDecisions != nil {
 					fmt.Printf("  cache_decisions = %v\n", *cfg.Proxy.Filter.CacheDecisions)
 				}
+				if cfg.Proxy.Filter.AskNotifications != nil {
+					fmt.Printf("  ask_notifications = %v\n", *cfg.Proxy.Filter.AskNotifications)
+				}
 				fmt.Println()
 
 				if len(cfg.Proxy.Filter.Rules) > 0 {
@@ -144,6 +158,28 @@ func newConfigShowCmd() *cobra.Command {
 	}
 }
 
+// printIncludeResults explains each [[include]]: whether it applies to the
+// current project and how each of its conditions evaluated.
+func printIncludeResults(w io.Writer, results []config.IncludeResult, homeDir string) {
+	for i, r := range results {
+		status := "not applied"
+		if r.Matched {
+			status = "applied"
+			if _, err := os.Stat(expandHome(homeDir, r.Include.Path)); err != nil {
+				status = "matched, but the file is missing"
+			}
+		}
+		fmt.Fprintf(w, "[[include]] #%d %s (%s)\n", i+1, r.Include.Path, status) //nolint:errcheck
+		for _, c := range r.Conditions {
+			mark := "no "
+			if c.Matched {
+				mark = "yes"
+			}
+			fmt.Fprintf(w, "  %s  %-3s %s  - %s\n", mark, c.Group, c.Condition, c.Reason) //nolint:errcheck
+		}
+	}
+}
+
 func newConfigPathCmd() *cobra.Command {
 	return &cobra.Command{
 		Use:   "path",
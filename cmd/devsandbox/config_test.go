@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"devsandbox/internal/config"
+)
+
+func TestPrintIncludeResults(t *testing.T) {
+	home := t.TempDir()
+	if err := os.WriteFile(filepath.Join(home, "org.toml"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	results := []config.IncludeResult{
+		{
+			Include: config.Include{Path: "~/org.toml"},
+			Matched: true,
+			Conditions: []config.ConditionResult{
+				{Group: "all", Condition: "git-remote:github.com/acme/**", Matched: true, Reason: "remote origin is github.com/acme/app"},
+				{Group: "all", Condition: "env:CI", Matched: true, Reason: "CI is set"},
+			},
+		},
+		{
+			Include: config.Include{Path: "~/missing.toml"},
+			Matched: true,
+			Conditions: []config.ConditionResult{
+				{Group: "if", Condition: "host:build-*", Matched: true, Reason: "host is build-1"},
+			},
+		},
+		{
+			Include: config.Include{Path: "~/work.toml"},
+			Conditions: []config.ConditionResult{
+				{Group: "if", Condition: "dir:~/work/**", Reason: "project is /src/app"},
+			},
+		},
+	}
+
+	var buf bytes.Buffer
+	printIncludeResults(&buf, results, home)
+	want := `[[include]] #1 ~/org.toml (applied)
+  yes  all git-remote:github.com/acme/**  - remote origin is github.com/acme/app
+  yes  all env:CI  - CI is set
+[[include]] #2 ~/missing.toml (matched, but the file is missing)
+  yes  if  host:build-*  - host is build-1
+[[include]] #3 ~/work.toml (not applied)
+  no   if  dir:~/work/**  - project is /src/app
+`
+	if got := buf.String(); got != want {
+		t.Errorf("output:\n%s\nwant:\n%s", got, want)
+	}
+}
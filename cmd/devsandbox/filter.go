@@ -9,6 +9,7 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/spf13/cobra"
@@ -35,6 +36,7 @@ func newFilterCmd() *cobra.Command {
 func newFilterGenerateCmd() *cobra.Command {
 	var (
 		fromLogs      string
+		fromHAR       string
 		project       string
 		minRequests   int
 		outputFile    string
@@ -46,6 +48,9 @@ func newFilterGenerateCmd() *cobra.Command {
 		Short: "Generate filter configuration from proxy logs",
 		Long: `Analyze proxy logs and generate filter rules based on observed traffic.
 
+--from-har reads an HTTP Archive instead, such as one saved from browser
+devtools or Charles, to build rules from traffic captured outside a sandbox.
+
 Examples:
   # Generate whitelist rules (block unmatched) from specific log directory
   devsandbox proxy filter generate --from-logs ~/.local/share/devsandbox/myproject/logs/proxy/
@@ -53,15 +58,22 @@ Examples:
   # Generate for current project
   devsandbox proxy filter generate
 
+  # Generate from traffic exported by browser devtools
+  devsandbox proxy filter generate --from-har session.har
+
   # Generate blacklist rules (allow unmatched)
   devsandbox proxy filter generate --default-action allow
 `,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return runFilterGenerate(fromLogs, project, minRequests, outputFile, defaultAction)
+			if fromHAR != "" && (fromLogs != "" || project != "") {
+				return fmt.Errorf("--from-har cannot be combined with --from-logs or --project")
+			}
+			return runFilterGenerate(fromLogs, fromHAR, project, minRequests, outputFile, defaultAction)
 		},
 	}
 
 	cmd.Flags().StringVar(&fromLogs, "from-logs", "", "Path to proxy log directory")
+	cmd.Flags().StringVar(&fromHAR, "from-har", "", "Path to an HTTP Archive (.har) file")
 	cmd.Flags().StringVar(&project, "project", "", "Project name (uses current directory if not set)")
 	cmd.Flags().IntVar(&minRequests, "min-requests", 1, "Minimum requests to include a domain")
 	cmd.Flags().StringVarP(&outputFile, "output", "o", "", "Output file (default: stdout)")
@@ -92,10 +104,16 @@ func newFilterShowCmd() *cobra.Command {
 			if cfg.Proxy.Filter.CacheDecisions != nil {
 				fmt.Printf("Cache Decisions: %v\n", *cfg.Proxy.Filter.CacheDecisions)
 			}
+			if cfg.Proxy.Filter.AskNotifications != nil {
+				fmt.Printf("Ask Notifications: %v\n", *cfg.Proxy.Filter.AskNotifications)
+			}
 			fmt.Println()
 			fmt.Printf("Rules (%d):\n", len(cfg.Proxy.Filter.Rules))
 			for i, rule := range cfg.Proxy.Filter.Rules {
 				fmt.Printf("  %d. [%s] %s (scope: %s)\n", i+1, rule.Action, rule.Pattern, rule.Scope)
+				for _, line := range filterRuleMatcherLines(rule) {
+					fmt.Printf("      %s\n", line)
+				}
 				if rule.Reason != "" {
 					fmt.Printf("      Reason: %s\n", rule.Reason)
 				}
@@ -106,6 +124,36 @@ func newFilterShowCmd() *cobra.Command {
 	}
 }
 
+// filterRuleMatcherLines describes a rule's port, method, header and body
+// matchers, one per line, in the order they are evaluated. A rule without any
+// yields nothing.
+func filterRuleMatcherLines(rule config.ProxyFilterRule) []string {
+	var lines []string
+	if len(rule.Ports) > 0 {
+		ports := make([]string, len(rule.Ports))
+		for i, port := range rule.Ports {
+			ports[i] = strconv.Itoa(port)
+		}
+		lines = append(lines, "Ports: "+strings.Join(ports, ", "))
+	}
+	if len(rule.Methods) > 0 {
+		lines = append(lines, "Methods: "+strings.Join(rule.Methods, ", "))
+	}
+	names := make([]string, 0, len(rule.Headers))
+	for name := range rule.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		lines = append(lines, fmt.Sprintf("Header: %s matches %s", name, rule.Headers[name]))
+	}
+	for _, bm := range rule.Body {
+		m := proxy.FilterBodyMatch{Path: bm.Path, In: bm.In, NotIn: bm.NotIn, Pattern: bm.Pattern}
+		lines = append(lines, "Body: "+m.String())
+	}
+	return lines
+}
+
 // DomainStats holds statistics for a domain.
 type DomainStats struct {
 	Domain       string
@@ -115,19 +163,26 @@ type DomainStats struct {
 	Paths        map[string]int
 }
 
-func runFilterGenerate(fromLogs, project string, minRequests int, outputFile, defaultAction string) error {
-	logDir, err := resolveLogDir(fromLogs, project)
-	if err != nil {
-		return err
-	}
+func runFilterGenerate(fromLogs, fromHAR, project string, minRequests int, outputFile, defaultAction string) error {
+	var stats []DomainStats
+	if fromHAR != "" {
+		var err error
+		if stats, err = analyzeHAR(fromHAR); err != nil {
+			return fmt.Errorf("failed to analyze HAR file: %w", err)
+		}
+	} else {
+		logDir, err := resolveLogDir(fromLogs, project)
+		if err != nil {
+			return err
+		}
 
-	if _, err := os.Stat(logDir); os.IsNotExist(err) {
-		return fmt.Errorf("log directory not found: %s", logDir)
-	}
+		if _, err := os.Stat(logDir); os.IsNotExist(err) {
+			return fmt.Errorf("log directory not found: %s", logDir)
+		}
 
-	stats, err := analyzeProxyLogs(logDir)
-	if err != nil {
-		return fmt.Errorf("failed to analyze logs: %w", err)
+		if stats, err = analyzeProxyLogs(logDir); err != nil {
+			return fmt.Errorf("failed to analyze logs: %w", err)
+		}
 	}
 	if len(stats) == 0 {
 		notice.Info("No requests found in logs.")
@@ -210,13 +265,40 @@ func analyzeProxyLogs(logDir string) ([]DomainStats, error) {
 		}
 	}
 
-	// Convert to slice
+	return domainStatsList(domainMap), nil
+}
+
+// analyzeHAR gathers the same statistics as analyzeProxyLogs from the entries
+// of an HTTP Archive.
+func analyzeHAR(path string) ([]DomainStats, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	har, err := proxy.ReadHAR(f)
+	if err != nil {
+		return nil, err
+	}
+	domainMap := make(map[string]*DomainStats)
+	for _, e := range har.Log.Entries {
+		// Browsers also record data:, blob: and extension URLs, which never
+		// reach the network.
+		if u, err := url.Parse(e.Request.URL); err != nil || u.Host == "" {
+			continue
+		}
+		recordRequest(domainMap, e.Request.Method, e.Request.URL, e.Response.Status)
+	}
+	return domainStatsList(domainMap), nil
+}
+
+func domainStatsList(domainMap map[string]*DomainStats) []DomainStats {
 	var result []DomainStats
 	for _, stats := range domainMap {
 		result = append(result, *stats)
 	}
-
-	return result, nil
+	return result
 }
 
 func processLogFile(path string, domainMap map[string]*DomainStats) error {
@@ -248,39 +330,43 @@ func processLogFile(path string, domainMap map[string]*DomainStats) error {
 		if err := json.Unmarshal([]byte(line), &entry); err != nil {
 			continue
 		}
+		recordRequest(domainMap, entry.Method, entry.URL, entry.StatusCode)
+	}
 
-		// Extract domain from URL
-		parsedURL, err := url.Parse(entry.URL)
-		if err != nil {
-			continue
-		}
+	return reader.Err()
+}
 
-		domain := proxy.NormalizeHost(parsedURL.Host)
+// recordRequest counts one request towards its domain's statistics.
+func recordRequest(domainMap map[string]*DomainStats, method, rawURL string, statusCode int) {
+	// Extract domain from URL
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil {
+		return
+	}
 
-		// Update stats
-		stats, ok := domainMap[domain]
-		if !ok {
-			stats = &DomainStats{
-				Domain:      domain,
-				Methods:     make(map[string]int),
-				StatusCodes: make(map[int]int),
-				Paths:       make(map[string]int),
-			}
-			domainMap[domain] = stats
-		}
+	domain := proxy.NormalizeHost(parsedURL.Host)
 
-		stats.RequestCount++
-		stats.Methods[entry.Method]++
-		if entry.StatusCode > 0 {
-			stats.StatusCodes[entry.StatusCode]++
+	// Update stats
+	stats, ok := domainMap[domain]
+	if !ok {
+		stats = &DomainStats{
+			Domain:      domain,
+			Methods:     make(map[string]int),
+			StatusCodes: make(map[int]int),
+			Paths:       make(map[string]int),
 		}
+		domainMap[domain] = stats
+	}
 
-		// Track unique paths (truncate to first 2 segments)
-		pathKey := truncatePath(parsedURL.Path)
-		stats.Paths[pathKey]++
+	stats.RequestCount++
+	stats.Methods[method]++
+	if statusCode > 0 {
+		stats.StatusCodes[statusCode]++
 	}
 
-	return reader.Err()
+	// Track unique paths (truncate to first 2 segments)
+	pathKey := truncatePath(parsedURL.Path)
+	stats.Paths[pathKey]++
 }
 
 func truncatePath(path string) string {
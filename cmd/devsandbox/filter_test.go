@@ -0,0 +1,78 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"devsandbox/internal/config"
+)
+
+func TestFilterRuleMatcherLines(t *testing.T) {
+	if got := filterRuleMatcherLines(config.ProxyFilterRule{Pattern: "example.com"}); len(got) != 0 {
+		t.Errorf("plain rule produced matcher lines: %v", got)
+	}
+
+	rule := config.ProxyFilterRule{
+		Pattern: "api.openai.com",
+		Ports:   []int{443, 8443},
+		Methods: []string{"POST", "PUT"},
+		Headers: map[string]string{"X-B": "b*", "Content-Type": "application/json*"},
+		Body: []config.ProxyFilterBodyMatch{
+			{Path: "$.model", NotIn: []string{"gpt-4.1-mini", "gpt-4.1"}},
+			{Path: "$.stream", In: []string{"true"}},
+			{Path: "$.user", Pattern: "agent-*"},
+			{Path: "$.tools"},
+		},
+	}
+	want := []string{
+		"Ports: 443, 8443",
+		"Methods: POST, PUT",
+		"Header: Content-Type matches application/json*",
+		"Header: X-B matches b*",
+		"Body: $.model not in [gpt-4.1-mini, gpt-4.1]",
+		"Body: $.stream in [true]",
+		"Body: $.user matches agent-*",
+		"Body: $.tools exists",
+	}
+	if got := filterRuleMatcherLines(rule); !reflect.DeepEqual(got, want) {
+		t.Errorf("filterRuleMatcherLines =\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestAnalyzeHAR(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.har")
+	har := `{"log":{"version":"1.2","creator":{"name":"test","version":"1"},"entries":[
+		{"request":{"method":"GET","url":"https://API.GitHub.com/repos/a/b/pulls"},"response":{"status":200}},
+		{"request":{"method":"POST","url":"https://api.github.com/graphql"},"response":{"status":200}},
+		{"request":{"method":"GET","url":"https://example.com/"},"response":{"status":0}},
+		{"request":{"method":"GET","url":"data:image/png;base64,AAAA"},"response":{"status":200}}
+	]}}`
+	if err := os.WriteFile(path, []byte(har), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := analyzeHAR(path)
+	if err != nil {
+		t.Fatalf("analyzeHAR: %v", err)
+	}
+	byDomain := make(map[string]DomainStats)
+	for _, s := range stats {
+		byDomain[s.Domain] = s
+	}
+	if len(byDomain) != 2 {
+		t.Fatalf("domains = %v, want api.github.com and example.com", byDomain)
+	}
+	gh := byDomain["api.github.com"]
+	if gh.RequestCount != 2 || gh.Methods["POST"] != 1 || gh.StatusCodes[200] != 2 || gh.Paths["/repos/a/..."] != 1 {
+		t.Errorf("api.github.com = %+v", gh)
+	}
+	if ex := byDomain["example.com"]; ex.RequestCount != 1 || len(ex.StatusCodes) != 0 {
+		t.Errorf("example.com = %+v", ex)
+	}
+
+	if _, err := analyzeHAR(filepath.Join(t.TempDir(), "missing.har")); err == nil {
+		t.Error("analyzeHAR of a missing file: no error")
+	}
+}
@@ -2,9 +2,13 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
@@ -21,6 +25,7 @@ import (
 func newForwardCmd() *cobra.Command {
 	var name string
 	var bind string
+	var reverse bool
 
 	cmd := &cobra.Command{
 		Use:   "forward [flags] <port_spec> [port_spec...]",
@@ -30,19 +35,31 @@ func newForwardCmd() *cobra.Command {
 Port spec format: <sandbox_port>[:<host_port>]
 
 If host_port is omitted, it defaults to sandbox_port. The forwarder runs
-in the foreground until Ctrl+C is pressed or the sandbox exits.`,
+in the foreground until Ctrl+C is pressed or the sandbox exits.
+
+With --reverse the direction flips: a port is opened on the sandbox's
+127.0.0.1 and each connection to it is relayed to the host - to a host
+TCP port, or to a host unix socket given by its path.
+
+Reverse spec format: <sandbox_port>[:<host_port>|:<host_socket_path>]`,
 		Example: `  devsandbox forward 3000
   devsandbox forward 3000:8080
   devsandbox forward --name myproject 3000 5432:5433
-  devsandbox forward --bind 0.0.0.0 3000`,
+  devsandbox forward --bind 0.0.0.0 3000
+  devsandbox forward --reverse 5432:/var/run/postgresql/.s.PGSQL.5432
+  devsandbox forward -R 9229`,
 		Args: cobra.MinimumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return runForward(cmd.Context(), name, bind, args)
+			if reverse && cmd.Flags().Changed("bind") {
+				return errors.New("--bind applies to host-side listeners and cannot be combined with --reverse")
+			}
+			return runForward(cmd.Context(), name, bind, reverse, args)
 		},
 	}
 
 	cmd.Flags().StringVarP(&name, "name", "n", "", "Target sandbox by name (auto-select if one running)")
 	cmd.Flags().StringVarP(&bind, "bind", "b", "127.0.0.1", "Bind address for host-side listeners")
+	cmd.Flags().BoolVarP(&reverse, "reverse", "R", false, "Forward from the sandbox to a host port or unix socket")
 
 	return cmd
 }
@@ -76,6 +93,36 @@ func parsePortSpec(spec string) (sandboxPort, hostPort int, err error) {
 	}
 }
 
+// parseReverseSpec parses a reverse spec of the form "<sandbox_port>",
+// "<sandbox_port>:<host_port>" or "<sandbox_port>:<host_socket_path>" into the
+// sandbox port and the host target to dial. A target containing a "/" is a
+// unix socket path; a relative one is resolved against the working directory.
+func parseReverseSpec(spec string) (sandboxPort int, network, address string, err error) {
+	portPart, target, hasTarget := strings.Cut(spec, ":")
+	sandboxPort, err = parsePort(portPart)
+	if err != nil {
+		return 0, "", "", fmt.Errorf("invalid reverse spec %q: sandbox port: %w", spec, err)
+	}
+	if !hasTarget {
+		return sandboxPort, "tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(sandboxPort)), nil
+	}
+	if target == "" {
+		return 0, "", "", fmt.Errorf("invalid reverse spec %q: empty host target", spec)
+	}
+	if strings.Contains(target, "/") {
+		path, err := filepath.Abs(target)
+		if err != nil {
+			return 0, "", "", fmt.Errorf("invalid reverse spec %q: %w", spec, err)
+		}
+		return sandboxPort, "unix", path, nil
+	}
+	hostPort, err := parsePort(target)
+	if err != nil {
+		return 0, "", "", fmt.Errorf("invalid reverse spec %q: host port: %w", spec, err)
+	}
+	return sandboxPort, "tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(hostPort)), nil
+}
+
 // parsePort parses a single port number string and validates it is in range 1–65535.
 func parsePort(s string) (int, error) {
 	n, err := strconv.Atoi(s)
@@ -135,7 +182,7 @@ func sessionNames(sessions []*session.Session) []string {
 	return names
 }
 
-func runForward(_ context.Context, name, bind string, portSpecs []string) error {
+func runForward(_ context.Context, name, bind string, reverse bool, portSpecs []string) error {
 	// 1. Open store and clean stale sessions.
 	store, err := session.DefaultStore()
 	if err != nil {
@@ -157,53 +204,38 @@ func runForward(_ context.Context, name, bind string, portSpecs []string) error
 		}
 	}
 
-	// 3. Parse all port specs.
-	type portMapping struct{ sandboxPort, hostPort int }
-	mappings := make([]portMapping, 0, len(portSpecs))
-	for _, spec := range portSpecs {
-		sp, hp, e := parsePortSpec(spec)
-		if e != nil {
-			return e
-		}
-		mappings = append(mappings, portMapping{sp, hp})
-	}
-
-	// 4. Create namespace dialer and start forwarders.
+	// 3. Start the forwarders.
 	dialer := portforward.NewNamespaceDialer(sess.PID)
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	var forwarders []*portforward.Forwarder
-	for _, m := range mappings {
-		fwd := &portforward.Forwarder{
-			HostPort:    m.hostPort,
-			SandboxPort: m.sandboxPort,
-			Bind:        bind,
-			Dialer:      dialer,
-		}
-		if err := fwd.Start(ctx); err != nil {
-			for _, started := range forwarders {
-				started.Stop()
-			}
-			return fmt.Errorf("failed to forward port %d: %w", m.hostPort, err)
-		}
-		forwarders = append(forwarders, fwd)
-		notice.Info("Forwarding %s:%d → sandbox:%d (%s)",
-			bind, fwd.ActualHostPort(), m.sandboxPort, sess.Name)
+	var stops []func()
+	var records []session.ForwardedPort
+	if reverse {
+		stops, records, err = startReverseForwards(ctx, dialer, sess.Name, portSpecs)
+	} else {
+		stops, records, err = startForwards(ctx, dialer, sess.Name, bind, portSpecs)
 	}
-
-	// 5. Update session registry with forwarded ports.
-	for _, m := range mappings {
-		sess.ForwardedPorts = append(sess.ForwardedPorts, session.ForwardedPort{
-			HostPort:    m.hostPort,
-			SandboxPort: m.sandboxPort,
-			Bind:        bind,
-			Protocol:    "tcp",
-		})
+	if err != nil {
+		return err
 	}
+
+	// 4. Update session registry with forwarded ports, and drop them again
+	// once forwarding stops.
+	sess.ForwardedPorts = append(sess.ForwardedPorts, records...)
 	_ = store.Update(sess)
+	defer func() {
+		current, err := store.Get(sess.Name)
+		if err != nil {
+			return
+		}
+		current.ForwardedPorts = slices.DeleteFunc(current.ForwardedPorts, func(fp session.ForwardedPort) bool {
+			return slices.Contains(records, fp)
+		})
+		_ = store.Update(current)
+	}()
 
-	// 6. Wait for signal or sandbox exit.
+	// 5. Wait for signal or sandbox exit.
 	notice.Info("\nPress Ctrl+C to stop forwarding.")
 
 	sigChan := make(chan os.Signal, 1)
@@ -238,14 +270,104 @@ func runForward(_ context.Context, name, bind string, portSpecs []string) error
 		notice.Info("\nSandbox exited, stopping port forwards...")
 	}
 
-	// 7. Stop all forwarders concurrently.
+	// 6. Stop all forwarders concurrently.
 	cancel()
 	var wg sync.WaitGroup
-	for _, fwd := range forwarders {
-		wg.Go(func() {
-			fwd.Stop()
-		})
+	for _, stop := range stops {
+		wg.Go(stop)
 	}
 	wg.Wait()
 	return nil
 }
+
+// startForwards starts a host→sandbox forwarder per port spec. On error the
+// ones already started are stopped.
+func startForwards(ctx context.Context, dialer portforward.Dialer, sessName, bind string, portSpecs []string) ([]func(), []session.ForwardedPort, error) {
+	type portMapping struct{ sandboxPort, hostPort int }
+	mappings := make([]portMapping, 0, len(portSpecs))
+	for _, spec := range portSpecs {
+		sp, hp, e := parsePortSpec(spec)
+		if e != nil {
+			return nil, nil, e
+		}
+		mappings = append(mappings, portMapping{sp, hp})
+	}
+
+	var stops []func()
+	var records []session.ForwardedPort
+	for _, m := range mappings {
+		fwd := &portforward.Forwarder{
+			HostPort:    m.hostPort,
+			SandboxPort: m.sandboxPort,
+			Bind:        bind,
+			Dialer:      dialer,
+		}
+		if err := fwd.Start(ctx); err != nil {
+			for _, stop := range stops {
+				stop()
+			}
+			return nil, nil, fmt.Errorf("failed to forward port %d: %w", m.hostPort, err)
+		}
+		stops = append(stops, fwd.Stop)
+		notice.Info("Forwarding %s:%d → sandbox:%d (%s)",
+			bind, fwd.ActualHostPort(), m.sandboxPort, sessName)
+		records = append(records, session.ForwardedPort{
+			HostPort:    m.hostPort,
+			SandboxPort: m.sandboxPort,
+			Bind:        bind,
+			Protocol:    "tcp",
+		})
+	}
+	return stops, records, nil
+}
+
+// startReverseForwards starts a sandbox→host forwarder per reverse spec. On
+// error the ones already started are stopped.
+func startReverseForwards(ctx context.Context, listener portforward.NamespaceListener, sessName string, specs []string) ([]func(), []session.ForwardedPort, error) {
+	forwarders := make([]*portforward.ReverseForwarder, 0, len(specs))
+	for _, spec := range specs {
+		sp, network, address, err := parseReverseSpec(spec)
+		if err != nil {
+			return nil, nil, err
+		}
+		forwarders = append(forwarders, &portforward.ReverseForwarder{
+			SandboxPort:   sp,
+			TargetNetwork: network,
+			TargetAddress: address,
+			Listener:      listener,
+		})
+	}
+
+	var stops []func()
+	var records []session.ForwardedPort
+	for _, fwd := range forwarders {
+		if fwd.TargetNetwork == "unix" {
+			if _, err := os.Stat(fwd.TargetAddress); err != nil {
+				notice.Warn("Host socket %s is not there yet: %v", fwd.TargetAddress, err)
+			}
+		}
+		if err := fwd.Start(ctx); err != nil {
+			for _, stop := range stops {
+				stop()
+			}
+			return nil, nil, fmt.Errorf("failed to forward sandbox port %d: %w", fwd.SandboxPort, err)
+		}
+		stops = append(stops, fwd.Stop)
+		notice.Info("Forwarding sandbox:%d → %s (%s)", fwd.SandboxPort, fwd.TargetAddress, sessName)
+
+		record := session.ForwardedPort{
+			SandboxPort: fwd.SandboxPort,
+			Bind:        "127.0.0.1",
+			Protocol:    "tcp",
+			Direction:   session.DirectionReverse,
+		}
+		if fwd.TargetNetwork == "unix" {
+			record.HostSocket = fwd.TargetAddress
+		} else {
+			_, port, _ := net.SplitHostPort(fwd.TargetAddress)
+			record.HostPort, _ = strconv.Atoi(port)
+		}
+		records = append(records, record)
+	}
+	return stops, records, nil
+}
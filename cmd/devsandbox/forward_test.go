@@ -2,6 +2,7 @@ package main
 
 import (
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -43,6 +44,49 @@ func TestParsePortSpec(t *testing.T) {
 	}
 }
 
+func TestParseReverseSpec(t *testing.T) {
+	tests := []struct {
+		input       string
+		sandboxPort int
+		network     string
+		address     string
+		wantErr     bool
+	}{
+		{"9229", 9229, "tcp", "127.0.0.1:9229", false},
+		{"9229:9230", 9229, "tcp", "127.0.0.1:9230", false},
+		{"5432:/var/run/postgresql/.s.PGSQL.5432", 5432, "unix", "/var/run/postgresql/.s.PGSQL.5432", false},
+		{"0", 0, "", "", true},
+		{"5432:", 0, "", "", true},
+		{":5432", 0, "", "", true},
+		{"5432:abc", 0, "", "", true},
+		{"5432:99999", 0, "", "", true},
+	}
+	for _, tt := range tests {
+		sandboxPort, network, address, err := parseReverseSpec(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseReverseSpec(%q): err = %v, wantErr = %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if sandboxPort != tt.sandboxPort || network != tt.network || address != tt.address {
+			t.Errorf("parseReverseSpec(%q) = %d, %s, %s; want %d, %s, %s",
+				tt.input, sandboxPort, network, address, tt.sandboxPort, tt.network, tt.address)
+		}
+	}
+}
+
+func TestParseReverseSpec_RelativeSocket(t *testing.T) {
+	_, network, address, err := parseReverseSpec("5432:./run/pg.sock")
+	if err != nil {
+		t.Fatalf("parseReverseSpec: %v", err)
+	}
+	if network != "unix" || !filepath.IsAbs(address) || !strings.HasSuffix(address, "/run/pg.sock") {
+		t.Errorf("got %s %s, want an absolute unix socket path", network, address)
+	}
+}
+
 func TestResolveSession_ExplicitName(t *testing.T) {
 	store := newForwardTestStore(t)
 	registerLive(t, store, "alpha", "/work/alpha")
@@ -27,6 +27,7 @@ import (
 	"devsandbox/internal/notice"
 	"devsandbox/internal/proxy"
 	"devsandbox/internal/sandbox"
+	"devsandbox/internal/version"
 )
 
 func newLogsCmd() *cobra.Command {
@@ -236,6 +237,7 @@ func newLogsProxyCmd() *cobra.Command {
 		noColor      bool
 		compact      bool
 		stats        bool
+		format       string
 	)
 
 	cmd := &cobra.Command{
@@ -254,7 +256,11 @@ Time filters support multiple formats:
 Status filters support:
   - Single value: --status 200
   - Range: --status 400-599
-  - Comparison: --status ">=400"`,
+  - Comparison: --status ">=400"
+
+--format har writes an HTTP Archive 1.2 for browser devtools, Charles and
+other HTTP tools. It always carries the recorded bodies, and exports every
+matching entry unless --last is given.`,
 		Example: `  devsandbox logs proxy                      # All logs for current project
   devsandbox logs proxy myproject            # Logs for specific sandbox
   devsandbox logs proxy --last 50            # Show last 50 requests
@@ -266,74 +272,46 @@ Status filters support:
   devsandbox logs proxy --url /api --method POST  # Filter by URL and method
   devsandbox logs proxy --json               # JSON output
   devsandbox logs proxy --compact            # Compact one-line format
-  devsandbox logs proxy --stats              # Show statistics summary`,
+  devsandbox logs proxy --format har > session.har  # HTTP Archive export
+  devsandbox logs proxy --stats              # Show statistics summary
+  devsandbox logs proxy replay 3f2a9c1e0b4d  # Re-send a request, diff the response`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			homeDir, err := os.UserHomeDir()
-			if err != nil {
-				return err
-			}
-
-			// Build filter
-			filter := &ProxyLogFilter{
-				URL:        filterURL,
-				Method:     filterMethod,
-				ErrorsOnly: errorsOnly,
-			}
-
-			// Parse time filters
-			if since != "" {
-				t, err := ParseTimeFilter(since)
-				if err != nil {
-					return err
+			switch {
+			case format == "":
+				format = "table"
+				if jsonOutput {
+					format = "json"
+				} else if compact {
+					format = "compact"
 				}
-				filter.Since = t
+			case !slices.Contains(proxyLogFormats, format):
+				return fmt.Errorf("unknown --format %q (want %s)", format, strings.Join(proxyLogFormats, ", "))
+			case jsonOutput || compact:
+				return fmt.Errorf("--format cannot be combined with --json or --compact")
 			}
-			if until != "" {
-				t, err := ParseTimeFilter(until)
-				if err != nil {
-					return err
-				}
-				filter.Until = t
+			if format == "har" && follow {
+				return fmt.Errorf("--format har cannot be combined with --follow")
 			}
 
-			// Parse status filter
-			if filterStatus != "" {
-				exact, min, max, err := ParseStatusFilter(filterStatus)
-				if err != nil {
-					return err
-				}
-				filter.StatusCode = exact
-				filter.StatusMin = min
-				filter.StatusMax = max
+			filter, err := buildProxyLogFilter(filterURL, filterMethod, filterStatus, since, until, errorsOnly)
+			if err != nil {
+				return err
 			}
 
-			// Determine sandbox name
 			name := sandboxName
 			if len(args) > 0 {
 				name = args[0]
 			}
-			if name == "" {
-				cwd, err := os.Getwd()
-				if err != nil {
-					return err
-				}
-				name = sandbox.GenerateSandboxName(cwd)
-			}
-
-			baseDir := sandbox.SandboxBasePath(homeDir)
-			sandboxRoot := filepath.Join(baseDir, name)
-			logDir := filepath.Join(sandboxRoot, proxy.LogBaseDirName, proxy.ProxyLogDirName)
-
-			// Check if log directory exists
-			if _, err := os.Stat(logDir); os.IsNotExist(err) {
-				return fmt.Errorf("no logs found for sandbox %q (run with --proxy to capture logs)", name)
+			_, logDir, err := resolveProxyLogDir(name)
+			if err != nil {
+				return err
 			}
 
 			if follow {
-				return followProxyLogs(logDir, filter, jsonOutput, showBody, compact, noColor)
+				return followProxyLogs(logDir, filter, format == "json", showBody, format == "compact", noColor)
 			}
 
-			return viewProxyLogs(logDir, filter, last, jsonOutput, showBody, compact, noColor, stats)
+			return viewProxyLogs(logDir, filter, last, format, showBody, noColor, stats)
 		},
 	}
 
@@ -341,6 +319,7 @@ Status filters support:
 	cmd.Flags().IntVarP(&last, "last", "n", 0, "Show only last N entries (default: 100)")
 	cmd.Flags().BoolVarP(&follow, "follow", "f", false, "Follow/tail log output")
 	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Output in JSON format")
+	cmd.Flags().StringVar(&format, "format", "", "Output format: table, compact, json or har")
 	cmd.Flags().BoolVar(&showBody, "body", false, "Include request/response bodies")
 	cmd.Flags().StringVar(&filterURL, "url", "", "Filter by URL (substring match)")
 	cmd.Flags().StringVar(&filterMethod, "method", "", "Filter by HTTP method")
@@ -352,10 +331,78 @@ Status filters support:
 	cmd.Flags().BoolVar(&compact, "compact", false, "Compact one-line output format")
 	cmd.Flags().BoolVar(&stats, "stats", false, "Show summary statistics")
 
+	cmd.AddCommand(newLogsProxyReplayCmd())
+
 	return cmd
 }
 
-func viewProxyLogs(logDir string, filter *ProxyLogFilter, last int, jsonOutput, showBody, compact, noColor, showStats bool) error {
+// buildProxyLogFilter parses the filter flags shared by `logs proxy` and
+// `logs proxy replay`.
+func buildProxyLogFilter(url, method, status, since, until string, errorsOnly bool) (*ProxyLogFilter, error) {
+	filter := &ProxyLogFilter{
+		URL:        url,
+		Method:     method,
+		ErrorsOnly: errorsOnly,
+	}
+
+	// Parse time filters
+	if since != "" {
+		t, err := ParseTimeFilter(since)
+		if err != nil {
+			return nil, err
+		}
+		filter.Since = t
+	}
+	if until != "" {
+		t, err := ParseTimeFilter(until)
+		if err != nil {
+			return nil, err
+		}
+		filter.Until = t
+	}
+
+	// Parse status filter
+	if status != "" {
+		exact, min, max, err := ParseStatusFilter(status)
+		if err != nil {
+			return nil, err
+		}
+		filter.StatusCode = exact
+		filter.StatusMin = min
+		filter.StatusMax = max
+	}
+	return filter, nil
+}
+
+// resolveProxyLogDir returns the sandbox root and proxy log directory of the
+// named sandbox, or of the current directory's when name is empty.
+func resolveProxyLogDir(name string) (sandboxRoot, logDir string, err error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", "", err
+	}
+	if name == "" {
+		cwd, err := os.Getwd()
+		if err != nil {
+			return "", "", err
+		}
+		name = sandbox.GenerateSandboxName(cwd)
+	}
+
+	sandboxRoot = filepath.Join(sandbox.SandboxBasePath(homeDir), name)
+	logDir = filepath.Join(sandboxRoot, proxy.LogBaseDirName, proxy.ProxyLogDirName)
+
+	// Check if log directory exists
+	if _, err := os.Stat(logDir); os.IsNotExist(err) {
+		return "", "", fmt.Errorf("no logs found for sandbox %q (run with --proxy to capture logs)", name)
+	}
+	return sandboxRoot, logDir, nil
+}
+
+// proxyLogFormats are the values `logs proxy --format` accepts.
+var proxyLogFormats = []string{"table", "compact", "json", "har"}
+
+func viewProxyLogs(logDir string, filter *ProxyLogFilter, last int, format string, showBody, noColor, showStats bool) error {
 	// Rejected up front rather than clamped: the trims below index
 	// entries[len(entries)-last:], so a negative value indexes past the end and
 	// panics. `logs internal` guards its own --last with `last > 0`.
@@ -363,33 +410,90 @@ func viewProxyLogs(logDir string, filter *ProxyLogFilter, last int, jsonOutput,
 		return fmt.Errorf("--last must not be negative, got %d", last)
 	}
 
+	files, err := proxyLogFiles(logDir)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		fmt.Println("No log files found.")
+		return nil
+	}
+
+	// If --last not specified, default to last 100 entries. An archive is
+	// an export rather than a glance at recent traffic, so it takes them all.
+	if last == 0 && format != "har" {
+		last = 100
+	}
+	entries := readProxyLogs(files, last)
+
+	// Apply filter
+	var filtered []proxy.RequestLog
+	for _, e := range entries {
+		if filter.Match(&e) {
+			filtered = append(filtered, e)
+		}
+	}
+	entries = filtered
+
+	// An empty archive is still one, and keeps stdout a valid HAR file.
+	if len(entries) == 0 && format != "har" {
+		fmt.Println("No matching log entries.")
+		return nil
+	}
+
+	// Apply --last limit
+	if last > 0 && len(entries) > last {
+		entries = entries[len(entries)-last:]
+	}
+
+	// Output
+	switch format {
+	case "json":
+		return printProxyLogsJSON(entries, showBody)
+	case "har":
+		return printProxyLogsHAR(entries)
+	case "compact":
+		return printProxyLogsCompact(entries, noColor)
+	}
+
+	err = printProxyLogsTable(entries, showBody, noColor)
+	if err != nil {
+		return err
+	}
+
+	if showStats {
+		printProxyStats(entries)
+	}
+
+	return nil
+}
+
+// proxyLogFiles lists a log directory's request logs, archives and the
+// active file alike, in chronological order.
+func proxyLogFiles(logDir string) ([]string, error) {
 	// Find both compressed and uncompressed log files
 	activePattern := filepath.Join(logDir, proxy.RequestLogPrefix+"*"+proxy.RequestLogSuffix)
 	archivePattern := filepath.Join(logDir, proxy.RequestLogPrefix+"*"+proxy.RequestLogArchiveSuffix)
 
 	activeFiles, err := filepath.Glob(activePattern)
 	if err != nil {
-		return fmt.Errorf("invalid log pattern: %w", err)
+		return nil, fmt.Errorf("invalid log pattern: %w", err)
 	}
 	archiveFiles, err := filepath.Glob(archivePattern)
 	if err != nil {
-		return fmt.Errorf("invalid archive pattern: %w", err)
+		return nil, fmt.Errorf("invalid archive pattern: %w", err)
 	}
 
 	files := append(archiveFiles, activeFiles...)
-	if len(files) == 0 {
-		fmt.Println("No log files found.")
-		return nil
-	}
 
 	// Sort files by name (chronological order)
 	sort.Strings(files)
+	return files, nil
+}
 
-	// If --last not specified, default to last 100 entries
-	if last == 0 {
-		last = 100
-	}
-
+// readProxyLogs reads the newest last entries of files, or all of them when
+// last is 0, warning about any file that could not be read whole.
+func readProxyLogs(files []string, last int) []proxy.RequestLog {
 	// Read entries from files (newest first, stop when we have enough)
 	var entries []proxy.RequestLog
 
@@ -417,53 +521,17 @@ func viewProxyLogs(logDir string, filter *ProxyLogFilter, last int, jsonOutput,
 		entries = append(fileEntries, entries...)
 
 		// Trim to limit
-		if len(entries) > last {
+		if last > 0 && len(entries) > last {
 			entries = entries[len(entries)-last:]
 		}
 
 		// Stop if we have enough entries
-		if len(entries) >= last {
+		if last > 0 && len(entries) >= last {
 			break
 		}
 	}
 
-	// Apply filter
-	var filtered []proxy.RequestLog
-	for _, e := range entries {
-		if filter.Match(&e) {
-			filtered = append(filtered, e)
-		}
-	}
-	entries = filtered
-
-	if len(entries) == 0 {
-		fmt.Println("No matching log entries.")
-		return nil
-	}
-
-	// Apply --last limit
-	if last > 0 && len(entries) > last {
-		entries = entries[len(entries)-last:]
-	}
-
-	// Output
-	if jsonOutput {
-		return printProxyLogsJSON(entries, showBody)
-	}
-	if compact {
-		return printProxyLogsCompact(entries, noColor)
-	}
-
-	err = printProxyLogsTable(entries, showBody, noColor)
-	if err != nil {
-		return err
-	}
-
-	if showStats {
-		printProxyStats(entries)
-	}
-
-	return nil
+	return entries
 }
 
 func followProxyLogs(logDir string, filter *ProxyLogFilter, jsonOutput, showBody, compact, noColor bool) error {
@@ -834,13 +902,19 @@ func printProxyLogsJSON(entries []proxy.RequestLog, showBody bool) error {
 	return encoder.Encode(output)
 }
 
+func printProxyLogsHAR(entries []proxy.RequestLog) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(proxy.NewHAR(entries, version.Version))
+}
+
 func printProxyLogsTable(entries []proxy.RequestLog, showBody, noColor bool) error {
 	table := tablewriter.NewWriter(os.Stdout)
 
 	if showBody {
-		table.Header("TIME", "METHOD", "STATUS", "DURATION", "URL", "REQ BODY", "RESP BODY")
+		table.Header("ID", "TIME", "METHOD", "STATUS", "DURATION", "URL", "REQ BODY", "RESP BODY")
 	} else {
-		table.Header("TIME", "METHOD", "STATUS", "DURATION", "URL")
+		table.Header("ID", "TIME", "METHOD", "STATUS", "DURATION", "URL")
 	}
 
 	for _, e := range entries {
@@ -874,6 +948,7 @@ func printProxyLogsTable(entries []proxy.RequestLog, showBody, noColor bool) err
 				respBody = "-"
 			}
 			_ = table.Append(
+				e.LogID(),
 				e.Timestamp.Format("15:04:05"),
 				e.Method,
 				status,
@@ -884,6 +959,7 @@ func printProxyLogsTable(entries []proxy.RequestLog, showBody, noColor bool) err
 			)
 		} else {
 			_ = table.Append(
+				e.LogID(),
 				e.Timestamp.Format("15:04:05"),
 				e.Method,
 				status,
@@ -918,7 +994,8 @@ func printProxyLogCompactLine(e *proxy.RequestLog, noColor bool) {
 		duration = fmt.Sprintf("%dms", e.Duration.Milliseconds())
 	}
 
-	fmt.Printf("%s %s %s %s %s\n",
+	fmt.Printf("%s %s %s %s %s %s\n",
+		e.LogID(),
 		e.Timestamp.Format("15:04:05"),
 		e.Method,
 		status,
@@ -942,7 +1019,8 @@ func printProxyLogLine(e *proxy.RequestLog, showBody, noColor bool) {
 		duration = e.Duration.Round(time.Millisecond).String()
 	}
 
-	fmt.Printf("%s | %s | %s | %s | %s\n",
+	fmt.Printf("%s | %s | %s | %s | %s | %s\n",
+		e.LogID(),
 		e.Timestamp.Format("15:04:05"),
 		e.Method,
 		status,
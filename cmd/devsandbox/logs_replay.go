@@ -0,0 +1,369 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+	"unicode/utf8"
+
+	"github.com/spf13/cobra"
+
+	"devsandbox/internal/config"
+	"devsandbox/internal/notice"
+	"devsandbox/internal/overlay"
+	"devsandbox/internal/prompt"
+	"devsandbox/internal/proxy"
+)
+
+// maxReplayBodyBytes bounds how much of a replayed response is read for the
+// diff.
+const maxReplayBodyBytes = 16 << 20
+
+// replayIgnoredHeaders differ on every response and would bury the headers
+// that changed for a reason.
+var replayIgnoredHeaders = []string{"Date"}
+
+func newLogsProxyReplayCmd() *cobra.Command {
+	var (
+		sandboxName  string
+		last         int
+		filterURL    string
+		filterMethod string
+		filterStatus string
+		since        string
+		until        string
+		errorsOnly   bool
+		yes          bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "replay [id]",
+		Short: "Re-send logged requests and diff the responses",
+		Long: `Re-send a logged request through the proxy pipeline - filter, credential
+injection, redaction and limits, as configured for the current directory - and
+show the new response diffed against the recorded one.
+
+Name the entry by the ID shown in 'devsandbox logs proxy' (a unique prefix is
+enough), or select entries with the filter flags: the newest match is
+replayed, or the newest --last N.
+
+A request is refused when its log entry does not hold what was sent: a body or
+header set truncated by the capture limit, or one a redaction rule rewrote.
+Credentials withheld from the log are not sent; credential injection adds them
+back where it is configured to. Methods other than GET, HEAD and OPTIONS are
+confirmed first unless --yes is given.`,
+		Example: `  devsandbox logs proxy replay 3f2a9c1e0b4d
+  devsandbox logs proxy replay 3f2a               # Unique ID prefix
+  devsandbox logs proxy replay --url /v1/chat --errors
+  devsandbox logs proxy replay --method POST --last 3 --yes`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if last < 1 {
+				return fmt.Errorf("--last must be at least 1, got %d", last)
+			}
+			id := ""
+			if len(args) > 0 {
+				id = args[0]
+			}
+			filtered := filterURL != "" || filterMethod != "" || filterStatus != "" || since != "" || until != "" || errorsOnly
+			if id == "" && !filtered {
+				return errors.New("name an entry ID, or select entries with --url, --method, --status, --since, --until or --errors")
+			}
+			filter, err := buildProxyLogFilter(filterURL, filterMethod, filterStatus, since, until, errorsOnly)
+			if err != nil {
+				return err
+			}
+
+			sandboxRoot, logDir, err := resolveProxyLogDir(sandboxName)
+			if err != nil {
+				return err
+			}
+			files, err := proxyLogFiles(logDir)
+			if err != nil {
+				return err
+			}
+			entries, err := selectReplayEntries(readProxyLogs(files, 0), id, filter, last)
+			if err != nil {
+				return err
+			}
+			return runProxyReplay(cmd, sandboxRoot, entries, yes)
+		},
+	}
+
+	cmd.Flags().StringVarP(&sandboxName, "sandbox", "s", "", "Sandbox name (default: current directory)")
+	cmd.Flags().IntVarP(&last, "last", "n", 1, "Replay the newest N matching entries")
+	cmd.Flags().StringVar(&filterURL, "url", "", "Filter by URL (substring match)")
+	cmd.Flags().StringVar(&filterMethod, "method", "", "Filter by HTTP method")
+	cmd.Flags().StringVar(&filterStatus, "status", "", "Filter by status code (e.g., 200, 400-599, >=400)")
+	cmd.Flags().StringVar(&since, "since", "", "Only entries since time (e.g., 1h, today, 2024-01-15)")
+	cmd.Flags().StringVar(&until, "until", "", "Only entries until time")
+	cmd.Flags().BoolVar(&errorsOnly, "errors", false, "Only errors (status >= 400 or error field)")
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "Replay methods other than GET, HEAD and OPTIONS without asking")
+
+	return cmd
+}
+
+// selectReplayEntries picks what to replay: the entry whose ID starts with
+// id, when one is named, otherwise the newest last entries filter matches.
+func selectReplayEntries(entries []proxy.RequestLog, id string, filter *ProxyLogFilter, last int) ([]proxy.RequestLog, error) {
+	var matched []proxy.RequestLog
+	for _, e := range entries {
+		if filter.Match(&e) && strings.HasPrefix(e.LogID(), id) {
+			matched = append(matched, e)
+		}
+	}
+
+	if id == "" {
+		if len(matched) == 0 {
+			return nil, errors.New("no log entries match")
+		}
+		return matched[max(len(matched)-last, 0):], nil
+	}
+
+	if len(id) < 4 {
+		return nil, fmt.Errorf("entry ID %q is too short to be unique; give at least 4 characters", id)
+	}
+	switch len(matched) {
+	case 0:
+		return nil, fmt.Errorf("no log entry with ID %q", id)
+	case 1:
+		return matched, nil
+	}
+	ids := make([]string, len(matched))
+	for i, e := range matched {
+		ids[i] = e.LogID()
+	}
+	return nil, fmt.Errorf("entry ID %q is ambiguous: %s", id, strings.Join(ids, ", "))
+}
+
+func runProxyReplay(cmd *cobra.Command, sandboxRoot string, entries []proxy.RequestLog, yes bool) error {
+	// Every entry is checked before anything is sent, so a refusal never
+	// leaves a batch half replayed.
+	requests := make([]*http.Request, len(entries))
+	unsafe := false
+	for i := range entries {
+		req, withheld, err := proxy.ReplayRequest(&entries[i])
+		if err != nil {
+			return fmt.Errorf("%s: %w", entries[i].LogID(), err)
+		}
+		if len(withheld) > 0 {
+			notice.Info("%s: %s withheld from the log, not sent", entries[i].LogID(), strings.Join(withheld, ", "))
+		}
+		requests[i] = req
+		if !slices.Contains([]string{http.MethodGet, http.MethodHead, http.MethodOptions}, req.Method) {
+			unsafe = true
+		}
+	}
+	if unsafe && !yes {
+		if err := confirmReplay(os.Stdin, os.Stderr, entries, prompt.IsInteractive(os.Stdin, os.Stderr)); err != nil {
+			return err
+		}
+	}
+
+	appCfg, _, projectDir, err := config.LoadConfig()
+	if err != nil {
+		return err
+	}
+	pCfg, err := buildReplayProxyConfig(appCfg, cmd, sandboxRoot, projectDir)
+	if err != nil {
+		return err
+	}
+	server, err := proxy.NewServer(pCfg)
+	if err != nil {
+		return fmt.Errorf("failed to create proxy server: %w", err)
+	}
+	if err := server.Start(); err != nil {
+		return fmt.Errorf("failed to start proxy server: %w", err)
+	}
+	defer func() { _ = server.Stop() }()
+	server.SetSessionName("replay")
+
+	ctx, stop := signal.NotifyContext(cmd.Context(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	client := server.Client()
+
+	for i, req := range requests {
+		if i > 0 {
+			fmt.Println()
+		}
+		if err := replayOne(ctx, client, &entries[i], req, os.Stdout); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// buildReplayProxyConfig configures the replay's proxy as a sandbox launch
+// from the project directory would, minus what only a sandbox can use: the
+// DNS resolver and SOCKS listener. It shares the sandbox's CA, logs and ask
+// socket, so replays show up in its logs and in a running monitor.
+func buildReplayProxyConfig(appCfg *config.Config, cmd *cobra.Command, sandboxRoot, projectDir string) (*proxy.Config, error) {
+	pCfg := proxy.NewConfig(sandboxRoot, 0)
+	pCfg.MITM = appCfg.Proxy.IsMITMEnabled()
+	pCfg.LogReceivers = appCfg.Logging.Receivers
+	pCfg.LogAttributes = appCfg.Logging.Attributes
+	pCfg.LogFilterDecisions = appCfg.Logging.LogFilterDecisions
+	pCfg.MaxLogBodyBytes = appCfg.Proxy.MaxLogBodyBytes
+	var err error
+	pCfg.CredentialInjectors, err = proxy.BuildCredentialInjectors(appCfg.Proxy.Credentials)
+	if err != nil {
+		return nil, fmt.Errorf("build credential injectors: %w", err)
+	}
+	pCfg.Filter = buildFilterConfig(appCfg, cmd, "", nil, nil)
+	pCfg.Redaction = buildRedactionConfig(&appCfg.Proxy.Redaction)
+	pCfg.LogSkip = buildLogSkipConfig(appCfg)
+	pCfg.GitPush = buildGitPushConfig(appCfg)
+	pCfg.Limits = buildLimitsConfig(appCfg)
+	pCfg.ProjectDir = projectDir
+	pCfg.LocalConfigDir = projectDir
+	return pCfg, nil
+}
+
+// confirmReplay asks before re-sending requests that may change something
+// upstream. Without a terminal to ask on it refuses rather than guessing.
+func confirmReplay(in io.Reader, out io.Writer, entries []proxy.RequestLog, interactive bool) error {
+	if !interactive {
+		return errors.New("replaying requests other than GET, HEAD and OPTIONS needs confirmation: pass --yes")
+	}
+	fmt.Fprintln(out, "Replay:") //nolint:errcheck
+	for _, e := range entries {
+		fmt.Fprintf(out, "  %s %s %s\n", e.LogID(), e.Method, e.URL) //nolint:errcheck
+	}
+	fmt.Fprint(out, "Send these requests again? [y/N]: ") //nolint:errcheck
+
+	response, err := prompt.ReadLine(in)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("read confirmation: %w", err)
+	}
+	if !prompt.IsYes(response) {
+		return errors.New("aborted: replay not confirmed")
+	}
+	return nil
+}
+
+// replayOne sends req and writes its response diffed against the one e
+// recorded.
+func replayOne(ctx context.Context, client *http.Client, e *proxy.RequestLog, req *http.Request, w io.Writer) error {
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("%s: replay failed: %w", e.LogID(), err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxReplayBodyBytes))
+	if err != nil {
+		return fmt.Errorf("%s: reading the response: %w", e.LogID(), err)
+	}
+	return writeReplayDiff(w, e, resp, body)
+}
+
+// writeReplayDiff writes how a replayed response differs from the recorded
+// one: status, headers and a unified diff of the bodies.
+func writeReplayDiff(w io.Writer, e *proxy.RequestLog, resp *http.Response, body []byte) error {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Replayed %s %s %s\n", e.LogID(), e.Method, e.URL)
+
+	recorded := fmt.Sprintf("%d", e.StatusCode)
+	if e.Error != "" {
+		recorded = "error (" + e.Error + ")"
+	}
+	if e.StatusCode == resp.StatusCode && e.Error == "" {
+		fmt.Fprintf(&sb, "Status:  %d (unchanged)\n", resp.StatusCode)
+	} else {
+		fmt.Fprintf(&sb, "Status:  %s -> %d\n", recorded, resp.StatusCode)
+	}
+
+	headerDiff := diffReplayHeaders(e.ResponseHeaders, proxy.LoggedHeaders(resp.Header))
+	if len(headerDiff) == 0 {
+		sb.WriteString("Headers: unchanged\n")
+	} else {
+		sb.WriteString("Headers:\n")
+		for _, line := range headerDiff {
+			sb.WriteString("  " + line + "\n")
+		}
+	}
+
+	old, cur := e.ResponseBody, body
+	if e.ResponseBodyTruncated && len(cur) > len(old) {
+		// Only a prefix was recorded; compare like for like.
+		cur = cur[:len(old)]
+		fmt.Fprintf(&sb, "Body:    recorded body was truncated at %d bytes; comparing that much\n", len(old))
+	}
+	switch {
+	case bytes.Equal(old, cur):
+		sb.WriteString("Body:    unchanged\n")
+	case !isReplayText(old) || !isReplayText(cur):
+		fmt.Fprintf(&sb, "Body:    binary, %d -> %d bytes\n", len(old), len(cur))
+	default:
+		sb.WriteString("Body:\n")
+		if err := overlay.WriteUnified(&sb, "recorded", "replayed", indentJSON(old), indentJSON(cur)); err != nil {
+			return err
+		}
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// diffReplayHeaders lists the headers that changed, as "-" and "+" lines in
+// name order.
+func diffReplayHeaders(recorded, replayed map[string][]string) []string {
+	canonical := func(h map[string][]string) map[string][]string {
+		out := make(map[string][]string, len(h))
+		for name, values := range h {
+			name = http.CanonicalHeaderKey(name)
+			out[name] = append(out[name], values...)
+		}
+		return out
+	}
+	oldH, newH := canonical(recorded), canonical(replayed)
+
+	names := make([]string, 0, len(oldH)+len(newH))
+	for name := range oldH {
+		names = append(names, name)
+	}
+	for name := range newH {
+		if _, ok := oldH[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+
+	var lines []string
+	for _, name := range names {
+		if slices.Contains(replayIgnoredHeaders, name) || slices.Equal(oldH[name], newH[name]) {
+			continue
+		}
+		for _, v := range oldH[name] {
+			lines = append(lines, "- "+name+": "+v)
+		}
+		for _, v := range newH[name] {
+			lines = append(lines, "+ "+name+": "+v)
+		}
+	}
+	return lines
+}
+
+// isReplayText reports whether a body can be shown as a line diff.
+func isReplayText(b []byte) bool {
+	return utf8.Valid(b) && bytes.IndexByte(b, 0) < 0
+}
+
+// indentJSON pretty-prints a JSON body so a diff shows the fields that changed
+// rather than one long line; anything else is returned as is.
+func indentJSON(b []byte) []byte {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, b, "", "  "); err != nil {
+		return b
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes()
+}
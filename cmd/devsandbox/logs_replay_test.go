@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+
+	"devsandbox/internal/proxy"
+)
+
+func TestSelectReplayEntries(t *testing.T) {
+	entries := []proxy.RequestLog{
+		{ID: "aaaa11110000", Method: "GET", URL: "https://a.example.com/", StatusCode: 200},
+		{ID: "aaaa22220000", Method: "POST", URL: "https://b.example.com/", StatusCode: 500},
+		{ID: "bbbb33330000", Method: "GET", URL: "https://b.example.com/", StatusCode: 404},
+		{ID: "cccc44440000", Method: "GET", URL: "https://c.example.com/", StatusCode: 200},
+	}
+	ids := func(es []proxy.RequestLog) string {
+		var out []string
+		for _, e := range es {
+			out = append(out, e.ID)
+		}
+		return strings.Join(out, ",")
+	}
+
+	got, err := selectReplayEntries(entries, "bbbb", &ProxyLogFilter{}, 1)
+	if err != nil || ids(got) != "bbbb33330000" {
+		t.Errorf("by ID prefix = %s, %v", ids(got), err)
+	}
+
+	if _, err := selectReplayEntries(entries, "aaaa", &ProxyLogFilter{}, 1); err == nil || !strings.Contains(err.Error(), "ambiguous") {
+		t.Errorf("ambiguous prefix: err = %v", err)
+	}
+	if _, err := selectReplayEntries(entries, "aa", &ProxyLogFilter{}, 1); err == nil {
+		t.Error("short prefix: no error")
+	}
+	if _, err := selectReplayEntries(entries, "dddd", &ProxyLogFilter{}, 1); err == nil {
+		t.Error("unknown ID: no error")
+	}
+
+	// Filters narrow an ID prefix too.
+	got, err = selectReplayEntries(entries, "aaaa", &ProxyLogFilter{Method: "POST"}, 1)
+	if err != nil || ids(got) != "aaaa22220000" {
+		t.Errorf("ID prefix with filter = %s, %v", ids(got), err)
+	}
+
+	// Without an ID the newest matches are taken, oldest first.
+	got, err = selectReplayEntries(entries, "", &ProxyLogFilter{URL: "b.example.com"}, 1)
+	if err != nil || ids(got) != "bbbb33330000" {
+		t.Errorf("newest match = %s, %v", ids(got), err)
+	}
+	got, err = selectReplayEntries(entries, "", &ProxyLogFilter{ErrorsOnly: true}, 5)
+	if err != nil || ids(got) != "aaaa22220000,bbbb33330000" {
+		t.Errorf("last 5 errors = %s, %v", ids(got), err)
+	}
+	if _, err := selectReplayEntries(entries, "", &ProxyLogFilter{Method: "DELETE"}, 1); err == nil {
+		t.Error("no match: no error")
+	}
+}
+
+func TestConfirmReplay(t *testing.T) {
+	entries := []proxy.RequestLog{{ID: "abcd12340000", Method: "POST", URL: "https://example.com/items"}}
+
+	var out bytes.Buffer
+	if err := confirmReplay(strings.NewReader("y\n"), &out, entries, true); err != nil {
+		t.Errorf("confirmed: %v", err)
+	}
+	if !strings.Contains(out.String(), "abcd12340000 POST https://example.com/items") {
+		t.Errorf("prompt does not list the request: %q", out.String())
+	}
+	for _, answer := range []string{"\n", "n\n", ""} {
+		if err := confirmReplay(strings.NewReader(answer), new(bytes.Buffer), entries, true); err == nil {
+			t.Errorf("answer %q: replay not refused", answer)
+		}
+	}
+	if err := confirmReplay(strings.NewReader("y\n"), new(bytes.Buffer), entries, false); err == nil || !strings.Contains(err.Error(), "--yes") {
+		t.Errorf("non-interactive: err = %v, want a pointer to --yes", err)
+	}
+}
+
+func TestWriteReplayDiff(t *testing.T) {
+	recorded := &proxy.RequestLog{
+		ID:         "abcd12340000",
+		Method:     "GET",
+		URL:        "https://api.example.com/v1/items",
+		StatusCode: 200,
+		ResponseHeaders: map[string][]string{
+			"content-type": {"application/json"},
+			"Etag":         {`"v1"`},
+			"Date":         {"Mon, 15 Jan 2024 10:30:00 GMT"},
+		},
+		ResponseBody: []byte(`{"count":1,"items":["a"]}`),
+	}
+	resp := &http.Response{
+		StatusCode: 503,
+		Header: http.Header{
+			"Content-Type": {"application/json"},
+			"Etag":         {`"v2"`},
+			"Retry-After":  {"5"},
+			"Date":         {"Tue, 16 Jan 2024 10:30:00 GMT"},
+			"Set-Cookie":   {"session=secret"},
+		},
+	}
+
+	var out bytes.Buffer
+	if err := writeReplayDiff(&out, recorded, resp, []byte(`{"count":2,"items":["a","b"]}`)); err != nil {
+		t.Fatal(err)
+	}
+	got := out.String()
+	for _, want := range []string{
+		"Replayed abcd12340000 GET https://api.example.com/v1/items\n",
+		"Status:  200 -> 503\n",
+		`  - Etag: "v1"` + "\n",
+		`  + Etag: "v2"` + "\n",
+		"  + Retry-After: 5\n",
+		"  + Set-Cookie: [REDACTED]\n",
+		"--- recorded\n+++ replayed\n",
+		`-  "count": 1,`,
+		`+  "count": 2,`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("diff missing %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "Date") || strings.Contains(got, "Content-Type") || strings.Contains(got, "secret") {
+		t.Errorf("diff shows an ignored, unchanged or withheld header:\n%s", got)
+	}
+}
+
+func TestWriteReplayDiff_Unchanged(t *testing.T) {
+	recorded := &proxy.RequestLog{
+		ID: "abcd12340000", Method: "GET", URL: "https://example.com/", StatusCode: 200,
+		ResponseHeaders: map[string][]string{"X-A": {"1"}},
+		ResponseBody:    []byte("hello"), ResponseBodyTruncated: true,
+	}
+	resp := &http.Response{StatusCode: 200, Header: http.Header{"X-A": {"1"}}}
+
+	var out bytes.Buffer
+	if err := writeReplayDiff(&out, recorded, resp, []byte("hello, world")); err != nil {
+		t.Fatal(err)
+	}
+	got := out.String()
+	for _, want := range []string{"Status:  200 (unchanged)", "Headers: unchanged", "truncated at 5 bytes", "Body:    unchanged"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %q:\n%s", want, got)
+		}
+	}
+
+	out.Reset()
+	recorded.ResponseBody, recorded.ResponseBodyTruncated = []byte{0xff, 0x00}, false
+	if err := writeReplayDiff(&out, recorded, resp, []byte{0xff, 0x01, 0x02}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "Body:    binary, 2 -> 3 bytes") {
+		t.Errorf("binary body:\n%s", out.String())
+	}
+}
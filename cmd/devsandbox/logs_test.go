@@ -382,7 +382,7 @@ func TestViewProxyLogs_RejectsNegativeLast(t *testing.T) {
 		t.Fatalf("write log file: %v", err)
 	}
 
-	err := viewProxyLogs(dir, nil, -1, false, false, false, true, false)
+	err := viewProxyLogs(dir, nil, -1, "table", false, true, false)
 	if err == nil {
 		t.Fatal("viewProxyLogs accepted --last -1, want an error")
 	}
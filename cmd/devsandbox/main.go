@@ -8,6 +8,7 @@ import (
 	"os/signal"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -45,7 +46,7 @@ func addSandboxFlags(cmd *cobra.Command) {
 	cmd.Flags().Bool("no-mitm", false, "Disable HTTPS MITM interception (transparent CONNECT tunneling)")
 
 	// Tool flags
-	cmd.Flags().String("git-mode", "", "Override git tool mode for this session (readonly, readwrite, disabled)")
+	cmd.Flags().String("git-mode", "", "Override git tool mode for this session (readonly, readwrite, push-via-proxy, disabled)")
 
 	// Filter flags
 	cmd.Flags().String("filter-default", "", "Default filter action for unmatched requests: allow, block, or ask")
@@ -63,6 +64,9 @@ func addSandboxFlags(cmd *cobra.Command) {
 	cmd.Flags().Lookup("worktree").NoOptDefVal = " "
 	cmd.Flags().String("worktree-base", "", "Base ref when creating a new worktree branch. Defaults to HEAD. Ignored when the branch already exists.")
 
+	// Project overlay (transactional mode)
+	cmd.Flags().Bool("project-overlay", false, "Mount the project as an overlay: writes wait for 'devsandbox review' instead of reaching the real tree (bwrap only)")
+
 	// Security flags
 	cmd.Flags().Bool("no-hide-env", false, "Disable .env file hiding (exposes .env files inside the sandbox)")
 
@@ -126,10 +130,15 @@ Proxy Mode (--proxy):
 	rootCmd.AddCommand(newImageCmd())
 	rootCmd.AddCommand(newSessionsCmd())
 	rootCmd.AddCommand(newOverlayCmd())
+	rootCmd.AddCommand(newSnapshotCmd())
+	rootCmd.AddCommand(newReviewCmd())
+	rootCmd.AddCommand(newWorktreeCmd())
 	rootCmd.AddCommand(newForwardCmd())
 	rootCmd.AddCommand(newNSDialCmd())
 	rootCmd.AddCommand(newRunAgentCmd())
 	rootCmd.AddCommand(newAgentWrappersCmd())
+	rootCmd.AddCommand(newTmuxCmd())
+	rootCmd.AddCommand(newZellijCmd())
 
 	versionTpl := fmt.Sprintf("devsandbox %s (built: %s)\n", version.FullVersion(), version.Date)
 	if runtime.GOOS == "linux" {
@@ -223,6 +232,12 @@ func runSandbox(cmd *cobra.Command, args []string) (retErr error) {
 	if err != nil {
 		return err
 	}
+	// The directory the local config was read from; projectDir moves to the
+	// worktree below.
+	localConfigDir := projectDir
+	if loadOpts.SkipLocalConfig {
+		localConfigDir = ""
+	}
 
 	// Ensure mise configs are trusted before sandbox launch.
 	// Inside the sandbox, mise config dirs are read-only so trust prompts
@@ -250,6 +265,12 @@ func runSandbox(cmd *cobra.Command, args []string) (retErr error) {
 		keepContainer = false
 	}
 
+	// Create sandbox config
+	cfg, err := sandbox.NewConfig(&sandbox.Options{BasePath: appCfg.Sandbox.BasePath})
+	if err != nil {
+		return err
+	}
+
 	// Build isolator with functional options
 	iso, err := isolator.MustNew(isolator.Backend(isolation),
 		isolator.WithDockerConfig(
@@ -261,17 +282,14 @@ func runSandbox(cmd *cobra.Command, args []string) (retErr error) {
 			appCfg.Sandbox.Resources.Limits(),
 			appCfg.Sandbox.ResolvedResources().Limits(),
 		),
+		// Sandbox writes land on the project and on the sandbox home, so
+		// those are the disks io bandwidth caps apply to.
+		isolator.WithIOPaths(projectDir, cfg.SandboxBase),
 	)
 	if err != nil {
 		return err
 	}
 
-	// Create sandbox config
-	cfg, err := sandbox.NewConfig(&sandbox.Options{BasePath: appCfg.Sandbox.BasePath})
-	if err != nil {
-		return err
-	}
-
 	// Apply config file defaults, then CLI overrides
 	if appCfg.Proxy.IsEnabled() {
 		cfg.ProxyEnabled = true
@@ -303,7 +321,7 @@ func runSandbox(cmd *cobra.Command, args []string) (retErr error) {
 	if cmd.Flags().Changed("git-mode") {
 		gitMode, _ := cmd.Flags().GetString("git-mode")
 		if !tools.ValidGitMode(gitMode) {
-			return fmt.Errorf("invalid --git-mode value %q: must be readonly, readwrite, or disabled", gitMode)
+			return fmt.Errorf("invalid --git-mode value %q: must be readonly, readwrite, push-via-proxy, or disabled", gitMode)
 		}
 		if appCfg.Tools == nil {
 			appCfg.Tools = make(map[string]any)
@@ -329,18 +347,24 @@ func runSandbox(cmd *cobra.Command, args []string) (retErr error) {
 		cfg.HideEnvFiles = !noHideEnv
 	}
 
+	// --project-overlay keeps the sandbox's writes in an upper until they are
+	// reviewed. Anything that deletes that upper before the review would throw
+	// the work away, so --rm is refused outright.
+	if projectOverlay, _ := cmd.Flags().GetBool("project-overlay"); projectOverlay {
+		if rmFlag {
+			return fmt.Errorf("--project-overlay cannot be combined with --rm: removing the sandbox on exit would discard the changes before they can be reviewed")
+		}
+		if cfg.Isolation != sandbox.IsolationBwrap {
+			return fmt.Errorf("--project-overlay is supported by the bwrap backend only (isolation is %s)", cfg.Isolation)
+		}
+		cfg.ProjectOverlay = true
+	}
+
 	if showInfo {
 		printInfo(cfg)
 		return nil
 	}
 
-	// Resolve --worktree. Validation happens up-front; the actual
-	// `git worktree add` is deferred until after validation succeeds.
-	worktreeRaw, _ := cmd.Flags().GetString("worktree")
-	worktreeBase, _ := cmd.Flags().GetString("worktree-base")
-	wtEnabled := cmd.Flags().Changed("worktree")
-	explicitBranch := strings.TrimSpace(worktreeRaw)
-
 	resolvedGitMode := "readonly"
 	if gitCfg, ok := appCfg.Tools["git"].(map[string]any); ok {
 		if s, ok := gitCfg["mode"].(string); ok && s != "" {
@@ -348,6 +372,21 @@ func runSandbox(cmd *cobra.Command, args []string) (retErr error) {
 		}
 	}
 
+	// push-via-proxy leaves authentication to the proxy's credential
+	// injectors; without them a push would just fail unauthenticated, so the
+	// launch is refused rather than started half-working.
+	gitMode := tools.ParseGitMode(resolvedGitMode)
+	if err := tools.CheckGitModeProxy(gitMode, cfg.ProxyEnabled, cfg.ProxyMITM); err != nil {
+		return err
+	}
+
+	// Resolve --worktree. Validation happens up-front; the actual
+	// `git worktree add` is deferred until after validation succeeds.
+	worktreeRaw, _ := cmd.Flags().GetString("worktree")
+	worktreeBase, _ := cmd.Flags().GetString("worktree-base")
+	wtEnabled := cmd.Flags().Changed("worktree")
+	explicitBranch := strings.TrimSpace(worktreeRaw)
+
 	if err := worktree.Validate(worktree.Options{
 		Enabled: wtEnabled,
 		Branch:  explicitBranch,
@@ -434,10 +473,20 @@ func runSandbox(cmd *cobra.Command, args []string) (retErr error) {
 	}
 	defer func() { _ = sessionHandle.Release() }()
 
+	// A concurrent session's overlays are discarded on exit, which for the
+	// project would be every change the agent made.
+	if cfg.ProjectOverlay && cfg.IsConcurrent {
+		return fmt.Errorf("--project-overlay needs the sandbox to itself: another session is active, and a concurrent session's overlay changes are discarded on exit")
+	}
+
 	if cfg.IsConcurrent {
 		notice.Info("Another session is active. Running in concurrent mode (overlay changes will be discarded on exit).")
 	}
 
+	if sessionHandle.IsPrimary() && !cfg.ProjectOverlay && sandbox.HasProjectOverlayChanges(cfg.SandboxHome, cfg.ProjectDir) {
+		notice.Warn("an earlier --project-overlay session left project changes that are not visible in this session; run 'devsandbox review' to apply or discard them")
+	}
+
 	// Primary session: clean up stale session overlay dirs from crashed
 	// concurrent sessions.
 	//
@@ -551,10 +600,19 @@ func runSandbox(cmd *cobra.Command, args []string) (retErr error) {
 		if err != nil {
 			return fmt.Errorf("build credential injectors: %w", err)
 		}
+		if gitMode == tools.GitModePushViaProxy && len(pCfg.CredentialInjectors) == 0 {
+			notice.Warn("git mode push-via-proxy: no credential injector is enabled, so pushes will be unauthenticated " +
+				"(see [proxy.credentials.github-git] in the proxy docs)")
+		}
 		pCfg.Filter = buildFilterConfig(appCfg, cmd, filterDefault, allowDomains, blockDomains)
 		pCfg.Redaction = buildRedactionConfig(&appCfg.Proxy.Redaction)
 		pCfg.LogSkip = buildLogSkipConfig(appCfg)
+		pCfg.GitPush = buildGitPushConfig(appCfg)
+		pCfg.Limits = buildLimitsConfig(appCfg)
+		pCfg.DNS = buildDNSConfig(appCfg, iso.Name())
+		pCfg.SOCKS = buildSOCKSConfig(appCfg, iso.Name())
 		pCfg.ProjectDir = projectDir
+		pCfg.LocalConfigDir = localConfigDir
 
 		if netInfo != nil {
 			pCfg.BindAddress = netInfo.BindAddress
@@ -567,6 +625,8 @@ func runSandbox(cmd *cobra.Command, args []string) (retErr error) {
 		defer deferProxyCleanup(proxyRes)
 
 		cfg.ProxyPort = proxyRes.port
+		cfg.DNSPort = proxyRes.server.DNSPort()
+		cfg.SOCKSPort = proxyRes.server.SOCKSPort()
 		proxyServer = proxyRes.server
 
 		notice.Info("Proxy server started on %s:%d", pCfg.GetBindAddress(), proxyRes.port)
@@ -581,14 +641,34 @@ func runSandbox(cmd *cobra.Command, args []string) (retErr error) {
 			if pCfg.Filter.DefaultAction == proxy.FilterActionAsk {
 				notice.Info("Filter: ask mode (default action for unmatched requests)")
 				notice.Info("\nRun in another terminal to approve/deny requests:\n  devsandbox proxy monitor\n\nRequests without response within 30s will be rejected.")
+				if pCfg.Filter.IsAskNotificationsEnabled() {
+					notice.Info("Without a monitor, requests are asked through desktop notifications where available.")
+				}
 			} else {
 				notice.Info("Filter: %d rules, default action: %s", len(pCfg.Filter.Rules), pCfg.Filter.DefaultAction)
 			}
 		}
 
 		if pCfg.Redaction != nil && pCfg.Redaction.IsEnabled() {
-			notice.Info("Redaction: %d rules, default action: %s",
-				len(pCfg.Redaction.Rules), pCfg.Redaction.GetDefaultAction())
+			notice.Info("Redaction: %d rules, %d response rules, default action: %s",
+				len(pCfg.Redaction.Rules), len(pCfg.Redaction.ResponseRules), pCfg.Redaction.GetDefaultAction())
+		}
+
+		if pCfg.GitPush.IsEnabled() {
+			notice.Info("Git push policy: %d allowed, %d protected ref patterns",
+				len(pCfg.GitPush.AllowRefs), len(pCfg.GitPush.ProtectedRefs))
+		}
+
+		if pCfg.Limits.IsEnabled() {
+			notice.Info("Limits: %d rules (counters shown in `devsandbox proxy monitor`)", len(pCfg.Limits.Rules))
+		}
+
+		if cfg.DNSPort > 0 {
+			notice.Info("DNS: resolver on 127.0.0.1:%d (answers names the filter allows)", cfg.DNSPort)
+		}
+
+		if cfg.SOCKSPort > 0 {
+			notice.Info("SOCKS: listener on 127.0.0.1:%d, reached in the sandbox at 127.0.0.1:%d (ALL_PROXY)", cfg.SOCKSPort, sandbox.SOCKSSandboxPort)
 		}
 
 		if pCfg.LogSkip.IsEnabled() {
@@ -633,6 +713,9 @@ func runSandbox(cmd *cobra.Command, args []string) (retErr error) {
 				sandboxName = sessionStore.AutoName(projectDir)
 			}
 		}
+		if proxyServer != nil {
+			proxyServer.SetSessionName(sandboxName)
+		}
 	}
 
 	// Audit logging: build session context AFTER AutoName resolves so
@@ -694,6 +777,25 @@ func runSandbox(cmd *cobra.Command, args []string) (retErr error) {
 		SandboxName:    sandboxName,
 	}
 
+	// The cgroup is resolved in the background and may be known before or
+	// after the session is registered; whichever comes second records it.
+	// sessMu also serializes the port-forward updates to the same session.
+	var (
+		sessMu       sync.Mutex
+		liveSession  *session.Session
+		liveStore    *session.Store
+		sandboxCgDir string
+	)
+	runCfg.OnCgroupResolved = func(dir string) {
+		sessMu.Lock()
+		defer sessMu.Unlock()
+		sandboxCgDir = dir
+		if liveSession != nil {
+			liveSession.CgroupDir = dir
+			_ = liveStore.Update(liveSession)
+		}
+	}
+
 	runCfg.OnSandboxStart = func(nsPID int, nsPath string) {
 		sessionStore, err := session.DefaultStore()
 		if err != nil {
@@ -718,10 +820,15 @@ func runSandbox(cmd *cobra.Command, args []string) (retErr error) {
 			}
 		}
 
+		sessMu.Lock()
+		sess.CgroupDir = sandboxCgDir
 		if err := sessionStore.Register(sess); err != nil {
+			sessMu.Unlock()
 			notice.Warn("[devsandbox] failed to register session: %v", err)
 			return
 		}
+		liveSession, liveStore = sess, sessionStore
+		sessMu.Unlock()
 
 		notice.Info("Session: %s (use 'devsandbox forward %s <port>' to forward ports)", sandboxName, sandboxName)
 
@@ -752,51 +859,77 @@ func runSandbox(cmd *cobra.Command, args []string) (retErr error) {
 			for _, p := range appCfg.PortForwarding.ExcludePorts {
 				excludePorts[p] = true
 			}
+			// pasta's end of the SOCKS forward is a listener inside the
+			// sandbox like any other; forwarding it back out is pointless.
+			if cfg.SOCKSPort > 0 {
+				excludePorts[sandbox.SOCKSSandboxPort] = true
+			}
 
-			forwarders := make(map[int]*portforward.Forwarder)
+			type forwardKey struct {
+				protocol string
+				port     int
+			}
+			forwarders := make(map[forwardKey]*portforward.Forwarder)
 			var fwdMu sync.Mutex
 
 			mon := &portforward.Monitor{
 				Scanner:      scanner,
 				Interval:     appCfg.PortForwarding.GetScanInterval(),
 				ExcludePorts: excludePorts,
-				OnPortAdded: func(port int) {
+				OnPortAdded: func(e portforward.ListenEntry) {
+					port := e.Port
 					fwd := &portforward.Forwarder{
 						HostPort:    port,
 						SandboxPort: port,
+						SandboxHost: e.DialHost(),
 						Bind:        "127.0.0.1",
+						Protocol:    e.Protocol,
 						Dialer:      dialer,
 					}
 					hostPort, fellBack, err := fwd.StartWithFallback(cmd.Context())
 					if err != nil {
-						notice.Warn("[devsandbox] cannot forward port %d: %v (try: devsandbox forward %d:<alt_port>)", port, err, port)
+						notice.Warn("[devsandbox] cannot forward %s port %d: %v (try: devsandbox forward %d:<alt_port>)", e.Protocol, port, err, port)
 						return
 					}
 					fwdMu.Lock()
-					forwarders[port] = fwd
+					forwarders[forwardKey{e.Protocol, port}] = fwd
 					fwdMu.Unlock()
 
 					if fellBack {
-						notice.Info("[devsandbox] auto-forwarding port %d → 127.0.0.1:%d (host port %d was in use)", port, hostPort, port)
+						notice.Info("[devsandbox] auto-forwarding %s port %d → 127.0.0.1:%d (host port %d was in use)", e.Protocol, port, hostPort, port)
 					} else {
-						notice.Info("[devsandbox] auto-forwarding port %d → 127.0.0.1:%d", port, hostPort)
+						notice.Info("[devsandbox] auto-forwarding %s port %d → 127.0.0.1:%d", e.Protocol, port, hostPort)
 					}
 
 					// Update session registry
+					sessMu.Lock()
 					sess.ForwardedPorts = append(sess.ForwardedPorts, session.ForwardedPort{
-						HostPort: hostPort, SandboxPort: port, Bind: "127.0.0.1", Protocol: "tcp",
+						HostPort: hostPort, SandboxPort: port, Bind: "127.0.0.1", Protocol: e.Protocol,
 					})
 					_ = sessionStore.Update(sess)
+					sessMu.Unlock()
 				},
-				OnPortRemoved: func(port int) {
+				OnPortRemoved: func(e portforward.ListenEntry) {
+					key := forwardKey{e.Protocol, e.Port}
 					fwdMu.Lock()
-					if fwd, ok := forwarders[port]; ok {
+					fwd, ok := forwarders[key]
+					if ok {
 						fwd.Stop()
-						delete(forwarders, port)
+						delete(forwarders, key)
 					}
 					fwdMu.Unlock()
+					if !ok {
+						return
+					}
+
+					sessMu.Lock()
+					sess.ForwardedPorts = slices.DeleteFunc(sess.ForwardedPorts, func(fp session.ForwardedPort) bool {
+						return fp.SandboxPort == e.Port && fp.Protocol == e.Protocol
+					})
+					_ = sessionStore.Update(sess)
+					sessMu.Unlock()
 
-					notice.Info("[devsandbox] stopped forwarding port %d", port)
+					notice.Info("[devsandbox] stopped forwarding %s port %d", e.Protocol, e.Port)
 				},
 			}
 
@@ -980,16 +1113,29 @@ func buildFilterConfig(appCfg *config.Config, cmd *cobra.Command, filterDefault
 		filterCfg.AskTimeout = appCfg.Proxy.Filter.AskTimeout
 	}
 	filterCfg.CacheDecisions = appCfg.Proxy.Filter.CacheDecisions
+	filterCfg.AskNotifications = appCfg.Proxy.Filter.AskNotifications
 
 	// Convert config file rules
 	for _, r := range appCfg.Proxy.Filter.Rules {
-		filterCfg.Rules = append(filterCfg.Rules, proxy.FilterRule{
+		rule := proxy.FilterRule{
 			Pattern: r.Pattern,
 			Action:  proxy.FilterAction(r.Action),
 			Scope:   proxy.FilterScope(r.Scope),
 			Type:    proxy.PatternType(r.Type),
 			Reason:  r.Reason,
-		})
+			Methods: r.Methods,
+			Headers: r.Headers,
+			Ports:   r.Ports,
+		}
+		for _, bm := range r.Body {
+			rule.Body = append(rule.Body, proxy.FilterBodyMatch{
+				Path:    bm.Path,
+				In:      bm.In,
+				NotIn:   bm.NotIn,
+				Pattern: bm.Pattern,
+			})
+		}
+		filterCfg.Rules = append(filterCfg.Rules, rule)
 	}
 
 	// CLI override for default action
@@ -1050,15 +1196,79 @@ func buildLogSkipConfig(appCfg *config.Config) *proxy.LogSkipConfig {
 	return cfg
 }
 
+// buildGitPushConfig converts the config-layer git push policy to proxy
+// types. Returns nil when neither list is set.
+func buildGitPushConfig(appCfg *config.Config) *proxy.GitPushConfig {
+	gp := appCfg.Proxy.GitPush
+	if len(gp.AllowRefs) == 0 && len(gp.ProtectedRefs) == 0 {
+		return nil
+	}
+	return &proxy.GitPushConfig{
+		AllowRefs:     gp.AllowRefs,
+		ProtectedRefs: gp.ProtectedRefs,
+	}
+}
+
+// buildDNSConfig converts the config-layer DNS resolver setting to proxy
+// types. The resolver is reached through a pasta forward, so any backend other
+// than bwrap keeps its own DNS and gets a warning instead.
+func buildDNSConfig(appCfg *config.Config, backend isolator.Backend) *proxy.DNSConfig {
+	if appCfg.Proxy.DNS.Enabled == nil || !*appCfg.Proxy.DNS.Enabled {
+		return nil
+	}
+	if backend != isolator.BackendBwrap {
+		notice.Warn("proxy.dns is only supported by the bwrap backend; %s keeps its own DNS", backend)
+		return nil
+	}
+	return &proxy.DNSConfig{
+		Enabled:             appCfg.Proxy.DNS.Enabled,
+		AnswerHostlessRules: appCfg.Proxy.DNS.AnswerHostlessRules,
+	}
+}
+
+// buildSOCKSConfig converts the config-layer SOCKS listener setting to proxy
+// types. Like the DNS resolver it is reached through a pasta forward, so any
+// backend other than bwrap gets a warning instead.
+func buildSOCKSConfig(appCfg *config.Config, backend isolator.Backend) *proxy.SOCKSConfig {
+	if appCfg.Proxy.SOCKS.Enabled == nil || !*appCfg.Proxy.SOCKS.Enabled {
+		return nil
+	}
+	if backend != isolator.BackendBwrap {
+		notice.Warn("proxy.socks is only supported by the bwrap backend; %s has no SOCKS listener", backend)
+		return nil
+	}
+	return &proxy.SOCKSConfig{Enabled: appCfg.Proxy.SOCKS.Enabled}
+}
+
+// buildLimitsConfig converts the config-layer traffic limits to proxy types.
+// Returns nil when no rule is set.
+func buildLimitsConfig(appCfg *config.Config) *proxy.LimitsConfig {
+	if len(appCfg.Proxy.Limits.Rules) == 0 {
+		return nil
+	}
+	cfg := &proxy.LimitsConfig{}
+	for _, r := range appCfg.Proxy.Limits.Rules {
+		cfg.Rules = append(cfg.Rules, proxy.LimitRule{
+			Pattern:           r.Pattern,
+			RequestsPerMinute: r.RequestsPerMinute,
+			MaxConcurrent:     r.MaxConcurrent,
+			MaxUploadBytes:    r.MaxUploadBytes,
+			OnExceed:          proxy.FilterAction(r.OnExceed),
+		})
+	}
+	return cfg
+}
+
 // buildRedactionConfig converts config types to proxy redaction types.
 func buildRedactionConfig(cfg *config.ProxyRedactionConfig) *proxy.RedactionConfig {
 	if cfg == nil {
 		return nil
 	}
 	redCfg := &proxy.RedactionConfig{
-		Enabled:       cfg.Enabled,
-		DefaultAction: proxy.RedactionAction(cfg.DefaultAction),
-		MaxScanBytes:  cfg.MaxScanBytes,
+		Enabled:              cfg.Enabled,
+		DefaultAction:        proxy.RedactionAction(cfg.DefaultAction),
+		MaxScanBytes:         cfg.MaxScanBytes,
+		MaxResponseScanBytes: cfg.MaxResponseScanBytes,
 	}
 	for _, r := range cfg.Rules {
 		rule := proxy.RedactionRule{
@@ -1076,6 +1286,24 @@ func buildRedactionConfig(cfg *config.ProxyRedactionConfig) *proxy.RedactionConf
 		}
 		redCfg.Rules = append(redCfg.Rules, rule)
 	}
+	for _, r := range cfg.ResponseRules {
+		rule := proxy.ResponseRedactionRule{
+			Name:         r.Name,
+			Hosts:        r.Hosts,
+			Action:       proxy.RedactionAction(r.Action),
+			Pattern:      r.Pattern,
+			StripHeaders: r.StripHeaders,
+		}
+		if r.Source != nil {
+			rule.Source = &proxy.RedactionSource{
+				Value:      r.Source.Value,
+				Env:        r.Source.Env,
+				File:       r.Source.File,
+				EnvFileKey: r.Source.EnvFileKey,
+			}
+		}
+		redCfg.ResponseRules = append(redCfg.ResponseRules, rule)
+	}
 	return redCfg
 }
 
@@ -29,6 +29,59 @@ func TestWorktreeFlagsRegistered(t *testing.T) {
 	}
 }
 
+func TestBuildGitPushConfig(t *testing.T) {
+	if got := buildGitPushConfig(&config.Config{}); got != nil {
+		t.Errorf("expected nil for empty config, got %+v", got)
+	}
+
+	appCfg := &config.Config{Proxy: config.ProxyConfig{
+		GitPush: config.ProxyGitPushConfig{
+			AllowRefs:     []string{"refs/heads/devsandbox/*"},
+			ProtectedRefs: []string{"refs/heads/main"},
+		},
+	}}
+	got := buildGitPushConfig(appCfg)
+	if got == nil {
+		t.Fatal("expected non-nil result")
+	}
+	if len(got.AllowRefs) != 1 || got.AllowRefs[0] != "refs/heads/devsandbox/*" {
+		t.Errorf("AllowRefs = %v", got.AllowRefs)
+	}
+	if len(got.ProtectedRefs) != 1 || got.ProtectedRefs[0] != "refs/heads/main" {
+		t.Errorf("ProtectedRefs = %v", got.ProtectedRefs)
+	}
+}
+
+func TestBuildLimitsConfig(t *testing.T) {
+	if got := buildLimitsConfig(&config.Config{}); got != nil {
+		t.Errorf("expected nil for empty config, got %+v", got)
+	}
+
+	appCfg := &config.Config{Proxy: config.ProxyConfig{
+		Limits: config.ProxyLimitsConfig{Rules: []config.ProxyLimitRule{{
+			Pattern:           "*.openai.com",
+			RequestsPerMinute: 60,
+			MaxConcurrent:     4,
+			MaxUploadBytes:    1 << 20,
+			OnExceed:          "ask",
+		}}},
+	}}
+	got := buildLimitsConfig(appCfg)
+	if got == nil || len(got.Rules) != 1 {
+		t.Fatalf("expected one rule, got %+v", got)
+	}
+	want := proxy.LimitRule{
+		Pattern:           "*.openai.com",
+		RequestsPerMinute: 60,
+		MaxConcurrent:     4,
+		MaxUploadBytes:    1 << 20,
+		OnExceed:          proxy.FilterActionAsk,
+	}
+	if got.Rules[0] != want {
+		t.Errorf("rule = %+v, want %+v", got.Rules[0], want)
+	}
+}
+
 func TestBuildLogSkipConfig(t *testing.T) {
 	t.Run("empty config returns nil", func(t *testing.T) {
 		got := buildLogSkipConfig(&config.Config{})
@@ -1,13 +1,17 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"net"
 	"os"
 	"sync"
+	"syscall"
 
 	"github.com/spf13/cobra"
+
+	"devsandbox/internal/portforward"
 )
 
 // newNSDialCmd creates a hidden helper subcommand used by the in-process
@@ -17,18 +21,42 @@ import (
 // local because nsenter set us up that way) and bridge the connection to
 // our stdin/stdout. The parent wraps our pipes as a net.Conn.
 //
+// With --listen the direction is reversed: the address is listened on, and
+// every connection accepted is relayed to the --bridge unix socket, which
+// the parent serves on the host. nsenter leaves us in the host's mount
+// namespace, so that path is reachable from here.
+//
+// With --udp the address is dialed over UDP and the pipes carry datagrams
+// framed by portforward.WriteDatagram, one frame per datagram.
+//
 // This indirection exists because setns(CLONE_NEWUSER) requires the calling
 // process to be single-threaded, which Go processes never are.
 func newNSDialCmd() *cobra.Command {
-	return &cobra.Command{
+	var udp, listen bool
+	var bridge string
+	cmd := &cobra.Command{
 		Use:    "__nsdial <host:port>",
-		Short:  "Internal helper: bridge stdio to a TCP connection (no namespace ops here)",
+		Short:  "Internal helper: bridge stdio to a TCP or UDP connection (no namespace ops here)",
 		Hidden: true,
 		Args:   cobra.ExactArgs(1),
 		RunE: func(_ *cobra.Command, args []string) error {
+			switch {
+			case listen:
+				if bridge == "" {
+					return errors.New("--listen requires --bridge")
+				}
+				return runNSListen(args[0], bridge)
+			case udp:
+				return runNSDialUDP(args[0])
+			}
 			return runNSDial(args[0])
 		},
 	}
+	cmd.Flags().BoolVar(&udp, "udp", false, "Dial over UDP and frame datagrams on stdio")
+	cmd.Flags().BoolVar(&listen, "listen", false, "Listen on the address and relay each connection to --bridge")
+	cmd.Flags().StringVar(&bridge, "bridge", "", "Host unix socket that --listen relays connections to")
+	cmd.MarkFlagsMutuallyExclusive("udp", "listen")
+	return cmd
 }
 
 func runNSDial(addr string) error {
@@ -60,3 +88,93 @@ func runNSDial(addr string) error {
 	wg.Wait()
 	return nil
 }
+
+// runNSDialUDP relays framed datagrams between stdio and a UDP socket. It
+// returns when stdin closes: the parent closing its end is the only "end of
+// stream" a datagram relay has.
+func runNSDialUDP(addr string) error {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return fmt.Errorf("dial %s: %w", addr, err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	// conn → stdout. Ends when conn is closed below.
+	go func() {
+		buf := make([]byte, portforward.MaxDatagramSize)
+		for {
+			n, err := conn.Read(buf)
+			if err != nil {
+				// A refused datagram (ICMP port unreachable) is not fatal:
+				// the sandbox server may not be up yet.
+				if errors.Is(err, syscall.ECONNREFUSED) {
+					continue
+				}
+				return
+			}
+			if err := portforward.WriteDatagram(os.Stdout, buf[:n]); err != nil {
+				return
+			}
+		}
+	}()
+
+	// stdin → conn
+	buf := make([]byte, portforward.MaxDatagramSize)
+	for {
+		n, err := portforward.ReadDatagram(os.Stdin, buf)
+		if err != nil {
+			return nil
+		}
+		_, _ = conn.Write(buf[:n])
+	}
+}
+
+// runNSListen listens on addr and relays each accepted connection to the
+// bridge socket. It prints portforward.ListenReady once bound and returns
+// when stdin closes - the parent closing its end, or exiting.
+func runNSListen(addr, bridge string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("listen %s: %w", addr, err)
+	}
+	defer func() { _ = ln.Close() }()
+
+	if _, err := fmt.Fprintln(os.Stdout, portforward.ListenReady); err != nil {
+		return err
+	}
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go relayToBridge(conn, bridge)
+		}
+	}()
+
+	_, _ = io.Copy(io.Discard, os.Stdin)
+	return nil
+}
+
+// relayToBridge copies between conn and a fresh connection to the bridge
+// socket until both directions are done.
+func relayToBridge(conn net.Conn, bridge string) {
+	defer func() { _ = conn.Close() }()
+	up, err := net.Dial("unix", bridge)
+	if err != nil {
+		return
+	}
+	defer func() { _ = up.Close() }()
+
+	var wg sync.WaitGroup
+	wg.Go(func() {
+		_, _ = io.Copy(up, conn)
+		_ = up.(*net.UnixConn).CloseWrite()
+	})
+	wg.Go(func() {
+		_, _ = io.Copy(conn, up)
+		_ = conn.(*net.TCPConn).CloseWrite()
+	})
+	wg.Wait()
+}
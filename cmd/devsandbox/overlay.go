@@ -33,6 +33,7 @@ func newOverlayCmd() *cobra.Command {
 		Short: "Manage sandbox overlay upper directories",
 	}
 	cmd.AddCommand(newOverlayMigrateCmd())
+	cmd.AddCommand(newOverlayDiffCmd())
 	return cmd
 }
 
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"devsandbox/internal/overlay"
+	"devsandbox/internal/sandbox"
+	"devsandbox/internal/sandbox/tools"
+)
+
+type overlayDiffFlags struct {
+	sandbox     string
+	path        string
+	tool        string
+	primaryOnly bool
+	unified     bool
+	jsonOutput  bool
+}
+
+func newOverlayDiffCmd() *cobra.Command {
+	f := &overlayDiffFlags{}
+	cmd := &cobra.Command{
+		Use:   "diff",
+		Short: "Show what a sandbox changed under its overlay mounts",
+		Long: `Show what a sandbox changed under its overlay-mounted host paths - tool state
+such as ~/.claude or ~/.config/*, and overlay mount rules - without touching
+anything. The changes are the ones 'devsandbox overlay migrate' would promote:
+
+  +  created in the sandbox
+  ~  overwritten: the host has the path, the sandbox replaced it
+  -  deleted in the sandbox (an overlayfs whiteout)
+
+A directory marked "opaque" was removed and recreated in the sandbox, which
+hides everything the host holds beneath it; migrate merges into it instead.
+Per-session uppers of concurrent sessions are included unless --primary-only.
+
+Acts on the sandbox of the current directory unless --sandbox names another.
+With --unified, regular files also get a unified diff against the host copy.`,
+		Example: `  devsandbox overlay diff
+  devsandbox overlay diff --tool claude -u
+  devsandbox overlay diff --sandbox wip-graph --path ~/.claude/projects
+  devsandbox overlay diff --json -u`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runOverlayDiff(cmd, f)
+		},
+	}
+	cmd.Flags().StringVarP(&f.sandbox, "sandbox", "s", "", "Sandbox name (default: current directory)")
+	cmd.Flags().StringVar(&f.path, "path", "", "Only show changes at or under this host path")
+	cmd.Flags().StringVar(&f.tool, "tool", "", "Only show the overlays of this tool's bindings")
+	cmd.Flags().BoolVar(&f.primaryOnly, "primary-only", false, "Ignore session uppers")
+	cmd.Flags().BoolVarP(&f.unified, "unified", "u", false, "Include a unified diff of each changed regular file")
+	cmd.Flags().BoolVar(&f.jsonOutput, "json", false, "Output in JSON format")
+	cmd.MarkFlagsMutuallyExclusive("path", "tool")
+	return cmd
+}
+
+// overlayDiffMount is one overlay's changes, as printed and as encoded by
+// --json.
+type overlayDiffMount struct {
+	HostPath string              `json:"host_path"`
+	Custom   bool                `json:"custom,omitempty"`
+	Changes  []overlayDiffChange `json:"changes"`
+}
+
+type overlayDiffChange struct {
+	Kind            string `json:"kind"`
+	Path            string `json:"path"`
+	Source          string `json:"source,omitempty"`
+	Bytes           int64  `json:"bytes,omitempty"`
+	Dir             bool   `json:"dir,omitempty"`
+	LinkTarget      string `json:"link_target,omitempty"`
+	Opaque          bool   `json:"opaque,omitempty"`
+	ReplacesHostDir bool   `json:"replaces_host_dir,omitempty"`
+	Diff            string `json:"diff,omitempty"`
+}
+
+func runOverlayDiff(cmd *cobra.Command, f *overlayDiffFlags) error {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return err
+	}
+	name := f.sandbox
+	if name == "" {
+		cwd, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+		name = sandbox.GenerateSandboxName(cwd)
+	}
+	if name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+		return fmt.Errorf("invalid sandbox name %q", name)
+	}
+	sandboxHome := filepath.Join(sandbox.SandboxBasePath(homeDir), name, "home")
+	if _, err := os.Stat(sandboxHome); err != nil {
+		return fmt.Errorf("no sandbox home for %q (see 'devsandbox sandboxes list')", name)
+	}
+
+	var only map[string]bool
+	if f.tool != "" {
+		tool := tools.Get(f.tool)
+		if tool == nil {
+			return fmt.Errorf("unknown tool %q", f.tool)
+		}
+		only = map[string]bool{}
+		for _, b := range tool.Bindings(homeDir, "") {
+			only[filepath.Clean(b.Source)] = true
+		}
+	}
+	filterPath := ""
+	if f.path != "" {
+		filterPath = filepath.Clean(expandHome(homeDir, f.path))
+	}
+
+	mounts, unresolved, err := overlay.LocateMounts(sandboxHome, overlayCandidates(homeDir), f.primaryOnly)
+	if err != nil {
+		return fmt.Errorf("locate overlays: %w", err)
+	}
+	for _, u := range unresolved {
+		if _, err := fmt.Fprintf(cmd.ErrOrStderr(), "overlay/%s: no host path matches this upper (removed from the host?), skipping\n", u); err != nil {
+			return err
+		}
+	}
+
+	var result []overlayDiffMount
+	for _, m := range mounts {
+		if only != nil && !only[m.HostPath] {
+			continue
+		}
+		if filterPath != "" && !pathWithin(m.HostPath, filterPath) && !pathWithin(filterPath, m.HostPath) {
+			continue
+		}
+		for i := range m.Sources {
+			if m.Sources[i].Kind == overlay.UpperPrimary {
+				m.Sources[i].SourceLabel = "primary"
+			} else {
+				m.Sources[i].SourceLabel = "session " + m.Sources[i].SessionID
+			}
+		}
+		plan, err := overlay.BuildPlan(m.Sources, m.HostPath)
+		if err != nil {
+			return fmt.Errorf("build plan for %s: %w", m.HostPath, err)
+		}
+		changes, err := overlayDiffChanges(plan, func(op overlay.Operation) bool {
+			return filterPath == "" || pathWithin(op.HostPath, filterPath)
+		}, f.unified)
+		if err != nil {
+			return err
+		}
+		dm := overlayDiffMount{HostPath: m.HostPath, Custom: m.Custom, Changes: changes}
+		if len(dm.Changes) > 0 {
+			result = append(result, dm)
+		}
+	}
+
+	if f.jsonOutput {
+		encoder := json.NewEncoder(cmd.OutOrStdout())
+		encoder.SetIndent("", "  ")
+		if result == nil {
+			result = []overlayDiffMount{}
+		}
+		return encoder.Encode(result)
+	}
+	return printOverlayDiff(cmd.OutOrStdout(), result)
+}
+
+// overlayDiffChanges converts the operations of plan that keep accepts into
+// changes, with their unified diffs when unified is set.
+func overlayDiffChanges(plan overlay.Plan, keep func(overlay.Operation) bool, unified bool) ([]overlayDiffChange, error) {
+	changes := []overlayDiffChange{}
+	for _, op := range plan.Operations {
+		if !keep(op) {
+			continue
+		}
+		// A directory over a host directory is merged, not changed: it is in
+		// the upper only because something beneath it was written.
+		if op.IsDir && op.Kind == overlay.OpOverwrite && !op.Opaque {
+			continue
+		}
+		c := overlayDiffChange{
+			Kind:            op.Kind.String(),
+			Path:            op.HostPath,
+			Source:          op.SourceLabel,
+			Bytes:           op.Bytes,
+			Dir:             op.IsDir,
+			LinkTarget:      op.LinkTarget,
+			Opaque:          op.Opaque,
+			ReplacesHostDir: op.ReplacesHostDir,
+		}
+		if op.Kind == overlay.OpDelete {
+			c.Source = ""
+		}
+		if unified {
+			var buf bytes.Buffer
+			if err := overlay.WriteFileDiff(&buf, op); err != nil {
+				return nil, fmt.Errorf("diff %s: %w", op.HostPath, err)
+			}
+			c.Diff = buf.String()
+		}
+		changes = append(changes, c)
+	}
+	return changes, nil
+}
+
+// overlayCandidates returns the host paths every tool can overlay, which is
+// what LocateMounts maps upper directory names back to before it falls back
+// to searching the host filesystem.
+func overlayCandidates(homeDir string) []string {
+	var paths []string
+	for _, tool := range tools.All() {
+		for _, b := range tool.Bindings(homeDir, "") {
+			// The upper is named after the mount destination, and the host
+			// path is the source; they differ only for bindings that never
+			// get a persistent bwrap overlay.
+			if b.Dest == "" || b.Dest == b.Source {
+				paths = append(paths, b.Source)
+			}
+		}
+	}
+	return paths
+}
+
+// pathWithin reports whether p is dir or lies beneath it.
+func pathWithin(p, dir string) bool {
+	return p == dir || strings.HasPrefix(p, dir+string(filepath.Separator)) || dir == string(filepath.Separator)
+}
+
+// printOverlayDiff prints each overlay's changes with the marks of the
+// migrate preview, followed by their diffs when there are any.
+func printOverlayDiff(w io.Writer, mounts []overlayDiffMount) error {
+	if len(mounts) == 0 {
+		_, err := fmt.Fprintln(w, "No overlay changes.")
+		return err
+	}
+	marks := map[string]string{"create": "+", "overwrite": "~", "delete": "-"}
+	var creates, overwrites, deletes int
+	for _, m := range mounts {
+		header := m.HostPath
+		if m.Custom {
+			header += "  (mount rule)"
+		}
+		if _, err := fmt.Fprintln(w, header); err != nil {
+			return err
+		}
+		var diffs []string
+		for _, c := range m.Changes {
+			switch c.Kind {
+			case "create":
+				creates++
+			case "overwrite":
+				overwrites++
+			case "delete":
+				deletes++
+			}
+			var extra []string
+			switch {
+			case c.Kind == "delete":
+				extra = append(extra, "(whiteout)")
+			case c.LinkTarget != "":
+				extra = append(extra, "-> "+c.LinkTarget)
+			case !c.Dir:
+				extra = append(extra, "["+sandbox.FormatSize(c.Bytes)+"]")
+			}
+			if c.Source != "" {
+				extra = append(extra, "("+c.Source+")")
+			}
+			if c.Opaque {
+				extra = append(extra, "(opaque: hides the host directory's contents)")
+			}
+			if c.ReplacesHostDir {
+				extra = append(extra, "(replaces a host directory)")
+			}
+			line := fmt.Sprintf("  %s %s", marks[c.Kind], c.Path)
+			if len(extra) > 0 {
+				line += "  " + strings.Join(extra, "  ")
+			}
+			if _, err := fmt.Fprintln(w, line); err != nil {
+				return err
+			}
+			if c.Diff != "" {
+				diffs = append(diffs, c.Diff)
+			}
+		}
+		if len(diffs) > 0 {
+			if _, err := fmt.Fprintf(w, "\n%s", strings.Join(diffs, "")); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintln(w); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(w, "Summary: %d create, %d overwrite, %d delete.\n", creates, overwrites, deletes)
+	return err
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"devsandbox/internal/overlay"
+	"devsandbox/internal/sandbox"
+)
+
+// newOverlayDiffSandbox lays out a sandbox whose overlay of a host directory
+// created one file and rewrote another, and returns that host directory.
+func newOverlayDiffSandbox(t *testing.T) (hostDir string) {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	hostDir = filepath.Join(home, ".config", "tool")
+	writeTestFile(t, filepath.Join(hostDir, "settings.json"), "{\"theme\": \"dark\"}\n")
+	writeTestFile(t, filepath.Join(hostDir, "keep.txt"), "untouched\n")
+
+	safe, err := overlay.SafePath(hostDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	upper := filepath.Join(sandbox.SandboxBasePath(home), "box", "home", "overlay", safe, "upper")
+	writeTestFile(t, filepath.Join(upper, "settings.json"), "{\"theme\": \"light\"}\n")
+	writeTestFile(t, filepath.Join(upper, "notes", "new.md"), "hello\n")
+	return hostDir
+}
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOverlayDiff_Text(t *testing.T) {
+	hostDir := newOverlayDiffSandbox(t)
+
+	cmd := newOverlayCmd()
+	var out, errOut bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetErr(&errOut)
+	cmd.SetArgs([]string{"diff", "--sandbox", "box", "-u"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("overlay diff: %v\n%s", err, errOut.String())
+	}
+	got := out.String()
+	for _, want := range []string{
+		hostDir + "\n",
+		"  + " + filepath.Join(hostDir, "notes") + "  (primary)\n",
+		"  + " + filepath.Join(hostDir, "notes", "new.md") + "  [6 B]  (primary)\n",
+		"  ~ " + filepath.Join(hostDir, "settings.json") + "  [19 B]  (primary)\n",
+		"-{\"theme\": \"dark\"}\n+{\"theme\": \"light\"}\n",
+		"Summary: 2 create, 1 overwrite, 0 delete.\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output missing %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "keep.txt") {
+		t.Errorf("an unchanged host file was listed:\n%s", got)
+	}
+	// Read-only: the host still has its own copy.
+	if data, _ := os.ReadFile(filepath.Join(hostDir, "settings.json")); !strings.Contains(string(data), "dark") {
+		t.Errorf("overlay diff modified the host: %q", data)
+	}
+}
+
+func TestOverlayDiff_JSONAndPathFilter(t *testing.T) {
+	hostDir := newOverlayDiffSandbox(t)
+
+	cmd := newOverlayCmd()
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetErr(&out)
+	cmd.SetArgs([]string{"diff", "--sandbox", "box", "--json", "--path", filepath.Join(hostDir, "settings.json")})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("overlay diff --json: %v", err)
+	}
+	var mounts []overlayDiffMount
+	if err := json.Unmarshal(out.Bytes(), &mounts); err != nil {
+		t.Fatalf("decode: %v\n%s", err, out.String())
+	}
+	if len(mounts) != 1 || mounts[0].HostPath != hostDir {
+		t.Fatalf("mounts = %+v, want the one overlay of %s", mounts, hostDir)
+	}
+	changes := mounts[0].Changes
+	if len(changes) != 1 || changes[0].Kind != "overwrite" || changes[0].Path != filepath.Join(hostDir, "settings.json") {
+		t.Errorf("changes = %+v, want only the settings.json overwrite", changes)
+	}
+	if changes[0].Diff != "" {
+		t.Errorf("diff included without -u: %q", changes[0].Diff)
+	}
+}
+
+func TestOverlayDiff_NoChanges(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	if err := os.MkdirAll(filepath.Join(sandbox.SandboxBasePath(home), "empty", "home"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := newOverlayCmd()
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetErr(&out)
+	cmd.SetArgs([]string{"diff", "--sandbox", "empty"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "No overlay changes.\n" {
+		t.Errorf("output = %q", out.String())
+	}
+}
+
+func TestOverlayDiff_UnknownSandbox(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	cmd := newOverlayCmd()
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetErr(&out)
+	cmd.SetArgs([]string{"diff", "--sandbox", "nope"})
+	if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), "no sandbox home") {
+		t.Errorf("want a missing sandbox error, got %v", err)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strings"
 	"syscall"
 	"time"
 
@@ -33,7 +34,9 @@ func newProxyCmd() *cobra.Command {
 }
 
 func newProxyMonitorCmd() *cobra.Command {
-	return &cobra.Command{
+	var plain bool
+
+	cmd := &cobra.Command{
 		Use:   "monitor [socket-path]",
 		Short: "Monitor and approve HTTP requests in ask mode",
 		Long: `Interactive terminal for approving/denying HTTP requests when proxy is running in ask mode.
@@ -44,22 +47,49 @@ Can be started before or after the sandbox:
 
 If no socket path is provided, it will be auto-detected from the current directory's sandbox.
 
+The monitor runs full-screen: pending requests with the time left before each
+is auto-rejected, the selected request's headers and body preview (redacted as
+in the proxy logs), and a history of recent decisions. When started before the
+sandbox it takes every session that connects, so several sandboxes of the
+project queue their requests in one view. --plain gives the line-by-line
+monitor instead, one request at a time.
+
 Keys (instant response, no Enter needed):
   a - Allow this request
   b - Block this request
   s - Allow and remember for session
   n - Block and remember for session
+  t - Allow the host for a while (full-screen only)
+  T - Block the host for a while (full-screen only)
+  f - Allow forever: saved as a rule to the project or global config
+  x - Block forever: saved as a rule to the project or global config
+
+After t or T, pick how long: 1 (5 minutes), 2 (10), 3 (30) or 4 (an hour).
+After f or x, choose what the rule covers - h (host), p (path prefix) or
+u (exact URL) - and where it is saved - l (the project's .devsandbox.toml,
+which is re-trusted) or g (the global config). Esc goes back.
+
+In the full-screen view, up/down (or k/j) select a request and PgUp/PgDn
+scroll the history; q quits.
 
 Requests that don't receive a response within 30 seconds are automatically rejected.`,
 		Example: `  # Auto-detect socket from current project (before or after sandbox)
   devsandbox proxy monitor
 
   # Explicit socket path (client mode only)
-  devsandbox proxy monitor /path/to/ask.sock`,
+  devsandbox proxy monitor /path/to/ask.sock
+
+  # The line-by-line monitor
+  devsandbox proxy monitor --plain`,
 		Args: cobra.MaximumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
+			// The full-screen view needs a terminal to draw on, not just
+			// one to read keys from.
+			if !term.IsTerminal(int(os.Stdout.Fd())) {
+				plain = true
+			}
 			if len(args) > 0 {
-				return runProxyMonitor(args[0])
+				return runProxyMonitor(args[0], plain)
 			}
 
 			sandboxBase, err := resolveSandboxBase()
@@ -71,7 +101,7 @@ Requests that don't receive a response within 30 seconds are automatically rejec
 
 			// If socket exists, try connecting directly as client (no probe dial)
 			if _, statErr := os.Stat(socketPath); statErr == nil {
-				if clientErr := runProxyMonitor(socketPath); clientErr == nil {
+				if clientErr := runProxyMonitor(socketPath, plain); clientErr == nil {
 					return nil
 				}
 				// Connection failed — stale socket, clean up and fall through
@@ -79,9 +109,13 @@ Requests that don't receive a response within 30 seconds are automatically rejec
 			}
 
 			// No socket or stale socket — start in server mode
-			return runProxyMonitorServer(sandboxBase)
+			return runProxyMonitorServer(sandboxBase, plain)
 		},
 	}
+
+	cmd.Flags().BoolVar(&plain, "plain", false, "Line-by-line monitor instead of the full-screen view")
+
+	return cmd
 }
 
 // resolveSandboxBase returns the sandbox base path for the current directory's project.
@@ -104,7 +138,7 @@ func resolveSandboxBase() (string, error) {
 	return filepath.Join(basePath, projectName), nil
 }
 
-func runProxyMonitorServer(sandboxBase string) error {
+func runProxyMonitorServer(sandboxBase string, plain bool) error {
 	if !term.IsTerminal(int(os.Stdin.Fd())) {
 		return fmt.Errorf("proxy monitor requires an interactive terminal (stdin is not a TTY)")
 	}
@@ -133,6 +167,10 @@ func runProxyMonitorServer(sandboxBase string) error {
 		_ = os.Remove(socketPath)
 	}()
 
+	if !plain {
+		return runProxyMonitorTUI(acceptConns(listener), false)
+	}
+
 	// Set terminal to raw mode
 	oldState, err := term.MakeRaw(int(os.Stdin.Fd()))
 	if err != nil {
@@ -198,6 +236,7 @@ func runProxyMonitorServer(sandboxBase string) error {
 
 		encoder := json.NewEncoder(conn)
 		decoder := json.NewDecoder(conn)
+		subscribeMonitorTopics(encoder)
 
 		// Handle requests from this sandbox session
 		for {
@@ -211,6 +250,10 @@ func runProxyMonitorServer(sandboxBase string) error {
 				_ = conn.Close()
 				break
 			}
+			if req.Type != "" {
+				displayTopicMessage(&req)
+				continue
+			}
 
 			displayRequest(&req)
 			resp := getUserDecisionWithTimeout(&req, keyChan)
@@ -226,7 +269,7 @@ func runProxyMonitorServer(sandboxBase string) error {
 	}
 }
 
-func runProxyMonitor(socketPath string) error {
+func runProxyMonitor(socketPath string, plain bool) error {
 	if !term.IsTerminal(int(os.Stdin.Fd())) {
 		return fmt.Errorf("proxy monitor requires an interactive terminal (stdin is not a TTY)")
 	}
@@ -238,8 +281,16 @@ func runProxyMonitor(socketPath string) error {
 	}
 	defer func() { _ = conn.Close() }()
 
+	if !plain {
+		conns := make(chan net.Conn, 1)
+		conns <- conn
+		close(conns)
+		return runProxyMonitorTUI(conns, true)
+	}
+
 	encoder := json.NewEncoder(conn)
 	decoder := json.NewDecoder(conn)
+	subscribeMonitorTopics(encoder)
 
 	// Set terminal to raw mode for single-key input
 	oldState, err := term.MakeRaw(int(os.Stdin.Fd()))
@@ -297,6 +348,10 @@ func runProxyMonitor(socketPath string) error {
 			fmt.Printf("\r\nConnection closed: %v\r\n", err)
 			return nil
 		}
+		if req.Type != "" {
+			displayTopicMessage(&req)
+			continue
+		}
 
 		// Display request details
 		displayRequest(&req)
@@ -319,6 +374,7 @@ func printHeader() {
 	fmt.Print("╠════════════════════════════════════════════════════════════════╣\r\n")
 	fmt.Print("║  Waiting for requests...                                       ║\r\n")
 	fmt.Print("║  Keys: [a]llow  [b]lock  [s]ession-allow  [n]ever-allow        ║\r\n")
+	fmt.Print("║        [f]orever-allow  [x] forever-block (saved to config)    ║\r\n")
 	fmt.Print("║  Requests timeout after 30 seconds (auto-reject)               ║\r\n")
 	fmt.Print("╚════════════════════════════════════════════════════════════════╝\r\n")
 	fmt.Print("\r\n")
@@ -331,6 +387,11 @@ func displayRequest(req *proxy.AskRequest) {
 	fmt.Printf("│  Method: %-55s│\r\n", req.Method)
 	fmt.Printf("│  Host:   %-55s│\r\n", truncate(req.Host, 55))
 	fmt.Printf("│  Path:   %-55s│\r\n", truncate(req.Path, 55))
+	if req.Limit != "" {
+		fmt.Print("├──────────────────────────────────────────────────────────────────┤\r\n")
+		fmt.Printf("│  %-62s│\r\n", "Over limit - a decision covers this request only:")
+		fmt.Printf("│  %-62s│\r\n", truncate(req.Limit, 62))
+	}
 
 	if len(req.Headers) > 0 {
 		fmt.Print("├──────────────────────────────────────────────────────────────────┤\r\n")
@@ -349,9 +410,52 @@ func displayRequest(req *proxy.AskRequest) {
 
 	fmt.Print("├──────────────────────────────────────────────────────────────────┤\r\n")
 	fmt.Print("│  [a]llow    [b]lock    [s]ession-allow    [n]ever-allow         │\r\n")
+	if req.Limit == "" {
+		fmt.Print("│  [f]orever-allow    [x] forever-block                           │\r\n")
+	}
 	fmt.Print("└──────────────────────────────────────────────────────────────────┘\r\n")
 }
 
+// subscribeMonitorTopics asks the proxy for the status messages this monitor
+// can display. A proxy predating topics ignores the message.
+func subscribeMonitorTopics(encoder *json.Encoder) {
+	_ = encoder.Encode(proxy.AskResponse{Subscribe: []string{proxy.AskTopicLimits}})
+}
+
+// displayTopicMessage shows a status message from the proxy. Topics this
+// monitor does not know are skipped.
+func displayTopicMessage(msg *proxy.AskRequest) {
+	if msg.Type != proxy.AskTopicLimits {
+		return
+	}
+	fmt.Print("── Limits ──────────────────────────────────────────────────────────\r\n")
+	for _, c := range msg.Limits {
+		fmt.Printf("  %s\r\n", limitCountersLine(c))
+	}
+	fmt.Print("\r\n")
+}
+
+// limitCountersLine renders one rule's counters against its limits.
+func limitCountersLine(c proxy.LimitCounters) string {
+	withMax := func(n, limit int64, format func(int64) string) string {
+		if limit > 0 {
+			return format(n) + "/" + format(limit)
+		}
+		return format(n)
+	}
+	count := func(n int64) string { return fmt.Sprintf("%d", n) }
+
+	parts := []string{
+		withMax(int64(c.Requests), int64(c.RequestsPerMinute), count) + " req/min",
+		withMax(int64(c.InFlight), int64(c.MaxConcurrent), count) + " in flight",
+		withMax(c.UploadedBytes, c.MaxUploadBytes, sandbox.FormatSize) + " sent",
+	}
+	if c.Exceeded > 0 {
+		parts = append(parts, fmt.Sprintf("%d over limit", c.Exceeded))
+	}
+	return c.Pattern + ": " + strings.Join(parts, ", ")
+}
+
 func getUserDecisionWithTimeout(req *proxy.AskRequest, keyChan <-chan byte) proxy.AskResponse {
 	timeout := 30 * time.Second
 	if req.Timeout > 0 {
@@ -394,6 +498,21 @@ func getUserDecisionWithTimeout(req *proxy.AskRequest, keyChan <-chan byte) prox
 				fmt.Printf("%c\r\n✗ Blocked for session: %s\r\n", key, req.Host)
 				return resp
 
+			case 'f', 'F', 'x', 'X':
+				// An over-limit decision covers that one request only.
+				if req.Limit != "" {
+					continue
+				}
+				resp.Action = proxy.FilterActionAllow
+				if key == 'x' || key == 'X' {
+					resp.Action = proxy.FilterActionBlock
+				}
+				fmt.Printf("%c\r\n", key)
+				if done := decidePermanently(req, &resp, keyChan, timer.C); done {
+					return resp
+				}
+				fmt.Print("Decision: ")
+
 			default:
 				continue
 			}
@@ -405,6 +524,125 @@ func getUserDecisionWithTimeout(req *proxy.AskRequest, keyChan <-chan byte) prox
 	}
 }
 
+// decidePermanently asks what a forever decision covers and where it is
+// saved, then writes the rule. It returns false when the user backed out with
+// Esc, leaving the decision open. A rule that cannot be saved still answers
+// this request, as a one-off; a request that times out meanwhile is rejected.
+func decidePermanently(req *proxy.AskRequest, resp *proxy.AskResponse, keyChan <-chan byte, timeout <-chan time.Time) bool {
+	scope := proxy.PermanentScopeHost
+	if !req.HostRulesOnly && req.Path != "" {
+		fmt.Print("  Cover: [h]ost  [p]ath prefix  [u]rl  [esc] back: ")
+		key, ok, timedOut := readChoice(keyChan, timeout, "hpu")
+		if timedOut {
+			return rejectTimedOut(resp)
+		}
+		if !ok {
+			return false
+		}
+		scope = map[byte]proxy.PermanentScope{
+			'h': proxy.PermanentScopeHost,
+			'p': proxy.PermanentScopePathPrefix,
+			'u': proxy.PermanentScopeURL,
+		}[key]
+	}
+
+	projectDir := req.Project
+	if projectDir != "" {
+		fmt.Print("  Save to: [l]ocal .devsandbox.toml  [g]lobal config  [esc] back: ")
+		key, ok, timedOut := readChoice(keyChan, timeout, "lg")
+		if timedOut {
+			return rejectTimedOut(resp)
+		}
+		if !ok {
+			return false
+		}
+		if key == 'g' {
+			projectDir = ""
+		}
+	}
+
+	verb := "✓ Allowed"
+	if resp.Action == proxy.FilterActionBlock {
+		verb = "✗ Blocked"
+	}
+	rule, err := proxy.PermanentRule(req, resp.Action, scope)
+	var path string
+	if err == nil {
+		path, err = savePermanentRule(rule, projectDir)
+	}
+	if err != nil {
+		fmt.Printf("  Rule not saved: %v\r\n%s this request only: %s\r\n", err, verb, req.Host)
+		return true
+	}
+	resp.Permanent = true
+	resp.Scope = scope
+	fmt.Printf("%s forever: %s\r\n  Rule saved to %s\r\n", verb, rule.Pattern, path)
+	return true
+}
+
+// rejectTimedOut turns resp into the rejection a request that timed out gets.
+func rejectTimedOut(resp *proxy.AskResponse) bool {
+	fmt.Print("\r\n  Request timed out (auto-rejected)\r\n")
+	*resp = proxy.AskResponse{ID: resp.ID, Action: proxy.FilterActionBlock}
+	return true
+}
+
+// readChoice waits for one of the keys in choices, case-insensitively, and
+// returns it in lower case. Esc returns ok false. The keyboard closing counts
+// as a timeout: either way no answer is coming.
+func readChoice(keyChan <-chan byte, timeout <-chan time.Time, choices string) (key byte, ok, timedOut bool) {
+	for {
+		select {
+		case key, open := <-keyChan:
+			if !open {
+				return 0, false, true
+			}
+			if key == 27 { // Esc
+				fmt.Print("\r\n")
+				return 0, false, false
+			}
+			if key >= 'A' && key <= 'Z' {
+				key += 'a' - 'A'
+			}
+			if strings.IndexByte(choices, key) >= 0 {
+				fmt.Printf("%c\r\n", key)
+				return key, true, false
+			}
+		case <-timeout:
+			return 0, false, true
+		}
+	}
+}
+
+// savePermanentRule writes rule to projectDir's .devsandbox.toml, re-trusting
+// it, or to the global config when projectDir is empty. It returns the path
+// written.
+func savePermanentRule(rule proxy.FilterRule, projectDir string) (string, error) {
+	cfgRule := config.ProxyFilterRule{
+		Pattern: rule.Pattern,
+		Action:  string(rule.Action),
+		Scope:   string(rule.Scope),
+		Type:    string(rule.Type),
+		Ports:   rule.Ports,
+	}
+	comment := "Saved from devsandbox proxy monitor on " + time.Now().Format("2006-01-02")
+
+	if projectDir == "" {
+		path := config.ConfigPath()
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return "", err
+		}
+		return path, config.AddFilterRule(path, cfgRule, comment)
+	}
+
+	store, err := config.LoadTrustStore(config.TrustStorePath())
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(projectDir, config.LocalConfigFile)
+	return path, config.AddLocalFilterRule(projectDir, cfgRule, comment, store)
+}
+
 func truncate(s string, maxLen int) string {
 	if len(s) <= maxLen {
 		return s
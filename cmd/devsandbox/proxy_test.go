@@ -0,0 +1,92 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"devsandbox/internal/config"
+	"devsandbox/internal/proxy"
+)
+
+func TestLimitCountersLine(t *testing.T) {
+	tests := []struct {
+		name string
+		in   proxy.LimitCounters
+		want string
+	}{
+		{
+			name: "all limits set",
+			in: proxy.LimitCounters{
+				Pattern: "*.openai.com", Requests: 12, RequestsPerMinute: 60,
+				InFlight: 2, MaxConcurrent: 4,
+				UploadedBytes: 3 << 20, MaxUploadBytes: 100 << 20,
+				Exceeded: 5,
+			},
+			want: "*.openai.com: 12/60 req/min, 2/4 in flight, 3.0 MB/100.0 MB sent, 5 over limit",
+		},
+		{
+			name: "unset limits shown as bare counts",
+			in:   proxy.LimitCounters{Pattern: "example.com", Requests: 3, MaxConcurrent: 1},
+			want: "example.com: 3 req/min, 0/1 in flight, 0 B sent",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := limitCountersLine(tt.in); got != tt.want {
+				t.Errorf("limitCountersLine() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetUserDecision_Forever(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	projectDir := t.TempDir()
+	req := &proxy.AskRequest{
+		ID:      "1",
+		URL:     "https://api.example.com/v1/users",
+		Host:    "api.example.com",
+		Path:    "/v1/users",
+		Project: projectDir,
+		Timeout: 5,
+	}
+	decide := func(keys string) proxy.AskResponse {
+		keyChan := make(chan byte, len(keys))
+		for i := range len(keys) {
+			keyChan <- keys[i]
+		}
+		return getUserDecisionWithTimeout(req, keyChan)
+	}
+
+	resp := decide("fpl")
+	if !resp.Permanent || resp.Action != proxy.FilterActionAllow || resp.Scope != proxy.PermanentScopePathPrefix {
+		t.Fatalf("f p l: %+v", resp)
+	}
+	localPath := filepath.Join(projectDir, config.LocalConfigFile)
+	data, err := os.ReadFile(localPath)
+	if err != nil || !strings.Contains(string(data), `pattern = "https://api.example.com/v1/**"`) {
+		t.Fatalf("project config = %q, %v", data, err)
+	}
+	store, err := config.LoadTrustStore(config.TrustStorePath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hash, _ := config.HashFile(localPath); !store.IsTrusted(projectDir, hash) {
+		t.Error("project config not re-trusted")
+	}
+
+	resp = decide("xhg")
+	if !resp.Permanent || resp.Action != proxy.FilterActionBlock || resp.Scope != proxy.PermanentScopeHost {
+		t.Fatalf("x h g: %+v", resp)
+	}
+	if data, err := os.ReadFile(config.ConfigPath()); err != nil || !strings.Contains(string(data), `pattern = "api.example.com"`) {
+		t.Fatalf("global config = %q, %v", data, err)
+	}
+
+	// Esc backs out to the plain decision.
+	if resp := decide("f\x1ba"); resp.Permanent || resp.Action != proxy.FilterActionAllow {
+		t.Errorf("f esc a: %+v", resp)
+	}
+}
@@ -0,0 +1,640 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+
+	"devsandbox/internal/proxy"
+)
+
+// The full-screen view of `devsandbox proxy monitor`. It speaks the same
+// AskRequest/AskResponse protocol as the line-by-line view, but takes every
+// connection at once, so several sessions' requests queue side by side
+// instead of one session waiting for another to disconnect.
+
+// tuiHistoryLimit bounds the decisions kept for the history pane.
+const tuiHistoryLimit = 500
+
+// tuiDurations are the choices of a timed decision, by key.
+var tuiDurations = map[tuiKey]time.Duration{
+	'1': 5 * time.Minute,
+	'2': 10 * time.Minute,
+	'3': 30 * time.Minute,
+	'4': time.Hour,
+}
+
+// tuiKey is a key press: a byte, or one of the special keys below.
+type tuiKey int
+
+const (
+	keyEsc tuiKey = -(iota + 1)
+	keyUp
+	keyDown
+	keyPgUp
+	keyPgDn
+)
+
+// parseTUIKeys splits what one read from the terminal returned into keys.
+// Escape sequences this view has no use for are skipped whole, so they are
+// not read as Esc followed by letters.
+func parseTUIKeys(buf []byte) []tuiKey {
+	var keys []tuiKey
+	for i := 0; i < len(buf); i++ {
+		if buf[i] != 27 {
+			keys = append(keys, tuiKey(buf[i]))
+			continue
+		}
+		rest := buf[i+1:]
+		if len(rest) < 2 || (rest[0] != '[' && rest[0] != 'O') {
+			keys = append(keys, keyEsc)
+			continue
+		}
+		// CSI/SS3: parameters, then a final byte in 0x40-0x7e.
+		end := 1
+		for end < len(rest) && (rest[end] < 0x40 || rest[end] > 0x7e) {
+			end++
+		}
+		switch string(rest[1:min(end+1, len(rest))]) {
+		case "A":
+			keys = append(keys, keyUp)
+		case "B":
+			keys = append(keys, keyDown)
+		case "5~":
+			keys = append(keys, keyPgUp)
+		case "6~":
+			keys = append(keys, keyPgDn)
+		}
+		i += end + 1
+	}
+	return keys
+}
+
+// tuiSource is one proxy connected to the monitor: one sandbox session.
+type tuiSource struct {
+	id     int
+	name   string // the session its requests name; "" until one does
+	conn   net.Conn
+	send   func(proxy.AskResponse) error
+	limits []proxy.LimitCounters
+}
+
+func (s *tuiSource) label() string {
+	if s.name != "" {
+		return s.name
+	}
+	return fmt.Sprintf("#%d", s.id)
+}
+
+// tuiAsk is a request waiting for a decision.
+type tuiAsk struct {
+	req      proxy.AskRequest
+	source   *tuiSource
+	deadline time.Time
+}
+
+// tuiDecision is a line of the history pane.
+type tuiDecision struct {
+	at      time.Time
+	source  string
+	request string
+	outcome string
+}
+
+type promptKind int
+
+const (
+	promptNone promptKind = iota
+	promptDuration
+	promptScope
+	promptTarget
+)
+
+// tuiPrompt is a decision that takes more than one key, part-way through.
+type tuiPrompt struct {
+	kind   promptKind
+	ask    *tuiAsk
+	action proxy.FilterAction
+	scope  proxy.PermanentScope
+}
+
+// monitorTUI is the state of the full-screen monitor. Everything happens on
+// one goroutine: connections and the keyboard feed it events.
+type monitorTUI struct {
+	sources  []*tuiSource
+	pending  []*tuiAsk
+	selected int
+	history  []tuiDecision
+	scroll   int // history entries scrolled back from the newest
+	pageSize int // history rows on screen at the last render
+	prompt   tuiPrompt
+	status   string
+
+	now func() time.Time
+}
+
+func newMonitorTUI() *monitorTUI {
+	return &monitorTUI{now: time.Now, pageSize: 10}
+}
+
+func (m *monitorTUI) connect(src *tuiSource) {
+	m.sources = append(m.sources, src)
+	m.status = fmt.Sprintf("Session %s connected.", src.label())
+}
+
+// disconnect forgets a source. Its requests are dropped: with the connection
+// gone the proxy has already rejected them.
+func (m *monitorTUI) disconnect(src *tuiSource) {
+	m.sources = slices.DeleteFunc(m.sources, func(s *tuiSource) bool { return s == src })
+	for _, a := range slices.Clone(m.pending) {
+		if a.source == src {
+			m.resolve(a, "dropped, session disconnected")
+		}
+	}
+	m.status = fmt.Sprintf("Session %s disconnected.", src.label())
+}
+
+// receive takes a message from a source: a request to queue, or a topic
+// update.
+func (m *monitorTUI) receive(src *tuiSource, req *proxy.AskRequest) {
+	if req.Type != "" {
+		if req.Type == proxy.AskTopicLimits {
+			src.limits = req.Limits
+		}
+		return
+	}
+	if req.Session != "" {
+		src.name = req.Session
+	}
+	timeout := 30 * time.Second
+	if req.Timeout > 0 {
+		timeout = time.Duration(req.Timeout) * time.Second
+	}
+	m.pending = append(m.pending, &tuiAsk{req: *req, source: src, deadline: m.now().Add(timeout)})
+}
+
+// tick rejects the requests whose time is up, as the proxy is about to.
+func (m *monitorTUI) tick() {
+	now := m.now()
+	for _, a := range slices.Clone(m.pending) {
+		if !now.Before(a.deadline) {
+			m.answer(a, proxy.AskResponse{Action: proxy.FilterActionBlock}, "timed out, rejected")
+		}
+	}
+}
+
+// answer sends resp for a and moves it to the history.
+func (m *monitorTUI) answer(a *tuiAsk, resp proxy.AskResponse, outcome string) {
+	resp.ID = a.req.ID
+	if err := a.source.send(resp); err != nil {
+		m.status = fmt.Sprintf("Failed to send response: %v", err)
+	}
+	m.resolve(a, outcome)
+}
+
+// resolve removes a from the queue and records outcome for it.
+func (m *monitorTUI) resolve(a *tuiAsk, outcome string) {
+	i := slices.Index(m.pending, a)
+	if i < 0 {
+		return
+	}
+	m.pending = slices.Delete(m.pending, i, i+1)
+	if m.selected > i || m.selected >= len(m.pending) {
+		m.selected = max(0, m.selected-1)
+	}
+	if m.prompt.ask == a {
+		m.prompt = tuiPrompt{}
+	}
+	m.history = append(m.history, tuiDecision{
+		at:      m.now(),
+		source:  a.source.label(),
+		request: a.req.Method + " " + a.req.Host + a.req.Path,
+		outcome: outcome,
+	})
+	if len(m.history) > tuiHistoryLimit {
+		m.history = m.history[len(m.history)-tuiHistoryLimit:]
+	}
+	if m.scroll > 0 {
+		m.scroll++ // keep the scrolled-back view where it is
+	}
+}
+
+// handleKey applies a key press. It returns true when the user quits.
+func (m *monitorTUI) handleKey(k tuiKey) bool {
+	if m.prompt.kind != promptNone {
+		m.handlePromptKey(k)
+		return false
+	}
+
+	switch k {
+	case 'q', 'Q':
+		return true
+	case keyUp, 'k':
+		m.selected = max(0, m.selected-1)
+		return false
+	case keyDown, 'j':
+		m.selected = max(0, min(len(m.pending)-1, m.selected+1))
+		return false
+	case keyPgUp:
+		m.scroll = min(max(0, len(m.history)-m.pageSize), m.scroll+m.pageSize)
+		return false
+	case keyPgDn:
+		m.scroll = max(0, m.scroll-m.pageSize)
+		return false
+	}
+
+	if len(m.pending) == 0 {
+		return false
+	}
+	a := m.pending[m.selected]
+	host := a.req.Host
+	switch k {
+	case 'a', 'A', 'y', 'Y':
+		m.answer(a, proxy.AskResponse{Action: proxy.FilterActionAllow}, "allowed")
+	case 'b', 'B':
+		m.answer(a, proxy.AskResponse{Action: proxy.FilterActionBlock}, "blocked")
+	case 's', 'S':
+		m.answer(a, proxy.AskResponse{Action: proxy.FilterActionAllow, Remember: true}, "allowed "+host+" for session")
+	case 'n', 'N':
+		m.answer(a, proxy.AskResponse{Action: proxy.FilterActionBlock, Remember: true}, "blocked "+host+" for session")
+	case 't', 'T', 'f', 'F', 'x', 'X':
+		// An over-limit decision covers that one request only.
+		if a.req.Limit != "" {
+			m.status = "An over-limit request can only be decided once: a, b, s or n."
+			return false
+		}
+		action := proxy.FilterActionAllow
+		if k == 'T' || k == 'x' || k == 'X' {
+			action = proxy.FilterActionBlock
+		}
+		m.prompt = tuiPrompt{kind: promptDuration, ask: a, action: action}
+		if k != 't' && k != 'T' {
+			m.prompt.kind = promptScope
+			if a.req.HostRulesOnly || a.req.Path == "" {
+				m.prompt.scope = proxy.PermanentScopeHost
+				m.afterScope()
+			}
+		}
+	}
+	return false
+}
+
+func (m *monitorTUI) handlePromptKey(k tuiKey) {
+	if k == keyEsc {
+		m.prompt = tuiPrompt{}
+		return
+	}
+	if k >= 'A' && k <= 'Z' {
+		k += 'a' - 'A'
+	}
+	p := m.prompt
+	switch p.kind {
+	case promptDuration:
+		d, ok := tuiDurations[k]
+		if !ok {
+			return
+		}
+		m.answer(p.ask, proxy.AskResponse{Action: p.action, RememberFor: int(d.Seconds())},
+			fmt.Sprintf("%s %s for %s", pastTense(p.action), p.ask.req.Host, shortDuration(d)))
+	case promptScope:
+		scope, ok := map[tuiKey]proxy.PermanentScope{
+			'h': proxy.PermanentScopeHost,
+			'p': proxy.PermanentScopePathPrefix,
+			'u': proxy.PermanentScopeURL,
+		}[k]
+		if !ok {
+			return
+		}
+		m.prompt.scope = scope
+		m.afterScope()
+	case promptTarget:
+		switch k {
+		case 'l':
+			m.savePermanentDecision(p.ask.req.Project)
+		case 'g':
+			m.savePermanentDecision("")
+		}
+	}
+}
+
+// afterScope moves a permanent decision on to where it is saved, asking only
+// when the session has a project config to offer.
+func (m *monitorTUI) afterScope() {
+	if m.prompt.ask.req.Project != "" {
+		m.prompt.kind = promptTarget
+		return
+	}
+	m.savePermanentDecision("")
+}
+
+// savePermanentDecision writes the prompt's rule and answers with it. A rule
+// that cannot be saved still answers the request, once.
+func (m *monitorTUI) savePermanentDecision(projectDir string) {
+	p := m.prompt
+	rule, err := proxy.PermanentRule(&p.ask.req, p.action, p.scope)
+	var path string
+	if err == nil {
+		path, err = savePermanentRule(rule, projectDir)
+	}
+	if err != nil {
+		m.answer(p.ask, proxy.AskResponse{Action: p.action}, pastTense(p.action)+", rule not saved")
+		m.status = fmt.Sprintf("Rule not saved: %v", err)
+		return
+	}
+	m.answer(p.ask, proxy.AskResponse{Action: p.action, Permanent: true, Scope: p.scope},
+		fmt.Sprintf("%s forever: %s", pastTense(p.action), rule.Pattern))
+	m.status = "Rule saved to " + path
+}
+
+// shortDuration formats one of tuiDurations: "10m", "1h".
+func shortDuration(d time.Duration) string {
+	if d%time.Hour == 0 {
+		return fmt.Sprintf("%dh", d/time.Hour)
+	}
+	return fmt.Sprintf("%dm", d/time.Minute)
+}
+
+func pastTense(action proxy.FilterAction) string {
+	if action == proxy.FilterActionBlock {
+		return "blocked"
+	}
+	return "allowed"
+}
+
+// render draws the whole screen for a terminal of the given size.
+func (m *monitorTUI) render(width, height int) string {
+	var lines []string
+	add := func(s string) { lines = append(lines, fitWidth(s, width)) }
+	now := m.now()
+
+	add(fmt.Sprintf("\033[7m devsandbox proxy monitor   %d session(s) connected   %d pending", len(m.sources), len(m.pending)))
+	var limits []string
+	for _, src := range m.sources {
+		for _, c := range src.limits {
+			limits = append(limits, "  "+src.label()+"  "+limitCountersLine(c))
+		}
+	}
+	for _, l := range limits[:min(len(limits), 3)] {
+		add(l)
+	}
+
+	add(paneTitle("Pending", width))
+	if len(m.pending) == 0 {
+		add("  No requests waiting.")
+	}
+	rows := min(len(m.pending), max(3, height/4))
+	start := max(0, m.selected-rows+1)
+	for i := start; i < min(len(m.pending), start+rows); i++ {
+		a := m.pending[i]
+		marker := "  "
+		if i == m.selected {
+			marker = "> "
+		}
+		left := max(0, int(a.deadline.Sub(now).Round(time.Second)/time.Second))
+		add(fmt.Sprintf("%s%3ds  %-12s %-7s %s%s", marker, left, a.source.label(), a.req.Method, a.req.Host, a.req.Path))
+	}
+
+	if len(m.pending) > 0 {
+		a := m.pending[m.selected]
+		add(paneTitle(fmt.Sprintf("Request #%s from %s", a.req.ID, a.source.label()), width))
+		detail := []string{"  " + a.req.Method + " " + a.req.URL}
+		if a.req.Limit != "" {
+			detail = append(detail, "  Over limit: "+a.req.Limit)
+		}
+		names := make([]string, 0, len(a.req.Headers))
+		for name := range a.req.Headers {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		for _, name := range names {
+			detail = append(detail, "  "+name+": "+a.req.Headers[name])
+		}
+		if a.req.Body != "" {
+			detail = append(detail, "  Body: "+strings.Join(strings.Fields(a.req.Body), " "))
+		}
+		for _, l := range detail[:min(len(detail), max(3, height/3))] {
+			add(l)
+		}
+	}
+
+	title := "History"
+	if m.scroll > 0 {
+		title = fmt.Sprintf("History (%d newer below)", m.scroll)
+	}
+	add(paneTitle(title, width))
+	m.pageSize = max(1, height-len(lines)-2)
+	m.scroll = min(m.scroll, max(0, len(m.history)-m.pageSize))
+	end := len(m.history) - m.scroll
+	for _, d := range m.history[max(0, end-m.pageSize):end] {
+		add(fmt.Sprintf("  %s  %-12s %-32s %s", d.at.Format("15:04:05"), d.source, d.outcome, d.request))
+	}
+	for len(lines) < height-2 {
+		lines = append(lines, "")
+	}
+
+	add("\033[7m ↑↓ select  a/b allow/block  s/n for session  t/T for a time  f/x forever  PgUp/PgDn history  q quit")
+	add(m.promptLine())
+	return "\033[H" + strings.Join(lines, "\033[0m\033[K\r\n") + "\033[0m\033[K\033[J"
+}
+
+// promptLine is the last line: the question of a decision in progress, or the
+// latest status.
+func (m *monitorTUI) promptLine() string {
+	p := m.prompt
+	switch p.kind {
+	case promptDuration:
+		return fmt.Sprintf(" %s %s for: [1] 5m  [2] 10m  [3] 30m  [4] 1h  [esc] back", strings.ToUpper(string(p.action[:1]))+string(p.action[1:]), p.ask.req.Host)
+	case promptScope:
+		return fmt.Sprintf(" %s forever: [h]ost  [p]ath prefix  [u]rl  [esc] back", pastTense(p.action))
+	case promptTarget:
+		return " Save the rule to: [l]ocal .devsandbox.toml  [g]lobal config  [esc] back"
+	}
+	return " " + m.status
+}
+
+// paneTitle is a pane's separator line.
+func paneTitle(title string, width int) string {
+	line := "── " + title + " "
+	return line + strings.Repeat("─", max(0, width-utf8.RuneCountInString(line)))
+}
+
+// fitWidth cuts s to width columns, counting runes and skipping escape
+// sequences, which take none.
+func fitWidth(s string, width int) string {
+	var sb strings.Builder
+	cols := 0
+	for i := 0; i < len(s); {
+		if s[i] == 27 {
+			end := strings.IndexByte(s[i:], 'm')
+			if end < 0 {
+				break
+			}
+			sb.WriteString(s[i : i+end+1])
+			i += end + 1
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if cols >= width {
+			break
+		}
+		if r < 0x20 {
+			r = ' '
+		}
+		sb.WriteRune(r)
+		cols++
+		i += size
+	}
+	return sb.String()
+}
+
+// runProxyMonitorTUI runs the full-screen monitor over the connections conns
+// delivers. With exitWhenIdle, it ends when the last connection closes, as a
+// monitor that connected to a proxy's socket has nothing left to wait for.
+func runProxyMonitorTUI(conns <-chan net.Conn, exitWhenIdle bool) error {
+	fd := int(os.Stdin.Fd())
+	oldState, err := term.MakeRaw(fd)
+	if err != nil {
+		return fmt.Errorf("failed to set raw terminal mode: %w", err)
+	}
+	defer func() { _ = term.Restore(fd, oldState) }()
+
+	// Alternate screen, cursor hidden; both undone on the way out.
+	fmt.Print("\033[?1049h\033[?25l")
+	defer fmt.Print("\033[?25h\033[?1049l")
+
+	done := make(chan struct{})
+	defer close(done)
+
+	keys := make(chan []byte, 10)
+	go func() {
+		buf := make([]byte, 64)
+		for {
+			n, readErr := os.Stdin.Read(buf)
+			if readErr != nil || n == 0 {
+				return
+			}
+			select {
+			case keys <- slices.Clone(buf[:n]):
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigChan)
+	winch := make(chan os.Signal, 1)
+	signal.Notify(winch, syscall.SIGWINCH)
+	defer signal.Stop(winch)
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	type event struct {
+		source *tuiSource
+		req    *proxy.AskRequest // nil: the source disconnected
+	}
+	events := make(chan event, 64)
+
+	m := newMonitorTUI()
+	m.status = "Waiting for a sandbox to connect..."
+	nextID := 0
+	defer func() {
+		for _, src := range m.sources {
+			_ = src.conn.Close()
+		}
+	}()
+
+	draw := func() {
+		w, h, sizeErr := term.GetSize(int(os.Stdout.Fd()))
+		if sizeErr != nil || w <= 0 || h <= 0 {
+			w, h = 80, 24
+		}
+		_, _ = os.Stdout.WriteString(m.render(w, h))
+	}
+
+	for {
+		draw()
+		select {
+		case conn, ok := <-conns:
+			if !ok {
+				conns = nil
+				continue
+			}
+			nextID++
+			encoder := json.NewEncoder(conn)
+			subscribeMonitorTopics(encoder)
+			src := &tuiSource{id: nextID, conn: conn, send: func(r proxy.AskResponse) error { return encoder.Encode(&r) }}
+			m.connect(src)
+			go func() {
+				decoder := json.NewDecoder(conn)
+				for {
+					var req proxy.AskRequest
+					decodeErr := decoder.Decode(&req)
+					ev := event{source: src}
+					if decodeErr == nil {
+						ev.req = &req
+					}
+					select {
+					case events <- ev:
+					case <-done:
+						return
+					}
+					if decodeErr != nil {
+						return
+					}
+				}
+			}()
+
+		case ev := <-events:
+			if ev.req == nil {
+				_ = ev.source.conn.Close()
+				m.disconnect(ev.source)
+				if exitWhenIdle && len(m.sources) == 0 {
+					return nil
+				}
+				continue
+			}
+			m.receive(ev.source, ev.req)
+
+		case b := <-keys:
+			for _, k := range parseTUIKeys(b) {
+				if k == 3 || m.handleKey(k) { // Ctrl+C or q
+					return nil
+				}
+			}
+
+		case <-ticker.C:
+			m.tick()
+		case <-winch:
+		case <-sigChan:
+			return nil
+		}
+	}
+}
+
+// acceptConns delivers the connections listener accepts until it is closed.
+func acceptConns(listener net.Listener) <-chan net.Conn {
+	conns := make(chan net.Conn)
+	go func() {
+		defer close(conns)
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conns <- conn
+		}
+	}()
+	return conns
+}
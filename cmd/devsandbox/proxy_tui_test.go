@@ -0,0 +1,183 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"devsandbox/internal/config"
+	"devsandbox/internal/proxy"
+)
+
+func TestParseTUIKeys(t *testing.T) {
+	got := parseTUIKeys([]byte("a\033[A\033OB\033[5~\033[6~\033\033[1;5Cq"))
+	want := []tuiKey{'a', keyUp, keyDown, keyPgUp, keyPgDn, keyEsc, 'q'}
+	if len(got) != len(want) {
+		t.Fatalf("keys = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("key %d = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+// newTestMonitorTUI returns a monitor on a settable clock with one source,
+// whose responses are collected in sent.
+func newTestMonitorTUI() (m *monitorTUI, src *tuiSource, clock *time.Time, sent *[]proxy.AskResponse) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	var responses []proxy.AskResponse
+	m = newMonitorTUI()
+	m.now = func() time.Time { return now }
+	src = &tuiSource{id: 1, send: func(r proxy.AskResponse) error {
+		responses = append(responses, r)
+		return nil
+	}}
+	m.connect(src)
+	return m, src, &now, &responses
+}
+
+func TestMonitorTUI_Decisions(t *testing.T) {
+	m, src, _, sent := newTestMonitorTUI()
+	for _, id := range []string{"1", "2", "3"} {
+		m.receive(src, &proxy.AskRequest{ID: id, Method: "GET", Host: "h" + id + ".example.com", Path: "/", Session: "web"})
+	}
+	if src.label() != "web" {
+		t.Errorf("label = %q, want the session name", src.label())
+	}
+
+	m.handleKey(keyDown)
+	m.handleKey('b')
+	m.handleKey('t')
+	m.handleKey('2')
+	m.handleKey('T')
+	m.handleKey(keyEsc)
+	m.handleKey('n')
+
+	want := []proxy.AskResponse{
+		{ID: "2", Action: proxy.FilterActionBlock},
+		{ID: "3", Action: proxy.FilterActionAllow, RememberFor: 600},
+		{ID: "1", Action: proxy.FilterActionBlock, Remember: true},
+	}
+	if len(*sent) != len(want) {
+		t.Fatalf("sent %+v, want %+v", *sent, want)
+	}
+	for i, w := range want {
+		got := (*sent)[i]
+		if got.ID != w.ID || got.Action != w.Action || got.Remember != w.Remember || got.RememberFor != w.RememberFor {
+			t.Errorf("response %d = %+v, want %+v", i, got, w)
+		}
+	}
+	if len(m.pending) != 0 {
+		t.Errorf("pending = %d, want 0", len(m.pending))
+	}
+	if got := m.history[1].outcome; got != "allowed h3.example.com for 10m" {
+		t.Errorf("history outcome = %q", got)
+	}
+}
+
+func TestMonitorTUI_Timeout(t *testing.T) {
+	m, src, clock, sent := newTestMonitorTUI()
+	m.receive(src, &proxy.AskRequest{ID: "1", Host: "a.com", Timeout: 10})
+	m.receive(src, &proxy.AskRequest{ID: "2", Host: "b.com"})
+	m.handleKey('t') // a prompt on a request that then times out
+
+	*clock = clock.Add(10 * time.Second)
+	m.tick()
+	if len(*sent) != 1 || (*sent)[0].ID != "1" || (*sent)[0].Action != proxy.FilterActionBlock {
+		t.Fatalf("sent %+v, want request 1 rejected", *sent)
+	}
+	if m.prompt.kind != promptNone {
+		t.Error("prompt left open for a request that timed out")
+	}
+	if len(m.pending) != 1 || m.pending[0].req.ID != "2" {
+		t.Errorf("pending = %+v, want request 2 left", m.pending)
+	}
+}
+
+func TestMonitorTUI_Disconnect(t *testing.T) {
+	m, src, _, sent := newTestMonitorTUI()
+	m.receive(src, &proxy.AskRequest{ID: "1", Host: "a.com"})
+	m.disconnect(src)
+	if len(*sent) != 0 || len(m.pending) != 0 || len(m.sources) != 0 {
+		t.Errorf("after disconnect: sent %+v, %d pending, %d sources", *sent, len(m.pending), len(m.sources))
+	}
+	if len(m.history) != 1 || !strings.Contains(m.history[0].outcome, "disconnected") {
+		t.Errorf("history = %+v", m.history)
+	}
+}
+
+func TestMonitorTUI_Forever(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	projectDir := t.TempDir()
+	m, src, _, sent := newTestMonitorTUI()
+	m.receive(src, &proxy.AskRequest{ID: "1", URL: "https://api.example.com/v1/users", Host: "api.example.com", Path: "/v1/users", Project: projectDir})
+	m.receive(src, &proxy.AskRequest{ID: "2", Host: "limited.com", Limit: "requests_per_minute"})
+
+	for _, k := range "fpl" {
+		m.handleKey(tuiKey(k))
+	}
+	if len(*sent) != 1 || !(*sent)[0].Permanent || (*sent)[0].Scope != proxy.PermanentScopePathPrefix {
+		t.Fatalf("sent %+v", *sent)
+	}
+	data, err := os.ReadFile(filepath.Join(projectDir, config.LocalConfigFile))
+	if err != nil || !strings.Contains(string(data), `pattern = "https://api.example.com/v1/**"`) {
+		t.Fatalf("project config = %q, %v", data, err)
+	}
+
+	// An over-limit request is decided once only.
+	m.handleKey('x')
+	if m.prompt.kind != promptNone || len(*sent) != 1 {
+		t.Errorf("x offered for an over-limit request: prompt %v, sent %+v", m.prompt.kind, *sent)
+	}
+}
+
+func TestMonitorTUI_Render(t *testing.T) {
+	m, src, clock, _ := newTestMonitorTUI()
+	m.receive(src, &proxy.AskRequest{
+		ID:      "7",
+		Method:  "POST",
+		URL:     "https://api.example.com/v1/users",
+		Host:    "api.example.com",
+		Path:    "/v1/users",
+		Session: "web",
+		Headers: map[string]string{"Authorization": "[REDACTED]", "Content-Type": "application/json"},
+		Body:    "{\n  \"name\": \"x\"\n}",
+		Timeout: 30,
+	})
+	m.receive(src, &proxy.AskRequest{ID: "8", Method: "GET", Host: "b.com", Path: "/"})
+	m.handleKey('j')
+	m.handleKey('a')
+	*clock = clock.Add(12 * time.Second)
+
+	out := m.render(80, 24)
+	for _, want := range []string{
+		"1 session(s) connected   1 pending",
+		">  18s  web          POST    api.example.com/v1/users",
+		"Request #7 from web",
+		"  Authorization: [REDACTED]",
+		`  Body: { "name": "x" }`,
+		"12:00:00  web          allowed",
+		"GET b.com/",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("render missing %q:\n%s", want, out)
+		}
+	}
+	if lines := strings.Split(out, "\r\n"); len(lines) != 24 {
+		t.Errorf("render has %d lines, want 24", len(lines))
+	}
+
+	m.handleKey('t')
+	if out := m.render(80, 24); !strings.Contains(out, "[1] 5m") {
+		t.Errorf("duration prompt not shown:\n%s", out)
+	}
+}
+
+func TestFitWidth(t *testing.T) {
+	if got := fitWidth("\033[7m héllo\tworld", 7); got != "\033[7m héllo " {
+		t.Errorf("fitWidth = %q", got)
+	}
+}
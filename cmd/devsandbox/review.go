@@ -0,0 +1,253 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"devsandbox/internal/overlay"
+	"devsandbox/internal/sandbox"
+)
+
+type reviewFlags struct {
+	sandbox    string
+	unified    bool
+	apply      bool
+	discard    bool
+	jsonOutput bool
+}
+
+func newReviewCmd() *cobra.Command {
+	f := &reviewFlags{}
+	cmd := &cobra.Command{
+		Use:   "review [path...]",
+		Short: "Review the project changes of a --project-overlay session",
+		Long: `Review what a sandbox started with --project-overlay changed in the project,
+then apply the changes to the real tree or discard them.
+
+Without --apply or --discard nothing is written: the changes are listed with
+the marks of 'devsandbox overlay diff' (+ created, ~ overwritten, - deleted),
+and -u adds a unified diff of each changed file. Paths narrow everything to
+the changes at or beneath them, so a subset can be applied and the rest
+discarded, or left for later.
+
+Applied and discarded changes leave the overlay; what remains shows up in the
+next review and in the next --project-overlay session. .git is not part of the
+overlay: the sandbox's git mode governs it directly.
+
+Applying or discarding is refused while a session of the sandbox is running.
+Acts on the sandbox of the current directory unless --sandbox names another.`,
+		Example: `  devsandbox review
+  devsandbox review -u
+  devsandbox review --apply
+  devsandbox review --apply src/ go.mod
+  devsandbox review --discard vendor/`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runReview(cmd, f, args)
+		},
+	}
+	cmd.Flags().StringVarP(&f.sandbox, "sandbox", "s", "", "Sandbox name (default: current directory)")
+	cmd.Flags().BoolVarP(&f.unified, "unified", "u", false, "Include a unified diff of each changed regular file")
+	cmd.Flags().BoolVar(&f.apply, "apply", false, "Apply the changes to the project")
+	cmd.Flags().BoolVar(&f.discard, "discard", false, "Discard the changes")
+	cmd.Flags().BoolVar(&f.jsonOutput, "json", false, "Output in JSON format")
+	cmd.MarkFlagsMutuallyExclusive("apply", "discard")
+	return cmd
+}
+
+func runReview(cmd *cobra.Command, f *reviewFlags, args []string) error {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return err
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	name := f.sandbox
+	if name == "" {
+		name = sandbox.GenerateSandboxName(cwd)
+	}
+	if name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+		return fmt.Errorf("invalid sandbox name %q", name)
+	}
+	root := filepath.Join(sandbox.SandboxBasePath(homeDir), name)
+	sandboxHome := filepath.Join(root, "home")
+	if _, err := os.Stat(sandboxHome); err != nil {
+		return fmt.Errorf("no sandbox home for %q (see 'devsandbox sandboxes list')", name)
+	}
+
+	candidates := []string{cwd}
+	if meta, err := sandbox.LoadMetadata(root); err == nil && meta.ProjectDir != "" {
+		candidates = append(candidates, meta.ProjectDir)
+	}
+	projects, unresolved, err := overlay.LocateProjectMounts(sandboxHome, candidates)
+	if err != nil {
+		return fmt.Errorf("locate project overlay: %w", err)
+	}
+	for _, u := range unresolved {
+		if _, err := fmt.Fprintf(cmd.ErrOrStderr(), "overlay/%s: the project directory no longer exists, skipping\n", u); err != nil {
+			return err
+		}
+	}
+
+	var selected []string
+	for _, a := range args {
+		abs, err := filepath.Abs(a)
+		if err != nil {
+			return err
+		}
+		selected = append(selected, abs)
+	}
+	inSelection := func(p string) bool {
+		if len(selected) == 0 {
+			return true
+		}
+		for _, s := range selected {
+			if pathWithin(p, s) {
+				return true
+			}
+		}
+		return false
+	}
+
+	var result []overlayDiffMount
+	var plans []overlay.Plan
+	for _, p := range projects {
+		plan, err := overlay.BuildPlan(p.Sources, p.HostPath)
+		if err != nil {
+			return fmt.Errorf("build plan for %s: %w", p.HostPath, err)
+		}
+		changes, err := overlayDiffChanges(plan, func(op overlay.Operation) bool { return inSelection(op.HostPath) }, f.unified)
+		if err != nil {
+			return err
+		}
+		if len(changes) > 0 {
+			result = append(result, overlayDiffMount{HostPath: p.HostPath, Changes: changes})
+			plans = append(plans, plan)
+		}
+	}
+
+	if f.jsonOutput {
+		encoder := json.NewEncoder(cmd.OutOrStdout())
+		encoder.SetIndent("", "  ")
+		if result == nil {
+			result = []overlayDiffMount{}
+		}
+		if err := encoder.Encode(result); err != nil {
+			return err
+		}
+	} else if len(result) == 0 {
+		_, err := fmt.Fprintln(cmd.OutOrStdout(), "No project changes to review.")
+		return err
+	} else if err := printOverlayDiff(cmd.OutOrStdout(), result); err != nil {
+		return err
+	}
+
+	if !f.apply && !f.discard {
+		if f.jsonOutput {
+			return nil
+		}
+		_, err := fmt.Fprintln(cmd.OutOrStdout(), "Apply them with 'devsandbox review --apply [path...]' or drop them with 'devsandbox review --discard [path...]'.")
+		return err
+	}
+	if len(result) == 0 {
+		return nil
+	}
+	// Held across the writes, so a session cannot start against an overlay
+	// that is half applied or half dropped.
+	lock, err := sandbox.LockIdle(root)
+	if errors.Is(err, sandbox.ErrSandboxBusy) {
+		return fmt.Errorf("sandbox %s has a running session; exit it before applying or discarding its changes", name)
+	}
+	if err != nil {
+		return err
+	}
+	defer func() { _ = lock.Close() }()
+
+	verb := "Discarded"
+	for i, plan := range plans {
+		project := result[i].HostPath
+		if f.apply {
+			verb = "Applied"
+			if err := overlay.Apply(reviewSelection(plan, selected, inSelection)); err != nil {
+				return fmt.Errorf("apply to %s: %w", project, err)
+			}
+		}
+		if err := dropReviewed(sandboxHome, project, selected); err != nil {
+			return err
+		}
+	}
+	// Keep stdout a single JSON document.
+	out := cmd.OutOrStdout()
+	if f.jsonOutput {
+		out = cmd.ErrOrStderr()
+	}
+	return printReviewOutcome(out, verb, result)
+}
+
+// reviewSelection narrows plan to the selected operations, plus the
+// directories above them, so a created directory keeps the mode it had in the
+// sandbox rather than getting a default one from Apply's MkdirAll.
+func reviewSelection(plan overlay.Plan, selected []string, inSelection func(string) bool) overlay.Plan {
+	if len(selected) == 0 {
+		return plan
+	}
+	out := overlay.Plan{HostPath: plan.HostPath, BySandbox: map[string][]overlay.Operation{}}
+	for _, op := range plan.Operations {
+		keep := inSelection(op.HostPath)
+		if !keep && op.IsDir {
+			for _, s := range selected {
+				if pathWithin(s, op.HostPath) {
+					keep = true
+					break
+				}
+			}
+		}
+		if keep {
+			out.Operations = append(out.Operations, op)
+		}
+	}
+	return out
+}
+
+// dropReviewed removes what was just applied or discarded from the project's
+// overlay: all of it, or the selected paths under project.
+func dropReviewed(sandboxHome, project string, selected []string) error {
+	var rels []string
+	for _, s := range selected {
+		if s == project || pathWithin(project, s) {
+			return sandbox.RemoveProjectOverlay(sandboxHome, project)
+		}
+		if pathWithin(s, project) {
+			rel, err := filepath.Rel(project, s)
+			if err != nil {
+				return err
+			}
+			rels = append(rels, rel)
+		}
+	}
+	if len(selected) == 0 {
+		return sandbox.RemoveProjectOverlay(sandboxHome, project)
+	}
+	return sandbox.DiscardProjectOverlayPaths(sandboxHome, project, rels)
+}
+
+func printReviewOutcome(w io.Writer, verb string, result []overlayDiffMount) error {
+	n := 0
+	for _, m := range result {
+		n += len(m.Changes)
+	}
+	noun := "changes"
+	if n == 1 {
+		noun = "change"
+	}
+	_, err := fmt.Fprintf(w, "%s %d %s.\n", verb, n, noun)
+	return err
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"devsandbox/internal/sandbox"
+)
+
+// newReviewSandbox lays out a sandbox "box" whose --project-overlay session
+// rewrote one project file and created another, and returns the project
+// directory and its upper.
+func newReviewSandbox(t *testing.T) (project, upper string) {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	project = filepath.Join(t.TempDir(), "project")
+	writeTestFile(t, filepath.Join(project, "main.go"), "package main\n")
+
+	sandboxHome := filepath.Join(sandbox.SandboxBasePath(home), "box", "home")
+	dir, err := sandbox.ProjectOverlayDir(sandboxHome, project)
+	if err != nil {
+		t.Fatal(err)
+	}
+	upper = filepath.Join(dir, "upper")
+	writeTestFile(t, filepath.Join(upper, "main.go"), "package main\n\nfunc main() {}\n")
+	writeTestFile(t, filepath.Join(upper, "README.md"), "# project\n")
+	return project, upper
+}
+
+func runReviewCmd(t *testing.T, args ...string) string {
+	t.Helper()
+	cmd := newReviewCmd()
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetErr(&out)
+	cmd.SetArgs(args)
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("review %v: %v\n%s", args, err, out.String())
+	}
+	return out.String()
+}
+
+func TestReview_ListsWithoutWriting(t *testing.T) {
+	project, upper := newReviewSandbox(t)
+
+	got := runReviewCmd(t, "--sandbox", "box", "-u")
+	for _, want := range []string{
+		"  + " + filepath.Join(project, "README.md"),
+		"  ~ " + filepath.Join(project, "main.go"),
+		"+func main() {}\n",
+		"Summary: 1 create, 1 overwrite, 0 delete.\n",
+		"devsandbox review --apply",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output missing %q:\n%s", want, got)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(project, "README.md")); !os.IsNotExist(err) {
+		t.Errorf("review without --apply wrote to the project: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(upper, "README.md")); err != nil {
+		t.Errorf("review without --apply changed the overlay: %v", err)
+	}
+}
+
+func TestReview_ApplySelection(t *testing.T) {
+	project, upper := newReviewSandbox(t)
+
+	got := runReviewCmd(t, "--sandbox", "box", "--apply", filepath.Join(project, "README.md"))
+	if !strings.Contains(got, "Applied 1 change.") {
+		t.Errorf("output:\n%s", got)
+	}
+	if data, _ := os.ReadFile(filepath.Join(project, "README.md")); string(data) != "# project\n" {
+		t.Errorf("selected change not applied: %q", data)
+	}
+	if data, _ := os.ReadFile(filepath.Join(project, "main.go")); string(data) != "package main\n" {
+		t.Errorf("unselected change applied: %q", data)
+	}
+	if _, err := os.Stat(filepath.Join(upper, "README.md")); !os.IsNotExist(err) {
+		t.Errorf("applied change left in the overlay: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(upper, "main.go")); err != nil {
+		t.Errorf("unselected change dropped from the overlay: %v", err)
+	}
+}
+
+func TestReview_ApplyAll(t *testing.T) {
+	project, upper := newReviewSandbox(t)
+
+	runReviewCmd(t, "--sandbox", "box", "--apply")
+	if data, _ := os.ReadFile(filepath.Join(project, "main.go")); !strings.Contains(string(data), "func main") {
+		t.Errorf("change not applied: %q", data)
+	}
+	if _, err := os.Stat(filepath.Dir(upper)); !os.IsNotExist(err) {
+		t.Errorf("project overlay still present after applying everything: %v", err)
+	}
+	if got := runReviewCmd(t, "--sandbox", "box"); got != "No project changes to review.\n" {
+		t.Errorf("second review = %q", got)
+	}
+}
+
+func TestReview_Discard(t *testing.T) {
+	project, upper := newReviewSandbox(t)
+
+	got := runReviewCmd(t, "--sandbox", "box", "--discard")
+	if !strings.Contains(got, "Discarded 2 changes.") {
+		t.Errorf("output:\n%s", got)
+	}
+	if data, _ := os.ReadFile(filepath.Join(project, "main.go")); string(data) != "package main\n" {
+		t.Errorf("discard wrote to the project: %q", data)
+	}
+	if _, err := os.Stat(filepath.Dir(upper)); !os.IsNotExist(err) {
+		t.Errorf("project overlay still present after discarding: %v", err)
+	}
+}
+
+func TestReview_RefusesWhileRunning(t *testing.T) {
+	_, _ = newReviewSandbox(t)
+	home, _ := os.UserHomeDir()
+	handle, err := sandbox.AcquireSession(filepath.Join(sandbox.SandboxBasePath(home), "box"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = handle.Release() }()
+
+	cmd := newReviewCmd()
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetErr(&out)
+	cmd.SetArgs([]string{"--sandbox", "box", "--apply"})
+	if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), "running session") {
+		t.Errorf("want a running-session error, got %v", err)
+	}
+}
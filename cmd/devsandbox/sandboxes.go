@@ -98,6 +98,7 @@ func newListCmd() *cobra.Command {
 							notice.Warn("failed to calculate size for %s: %v", s.Name, err)
 						}
 						s.SizeBytes = size
+						s.SnapshotBytes, _ = sandbox.GetSnapshotsSize(s.SandboxRoot)
 					}
 				}
 			}
@@ -394,6 +395,20 @@ func formatSandboxStatus(s *sandbox.Metadata) string {
 	return strings.Join(parts, ", ")
 }
 
+// formatSandboxSize renders the size column. Snapshots are included in the
+// size, since pruning the sandbox reclaims them too, and called out because
+// they are the part `devsandbox snapshot rm` can reclaim without pruning.
+func formatSandboxSize(s *sandbox.Metadata) string {
+	if s.Isolation == sandbox.IsolationDocker && s.SizeBytes == 0 {
+		return "-"
+	}
+	size := sandbox.FormatSize(s.SizeBytes)
+	if s.SnapshotBytes > 0 {
+		size += " (snapshots " + sandbox.FormatSize(s.SnapshotBytes) + ")"
+	}
+	return size
+}
+
 func printJSON(sandboxes []*sandbox.Metadata) error {
 	encoder := json.NewEncoder(os.Stdout)
 	encoder.SetIndent("", "  ")
@@ -422,10 +437,7 @@ func printTable(sandboxes []*sandbox.Metadata, showSize bool) error {
 			isoType = "bwrap"
 		}
 
-		sizeStr := sandbox.FormatSize(s.SizeBytes)
-		if s.Isolation == sandbox.IsolationDocker && s.SizeBytes == 0 {
-			sizeStr = "-"
-		}
+		sizeStr := formatSandboxSize(s)
 
 		if showSize {
 			_ = table.Append(
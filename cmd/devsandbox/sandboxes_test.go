@@ -55,3 +55,22 @@ func TestFormatSandboxStatus(t *testing.T) {
 		})
 	}
 }
+
+func TestFormatSandboxSize(t *testing.T) {
+	tests := []struct {
+		name string
+		meta *sandbox.Metadata
+		want string
+	}{
+		{"plain", &sandbox.Metadata{SizeBytes: 2048}, "2.0 KB"},
+		{"with snapshots", &sandbox.Metadata{SizeBytes: 3 << 20, SnapshotBytes: 1 << 20}, "3.0 MB (snapshots 1.0 MB)"},
+		{"docker without volume data", &sandbox.Metadata{Isolation: sandbox.IsolationDocker}, "-"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatSandboxSize(tt.meta); got != tt.want {
+				t.Errorf("formatSandboxSize() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,8 +1,11 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"strconv"
 	"strings"
@@ -11,6 +14,8 @@ import (
 	"github.com/olekukonko/tablewriter"
 	"github.com/spf13/cobra"
 
+	"devsandbox/internal/cgroups"
+	"devsandbox/internal/sandbox"
 	"devsandbox/internal/session"
 )
 
@@ -52,6 +57,7 @@ func newSessionsCmd() *cobra.Command {
 	}
 
 	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Output in JSON format")
+	cmd.AddCommand(newSessionsStatsCmd())
 	return cmd
 }
 
@@ -87,6 +93,21 @@ func formatPorts(ports []session.ForwardedPort) string {
 		if i > 0 {
 			b.WriteString(", ")
 		}
+		if p.Direction == session.DirectionReverse {
+			// Sandbox port first, then where it leads on the host.
+			b.WriteString(strconv.Itoa(p.SandboxPort))
+			if p.Protocol != "" {
+				b.WriteString("/")
+				b.WriteString(p.Protocol)
+			}
+			b.WriteString("←")
+			if p.HostSocket != "" {
+				b.WriteString(p.HostSocket)
+			} else {
+				b.WriteString(strconv.Itoa(p.HostPort))
+			}
+			continue
+		}
 		if p.HostPort == p.SandboxPort {
 			b.WriteString(strconv.Itoa(p.HostPort))
 		} else {
@@ -94,6 +115,10 @@ func formatPorts(ports []session.ForwardedPort) string {
 			b.WriteString("→")
 			b.WriteString(strconv.Itoa(p.SandboxPort))
 		}
+		if p.Protocol != "" {
+			b.WriteString("/")
+			b.WriteString(p.Protocol)
+		}
 	}
 	return b.String()
 }
@@ -111,3 +136,225 @@ func formatTimeAgo(t time.Time) string {
 		return t.Format("2006-01-02 15:04")
 	}
 }
+
+func newSessionsStatsCmd() *cobra.Command {
+	var (
+		jsonOutput bool
+		watch      bool
+		interval   time.Duration
+	)
+
+	cmd := &cobra.Command{
+		Use:   "stats [name]",
+		Short: "Show live resource usage of running sessions",
+		Long: `Show live CPU, memory, swap, IO and process usage of running sessions.
+
+Usage is read from the sandbox's own cgroup: memory.current, cpu.stat, io.stat
+and pids.current. CPU and IO are rates, measured over --interval. A bwrap
+sandbox gets its own cgroup only when a [sandbox.resources] limit is set;
+without one it shares devsandbox's cgroup and has no stats of its own. Docker
+and krun sandboxes always have one.`,
+		Example: `  devsandbox sessions stats
+  devsandbox sessions stats myproject --watch
+  devsandbox sessions stats --json`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if jsonOutput && watch {
+				return errors.New("--json cannot be combined with --watch")
+			}
+			if interval <= 0 {
+				return fmt.Errorf("--interval must be positive, got %s", interval)
+			}
+
+			store, err := session.DefaultStore()
+			if err != nil {
+				return err
+			}
+			list := func() ([]*session.Session, error) {
+				store.CleanStale()
+				if len(args) == 1 {
+					sess, err := store.Get(args[0])
+					if err != nil {
+						return nil, err
+					}
+					return []*session.Session{sess}, nil
+				}
+				return store.ListLive()
+			}
+
+			sessions, err := list()
+			if err != nil {
+				return err
+			}
+			if len(sessions) == 0 {
+				fmt.Println("No running sandbox sessions.")
+				return nil
+			}
+
+			return watchSessionStats(cmd.Context(), os.Stdout, list, sessions, interval, watch, jsonOutput)
+		},
+	}
+
+	cmd.Flags().DurationVar(&interval, "interval", time.Second, "Time over which CPU and IO rates are measured")
+	cmd.Flags().BoolVarP(&watch, "watch", "w", false, "Keep refreshing every interval until interrupted")
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Output in JSON format")
+	return cmd
+}
+
+// statsReading is one ReadStats result for a session, keyed by session name.
+type statsReading struct {
+	stats cgroups.Stats
+	err   error
+}
+
+// sessionStats is what stats reports for one session. Stats and Rates are nil
+// when there is nothing to report, and Unavailable then says why.
+type sessionStats struct {
+	Name        string         `json:"name"`
+	Stats       *cgroups.Stats `json:"stats,omitempty"`
+	Rates       *cgroups.Rates `json:"rates,omitempty"`
+	Unavailable string         `json:"unavailable,omitempty"`
+}
+
+// watchSessionStats samples every session twice, interval apart, and reports
+// usage and the rates in between. With watch it keeps sampling, reusing each
+// reading as the baseline for the next, and re-lists the sessions each time
+// so ones started or stopped meanwhile come and go.
+func watchSessionStats(ctx context.Context, w io.Writer, list func() ([]*session.Session, error), sessions []*session.Session, interval time.Duration, watch, jsonOutput bool) error {
+	prev := readSessionStats(sessions, cgroups.ReadStats)
+	prevAt := time.Now()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(interval):
+		}
+
+		if watch {
+			var err error
+			if sessions, err = list(); err != nil {
+				return err
+			}
+		}
+		cur := readSessionStats(sessions, cgroups.ReadStats)
+		now := time.Now()
+		report := buildSessionStats(sessions, prev, cur, now.Sub(prevAt))
+
+		if jsonOutput {
+			encoder := json.NewEncoder(w)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(report)
+		}
+		if watch {
+			// Home the cursor and clear the screen, like watch(1).
+			if _, err := io.WriteString(w, "\033[H\033[2J"); err != nil {
+				return err
+			}
+		}
+		if err := printSessionStatsTable(w, report); err != nil {
+			return err
+		}
+		if !watch {
+			return nil
+		}
+		prev, prevAt = cur, now
+	}
+}
+
+func readSessionStats(sessions []*session.Session, read func(dir string) (cgroups.Stats, error)) map[string]statsReading {
+	readings := make(map[string]statsReading, len(sessions))
+	for _, s := range sessions {
+		if s.CgroupDir == "" {
+			continue
+		}
+		st, err := read(s.CgroupDir)
+		readings[s.Name] = statsReading{stats: st, err: err}
+	}
+	return readings
+}
+
+func buildSessionStats(sessions []*session.Session, prev, cur map[string]statsReading, elapsed time.Duration) []sessionStats {
+	report := make([]sessionStats, 0, len(sessions))
+	for _, s := range sessions {
+		entry := sessionStats{Name: s.Name}
+		r, ok := cur[s.Name]
+		switch {
+		case s.CgroupDir == "":
+			entry.Unavailable = "no cgroup of its own (set a [sandbox.resources] limit)"
+		case !ok:
+			entry.Unavailable = "not sampled"
+		case errors.Is(r.err, cgroups.ErrProcessGone):
+			entry.Unavailable = "exited"
+		case r.err != nil:
+			entry.Unavailable = r.err.Error()
+		default:
+			st := r.stats
+			entry.Stats = &st
+			// A session new since the last reading has no baseline yet.
+			if p, ok := prev[s.Name]; ok && p.err == nil {
+				rates := cgroups.RatesBetween(p.stats, r.stats, elapsed)
+				entry.Rates = &rates
+			}
+		}
+		report = append(report, entry)
+	}
+	return report
+}
+
+func printSessionStatsTable(w io.Writer, report []sessionStats) error {
+	table := tablewriter.NewWriter(w)
+	table.Header("NAME", "CPU", "MEMORY", "SWAP", "IO READ", "IO WRITE", "PIDS")
+
+	var notes []string
+	for _, e := range report {
+		if err := table.Append(sessionStatsRow(e)); err != nil {
+			return err
+		}
+		if e.Unavailable != "" {
+			notes = append(notes, fmt.Sprintf("%s: %s", e.Name, e.Unavailable))
+		}
+	}
+	if err := table.Render(); err != nil {
+		return err
+	}
+	for _, n := range notes {
+		if _, err := fmt.Fprintln(w, n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sessionStatsRow formats one table row, with "-" for anything that is not
+// accounted: a controller that is not enabled, or a rate with no baseline.
+func sessionStatsRow(e sessionStats) []string {
+	row := []string{e.Name, "-", "-", "-", "-", "-", "-"}
+	st := e.Stats
+	if st == nil {
+		return row
+	}
+	if e.Rates != nil {
+		row[1] = fmt.Sprintf("%.1f%%", e.Rates.CPUPercent)
+	}
+	if st.HasMemory {
+		row[2] = formatUsage(sandbox.FormatSize(st.MemoryCurrent), st.MemoryMax, sandbox.FormatSize)
+		row[3] = sandbox.FormatSize(st.SwapCurrent)
+	}
+	if st.HasIO && e.Rates != nil {
+		row[4] = sandbox.FormatSize(int64(e.Rates.IOReadBPS)) + "/s"
+		row[5] = sandbox.FormatSize(int64(e.Rates.IOWriteBPS)) + "/s"
+	}
+	if st.HasPIDs {
+		row[6] = formatUsage(strconv.FormatInt(st.PIDs, 10), st.PIDsMax, func(n int64) string { return strconv.FormatInt(n, 10) })
+	}
+	return row
+}
+
+// formatUsage appends " / limit" to current, unless there is no limit.
+func formatUsage(current string, limit int64, format func(int64) string) string {
+	if limit == 0 {
+		return current
+	}
+	return current + " / " + format(limit)
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"devsandbox/internal/cgroups"
+	"devsandbox/internal/session"
+)
+
+func TestFormatPorts(t *testing.T) {
+	tests := []struct {
+		name  string
+		ports []session.ForwardedPort
+		want  string
+	}{
+		{"none", nil, "(none)"},
+		{"same port", []session.ForwardedPort{{HostPort: 8080, SandboxPort: 8080, Protocol: "tcp"}}, "8080/tcp"},
+		{"remapped", []session.ForwardedPort{{HostPort: 9000, SandboxPort: 80, Protocol: "tcp"}}, "9000→80/tcp"},
+		{"mixed protocols", []session.ForwardedPort{
+			{HostPort: 8080, SandboxPort: 8080, Protocol: "tcp"},
+			{HostPort: 5353, SandboxPort: 5353, Protocol: "udp"},
+		}, "8080/tcp, 5353/udp"},
+		{"reverse to a socket", []session.ForwardedPort{
+			{SandboxPort: 5432, Protocol: "tcp", Direction: session.DirectionReverse, HostSocket: "/run/postgresql/.s.PGSQL.5432"},
+		}, "5432/tcp←/run/postgresql/.s.PGSQL.5432"},
+		{"reverse to a port", []session.ForwardedPort{
+			{HostPort: 9229, SandboxPort: 9230, Protocol: "tcp", Direction: session.DirectionReverse},
+		}, "9230/tcp←9229"},
+		// Records written before the protocol was stored.
+		{"no protocol", []session.ForwardedPort{{HostPort: 3000, SandboxPort: 3000}}, "3000"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatPorts(tt.ports); got != tt.want {
+				t.Errorf("formatPorts() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildSessionStats(t *testing.T) {
+	sessions := []*session.Session{
+		{Name: "limited", CgroupDir: "/sys/fs/cgroup/limited"},
+		{Name: "fresh", CgroupDir: "/sys/fs/cgroup/fresh"},
+		{Name: "gone", CgroupDir: "/sys/fs/cgroup/gone"},
+		{Name: "shared"},
+	}
+	prev := map[string]statsReading{
+		"limited": {stats: cgroups.Stats{CPUUsage: time.Second, HasIO: true, IOWrite: 0}},
+	}
+	cur := map[string]statsReading{
+		"limited": {stats: cgroups.Stats{CPUUsage: 3 * time.Second, HasIO: true, IOWrite: 4 << 20}},
+		"fresh":   {stats: cgroups.Stats{CPUUsage: time.Second}},
+		"gone":    {err: cgroups.ErrProcessGone},
+	}
+
+	report := buildSessionStats(sessions, prev, cur, 2*time.Second)
+	if len(report) != len(sessions) {
+		t.Fatalf("got %d entries, want one per session", len(report))
+	}
+
+	limited := report[0]
+	if limited.Rates == nil || limited.Rates.CPUPercent != 100 || limited.Rates.IOWriteBPS != 2<<20 {
+		t.Errorf("limited rates = %+v, want 100%% CPU and 2 MiB/s written", limited.Rates)
+	}
+	if fresh := report[1]; fresh.Stats == nil || fresh.Rates != nil {
+		t.Errorf("a session with no previous reading must report usage but no rates, got %+v", fresh)
+	}
+	if gone := report[2]; gone.Unavailable != "exited" {
+		t.Errorf("gone.Unavailable = %q, want exited", gone.Unavailable)
+	}
+	if shared := report[3]; shared.Stats != nil || !strings.Contains(shared.Unavailable, "[sandbox.resources]") {
+		t.Errorf("a session without a cgroup must say how to get one, got %+v", shared)
+	}
+}
+
+func TestSessionStatsRow(t *testing.T) {
+	tests := []struct {
+		name  string
+		entry sessionStats
+		want  []string
+	}{
+		{
+			"unavailable",
+			sessionStats{Name: "s", Unavailable: "exited"},
+			[]string{"s", "-", "-", "-", "-", "-", "-"},
+		},
+		{
+			"limited",
+			sessionStats{
+				Name: "s",
+				Stats: &cgroups.Stats{
+					HasMemory: true, MemoryCurrent: 512 << 20, MemoryMax: 2 << 30, SwapCurrent: 0,
+					HasIO:   true,
+					HasPIDs: true, PIDs: 12, PIDsMax: 512,
+				},
+				Rates: &cgroups.Rates{CPUPercent: 150, IOReadBPS: 1 << 20, IOWriteBPS: 0},
+			},
+			[]string{"s", "150.0%", "512.0 MB / 2.0 GB", "0 B", "1.0 MB/s", "0 B/s", "12 / 512"},
+		},
+		{
+			"unlimited and no io controller",
+			sessionStats{
+				Name:  "s",
+				Stats: &cgroups.Stats{HasMemory: true, MemoryCurrent: 2048, HasPIDs: true, PIDs: 3},
+				Rates: &cgroups.Rates{CPUPercent: 0.5},
+			},
+			[]string{"s", "0.5%", "2.0 KB", "0 B", "-", "-", "3"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sessionStatsRow(tt.entry); !slices.Equal(got, tt.want) {
+				t.Errorf("sessionStatsRow() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrintSessionStatsTableNotesUnavailable(t *testing.T) {
+	var buf bytes.Buffer
+	report := []sessionStats{
+		{Name: "a", Stats: &cgroups.Stats{HasPIDs: true, PIDs: 4}},
+		{Name: "b", Unavailable: "exited"},
+	}
+	if err := printSessionStatsTable(&buf, report); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	if !strings.Contains(out, "IO WRITE") {
+		t.Errorf("missing header in:\n%s", out)
+	}
+	if !strings.Contains(out, "b: exited") {
+		t.Errorf("unavailable session not explained in:\n%s", out)
+	}
+}
+
+func TestReadSessionStatsSkipsSessionsWithoutCgroup(t *testing.T) {
+	var read []string
+	readings := readSessionStats([]*session.Session{{Name: "a", CgroupDir: "/cg/a"}, {Name: "b"}}, func(dir string) (cgroups.Stats, error) {
+		read = append(read, dir)
+		return cgroups.Stats{}, errors.New("boom")
+	})
+	if !slices.Equal(read, []string{"/cg/a"}) {
+		t.Errorf("read %v, want only the session with a cgroup", read)
+	}
+	if _, ok := readings["b"]; ok {
+		t.Error("a session without a cgroup must have no reading")
+	}
+	if readings["a"].err == nil {
+		t.Error("read error was dropped")
+	}
+}
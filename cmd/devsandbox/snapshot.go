@@ -0,0 +1,293 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+
+	"devsandbox/internal/sandbox"
+)
+
+func newSnapshotCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "snapshot",
+		Short: "Save and roll back a sandbox's home",
+		Long: `Save and roll back a sandbox's persistent home: tool configuration, caches
+and the overlay uppers under it.
+
+A snapshot is a copy of the sandbox home kept beside it, under
+~/.local/share/devsandbox/<sandbox>/snapshots/. Restoring one replaces the
+home wholesale, so a sandbox whose tool config or caches were wrecked can be
+reset to a known-good point without pruning it. The project directory itself
+is never touched. Snapshots cover bwrap sandboxes; docker and krun keep their
+home in a volume.
+
+Commands act on the sandbox of the current directory unless --sandbox names
+another one (see 'devsandbox sandboxes list').`,
+	}
+	cmd.PersistentFlags().StringP("sandbox", "s", "", "Sandbox name (default: current directory)")
+
+	cmd.AddCommand(newSnapshotCreateCmd())
+	cmd.AddCommand(newSnapshotListCmd())
+	cmd.AddCommand(newSnapshotRestoreCmd())
+	cmd.AddCommand(newSnapshotRmCmd())
+	cmd.AddCommand(newSnapshotDiffCmd())
+	return cmd
+}
+
+func newSnapshotCreateCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "create [name]",
+		Short: "Snapshot the sandbox home",
+		Long: `Snapshot the sandbox home under name, or under the current time when no name
+is given. A running sandbox can be snapshotted, but files it is writing at that
+moment may be captured half-written; exit it first for a clean snapshot.`,
+		Example: `  devsandbox snapshot create
+  devsandbox snapshot create before-upgrade
+  devsandbox snapshot create known-good --sandbox myproject-1a2b3c4d`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			root, err := resolveSnapshotSandbox(cmd)
+			if err != nil {
+				return err
+			}
+			name := time.Now().Format("20060102-150405")
+			if len(args) == 1 {
+				name = args[0]
+			}
+			if sandbox.IsSessionActive(root) {
+				if _, err := fmt.Fprintln(cmd.ErrOrStderr(), "Warning: the sandbox is running; files it is writing may be captured half-written."); err != nil {
+					return err
+				}
+			}
+			snap, err := sandbox.CreateSnapshot(root, name)
+			if err != nil {
+				return err
+			}
+			size, _ := sandbox.GetSandboxSize(snap.Path)
+			_, err = fmt.Fprintf(cmd.OutOrStdout(), "Created snapshot %s (%s)\n", snap.Name, sandbox.FormatSize(size))
+			return err
+		},
+	}
+}
+
+func newSnapshotListCmd() *cobra.Command {
+	var jsonOutput bool
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List the sandbox's snapshots",
+		Example: `  devsandbox snapshot list
+  devsandbox snapshot list --json`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			root, err := resolveSnapshotSandbox(cmd)
+			if err != nil {
+				return err
+			}
+			snaps, err := sandbox.ListSnapshots(root)
+			if err != nil {
+				return err
+			}
+			if jsonOutput {
+				encoder := json.NewEncoder(cmd.OutOrStdout())
+				encoder.SetIndent("", "  ")
+				if snaps == nil {
+					snaps = []*sandbox.Snapshot{}
+				}
+				return encoder.Encode(snaps)
+			}
+			if len(snaps) == 0 {
+				_, err := fmt.Fprintln(cmd.OutOrStdout(), "No snapshots.")
+				return err
+			}
+			for _, s := range snaps {
+				s.SizeBytes, _ = sandbox.GetSandboxSize(s.Path)
+			}
+			return printSnapshotsTable(cmd.OutOrStdout(), snaps)
+		},
+	}
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Output in JSON format")
+	return cmd
+}
+
+func printSnapshotsTable(w io.Writer, snaps []*sandbox.Snapshot) error {
+	table := tablewriter.NewWriter(w)
+	table.Header("NAME", "CREATED", "SIZE")
+	for _, s := range snaps {
+		_ = table.Append(s.Name, s.CreatedAt.Format("2006-01-02 15:04"), sandbox.FormatSize(s.SizeBytes))
+	}
+	return table.Render()
+}
+
+func newSnapshotRestoreCmd() *cobra.Command {
+	var force bool
+	cmd := &cobra.Command{
+		Use:   "restore <name>",
+		Short: "Replace the sandbox home with a snapshot",
+		Long: `Replace the sandbox home with a snapshot. Everything written to the home since
+the snapshot was taken is discarded; 'devsandbox snapshot diff' shows what that
+is. The snapshot itself is kept. Refused while any session of the sandbox is
+running.`,
+		Example: `  devsandbox snapshot restore before-upgrade
+  devsandbox snapshot restore known-good --force`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			root, err := resolveSnapshotSandbox(cmd)
+			if err != nil {
+				return err
+			}
+			if _, err := sandbox.GetSnapshot(root, args[0]); err != nil {
+				return err
+			}
+			if sandbox.IsSessionActive(root) {
+				return fmt.Errorf("sandbox %s has a running session; exit it before restoring", filepath.Base(root))
+			}
+
+			if !force {
+				fmt.Printf("Replace the home of %s with snapshot %s? Changes since the snapshot are lost. [y/N] ", filepath.Base(root), args[0])
+				reader := bufio.NewReader(os.Stdin)
+				response, err := reader.ReadString('\n')
+				if err != nil {
+					return err
+				}
+				response = strings.TrimSpace(strings.ToLower(response))
+				if response != "y" && response != "yes" {
+					fmt.Println("Aborted.")
+					return nil
+				}
+			}
+
+			if err := sandbox.RestoreSnapshot(root, args[0]); err != nil {
+				if errors.Is(err, sandbox.ErrSandboxBusy) {
+					return fmt.Errorf("sandbox %s has a running session; exit it before restoring", filepath.Base(root))
+				}
+				return err
+			}
+			_, err = fmt.Fprintf(cmd.OutOrStdout(), "Restored snapshot %s\n", args[0])
+			return err
+		},
+	}
+	cmd.Flags().BoolVarP(&force, "force", "f", false, "Skip confirmation prompt")
+	return cmd
+}
+
+func newSnapshotRmCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:     "rm <name>...",
+		Aliases: []string{"remove"},
+		Short:   "Delete snapshots",
+		Example: `  devsandbox snapshot rm before-upgrade`,
+		Args:    cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			root, err := resolveSnapshotSandbox(cmd)
+			if err != nil {
+				return err
+			}
+			for _, name := range args {
+				if err := sandbox.RemoveSnapshot(root, name); err != nil {
+					return err
+				}
+				if _, err := fmt.Fprintf(cmd.OutOrStdout(), "Removed snapshot %s\n", name); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+}
+
+func newSnapshotDiffCmd() *cobra.Command {
+	var jsonOutput bool
+	cmd := &cobra.Command{
+		Use:   "diff <name>",
+		Short: "Show what changed in the sandbox home since a snapshot",
+		Long: `Show what changed in the sandbox home since a snapshot: what restoring it
+would undo. Files are compared by type, size and modification time. A directory
+added or removed as a whole is listed once, without its contents.`,
+		Example: `  devsandbox snapshot diff before-upgrade
+  devsandbox snapshot diff before-upgrade --json`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			root, err := resolveSnapshotSandbox(cmd)
+			if err != nil {
+				return err
+			}
+			changes, err := sandbox.DiffSnapshot(root, args[0])
+			if err != nil {
+				return err
+			}
+			if jsonOutput {
+				encoder := json.NewEncoder(cmd.OutOrStdout())
+				encoder.SetIndent("", "  ")
+				if changes == nil {
+					changes = []sandbox.SnapshotChange{}
+				}
+				return encoder.Encode(changes)
+			}
+			return printSnapshotChanges(cmd.OutOrStdout(), changes)
+		},
+	}
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Output in JSON format")
+	return cmd
+}
+
+// printSnapshotChanges prints one change per line, marked like `git status
+// --short`: A added, D removed, M modified.
+func printSnapshotChanges(w io.Writer, changes []sandbox.SnapshotChange) error {
+	if len(changes) == 0 {
+		_, err := fmt.Fprintln(w, "No changes since the snapshot.")
+		return err
+	}
+	marks := map[sandbox.SnapshotChangeKind]string{
+		sandbox.SnapshotAdded:    "A",
+		sandbox.SnapshotRemoved:  "D",
+		sandbox.SnapshotModified: "M",
+	}
+	for _, c := range changes {
+		if _, err := fmt.Fprintf(w, "%s %s\n", marks[c.Kind], c.Path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolveSnapshotSandbox returns the root of the sandbox the command acts on:
+// the one named by --sandbox, or the current directory's.
+func resolveSnapshotSandbox(cmd *cobra.Command) (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	name, _ := cmd.Flags().GetString("sandbox")
+	if name == "" {
+		cwd, err := os.Getwd()
+		if err != nil {
+			return "", err
+		}
+		name = sandbox.GenerateSandboxName(cwd)
+	}
+	if name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+		return "", fmt.Errorf("invalid sandbox name %q", name)
+	}
+
+	root := filepath.Join(sandbox.SandboxBasePath(homeDir), name)
+	if _, err := os.Stat(filepath.Join(root, "home")); err != nil {
+		return "", fmt.Errorf("no sandbox home for %q (see 'devsandbox sandboxes list')", name)
+	}
+	if meta, err := sandbox.LoadMetadata(root); err == nil {
+		switch meta.Isolation {
+		case sandbox.IsolationDocker, sandbox.IsolationKrun:
+			return "", fmt.Errorf("sandbox %q uses %s, which keeps its home in a volume; snapshots cover bwrap sandboxes only", name, meta.Isolation)
+		}
+	}
+	return root, nil
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"devsandbox/internal/sandbox"
+)
+
+// runSnapshotCmd runs `devsandbox snapshot <args>` against a sandbox called
+// "proj" under a temporary HOME, and returns its output.
+func runSnapshotCmd(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	cmd := newSnapshotCmd()
+	var buf bytes.Buffer
+	cmd.SetOut(&buf)
+	cmd.SetErr(&buf)
+	cmd.SetArgs(append(args, "--sandbox", "proj"))
+	err := cmd.Execute()
+	return buf.String(), err
+}
+
+func newSnapshotCmdSandbox(t *testing.T) string {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	root := filepath.Join(sandbox.SandboxBasePath(home), "proj")
+	if err := os.MkdirAll(filepath.Join(root, "home", ".config"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "home", ".config", "tool.toml"), []byte("ok"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return root
+}
+
+func TestSnapshotCmd_CreateListDiffRestoreRm(t *testing.T) {
+	root := newSnapshotCmdSandbox(t)
+
+	if out, err := runSnapshotCmd(t, "create", "good"); err != nil || !strings.Contains(out, "Created snapshot good") {
+		t.Fatalf("create: %v\n%s", err, out)
+	}
+	if out, err := runSnapshotCmd(t, "list"); err != nil || !strings.Contains(out, "good") {
+		t.Fatalf("list: %v\n%s", err, out)
+	}
+
+	if err := os.WriteFile(filepath.Join(root, "home", "junk"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := runSnapshotCmd(t, "diff", "good"); err != nil || !strings.Contains(out, "A junk") {
+		t.Fatalf("diff: %v\n%s", err, out)
+	}
+
+	if out, err := runSnapshotCmd(t, "restore", "good", "--force"); err != nil || !strings.Contains(out, "Restored snapshot good") {
+		t.Fatalf("restore: %v\n%s", err, out)
+	}
+	if _, err := os.Lstat(filepath.Join(root, "home", "junk")); !os.IsNotExist(err) {
+		t.Errorf("restore kept a file written after the snapshot: %v", err)
+	}
+
+	if out, err := runSnapshotCmd(t, "rm", "good"); err != nil || !strings.Contains(out, "Removed snapshot good") {
+		t.Fatalf("rm: %v\n%s", err, out)
+	}
+	if out, err := runSnapshotCmd(t, "list"); err != nil || !strings.Contains(out, "No snapshots.") {
+		t.Errorf("list after rm: %v\n%s", err, out)
+	}
+}
+
+func TestSnapshotCmd_RestoreRefusesWhileSessionActive(t *testing.T) {
+	root := newSnapshotCmdSandbox(t)
+	if _, err := runSnapshotCmd(t, "create", "good"); err != nil {
+		t.Fatal(err)
+	}
+	handle, err := sandbox.AcquireSession(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = handle.Release() }()
+
+	_, err = runSnapshotCmd(t, "restore", "good", "--force")
+	if err == nil || !strings.Contains(err.Error(), "running session") {
+		t.Errorf("restore during a session: got %v, want a running-session refusal", err)
+	}
+}
+
+func TestSnapshotCmd_RefusesContainerSandboxes(t *testing.T) {
+	root := newSnapshotCmdSandbox(t)
+	if err := sandbox.SaveMetadata(&sandbox.Metadata{Name: "proj", Isolation: sandbox.IsolationDocker}, root); err != nil {
+		t.Fatal(err)
+	}
+	_, err := runSnapshotCmd(t, "create")
+	if err == nil || !strings.Contains(err.Error(), "bwrap sandboxes only") {
+		t.Errorf("create on a docker sandbox: got %v", err)
+	}
+}
+
+func TestSnapshotCmd_UnknownSandbox(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	_, err := runSnapshotCmd(t, "list")
+	if err == nil || !strings.Contains(err.Error(), "no sandbox home") {
+		t.Errorf("list on a missing sandbox: got %v", err)
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"devsandbox/internal/tmuxproxy"
+)
+
+// newTmuxCmd creates the in-sandbox client for the tmux proxy. The host tmux
+// socket is never mounted into the sandbox, so a real tmux client has nothing
+// to talk to; this command sends its argv to the proxy, which runs the
+// command against the host server if the configured capabilities allow it.
+func newTmuxCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "tmux <command> [args...]",
+		Short: "Run a tmux command on the host through the sandbox's tmux proxy",
+		Long: `Run a tmux command on the host through the sandbox's tmux proxy.
+
+Only available inside a sandbox started from a tmux session with the tmux
+proxy enabled ([tools.tmux] commands). The proxy allows opening a pane running
+one of the configured commands, and sending keys to, capturing, listing and
+closing the panes the sandbox opened. Every other command is denied.`,
+		Example: `  devsandbox tmux split-window -h -l 40% -- revdiff main
+  devsandbox tmux send-keys -t %12 q
+  devsandbox tmux list-panes
+  devsandbox tmux kill-pane -t %12`,
+		Args:                  cobra.MinimumNArgs(1),
+		DisableFlagsInUseLine: true,
+		// tmux's own flags (-h, -t, ...) must reach the proxy untouched.
+		DisableFlagParsing: true,
+		SilenceUsage:       true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if args[0] == "-h" || args[0] == "--help" {
+				return cmd.Help()
+			}
+			return runTmux(os.Stdout, os.Getenv("DEVSANDBOX_TMUX_SOCKET"), args)
+		},
+	}
+	return cmd
+}
+
+func runTmux(stdout io.Writer, socket string, args []string) error {
+	if socket == "" {
+		return errors.New("the tmux proxy is not available (run inside a sandbox started from tmux with [tools.tmux] commands configured)")
+	}
+	resp, err := tmuxproxy.Call(socket, args)
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(stdout, resp.Output); err != nil {
+		return err
+	}
+	if !resp.OK {
+		return fmt.Errorf("tmux: %s", resp.Error)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"devsandbox/internal/tmuxproxy"
+)
+
+func TestRunTmux_NoProxy(t *testing.T) {
+	if err := runTmux(&bytes.Buffer{}, "", []string{"list-panes"}); err == nil {
+		t.Error("expected an error without DEVSANDBOX_TMUX_SOCKET")
+	}
+}
+
+func TestRunTmux(t *testing.T) {
+	dir, err := os.MkdirTemp("/tmp", "ds")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	sock := filepath.Join(dir, "tmux.sock")
+	l, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = l.Close() }()
+
+	// Answers list-panes and denies everything else, like the proxy would.
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			req, err := tmuxproxy.ReadRequest(bufio.NewReader(conn))
+			resp := tmuxproxy.Response{Error: "tmux-proxy: denied"}
+			if err == nil && slices.Equal(req.Argv, []string{"list-panes"}) {
+				resp = tmuxproxy.Response{OK: true, Output: "%3\n"}
+			}
+			_ = tmuxproxy.WriteMessage(conn, resp)
+			_ = conn.Close()
+		}
+	}()
+
+	var out bytes.Buffer
+	if err := runTmux(&out, sock, []string{"list-panes"}); err != nil {
+		t.Fatalf("runTmux: %v", err)
+	}
+	if out.String() != "%3\n" {
+		t.Errorf("output = %q", out.String())
+	}
+
+	err = runTmux(&out, sock, []string{"kill-server"})
+	if err == nil || !strings.Contains(err.Error(), "denied") {
+		t.Errorf("denial should surface as an error, got %v", err)
+	}
+}
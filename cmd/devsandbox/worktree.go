@@ -0,0 +1,603 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+
+	"devsandbox/internal/notice"
+	"devsandbox/internal/sandbox"
+	"devsandbox/internal/session"
+	"devsandbox/internal/worktree"
+)
+
+func newWorktreeCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "worktree",
+		Short: "Manage the git worktrees created by --worktree",
+		Long: `Manage the git worktrees that 'devsandbox --worktree' creates under
+~/.local/share/devsandbox/<sandbox>/worktrees/.
+
+Worktrees outlive the sessions that create them, so they accumulate: list
+shows each one's branch, the ref it was created from, whether it has
+uncommitted changes or commits not in that ref, the last session that used
+it and its disk usage; rm and prune remove them; shell opens a sandbox in
+one again.
+
+Commands act on the repository containing the current directory. Removing a
+worktree keeps its branch, and a worktree a running session is using is
+never removed.`,
+	}
+
+	cmd.AddCommand(newWorktreeListCmd())
+	cmd.AddCommand(newWorktreeRmCmd())
+	cmd.AddCommand(newWorktreePruneCmd())
+	cmd.AddCommand(newWorktreeShellCmd())
+	return cmd
+}
+
+// worktreeEntry is a managed worktree with its status, as listed and as
+// encoded by --json.
+type worktreeEntry struct {
+	Branch   string `json:"branch"`
+	Path     string `json:"path"`
+	Head     string `json:"head"`
+	Base     string `json:"base,omitempty"`
+	Missing  bool   `json:"missing,omitempty"`
+	Dirty    bool   `json:"dirty"`
+	Compared bool   `json:"compared"`
+	Ahead    int    `json:"ahead"`
+	Behind   int    `json:"behind"`
+	Merged   bool   `json:"merged"`
+	// Unverified marks a worktree whose .git the sandbox replaced; its
+	// changes were not read and it is treated as dirty.
+	Unverified bool `json:"unverified,omitempty"`
+
+	LastCommit    time.Time  `json:"last_commit"`
+	LastSession   string     `json:"last_session,omitempty"`
+	LastSessionAt *time.Time `json:"last_session_at,omitempty"`
+	// InUseBy names the running session using the worktree.
+	InUseBy   string `json:"in_use_by,omitempty"`
+	SizeBytes int64  `json:"size_bytes"`
+}
+
+// lastUsed is the later of the last commit and the last session start: a
+// worktree that was worked in but never committed to is not idle.
+func (e *worktreeEntry) lastUsed() time.Time {
+	if e.LastSessionAt != nil && e.LastSessionAt.After(e.LastCommit) {
+		return *e.LastSessionAt
+	}
+	return e.LastCommit
+}
+
+// label is how the entry is named in messages: its branch, or its directory
+// when HEAD is detached.
+func (e *worktreeEntry) label() string {
+	if e.Branch != "" {
+		return e.Branch
+	}
+	return filepath.Base(e.Path)
+}
+
+// worktreeInventory is the repository of the current directory and its
+// managed worktrees.
+type worktreeInventory struct {
+	repoRoot    string
+	sandboxRoot string
+	entries     []*worktreeEntry
+}
+
+func loadWorktreeInventory(ctx context.Context, withSize bool) (*worktreeInventory, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	repoRoot, err := worktree.RepoRoot(cwd)
+	if err != nil {
+		return nil, err
+	}
+	// The same derivation as --worktree, which keys the sandbox on the main
+	// repo root so every worktree of a repo shares one.
+	inv := &worktreeInventory{
+		repoRoot:    repoRoot,
+		sandboxRoot: filepath.Join(sandbox.SandboxBasePath(homeDir), sandbox.GenerateSandboxName(repoRoot)),
+	}
+
+	mgr := worktree.NewManager()
+	list, err := mgr.List(ctx, repoRoot, inv.sandboxRoot)
+	if err != nil {
+		return nil, err
+	}
+	for _, wt := range list {
+		st, err := mgr.Status(ctx, repoRoot, wt)
+		if err != nil {
+			return nil, err
+		}
+		e := &worktreeEntry{
+			Branch:     wt.Branch,
+			Path:       wt.Path,
+			Head:       wt.Head,
+			Base:       wt.Base,
+			Missing:    wt.Prunable,
+			Dirty:      st.Dirty,
+			Compared:   st.Compared,
+			Ahead:      st.Ahead,
+			Behind:     st.Behind,
+			Merged:     st.Merged,
+			Unverified: st.Unverified,
+			LastCommit: st.LastCommit,
+		}
+		if st.Unverified {
+			notice.Warn("worktree %s: .git no longer points at the repository's record of it; treating it as dirty", wt.Path)
+		}
+		if withSize && !e.Missing {
+			e.SizeBytes, _ = sandbox.GetSandboxSize(wt.Path)
+		}
+		inv.entries = append(inv.entries, e)
+	}
+	if len(inv.entries) == 0 {
+		return inv, nil
+	}
+
+	store, err := session.DefaultStore()
+	if err != nil {
+		return nil, err
+	}
+	all, err := store.List()
+	if err != nil {
+		return nil, err
+	}
+	live, err := store.ListLive()
+	if err != nil {
+		return nil, err
+	}
+	attachWorktreeSessions(inv.entries, all, live, repoRoot, sandbox.IsSessionActive(inv.sandboxRoot))
+	return inv, nil
+}
+
+// attachWorktreeSessions records on each entry the last session that used
+// it and, when that session is still running, InUseBy.
+//
+// Only sessions with a network namespace write a session record, so a
+// running sandbox may have none. When the sandbox is active but no live
+// record belongs to the repository, the running session could be in any
+// worktree, and all of them are treated as in use.
+func attachWorktreeSessions(entries []*worktreeEntry, all, live []*session.Session, repoRoot string, sandboxActive bool) {
+	isLive := make(map[string]bool, len(live))
+	for _, s := range live {
+		isLive[s.Name] = true
+	}
+	byPath := make(map[string]*worktreeEntry, len(entries))
+	for _, e := range entries {
+		byPath[e.Path] = e
+	}
+
+	attributed := false
+	for _, s := range all {
+		var e *worktreeEntry
+		if s.Worktree != nil {
+			e = byPath[filepath.Clean(s.Worktree.Path)]
+		}
+		if isLive[s.Name] && (e != nil || filepath.Clean(s.WorkDir) == repoRoot || (s.Worktree != nil && s.Worktree.RepoRoot == repoRoot)) {
+			attributed = true
+		}
+		if e == nil {
+			continue
+		}
+		if e.LastSessionAt == nil || s.StartedAt.After(*e.LastSessionAt) {
+			startedAt := s.StartedAt
+			e.LastSession, e.LastSessionAt = s.Name, &startedAt
+		}
+		if isLive[s.Name] {
+			e.InUseBy = s.Name
+		}
+	}
+
+	if sandboxActive && !attributed {
+		for _, e := range entries {
+			if e.InUseBy == "" {
+				e.InUseBy = "an unregistered session"
+			}
+		}
+	}
+}
+
+func newWorktreeListCmd() *cobra.Command {
+	var (
+		jsonOutput bool
+		noSize     bool
+	)
+	cmd := &cobra.Command{
+		Use:     "list",
+		Aliases: []string{"ls"},
+		Short:   "List the repository's managed worktrees",
+		Long: `List the worktrees --worktree created for the repository of the current
+directory.
+
+BASE is the ref the branch was created from; HEAD is recorded as the branch
+the main checkout had at the time. STATUS says whether the worktree has
+uncommitted changes and how its branch compares to the base: ahead counts
+commits not in the base, behind counts base commits not on the branch, and
+merged means the base contains the whole branch. A branch that existed
+before --worktree checked it out has no base and is not compared.`,
+		Example: `  devsandbox worktree list
+  devsandbox worktree list --json`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			inv, err := loadWorktreeInventory(cmd.Context(), !noSize)
+			if err != nil {
+				return err
+			}
+			if jsonOutput {
+				encoder := json.NewEncoder(cmd.OutOrStdout())
+				encoder.SetIndent("", "  ")
+				if inv.entries == nil {
+					inv.entries = []*worktreeEntry{}
+				}
+				return encoder.Encode(inv.entries)
+			}
+			if len(inv.entries) == 0 {
+				_, err := fmt.Fprintln(cmd.OutOrStdout(), "No worktrees.")
+				return err
+			}
+			return printWorktreeTable(cmd.OutOrStdout(), inv.entries, !noSize)
+		},
+	}
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Output in JSON format")
+	cmd.Flags().BoolVar(&noSize, "no-size", false, "Skip disk usage calculation (faster)")
+	return cmd
+}
+
+func printWorktreeTable(w io.Writer, entries []*worktreeEntry, showSize bool) error {
+	table := tablewriter.NewWriter(w)
+	if showSize {
+		table.Header("BRANCH", "BASE", "STATUS", "LAST SESSION", "SIZE")
+	} else {
+		table.Header("BRANCH", "BASE", "STATUS", "LAST SESSION")
+	}
+	for _, e := range entries {
+		base := e.Base
+		if base == "" {
+			base = "-"
+		}
+		row := []string{e.label(), base, formatWorktreeStatus(e), formatWorktreeSession(e)}
+		if showSize {
+			size := "-"
+			if !e.Missing {
+				size = sandbox.FormatSize(e.SizeBytes)
+			}
+			row = append(row, size)
+		}
+		_ = table.Append(row)
+	}
+	return table.Render()
+}
+
+func formatWorktreeStatus(e *worktreeEntry) string {
+	if e.Missing {
+		return "missing"
+	}
+	parts := []string{"clean"}
+	switch {
+	case e.Unverified:
+		parts[0] = "unverified .git"
+	case e.Dirty:
+		parts[0] = "dirty"
+	}
+	switch {
+	case !e.Compared:
+	case e.Merged:
+		parts = append(parts, "merged")
+	default:
+		parts = append(parts, fmt.Sprintf("%d ahead", e.Ahead))
+		if e.Behind > 0 {
+			parts = append(parts, fmt.Sprintf("%d behind", e.Behind))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+func formatWorktreeSession(e *worktreeEntry) string {
+	switch {
+	case e.InUseBy != "" && e.InUseBy != e.LastSession:
+		return "in use by " + e.InUseBy
+	case e.InUseBy != "":
+		return e.LastSession + " (running)"
+	case e.LastSessionAt != nil:
+		return e.LastSession + ", " + formatTimeAgo(*e.LastSessionAt)
+	}
+	return "-"
+}
+
+// findWorktreeEntry resolves a command-line reference to a worktree: its
+// branch, its path, or the name of its directory.
+func findWorktreeEntry(entries []*worktreeEntry, ref string) (*worktreeEntry, error) {
+	for _, e := range entries {
+		if e.Branch == ref {
+			return e, nil
+		}
+	}
+	if abs, err := filepath.Abs(ref); err == nil {
+		if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+			abs = resolved
+		}
+		for _, e := range entries {
+			if e.Path == abs {
+				return e, nil
+			}
+		}
+	}
+	for _, e := range entries {
+		if filepath.Base(e.Path) == ref {
+			return e, nil
+		}
+	}
+	return nil, fmt.Errorf("no managed worktree %q (see 'devsandbox worktree list')", ref)
+}
+
+func newWorktreeRmCmd() *cobra.Command {
+	var force bool
+	cmd := &cobra.Command{
+		Use:     "rm <branch|path>...",
+		Aliases: []string{"remove"},
+		Short:   "Remove worktrees",
+		Long: `Remove worktrees by branch, path or directory name. The branches are kept;
+delete them with 'git branch -d' once they are no longer wanted.
+
+Uncommitted changes in a worktree are lost with it, so removing a dirty one
+asks first unless --force is given. A worktree a running session is using is
+refused.`,
+		Example: `  devsandbox worktree rm feat/login
+  devsandbox worktree rm devsandbox/myproject-1a2b3c4d --force`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			inv, err := loadWorktreeInventory(cmd.Context(), false)
+			if err != nil {
+				return err
+			}
+			var targets []*worktreeEntry
+			dirty := false
+			for _, ref := range args {
+				e, err := findWorktreeEntry(inv.entries, ref)
+				if err != nil {
+					return err
+				}
+				if e.InUseBy != "" {
+					return fmt.Errorf("worktree %s is in use by %s; exit it first", e.label(), e.InUseBy)
+				}
+				dirty = dirty || e.Dirty
+				targets = append(targets, e)
+			}
+
+			if dirty && !force {
+				for _, e := range targets {
+					if e.Dirty {
+						fmt.Printf("  %s has uncommitted changes (%s)\n", e.label(), e.Path)
+					}
+				}
+				fmt.Print("Remove the worktrees and discard these changes? [y/N] ")
+				reader := bufio.NewReader(os.Stdin)
+				response, err := reader.ReadString('\n')
+				if err != nil {
+					return err
+				}
+				response = strings.TrimSpace(strings.ToLower(response))
+				if response != "y" && response != "yes" {
+					fmt.Println("Aborted.")
+					return nil
+				}
+			}
+
+			mgr := worktree.NewManager()
+			for _, e := range targets {
+				if err := mgr.Remove(cmd.Context(), inv.repoRoot, e.Path); err != nil {
+					return err
+				}
+				if _, err := fmt.Fprintf(cmd.OutOrStdout(), "Removed worktree %s\n", e.label()); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVarP(&force, "force", "f", false, "Skip confirmation prompt")
+	return cmd
+}
+
+// worktreePruneOptions selects the worktrees prune removes.
+type worktreePruneOptions struct {
+	Merged    bool          // only those whose branch is merged into its base
+	OlderThan time.Duration // only those unused for this long
+	Dirty     bool          // include worktrees with uncommitted changes
+	Now       time.Time
+}
+
+// worktreeSkip is a worktree the filters selected that prune leaves alone.
+type worktreeSkip struct {
+	Entry  *worktreeEntry
+	Reason string
+}
+
+// selectWorktreesForPrune applies opts. A worktree whose directory is gone is
+// always selected; without --merged or --older-than nothing else is. The two
+// filters combine: a worktree must pass both.
+func selectWorktreesForPrune(entries []*worktreeEntry, opts worktreePruneOptions) ([]*worktreeEntry, []worktreeSkip) {
+	var selected []*worktreeEntry
+	var skipped []worktreeSkip
+	for _, e := range entries {
+		match := e.Missing
+		if !match && (opts.Merged || opts.OlderThan > 0) {
+			match = (!opts.Merged || e.Merged) &&
+				(opts.OlderThan <= 0 || opts.Now.Sub(e.lastUsed()) >= opts.OlderThan)
+		}
+		switch {
+		case !match:
+		case e.InUseBy != "":
+			skipped = append(skipped, worktreeSkip{e, "in use by " + e.InUseBy})
+		case e.Dirty && !opts.Dirty:
+			skipped = append(skipped, worktreeSkip{e, "uncommitted changes (--dirty removes it anyway)"})
+		default:
+			selected = append(selected, e)
+		}
+	}
+	return selected, skipped
+}
+
+func newWorktreePruneCmd() *cobra.Command {
+	var (
+		merged    bool
+		olderThan string
+		dirty     bool
+		dryRun    bool
+		force     bool
+	)
+	cmd := &cobra.Command{
+		Use:   "prune",
+		Short: "Remove merged, idle or missing worktrees",
+		Long: `Remove worktrees that are no longer needed.
+
+Without flags only worktrees whose directory no longer exists are pruned.
+--merged selects worktrees whose branch is contained in the ref it was
+created from (see 'devsandbox worktree list'); a squash-merged branch is not
+detected. --older-than selects worktrees with no commit and no session for the
+given duration. Both together select worktrees that pass both.
+
+Worktrees with uncommitted changes are skipped unless --dirty is given, and a
+worktree a running session is using always is. Branches are kept.`,
+		Example: `  devsandbox worktree prune                       # Missing directories only
+  devsandbox worktree prune --merged              # Worktrees of merged branches
+  devsandbox worktree prune --older-than 14d      # Unused for two weeks
+  devsandbox worktree prune --merged --dry-run    # Show what would be removed`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts := worktreePruneOptions{Merged: merged, Dirty: dirty, Now: time.Now()}
+			if olderThan != "" {
+				d, err := parseDuration(olderThan)
+				if err != nil {
+					return fmt.Errorf("invalid duration %q: %w", olderThan, err)
+				}
+				opts.OlderThan = d
+			}
+
+			inv, err := loadWorktreeInventory(cmd.Context(), false)
+			if err != nil {
+				return err
+			}
+			toPrune, skipped := selectWorktreesForPrune(inv.entries, opts)
+			for _, s := range skipped {
+				fmt.Printf("Skipping %s: %s\n", s.Entry.label(), s.Reason)
+			}
+			if len(toPrune) == 0 {
+				fmt.Println("No worktrees to prune.")
+				return nil
+			}
+
+			fmt.Printf("Worktrees to remove (%d):\n\n", len(toPrune))
+			for _, e := range toPrune {
+				fmt.Printf("  %s  [%s]\n", e.label(), formatWorktreeStatus(e))
+				fmt.Printf("    Path: %s\n", e.Path)
+				if !e.lastUsed().IsZero() {
+					fmt.Printf("    Last used: %s\n", e.lastUsed().Format("2006-01-02 15:04"))
+				}
+				fmt.Println()
+			}
+
+			if dryRun {
+				fmt.Println("Dry run - no worktrees were removed.")
+				return nil
+			}
+			if !force {
+				fmt.Print("Remove these worktrees? [y/N] ")
+				reader := bufio.NewReader(os.Stdin)
+				response, err := reader.ReadString('\n')
+				if err != nil {
+					return err
+				}
+				response = strings.TrimSpace(strings.ToLower(response))
+				if response != "y" && response != "yes" {
+					fmt.Println("Aborted.")
+					return nil
+				}
+			}
+
+			mgr := worktree.NewManager()
+			var removed, failed int
+			for _, e := range toPrune {
+				if err := mgr.Remove(cmd.Context(), inv.repoRoot, e.Path); err != nil {
+					fmt.Fprintf(os.Stderr, "Failed to remove %s: %v\n", e.label(), err)
+					failed++
+					continue
+				}
+				removed++
+			}
+			fmt.Printf("Removed %d worktree(s)", removed)
+			if failed > 0 {
+				fmt.Printf(", %d failed", failed)
+			}
+			fmt.Println()
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&merged, "merged", false, "Prune worktrees whose branch is merged into its base")
+	cmd.Flags().StringVar(&olderThan, "older-than", "", "Prune worktrees unused for duration (e.g., 14d, 2w)")
+	cmd.Flags().BoolVar(&dirty, "dirty", false, "Also prune worktrees with uncommitted changes")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show what would be removed without removing")
+	cmd.Flags().BoolVarP(&force, "force", "f", false, "Skip confirmation prompt")
+	return cmd
+}
+
+func newWorktreeShellCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "shell <branch|path> [command...]",
+		Short: "Open a sandbox in an existing worktree",
+		Long: `Open a sandbox in an existing worktree, as 'devsandbox --worktree=<branch>'
+run from the repository would, and with the same sandbox flags. With no
+command an interactive shell starts.`,
+		Example: `  devsandbox worktree shell feat/login
+  devsandbox worktree shell feat/login claude
+  devsandbox worktree shell --proxy devsandbox/myproject-1a2b3c4d`,
+		Args:                  cobra.MinimumNArgs(1),
+		DisableFlagsInUseLine: true,
+		SilenceUsage:          true,
+		SilenceErrors:         true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			inv, err := loadWorktreeInventory(cmd.Context(), false)
+			if err != nil {
+				return err
+			}
+			e, err := findWorktreeEntry(inv.entries, args[0])
+			if err != nil {
+				return err
+			}
+			switch {
+			case e.Missing:
+				return fmt.Errorf("worktree %s no longer exists (see 'devsandbox worktree prune')", e.label())
+			case e.Branch == "":
+				return fmt.Errorf("worktree %s has a detached HEAD; check out a branch in it first", e.label())
+			}
+			if err := os.Chdir(inv.repoRoot); err != nil {
+				return fmt.Errorf("enter repository %q: %w", inv.repoRoot, err)
+			}
+			if err := cmd.Flags().Set("worktree", e.Branch); err != nil {
+				return err
+			}
+			return runSandbox(cmd, args[1:])
+		},
+	}
+	cmd.Flags().SetInterspersed(false)
+	addSandboxFlags(cmd)
+	return cmd
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"devsandbox/internal/sandbox"
+	"devsandbox/internal/session"
+	"devsandbox/internal/worktree"
+)
+
+func TestSelectWorktreesForPrune(t *testing.T) {
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	recent := now.Add(-time.Hour)
+	old := now.Add(-30 * 24 * time.Hour)
+
+	missing := &worktreeEntry{Branch: "missing", Missing: true}
+	merged := &worktreeEntry{Branch: "merged", Compared: true, Merged: true, LastCommit: recent}
+	mergedOld := &worktreeEntry{Branch: "merged-old", Compared: true, Merged: true, LastCommit: old}
+	// Committed to long ago, but a session ran in it an hour ago.
+	sessionRecent := &worktreeEntry{Branch: "session-recent", Compared: true, Ahead: 2, LastCommit: old, LastSessionAt: &recent}
+	ahead := &worktreeEntry{Branch: "ahead", Compared: true, Ahead: 1, LastCommit: old}
+	dirty := &worktreeEntry{Branch: "dirty", Dirty: true, Compared: true, Merged: true, LastCommit: old}
+	busy := &worktreeEntry{Branch: "busy", Compared: true, Merged: true, LastCommit: old, InUseBy: "proj"}
+	entries := []*worktreeEntry{missing, merged, mergedOld, sessionRecent, ahead, dirty, busy}
+
+	labels := func(es []*worktreeEntry) []string {
+		var out []string
+		for _, e := range es {
+			out = append(out, e.Branch)
+		}
+		return out
+	}
+	tests := []struct {
+		name    string
+		opts    worktreePruneOptions
+		want    []string
+		skipped []string
+	}{
+		{"no filters", worktreePruneOptions{}, []string{"missing"}, nil},
+		{"merged", worktreePruneOptions{Merged: true}, []string{"missing", "merged", "merged-old"}, []string{"dirty", "busy"}},
+		{"older than", worktreePruneOptions{OlderThan: 7 * 24 * time.Hour}, []string{"missing", "merged-old", "ahead"}, []string{"dirty", "busy"}},
+		{"merged and older than", worktreePruneOptions{Merged: true, OlderThan: 7 * 24 * time.Hour}, []string{"missing", "merged-old"}, []string{"dirty", "busy"}},
+		{"dirty included", worktreePruneOptions{Merged: true, Dirty: true}, []string{"missing", "merged", "merged-old", "dirty"}, []string{"busy"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.Now = now
+			got, skipped := selectWorktreesForPrune(entries, tt.opts)
+			if g := labels(got); !equalStrings(g, tt.want) {
+				t.Errorf("selected = %v, want %v", g, tt.want)
+			}
+			var s []string
+			for _, sk := range skipped {
+				s = append(s, sk.Entry.Branch)
+			}
+			if !equalStrings(s, tt.skipped) {
+				t.Errorf("skipped = %v, want %v", s, tt.skipped)
+			}
+		})
+	}
+}
+
+func TestAttachWorktreeSessions(t *testing.T) {
+	t1 := time.Date(2026, 5, 1, 9, 0, 0, 0, time.UTC)
+	t2 := t1.Add(time.Hour)
+	newEntries := func() (a, b *worktreeEntry) {
+		return &worktreeEntry{Branch: "a", Path: "/sb/worktrees/a"}, &worktreeEntry{Branch: "b", Path: "/sb/worktrees/b"}
+	}
+	wtSession := func(name, path string, at time.Time) *session.Session {
+		return &session.Session{Name: name, WorkDir: path, StartedAt: at,
+			Worktree: &session.WorktreeInfo{Path: path, RepoRoot: "/repo"}}
+	}
+	all := []*session.Session{
+		wtSession("old", "/sb/worktrees/a", t1),
+		wtSession("new", "/sb/worktrees/a", t2),
+		wtSession("live", "/sb/worktrees/b", t1),
+	}
+	live := []*session.Session{all[2]}
+
+	a, b := newEntries()
+	attachWorktreeSessions([]*worktreeEntry{a, b}, all, live, "/repo", true)
+	if a.LastSession != "new" || !a.LastSessionAt.Equal(t2) || a.InUseBy != "" {
+		t.Errorf("a = %+v, want last session new, not in use", a)
+	}
+	if b.LastSession != "live" || b.InUseBy != "live" {
+		t.Errorf("b = %+v, want in use by live", b)
+	}
+
+	// An active sandbox with no live record for the repository: the running
+	// session cannot be placed, so every worktree is in use.
+	a, b = newEntries()
+	attachWorktreeSessions([]*worktreeEntry{a, b}, all[:2], nil, "/repo", true)
+	if a.InUseBy == "" || b.InUseBy == "" {
+		t.Errorf("unattributed session: a.InUseBy = %q, b.InUseBy = %q", a.InUseBy, b.InUseBy)
+	}
+
+	// A live plain session of the repository accounts for the lock.
+	plain := &session.Session{Name: "plain", WorkDir: "/repo", StartedAt: t2}
+	a, b = newEntries()
+	attachWorktreeSessions([]*worktreeEntry{a, b}, append(all[:2:2], plain), []*session.Session{plain}, "/repo", true)
+	if a.InUseBy != "" || b.InUseBy != "" {
+		t.Errorf("attributed session: a.InUseBy = %q, b.InUseBy = %q", a.InUseBy, b.InUseBy)
+	}
+}
+
+func TestFormatWorktreeStatus(t *testing.T) {
+	tests := []struct {
+		e    worktreeEntry
+		want string
+	}{
+		{worktreeEntry{Missing: true, Dirty: true}, "missing"},
+		{worktreeEntry{}, "clean"},
+		{worktreeEntry{Dirty: true, Compared: true, Merged: true}, "dirty, merged"},
+		{worktreeEntry{Compared: true, Ahead: 3}, "clean, 3 ahead"},
+		{worktreeEntry{Compared: true, Ahead: 1, Behind: 4}, "clean, 1 ahead, 4 behind"},
+	}
+	for _, tt := range tests {
+		if got := formatWorktreeStatus(&tt.e); got != tt.want {
+			t.Errorf("formatWorktreeStatus(%+v) = %q, want %q", tt.e, got, tt.want)
+		}
+	}
+}
+
+func TestWorktreeCmd_ListAndRm(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_STATE_HOME", filepath.Join(home, ".local", "state"))
+
+	repo := t.TempDir()
+	for _, argv := range [][]string{
+		{"init", "-q", "-b", "main"},
+		{"config", "user.email", "a@b"},
+		{"config", "user.name", "a"},
+		{"config", "commit.gpgsign", "false"},
+		{"commit", "-q", "--allow-empty", "-m", "init"},
+	} {
+		cmd := exec.Command("git", argv...)
+		cmd.Dir = repo
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", argv, err, out)
+		}
+	}
+	repoRoot, err := worktree.RepoRoot(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sandboxRoot := filepath.Join(sandbox.SandboxBasePath(home), sandbox.GenerateSandboxName(repoRoot))
+	if err := os.MkdirAll(sandboxRoot, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	h, err := worktree.NewManager().Ensure(t.Context(), worktree.EnsureRequest{RepoRoot: repoRoot, SandboxRoot: sandboxRoot, Branch: "feat/x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(h.Path, "scratch"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(repo)
+
+	run := func(args ...string) string {
+		t.Helper()
+		cmd := newWorktreeCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+		cmd.SetErr(&buf)
+		cmd.SetArgs(args)
+		if err := cmd.Execute(); err != nil {
+			t.Fatalf("worktree %v: %v\n%s", args, err, buf.String())
+		}
+		return buf.String()
+	}
+
+	var entries []worktreeEntry
+	if err := json.Unmarshal([]byte(run("list", "--json")), &entries); err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("list returned %d entries, want 1: %+v", len(entries), entries)
+	}
+	e := entries[0]
+	if e.Branch != "feat/x" || e.Base != "main" || !e.Dirty || !e.Compared || !e.Merged || e.SizeBytes == 0 {
+		t.Errorf("entry = %+v", e)
+	}
+
+	run("rm", "feat/x", "--force")
+	if _, err := os.Stat(h.Path); !os.IsNotExist(err) {
+		t.Errorf("worktree still on disk after rm: %v", err)
+	}
+	if out := run("list"); out != "No worktrees.\n" {
+		t.Errorf("list after rm = %q", out)
+	}
+}
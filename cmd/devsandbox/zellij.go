@@ -0,0 +1,63 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"devsandbox/internal/zellijproxy"
+)
+
+// newZellijCmd creates the in-sandbox client for the zellij proxy. The host
+// zellij socket directory is never mounted into the sandbox, so a real zellij
+// client has nothing to talk to; this command sends its argv to the proxy,
+// which runs the command against the host session if the configured
+// capabilities allow it.
+func newZellijCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "zellij <command> [args...]",
+		Short: "Run a zellij command on the host through the sandbox's zellij proxy",
+		Long: `Run a zellij command on the host through the sandbox's zellij proxy.
+
+Only available inside a sandbox started from a zellij session with the zellij
+proxy enabled ([tools.zellij] commands). The proxy allows opening a floating
+pane running one of the configured commands, and writing to and closing the
+panes the sandbox opened. Every other command is denied.`,
+		Example: `  pane=$(devsandbox zellij run --name diff -- revdiff main)
+  devsandbox zellij action write-chars --pane-id "$pane" q
+  devsandbox zellij action close-pane --pane-id "$pane"`,
+		Args:                  cobra.MinimumNArgs(1),
+		DisableFlagsInUseLine: true,
+		// zellij's own options (--name, --pane-id, ...) must reach the proxy
+		// untouched.
+		DisableFlagParsing: true,
+		SilenceUsage:       true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if args[0] == "-h" || args[0] == "--help" {
+				return cmd.Help()
+			}
+			return runZellij(os.Stdout, os.Getenv("DEVSANDBOX_ZELLIJ_SOCKET"), args)
+		},
+	}
+	return cmd
+}
+
+func runZellij(stdout io.Writer, socket string, args []string) error {
+	if socket == "" {
+		return errors.New("the zellij proxy is not available (run inside a sandbox started from zellij with [tools.zellij] commands configured)")
+	}
+	resp, err := zellijproxy.Call(socket, args)
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(stdout, resp.Output); err != nil {
+		return err
+	}
+	if !resp.OK {
+		return fmt.Errorf("zellij: %s", resp.Error)
+	}
+	return nil
+}
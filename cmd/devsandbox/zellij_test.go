@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"devsandbox/internal/zellijproxy"
+)
+
+func TestRunZellij_NoProxy(t *testing.T) {
+	if err := runZellij(&bytes.Buffer{}, "", []string{"run", "revdiff"}); err == nil {
+		t.Error("expected an error without DEVSANDBOX_ZELLIJ_SOCKET")
+	}
+}
+
+func TestRunZellij(t *testing.T) {
+	dir, err := os.MkdirTemp("/tmp", "ds")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	sock := filepath.Join(dir, "zellij.sock")
+	l, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = l.Close() }()
+
+	// Answers run and denies everything else, like the proxy would.
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			req, err := zellijproxy.ReadRequest(bufio.NewReader(conn))
+			resp := zellijproxy.Response{Error: "zellij-proxy: denied"}
+			if err == nil && slices.Equal(req.Argv, []string{"run", "--", "revdiff"}) {
+				resp = zellijproxy.Response{OK: true, Output: "terminal_3\n"}
+			}
+			_ = zellijproxy.WriteMessage(conn, resp)
+			_ = conn.Close()
+		}
+	}()
+
+	var out bytes.Buffer
+	if err := runZellij(&out, sock, []string{"run", "--", "revdiff"}); err != nil {
+		t.Fatalf("runZellij: %v", err)
+	}
+	if out.String() != "terminal_3\n" {
+		t.Errorf("output = %q", out.String())
+	}
+
+	err = runZellij(&out, sock, []string{"kill-session", "main"})
+	if err == nil || !strings.Contains(err.Error(), "denied") {
+		t.Errorf("denial should surface as an error, got %v", err)
+	}
+}
@@ -0,0 +1,141 @@
+//go:build linux
+
+package cgroups
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// sysBlockRoot, procMountInfo and devRoot are where a path's block device is
+// looked up. They are variables so tests can point the resolution at a fake
+// sysfs, mount table and /dev.
+var (
+	sysBlockRoot  = "/sys/dev/block"
+	procMountInfo = "/proc/self/mountinfo"
+	devRoot       = "/dev"
+)
+
+// BlockDevices returns the device nodes of the whole disks backing paths, in
+// order and without duplicates.
+//
+// A cgroup bandwidth cap names a disk, not a partition: the kernel throttles at
+// the request queue, which a disk's partitions share, and refuses a partition's
+// device number outright. A path that does not exist yet resolves through its
+// nearest existing parent, so a sandbox directory created later in the launch
+// still lands on the disk it will be written to.
+func BlockDevices(paths []string) ([]string, error) {
+	if len(paths) == 0 {
+		return nil, errors.New("io bandwidth limits need a path to resolve a block device from, and none was given")
+	}
+	var out []string
+	for _, p := range paths {
+		dev, err := blockDevice(p)
+		if err != nil {
+			return nil, err
+		}
+		if !slices.Contains(out, dev) {
+			out = append(out, dev)
+		}
+	}
+	return out, nil
+}
+
+func blockDevice(path string) (string, error) {
+	existing, err := nearestExisting(path)
+	if err != nil {
+		return "", err
+	}
+	var st unix.Stat_t
+	if err := unix.Stat(existing, &st); err != nil {
+		return "", fmt.Errorf("stat %s: %w", existing, err)
+	}
+	major, minor := unix.Major(st.Dev), unix.Minor(st.Dev)
+
+	// btrfs, and anything else that hands out anonymous device numbers, has no
+	// sysfs entry for st_dev. The mount table still names the device the
+	// filesystem lives on.
+	if _, err := os.Stat(sysBlockPath(major, minor)); err != nil {
+		major, minor, err = mountSourceDevice(major, minor, path)
+		if err != nil {
+			return "", err
+		}
+	}
+	return wholeDisk(major, minor)
+}
+
+// nearestExisting returns path, or its closest ancestor that exists.
+func nearestExisting(path string) (string, error) {
+	p, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	for {
+		if _, err := os.Stat(p); err == nil {
+			return p, nil
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return "", fmt.Errorf("stat %s: %w", p, err)
+		}
+		parent := filepath.Dir(p)
+		if parent == p {
+			return "", fmt.Errorf("no part of %s exists", path)
+		}
+		p = parent
+	}
+}
+
+func sysBlockPath(major, minor uint32) string {
+	return filepath.Join(sysBlockRoot, fmt.Sprintf("%d:%d", major, minor))
+}
+
+// mountSourceDevice finds the block device a filesystem with an anonymous
+// device number was mounted from, by its source in the mount table.
+func mountSourceDevice(major, minor uint32, path string) (uint32, uint32, error) {
+	data, err := os.ReadFile(procMountInfo)
+	if err != nil {
+		return 0, 0, fmt.Errorf("read %s: %w", procMountInfo, err)
+	}
+	want := fmt.Sprintf("%d:%d", major, minor)
+	for line := range strings.SplitSeq(string(data), "\n") {
+		fields := strings.Fields(line)
+		// id parent major:minor root mountpoint options [optional...] - fstype source superoptions
+		if len(fields) < 3 || fields[2] != want {
+			continue
+		}
+		sep := slices.Index(fields, "-")
+		if sep < 0 || sep+2 >= len(fields) {
+			continue
+		}
+		fsType, source := fields[sep+1], fields[sep+2]
+		var st unix.Stat_t
+		if !filepath.IsAbs(source) || unix.Stat(source, &st) != nil || st.Mode&unix.S_IFMT != unix.S_IFBLK {
+			return 0, 0, fmt.Errorf("%s is on a %s filesystem that is not backed by a block device, so an io bandwidth limit has nothing to apply to", path, fsType)
+		}
+		return unix.Major(st.Rdev), unix.Minor(st.Rdev), nil
+	}
+	return 0, 0, fmt.Errorf("%s is on device %s, which is neither a block device nor in %s", path, want, procMountInfo)
+}
+
+// wholeDisk returns the /dev node of the disk a block device belongs to: the
+// device itself, or the disk holding it when it is a partition.
+func wholeDisk(major, minor uint32) (string, error) {
+	dir, err := filepath.EvalSymlinks(sysBlockPath(major, minor))
+	if err != nil {
+		return "", fmt.Errorf("resolve block device %d:%d: %w", major, minor, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "partition")); err == nil {
+		dir = filepath.Dir(dir)
+	}
+	node := filepath.Join(devRoot, filepath.Base(dir))
+	if _, err := os.Stat(node); err != nil {
+		return "", fmt.Errorf("block device %d:%d has no device node: %w", major, minor, err)
+	}
+	return node, nil
+}
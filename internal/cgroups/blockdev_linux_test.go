@@ -0,0 +1,108 @@
+//go:build linux
+
+package cgroups
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+// fakeBlockDevices points the device resolution at a fake sysfs and /dev in
+// which the filesystem holding dir is partition 1 of disk "vdz".
+func fakeBlockDevices(t *testing.T, dir string) {
+	t.Helper()
+	var st unix.Stat_t
+	if err := unix.Stat(dir, &st); err != nil {
+		t.Fatal(err)
+	}
+	root := t.TempDir()
+	part := filepath.Join(root, "devices", "vdz", "vdz1")
+	if err := os.MkdirAll(part, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(part, "partition"), []byte("1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	sys := filepath.Join(root, "dev", "block")
+	if err := os.MkdirAll(sys, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(sys, fmt.Sprintf("%d:%d", unix.Major(st.Dev), unix.Minor(st.Dev)))
+	if err := os.Symlink(part, link); err != nil {
+		t.Fatal(err)
+	}
+	dev := filepath.Join(root, "devnodes")
+	if err := os.MkdirAll(dev, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dev, "vdz"), nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	prevSys, prevDev := sysBlockRoot, devRoot
+	sysBlockRoot, devRoot = sys, dev
+	t.Cleanup(func() { sysBlockRoot, devRoot = prevSys, prevDev })
+}
+
+func TestBlockDevices_PartitionResolvesToDisk(t *testing.T) {
+	dir := t.TempDir()
+	fakeBlockDevices(t, dir)
+
+	got, err := BlockDevices([]string{dir, filepath.Join(dir, "not", "created", "yet")})
+	if err != nil {
+		t.Fatalf("BlockDevices() error = %v", err)
+	}
+	if want := []string{filepath.Join(devRoot, "vdz")}; !slices.Equal(got, want) {
+		t.Errorf("BlockDevices() = %v, want %v (one disk, deduplicated)", got, want)
+	}
+}
+
+func TestBlockDevices_NoBlockDevice(t *testing.T) {
+	dir := t.TempDir()
+	var st unix.Stat_t
+	if err := unix.Stat(dir, &st); err != nil {
+		t.Fatal(err)
+	}
+
+	prevSys, prevMounts := sysBlockRoot, procMountInfo
+	sysBlockRoot = t.TempDir()
+	procMountInfo = filepath.Join(t.TempDir(), "mountinfo")
+	t.Cleanup(func() { sysBlockRoot, procMountInfo = prevSys, prevMounts })
+
+	line := fmt.Sprintf("40 1 %d:%d / / rw - tmpfs tmpfs rw\n", unix.Major(st.Dev), unix.Minor(st.Dev))
+	if err := os.WriteFile(procMountInfo, []byte(line), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := BlockDevices([]string{dir})
+	if err == nil || !strings.Contains(err.Error(), "tmpfs filesystem that is not backed by a block device") {
+		t.Errorf("BlockDevices() error = %v, want it to name the tmpfs mount", err)
+	}
+}
+
+func TestBlockDevices_RequiresPaths(t *testing.T) {
+	if _, err := BlockDevices(nil); err == nil {
+		t.Error("BlockDevices(nil) = nil error, want one")
+	}
+}
+
+func TestLimits_PropertiesIOBandwidth(t *testing.T) {
+	dir := t.TempDir()
+	fakeBlockDevices(t, dir)
+	disk := filepath.Join(devRoot, "vdz")
+
+	got, err := Limits{IOReadBPS: "100m", IOWriteBPS: "50m", IOPaths: []string{dir}}.properties()
+	if err != nil {
+		t.Fatalf("properties() error = %v", err)
+	}
+	want := []string{"IOReadBandwidthMax=" + disk + " 100M", "IOWriteBandwidthMax=" + disk + " 50M"}
+	if !slices.Equal(got, want) {
+		t.Errorf("properties() = %v, want %v", got, want)
+	}
+}
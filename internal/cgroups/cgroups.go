@@ -21,17 +21,47 @@ import (
 	"devsandbox/internal/cmdpattern"
 )
 
-// Limits describes the resource caps applied to a sandbox. Empty memory and
-// cpus strings, and a zero pids count, mean unlimited.
+// Limits describes the resource caps applied to a sandbox. Empty strings and
+// zero counts mean unlimited.
 type Limits struct {
 	Memory string
 	CPUs   string
 	PIDs   int
+
+	// MemoryHigh is the soft memory limit: above it the kernel throttles the
+	// sandbox and reclaims its memory aggressively instead of OOM-killing it.
+	MemoryHigh string
+	// MemorySwap is the swap the sandbox may use on top of Memory. "0" means
+	// none; empty leaves swap unbounded.
+	MemorySwap string
+
+	// IOReadBPS and IOWriteBPS cap the sandbox's read and write bandwidth, in
+	// bytes per second with the same suffixes as Memory, on the block devices
+	// backing IOPaths.
+	IOReadBPS  string
+	IOWriteBPS string
+	// IOWeight is the sandbox's cgroup v2 io.weight, from 1 to 10000 with 100
+	// as the kernel default. Zero leaves it unset.
+	IOWeight int
+
+	// IOPaths are the paths whose backing block devices the bandwidth caps
+	// apply to. A cgroup bandwidth cap is per device, so it has to name one;
+	// the isolator supplies the directories the sandbox writes to. They are
+	// not a limit themselves and do not count towards IsZero.
+	IOPaths []string
 }
 
 // IsZero reports whether no limits are configured.
 func (l Limits) IsZero() bool {
-	return l.Memory == "" && l.CPUs == "" && l.PIDs == 0
+	return l.Memory == "" && l.CPUs == "" && l.PIDs == 0 &&
+		l.MemoryHigh == "" && l.MemorySwap == "" &&
+		l.IOReadBPS == "" && l.IOWriteBPS == "" && l.IOWeight == 0
+}
+
+// hasIOBandwidth reports whether a bandwidth cap is configured, which is what
+// needs IOPaths resolved to devices.
+func (l Limits) hasIOBandwidth() bool {
+	return l.IOReadBPS != "" || l.IOWriteBPS != ""
 }
 
 // memoryPattern matches a byte count with an optional base-1024 unit suffix.
@@ -43,6 +73,12 @@ var zeroMemoryPattern = regexp.MustCompile(`^0+[bkmgBKMG]?$`)
 // maxCPUs bounds the cpus value so the percent conversion cannot overflow.
 const maxCPUs = 1e6
 
+// The cgroup v2 io.weight range.
+const (
+	minIOWeight = 1
+	maxIOWeight = 10000
+)
+
 // ValidateMemory checks a memory limit string against the forms this package can
 // translate. It is exported so the config layer validates exactly those forms
 // rather than keeping a second copy of the rules that could drift from these.
@@ -60,6 +96,58 @@ func ValidateMemory(s string) error {
 	return nil
 }
 
+// ValidateBandwidth checks an io_read_bps or io_write_bps value. It takes the
+// memory forms, read as bytes per second, and rejects zero: both systemd and
+// docker read a zero cap as a device the sandbox may not touch at all, which no
+// one asking for a bandwidth limit means.
+func ValidateBandwidth(s string) error {
+	if s == "" {
+		return nil
+	}
+	if !memoryPattern.MatchString(s) {
+		return fmt.Errorf("invalid io bandwidth limit %q: use bytes per second with an optional b, k, m or g suffix, like '50m'", s)
+	}
+	if zeroMemoryPattern.MatchString(s) {
+		return fmt.Errorf("invalid io bandwidth limit %q: a zero cap would stall every read or write on the device; omit the setting for unlimited", s)
+	}
+	return nil
+}
+
+// ValidateIOWeight checks an io_weight value against the cgroup v2 io.weight
+// range. Zero means unset.
+func ValidateIOWeight(w int) error {
+	if w != 0 && (w < minIOWeight || w > maxIOWeight) {
+		return fmt.Errorf("invalid io weight %d: must be between %d and %d (100 is the default every other process gets)", w, minIOWeight, maxIOWeight)
+	}
+	return nil
+}
+
+// ParseBytes converts a value in the memory form to a byte count, reading the
+// suffixes as base-1024 the way systemd and docker both do.
+func ParseBytes(s string) (int64, error) {
+	if err := ValidateMemory(s); err != nil {
+		return 0, err
+	}
+	if s == "" {
+		return 0, nil
+	}
+	mult := int64(1)
+	switch s[len(s)-1] {
+	case 'k', 'K':
+		mult = 1 << 10
+	case 'm', 'M':
+		mult = 1 << 20
+	case 'g', 'G':
+		mult = 1 << 30
+	}
+	digits := strings.TrimRight(s, "bkmgBKMG")
+	n, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil || n > math.MaxInt64/mult {
+		return 0, fmt.Errorf("invalid size %q: too large", s)
+	}
+	return n * mult, nil
+}
+
 // validateSystemdMemory adds the constraint that only holds under a transient
 // scope: systemd's MemoryMax=0 grants the scope no memory at all, so a sandbox
 // launched with it is OOM-killed the moment it starts. Docker reads the same
@@ -76,7 +164,7 @@ func validateSystemdMemory(s string) error {
 }
 
 // properties converts the limits to systemd unit properties, in a stable order:
-// memory, cpu, pids.
+// memory, cpu, pids, io.
 func (l Limits) properties() ([]string, error) {
 	var props []string
 
@@ -87,20 +175,38 @@ func (l Limits) properties() ([]string, error) {
 		// systemd's MemoryMax uses base-1024 uppercase suffixes and treats a
 		// bare integer as bytes, matching the config form once uppercased.
 		//
-		// No MemorySwapMax accompanies it, so memory.swap.max stays at max and
-		// swap is not bounded here. That is weaker than the container backends:
-		// docker and krun pass --memory with --memory-swap unset, which the
-		// engine expands to a memory+swap ceiling of twice the value.
+		// Unless memory_swap is set, no MemorySwapMax accompanies it, so
+		// memory.swap.max stays at max and swap is not bounded here. That is
+		// weaker than the container backends: docker and krun pass --memory
+		// with --memory-swap unset, which the engine expands to a memory+swap
+		// ceiling of twice the value.
 		//
-		// Pinning swap to zero would make the configured number a hard ceiling,
-		// but it cannot be verified: memory.swap.max exists only with kernel
-		// swap accounting, and without it systemd logs a warning and starts the
-		// scope anyway, which no preflight check can observe. Asserting a
-		// guarantee the host may not be enforcing is worse than documenting the
-		// weaker one bwrap actually delivers.
+		// Pinning swap to zero by default would make the configured number a
+		// hard ceiling, but it cannot be verified for every host: memory.swap.max
+		// exists only with kernel swap accounting, and without it systemd logs
+		// a warning and starts the scope anyway. An explicit memory_swap is
+		// different - the user asked for it, so Preflight checks the file is
+		// there and refuses the launch when it is not.
 		props = append(props, "MemoryMax="+strings.ToUpper(l.Memory))
 	}
 
+	if l.MemoryHigh != "" {
+		// MemoryHigh=0 throttles every allocation, which is a hang rather than
+		// a limit, for the same reason MemoryMax=0 is an OOM kill.
+		if err := validateSystemdMemory(l.MemoryHigh); err != nil {
+			return nil, fmt.Errorf("memory_high: %w", err)
+		}
+		props = append(props, "MemoryHigh="+strings.ToUpper(l.MemoryHigh))
+	}
+
+	if l.MemorySwap != "" {
+		// Zero is meaningful here: no swap at all.
+		if err := ValidateMemory(l.MemorySwap); err != nil {
+			return nil, fmt.Errorf("memory_swap: %w", err)
+		}
+		props = append(props, "MemorySwapMax="+strings.ToUpper(l.MemorySwap))
+	}
+
 	if l.CPUs != "" {
 		v, err := strconv.ParseFloat(l.CPUs, 64)
 		if err != nil || math.IsNaN(v) || math.IsInf(v, 0) || v <= 0 || v > maxCPUs {
@@ -120,6 +226,37 @@ func (l Limits) properties() ([]string, error) {
 		props = append(props, fmt.Sprintf("TasksMax=%d", l.PIDs))
 	}
 
+	if err := ValidateIOWeight(l.IOWeight); err != nil {
+		return nil, err
+	}
+	if l.IOWeight > 0 {
+		props = append(props, fmt.Sprintf("IOWeight=%d", l.IOWeight))
+	}
+
+	if l.hasIOBandwidth() {
+		for _, v := range []string{l.IOReadBPS, l.IOWriteBPS} {
+			if err := ValidateBandwidth(v); err != nil {
+				return nil, err
+			}
+		}
+		// Devices are resolved here rather than handed to systemd as paths:
+		// systemd would accept a path and resolve it itself, but the docker
+		// backend needs device nodes anyway, and resolving both the same way
+		// means both backends cap the same disks.
+		devices, err := BlockDevices(l.IOPaths)
+		if err != nil {
+			return nil, err
+		}
+		for _, dev := range devices {
+			if l.IOReadBPS != "" {
+				props = append(props, fmt.Sprintf("IOReadBandwidthMax=%s %s", dev, strings.ToUpper(l.IOReadBPS)))
+			}
+			if l.IOWriteBPS != "" {
+				props = append(props, fmt.Sprintf("IOWriteBandwidthMax=%s %s", dev, strings.ToUpper(l.IOWriteBPS)))
+			}
+		}
+	}
+
 	return props, nil
 }
 
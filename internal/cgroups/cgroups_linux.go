@@ -33,6 +33,7 @@ var limitNames = map[string]string{
 	"memory": "memory",
 	"cpu":    "cpus",
 	"pids":   "pids",
+	"io":     "io_*",
 }
 
 // Preflight verifies the limits can actually be enforced before anything is
@@ -66,6 +67,9 @@ func Preflight(l Limits) error {
 	if err := checkDelegation(l); err != nil {
 		return err
 	}
+	if err := checkSwapAccounting(l); err != nil {
+		return err
+	}
 	return probeScope(props)
 }
 
@@ -174,7 +178,7 @@ const outsideUserManagerHint = "this session is not running under the systemd us
 // same order properties emits them.
 func requiredControllers(l Limits) []string {
 	var out []string
-	if l.Memory != "" {
+	if l.Memory != "" || l.MemoryHigh != "" || l.MemorySwap != "" {
 		out = append(out, "memory")
 	}
 	if l.CPUs != "" {
@@ -183,6 +187,9 @@ func requiredControllers(l Limits) []string {
 	if l.PIDs > 0 {
 		out = append(out, "pids")
 	}
+	if l.IOWeight > 0 || l.hasIOBandwidth() {
+		out = append(out, "io")
+	}
 	return out
 }
 
@@ -248,6 +255,27 @@ func checkDelegation(l Limits) error {
 	return nil
 }
 
+// checkSwapAccounting verifies a configured memory_swap can be enforced.
+// memory.swap.max exists only when the kernel accounts swap per cgroup, and
+// without it systemd accepts MemorySwapMax, logs a warning nobody reads, and
+// starts the scope with swap unbounded - which no later step can observe. The
+// file's presence in the user manager cgroup is the one place that shows it.
+func checkSwapAccounting(l Limits) error {
+	if l.MemorySwap == "" {
+		return nil
+	}
+	managerCgroup, _ := userManagerCgroup()
+	file := filepath.Join(cgroupRoot, filepath.FromSlash(managerCgroup), "memory.swap.max")
+	if _, err := os.Stat(file); err != nil {
+		return fmt.Errorf(
+			"the configured memory_swap limit needs kernel swap accounting, but %s does not exist, so the limit would be silently ignored: "+
+				"enable swap accounting (swapaccount=1 on older kernels) or remove memory_swap",
+			file,
+		)
+	}
+	return nil
+}
+
 // delegatedControllers reads the controllers made available to the systemd user
 // manager cgroup, which is the subtree an unprivileged transient scope lands in.
 func delegatedControllers() ([]string, error) {
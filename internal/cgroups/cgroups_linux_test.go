@@ -81,6 +81,10 @@ func TestRequiredControllers(t *testing.T) {
 			limits: Limits{Memory: "4g", CPUs: "2", PIDs: 64},
 			want:   []string{"memory", "cpu", "pids"},
 		},
+		{name: "memory_high needs memory", limits: Limits{MemoryHigh: "3g"}, want: []string{"memory"}},
+		{name: "io weight", limits: Limits{IOWeight: 50}, want: []string{"io"}},
+		{name: "io bandwidth", limits: Limits{IOWriteBPS: "50m"}, want: []string{"io"}},
+		{name: "io paths alone need nothing", limits: Limits{IOPaths: []string{"/"}}, want: nil},
 	}
 
 	for _, tt := range tests {
@@ -755,3 +759,26 @@ func shortRuntimeDir(t *testing.T) string {
 	}
 	return dir
 }
+
+func TestCheckSwapAccounting(t *testing.T) {
+	root := fakeHierarchy(t, "cpu io memory pids\n")
+	withCgroupRoot(t, root)
+	withProcCgroup(t, "0::/\n")
+
+	if err := checkSwapAccounting(Limits{Memory: "4g"}); err != nil {
+		t.Errorf("checkSwapAccounting() without memory_swap = %v, want nil", err)
+	}
+
+	err := checkSwapAccounting(Limits{Memory: "4g", MemorySwap: "0"})
+	if err == nil || !strings.Contains(err.Error(), "swap accounting") {
+		t.Fatalf("checkSwapAccounting() = %v, want a swap accounting error", err)
+	}
+
+	swapMax := filepath.Join(root, filepath.FromSlash(conventionalCgroup()), "memory.swap.max")
+	if err := os.WriteFile(swapMax, []byte("max\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkSwapAccounting(Limits{Memory: "4g", MemorySwap: "0"}); err != nil {
+		t.Errorf("checkSwapAccounting() with memory.swap.max present = %v, want nil", err)
+	}
+}
@@ -13,3 +13,8 @@ func Preflight(l Limits) error {
 	}
 	return errors.New("resource limits are only supported on Linux")
 }
+
+// BlockDevices cannot resolve a cgroup io device on a platform without cgroups.
+func BlockDevices(_ []string) ([]string, error) {
+	return nil, errors.New("io bandwidth limits are only supported on Linux")
+}
@@ -30,6 +30,11 @@ func TestLimits_IsZero(t *testing.T) {
 		{name: "pids set", limits: Limits{PIDs: 64}, want: false},
 		{name: "all set", limits: Limits{Memory: "4g", CPUs: "2", PIDs: 64}, want: false},
 		{name: "negative pids", limits: Limits{PIDs: -1}, want: false},
+		{name: "memory_high set", limits: Limits{MemoryHigh: "3g"}, want: false},
+		{name: "memory_swap zero set", limits: Limits{MemorySwap: "0"}, want: false},
+		{name: "io read set", limits: Limits{IOReadBPS: "50m"}, want: false},
+		{name: "io weight set", limits: Limits{IOWeight: 50}, want: false},
+		{name: "io paths alone", limits: Limits{IOPaths: []string{"/"}}, want: true},
 	}
 
 	for _, tt := range tests {
@@ -69,6 +74,13 @@ func TestLimits_Properties(t *testing.T) {
 			limits: Limits{Memory: "512m", PIDs: 64},
 			want:   []string{"MemoryMax=512M", "TasksMax=64"},
 		},
+		{
+			name:   "soft limit and swap follow memory",
+			limits: Limits{Memory: "4g", MemoryHigh: "3g", MemorySwap: "1g"},
+			want:   []string{"MemoryMax=4G", "MemoryHigh=3G", "MemorySwapMax=1G"},
+		},
+		{name: "zero swap means none", limits: Limits{Memory: "4g", MemorySwap: "0"}, want: []string{"MemoryMax=4G", "MemorySwapMax=0"}},
+		{name: "io weight last", limits: Limits{PIDs: 64, IOWeight: 50}, want: []string{"TasksMax=64", "IOWeight=50"}},
 	}
 
 	for _, tt := range tests {
@@ -106,6 +118,13 @@ func TestLimits_PropertiesErrors(t *testing.T) {
 		{name: "absurd cpus", limits: Limits{CPUs: "1e9"}, wantErr: "invalid cpu limit"},
 		{name: "negative pids", limits: Limits{PIDs: -1}, wantErr: "invalid pids limit"},
 		{name: "cpus rounds to zero", limits: Limits{CPUs: "0.004"}, wantErr: "rounds to CPUQuota=0%"},
+		{name: "memory_high zero", limits: Limits{MemoryHigh: "0"}, wantErr: "memory_high"},
+		{name: "memory_high unparseable", limits: Limits{MemoryHigh: "soft"}, wantErr: "invalid memory limit"},
+		{name: "memory_swap unparseable", limits: Limits{Memory: "4g", MemorySwap: "-1"}, wantErr: "memory_swap"},
+		{name: "io weight too high", limits: Limits{IOWeight: 10001}, wantErr: "invalid io weight"},
+		{name: "io weight negative", limits: Limits{IOWeight: -1}, wantErr: "invalid io weight"},
+		{name: "io bandwidth zero", limits: Limits{IOReadBPS: "0m", IOPaths: []string{"/"}}, wantErr: "zero cap"},
+		{name: "io bandwidth unparseable", limits: Limits{IOWriteBPS: "fast", IOPaths: []string{"/"}}, wantErr: "invalid io bandwidth limit"},
 	}
 
 	for _, tt := range tests {
@@ -124,6 +143,29 @@ func TestLimits_PropertiesErrors(t *testing.T) {
 	}
 }
 
+func TestParseBytes(t *testing.T) {
+	tests := map[string]int64{
+		"":      0,
+		"0":     0,
+		"512":   512,
+		"4096b": 4096,
+		"2k":    2 << 10,
+		"512M":  512 << 20,
+		"4g":    4 << 30,
+	}
+	for in, want := range tests {
+		got, err := ParseBytes(in)
+		if err != nil || got != want {
+			t.Errorf("ParseBytes(%q) = %d, %v, want %d", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"4gb", "-1", "99999999999999999999g"} {
+		if _, err := ParseBytes(in); err == nil {
+			t.Errorf("ParseBytes(%q) = nil error, want one", in)
+		}
+	}
+}
+
 // A cpus value that rounds to zero must be rejected rather than emitted as
 // CPUQuota=0%, which produces a sandbox that never schedules.
 func TestLimits_PropertiesRejectsZeroRoundedCPUQuota(t *testing.T) {
@@ -0,0 +1,124 @@
+package cgroups
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrStatsUnsupported reports that live stats cannot be read on this platform.
+// They come from cgroup v2 interface files, which exist only on Linux.
+var ErrStatsUnsupported = errors.New("sandbox stats read cgroup v2 interface files and are only supported on Linux")
+
+// Stats is one reading of a sandbox cgroup's resource usage.
+//
+// Only cpu.stat is guaranteed: it belongs to the cgroup core. The other
+// files exist only while their controller is enabled in the cgroup, and
+// systemd enables a controller for a scope only when a property needs it - a
+// scope with no io_* limit usually has no io.stat. The Has flags keep "not
+// accounted" apart from a real zero.
+type Stats struct {
+	// CPUUsage is cpu.stat's usage_usec: CPU time consumed since the cgroup
+	// was created.
+	CPUUsage time.Duration `json:"cpu_usage_ns"`
+
+	HasMemory bool `json:"has_memory"`
+	// MemoryCurrent is memory.current, and MemoryMax memory.max with zero for
+	// no limit.
+	MemoryCurrent int64 `json:"memory_current"`
+	MemoryMax     int64 `json:"memory_max"`
+	// SwapCurrent is memory.swap.current, zero when swap is not accounted.
+	SwapCurrent int64 `json:"swap_current"`
+
+	HasIO bool `json:"has_io"`
+	// IORead and IOWrite are io.stat's rbytes and wbytes, summed over every
+	// device: bytes transferred since the cgroup was created.
+	IORead  int64 `json:"io_read_bytes"`
+	IOWrite int64 `json:"io_write_bytes"`
+
+	HasPIDs bool `json:"has_pids"`
+	// PIDs is pids.current, and PIDsMax pids.max with zero for no limit.
+	PIDs    int64 `json:"pids"`
+	PIDsMax int64 `json:"pids_max"`
+}
+
+// Rates are the per-second rates between two readings of the same cgroup.
+type Rates struct {
+	// CPUPercent is CPU time over wall time, where 100 is one full core.
+	CPUPercent float64 `json:"cpu_percent"`
+	// IOReadBPS and IOWriteBPS are bytes per second.
+	IOReadBPS  float64 `json:"io_read_bps"`
+	IOWriteBPS float64 `json:"io_write_bps"`
+}
+
+// RatesBetween computes the rates from prev to cur, taken elapsed apart.
+// Counters that went backwards - a cgroup removed and recreated under the same
+// path - yield zero rather than a negative rate.
+func RatesBetween(prev, cur Stats, elapsed time.Duration) Rates {
+	if elapsed <= 0 {
+		return Rates{}
+	}
+	secs := elapsed.Seconds()
+	return Rates{
+		CPUPercent: float64(max(cur.CPUUsage-prev.CPUUsage, 0)) / float64(elapsed) * 100,
+		IOReadBPS:  float64(max(cur.IORead-prev.IORead, 0)) / secs,
+		IOWriteBPS: float64(max(cur.IOWrite-prev.IOWrite, 0)) / secs,
+	}
+}
+
+// parseCPUStat returns usage_usec from a cpu.stat file.
+func parseCPUStat(data string) time.Duration {
+	for line := range strings.SplitSeq(data, "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), " ")
+		if !ok || key != "usage_usec" {
+			continue
+		}
+		n, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		if err != nil {
+			return 0
+		}
+		return time.Duration(n) * time.Microsecond
+	}
+	return 0
+}
+
+// parseIOStat sums rbytes and wbytes over every device line of an io.stat
+// file. Like memory.events it gains keys across kernel versions, so unknown
+// keys and unparseable values are skipped rather than failing the read.
+func parseIOStat(data string) (read, write int64) {
+	for line := range strings.SplitSeq(data, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		// The first field is the device's major:minor.
+		for _, kv := range fields[1:] {
+			key, value, ok := strings.Cut(kv, "=")
+			if !ok {
+				continue
+			}
+			n, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				continue
+			}
+			switch key {
+			case "rbytes":
+				read += n
+			case "wbytes":
+				write += n
+			}
+		}
+	}
+	return read, write
+}
+
+// parseCounter reads a single-value interface file such as memory.current or
+// pids.max. "max" is the kernel's way of writing no limit and reads as zero.
+func parseCounter(data string) (int64, error) {
+	s := strings.TrimSpace(data)
+	if s == "max" {
+		return 0, nil
+	}
+	return strconv.ParseInt(s, 10, 64)
+}
@@ -0,0 +1,65 @@
+//go:build linux
+
+package cgroups
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// ReadStats takes one reading of the cgroup at dir, as located by
+// ScopeCgroupDir or ContainerCgroupDir.
+//
+// A missing cpu.stat means the cgroup itself is gone - the sandbox exited and
+// systemd or the engine removed it - and is reported as ErrProcessGone. Files
+// of controllers that are not enabled are simply absent and leave their Has
+// flag false.
+func ReadStats(dir string) (Stats, error) {
+	var s Stats
+
+	data, err := os.ReadFile(filepath.Join(dir, "cpu.stat"))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return Stats{}, fmt.Errorf("%w: cgroup %s no longer exists", ErrProcessGone, dir)
+		}
+		return Stats{}, fmt.Errorf("read %s: %w", filepath.Join(dir, "cpu.stat"), err)
+	}
+	s.CPUUsage = parseCPUStat(string(data))
+
+	if cur, ok := readCounter(dir, "memory.current"); ok {
+		s.HasMemory = true
+		s.MemoryCurrent = cur
+		s.MemoryMax, _ = readCounter(dir, "memory.max")
+		s.SwapCurrent, _ = readCounter(dir, "memory.swap.current")
+	}
+
+	if data, err := os.ReadFile(filepath.Join(dir, "io.stat")); err == nil {
+		s.HasIO = true
+		s.IORead, s.IOWrite = parseIOStat(string(data))
+	}
+
+	if cur, ok := readCounter(dir, "pids.current"); ok {
+		s.HasPIDs = true
+		s.PIDs = cur
+		s.PIDsMax, _ = readCounter(dir, "pids.max")
+	}
+
+	return s, nil
+}
+
+// readCounter reads a single-value interface file, reporting false when it is
+// absent or unparseable.
+func readCounter(dir, name string) (int64, bool) {
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return 0, false
+	}
+	n, err := parseCounter(string(data))
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
@@ -0,0 +1,67 @@
+//go:build linux
+
+package cgroups
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeCgroupFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestReadStats(t *testing.T) {
+	dir := t.TempDir()
+	writeCgroupFiles(t, dir, map[string]string{
+		"cpu.stat":            "usage_usec 2000000\n",
+		"memory.current":      "1048576\n",
+		"memory.max":          "4294967296\n",
+		"memory.swap.current": "0\n",
+		"io.stat":             "8:0 rbytes=10 wbytes=20\n",
+		"pids.current":        "12\n",
+		"pids.max":            "max\n",
+	})
+
+	s, err := ReadStats(dir)
+	if err != nil {
+		t.Fatalf("ReadStats() error = %v", err)
+	}
+	want := Stats{
+		CPUUsage:  2 * time.Second,
+		HasMemory: true, MemoryCurrent: 1 << 20, MemoryMax: 4 << 30,
+		HasIO: true, IORead: 10, IOWrite: 20,
+		HasPIDs: true, PIDs: 12,
+	}
+	if s != want {
+		t.Errorf("ReadStats() = %+v\nwant %+v", s, want)
+	}
+}
+
+func TestReadStats_ControllersNotEnabled(t *testing.T) {
+	dir := t.TempDir()
+	writeCgroupFiles(t, dir, map[string]string{"cpu.stat": "usage_usec 5\n"})
+
+	s, err := ReadStats(dir)
+	if err != nil {
+		t.Fatalf("ReadStats() error = %v", err)
+	}
+	if s.HasMemory || s.HasIO || s.HasPIDs {
+		t.Errorf("ReadStats() = %+v, want every optional controller reported absent", s)
+	}
+}
+
+func TestReadStats_CgroupGone(t *testing.T) {
+	_, err := ReadStats(filepath.Join(t.TempDir(), "gone.scope"))
+	if !errors.Is(err, ErrProcessGone) {
+		t.Errorf("ReadStats() error = %v, want ErrProcessGone", err)
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+
+package cgroups
+
+// ReadStats reports the platform limitation rather than a reading of zeros.
+func ReadStats(_ string) (Stats, error) {
+	return Stats{}, ErrStatsUnsupported
+}
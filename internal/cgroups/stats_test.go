@@ -0,0 +1,57 @@
+package cgroups
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCPUStat(t *testing.T) {
+	data := "usage_usec 1500000\nuser_usec 1000000\nsystem_usec 500000\n"
+	if got := parseCPUStat(data); got != 1500*time.Millisecond {
+		t.Errorf("parseCPUStat() = %v, want 1.5s", got)
+	}
+	if got := parseCPUStat("user_usec 10\n"); got != 0 {
+		t.Errorf("parseCPUStat() without usage_usec = %v, want 0", got)
+	}
+}
+
+func TestParseIOStat(t *testing.T) {
+	data := "8:0 rbytes=1000 wbytes=2000 rios=1 wios=2 dbytes=0 dios=0\n" +
+		"254:0 rbytes=500 wbytes=junk rios=3 wios=4 newkey=9\n" +
+		"\n"
+	read, write := parseIOStat(data)
+	if read != 1500 || write != 2000 {
+		t.Errorf("parseIOStat() = %d, %d, want 1500, 2000", read, write)
+	}
+}
+
+func TestParseCounter(t *testing.T) {
+	if n, err := parseCounter("4096\n"); err != nil || n != 4096 {
+		t.Errorf("parseCounter(4096) = %d, %v", n, err)
+	}
+	if n, err := parseCounter("max\n"); err != nil || n != 0 {
+		t.Errorf("parseCounter(max) = %d, %v, want 0 for no limit", n, err)
+	}
+	if _, err := parseCounter("lots"); err == nil {
+		t.Error("parseCounter(lots) = nil error, want one")
+	}
+}
+
+func TestRatesBetween(t *testing.T) {
+	prev := Stats{CPUUsage: time.Second, IORead: 1 << 20, IOWrite: 4 << 20}
+	cur := Stats{CPUUsage: 3 * time.Second, IORead: 3 << 20, IOWrite: 2 << 20}
+
+	r := RatesBetween(prev, cur, 2*time.Second)
+	if r.CPUPercent != 100 {
+		t.Errorf("CPUPercent = %v, want 100 (one full core)", r.CPUPercent)
+	}
+	if r.IOReadBPS != 1<<20 {
+		t.Errorf("IOReadBPS = %v, want 1 MiB/s", r.IOReadBPS)
+	}
+	if r.IOWriteBPS != 0 {
+		t.Errorf("IOWriteBPS = %v, want 0 for a counter that went backwards", r.IOWriteBPS)
+	}
+	if got := RatesBetween(prev, cur, 0); got != (Rates{}) {
+		t.Errorf("RatesBetween() with no elapsed time = %+v, want zero", got)
+	}
+}
@@ -5,9 +5,10 @@ import "sync"
 // OwnedSet is a concurrent-safe set of resource IDs the sandbox created itself.
 //
 // Proxies use it to scope mutating operations: a request naming an ID that is
-// not in the set is denied, so a sandbox can only act on windows/tabs/panes it
-// opened, never on the user's own. The type parameter exists because kitty
-// identifies windows by int and herdr identifies tabs and panes by string.
+// not in the set is denied, so a sandbox can only act on windows/tabs/panes or
+// containers it opened, never on the user's own. The type parameter exists
+// because kitty identifies windows by int and herdr and Docker identify their
+// resources by string.
 type OwnedSet[T comparable] struct {
 	mu  sync.RWMutex
 	ids map[T]struct{}
@@ -29,3 +30,14 @@ func (s *OwnedSet[T]) Contains(id T) bool {
 	s.mu.RUnlock()
 	return ok
 }
+
+// Items returns the IDs in the set, in no particular order.
+func (s *OwnedSet[T]) Items() []T {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	items := make([]T, 0, len(s.ids))
+	for id := range s.ids {
+		items = append(items, id)
+	}
+	return items
+}
@@ -1,6 +1,7 @@
 package cmdpattern
 
 import (
+	"slices"
 	"sync"
 	"testing"
 )
@@ -53,6 +54,23 @@ func TestOwnedSetAddIsIdempotent(t *testing.T) {
 	}
 }
 
+func TestOwnedSetItems(t *testing.T) {
+	s := NewOwnedSet[string]()
+	if got := s.Items(); len(got) != 0 {
+		t.Errorf("Items() = %v on empty set, want none", got)
+	}
+
+	s.Add("b")
+	s.Add("a")
+	s.Add("a")
+
+	got := s.Items()
+	slices.Sort(got)
+	if !slices.Equal(got, []string{"a", "b"}) {
+		t.Errorf("Items() = %v, want [a b]", got)
+	}
+}
+
 // TestOwnedSetConcurrent exercises the mutex under -race. The proxy adds ids
 // from the upstream-response pump while the request pump reads them, so
 // concurrent Add/Contains is the normal operating mode, not an edge case.
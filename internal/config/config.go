@@ -19,6 +19,7 @@ import (
 	"devsandbox/internal/notice"
 	"devsandbox/internal/source"
 	"github.com/BurntSushi/toml"
+	"github.com/bmatcuk/doublestar/v4"
 )
 
 const (
@@ -44,6 +45,11 @@ const (
 	// can hold - and the proxy runs outside the sandbox's resource limits. A
 	// body past the limit is blocked rather than forwarded on a partial scan.
 	DefaultMaxRedactionScanBytes = 10 * 1024 * 1024
+	// DefaultMaxResponseRedactionScanBytes bounds the response body the
+	// response redaction scan buffers - or, for a streamed response, the
+	// longest line it holds - when proxy.redaction.max_response_scan_bytes is
+	// unset. A body or line past it is refused, never passed on unscanned.
+	DefaultMaxResponseRedactionScanBytes = 10 * 1024 * 1024
 )
 
 // Config represents the devsandbox configuration file.
@@ -116,6 +122,22 @@ type ProxyConfig struct {
 	// log dispatchers (syslog/OTLP). Empty rules → nothing is skipped.
 	LogSkip ProxyLogSkipConfig `toml:"log_skip"`
 
+	// GitPush restricts which refs a git push over smart HTTP may change.
+	// Requires MITM for HTTPS remotes. Empty lists → pushes are not inspected.
+	GitPush ProxyGitPushConfig `toml:"git_push"`
+
+	// Limits caps requests per minute, concurrent requests and uploaded
+	// bytes per host pattern. Requires MITM. Empty rules → no limits.
+	Limits ProxyLimitsConfig `toml:"limits"`
+
+	// DNS runs a resolver for the sandbox that answers only for names the
+	// filter would allow. bwrap backend only.
+	DNS ProxyDNSConfig `toml:"dns"`
+
+	// SOCKS runs a SOCKS5 listener for TCP egress that is not HTTP, decided
+	// by the filter per host:port. bwrap backend only.
+	SOCKS ProxySOCKSConfig `toml:"socks"`
+
 	// MaxLogBodyBytes bounds how many bytes of a request or response body are
 	// recorded in a log entry. The body itself always reaches its destination
 	// whole; only the recorded copy is bounded, and an entry cut short is
@@ -168,6 +190,11 @@ type ProxyFilterConfig struct {
 	// Default: true
 	CacheDecisions *bool `toml:"cache_decisions"`
 
+	// AskNotifications raises a desktop notification with Allow and Block
+	// buttons for an ask-mode request no monitor is connected to answer.
+	// Default: true
+	AskNotifications *bool `toml:"ask_notifications"`
+
 	// Rules is the list of filter rules.
 	Rules []ProxyFilterRule `toml:"rules"`
 }
@@ -190,6 +217,38 @@ type ProxyFilterRule struct {
 
 	// Reason is shown when blocking a request.
 	Reason string `toml:"reason"`
+
+	// Methods restricts the rule to these HTTP methods. Empty → any method.
+	Methods []string `toml:"methods"`
+
+	// Headers restricts the rule to requests whose named headers match the
+	// given patterns (glob, or regex when auto-detected).
+	Headers map[string]string `toml:"headers"`
+
+	// Body restricts the rule to requests whose JSON body satisfies every
+	// matcher.
+	Body []ProxyFilterBodyMatch `toml:"body"`
+
+	// Ports restricts the rule to these destination ports. Empty → any port.
+	Ports []int `toml:"ports"`
+}
+
+// ProxyFilterBodyMatch tests values selected from a JSON request body.
+// At most one of In, NotIn, and Pattern may be set; with none, the matcher
+// tests that Path selects anything.
+type ProxyFilterBodyMatch struct {
+	// Path is a JSONPath expression, e.g. "$.model".
+	Path string `toml:"path"`
+
+	// In matches when a selected value is in the list.
+	In []string `toml:"in"`
+
+	// NotIn matches when a selected value is outside the list, or when
+	// nothing is selected.
+	NotIn []string `toml:"not_in"`
+
+	// Pattern matches when a selected value matches (glob or regex).
+	Pattern string `toml:"pattern"`
 }
 
 // ProxyLogSkipConfig contains rules for suppressing request log entries.
@@ -214,6 +273,61 @@ type ProxyLogSkipRule struct {
 	Type string `toml:"type"`
 }
 
+// ProxyGitPushConfig contains the git push ref policy.
+// Enforcement is active when either list is non-empty.
+type ProxyGitPushConfig struct {
+	// AllowRefs lists the refs a push may change, as globs
+	// (e.g. "refs/heads/devsandbox/*"). A push touching any other ref is
+	// refused. Empty → every ref not listed in ProtectedRefs is allowed.
+	AllowRefs []string `toml:"allow_refs"`
+
+	// ProtectedRefs lists refs that may be created but never moved or
+	// deleted, even when AllowRefs matches them.
+	ProtectedRefs []string `toml:"protected_refs"`
+}
+
+// ProxyDNSConfig controls the proxy's DNS resolver.
+type ProxyDNSConfig struct {
+	// Enabled points the sandbox's resolv.conf at the resolver. Default: false.
+	Enabled *bool `toml:"enabled"`
+
+	// AnswerHostlessRules answers every name while an allow rule names no
+	// host, such as a path-scoped rule. Default: false.
+	AnswerHostlessRules *bool `toml:"answer_hostless_rules"`
+}
+
+// ProxySOCKSConfig controls the proxy's SOCKS5 listener.
+type ProxySOCKSConfig struct {
+	// Enabled forwards the sandbox's 127.0.0.1:1080 to the listener and sets
+	// ALL_PROXY to it. Default: false.
+	Enabled *bool `toml:"enabled"`
+}
+
+// ProxyLimitsConfig contains per-host traffic limits.
+type ProxyLimitsConfig struct {
+	// Rules lists the limits. Every rule matching a host applies.
+	Rules []ProxyLimitRule `toml:"rules"`
+}
+
+// ProxyLimitRule caps the traffic to hosts matching Pattern. A zero limit is
+// unset; at least one must be set.
+type ProxyLimitRule struct {
+	// Pattern matches the request host (glob, or regex when auto-detected).
+	Pattern string `toml:"pattern"`
+
+	// RequestsPerMinute caps the requests sent in any 60-second window.
+	RequestsPerMinute int `toml:"requests_per_minute"`
+
+	// MaxConcurrent caps the requests in flight at once.
+	MaxConcurrent int `toml:"max_concurrent"`
+
+	// MaxUploadBytes caps the request body bytes sent over the session.
+	MaxUploadBytes int64 `toml:"max_upload_bytes"`
+
+	// OnExceed is "block" (429, the default) or "ask".
+	OnExceed string `toml:"on_exceed"`
+}
+
 // ProxyRedactionConfig contains content redaction settings.
 type ProxyRedactionConfig struct {
 	// Enabled enables content redaction scanning.
@@ -232,6 +346,16 @@ type ProxyRedactionConfig struct {
 	// scan cannot prove the rest carries no secret.
 	// 0 → DefaultMaxRedactionScanBytes. Read through GetMaxScanBytes.
 	MaxScanBytes int `toml:"max_scan_bytes"`
+
+	// ResponseRules run on upstream responses before they reach the sandbox.
+	ResponseRules []ProxyResponseRedactionRule `toml:"response_rules"`
+
+	// MaxResponseScanBytes bounds the response body the response scan
+	// buffers, and the longest line of a streamed response it holds. A
+	// response past this is refused rather than passed on unscanned.
+	// 0 → DefaultMaxResponseRedactionScanBytes. Read through
+	// GetMaxResponseScanBytes.
+	MaxResponseScanBytes int `toml:"max_response_scan_bytes"`
 }
 
 // GetMaxScanBytes returns the redaction scan limit, defaulting to
@@ -243,6 +367,38 @@ func (c ProxyRedactionConfig) GetMaxScanBytes() int {
 	return c.MaxScanBytes
 }
 
+// GetMaxResponseScanBytes returns the response scan limit, defaulting to
+// DefaultMaxResponseRedactionScanBytes when unset.
+func (c ProxyRedactionConfig) GetMaxResponseScanBytes() int {
+	if c.MaxResponseScanBytes <= 0 {
+		return DefaultMaxResponseRedactionScanBytes
+	}
+	return c.MaxResponseScanBytes
+}
+
+// ProxyResponseRedactionRule defines a redaction rule for upstream responses.
+type ProxyResponseRedactionRule struct {
+	// Name is an optional human-readable identifier.
+	// Auto-generated as "response-rule-<index>" if omitted.
+	Name string `toml:"name"`
+
+	// Hosts limits the rule to responses from these hosts (exact, glob, or
+	// regex). Empty applies it to every host.
+	Hosts []string `toml:"hosts"`
+
+	// Action overrides the default action for Source or Pattern matches.
+	Action string `toml:"action"`
+
+	// Source resolves the secret value. Mutually exclusive with Pattern.
+	Source *ProxyRedactionSource `toml:"source"`
+
+	// Pattern is a regex pattern to match. Mutually exclusive with Source.
+	Pattern string `toml:"pattern"`
+
+	// StripHeaders names response headers to remove, e.g. "Set-Cookie".
+	StripHeaders []string `toml:"strip_headers"`
+}
+
 // ProxyRedactionRule defines a single redaction rule.
 type ProxyRedactionRule struct {
 	// Name is an optional human-readable identifier.
@@ -328,12 +484,34 @@ type ResourcesConfig struct {
 	CPUs string `toml:"cpus"`
 	// PIDs is the maximum number of processes/threads. Zero means unlimited.
 	PIDs int `toml:"pids"`
+	// MemoryHigh is the soft memory limit (e.g., "3g"): above it the sandbox is
+	// throttled and reclaimed rather than OOM-killed. Empty means none.
+	MemoryHigh string `toml:"memory_high"`
+	// MemorySwap is the swap allowed on top of Memory (e.g., "1g"; "0" for no
+	// swap). Requires Memory. Empty leaves swap to the backend default.
+	MemorySwap string `toml:"memory_swap"`
+	// IOReadBPS and IOWriteBPS cap disk bandwidth in bytes per second (e.g.,
+	// "50m"). Empty means unlimited.
+	IOReadBPS  string `toml:"io_read_bps"`
+	IOWriteBPS string `toml:"io_write_bps"`
+	// IOWeight is the relative disk priority, 1-10000 with 100 as the default
+	// every other process gets. Zero leaves it unset.
+	IOWeight int `toml:"io_weight"`
 }
 
 // Limits converts the section to the limit type the isolator and cgroups layers
 // use, so the conversion exists once rather than at each call site.
 func (r ResourcesConfig) Limits() cgroups.Limits {
-	return cgroups.Limits{Memory: r.Memory, CPUs: r.CPUs, PIDs: r.PIDs}
+	return cgroups.Limits{
+		Memory:     r.Memory,
+		CPUs:       r.CPUs,
+		PIDs:       r.PIDs,
+		MemoryHigh: r.MemoryHigh,
+		MemorySwap: r.MemorySwap,
+		IOReadBPS:  r.IOReadBPS,
+		IOWriteBPS: r.IOWriteBPS,
+		IOWeight:   r.IOWeight,
+	}
 }
 
 // IsKeepContainerEnabled returns whether container persistence is enabled (defaults to true).
@@ -404,19 +582,16 @@ type SandboxConfig struct {
 // opposite of the backward compatibility the alias exists for. bwrap reads
 // Sandbox.Resources directly.
 func (s SandboxConfig) ResolvedResources() ResourcesConfig {
-	resolved := ResourcesConfig{
-		Memory: s.Docker.Resources.Memory,
-		CPUs:   s.Docker.Resources.CPUs,
-	}
+	// Fields the deprecated section never had come from the new one as they are.
+	resolved := s.Resources
+	resolved.Memory = s.Docker.Resources.Memory
+	resolved.CPUs = s.Docker.Resources.CPUs
 	if s.Resources.Memory != "" {
 		resolved.Memory = s.Resources.Memory
 	}
 	if s.Resources.CPUs != "" {
 		resolved.CPUs = s.Resources.CPUs
 	}
-	if s.Resources.PIDs != 0 {
-		resolved.PIDs = s.Resources.PIDs
-	}
 	return resolved
 }
 
@@ -541,13 +716,15 @@ type LoggingConfig struct {
 
 // ReceiverConfig defines a single log receiver.
 type ReceiverConfig struct {
-	// Type is the receiver type: "syslog", "syslog-remote", or "otlp".
+	// Type is the receiver type: "syslog", "syslog-remote", "otlp", "file",
+	// or "webhook".
 	Type string `toml:"type"`
 
-	// Address is the remote server address (for syslog-remote and otlp).
+	// Address is the remote server address (for syslog-remote, otlp and
+	// webhook).
 	Address string `toml:"address"`
 
-	// Endpoint is the OTLP endpoint URL (alias for Address, for otlp type).
+	// Endpoint is the OTLP endpoint or webhook URL (alias for Address).
 	Endpoint string `toml:"endpoint"`
 
 	// Protocol is the transport protocol:
@@ -561,25 +738,48 @@ type ReceiverConfig struct {
 	// Tag is the syslog program tag.
 	Tag string `toml:"tag"`
 
-	// Headers are custom HTTP headers for OTLP. Values are stored verbatim and
+	// Headers are custom HTTP headers for OTLP and webhook. Values are stored verbatim and
 	// are best for non-secret metadata. For tokens or other secrets, prefer
 	// HeaderSources, which resolves from env vars, files, or literal values.
 	Headers map[string]string `toml:"headers"`
 
-	// HeaderSources defines OTLP headers whose values are resolved from a
+	// HeaderSources defines OTLP and webhook headers whose values are resolved from a
 	// host environment variable, a file, or a literal value. This keeps
 	// secrets out of the config file. Sources take precedence over Headers
 	// when the same header name is set in both.
 	HeaderSources map[string]source.Source `toml:"header_sources"`
 
-	// BatchSize is the OTLP batch size before flush.
+	// BatchSize is the OTLP or webhook batch size before flush.
 	BatchSize int `toml:"batch_size"`
 
-	// FlushInterval is the OTLP flush interval (e.g., "5s").
+	// FlushInterval is the OTLP or webhook flush interval (e.g., "5s").
 	FlushInterval string `toml:"flush_interval"`
 
 	// Insecure disables TLS verification for gRPC connections.
 	Insecure bool `toml:"insecure"`
+
+	// Path is the directory the file receiver writes JSONL files to.
+	Path string `toml:"path"`
+
+	// MaxFileBytes is the size at which the file receiver rotates its active
+	// file. Default: 50 MiB.
+	MaxFileBytes int64 `toml:"max_file_bytes"`
+
+	// MaxFiles is the number of files, active and archived, the file receiver
+	// keeps. Default: 5.
+	MaxFiles int `toml:"max_files"`
+
+	// MaxRetries is how many times the webhook receiver re-sends a failed
+	// batch before spooling it. Default: 3; 0 disables retries.
+	MaxRetries *int `toml:"max_retries"`
+
+	// SpoolDir holds webhook batches that could not be delivered. Default: a
+	// per-endpoint directory next to the internal logging error log.
+	SpoolDir string `toml:"spool_dir"`
+
+	// SpoolMaxBytes bounds SpoolDir; the oldest batches are dropped beyond
+	// it. Default: 10 MiB.
+	SpoolMaxBytes int64 `toml:"spool_max_bytes"`
 }
 
 // PortForwardingConfig contains port forwarding settings.
@@ -858,6 +1058,63 @@ func (c *Config) Validate() error {
 		if rule.Action != "" && !validActions[rule.Action] {
 			return fmt.Errorf("proxy.filter.rules[%d].action must be 'allow', 'block', or 'ask', got %q", i, rule.Action)
 		}
+		for j, method := range rule.Methods {
+			if method == "" {
+				return fmt.Errorf("proxy.filter.rules[%d].methods[%d] cannot be empty", i, j)
+			}
+		}
+		for name := range rule.Headers {
+			if name == "" {
+				return fmt.Errorf("proxy.filter.rules[%d].headers: header name cannot be empty", i)
+			}
+		}
+		for j, port := range rule.Ports {
+			if port < 1 || port > 65535 {
+				return fmt.Errorf("proxy.filter.rules[%d].ports[%d] must be between 1 and 65535, got %d", i, j, port)
+			}
+		}
+		for j, bm := range rule.Body {
+			if bm.Path == "" {
+				return fmt.Errorf("proxy.filter.rules[%d].body[%d].path cannot be empty", i, j)
+			}
+			set := 0
+			for _, isSet := range []bool{len(bm.In) > 0, len(bm.NotIn) > 0, bm.Pattern != ""} {
+				if isSet {
+					set++
+				}
+			}
+			if set > 1 {
+				return fmt.Errorf("proxy.filter.rules[%d].body[%d]: set at most one of in, not_in, and pattern", i, j)
+			}
+		}
+	}
+
+	// Validate git push ref patterns
+	for i, pattern := range c.Proxy.GitPush.AllowRefs {
+		if err := validateRefGlob(pattern); err != nil {
+			return fmt.Errorf("proxy.git_push.allow_refs[%d]: %w", i, err)
+		}
+	}
+	for i, pattern := range c.Proxy.GitPush.ProtectedRefs {
+		if err := validateRefGlob(pattern); err != nil {
+			return fmt.Errorf("proxy.git_push.protected_refs[%d]: %w", i, err)
+		}
+	}
+
+	// Validate limit rules
+	for i, rule := range c.Proxy.Limits.Rules {
+		if rule.Pattern == "" {
+			return fmt.Errorf("proxy.limits.rules[%d].pattern cannot be empty", i)
+		}
+		if rule.RequestsPerMinute < 0 || rule.MaxConcurrent < 0 || rule.MaxUploadBytes < 0 {
+			return fmt.Errorf("proxy.limits.rules[%d]: limits cannot be negative", i)
+		}
+		if rule.RequestsPerMinute == 0 && rule.MaxConcurrent == 0 && rule.MaxUploadBytes == 0 {
+			return fmt.Errorf("proxy.limits.rules[%d]: set at least one of requests_per_minute, max_concurrent, and max_upload_bytes", i)
+		}
+		if rule.OnExceed != "" && rule.OnExceed != "block" && rule.OnExceed != "ask" {
+			return fmt.Errorf("proxy.limits.rules[%d].on_exceed must be 'block' or 'ask', got %q", i, rule.OnExceed)
+		}
 	}
 
 	// Validate log_skip rules
@@ -930,6 +1187,17 @@ func (c *Config) Validate() error {
 	return nil
 }
 
+// validateRefGlob checks a git ref pattern from proxy.git_push.
+func validateRefGlob(pattern string) error {
+	if pattern == "" {
+		return fmt.Errorf("pattern cannot be empty")
+	}
+	if !doublestar.ValidatePattern(pattern) {
+		return fmt.Errorf("invalid glob pattern %q", pattern)
+	}
+	return nil
+}
+
 // validateResources checks a resource limit block. section names the TOML block
 // so the error tells the user which one to fix.
 //
@@ -953,6 +1221,26 @@ func validateResources(r ResourcesConfig, section string) error {
 	if r.PIDs < 0 {
 		return fmt.Errorf("invalid [%s] pids limit %d: must be zero (unlimited) or a positive number", section, r.PIDs)
 	}
+	if err := cgroups.ValidateMemory(r.MemoryHigh); err != nil {
+		return fmt.Errorf("[%s] memory_high: %w", section, err)
+	}
+	if err := cgroups.ValidateMemory(r.MemorySwap); err != nil {
+		return fmt.Errorf("[%s] memory_swap: %w", section, err)
+	}
+	// docker's --memory-swap is a memory+swap total, so the swap allowance can
+	// only be expressed on top of a memory limit. Requiring it everywhere keeps
+	// the setting meaning the same thing on every backend.
+	if r.MemorySwap != "" && r.Memory == "" {
+		return fmt.Errorf("[%s] memory_swap %q needs memory to be set: it is the swap allowed on top of the memory limit", section, r.MemorySwap)
+	}
+	for _, v := range []string{r.IOReadBPS, r.IOWriteBPS} {
+		if err := cgroups.ValidateBandwidth(v); err != nil {
+			return fmt.Errorf("[%s]: %w", section, err)
+		}
+	}
+	if err := cgroups.ValidateIOWeight(r.IOWeight); err != nil {
+		return fmt.Errorf("[%s]: %w", section, err)
+	}
 	return nil
 }
 
@@ -1068,12 +1356,82 @@ func (c *Config) validateRedaction() error {
 		}
 	}
 
+	if r.MaxResponseScanBytes < 0 {
+		return fmt.Errorf("proxy.redaction.max_response_scan_bytes must be >= 0, got %d", r.MaxResponseScanBytes)
+	}
+
+	for i, rule := range r.ResponseRules {
+		for _, h := range rule.Hosts {
+			if h == "" {
+				return fmt.Errorf("proxy.redaction.response_rules[%d]: hosts must not contain an empty pattern", i)
+			}
+		}
+		for _, h := range rule.StripHeaders {
+			if h == "" {
+				return fmt.Errorf("proxy.redaction.response_rules[%d]: strip_headers must not contain an empty name", i)
+			}
+		}
+
+		hasSource := rule.Source != nil
+		hasPattern := rule.Pattern != ""
+
+		if hasSource && hasPattern {
+			return fmt.Errorf("proxy.redaction.response_rules[%d]: source and pattern are mutually exclusive", i)
+		}
+		if !hasSource && !hasPattern {
+			if len(rule.StripHeaders) == 0 {
+				return fmt.Errorf("proxy.redaction.response_rules[%d]: one of source, pattern, or strip_headers is required", i)
+			}
+			if rule.Action != "" {
+				return fmt.Errorf("proxy.redaction.response_rules[%d]: action requires source or pattern", i)
+			}
+		}
+
+		if hasSource {
+			src := rule.Source
+			if src.Value == "" && src.Env == "" && src.File == "" && src.EnvFileKey == "" {
+				return fmt.Errorf("proxy.redaction.response_rules[%d]: source must have at least one field set", i)
+			}
+		}
+
+		if hasPattern {
+			if _, err := regexp.Compile(rule.Pattern); err != nil {
+				return fmt.Errorf("proxy.redaction.response_rules[%d]: invalid regex pattern: %w", i, err)
+			}
+		}
+
+		if !validActions[rule.Action] {
+			return fmt.Errorf("proxy.redaction.response_rules[%d]: action must be 'block', 'redact', or 'log', got %q", i, rule.Action)
+		}
+	}
+
 	return nil
 }
 
-// validateLogging validates the logging receivers, including OTLP header sources.
+// validateLogging validates the logging receivers, including OTLP and
+// webhook header sources.
 func (c *Config) validateLogging() error {
 	for i, r := range c.Logging.Receivers {
+		switch r.Type {
+		case "file":
+			if r.Path == "" {
+				return fmt.Errorf("logging.receivers[%d]: path is required for a file receiver", i)
+			}
+		case "webhook":
+			endpoint := r.Endpoint
+			if endpoint == "" {
+				endpoint = r.Address
+			}
+			if !strings.HasPrefix(endpoint, "http://") && !strings.HasPrefix(endpoint, "https://") {
+				return fmt.Errorf("logging.receivers[%d]: endpoint must be an http:// or https:// URL for a webhook receiver, got %q", i, endpoint)
+			}
+		}
+		if r.MaxFileBytes < 0 || r.MaxFiles < 0 || r.SpoolMaxBytes < 0 {
+			return fmt.Errorf("logging.receivers[%d]: max_file_bytes, max_files and spool_max_bytes cannot be negative", i)
+		}
+		if r.MaxRetries != nil && *r.MaxRetries < 0 {
+			return fmt.Errorf("logging.receivers[%d]: max_retries cannot be negative, got %d", i, *r.MaxRetries)
+		}
 		for name, src := range r.HeaderSources {
 			if name == "" {
 				return fmt.Errorf("logging.receivers[%d].header_sources: header name cannot be empty", i)
@@ -1182,6 +1540,10 @@ port = 8080
 # Cache ask mode decisions for session (default: true)
 # cache_decisions = true
 
+# With no monitor connected, ask through a desktop notification with
+# Allow/Block buttons (default: true; needs a session D-Bus)
+# ask_notifications = true
+
 # Filter rules (evaluated in order, first match wins)
 # Defaults: type = "glob", scope = "host"
 # [[proxy.filter.rules]]
@@ -1197,6 +1559,18 @@ port = 8080
 # action = "block"
 # reason = "Tracking domain blocked"
 
+# Rules can also match the method, headers, and JSON body (requires MITM).
+# All matchers must match. A not_in body matcher also matches when the field
+# is missing or the body is not JSON.
+# [[proxy.filter.rules]]
+# pattern = "api.openai.com"
+# action = "block"
+# methods = ["POST"]
+# reason = "Model not approved"
+# [[proxy.filter.rules.body]]
+# path = "$.model"
+# not_in = ["gpt-4.1-mini"]
+
 # Log-skip rules drop matching requests from proxy logs entirely.
 # Use this for noisy traffic you don't want to see in logs/proxy/requests.jsonl
 # or in any configured remote log dispatcher. Skip is absolute: matched
@@ -1210,6 +1584,44 @@ port = 8080
 # scope = "url"
 # type = "glob"
 
+# Git push ref policy (requires proxy mode, and MITM for HTTPS remotes)
+# Inspects pushes over smart HTTP and refuses any that touch a ref outside
+# allow_refs. Protected refs may be created but never moved or deleted: the
+# proxy cannot tell a force push from a fast-forward, so it refuses both.
+# [proxy.git_push]
+# allow_refs = ["refs/heads/devsandbox/*"]
+# protected_refs = ["refs/heads/main", "refs/tags/**"]
+
+# Per-host traffic limits (requires proxy mode with MITM)
+# Every rule matching a host applies, with counters shared by all the hosts
+# it matches. A request over a limit gets HTTP 429, or a prompt in
+# "devsandbox proxy monitor" when on_exceed is "ask". The monitor also shows
+# the counters.
+# [[proxy.limits.rules]]
+# pattern = "*.openai.com"
+# requests_per_minute = 60
+# max_concurrent = 4
+# max_upload_bytes = 104857600   # 100 MiB of request bodies per session
+# on_exceed = "ask"
+
+# DNS resolver (requires proxy mode; bwrap backend only)
+# Answers the sandbox's lookups for names the filter would allow and returns
+# NXDOMAIN for the rest, so a tool that resolves before connecting fails at
+# once instead of timing out. Every query is logged as a proxy.dns.query event;
+# each also appears in "devsandbox logs proxy". A name only a path rule (or
+# another rule naming no host) could allow is refused unless
+# answer_hostless_rules is set.
+# [proxy.dns]
+# enabled = true
+# answer_hostless_rules = false
+
+# SOCKS5 listener for non-HTTP TCP (requires proxy mode; bwrap backend only)
+# Forwards the sandbox's 127.0.0.1:1080 to a listener that decides each
+# host:port with the filter rules (narrow a rule with ports = [5432]) and logs
+# every connection with its byte counts. ALL_PROXY is set to it.
+# [proxy.socks]
+# enabled = true
+
 # Credential injection (requires proxy mode)
 # Injects authentication tokens into outbound requests for specific domains.
 # Tokens are read from host environment and never exposed to the sandbox.
@@ -1238,6 +1650,12 @@ port = 8080
 # nothing about the rest. A project .devsandbox.toml may only lower this.
 # max_scan_bytes = 10485760
 
+# Largest response body - or line of a streamed response - the response scan
+# will hold (default: 10485760, 10 MiB). A response past this is replaced by a
+# 502 rather than passed on unscanned. A project .devsandbox.toml may only
+# lower this.
+# max_response_scan_bytes = 10485760
+
 # Source-based rule: detects exact secret value
 # [[proxy.redaction.rules]]
 # name = "api-secret"
@@ -1254,6 +1672,21 @@ port = 8080
 # action = "redact"
 # pattern = "sk-[a-zA-Z0-9]{32,}"
 
+# Response rules scan upstream responses before they reach the sandbox.
+# hosts limits a rule to matching hosts (empty = every host); strip_headers
+# drops whole headers. Responses with a known length are scanned whole;
+# streamed ones (event streams, chunked bodies) line by line as they flow.
+# [[proxy.redaction.response_rules]]
+# name = "metadata-creds"
+# hosts = ["169.254.169.254"]
+# action = "redact"
+# pattern = "\"SecretAccessKey\"\\s*:\\s*\"[^\"]+\""
+#
+# [[proxy.redaction.response_rules]]
+# name = "no-cookies"
+# hosts = ["*.example.com"]
+# strip_headers = ["Set-Cookie"]
+
 # Sandbox settings
 [sandbox]
 # Base directory for sandbox homes
@@ -1343,7 +1776,7 @@ port = 8080
 #
 # On bwrap (the default backend on Linux) the limits are applied with a systemd
 # transient scope, which needs cgroup v2 and a systemd user manager with the
-# memory/cpu/pids controllers delegated to user@<uid>.service. If a configured
+# memory/cpu/pids/io controllers delegated to user@<uid>.service. If a configured
 # limit cannot be enforced the launch is aborted with an error naming what is
 # missing, rather than running unlimited - so only set these once you want that
 # guarantee. pids is not enforceable on krun, which warns and skips it.
@@ -1357,6 +1790,17 @@ port = 8080
 # cpus = "2"
 # Maximum number of processes and threads.
 # pids = 2048
+# Soft memory limit: above it the sandbox is throttled and reclaimed instead of
+# OOM-killed. Not available on docker, which warns and skips it.
+# memory_high = "3g"
+# Swap allowed on top of memory; "0" disables swap. Requires memory.
+# memory_swap = "1g"
+# Disk bandwidth caps in bytes per second, applied to the disks holding the
+# project and the sandbox state, and the relative disk priority (1-10000,
+# default 100). These need the io controller delegated as well.
+# io_read_bps = "200m"
+# io_write_bps = "100m"
+# io_weight = 50
 
 # Overlay filesystem settings (global)
 [overlay]
@@ -1454,6 +1898,25 @@ ignore_global_config = false
 # batch_size = 100
 # flush_interval = "5s"
 
+# Example: Local JSONL files, rotated by size and gzip-archived
+# [[logging.receivers]]
+# type = "file"
+# path = "~/.local/state/devsandbox/audit"
+# max_file_bytes = 52428800  # 50 MiB (default)
+# max_files = 5              # active + archived (default)
+
+# Example: HTTP webhook (JSON array of records per POST)
+# [[logging.receivers]]
+# type = "webhook"
+# endpoint = "https://logs.example.com/ingest"
+# # [logging.receivers.header_sources.Authorization]
+# # env = "WEBHOOK_TOKEN"
+# batch_size = 100
+# flush_interval = "5s"
+# max_retries = 3              # then the batch goes to the spool
+# spool_max_bytes = 10485760   # 10 MiB, oldest batches dropped first
+# # spool_dir defaults to a per-endpoint directory next to logging-errors.log
+
 # Port forwarding (requires network isolation)
 # Forward TCP/UDP ports between host and sandbox.
 # Requires proxy mode or pasta for network isolation.
@@ -1595,7 +2058,7 @@ func applyIncludes(cfg *Config, projectDir string) (*Config, error) {
 	}
 	for _, inc := range matching {
 		includePath := expandHome(inc.Path)
-		includeCfg, err := loadIncludeFile(includePath)
+		includeCfg, keys, err := loadIncludeFile(includePath)
 		if err != nil {
 			if errors.Is(err, os.ErrNotExist) {
 				notice.Warn("include file %s not found, skipping", includePath)
@@ -1603,31 +2066,36 @@ func applyIncludes(cfg *Config, projectDir string) (*Config, error) {
 			}
 			return nil, fmt.Errorf("failed to load include %s: %w", includePath, err)
 		}
+		if err := checkSandboxKeyedInclude(inc, keys); err != nil {
+			return nil, err
+		}
 		cfg = mergeConfigs(cfg, includeCfg)
 	}
 	return cfg, nil
 }
 
-// loadIncludeFile loads a config file for inclusion.
+// loadIncludeFile loads a config file for inclusion, along with the keys the
+// file sets.
 // Returns error if file doesn't exist or has parse errors.
-func loadIncludeFile(path string) (*Config, error) {
+func loadIncludeFile(path string) (*Config, []toml.Key, error) {
 	data, err := os.ReadFile(path)
 	if os.IsNotExist(err) {
-		return nil, fmt.Errorf("file not found: %w", err)
+		return nil, nil, fmt.Errorf("file not found: %w", err)
 	}
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	cfg := &Config{}
-	if err := toml.Unmarshal(data, cfg); err != nil {
-		return nil, fmt.Errorf("parse error: %w", err)
+	md, err := toml.Decode(string(data), cfg)
+	if err != nil {
+		return nil, nil, fmt.Errorf("parse error: %w", err)
 	}
 	reportUnknownKeys(path, data)
 
 	// Validate included config
 	if err := cfg.Validate(); err != nil {
-		return nil, fmt.Errorf("validation error: %w", err)
+		return nil, nil, fmt.Errorf("validation error: %w", err)
 	}
 
 	// Warn if include file has nested includes
@@ -1636,7 +2104,7 @@ func loadIncludeFile(path string) (*Config, error) {
 		cfg.Include = nil
 	}
 
-	return cfg, nil
+	return cfg, md.Keys(), nil
 }
 
 // loadLocalConfig loads and validates the local .devsandbox.toml file.
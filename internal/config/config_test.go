@@ -407,7 +407,7 @@ func assertToolKeys(t *testing.T, toolsCfg map[string]any) {
 		"portal": {"notifications", "mount_mode"},
 		"kitty":  {"mode", "extra_capabilities", "mount_mode"},
 		"herdr":  {"mode", "mount_mode"},
-		"zellij": {"enabled", "mount_mode"},
+		"zellij": {"enabled", "mode", "commands", "extra_capabilities", "mount_mode"},
 	}
 
 	for tool, raw := range toolsCfg {
@@ -633,6 +633,153 @@ func TestValidate(t *testing.T) {
 			wantErr: true,
 			errMsg:  "proxy.log_skip.rules[0].type must be",
 		},
+		{
+			name: "valid filter request matchers",
+			cfg: &Config{
+				Proxy: ProxyConfig{
+					Filter: ProxyFilterConfig{
+						DefaultAction: "allow",
+						Rules: []ProxyFilterRule{{
+							Pattern: "api.openai.com",
+							Action:  "block",
+							Methods: []string{"POST"},
+							Headers: map[string]string{"Content-Type": "application/json*"},
+							Body:    []ProxyFilterBodyMatch{{Path: "$.model", NotIn: []string{"gpt-4.1"}}},
+						}},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "filter body matcher without path",
+			cfg: &Config{
+				Proxy: ProxyConfig{
+					Filter: ProxyFilterConfig{
+						Rules: []ProxyFilterRule{{
+							Pattern: "x", Action: "block",
+							Body: []ProxyFilterBodyMatch{{In: []string{"a"}}},
+						}},
+					},
+				},
+			},
+			wantErr: true,
+			errMsg:  "proxy.filter.rules[0].body[0].path cannot be empty",
+		},
+		{
+			name: "filter body matcher with two conditions",
+			cfg: &Config{
+				Proxy: ProxyConfig{
+					Filter: ProxyFilterConfig{
+						Rules: []ProxyFilterRule{{
+							Pattern: "x", Action: "block",
+							Body: []ProxyFilterBodyMatch{{Path: "$.a", In: []string{"a"}, Pattern: "b*"}},
+						}},
+					},
+				},
+			},
+			wantErr: true,
+			errMsg:  "set at most one of in, not_in, and pattern",
+		},
+		{
+			name: "filter empty method",
+			cfg: &Config{
+				Proxy: ProxyConfig{
+					Filter: ProxyFilterConfig{
+						Rules: []ProxyFilterRule{{Pattern: "x", Action: "block", Methods: []string{""}}},
+					},
+				},
+			},
+			wantErr: true,
+			errMsg:  "proxy.filter.rules[0].methods[0] cannot be empty",
+		},
+		{
+			name: "filter rule port out of range",
+			cfg: &Config{
+				Proxy: ProxyConfig{
+					Filter: ProxyFilterConfig{
+						Rules: []ProxyFilterRule{{Pattern: "db.example.com", Action: "allow", Ports: []int{5432, 70000}}},
+					},
+				},
+			},
+			wantErr: true,
+			errMsg:  "proxy.filter.rules[0].ports[1] must be between 1 and 65535, got 70000",
+		},
+		{
+			name: "valid git_push patterns",
+			cfg: &Config{
+				Proxy: ProxyConfig{
+					GitPush: ProxyGitPushConfig{
+						AllowRefs:     []string{"refs/heads/devsandbox/*"},
+						ProtectedRefs: []string{"refs/heads/main", "refs/tags/**"},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "git_push empty allow pattern",
+			cfg: &Config{
+				Proxy: ProxyConfig{
+					GitPush: ProxyGitPushConfig{AllowRefs: []string{""}},
+				},
+			},
+			wantErr: true,
+			errMsg:  "proxy.git_push.allow_refs[0]: pattern cannot be empty",
+		},
+		{
+			name: "git_push invalid protected glob",
+			cfg: &Config{
+				Proxy: ProxyConfig{
+					GitPush: ProxyGitPushConfig{ProtectedRefs: []string{"refs/heads/[main"}},
+				},
+			},
+			wantErr: true,
+			errMsg:  "proxy.git_push.protected_refs[0]: invalid glob pattern",
+		},
+		{
+			name: "valid limit rule",
+			cfg: &Config{
+				Proxy: ProxyConfig{
+					Limits: ProxyLimitsConfig{Rules: []ProxyLimitRule{
+						{Pattern: "*.openai.com", RequestsPerMinute: 60, OnExceed: "ask"},
+					}},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "limit rule without limits",
+			cfg: &Config{
+				Proxy: ProxyConfig{
+					Limits: ProxyLimitsConfig{Rules: []ProxyLimitRule{{Pattern: "example.com"}}},
+				},
+			},
+			wantErr: true,
+			errMsg:  "proxy.limits.rules[0]: set at least one of",
+		},
+		{
+			name: "limit rule negative limit",
+			cfg: &Config{
+				Proxy: ProxyConfig{
+					Limits: ProxyLimitsConfig{Rules: []ProxyLimitRule{{Pattern: "example.com", MaxConcurrent: -1}}},
+				},
+			},
+			wantErr: true,
+			errMsg:  "proxy.limits.rules[0]: limits cannot be negative",
+		},
+		{
+			name: "limit rule invalid on_exceed",
+			cfg: &Config{
+				Proxy: ProxyConfig{
+					Limits: ProxyLimitsConfig{Rules: []ProxyLimitRule{
+						{Pattern: "example.com", MaxConcurrent: 1, OnExceed: "allow"},
+					}},
+				},
+			},
+			wantErr: true,
+			errMsg:  "proxy.limits.rules[0].on_exceed must be 'block' or 'ask'",
+		},
 		{
 			name: "invalid isolation backend",
 			cfg: &Config{
@@ -817,6 +964,50 @@ func TestValidate(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "valid io and swap limits",
+			cfg: &Config{
+				Sandbox: SandboxConfig{
+					Resources: ResourcesConfig{
+						Memory: "4g", MemoryHigh: "3g", MemorySwap: "0",
+						IOReadBPS: "200m", IOWriteBPS: "100m", IOWeight: 50,
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "memory_swap without memory",
+			cfg: &Config{
+				Sandbox: SandboxConfig{Resources: ResourcesConfig{MemorySwap: "1g"}},
+			},
+			wantErr: true,
+			errMsg:  "needs memory to be set",
+		},
+		{
+			name: "invalid memory_high",
+			cfg: &Config{
+				Sandbox: SandboxConfig{Resources: ResourcesConfig{MemoryHigh: "3gb"}},
+			},
+			wantErr: true,
+			errMsg:  "memory_high",
+		},
+		{
+			name: "zero io bandwidth",
+			cfg: &Config{
+				Sandbox: SandboxConfig{Resources: ResourcesConfig{IOWriteBPS: "0"}},
+			},
+			wantErr: true,
+			errMsg:  "zero cap",
+		},
+		{
+			name: "io weight out of range",
+			cfg: &Config{
+				Sandbox: SandboxConfig{Resources: ResourcesConfig{IOWeight: 20000}},
+			},
+			wantErr: true,
+			errMsg:  "invalid io weight",
+		},
 		{
 			name: "valid otlp header_sources",
 			cfg: &Config{
@@ -870,6 +1061,73 @@ func TestValidate(t *testing.T) {
 			wantErr: true,
 			errMsg:  "header name cannot be empty",
 		},
+		{
+			name: "valid file receiver",
+			cfg: &Config{
+				Logging: LoggingConfig{
+					Receivers: []ReceiverConfig{{Type: "file", Path: "~/.local/state/devsandbox/audit", MaxFiles: 10}},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "file receiver without path",
+			cfg: &Config{
+				Logging: LoggingConfig{
+					Receivers: []ReceiverConfig{{Type: "file"}},
+				},
+			},
+			wantErr: true,
+			errMsg:  "path is required",
+		},
+		{
+			name: "valid webhook receiver",
+			cfg: &Config{
+				Logging: LoggingConfig{
+					Receivers: []ReceiverConfig{
+						{
+							Type:       "webhook",
+							Endpoint:   "https://logs.example.com/ingest",
+							MaxRetries: new(0),
+							HeaderSources: map[string]source.Source{
+								"Authorization": {Env: "WEBHOOK_TOKEN"},
+							},
+						},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "webhook receiver without scheme",
+			cfg: &Config{
+				Logging: LoggingConfig{
+					Receivers: []ReceiverConfig{{Type: "webhook", Endpoint: "logs.example.com/ingest"}},
+				},
+			},
+			wantErr: true,
+			errMsg:  "http:// or https://",
+		},
+		{
+			name: "webhook receiver with negative max_retries",
+			cfg: &Config{
+				Logging: LoggingConfig{
+					Receivers: []ReceiverConfig{{Type: "webhook", Endpoint: "https://logs.example.com", MaxRetries: new(-1)}},
+				},
+			},
+			wantErr: true,
+			errMsg:  "max_retries cannot be negative",
+		},
+		{
+			name: "negative spool_max_bytes",
+			cfg: &Config{
+				Logging: LoggingConfig{
+					Receivers: []ReceiverConfig{{Type: "webhook", Endpoint: "https://logs.example.com", SpoolMaxBytes: -1}},
+				},
+			},
+			wantErr: true,
+			errMsg:  "cannot be negative",
+		},
 	}
 
 	for _, tt := range tests {
@@ -1243,6 +1501,16 @@ func TestSandboxConfig_ResolvedResources(t *testing.T) {
 			},
 			expected: ResourcesConfig{Memory: "8g", CPUs: "0.5"},
 		},
+		{
+			name: "io and swap fields come from the new section",
+			sandbox: SandboxConfig{
+				Docker: DockerConfig{
+					Resources: DockerResourcesConfig{Memory: "512m"},
+				},
+				Resources: ResourcesConfig{MemorySwap: "0", IOWriteBPS: "50m", IOWeight: 50},
+			},
+			expected: ResourcesConfig{Memory: "512m", MemorySwap: "0", IOWriteBPS: "50m", IOWeight: 50},
+		},
 	}
 
 	for _, tt := range tests {
@@ -1644,6 +1912,53 @@ func TestApplyIncludes_MissingFileWarns(t *testing.T) {
 	}
 }
 
+func TestApplyIncludes_SandboxKeyed(t *testing.T) {
+	projectDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(projectDir, ".tool-versions"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	write := func(body string) string {
+		t.Helper()
+		p := filepath.Join(t.TempDir(), "inc.toml")
+		if err := os.WriteFile(p, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	creds := write("[proxy.credentials.github]\nenabled = true\n")
+	filter := write("[[proxy.filter.rules]]\npattern = \"*.acme.com\"\naction = \"allow\"\n")
+	docker := write("[tools.docker]\nenabled = true\n")
+	limits := write("[sandbox.resources]\nmemory = \"64g\"\n")
+	attrs := write("[logging.attributes]\nteam = \"acme\"\n")
+	mixed := write("[logging]\nlog_filter_decisions = true\n[logging.attributes]\nteam = \"acme\"\n")
+
+	tests := []struct {
+		name    string
+		inc     Include
+		wantErr string
+	}{
+		{"credentials keyed on exists", Include{If: "exists:.tool-versions", Path: creds}, "proxy.credentials.github.enabled"},
+		{"filter keyed on exists", Include{Any: []string{"host:none-*", "exists:.tool-versions"}, Path: filter}, "proxy.filter.rules.pattern"},
+		{"tool keyed on exists", Include{If: "exists:.tool-versions", Path: docker}, "tools.docker.enabled"},
+		{"limits keyed on exists", Include{If: "exists:.tool-versions", Path: limits}, "sandbox.resources.memory"},
+		{"allowed table beside a refused key", Include{If: "exists:.tool-versions", Path: mixed}, "logging.log_filter_decisions"},
+		{"bounded by dir still refused", Include{All: []string{"dir:" + projectDir, "exists:.tool-versions"}, Path: creds}, "exists:.tool-versions"},
+		{"credentials keyed on dir", Include{If: "dir:" + projectDir, Path: creds}, ""},
+		{"log attributes keyed on exists", Include{If: "exists:.tool-versions", Path: attrs}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := applyIncludes(&Config{Include: []Include{tt.inc}}, projectDir)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("err = %v, want one naming %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
 func TestMergeConfigs_RedactionEnabledCannotBeDisabledByOverlay(t *testing.T) {
 	base := &Config{}
 	base.Proxy.Redaction.Enabled = new(true)
@@ -2159,6 +2474,74 @@ func TestMergeProjectConfig_RedactionScanLimitOnlyTightens(t *testing.T) {
 	}
 }
 
+// TestMergeProjectConfig_ResponseScanLimitOnlyTightens applies the same
+// policy to the separate response scan budget.
+func TestMergeProjectConfig_ResponseScanLimitOnlyTightens(t *testing.T) {
+	tests := []struct {
+		name          string
+		base, overlay int
+		want          int
+	}{
+		{"overlay tightens", 4096, 1024, 1024},
+		{"overlay widens", 1024, 4096, 1024},
+		{"overlay widens past default", 0, DefaultMaxResponseRedactionScanBytes * 2, 0},
+		{"overlay unset", 2048, 0, 2048},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base := &Config{}
+			base.Proxy.Redaction.MaxResponseScanBytes = tt.base
+			overlay := &Config{}
+			overlay.Proxy.Redaction.MaxResponseScanBytes = tt.overlay
+
+			got := mergeProjectConfig(base, overlay).Proxy.Redaction.MaxResponseScanBytes
+			if got != tt.want {
+				t.Errorf("merged max_response_scan_bytes = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestValidate_ResponseRedactionRules covers the shapes a response rule may
+// and may not take.
+func TestValidate_ResponseRedactionRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    ProxyResponseRedactionRule
+		wantErr string
+	}{
+		{"strip only", ProxyResponseRedactionRule{StripHeaders: []string{"Set-Cookie"}}, ""},
+		{"pattern with hosts", ProxyResponseRedactionRule{Hosts: []string{"169.254.169.254"}, Pattern: "AKIA"}, ""},
+		{"nothing to do", ProxyResponseRedactionRule{Hosts: []string{"example.com"}}, "one of source, pattern, or strip_headers"},
+		{"action without content", ProxyResponseRedactionRule{StripHeaders: []string{"Set-Cookie"}, Action: "block"}, "action requires source or pattern"},
+		{"source and pattern", ProxyResponseRedactionRule{Pattern: "x", Source: &ProxyRedactionSource{Value: "y"}}, "mutually exclusive"},
+		{"empty host", ProxyResponseRedactionRule{Hosts: []string{""}, Pattern: "x"}, "empty pattern"},
+		{"bad action", ProxyResponseRedactionRule{Pattern: "x", Action: "drop"}, "action must be"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Config{}
+			c.Proxy.Redaction.ResponseRules = []ProxyResponseRedactionRule{tt.rule}
+			err := c.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), "proxy.redaction.response_rules[0]") || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Validate() = %v, want proxy.redaction.response_rules[0] error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	c := &Config{}
+	c.Proxy.Redaction.MaxResponseScanBytes = -1
+	if err := c.Validate(); err == nil {
+		t.Error("max_response_scan_bytes = -1 was accepted")
+	}
+}
+
 // TestMergeProjectConfig_LogBodyLimitOnlyTightens pins the same policy for the
 // request-log capture limit, which merged unconditionally beside it. 0 is the
 // value that matters: it records no bodies at all, so an unconditional merge
@@ -2230,3 +2613,48 @@ func TestMergeConfigs_TrustedOverlayMayRaiseLimits(t *testing.T) {
 		t.Errorf("project file raised max_log_body_bytes, merged = %d, want 1024", got)
 	}
 }
+
+func TestMergeConfigs_ProxyDNS(t *testing.T) {
+	on, off := true, false
+
+	base := &Config{}
+	base.Proxy.DNS.Enabled = &on
+
+	// An overlay that does not mention [proxy.dns] keeps the base setting.
+	if merged := mergeConfigs(base, &Config{}); merged.Proxy.DNS.Enabled == nil || !*merged.Proxy.DNS.Enabled {
+		t.Errorf("unset overlay dropped proxy.dns.enabled: %v", merged.Proxy.DNS.Enabled)
+	}
+
+	overlay := &Config{}
+	overlay.Proxy.DNS.Enabled = &off
+	if merged := mergeConfigs(base, overlay); merged.Proxy.DNS.Enabled == nil || *merged.Proxy.DNS.Enabled {
+		t.Errorf("overlay enabled = false not applied: %v", merged.Proxy.DNS.Enabled)
+	}
+
+	overlay = &Config{}
+	overlay.Proxy.DNS.AnswerHostlessRules = &on
+	merged := mergeConfigs(base, overlay)
+	if merged.Proxy.DNS.AnswerHostlessRules == nil || !*merged.Proxy.DNS.AnswerHostlessRules {
+		t.Errorf("overlay answer_hostless_rules = true not applied: %v", merged.Proxy.DNS.AnswerHostlessRules)
+	}
+	if merged.Proxy.DNS.Enabled == nil || !*merged.Proxy.DNS.Enabled {
+		t.Errorf("overlay setting answer_hostless_rules dropped proxy.dns.enabled: %v", merged.Proxy.DNS.Enabled)
+	}
+}
+
+func TestMergeConfigs_ProxySOCKS(t *testing.T) {
+	on, off := true, false
+
+	base := &Config{}
+	base.Proxy.SOCKS.Enabled = &on
+
+	if merged := mergeConfigs(base, &Config{}); merged.Proxy.SOCKS.Enabled == nil || !*merged.Proxy.SOCKS.Enabled {
+		t.Errorf("unset overlay dropped proxy.socks.enabled: %v", merged.Proxy.SOCKS.Enabled)
+	}
+
+	overlay := &Config{}
+	overlay.Proxy.SOCKS.Enabled = &off
+	if merged := mergeConfigs(base, overlay); merged.Proxy.SOCKS.Enabled == nil || *merged.Proxy.SOCKS.Enabled {
+		t.Errorf("overlay enabled = false not applied: %v", merged.Proxy.SOCKS.Enabled)
+	}
+}
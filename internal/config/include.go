@@ -2,38 +2,152 @@ package config
 
 import (
 	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
 	"path/filepath"
+	"slices"
 	"strings"
 
+	"github.com/BurntSushi/toml"
 	"github.com/bmatcuk/doublestar/v4"
 )
 
-// Include represents a conditional config include.
+// Include represents a conditional config include. It applies when if holds,
+// every condition in all holds, and at least one in any does; whichever of the
+// three are set.
+//
+// dir:, env: and host: conditions read state the sandbox cannot change.
+// git-remote: reads the project's .git/config and exists: looks at project
+// files, and the sandbox can write both, so it can make an include keyed on
+// them apply to any project. Such an include may set only the keys in
+// sandboxKeyedIncludeKeys; see checkSandboxKeyedInclude.
 type Include struct {
-	If   string `toml:"if"`   // e.g., "dir:~/work/**"
-	Path string `toml:"path"` // e.g., "~/.config/devsandbox/work.toml"
+	If   string   `toml:"if"`   // e.g., "dir:~/work/**"
+	All  []string `toml:"all"`  // e.g., ["git-remote:github.com/acme/**", "env:CI=true"]
+	Any  []string `toml:"any"`  // e.g., ["host:build-*", "exists:.tool-versions"]
+	Path string   `toml:"path"` // e.g., "~/.config/devsandbox/work.toml"
+}
+
+// includeConditionTypes are the condition prefixes parseIncludeCondition
+// accepts.
+var includeConditionTypes = []string{"dir", "git-remote", "env", "host", "exists"}
+
+// sandboxWritableConditionTypes are the condition types whose answer comes
+// from files inside the project, which the sandbox can write.
+var sandboxWritableConditionTypes = []string{"git-remote", "exists"}
+
+// sandboxKeyedIncludeKeys are the config tables an include selected by a
+// sandbox-writable condition may set. It is an allowlist because nearly every
+// other setting widens what the sandbox reaches or where its data goes -
+// proxy credentials, filtering and redaction, mounts, git modes, resource
+// limits, port forwarding, log receivers, tools such as the docker socket - and
+// a section added later must not become settable by a sandbox by default.
+var sandboxKeyedIncludeKeys = []string{"logging.attributes"}
+
+// sandboxKeyedConditions returns the conditions of inc the sandbox can make
+// hold.
+func (inc Include) sandboxKeyedConditions() []string {
+	var out []string
+	for _, cond := range append(append([]string{inc.If}, inc.All...), inc.Any...) {
+		condType, _, _ := strings.Cut(cond, ":")
+		if slices.Contains(sandboxWritableConditionTypes, condType) {
+			out = append(out, cond)
+		}
+	}
+	return out
+}
+
+// checkSandboxKeyedInclude refuses an include that is selected by a condition
+// the sandbox can make hold and that sets any key outside
+// sandboxKeyedIncludeKeys. Otherwise a sandbox could add a remote to
+// .git/config, or create a file, and have the next session inject another
+// project's credentials into its requests, run under a looser filter, or mount
+// what that project mounts. keys are the keys the include file sets.
+func checkSandboxKeyedInclude(inc Include, keys []toml.Key) error {
+	conds := inc.sandboxKeyedConditions()
+	if len(conds) == 0 {
+		return nil
+	}
+	var refused []string
+	for _, key := range keys {
+		if !sandboxKeyedKeyAllowed(key) {
+			refused = append(refused, key.String())
+		}
+	}
+	// Report only the innermost keys: a table is refused whenever a key in it
+	// is, and naming both says the same thing twice.
+	var leaves []string
+	for _, k := range refused {
+		if !slices.ContainsFunc(refused, func(other string) bool { return strings.HasPrefix(other, k+".") }) {
+			leaves = append(leaves, k)
+		}
+	}
+	if len(leaves) == 0 {
+		return nil
+	}
+	return fmt.Errorf("include %s sets %s but is selected by %s, which the sandbox can change by writing to the project; such an include may set only %s, so select it with dir:, env: or host: conditions only",
+		inc.Path, strings.Join(leaves, ", "), strings.Join(conds, ", "), strings.Join(sandboxKeyedIncludeKeys, ", "))
+}
+
+// sandboxKeyedKeyAllowed reports whether key is at or beneath an entry of
+// sandboxKeyedIncludeKeys, or is a table enclosing one.
+func sandboxKeyedKeyAllowed(key toml.Key) bool {
+	for _, allowed := range sandboxKeyedIncludeKeys {
+		path := strings.Split(allowed, ".")
+		n := min(len(key), len(path))
+		if slices.Equal(key[:n], path[:n]) {
+			return true
+		}
+	}
+	return false
 }
 
 // parseIncludeCondition parses an include condition string.
 // Returns the condition type and value.
-// Currently only "dir:" type is supported.
 func parseIncludeCondition(condition string) (condType, value string, err error) {
 	if !strings.Contains(condition, ":") {
-		return "", "", fmt.Errorf("invalid include condition %q: missing type prefix (expected 'dir:')", condition)
+		return "", "", fmt.Errorf("invalid include condition %q: missing type prefix (expected one of %s)", condition, strings.Join(includeConditionTypes, ":, ")+":")
 	}
 
 	parts := strings.SplitN(condition, ":", 2)
 	condType = parts[0]
 	value = parts[1]
 
-	if condType != "dir" {
-		return "", "", fmt.Errorf("unknown include condition type %q (only 'dir' is supported)", condType)
+	known := false
+	for _, t := range includeConditionTypes {
+		if condType == t {
+			known = true
+			break
+		}
+	}
+	if !known {
+		return "", "", fmt.Errorf("unknown include condition type %q (supported: %s)", condType, strings.Join(includeConditionTypes, ", "))
 	}
 
 	if value == "" {
 		return "", "", fmt.Errorf("empty value in include condition %q", condition)
 	}
 
+	// Patterns are checked here rather than on first use, so a typo fails the
+	// load even when an earlier condition already decided the include.
+	var pattern string
+	switch condType {
+	case "git-remote", "host":
+		pattern = value
+	case "env":
+		name, val, hasVal := strings.Cut(value, "=")
+		if name == "" {
+			return "", "", fmt.Errorf("missing variable name in include condition %q", condition)
+		}
+		if hasVal {
+			pattern = val
+		}
+	}
+	if pattern != "" && !doublestar.ValidatePattern(pattern) {
+		return "", "", fmt.Errorf("invalid glob pattern %q in include condition %q", pattern, condition)
+	}
+
 	return condType, value, nil
 }
 
@@ -61,18 +175,257 @@ func matchDirPattern(pattern, projectDir string) (bool, error) {
 	return matched, nil
 }
 
-// getMatchingIncludes returns all includes that match the given project directory.
-// It validates all conditions and returns an error if any condition is invalid.
-func getMatchingIncludes(includes []Include, projectDir string) ([]Include, error) {
-	var matched []Include
+// IncludeResult is how an include's conditions evaluated for a project.
+type IncludeResult struct {
+	Include    Include
+	Matched    bool
+	Conditions []ConditionResult
+}
 
+// ConditionResult is one evaluated include condition and why it held or not.
+type ConditionResult struct {
+	Group     string // "if", "all" or "any"
+	Condition string
+	Matched   bool
+	Reason    string // e.g., "remote origin is github.com/acme/app"
+}
+
+// includeContext is what conditions are evaluated against. The lookups are
+// fields so tests can stand in for the host.
+type includeContext struct {
+	projectDir string
+	lookupEnv  func(string) (string, bool)
+	hostname   func() (string, error)
+	remotes    func(dir string) ([]gitRemote, error)
+
+	// Remotes are read at most once per evaluation.
+	cachedRemotes []gitRemote
+	remotesErr    error
+	remotesRead   bool
+}
+
+func newIncludeContext(projectDir string) *includeContext {
+	return &includeContext{
+		projectDir: projectDir,
+		lookupEnv:  os.LookupEnv,
+		hostname:   os.Hostname,
+		remotes:    readGitRemotes,
+	}
+}
+
+// EvaluateIncludes evaluates every include against projectDir. Every
+// condition is evaluated, even once the outcome is settled, so the results
+// explain each one. An invalid condition anywhere is an error.
+func EvaluateIncludes(includes []Include, projectDir string) ([]IncludeResult, error) {
+	return newIncludeContext(projectDir).evaluate(includes)
+}
+
+func (c *includeContext) evaluate(includes []Include) ([]IncludeResult, error) {
+	results := make([]IncludeResult, 0, len(includes))
 	for _, inc := range includes {
-		match, err := matchDirPattern(inc.If, projectDir)
+		if inc.If == "" && len(inc.All) == 0 && len(inc.Any) == 0 {
+			return nil, fmt.Errorf("include %q has no condition (set if, all or any)", inc.Path)
+		}
+		res := IncludeResult{Include: inc, Matched: true}
+		add := func(group, cond string) error {
+			matched, reason, err := c.match(cond)
+			if err != nil {
+				return err
+			}
+			res.Conditions = append(res.Conditions, ConditionResult{Group: group, Condition: cond, Matched: matched, Reason: reason})
+			return nil
+		}
+
+		if inc.If != "" {
+			if err := add("if", inc.If); err != nil {
+				return nil, err
+			}
+		}
+		for _, cond := range inc.All {
+			if err := add("all", cond); err != nil {
+				return nil, err
+			}
+		}
+		anyMatched := len(inc.Any) == 0
+		for _, cond := range inc.Any {
+			if err := add("any", cond); err != nil {
+				return nil, err
+			}
+			anyMatched = anyMatched || res.Conditions[len(res.Conditions)-1].Matched
+		}
+
+		for _, cr := range res.Conditions {
+			if cr.Group != "any" && !cr.Matched {
+				res.Matched = false
+			}
+		}
+		res.Matched = res.Matched && anyMatched
+		results = append(results, res)
+	}
+	return results, nil
+}
+
+// match evaluates a single condition.
+func (c *includeContext) match(condition string) (bool, string, error) {
+	condType, value, err := parseIncludeCondition(condition)
+	if err != nil {
+		return false, "", err
+	}
+
+	switch condType {
+	case "dir":
+		matched, err := matchDirPattern(condition, c.projectDir)
 		if err != nil {
-			return nil, err
+			return false, "", err
+		}
+		return matched, "project is " + filepath.Clean(c.projectDir), nil
+
+	case "git-remote":
+		remotes, err := c.gitRemotes()
+		if err != nil {
+			return false, "not a git repository or git is unavailable", nil
+		}
+		if len(remotes) == 0 {
+			return false, "the repository has no remotes", nil
+		}
+		var seen []string
+		for _, r := range remotes {
+			if ok, _ := doublestar.Match(value, r.Normalized); ok {
+				return true, fmt.Sprintf("remote %s is %s", r.Name, r.Normalized), nil
+			}
+			seen = append(seen, r.Name+" is "+r.Normalized)
+		}
+		return false, "no remote matches (" + strings.Join(seen, ", ") + ")", nil
+
+	case "env":
+		name, want, hasWant := strings.Cut(value, "=")
+		got, set := c.lookupEnv(name)
+		switch {
+		case !hasWant && set && got != "":
+			return true, name + " is set", nil
+		case !set:
+			return false, name + " is not set", nil
+		case !hasWant:
+			return false, name + " is empty", nil
 		}
-		if match {
-			matched = append(matched, inc)
+		// The value itself stays out of the reason: it may be a secret.
+		if ok, _ := doublestar.Match(want, got); ok {
+			return true, name + " matches " + want, nil
+		}
+		return false, name + " is set but does not match " + want, nil
+
+	case "host":
+		host, err := c.hostname()
+		if err != nil {
+			return false, "", fmt.Errorf("include condition %q: %w", condition, err)
+		}
+		// Hostnames are case-insensitive.
+		ok, _ := doublestar.Match(strings.ToLower(value), strings.ToLower(host))
+		return ok, "host is " + host, nil
+
+	case "exists":
+		path := expandHome(value)
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(c.projectDir, path)
+		}
+		matches, err := doublestar.FilepathGlob(path)
+		if err != nil {
+			return false, "", fmt.Errorf("invalid glob pattern %q: %w", value, err)
+		}
+		if len(matches) == 0 {
+			return false, path + " does not exist", nil
+		}
+		return true, matches[0] + " exists", nil
+	}
+	panic(fmt.Sprintf("unhandled include condition type %q", condType))
+}
+
+func (c *includeContext) gitRemotes() ([]gitRemote, error) {
+	if !c.remotesRead {
+		c.cachedRemotes, c.remotesErr = c.remotes(c.projectDir)
+		c.remotesRead = true
+	}
+	return c.cachedRemotes, c.remotesErr
+}
+
+// gitRemote is a configured remote of the project's repository.
+type gitRemote struct {
+	Name       string
+	URL        string
+	Normalized string // host/path form git-remote: patterns match against
+}
+
+// readGitRemotes lists the remotes of the repository containing dir. Only
+// the configuration is read; nothing in the repository runs.
+func readGitRemotes(dir string) ([]gitRemote, error) {
+	// --local fails outside a repository, where plain `git config` would
+	// answer from the user's global config instead. include.path directives
+	// are not followed: .git/config is sandbox-writable, and they would have
+	// git read whatever host file it names.
+	cmd := exec.Command("git", "-C", dir, "config", "--local", "--no-includes", "--get-regexp", `^remote\..*\.url$`)
+	out, err := cmd.Output()
+	if err != nil {
+		// Exit status 1 is "no matching keys": a repository without remotes.
+		if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var remotes []gitRemote
+	for line := range strings.SplitSeq(strings.TrimSpace(string(out)), "\n") {
+		key, rawURL, ok := strings.Cut(line, " ")
+		if !ok {
+			continue
+		}
+		name := strings.TrimSuffix(strings.TrimPrefix(key, "remote."), ".url")
+		remotes = append(remotes, gitRemote{Name: name, URL: rawURL, Normalized: normalizeRemoteURL(rawURL)})
+	}
+	return remotes, nil
+}
+
+// normalizeRemoteURL reduces the URL forms git accepts to host/path, so that
+// https://github.com/acme/app.git, ssh://git@github.com:22/acme/app and
+// git@github.com:acme/app all read github.com/acme/app. A local path is
+// returned cleaned.
+func normalizeRemoteURL(raw string) string {
+	s := strings.TrimSpace(raw)
+	var host, path string
+	if strings.Contains(s, "://") {
+		u, err := url.Parse(s)
+		if err != nil || u.Host == "" {
+			// file:// URLs, and anything url.Parse rejects, name paths.
+			if err == nil {
+				s = u.Path
+			}
+			return filepath.Clean(s)
+		}
+		host, path = u.Hostname(), u.Path
+	} else if i := strings.Index(s, ":"); i > 0 && !strings.Contains(s[:i], "/") {
+		// scp-like syntax, [user@]host:path. A colon after a slash is part
+		// of a local path, as git reads it.
+		host, path = s[:i], s[i+1:]
+		if at := strings.LastIndex(host, "@"); at >= 0 {
+			host = host[at+1:]
+		}
+	} else {
+		return filepath.Clean(s)
+	}
+	path = strings.TrimSuffix(strings.Trim(path, "/"), ".git")
+	return strings.ToLower(host) + "/" + path
+}
+
+// getMatchingIncludes returns all includes that match the given project directory.
+// It validates all conditions and returns an error if any condition is invalid.
+func getMatchingIncludes(includes []Include, projectDir string) ([]Include, error) {
+	results, err := EvaluateIncludes(includes, projectDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var matched []Include
+	for _, r := range results {
+		if r.Matched {
+			matched = append(matched, r.Include)
 		}
 	}
 
@@ -504,7 +504,7 @@ func TestLoadIncludeFile_WarnsAboutUnknownKeys(t *testing.T) {
 
 	stderr := captureUnknownKeyWarnings(t)
 
-	if _, err := loadIncludeFile(path); err != nil {
+	if _, _, err := loadIncludeFile(path); err != nil {
 		t.Fatalf("loadIncludeFile: %v", err)
 	}
 	if !strings.Contains(stderr.String(), "sandbox.bogus") {
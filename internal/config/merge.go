@@ -58,6 +58,9 @@ func mergeConfigs(base, overlay *Config) *Config {
 	if overlay.Proxy.Filter.CacheDecisions != nil {
 		result.Proxy.Filter.CacheDecisions = overlay.Proxy.Filter.CacheDecisions
 	}
+	if overlay.Proxy.Filter.AskNotifications != nil {
+		result.Proxy.Filter.AskNotifications = overlay.Proxy.Filter.AskNotifications
+	}
 
 	// Rules: prepend overlay rules (higher priority)
 	if len(overlay.Proxy.Filter.Rules) > 0 {
@@ -83,7 +86,16 @@ func mergeConfigs(base, overlay *Config) *Config {
 	if overlay.Proxy.Redaction.MaxScanBytes > 0 {
 		result.Proxy.Redaction.MaxScanBytes = overlay.Proxy.Redaction.MaxScanBytes
 	}
+	if overlay.Proxy.Redaction.MaxResponseScanBytes > 0 {
+		result.Proxy.Redaction.MaxResponseScanBytes = overlay.Proxy.Redaction.MaxResponseScanBytes
+	}
 	// Rules are always additive (overlay prepends for higher priority)
+	if len(overlay.Proxy.Redaction.ResponseRules) > 0 {
+		result.Proxy.Redaction.ResponseRules = append(
+			overlay.Proxy.Redaction.ResponseRules,
+			result.Proxy.Redaction.ResponseRules...,
+		)
+	}
 	if len(overlay.Proxy.Redaction.Rules) > 0 {
 		result.Proxy.Redaction.Rules = append(
 			overlay.Proxy.Redaction.Rules,
@@ -101,6 +113,40 @@ func mergeConfigs(base, overlay *Config) *Config {
 		)
 	}
 
+	// Proxy git_push: ref lists are additive.
+	if len(overlay.Proxy.GitPush.AllowRefs) > 0 {
+		result.Proxy.GitPush.AllowRefs = append(
+			overlay.Proxy.GitPush.AllowRefs,
+			result.Proxy.GitPush.AllowRefs...,
+		)
+	}
+	if len(overlay.Proxy.GitPush.ProtectedRefs) > 0 {
+		result.Proxy.GitPush.ProtectedRefs = append(
+			overlay.Proxy.GitPush.ProtectedRefs,
+			result.Proxy.GitPush.ProtectedRefs...,
+		)
+	}
+
+	// Proxy limits: rules are additive. Every matching rule applies, so order
+	// does not matter and an overlay can only add limits, never lift one.
+	if len(overlay.Proxy.Limits.Rules) > 0 {
+		result.Proxy.Limits.Rules = append(
+			overlay.Proxy.Limits.Rules,
+			result.Proxy.Limits.Rules...,
+		)
+	}
+
+	if overlay.Proxy.DNS.Enabled != nil {
+		result.Proxy.DNS.Enabled = overlay.Proxy.DNS.Enabled
+	}
+	if overlay.Proxy.DNS.AnswerHostlessRules != nil {
+		result.Proxy.DNS.AnswerHostlessRules = overlay.Proxy.DNS.AnswerHostlessRules
+	}
+
+	if overlay.Proxy.SOCKS.Enabled != nil {
+		result.Proxy.SOCKS.Enabled = overlay.Proxy.SOCKS.Enabled
+	}
+
 	// Sandbox settings
 	if overlay.Sandbox.BasePath != "" {
 		result.Sandbox.BasePath = overlay.Sandbox.BasePath
@@ -276,6 +322,9 @@ func mergeProjectConfig(base, local *Config) *Config {
 	if merged.Proxy.Redaction.GetMaxScanBytes() > base.Proxy.Redaction.GetMaxScanBytes() {
 		merged.Proxy.Redaction.MaxScanBytes = base.Proxy.Redaction.MaxScanBytes
 	}
+	if merged.Proxy.Redaction.GetMaxResponseScanBytes() > base.Proxy.Redaction.GetMaxResponseScanBytes() {
+		merged.Proxy.Redaction.MaxResponseScanBytes = base.Proxy.Redaction.MaxResponseScanBytes
+	}
 	return merged
 }
 
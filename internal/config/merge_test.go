@@ -129,6 +129,52 @@ func Test_mergeConfigs_LogSkipRulesPrepend(t *testing.T) {
 	}
 }
 
+func Test_mergeConfigs_GitPushRefsAdditive(t *testing.T) {
+	base := &Config{
+		Proxy: ProxyConfig{
+			GitPush: ProxyGitPushConfig{
+				AllowRefs:     []string{"refs/heads/base/*"},
+				ProtectedRefs: []string{"refs/heads/main"},
+			},
+		},
+	}
+	overlay := &Config{
+		Proxy: ProxyConfig{
+			GitPush: ProxyGitPushConfig{
+				ProtectedRefs: []string{"refs/tags/**"},
+			},
+		},
+	}
+
+	result := mergeConfigs(base, overlay)
+
+	if got := result.Proxy.GitPush.AllowRefs; len(got) != 1 || got[0] != "refs/heads/base/*" {
+		t.Errorf("AllowRefs = %v, want base list kept", got)
+	}
+	got := result.Proxy.GitPush.ProtectedRefs
+	if len(got) != 2 || got[0] != "refs/tags/**" || got[1] != "refs/heads/main" {
+		t.Errorf("ProtectedRefs = %v, want overlay then base", got)
+	}
+}
+
+func Test_mergeConfigs_LimitRulesAdditive(t *testing.T) {
+	base := &Config{
+		Proxy: ProxyConfig{
+			Limits: ProxyLimitsConfig{Rules: []ProxyLimitRule{{Pattern: "base.example.com", MaxConcurrent: 2}}},
+		},
+	}
+	overlay := &Config{
+		Proxy: ProxyConfig{
+			Limits: ProxyLimitsConfig{Rules: []ProxyLimitRule{{Pattern: "*", RequestsPerMinute: 1000}}},
+		},
+	}
+
+	got := mergeConfigs(base, overlay).Proxy.Limits.Rules
+	if len(got) != 2 || got[0].Pattern != "*" || got[1].Pattern != "base.example.com" {
+		t.Errorf("Rules = %+v, want overlay then base", got)
+	}
+}
+
 func Test_mergeConfigs_NilOverlay(t *testing.T) {
 	base := &Config{
 		Proxy: ProxyConfig{Port: 8080},
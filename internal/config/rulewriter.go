@@ -0,0 +1,180 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+
+	"devsandbox/internal/fsutil"
+)
+
+// ErrLocalConfigUntrusted reports a .devsandbox.toml whose content is not what
+// was last trusted. AddLocalFilterRule refuses to write to one: trusting the
+// result would approve the unreviewed content along with the rule.
+var ErrLocalConfigUntrusted = errors.New("not trusted as it stands")
+
+// filterRulesHeaderRE matches the header line of a [[proxy.filter.rules]] table.
+var filterRulesHeaderRE = regexp.MustCompile(`^\s*\[\[\s*proxy\s*\.\s*filter\s*\.\s*rules\s*\]\]\s*(#.*)?$`)
+
+// AddFilterRule writes rule into the config file at path as a
+// [[proxy.filter.rules]] table, creating the file if missing. The rest of the
+// file is kept byte for byte, comments included. comment, when set, is
+// written as a comment line above the table.
+//
+// Rules are first-match-wins, so where the table lands decides what it
+// overrides. It goes ahead of the file's first ask rule - a decision made at a
+// prompt must be reached before the rule that prompted, or it never applies -
+// and after the allow and block rules above that one. With no ask rule in the
+// file it is appended.
+func AddFilterRule(path string, rule ProxyFilterRule, comment string) error {
+	raw, err := fsutil.ReadRegularFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	content, err := insertFilterRule(raw, rule, comment)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return fsutil.ReplaceRegularFile(path, content)
+}
+
+// AddLocalFilterRule is AddFilterRule for projectDir's .devsandbox.toml,
+// re-trusting the file afterwards so the next launch does not prompt for the
+// change devsandbox made itself.
+//
+// The file must be trusted as it stands before the write. The project
+// directory is writable from the sandbox, and re-trusting whatever the file
+// held plus the new rule would approve any change the sandbox made to it
+// without anyone seeing it.
+func AddLocalFilterRule(projectDir string, rule ProxyFilterRule, comment string, store *TrustStore) error {
+	path := filepath.Join(projectDir, LocalConfigFile)
+	raw, err := fsutil.ReadRegularFile(path)
+	switch {
+	case os.IsNotExist(err):
+		// Created below; its whole content is the rule.
+	case err != nil:
+		return err
+	default:
+		trusted := store.GetTrusted(projectDir)
+		if trusted == nil || trusted.Hash != hashBytes(raw) {
+			return fmt.Errorf("%s: %w (review it and run 'devsandbox trust add')", path, ErrLocalConfigUntrusted)
+		}
+	}
+
+	content, err := insertFilterRule(raw, rule, comment)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	if err := fsutil.ReplaceRegularFile(path, content); err != nil {
+		return err
+	}
+
+	// Trust is recorded against the bytes written, not re-read from disk: what
+	// lands there after the rename is not ours to approve.
+	store.AddTrust(projectDir, hashBytes(content))
+	if err := store.Save(); err != nil {
+		return fmt.Errorf("rule saved, but trust was not updated: %w (you will be asked to trust %s on next launch)", err, path)
+	}
+	return nil
+}
+
+// insertFilterRule returns raw with rule added as a [[proxy.filter.rules]]
+// table, placed as AddFilterRule describes. The result is parsed back, so a
+// file the edit would break - one declaring its rules inline, say - is an
+// error rather than a corrupted config.
+func insertFilterRule(raw []byte, rule ProxyFilterRule, comment string) ([]byte, error) {
+	if len(rule.Methods) > 0 || len(rule.Headers) > 0 || len(rule.Body) > 0 {
+		return nil, fmt.Errorf("rule %q: methods, headers and body cannot be written", rule.Pattern)
+	}
+
+	var before Config
+	if err := toml.Unmarshal(raw, &before); err != nil {
+		return nil, fmt.Errorf("failed to parse: %w", err)
+	}
+	pos := len(before.Proxy.Filter.Rules)
+	for i, r := range before.Proxy.Filter.Rules {
+		if r.Action == "ask" {
+			pos = i
+			break
+		}
+	}
+
+	block := renderFilterRule(rule, comment)
+	text := string(raw)
+	var out string
+	if pos == len(before.Proxy.Filter.Rules) {
+		if text != "" && !strings.HasSuffix(text, "\n") {
+			text += "\n"
+		}
+		if text != "" && !strings.HasSuffix(text, "\n\n") {
+			text += "\n"
+		}
+		out = text + block
+	} else {
+		lines := strings.SplitAfter(text, "\n")
+		at := -1
+		seen := 0
+		for i, line := range lines {
+			if filterRulesHeaderRE.MatchString(strings.TrimRight(line, "\r\n")) {
+				if seen == pos {
+					at = i
+					break
+				}
+				seen++
+			}
+		}
+		if at < 0 {
+			return nil, fmt.Errorf("cannot place the rule: proxy.filter.rules is not written as [[proxy.filter.rules]] tables")
+		}
+		// Comment lines directly above the ask rule describe it; keep them
+		// with it.
+		for at > 0 && strings.HasPrefix(strings.TrimSpace(lines[at-1]), "#") {
+			at--
+		}
+		out = strings.Join(lines[:at], "") + block + "\n" + strings.Join(lines[at:], "")
+	}
+
+	var after Config
+	if err := toml.Unmarshal([]byte(out), &after); err != nil {
+		return nil, fmt.Errorf("cannot add the rule: %w", err)
+	}
+	rules := after.Proxy.Filter.Rules
+	if len(rules) != len(before.Proxy.Filter.Rules)+1 || rules[pos].Pattern != rule.Pattern || rules[pos].Action != rule.Action {
+		return nil, fmt.Errorf("cannot add the rule: the edited file does not read back as written")
+	}
+	return []byte(out), nil
+}
+
+// renderFilterRule renders rule as a [[proxy.filter.rules]] table.
+func renderFilterRule(rule ProxyFilterRule, comment string) string {
+	var sb strings.Builder
+	if comment != "" {
+		fmt.Fprintf(&sb, "# %s\n", comment)
+	}
+	sb.WriteString("[[proxy.filter.rules]]\n")
+	fmt.Fprintf(&sb, "pattern = %q\n", rule.Pattern)
+	fmt.Fprintf(&sb, "action = %q\n", rule.Action)
+	if rule.Scope != "" {
+		fmt.Fprintf(&sb, "scope = %q\n", rule.Scope)
+	}
+	if rule.Type != "" {
+		fmt.Fprintf(&sb, "type = %q\n", rule.Type)
+	}
+	if len(rule.Ports) > 0 {
+		ports := make([]string, len(rule.Ports))
+		for i, p := range rule.Ports {
+			ports[i] = strconv.Itoa(p)
+		}
+		fmt.Fprintf(&sb, "ports = [%s]\n", strings.Join(ports, ", "))
+	}
+	if rule.Reason != "" {
+		fmt.Fprintf(&sb, "reason = %q\n", rule.Reason)
+	}
+	return sb.String()
+}
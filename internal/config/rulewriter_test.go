@@ -0,0 +1,157 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"devsandbox/internal/fsutil"
+)
+
+func TestInsertFilterRule(t *testing.T) {
+	rule := ProxyFilterRule{Pattern: "api.example.com", Action: "allow", Scope: "host", Type: "exact"}
+	block := `# saved
+[[proxy.filter.rules]]
+pattern = "api.example.com"
+action = "allow"
+scope = "host"
+type = "exact"
+`
+	tests := []struct {
+		name string
+		raw  string
+		want string
+	}{
+		{"empty file", "", block},
+		{
+			"appended without an ask rule",
+			"# project\n[proxy.filter]\ndefault_action = \"ask\"\n\n[[proxy.filter.rules]]\npattern = \"a.com\"\naction = \"block\"",
+			"# project\n[proxy.filter]\ndefault_action = \"ask\"\n\n[[proxy.filter.rules]]\npattern = \"a.com\"\naction = \"block\"\n\n" + block,
+		},
+		{
+			"ahead of the first ask rule and its comment",
+			`[[proxy.filter.rules]]
+pattern = "a.com"
+action = "block"
+
+# prompt for the API
+[[proxy.filter.rules]]
+pattern = "*.example.com"
+action = "ask"
+
+[[proxy.filter.rules]]
+pattern = "b.com"
+action = "ask"
+`,
+			`[[proxy.filter.rules]]
+pattern = "a.com"
+action = "block"
+
+` + block + `
+# prompt for the API
+[[proxy.filter.rules]]
+pattern = "*.example.com"
+action = "ask"
+
+[[proxy.filter.rules]]
+pattern = "b.com"
+action = "ask"
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := insertFilterRule([]byte(tt.raw), rule, "saved")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInsertFilterRule_Ports(t *testing.T) {
+	got, err := insertFilterRule(nil, ProxyFilterRule{Pattern: "db.example.com", Action: "allow", Scope: "host", Ports: []int{5432, 6432}}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "[[proxy.filter.rules]]\npattern = \"db.example.com\"\naction = \"allow\"\nscope = \"host\"\nports = [5432, 6432]\n"
+	if string(got) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestInsertFilterRule_InlineRules(t *testing.T) {
+	raw := "[proxy.filter]\nrules = [{ pattern = \"a.com\", action = \"ask\" }]\n"
+	if _, err := insertFilterRule([]byte(raw), ProxyFilterRule{Pattern: "b.com", Action: "allow"}, ""); err == nil {
+		t.Error("expected an error for rules declared inline")
+	}
+}
+
+func TestAddLocalFilterRule_Trust(t *testing.T) {
+	projectDir := t.TempDir()
+	path := filepath.Join(projectDir, LocalConfigFile)
+	store, err := LoadTrustStore(filepath.Join(t.TempDir(), "trusted-configs.toml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rule := ProxyFilterRule{Pattern: "api.example.com", Action: "allow", Scope: "host", Type: "exact"}
+
+	// No file yet: created, and trusted as written.
+	if err := AddLocalFilterRule(projectDir, rule, "", store); err != nil {
+		t.Fatalf("AddLocalFilterRule: %v", err)
+	}
+	hash, err := HashFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !store.IsTrusted(projectDir, hash) {
+		t.Error("created config is not trusted")
+	}
+
+	// A trusted file gets the rule and stays trusted.
+	if err := AddLocalFilterRule(projectDir, ProxyFilterRule{Pattern: "b.com", Action: "block"}, "", store); err != nil {
+		t.Fatalf("AddLocalFilterRule on a trusted file: %v", err)
+	}
+	if hash, _ = HashFile(path); !store.IsTrusted(projectDir, hash) {
+		t.Error("config is not trusted after the second rule")
+	}
+
+	// A change nobody trusted is not approved along with the rule.
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = f.WriteString("\n[[proxy.filter.rules]]\npattern = \"*\"\naction = \"allow\"\n")
+	_ = f.Close()
+	before, _ := os.ReadFile(path)
+	err = AddLocalFilterRule(projectDir, ProxyFilterRule{Pattern: "c.com", Action: "block"}, "", store)
+	if !errors.Is(err, ErrLocalConfigUntrusted) {
+		t.Fatalf("error = %v, want ErrLocalConfigUntrusted", err)
+	}
+	if after, _ := os.ReadFile(path); string(after) != string(before) {
+		t.Error("untrusted config was rewritten")
+	}
+	if hash, _ = HashFile(path); store.IsTrusted(projectDir, hash) {
+		t.Error("untrusted change became trusted")
+	}
+}
+
+func TestAddFilterRule_RefusesSymlink(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "target")
+	if err := os.WriteFile(target, []byte("secret = 1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(dir, "config.toml")
+	if err := os.Symlink(target, link); err != nil {
+		t.Fatal(err)
+	}
+	err := AddFilterRule(link, ProxyFilterRule{Pattern: "a.com", Action: "allow"}, "")
+	if !errors.Is(err, fsutil.ErrNotRegularFile) {
+		t.Errorf("error = %v, want ErrNotRegularFile", err)
+	}
+}
@@ -0,0 +1,348 @@
+package dbus
+
+import (
+	"bufio"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	busName      = "org.freedesktop.DBus"
+	busPath      = ObjectPath("/org/freedesktop/DBus")
+	busInterface = "org.freedesktop.DBus"
+)
+
+// ErrClosed reports a call on a connection that has closed.
+var ErrClosed = errors.New("dbus: connection closed")
+
+// SessionBusAddress returns the session bus address: DBUS_SESSION_BUS_ADDRESS,
+// or the conventional $XDG_RUNTIME_DIR/bus when that is unset and the socket
+// exists. It returns "" when there is no session bus to find.
+func SessionBusAddress() string {
+	if addr := os.Getenv("DBUS_SESSION_BUS_ADDRESS"); addr != "" {
+		return addr
+	}
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		path := filepath.Join(dir, "bus")
+		if _, err := os.Stat(path); err == nil {
+			return "unix:path=" + path
+		}
+	}
+	return ""
+}
+
+// Conn is a connection to a message bus.
+type Conn struct {
+	conn net.Conn
+	name string // the unique name the bus assigned
+
+	writeMu sync.Mutex
+	serial  uint32 // guarded by writeMu
+
+	mu      sync.Mutex
+	calls   map[uint32]chan *Message
+	closed  bool
+	readErr error
+
+	messages chan *Message
+}
+
+// Dial connects to the bus at addr - a D-Bus address such as
+// "unix:path=/run/user/1000/bus" - authenticates, and registers with Hello.
+// Of the transports only unix path and abstract sockets are supported.
+func Dial(ctx context.Context, addr string) (*Conn, error) {
+	var errs []error
+	for entry := range strings.SplitSeq(addr, ";") {
+		network, path, err := parseAddress(entry)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		var d net.Dialer
+		nc, err := d.DialContext(ctx, network, path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		c, err := newConn(ctx, nc)
+		if err != nil {
+			_ = nc.Close()
+			errs = append(errs, err)
+			continue
+		}
+		return c, nil
+	}
+	if len(errs) == 0 {
+		return nil, fmt.Errorf("dbus: empty address")
+	}
+	return nil, errors.Join(errs...)
+}
+
+// parseAddress turns one D-Bus address entry into a net.Dial network and
+// address.
+func parseAddress(entry string) (string, string, error) {
+	transport, params, ok := strings.Cut(entry, ":")
+	if !ok || transport != "unix" {
+		return "", "", fmt.Errorf("dbus: unsupported address %q", entry)
+	}
+	for kv := range strings.SplitSeq(params, ",") {
+		key, value, _ := strings.Cut(kv, "=")
+		value, err := url.PathUnescape(value)
+		if err != nil {
+			return "", "", fmt.Errorf("dbus: bad address %q: %w", entry, err)
+		}
+		switch key {
+		case "path":
+			return "unix", value, nil
+		case "abstract":
+			return "unix", "@" + value, nil
+		}
+	}
+	return "", "", fmt.Errorf("dbus: address %q names no socket", entry)
+}
+
+func newConn(ctx context.Context, nc net.Conn) (*Conn, error) {
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = nc.SetDeadline(deadline)
+	}
+	r := bufio.NewReader(nc)
+	if err := authenticate(nc, r); err != nil {
+		return nil, err
+	}
+	_ = nc.SetDeadline(time.Time{})
+
+	c := &Conn{
+		conn:     nc,
+		calls:    make(map[uint32]chan *Message),
+		messages: make(chan *Message, 64),
+	}
+	go c.readLoop(r)
+
+	reply, err := c.Call(ctx, busName, busPath, busInterface, "Hello", "")
+	if err != nil {
+		_ = c.Close()
+		return nil, fmt.Errorf("dbus: Hello: %w", err)
+	}
+	var name string
+	if len(reply.Body) == 1 {
+		name, _ = reply.Body[0].(string)
+	}
+	if name == "" {
+		_ = c.Close()
+		return nil, errors.New("dbus: Hello returned no name")
+	}
+	c.name = name
+	return c, nil
+}
+
+// authenticate runs the SASL exchange with the EXTERNAL mechanism: the bus
+// takes the uid from the socket's credentials and checks it against the one
+// claimed here.
+func authenticate(nc net.Conn, r *bufio.Reader) error {
+	uid := hex.EncodeToString([]byte(strconv.Itoa(os.Getuid())))
+	if _, err := nc.Write([]byte("\x00AUTH EXTERNAL " + uid + "\r\n")); err != nil {
+		return err
+	}
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("dbus: authentication: %w", err)
+	}
+	if !strings.HasPrefix(line, "OK ") {
+		return fmt.Errorf("dbus: authentication refused: %s", strings.TrimSpace(line))
+	}
+	_, err = nc.Write([]byte("BEGIN\r\n"))
+	return err
+}
+
+// Name is the connection's unique name on the bus.
+func (c *Conn) Name() string {
+	return c.name
+}
+
+// Messages delivers the signals and method calls that reach the connection.
+// It is closed when the connection is. Messages arriving while it is full
+// are dropped rather than stalling the replies behind them.
+func (c *Conn) Messages() <-chan *Message {
+	return c.messages
+}
+
+func (c *Conn) readLoop(r *bufio.Reader) {
+	var err error
+	for {
+		var m *Message
+		if m, err = readMessage(r); err != nil {
+			break
+		}
+		switch m.Type {
+		case TypeMethodReturn, TypeError:
+			c.mu.Lock()
+			ch := c.calls[m.ReplySerial]
+			delete(c.calls, m.ReplySerial)
+			c.mu.Unlock()
+			if ch != nil {
+				ch <- m
+			}
+		default:
+			select {
+			case c.messages <- m:
+			default:
+			}
+		}
+	}
+
+	c.mu.Lock()
+	c.closed = true
+	c.readErr = err
+	for serial, ch := range c.calls {
+		close(ch)
+		delete(c.calls, serial)
+	}
+	c.mu.Unlock()
+	close(c.messages)
+	_ = c.conn.Close()
+}
+
+// send writes m, giving it the next serial. A reply channel, when given, is
+// registered under that serial before the write, so the reply cannot arrive
+// ahead of it.
+func (c *Conn) send(m *Message, reply chan *Message) (uint32, error) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	c.serial++
+	m.Serial = c.serial
+	data, err := m.marshal()
+	if err != nil {
+		return 0, err
+	}
+	if reply != nil {
+		c.mu.Lock()
+		if c.closed {
+			c.mu.Unlock()
+			return 0, ErrClosed
+		}
+		c.calls[m.Serial] = reply
+		c.mu.Unlock()
+	}
+	if _, err := c.conn.Write(data); err != nil {
+		if reply != nil {
+			c.forget(m.Serial)
+		}
+		return 0, err
+	}
+	return m.Serial, nil
+}
+
+// Call calls a method and waits for its reply, or for ctx to end. An error
+// reply is returned as *Error.
+func (c *Conn) Call(ctx context.Context, dest string, path ObjectPath, iface, member, sig string, args ...any) (*Message, error) {
+	ch := make(chan *Message, 1)
+	serial, err := c.send(&Message{
+		Type:        TypeMethodCall,
+		Path:        path,
+		Interface:   iface,
+		Member:      member,
+		Destination: dest,
+		Signature:   sig,
+		Body:        args,
+	}, ch)
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case reply, ok := <-ch:
+		if !ok {
+			return nil, ErrClosed
+		}
+		if reply.Type == TypeError {
+			e := &Error{Name: reply.ErrorName}
+			if len(reply.Body) > 0 {
+				e.Message, _ = reply.Body[0].(string)
+			}
+			return nil, e
+		}
+		return reply, nil
+	case <-ctx.Done():
+		c.forget(serial)
+		return nil, ctx.Err()
+	}
+}
+
+func (c *Conn) forget(serial uint32) {
+	c.mu.Lock()
+	delete(c.calls, serial)
+	c.mu.Unlock()
+}
+
+// AddMatch asks the bus to route the messages rule matches to this
+// connection; see the spec's match rules.
+func (c *Conn) AddMatch(ctx context.Context, rule string) error {
+	_, err := c.Call(ctx, busName, busPath, busInterface, "AddMatch", "s", rule)
+	return err
+}
+
+// RequestName claims a well-known name, failing if another connection owns it.
+func (c *Conn) RequestName(ctx context.Context, name string) error {
+	const doNotQueue, primaryOwner = 4, 1
+	reply, err := c.Call(ctx, busName, busPath, busInterface, "RequestName", "su", name, uint32(doNotQueue))
+	if err != nil {
+		return err
+	}
+	var code uint32
+	if len(reply.Body) == 1 {
+		code, _ = reply.Body[0].(uint32)
+	}
+	if code != primaryOwner {
+		return fmt.Errorf("dbus: name %s is taken", name)
+	}
+	return nil
+}
+
+// Reply answers a method call.
+func (c *Conn) Reply(call *Message, sig string, args ...any) error {
+	_, err := c.send(&Message{
+		Type:        TypeMethodReturn,
+		Flags:       flagNoReplyExpected,
+		ReplySerial: call.Serial,
+		Destination: call.Sender,
+		Signature:   sig,
+		Body:        args,
+	}, nil)
+	return err
+}
+
+// Emit broadcasts a signal.
+func (c *Conn) Emit(path ObjectPath, iface, member, sig string, args ...any) error {
+	_, err := c.send(&Message{
+		Type:      TypeSignal,
+		Flags:     flagNoReplyExpected,
+		Path:      path,
+		Interface: iface,
+		Member:    member,
+		Signature: sig,
+		Body:      args,
+	}, nil)
+	return err
+}
+
+// Err is why the connection closed, once it has.
+func (c *Conn) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.readErr
+}
+
+// Close closes the connection. Calls in flight return ErrClosed.
+func (c *Conn) Close() error {
+	return c.conn.Close()
+}
@@ -0,0 +1,161 @@
+package dbus
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"devsandbox/internal/dbus/dbustest"
+)
+
+func TestMessageRoundTrip(t *testing.T) {
+	m := &Message{
+		Type:        TypeMethodCall,
+		Serial:      7,
+		Path:        "/org/example/Object",
+		Interface:   "org.example.Iface",
+		Member:      "Method",
+		Destination: "org.example",
+		Signature:   "ybnqiuxtdsogvasa{sv}a(su)",
+		Body: []any{
+			byte(1), true, int16(-2), uint16(3), int32(-4), uint32(5), int64(-6), uint64(7), 8.5,
+			"str", ObjectPath("/p"), Signature("a{sv}"),
+			Variant{Sig: "s", Value: "inner"},
+			[]string{"a", "b"},
+			map[string]any{"k": Variant{Sig: "u", Value: uint32(9)}},
+			[]any{[]any{"x", uint32(1)}, []any{"y", uint32(2)}},
+		},
+	}
+	data, err := m.marshal()
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	got, err := readMessage(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("readMessage: %v", err)
+	}
+	want := *m
+	want.Body = append([]any(nil), m.Body...)
+	want.Body[13] = []any{"a", "b"} // arrays decode as []any
+	if !reflect.DeepEqual(got, &want) {
+		t.Errorf("round trip:\n got %#v\nwant %#v", got, &want)
+	}
+}
+
+func TestMarshalRejectsMismatch(t *testing.T) {
+	for _, m := range []*Message{
+		{Signature: "s", Body: []any{uint32(1)}},
+		{Signature: "su", Body: []any{"only one"}},
+		{Signature: "s", Body: []any{"a", "b"}},
+		{Signature: "a{", Body: []any{nil}},
+	} {
+		if _, err := m.marshal(); err == nil {
+			t.Errorf("marshal(%q, %v): no error", m.Signature, m.Body)
+		}
+	}
+}
+
+func TestReadMessageTruncated(t *testing.T) {
+	data, err := (&Message{Type: TypeSignal, Path: "/p", Interface: "a.b", Member: "C", Signature: "s", Body: []any{"hello"}}).marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for n := range len(data) {
+		if _, err := readMessage(bytes.NewReader(data[:n])); err == nil {
+			t.Fatalf("readMessage of %d/%d bytes: no error", n, len(data))
+		}
+	}
+}
+
+func TestParseAddress(t *testing.T) {
+	tests := []struct {
+		addr    string
+		network string
+		path    string
+		wantErr bool
+	}{
+		{addr: "unix:path=/run/user/1000/bus", network: "unix", path: "/run/user/1000/bus"},
+		{addr: "unix:path=/tmp/a%20b,guid=123", network: "unix", path: "/tmp/a b"},
+		{addr: "unix:abstract=/tmp/dbus-x", network: "unix", path: "@/tmp/dbus-x"},
+		{addr: "tcp:host=localhost,port=1", wantErr: true},
+		{addr: "unix:guid=1", wantErr: true},
+	}
+	for _, tt := range tests {
+		network, path, err := parseAddress(tt.addr)
+		if (err != nil) != tt.wantErr || network != tt.network || path != tt.path {
+			t.Errorf("parseAddress(%q) = %q, %q, %v", tt.addr, network, path, err)
+		}
+	}
+}
+
+func TestConn_CallAndSignal(t *testing.T) {
+	addr := dbustest.StartBus(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	server, err := Dial(ctx, addr)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer func() { _ = server.Close() }()
+	if err := server.RequestName(ctx, "org.example.Echo"); err != nil {
+		t.Fatalf("RequestName: %v", err)
+	}
+	go func() {
+		for m := range server.Messages() {
+			if m.Type != TypeMethodCall {
+				continue
+			}
+			_ = server.Reply(m, "s", "echo: "+m.Body[0].(string))
+			_ = server.Emit("/org/example/Echo", "org.example.Echo", "Echoed", "s", m.Body[0])
+		}
+	}()
+
+	client, err := Dial(ctx, addr)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer func() { _ = client.Close() }()
+	if client.Name() == "" || client.Name() == server.Name() {
+		t.Errorf("unique names %q and %q", client.Name(), server.Name())
+	}
+	if err := client.AddMatch(ctx, "type='signal',interface='org.example.Echo'"); err != nil {
+		t.Fatalf("AddMatch: %v", err)
+	}
+
+	reply, err := client.Call(ctx, "org.example.Echo", "/org/example/Echo", "org.example.Echo", "Say", "s", "hi")
+	if err != nil {
+		t.Fatalf("Call: %v", err)
+	}
+	if reply.Body[0] != "echo: hi" {
+		t.Errorf("reply = %v", reply.Body)
+	}
+	for signal := false; !signal; {
+		select {
+		case m := <-client.Messages():
+			if m.Member != "Echoed" {
+				continue // NameAcquired, sent to every connection
+			}
+			if m.Sender != server.Name() || m.Body[0] != "hi" {
+				t.Errorf("signal = %+v", m)
+			}
+			signal = true
+		case <-ctx.Done():
+			t.Fatal("no signal")
+		}
+	}
+
+	_, err = client.Call(ctx, "org.example.Missing", "/", "org.example.Missing", "Nope", "")
+	var dbusErr *Error
+	if !errors.As(err, &dbusErr) || dbusErr.Name != "org.freedesktop.DBus.Error.ServiceUnknown" {
+		t.Errorf("call to a missing name: %v", err)
+	}
+
+	_ = client.Close()
+	if _, err := client.Call(ctx, busName, busPath, busInterface, "GetId", ""); err == nil {
+		t.Error("call on a closed connection succeeded")
+	}
+}
@@ -0,0 +1,645 @@
+// Package dbus is a minimal D-Bus client: enough of the wire protocol to call
+// methods on the session bus, receive signals, and - for tests - own a name and
+// answer calls. It covers the types the callers here use, not the whole spec:
+// no file descriptors, no big-endian marshalling on the way out, and dict keys
+// must be strings.
+package dbus
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// MessageType is the kind of a message.
+type MessageType byte
+
+const (
+	TypeMethodCall   MessageType = 1
+	TypeMethodReturn MessageType = 2
+	TypeError        MessageType = 3
+	TypeSignal       MessageType = 4
+)
+
+// flagNoReplyExpected marks a call whose caller will not read the reply.
+const flagNoReplyExpected = 0x1
+
+// Header field codes.
+const (
+	fieldPath        = 1
+	fieldInterface   = 2
+	fieldMember      = 3
+	fieldErrorName   = 4
+	fieldReplySerial = 5
+	fieldDestination = 6
+	fieldSender      = 7
+	fieldSignature   = 8
+)
+
+// maxMessageSize is the spec's limit on a whole message.
+const maxMessageSize = 128 << 20
+
+// ObjectPath is a value of D-Bus type o.
+type ObjectPath string
+
+// Signature is a value of D-Bus type g.
+type Signature string
+
+// Variant is a value of D-Bus type v: a value with its own signature.
+type Variant struct {
+	Sig   string
+	Value any
+}
+
+// Message is a D-Bus message. Body holds the arguments as Go values: byte,
+// bool, int16, uint16, int32, uint32, int64, uint64, float64, string,
+// ObjectPath, Signature, Variant, []any for arrays and structs, and
+// map[string]any for dicts. Encoding also takes []string for as.
+type Message struct {
+	Type        MessageType
+	Flags       byte
+	Serial      uint32
+	Path        ObjectPath
+	Interface   string
+	Member      string
+	ErrorName   string
+	ReplySerial uint32
+	Destination string
+	Sender      string
+	Signature   string
+	Body        []any
+}
+
+// Error is an error reply to a method call.
+type Error struct {
+	Name    string
+	Message string
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return e.Name
+	}
+	return e.Name + ": " + e.Message
+}
+
+// marshal encodes m, little-endian.
+func (m *Message) marshal() ([]byte, error) {
+	body := &encoder{}
+	if err := body.encodeAll(m.Signature, m.Body); err != nil {
+		return nil, err
+	}
+
+	var fields []any
+	addField := func(code byte, sig string, v any) {
+		fields = append(fields, []any{code, Variant{Sig: sig, Value: v}})
+	}
+	if m.Path != "" {
+		addField(fieldPath, "o", m.Path)
+	}
+	if m.Interface != "" {
+		addField(fieldInterface, "s", m.Interface)
+	}
+	if m.Member != "" {
+		addField(fieldMember, "s", m.Member)
+	}
+	if m.ErrorName != "" {
+		addField(fieldErrorName, "s", m.ErrorName)
+	}
+	if m.ReplySerial != 0 {
+		addField(fieldReplySerial, "u", m.ReplySerial)
+	}
+	if m.Destination != "" {
+		addField(fieldDestination, "s", m.Destination)
+	}
+	if m.Sender != "" {
+		addField(fieldSender, "s", m.Sender)
+	}
+	if m.Signature != "" {
+		addField(fieldSignature, "g", Signature(m.Signature))
+	}
+
+	head := &encoder{}
+	if err := head.encodeAll("yyyyuua(yv)", []any{byte('l'), byte(m.Type), m.Flags, byte(1), uint32(len(body.buf)), m.Serial, fields}); err != nil {
+		return nil, err
+	}
+	head.align(8)
+	if len(head.buf)+len(body.buf) > maxMessageSize {
+		return nil, fmt.Errorf("dbus: message of %d bytes is over the limit", len(head.buf)+len(body.buf))
+	}
+	return append(head.buf, body.buf...), nil
+}
+
+// readMessage reads one message from r.
+func readMessage(r io.Reader) (*Message, error) {
+	fixed := make([]byte, 16)
+	if _, err := io.ReadFull(r, fixed); err != nil {
+		return nil, err
+	}
+	var order binary.ByteOrder
+	switch fixed[0] {
+	case 'l':
+		order = binary.LittleEndian
+	case 'B':
+		order = binary.BigEndian
+	default:
+		return nil, fmt.Errorf("dbus: bad endianness byte %q", fixed[0])
+	}
+	bodyLen := order.Uint32(fixed[4:])
+	fieldsLen := order.Uint32(fixed[12:])
+	headLen := 16 + uint64(fieldsLen)
+	headLen += (8 - headLen%8) % 8
+	if headLen+uint64(bodyLen) > maxMessageSize {
+		return nil, errors.New("dbus: message over the size limit")
+	}
+
+	buf := make([]byte, headLen+uint64(bodyLen))
+	copy(buf, fixed)
+	if _, err := io.ReadFull(r, buf[16:]); err != nil {
+		return nil, err
+	}
+
+	head := &decoder{buf: buf[:headLen], order: order}
+	vals, err := head.decodeAll("yyyyuua(yv)")
+	if err != nil {
+		return nil, fmt.Errorf("dbus: bad header: %w", err)
+	}
+	m := &Message{
+		Type:   MessageType(vals[1].(byte)),
+		Flags:  vals[2].(byte),
+		Serial: vals[5].(uint32),
+	}
+	for _, f := range vals[6].([]any) {
+		field := f.([]any)
+		v := field[1].(Variant).Value
+		switch field[0].(byte) {
+		case fieldPath:
+			m.Path, _ = v.(ObjectPath)
+		case fieldInterface:
+			m.Interface, _ = v.(string)
+		case fieldMember:
+			m.Member, _ = v.(string)
+		case fieldErrorName:
+			m.ErrorName, _ = v.(string)
+		case fieldReplySerial:
+			m.ReplySerial, _ = v.(uint32)
+		case fieldDestination:
+			m.Destination, _ = v.(string)
+		case fieldSender:
+			m.Sender, _ = v.(string)
+		case fieldSignature:
+			sig, _ := v.(Signature)
+			m.Signature = string(sig)
+		}
+	}
+
+	body := &decoder{buf: buf[headLen:], order: order}
+	if m.Body, err = body.decodeAll(m.Signature); err != nil {
+		return nil, fmt.Errorf("dbus: bad body: %w", err)
+	}
+	return m, nil
+}
+
+// nextType splits the first complete type off sig.
+func nextType(sig string) (string, string, error) {
+	if sig == "" {
+		return "", "", errors.New("empty signature")
+	}
+	switch sig[0] {
+	case 'a':
+		elem, rest, err := nextType(sig[1:])
+		if err != nil {
+			return "", "", err
+		}
+		return "a" + elem, rest, nil
+	case '(', '{':
+		closer := map[byte]byte{'(': ')', '{': '}'}[sig[0]]
+		inner := sig[1:]
+		for len(inner) > 0 && inner[0] != closer {
+			_, rest, err := nextType(inner)
+			if err != nil {
+				return "", "", err
+			}
+			inner = rest
+		}
+		if inner == "" {
+			return "", "", fmt.Errorf("unterminated %q in signature", sig[0])
+		}
+		n := len(sig) - len(inner) + 1
+		return sig[:n], sig[n:], nil
+	case 'y', 'b', 'n', 'q', 'i', 'u', 'x', 't', 'd', 's', 'o', 'g', 'v':
+		return sig[:1], sig[1:], nil
+	}
+	return "", "", fmt.Errorf("unsupported type %q in signature", sig[0])
+}
+
+// alignment is the boundary a value of type sig starts on.
+func alignment(sig string) int {
+	switch sig[0] {
+	case 'y', 'g', 'v':
+		return 1
+	case 'n', 'q':
+		return 2
+	case 'x', 't', 'd', '(', '{':
+		return 8
+	}
+	return 4
+}
+
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) align(n int) {
+	for len(e.buf)%n != 0 {
+		e.buf = append(e.buf, 0)
+	}
+}
+
+func (e *encoder) uint32(v uint32) {
+	e.align(4)
+	e.buf = binary.LittleEndian.AppendUint32(e.buf, v)
+}
+
+// encodeAll encodes vals, one per complete type of sig.
+func (e *encoder) encodeAll(sig string, vals []any) error {
+	for i := 0; sig != ""; i++ {
+		t, rest, err := nextType(sig)
+		if err != nil {
+			return err
+		}
+		if i >= len(vals) {
+			return fmt.Errorf("dbus: signature %q wants more than %d values", sig, len(vals))
+		}
+		if err := e.encode(t, vals[i]); err != nil {
+			return err
+		}
+		sig = rest
+		if sig == "" && i+1 != len(vals) {
+			return fmt.Errorf("dbus: %d values for a signature of %d", len(vals), i+1)
+		}
+	}
+	return nil
+}
+
+func (e *encoder) encode(sig string, v any) error {
+	bad := func() error { return fmt.Errorf("dbus: cannot encode %T as %q", v, sig) }
+	e.align(alignment(sig))
+	switch sig[0] {
+	case 'y':
+		b, ok := v.(byte)
+		if !ok {
+			return bad()
+		}
+		e.buf = append(e.buf, b)
+	case 'b':
+		b, ok := v.(bool)
+		if !ok {
+			return bad()
+		}
+		var u uint32
+		if b {
+			u = 1
+		}
+		e.uint32(u)
+	case 'n', 'q':
+		var u uint16
+		switch x := v.(type) {
+		case int16:
+			u = uint16(x)
+		case uint16:
+			u = x
+		default:
+			return bad()
+		}
+		e.buf = binary.LittleEndian.AppendUint16(e.buf, u)
+	case 'i', 'u':
+		switch x := v.(type) {
+		case int32:
+			e.uint32(uint32(x))
+		case uint32:
+			e.uint32(x)
+		default:
+			return bad()
+		}
+	case 'x', 't', 'd':
+		var u uint64
+		switch x := v.(type) {
+		case int64:
+			u = uint64(x)
+		case uint64:
+			u = x
+		case float64:
+			u = math.Float64bits(x)
+		default:
+			return bad()
+		}
+		e.buf = binary.LittleEndian.AppendUint64(e.buf, u)
+	case 's', 'o':
+		var s string
+		switch x := v.(type) {
+		case string:
+			s = x
+		case ObjectPath:
+			s = string(x)
+		default:
+			return bad()
+		}
+		e.uint32(uint32(len(s)))
+		e.buf = append(append(e.buf, s...), 0)
+	case 'g':
+		s, ok := v.(Signature)
+		if !ok {
+			return bad()
+		}
+		if len(s) > 255 {
+			return fmt.Errorf("dbus: signature %q too long", s)
+		}
+		e.buf = append(append(append(e.buf, byte(len(s))), s...), 0)
+	case 'v':
+		vv, ok := v.(Variant)
+		if !ok {
+			return bad()
+		}
+		if _, rest, err := nextType(vv.Sig); err != nil || rest != "" {
+			return fmt.Errorf("dbus: variant signature %q is not one complete type", vv.Sig)
+		}
+		if err := e.encode("g", Signature(vv.Sig)); err != nil {
+			return err
+		}
+		return e.encode(vv.Sig, vv.Value)
+	case '(':
+		fields, ok := v.([]any)
+		if !ok {
+			return bad()
+		}
+		return e.encodeAll(sig[1:len(sig)-1], fields)
+	case 'a':
+		return e.encodeArray(sig, v)
+	default:
+		return bad()
+	}
+	return nil
+}
+
+func (e *encoder) encodeArray(sig string, v any) error {
+	elem := sig[1:]
+	e.uint32(0)
+	lenAt := len(e.buf) - 4
+	e.align(alignment(elem))
+	start := len(e.buf)
+
+	switch x := v.(type) {
+	case []string:
+		for _, s := range x {
+			if err := e.encode(elem, s); err != nil {
+				return err
+			}
+		}
+	case []any:
+		for _, item := range x {
+			if err := e.encode(elem, item); err != nil {
+				return err
+			}
+		}
+	case map[string]any:
+		if elem[0] != '{' {
+			return fmt.Errorf("dbus: cannot encode a map as %q", sig)
+		}
+		keySig, valSig, err := nextType(elem[1 : len(elem)-1])
+		if err != nil {
+			return err
+		}
+		for k, val := range x {
+			e.align(8)
+			if err := e.encode(keySig, k); err != nil {
+				return err
+			}
+			if err := e.encode(valSig, val); err != nil {
+				return err
+			}
+		}
+	case nil:
+	default:
+		return fmt.Errorf("dbus: cannot encode %T as %q", v, sig)
+	}
+
+	binary.LittleEndian.PutUint32(e.buf[lenAt:], uint32(len(e.buf)-start))
+	return nil
+}
+
+type decoder struct {
+	buf   []byte
+	pos   int
+	order binary.ByteOrder
+}
+
+var errShort = errors.New("message too short")
+
+func (d *decoder) align(n int) error {
+	for d.pos%n != 0 {
+		if d.pos >= len(d.buf) {
+			return errShort
+		}
+		d.pos++
+	}
+	return nil
+}
+
+func (d *decoder) take(n int) ([]byte, error) {
+	if n < 0 || len(d.buf)-d.pos < n {
+		return nil, errShort
+	}
+	b := d.buf[d.pos : d.pos+n]
+	d.pos += n
+	return b, nil
+}
+
+// decodeAll decodes one value per complete type of sig.
+func (d *decoder) decodeAll(sig string) ([]any, error) {
+	var vals []any
+	for sig != "" {
+		t, rest, err := nextType(sig)
+		if err != nil {
+			return nil, err
+		}
+		v, err := d.decode(t, 0)
+		if err != nil {
+			return nil, err
+		}
+		vals = append(vals, v)
+		sig = rest
+	}
+	return vals, nil
+}
+
+// maxDepth bounds how deep containers nest, as the spec does.
+const maxDepth = 64
+
+func (d *decoder) decode(sig string, depth int) (any, error) {
+	if depth > maxDepth {
+		return nil, errors.New("containers nested too deep")
+	}
+	if err := d.align(alignment(sig)); err != nil {
+		return nil, err
+	}
+	switch sig[0] {
+	case 'y':
+		b, err := d.take(1)
+		if err != nil {
+			return nil, err
+		}
+		return b[0], nil
+	case 'b':
+		b, err := d.take(4)
+		if err != nil {
+			return nil, err
+		}
+		return d.order.Uint32(b) != 0, nil
+	case 'n', 'q':
+		b, err := d.take(2)
+		if err != nil {
+			return nil, err
+		}
+		if sig[0] == 'n' {
+			return int16(d.order.Uint16(b)), nil
+		}
+		return d.order.Uint16(b), nil
+	case 'i', 'u':
+		b, err := d.take(4)
+		if err != nil {
+			return nil, err
+		}
+		if sig[0] == 'i' {
+			return int32(d.order.Uint32(b)), nil
+		}
+		return d.order.Uint32(b), nil
+	case 'x', 't', 'd':
+		b, err := d.take(8)
+		if err != nil {
+			return nil, err
+		}
+		u := d.order.Uint64(b)
+		switch sig[0] {
+		case 'x':
+			return int64(u), nil
+		case 'd':
+			return math.Float64frombits(u), nil
+		}
+		return u, nil
+	case 's', 'o':
+		b, err := d.take(4)
+		if err != nil {
+			return nil, err
+		}
+		s, err := d.take(int(d.order.Uint32(b)) + 1)
+		if err != nil {
+			return nil, err
+		}
+		if sig[0] == 'o' {
+			return ObjectPath(s[:len(s)-1]), nil
+		}
+		return string(s[:len(s)-1]), nil
+	case 'g':
+		n, err := d.take(1)
+		if err != nil {
+			return nil, err
+		}
+		s, err := d.take(int(n[0]) + 1)
+		if err != nil {
+			return nil, err
+		}
+		return Signature(s[:len(s)-1]), nil
+	case 'v':
+		vs, err := d.decode("g", depth)
+		if err != nil {
+			return nil, err
+		}
+		inner := string(vs.(Signature))
+		if t, rest, err := nextType(inner); err != nil || rest != "" || t == "" {
+			return nil, fmt.Errorf("variant signature %q is not one complete type", inner)
+		}
+		v, err := d.decode(inner, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		return Variant{Sig: inner, Value: v}, nil
+	case '(':
+		var fields []any
+		inner := sig[1 : len(sig)-1]
+		for inner != "" {
+			t, rest, err := nextType(inner)
+			if err != nil {
+				return nil, err
+			}
+			v, err := d.decode(t, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, v)
+			inner = rest
+		}
+		return fields, nil
+	case 'a':
+		return d.decodeArray(sig, depth)
+	}
+	return nil, fmt.Errorf("unsupported type %q", sig)
+}
+
+func (d *decoder) decodeArray(sig string, depth int) (any, error) {
+	b, err := d.take(4)
+	if err != nil {
+		return nil, err
+	}
+	n := int(d.order.Uint32(b))
+	elem := sig[1:]
+	if err := d.align(alignment(elem)); err != nil {
+		return nil, err
+	}
+	if n > len(d.buf)-d.pos {
+		return nil, errShort
+	}
+	end := d.pos + n
+
+	if elem[0] == '{' {
+		keySig, valSig, err := nextType(elem[1 : len(elem)-1])
+		if err != nil {
+			return nil, err
+		}
+		m := map[string]any{}
+		for d.pos < end {
+			if err := d.align(8); err != nil {
+				return nil, err
+			}
+			k, err := d.decode(keySig, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			v, err := d.decode(valSig, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			switch key := k.(type) {
+			case string:
+				m[key] = v
+			case ObjectPath:
+				m[string(key)] = v
+			default:
+				return nil, fmt.Errorf("unsupported dict key type %q", keySig)
+			}
+		}
+		return m, nil
+	}
+
+	items := []any{}
+	for d.pos < end {
+		v, err := d.decode(elem, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, v)
+	}
+	return items, nil
+}
@@ -1,34 +1,51 @@
 // Package dockerproxy provides a filtering proxy for the Docker socket.
 //
-// SECURITY WARNING: Even with filtering, Docker socket access grants significant
-// privileges. The proxy allows:
-//   - GET/HEAD: Read access to ALL Docker state (containers, images, volumes, networks)
-//   - POST exec/attach: Execute commands in ANY container on the host
+// The proxy allows:
+//   - GET/HEAD: Read access to Docker state (images, volumes, networks); the
+//     container list is filtered to owned containers
+//   - POST exec/attach/stop: Execute commands in, attach to and stop owned
+//     containers
 //
-// This is by design — the sandbox needs Docker access for container workflows.
-// However, users must understand that enabling Docker socket forwarding effectively
-// grants the sandbox access equivalent to the Docker group (often root-equivalent).
+// A container is owned when the sandbox created it through the proxy, or when
+// it carries one of the labels the user configured. Every request naming a
+// container or exec instance - including inspect and logs - is scoped to owned
+// ones, and the containers the session created are removed when it ends. An
+// exec may not ask for more than the container was created with: Privileged,
+// User and every other field outside allowedExecConfig are refused.
+//
+// SECURITY WARNING: Docker socket access still grants significant privileges:
+// anything that can exec into a container can act with that container's
+// mounts and capabilities. Only label containers whose reach you accept.
 //
 // The proxy blocks container creation, deletion, image manipulation, and other
-// write operations. But exec into existing containers is intentionally allowed.
+// write operations. With a Policy set, the sandbox may also create containers -
+// from allowed images, unprivileged, without host namespaces or binds outside
+// the project - and start, kill, wait on and remove the containers it owns.
 package dockerproxy
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"maps"
 	"regexp"
+	"slices"
+	"strings"
 )
 
-// Precompiled patterns for exec/attach allowlist
+// Precompiled patterns for the exec/attach/stop allowlist. The proxy scopes
+// each of them to owned containers.
 var execPatterns = []*regexp.Regexp{
 	regexp.MustCompile(`^(/v[\d.]+)?/containers/[a-zA-Z0-9_.-]+/exec$`),
 	regexp.MustCompile(`^(/v[\d.]+)?/exec/[a-zA-Z0-9_.-]+/start$`),
 	regexp.MustCompile(`^(/v[\d.]+)?/containers/[a-zA-Z0-9_.-]+/attach$`),
+	regexp.MustCompile(`^(/v[\d.]+)?/containers/[a-zA-Z0-9_.-]+/stop$`),
 }
 
 // IsAllowed checks if a Docker API request should be allowed.
 // GET and HEAD requests are always allowed (read-only).
-// POST requests for exec/attach endpoints are allowed.
-// All other write operations are denied.
+// POST requests for exec/attach/stop endpoints are allowed.
+// All other write operations are denied. Ownership is checked separately.
 func IsAllowed(method, path string) bool {
 	// GET and HEAD requests are always allowed (read-only operations)
 	if method == "GET" || method == "HEAD" {
@@ -56,3 +73,39 @@ func DenyReason(method, path string) string {
 
 	return fmt.Sprintf("docker proxy: %s %s blocked (write operations not allowed)", method, path)
 }
+
+// allowedExecConfig are the exec create fields the sandbox may set, by
+// lowercased name as Docker's decoder folds case. Everything else is refused
+// unless left at its zero value, which clients send by default - among them
+// Privileged, which runs the command with every capability and without the
+// seccomp and AppArmor profiles, and User, which picks a user other than the
+// container's own. Either would hand the exec what the create checks kept
+// from the container.
+var allowedExecConfig = map[string]bool{
+	"attachstdin": true, "attachstdout": true, "attachstderr": true,
+	"detachkeys": true, "tty": true, "consolesize": true,
+	"env": true, "cmd": true, "workingdir": true,
+}
+
+// prepareExec checks an exec create body and returns it re-encoded. As with
+// a create body, the daemon gets exactly the bytes that were checked, so keys
+// that differ only in case cannot slip a field past the check.
+func prepareExec(body []byte) ([]byte, error) {
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(body, &doc); err != nil || doc == nil {
+		return nil, errors.New("body is not a JSON object")
+	}
+	for _, name := range slices.Sorted(maps.Keys(doc)) {
+		if allowedExecConfig[strings.ToLower(name)] {
+			continue
+		}
+		var v any
+		if err := json.Unmarshal(doc[name], &v); err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", name, err)
+		}
+		if !isZeroJSON(v, true) {
+			return nil, fmt.Errorf("exec field %s is not allowed", name)
+		}
+	}
+	return json.Marshal(doc)
+}
@@ -60,6 +60,7 @@ func TestIsAllowed_ExecAttach(t *testing.T) {
 		{"POST", "/v1.41/containers/abc123/attach"},
 		{"POST", "/containers/my-container_name.1/exec"},
 		{"POST", "/containers/My-Container.Name_123/attach"},
+		{"POST", "/containers/abc123/stop"},
 	}
 
 	for _, tt := range tests {
@@ -79,7 +80,6 @@ func TestIsAllowed_Denied(t *testing.T) {
 		{"POST", "/containers/create"},
 		{"POST", "/v1.41/containers/create"},
 		{"DELETE", "/containers/abc123"},
-		{"POST", "/containers/abc123/stop"},
 		{"POST", "/containers/abc123/kill"},
 		{"POST", "/containers/abc123/restart"},
 		{"POST", "/images/create"},
@@ -0,0 +1,329 @@
+package dockerproxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// maxListBodyBytes bounds a container list response the proxy filters.
+const maxListBodyBytes = 32 << 20
+
+var (
+	// containerPathPattern matches every path naming a container; the
+	// reference is group 2.
+	containerPathPattern = regexp.MustCompile(`^((?:/v[\d.]+)?/containers/)([^/]+)(/.*)?$`)
+	// execPathPattern matches every path naming an exec instance.
+	execPathPattern      = regexp.MustCompile(`^((?:/v[\d.]+)?/exec/)([^/]+)(/.*)?$`)
+	containerListPattern = regexp.MustCompile(`^(/v[\d.]+)?/containers/json$`)
+	execCreatePattern    = regexp.MustCompile(`^(/v[\d.]+)?/containers/[^/]+/exec$`)
+)
+
+// collectionPaths are the /containers/<x> paths that name no container.
+var collectionPaths = map[string]bool{"json": true, "create": true, "prune": true}
+
+// scopeRequest checks a request naming a container or exec instance against
+// the owned set, and rewrites a container reference to the ID it was checked
+// as, so a name cannot be swapped for another container in between. It sends
+// the denial itself and returns false when the reference is not owned.
+func (p *Proxy) scopeRequest(conn net.Conn, req *http.Request) bool {
+	if m := containerPathPattern.FindStringSubmatch(req.URL.Path); m != nil && !collectionPaths[m[2]] {
+		id, owned := p.resolveContainer(m[2])
+		if !owned {
+			p.deny(conn, req, http.StatusForbidden, fmt.Sprintf("container %q is not owned by this sandbox", m[2]))
+			return false
+		}
+		req.URL.Path = m[1] + id + m[3]
+		req.URL.RawPath = ""
+		return true
+	}
+	if m := execPathPattern.FindStringSubmatch(req.URL.Path); m != nil && !p.execs.Contains(m[2]) {
+		p.deny(conn, req, http.StatusForbidden, fmt.Sprintf("exec %q was not started in a container owned by this sandbox", m[2]))
+		return false
+	}
+	return true
+}
+
+// ownsContainer reports whether ref names an owned container.
+func (p *Proxy) ownsContainer(ref string) bool {
+	_, owned := p.resolveContainer(ref)
+	return owned
+}
+
+// ownsVolume reports whether a create may mount the named volume: one an
+// earlier create introduced, one carrying this policy's OwnerLabel, or one
+// that does not exist yet, which the daemon creates with the container and
+// removeCreated deletes when the session ends. A volume someone else made -
+// perhaps with driver options binding a host path, perhaps holding another
+// project's data - is refused.
+func (p *Proxy) ownsVolume(name string) bool {
+	if name == "" {
+		return false
+	}
+	if p.volumes.Contains(name) {
+		return true
+	}
+	resp, err := p.daemon.Get("http://docker/volumes/" + url.PathEscape(name))
+	if err != nil {
+		p.logError("failed to inspect volume %q: %v", name, err)
+		return false
+	}
+	defer func() { _ = resp.Body.Close() }()
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		p.volumes.Add(name)
+		return true
+	case http.StatusOK:
+	default:
+		return false
+	}
+
+	var info struct {
+		Labels map[string]string
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		p.logError("failed to decode volume %q: %v", name, err)
+		return false
+	}
+	return p.policy != nil && info.Labels[OwnerLabel] == p.policy.Owner()
+}
+
+// resolveContainer inspects ref - an ID, ID prefix or name - on the daemon
+// and returns its full ID and whether it is owned.
+func (p *Proxy) resolveContainer(ref string) (string, bool) {
+	resp, err := p.daemon.Get("http://docker/containers/" + url.PathEscape(ref) + "/json")
+	if err != nil {
+		p.logError("failed to inspect container %q: %v", ref, err)
+		return "", false
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return "", false
+	}
+
+	var info struct {
+		ID     string `json:"Id"`
+		Config struct {
+			Labels map[string]string
+		}
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		p.logError("failed to decode inspect response for %q: %v", ref, err)
+		return "", false
+	}
+	if info.ID == "" || !p.owns(info.ID, info.Config.Labels) {
+		return "", false
+	}
+	return info.ID, true
+}
+
+// owns reports whether the container with id and labels is owned: created
+// through this proxy, or carrying one of the configured labels.
+func (p *Proxy) owns(id string, labels map[string]string) bool {
+	if p.created.Contains(id) {
+		return true
+	}
+	if p.policy != nil && labels[OwnerLabel] == p.policy.Owner() {
+		return true
+	}
+	for _, l := range p.ownedLabels {
+		key, value, hasValue := strings.Cut(l, "=")
+		if v, ok := labels[key]; ok && (!hasValue || v == value) {
+			return true
+		}
+	}
+	return false
+}
+
+// recordCreated adds the ID of a container the daemon created to the owned
+// set.
+func (p *Proxy) recordCreated(resp *http.Response) {
+	if id := p.createdID(resp); id != "" {
+		p.created.Add(id)
+		p.logInfo("container created: %s", id)
+	}
+}
+
+// recordExec adds the ID of an exec instance created in an owned container
+// to the owned execs.
+func (p *Proxy) recordExec(resp *http.Response) {
+	if id := p.createdID(resp); id != "" {
+		p.execs.Add(id)
+	}
+}
+
+// createdID reads the {"Id": ...} body of a 201 Created response, leaving
+// the body in place for the client.
+func (p *Proxy) createdID(resp *http.Response) string {
+	if resp.StatusCode != http.StatusCreated {
+		return ""
+	}
+	body, ok := p.readBody(resp, maxCreateBodyBytes)
+	if !ok {
+		return ""
+	}
+	var created struct {
+		ID string `json:"Id"`
+	}
+	if err := json.Unmarshal(body, &created); err != nil {
+		p.logError("failed to decode create response: %v", err)
+		return ""
+	}
+	return created.ID
+}
+
+// filterContainerList drops containers the sandbox does not own from a
+// GET /containers/json response.
+func (p *Proxy) filterContainerList(resp *http.Response) {
+	if resp.StatusCode != http.StatusOK {
+		return
+	}
+	body, ok := p.readBody(resp, maxListBodyBytes)
+	if !ok {
+		return
+	}
+
+	var entries []json.RawMessage
+	if err := json.Unmarshal(body, &entries); err != nil {
+		p.logError("failed to decode container list: %v", err)
+		setBody(resp, []byte("[]"))
+		return
+	}
+	kept := make([]json.RawMessage, 0, len(entries))
+	for _, raw := range entries {
+		var c struct {
+			ID     string `json:"Id"`
+			Labels map[string]string
+		}
+		if json.Unmarshal(raw, &c) == nil && p.owns(c.ID, c.Labels) {
+			kept = append(kept, raw)
+		}
+	}
+	filtered, err := json.Marshal(kept)
+	if err != nil {
+		filtered = []byte("[]")
+	}
+	setBody(resp, filtered)
+}
+
+// readBody reads a response body of at most limit bytes and puts it back
+// for the client. A body that cannot be read or is too large is replaced
+// with an empty JSON array, so nothing unchecked reaches the client.
+func (p *Proxy) readBody(resp *http.Response, limit int64) ([]byte, bool) {
+	body, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	_ = resp.Body.Close()
+	if err != nil || int64(len(body)) > limit {
+		p.logError("failed to read response body (%d bytes read): %v", len(body), err)
+		setBody(resp, []byte("[]"))
+		return nil, false
+	}
+	setBody(resp, body)
+	return body, true
+}
+
+func setBody(resp *http.Response, body []byte) {
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	resp.TransferEncoding = nil
+	resp.Header.Del("Content-Length")
+}
+
+// removeCreated force-removes, with their anonymous volumes, the containers
+// created through the proxy: the ones recorded from create responses and any
+// the daemon lists under this policy's OwnerLabel. It then deletes the named
+// volumes those creates introduced. The daemon made them without a label, so
+// nothing else would ever clean them up, and a later session could not mount
+// them again.
+func (p *Proxy) removeCreated() {
+	defer p.removeVolumes()
+
+	ids := p.created.Items()
+	if p.policy != nil {
+		ids = append(ids, p.labelledContainers()...)
+	}
+
+	seen := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		req, err := http.NewRequest(http.MethodDelete, "http://docker/containers/"+url.PathEscape(id)+"?force=1&v=1", nil)
+		if err != nil {
+			continue
+		}
+		resp, err := p.daemon.Do(req)
+		if err != nil {
+			p.logError("failed to remove container %s: %v", id, err)
+			continue
+		}
+		_ = resp.Body.Close()
+		switch resp.StatusCode {
+		case http.StatusNoContent:
+			p.logInfo("removed container %s", id)
+		case http.StatusNotFound:
+			// Already removed by the sandbox or --rm.
+		default:
+			p.logError("failed to remove container %s: status %d", id, resp.StatusCode)
+		}
+	}
+}
+
+// labelledContainers lists the IDs of all containers, running or not,
+// carrying this policy's OwnerLabel.
+func (p *Proxy) labelledContainers() []string {
+	filters, _ := json.Marshal(map[string][]string{"label": {OwnerLabel + "=" + p.policy.Owner()}})
+	resp, err := p.daemon.Get("http://docker/containers/json?all=1&filters=" + url.QueryEscape(string(filters)))
+	if err != nil {
+		p.logError("failed to list created containers: %v", err)
+		return nil
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil
+	}
+
+	var list []struct {
+		ID string `json:"Id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		p.logError("failed to decode created containers: %v", err)
+		return nil
+	}
+	ids := make([]string, len(list))
+	for i, c := range list {
+		ids[i] = c.ID
+	}
+	return ids
+}
+
+// removeVolumes deletes the named volumes creates introduced. One that was
+// never made - its create was refused or failed - is already gone.
+func (p *Proxy) removeVolumes() {
+	for _, name := range p.volumes.Items() {
+		req, err := http.NewRequest(http.MethodDelete, "http://docker/volumes/"+url.PathEscape(name), nil)
+		if err != nil {
+			continue
+		}
+		resp, err := p.daemon.Do(req)
+		if err != nil {
+			p.logError("failed to remove volume %s: %v", name, err)
+			continue
+		}
+		_ = resp.Body.Close()
+		switch resp.StatusCode {
+		case http.StatusNoContent:
+			p.logInfo("removed volume %s", name)
+		case http.StatusNotFound:
+		default:
+			p.logError("failed to remove volume %s: status %d", name, resp.StatusCode)
+		}
+	}
+}
@@ -0,0 +1,481 @@
+package dockerproxy
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// OwnerLabel is stamped on every container created through a Policy. Its
+// value identifies the proxy that created the container, which owns every
+// container carrying it.
+const OwnerLabel = "devsandbox.owner"
+
+// maxCreateBodyBytes bounds a container or exec create body. Real ones are a
+// few KiB.
+const maxCreateBodyBytes = 1 << 20
+
+// PolicyConfig contains container policy configuration.
+type PolicyConfig struct {
+	// ProjectDir is the only host directory bind mounts may come from.
+	// Empty allows no host-path binds at all.
+	ProjectDir string
+
+	// Images are the image references containers may be created from and
+	// pulled, as path.Match patterns (e.g. "postgres", "redis:7*",
+	// "ghcr.io/acme/*"). A pattern without a tag matches every tag. Empty
+	// allows no images.
+	Images []string
+
+	// Owner is the OwnerLabel value. Empty generates a random one.
+	Owner string
+}
+
+// Policy lets the sandbox create and manage its own containers: creates are
+// allowed only when the body passes the policy checks, and start, kill, wait
+// and rm - like every request naming a container - only for owned ones.
+type Policy struct {
+	projectDir string
+	images     []string
+	owner      string
+}
+
+// NewPolicy creates a container policy.
+func NewPolicy(cfg PolicyConfig) *Policy {
+	owner := cfg.Owner
+	if owner == "" {
+		b := make([]byte, 8)
+		_, _ = rand.Read(b)
+		owner = hex.EncodeToString(b)
+	}
+	projectDir := ""
+	if cfg.ProjectDir != "" {
+		projectDir = resolveHostPath(filepath.Clean(cfg.ProjectDir))
+	}
+	return &Policy{projectDir: projectDir, images: cfg.Images, owner: owner}
+}
+
+// Owner returns the OwnerLabel value stamped on created containers.
+func (p *Policy) Owner() string { return p.owner }
+
+// policyAction is a request kind the policy decides instead of IsAllowed.
+type policyAction int
+
+const (
+	actionNone policyAction = iota
+	actionCreate
+	actionPull
+	actionManage
+)
+
+var (
+	createPattern = regexp.MustCompile(`^(/v[\d.]+)?/containers/create$`)
+	pullPattern   = regexp.MustCompile(`^(/v[\d.]+)?/images/create$`)
+	managePattern = regexp.MustCompile(`^(/v[\d.]+)?/containers/[^/]+/(start|kill|wait)$`)
+	removePattern = regexp.MustCompile(`^(/v[\d.]+)?/containers/[^/]+$`)
+)
+
+// matchPolicyRequest classifies a request the policy decides. The container
+// a manage request names is checked for ownership like any other.
+func matchPolicyRequest(method, urlPath string) policyAction {
+	switch method {
+	case "POST":
+		if createPattern.MatchString(urlPath) {
+			return actionCreate
+		}
+		if pullPattern.MatchString(urlPath) {
+			return actionPull
+		}
+		if managePattern.MatchString(urlPath) {
+			return actionManage
+		}
+	case "DELETE":
+		if removePattern.MatchString(urlPath) {
+			return actionManage
+		}
+	}
+	return actionNone
+}
+
+// containerSpec holds the fields of a create body the policy checks.
+type containerSpec struct {
+	Image      string
+	HostConfig *struct {
+		Binds        []string
+		Mounts       []mountSpec
+		NetworkMode  string
+		PidMode      string
+		IpcMode      string
+		UTSMode      string
+		UsernsMode   string
+		CgroupnsMode string
+		VolumesFrom  []string
+		LogConfig    *struct{ Type string }
+	}
+}
+
+// allowedHostConfig are the HostConfig fields a create body may set, by
+// lowercased name as Docker's decoder folds case. Everything else - among
+// them Privileged, CapAdd, Devices, DeviceCgroupRules, DeviceRequests,
+// SecurityOpt, Sysctls, Runtime, MaskedPaths and ReadonlyPaths - is refused
+// unless left at its zero value: a new daemon field is denied until it has
+// been looked at. Fields naming host resources are checked in checkSpec.
+var allowedHostConfig = map[string]bool{
+	// Checked in checkSpec.
+	"binds": true, "mounts": true, "volumesfrom": true, "logconfig": true,
+	"networkmode": true, "pidmode": true, "ipcmode": true, "utsmode": true,
+	"usernsmode": true, "cgroupnsmode": true,
+	// Confined to the container.
+	"autoremove": true, "restartpolicy": true, "capdrop": true, "groupadd": true,
+	"readonlyrootfs": true, "tmpfs": true, "shmsize": true, "init": true,
+	"consolesize": true, "ulimits": true,
+	// Networking.
+	"portbindings": true, "publishallports": true, "links": true, "dns": true,
+	"dnsoptions": true, "dnssearch": true, "extrahosts": true,
+	// Resource limits.
+	"memory": true, "memoryreservation": true, "memoryswap": true,
+	"memoryswappiness": true, "nanocpus": true, "cpushares": true,
+	"cpuperiod": true, "cpuquota": true, "cpusetcpus": true, "cpusetmems": true,
+	"pidslimit": true, "blkioweight": true,
+}
+
+// emptyIsSetHostConfig are HostConfig fields whose empty list is not their
+// default: an empty MaskedPaths unmasks /proc, as systempaths=unconfined does.
+var emptyIsSetHostConfig = map[string]bool{"maskedpaths": true, "readonlypaths": true}
+
+// localLogDrivers keep container logs on the daemon's host; the others ship
+// them to an address of the body's choosing.
+var localLogDrivers = []string{"", "json-file", "local", "none"}
+
+type mountSpec struct {
+	Type          string
+	Source        string
+	VolumeOptions *struct {
+		DriverConfig *struct {
+			Name    string
+			Options map[string]string
+		}
+	}
+}
+
+// prepareCreate stamps OwnerLabel on a create body and checks the result.
+// The checks run on the re-encoded body - exactly the bytes the daemon will
+// decode - so keys that differ only in case, which Docker's decoder folds
+// together, cannot hide a field from them. owns reports whether a container
+// reference names an owned container, and ownsVolume whether a named volume
+// may be mounted.
+func (p *Policy) prepareCreate(body []byte, owns, ownsVolume func(name string) bool) ([]byte, error) {
+	var doc map[string]any
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err != nil || doc == nil {
+		return nil, errors.New("body is not a JSON object")
+	}
+
+	labels := map[string]any{}
+	for k, v := range doc {
+		if strings.EqualFold(k, "Labels") {
+			if m, ok := v.(map[string]any); ok {
+				for lk, lv := range m {
+					labels[lk] = lv
+				}
+			}
+			delete(doc, k)
+		}
+	}
+	labels[OwnerLabel] = p.owner
+	doc["Labels"] = labels
+
+	out, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	var spec containerSpec
+	if err := json.Unmarshal(out, &spec); err != nil {
+		return nil, fmt.Errorf("invalid body: %w", err)
+	}
+	var fields struct {
+		HostConfig map[string]json.RawMessage
+	}
+	if err := json.Unmarshal(out, &fields); err != nil {
+		return nil, fmt.Errorf("invalid body: %w", err)
+	}
+	if err := checkHostConfigFields(fields.HostConfig); err != nil {
+		return nil, err
+	}
+	if err := p.checkSpec(&spec, owns, ownsVolume); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// checkHostConfigFields refuses every HostConfig field that is set but not in
+// allowedHostConfig. Clients send many fields at their zero value, so those
+// pass.
+func checkHostConfigFields(fields map[string]json.RawMessage) error {
+	for _, name := range slices.Sorted(maps.Keys(fields)) {
+		key := strings.ToLower(name)
+		if allowedHostConfig[key] {
+			continue
+		}
+		var v any
+		if err := json.Unmarshal(fields[name], &v); err != nil {
+			return fmt.Errorf("invalid HostConfig.%s: %w", name, err)
+		}
+		if isZeroJSON(v, !emptyIsSetHostConfig[key]) {
+			continue
+		}
+		return fmt.Errorf("HostConfig.%s is not allowed", name)
+	}
+	return nil
+}
+
+// isZeroJSON reports whether a decoded JSON value is null, false, zero or an
+// empty string, or - when emptyIsZero - an array or object holding only such
+// values.
+func isZeroJSON(v any, emptyIsZero bool) bool {
+	switch v := v.(type) {
+	case nil:
+		return true
+	case bool:
+		return !v
+	case float64:
+		return v == 0
+	case string:
+		return v == ""
+	case []any:
+		if !emptyIsZero {
+			return false
+		}
+		for _, e := range v {
+			if !isZeroJSON(e, true) {
+				return false
+			}
+		}
+		return true
+	case map[string]any:
+		if !emptyIsZero {
+			return false
+		}
+		for _, e := range v {
+			if !isZeroJSON(e, true) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func (p *Policy) checkSpec(spec *containerSpec, owns, ownsVolume func(name string) bool) error {
+	if !p.imageAllowed(spec.Image) {
+		return fmt.Errorf("image %q is not in the allowed images", spec.Image)
+	}
+	hc := spec.HostConfig
+	if hc == nil {
+		return nil
+	}
+	for _, ns := range []struct{ name, mode string }{
+		{"network", hc.NetworkMode},
+		{"pid", hc.PidMode},
+		{"ipc", hc.IpcMode},
+		{"uts", hc.UTSMode},
+		{"userns", hc.UsernsMode},
+		{"cgroup", hc.CgroupnsMode},
+	} {
+		if ns.mode == "host" {
+			return fmt.Errorf("the host %s namespace is not allowed", ns.name)
+		}
+		if ref, ok := strings.CutPrefix(ns.mode, "container:"); ok && !owns(ref) {
+			return fmt.Errorf("joining the %s namespace of container %q is not allowed", ns.name, ref)
+		}
+	}
+	for _, from := range hc.VolumesFrom {
+		ref, _, _ := strings.Cut(from, ":")
+		if !owns(ref) {
+			return fmt.Errorf("volumes from container %q are not allowed", ref)
+		}
+	}
+	if hc.LogConfig != nil && !slices.Contains(localLogDrivers, hc.LogConfig.Type) {
+		return fmt.Errorf("log driver %q is not allowed", hc.LogConfig.Type)
+	}
+	for _, bind := range hc.Binds {
+		src, _, _ := strings.Cut(bind, ":")
+		// A source that is not a path is a named volume.
+		if !filepath.IsAbs(src) {
+			if !ownsVolume(src) {
+				return fmt.Errorf("volume %q was not created by this sandbox", src)
+			}
+			continue
+		}
+		if err := p.checkHostPath(src); err != nil {
+			return err
+		}
+	}
+	for _, m := range hc.Mounts {
+		switch m.Type {
+		case "bind":
+			if err := p.checkHostPath(m.Source); err != nil {
+				return err
+			}
+		case "volume":
+			// The local driver's device/o options bind an arbitrary host path.
+			if m.VolumeOptions != nil && m.VolumeOptions.DriverConfig != nil {
+				return fmt.Errorf("volume driver options are not allowed (volume %q)", m.Source)
+			}
+			// An empty source is an anonymous volume, new by definition.
+			if m.Source != "" && !ownsVolume(m.Source) {
+				return fmt.Errorf("volume %q was not created by this sandbox", m.Source)
+			}
+		case "tmpfs":
+		default:
+			return fmt.Errorf("mount type %q is not allowed", m.Type)
+		}
+	}
+	return nil
+}
+
+// checkHostPath allows a bind source only under the project directory. The
+// path is resolved through symlinks first: the sandbox can write the project
+// directory, so a symlink there must not lead the daemon elsewhere.
+func (p *Policy) checkHostPath(src string) error {
+	if p.projectDir == "" || !filepath.IsAbs(src) {
+		return fmt.Errorf("bind mount of %q is not allowed", src)
+	}
+	resolved := resolveHostPath(filepath.Clean(src))
+	if resolved != p.projectDir && !strings.HasPrefix(resolved, p.projectDir+string(filepath.Separator)) {
+		return fmt.Errorf("bind mount of %q is outside the project directory", src)
+	}
+	return nil
+}
+
+// resolveHostPath resolves symlinks in the longest existing prefix of an
+// absolute, clean path; the daemon creates a missing bind source, so the
+// part that does not exist yet cannot contain a symlink.
+func resolveHostPath(p string) string {
+	rest := ""
+	for cur := p; ; cur = filepath.Dir(cur) {
+		if resolved, err := filepath.EvalSymlinks(cur); err == nil {
+			return filepath.Join(resolved, rest)
+		}
+		if _, err := os.Lstat(cur); err == nil {
+			// Exists but cannot be resolved (a dangling or looping link).
+			return cur
+		}
+		if cur == filepath.Dir(cur) {
+			return p
+		}
+		rest = filepath.Join(filepath.Base(cur), rest)
+	}
+}
+
+// checkPull allows POST /images/create for an allowed image pulled from a
+// registry. Importing (fromSrc) is not a pull and is denied.
+func (p *Policy) checkPull(q url.Values) error {
+	if q.Get("fromSrc") != "" {
+		return errors.New("importing images is not allowed")
+	}
+	ref := q.Get("fromImage")
+	if ref == "" {
+		return errors.New("fromImage is required")
+	}
+	if tag := q.Get("tag"); tag != "" {
+		if strings.Contains(tag, ":") {
+			ref += "@" + tag
+		} else {
+			ref += ":" + tag
+		}
+	}
+	if !p.imageAllowed(ref) {
+		return fmt.Errorf("image %q is not in the allowed images", ref)
+	}
+	return nil
+}
+
+// imageAllowed matches ref against the allowed image patterns. Both sides
+// are compared as given and fully qualified, so "postgres" allows
+// "docker.io/library/postgres:16"; a pattern without a tag or digest matches
+// every tag.
+func (p *Policy) imageAllowed(ref string) bool {
+	if ref == "" {
+		return false
+	}
+	full := normalizeImage(ref)
+	for _, pattern := range p.images {
+		repo, tag, digest := splitImage(pattern)
+		if tag == "" && digest == "" {
+			if matchAny(repo, imageRepository(ref)) || matchAny(qualifyRepository(repo), imageRepository(full)) {
+				return true
+			}
+			continue
+		}
+		if matchAny(pattern, ref) || matchAny(qualifyRepository(repo)+pattern[len(repo):], full) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchAny(pattern, name string) bool {
+	ok, _ := path.Match(pattern, name)
+	return ok
+}
+
+// splitImage splits an image reference into repository, tag and digest.
+func splitImage(ref string) (repo, tag, digest string) {
+	if i := strings.IndexByte(ref, '@'); i >= 0 {
+		ref, digest = ref[:i], ref[i+1:]
+	}
+	if i := strings.LastIndexByte(ref, ':'); i > strings.LastIndexByte(ref, '/') {
+		ref, tag = ref[:i], ref[i+1:]
+	}
+	return ref, tag, digest
+}
+
+// imageRepository strips the tag and digest from an image reference.
+func imageRepository(ref string) string {
+	repo, _, _ := splitImage(ref)
+	return repo
+}
+
+// normalizeImage qualifies an image reference the way the Docker CLI does:
+// "postgres" is "docker.io/library/postgres:latest".
+func normalizeImage(ref string) string {
+	repo, tag, digest := splitImage(ref)
+	repo = qualifyRepository(repo)
+	if tag == "" && digest == "" {
+		tag = "latest"
+	}
+	if tag != "" {
+		repo += ":" + tag
+	}
+	if digest != "" {
+		repo += "@" + digest
+	}
+	return repo
+}
+
+// qualifyRepository adds the registry and, for Docker Hub, the library/
+// namespace a bare repository name implies.
+func qualifyRepository(repo string) string {
+	if i := strings.IndexByte(repo, '/'); i < 0 {
+		repo = "docker.io/library/" + repo
+	} else if first := repo[:i]; !strings.ContainsAny(first, ".:") && first != "localhost" {
+		repo = "docker.io/" + repo
+	}
+	if name, ok := strings.CutPrefix(repo, "docker.io/"); ok && !strings.Contains(name, "/") {
+		repo = "docker.io/library/" + name
+	}
+	return repo
+}
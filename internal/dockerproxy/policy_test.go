@@ -0,0 +1,231 @@
+package dockerproxy
+
+import (
+	"encoding/json"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func ownsNothing(string) bool { return false }
+
+func TestMatchPolicyRequest(t *testing.T) {
+	tests := []struct {
+		method, path string
+		action       policyAction
+	}{
+		{"POST", "/containers/create", actionCreate},
+		{"POST", "/v1.45/containers/create", actionCreate},
+		{"POST", "/images/create", actionPull},
+		{"POST", "/v1.45/containers/abc123/start", actionManage},
+		{"POST", "/containers/db/kill", actionManage},
+		{"POST", "/containers/db/wait", actionManage},
+		{"DELETE", "/v1.45/containers/db", actionManage},
+		{"POST", "/containers/db/stop", actionNone}, // allowed without a policy
+		{"POST", "/containers/db/restart", actionNone},
+		{"POST", "/containers/db/exec", actionNone},
+		{"DELETE", "/images/postgres", actionNone},
+		{"GET", "/containers/create", actionNone},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			if got := matchPolicyRequest(tt.method, tt.path); got != tt.action {
+				t.Errorf("got action %d, want %d", got, tt.action)
+			}
+		})
+	}
+}
+
+func TestPolicy_ImageAllowed(t *testing.T) {
+	p := NewPolicy(PolicyConfig{Images: []string{"postgres", "redis:7*", "ghcr.io/acme/*", "docker.io/testcontainers/ryuk:*"}})
+
+	allowed := []string{
+		"postgres",
+		"postgres:16",
+		"docker.io/library/postgres:16-alpine",
+		"library/postgres",
+		"postgres@sha256:abcd",
+		"redis:7.2",
+		"ghcr.io/acme/api:1.0",
+		"testcontainers/ryuk:0.6.0",
+	}
+	for _, ref := range allowed {
+		if !p.imageAllowed(ref) {
+			t.Errorf("%s should be allowed", ref)
+		}
+	}
+
+	denied := []string{
+		"",
+		"redis:6",
+		"redis",
+		"mysql:8",
+		"evil.io/postgres",
+		"ghcr.io/other/api",
+		"ghcr.io/acme/team/api",
+	}
+	for _, ref := range denied {
+		if p.imageAllowed(ref) {
+			t.Errorf("%s should be denied", ref)
+		}
+	}
+}
+
+func TestNormalizeImage(t *testing.T) {
+	tests := map[string]string{
+		"postgres":                "docker.io/library/postgres:latest",
+		"postgres:16":             "docker.io/library/postgres:16",
+		"docker.io/postgres":      "docker.io/library/postgres:latest",
+		"acme/api":                "docker.io/acme/api:latest",
+		"localhost:5000/api":      "localhost:5000/api:latest",
+		"ghcr.io/acme/api:1":      "ghcr.io/acme/api:1",
+		"postgres:16@sha256:abcd": "docker.io/library/postgres:16@sha256:abcd",
+	}
+	for in, want := range tests {
+		if got := normalizeImage(in); got != want {
+			t.Errorf("normalizeImage(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestPolicy_PrepareCreate_StampsOwnerLabel(t *testing.T) {
+	p := NewPolicy(PolicyConfig{Images: []string{"postgres"}, Owner: "session-1"})
+
+	body := `{"Image":"postgres:16","labels":{"org.testcontainers":"true","devsandbox.owner":"forged"},"Env":["A=1"],"StopTimeout":10}`
+	out, err := p.prepareCreate([]byte(body), ownsNothing, ownsNothing)
+	if err != nil {
+		t.Fatalf("prepareCreate: %v", err)
+	}
+
+	var doc map[string]any
+	if err := json.Unmarshal(out, &doc); err != nil {
+		t.Fatalf("output is not JSON: %v", err)
+	}
+	if _, ok := doc["labels"]; ok {
+		t.Error("differently-cased labels key was kept")
+	}
+	labels, _ := doc["Labels"].(map[string]any)
+	if labels[OwnerLabel] != "session-1" {
+		t.Errorf("owner label = %v, want session-1", labels[OwnerLabel])
+	}
+	if labels["org.testcontainers"] != "true" {
+		t.Errorf("existing labels dropped: %v", labels)
+	}
+	if !strings.Contains(string(out), `"StopTimeout":10`) {
+		t.Errorf("numbers not preserved: %s", out)
+	}
+}
+
+func TestPolicy_PrepareCreate_Denied(t *testing.T) {
+	projectDir := t.TempDir()
+	outside := t.TempDir()
+	if err := os.Symlink(outside, filepath.Join(projectDir, "escape")); err != nil {
+		t.Fatal(err)
+	}
+	p := NewPolicy(PolicyConfig{ProjectDir: projectDir, Images: []string{"postgres"}})
+
+	tests := map[string]string{
+		"image not allowed":     `{"Image":"mysql"}`,
+		"no image":              `{}`,
+		"not an object":         `[1]`,
+		"privileged":            `{"Image":"postgres","HostConfig":{"Privileged":true}}`,
+		"privileged lowercase":  `{"Image":"postgres","hostconfig":{"privileged":true}}`,
+		"privileged recased":    `{"Image":"postgres","HostConfig":{"Privileged":false,"PRIVILEGED":true}}`,
+		"host network":          `{"Image":"postgres","HostConfig":{"NetworkMode":"host"}}`,
+		"host pid":              `{"Image":"postgres","HostConfig":{"PidMode":"host"}}`,
+		"foreign container pid": `{"Image":"postgres","HostConfig":{"PidMode":"container:other"}}`,
+		"cap add":               `{"Image":"postgres","HostConfig":{"CapAdd":["SYS_ADMIN"]}}`,
+		"devices":               `{"Image":"postgres","HostConfig":{"Devices":[{"PathOnHost":"/dev/sda"}]}}`,
+		"volumes from":          `{"Image":"postgres","HostConfig":{"VolumesFrom":["other:ro"]}}`,
+		"bind outside":          `{"Image":"postgres","HostConfig":{"Binds":["/etc:/host-etc:ro"]}}`,
+		"bind traversal":        `{"Image":"postgres","HostConfig":{"Binds":["` + projectDir + `/../x:/x"]}}`,
+		"bind through symlink":  `{"Image":"postgres","HostConfig":{"Binds":["` + projectDir + `/escape:/x"]}}`,
+		"mount outside":         `{"Image":"postgres","HostConfig":{"Mounts":[{"Type":"bind","Source":"/var/run/docker.sock","Target":"/s"}]}}`,
+		"volume driver bind":    `{"Image":"postgres","HostConfig":{"Mounts":[{"Type":"volume","Source":"v","Target":"/v","VolumeOptions":{"DriverConfig":{"Name":"local","Options":{"device":"/","o":"bind"}}}}]}}`,
+		"npipe mount":           `{"Image":"postgres","HostConfig":{"Mounts":[{"Type":"npipe","Source":"x","Target":"/x"}]}}`,
+		// Escape paths outside the fields checkSpec looks at, refused by the
+		// HostConfig allowlist.
+		"device cgroup rule":     `{"Image":"postgres","HostConfig":{"DeviceCgroupRules":["b 8:* rmw"]}}`,
+		"device requests":        `{"Image":"postgres","HostConfig":{"DeviceRequests":[{"Driver":"nvidia","Count":-1}]}}`,
+		"seccomp unconfined":     `{"Image":"postgres","HostConfig":{"SecurityOpt":["seccomp=unconfined"]}}`,
+		"apparmor unconfined":    `{"Image":"postgres","HostConfig":{"SecurityOpt":["apparmor=unconfined"]}}`,
+		"systempaths":            `{"Image":"postgres","HostConfig":{"SecurityOpt":["systempaths=unconfined"]}}`,
+		"masked paths emptied":   `{"Image":"postgres","HostConfig":{"MaskedPaths":[]}}`,
+		"readonly paths emptied": `{"Image":"postgres","HostConfig":{"ReadonlyPaths":[]}}`,
+		"sysctls":                `{"Image":"postgres","HostConfig":{"Sysctls":{"kernel.core_pattern":"|/x"}}}`,
+		"runtime":                `{"Image":"postgres","HostConfig":{"Runtime":"runc-unsafe"}}`,
+		"host cgroupns":          `{"Image":"postgres","HostConfig":{"CgroupnsMode":"host"}}`,
+		"cgroup parent":          `{"Image":"postgres","HostConfig":{"CgroupParent":"/"}}`,
+		"oom score":              `{"Image":"postgres","HostConfig":{"OomScoreAdj":-1000}}`,
+		"unknown field":          `{"Image":"postgres","HostConfig":{"SomeFutureField":"x"}}`,
+		"remote log driver":      `{"Image":"postgres","HostConfig":{"LogConfig":{"Type":"syslog","Config":{"syslog-address":"tcp://evil:514"}}}}`,
+		"foreign named volume":   `{"Image":"postgres","HostConfig":{"Binds":["hostdata:/data"]}}`,
+		"foreign volume mount":   `{"Image":"postgres","HostConfig":{"Mounts":[{"Type":"volume","Source":"hostdata","Target":"/data"}]}}`,
+	}
+	for name, body := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := p.prepareCreate([]byte(body), ownsNothing, ownsNothing); err == nil {
+				t.Errorf("expected %s to be denied", body)
+			}
+		})
+	}
+}
+
+func TestPolicy_PrepareCreate_Allowed(t *testing.T) {
+	projectDir := t.TempDir()
+	p := NewPolicy(PolicyConfig{ProjectDir: projectDir, Images: []string{"postgres"}})
+	owns := func(ref string) bool { return ref == "mine" }
+	ownsVolume := func(name string) bool { return name == "pgdata" }
+
+	tests := map[string]string{
+		"minimal":             `{"Image":"postgres"}`,
+		"project bind":        `{"Image":"postgres","HostConfig":{"Binds":["` + projectDir + `/data:/var/lib/postgresql/data"]}}`,
+		"named volume":        `{"Image":"postgres","HostConfig":{"Binds":["pgdata:/var/lib/postgresql/data"]}}`,
+		"project mount":       `{"Image":"postgres","HostConfig":{"Mounts":[{"Type":"bind","Source":"` + projectDir + `","Target":"/src"}]}}`,
+		"tmpfs":               `{"Image":"postgres","HostConfig":{"Mounts":[{"Type":"tmpfs","Target":"/tmp"}]}}`,
+		"bridge network":      `{"Image":"postgres","HostConfig":{"NetworkMode":"bridge"}}`,
+		"own container netns": `{"Image":"postgres","HostConfig":{"NetworkMode":"container:mine"}}`,
+		"own volume mount":    `{"Image":"postgres","HostConfig":{"Mounts":[{"Type":"volume","Source":"pgdata","Target":"/data"}]}}`,
+		"anonymous volume":    `{"Image":"postgres","HostConfig":{"Mounts":[{"Type":"volume","Target":"/data"}]}}`,
+		"limits and ports": `{"Image":"postgres","HostConfig":{"Memory":536870912,"NanoCpus":1000000000,"PidsLimit":256,` +
+			`"PortBindings":{"5432/tcp":[{"HostIp":"127.0.0.1","HostPort":""}]},"CapDrop":["ALL"],"ReadonlyRootfs":true,` +
+			`"LogConfig":{"Type":"json-file","Config":{"max-size":"10m"}},"CgroupnsMode":"private"}}`,
+		// The Docker CLI sends most fields at their zero value.
+		"cli defaults": `{"Image":"postgres","HostConfig":{"Binds":null,"LogConfig":{"Type":"","Config":{}},"Privileged":false,` +
+			`"CapAdd":null,"Devices":[],"DeviceCgroupRules":null,"DeviceRequests":null,"SecurityOpt":null,"Sysctls":{},` +
+			`"MaskedPaths":null,"ReadonlyPaths":null,"Runtime":"","CgroupParent":"","OomScoreAdj":0,"BlkioWeightDevice":[],` +
+			`"KernelMemory":0,"OomKillDisable":false,"Isolation":"","ConsoleSize":[0,0]}}`,
+	}
+	for name, body := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := p.prepareCreate([]byte(body), owns, ownsVolume); err != nil {
+				t.Errorf("expected %s to be allowed: %v", body, err)
+			}
+		})
+	}
+}
+
+func TestPolicy_CheckPull(t *testing.T) {
+	p := NewPolicy(PolicyConfig{Images: []string{"postgres:16*"}})
+
+	tests := []struct {
+		query string
+		ok    bool
+	}{
+		{"fromImage=postgres&tag=16", true},
+		{"fromImage=postgres:16-alpine", true},
+		{"fromImage=postgres&tag=15", false},
+		{"fromImage=mysql&tag=16", false},
+		{"fromSrc=-&repo=postgres&tag=16", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		q, _ := url.ParseQuery(tt.query)
+		err := p.checkPull(q)
+		if (err == nil) != tt.ok {
+			t.Errorf("checkPull(%q) = %v, want ok=%v", tt.query, err, tt.ok)
+		}
+	}
+}
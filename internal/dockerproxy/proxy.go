@@ -2,11 +2,15 @@ package dockerproxy
 
 import (
 	"bufio"
+	"bytes"
 	"context"
+	"fmt"
 	"io"
 	"net"
 	"net/http"
+	"time"
 
+	"devsandbox/internal/cmdpattern"
 	"devsandbox/internal/socketproxy"
 	"golang.org/x/sync/errgroup"
 )
@@ -18,14 +22,34 @@ type Logger = socketproxy.Logger
 // Proxy is a filtering proxy for the Docker socket.
 // It logs errors to the provided logger if set, otherwise errors are silent.
 type Proxy struct {
-	hostSocket string
-	server     *socketproxy.Server
-	logger     Logger
+	hostSocket  string
+	server      *socketproxy.Server
+	logger      Logger
+	policy      *Policy
+	ownedLabels []string
+	created     *cmdpattern.OwnedSet[string] // container IDs created through the proxy
+	execs       *cmdpattern.OwnedSet[string] // exec IDs created in owned containers
+	volumes     *cmdpattern.OwnedSet[string] // named volumes creates introduced
+	daemon      *http.Client
 }
 
 // New creates a new Docker socket proxy.
 func New(hostSocket, listenPath string) *Proxy {
-	p := &Proxy{hostSocket: hostSocket}
+	p := &Proxy{
+		hostSocket: hostSocket,
+		created:    cmdpattern.NewOwnedSet[string](),
+		execs:      cmdpattern.NewOwnedSet[string](),
+		volumes:    cmdpattern.NewOwnedSet[string](),
+	}
+	p.daemon = &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", hostSocket)
+			},
+		},
+	}
 	p.server = socketproxy.NewServer(listenPath, 0o666, "docker-proxy", p.handleConnection)
 	return p
 }
@@ -37,11 +61,28 @@ func (p *Proxy) SetLogger(logger Logger) {
 	p.server.SetLogger(logger)
 }
 
+// SetPolicy lets the sandbox create and manage its own containers under
+// policy. Without one, only IsAllowed requests pass.
+func (p *Proxy) SetPolicy(policy *Policy) {
+	p.policy = policy
+}
+
+// SetOwnedLabels grants the sandbox the containers carrying any of labels,
+// each "key" or "key=value", in addition to the ones it created.
+func (p *Proxy) SetOwnedLabels(labels []string) {
+	p.ownedLabels = labels
+}
+
 // Start begins listening and proxying requests.
 func (p *Proxy) Start(ctx context.Context) error { return p.server.Start(ctx) }
 
-// Stop gracefully shuts down the proxy.
-func (p *Proxy) Stop() error { return p.server.Stop() }
+// Stop gracefully shuts down the proxy and removes the containers the
+// sandbox created through it.
+func (p *Proxy) Stop() error {
+	err := p.server.Stop()
+	p.removeCreated()
+	return err
+}
 
 func (p *Proxy) logError(format string, args ...any) {
 	if p.logger != nil {
@@ -67,20 +108,103 @@ func (p *Proxy) handleConnection(_ context.Context, conn net.Conn) {
 		return
 	}
 
+	action := actionNone
+	if p.policy != nil {
+		action = matchPolicyRequest(req.Method, req.URL.Path)
+	}
+
 	// Check if allowed
-	if !IsAllowed(req.Method, req.URL.Path) {
+	if action == actionNone && !IsAllowed(req.Method, req.URL.Path) {
 		reason := DenyReason(req.Method, req.URL.Path)
 		p.logInfo("request denied: %s %s - %s", req.Method, req.URL.Path, reason)
 		p.sendError(conn, http.StatusForbidden, reason)
 		return
 	}
 
+	var onResponse func(*http.Response)
+	switch action {
+	case actionCreate:
+		if !p.applyCreatePolicy(conn, req) {
+			return
+		}
+		onResponse = p.recordCreated
+	case actionPull:
+		if err := p.policy.checkPull(req.URL.Query()); err != nil {
+			p.deny(conn, req, http.StatusForbidden, err.Error())
+			return
+		}
+	}
+
+	// Requests naming a container or exec instance are scoped to owned ones.
+	if !p.scopeRequest(conn, req) {
+		return
+	}
+	switch {
+	case req.Method == "GET" && containerListPattern.MatchString(req.URL.Path):
+		onResponse = p.filterContainerList
+	case req.Method == "POST" && execCreatePattern.MatchString(req.URL.Path):
+		if !p.applyExecPolicy(conn, req) {
+			return
+		}
+		onResponse = p.recordExec
+	}
+
 	// Log and forward to Docker daemon
 	p.logInfo("request allowed: %s %s", req.Method, req.URL.Path)
-	p.forwardRequest(conn, req, reader)
+	p.forwardRequest(conn, req, reader, onResponse)
+}
+
+// applyCreatePolicy checks and rewrites a container create body. It sends
+// the denial itself and returns false when the policy rejects it.
+func (p *Proxy) applyCreatePolicy(conn net.Conn, req *http.Request) bool {
+	return p.rewriteBody(conn, req, func(raw []byte) ([]byte, error) {
+		return p.policy.prepareCreate(raw, p.ownsContainer, p.ownsVolume)
+	})
+}
+
+// applyExecPolicy checks and rewrites an exec create body, with or without a
+// Policy: an owned container is no reason to run a command with more than
+// it was created with. It sends the denial itself and returns false when
+// the body is refused.
+func (p *Proxy) applyExecPolicy(conn net.Conn, req *http.Request) bool {
+	return p.rewriteBody(conn, req, prepareExec)
+}
+
+// rewriteBody reads a request body of at most maxCreateBodyBytes, passes it
+// through prepare and replaces it with the result. It sends the denial
+// itself and returns false when the body is too large or prepare refuses it.
+func (p *Proxy) rewriteBody(conn net.Conn, req *http.Request, prepare func([]byte) ([]byte, error)) bool {
+	raw, err := io.ReadAll(io.LimitReader(req.Body, maxCreateBodyBytes+1))
+	if err != nil {
+		p.logError("failed to read request body: %v", err)
+		p.sendError(conn, http.StatusBadRequest, "failed to read request body")
+		return false
+	}
+	if len(raw) > maxCreateBodyBytes {
+		p.deny(conn, req, http.StatusRequestEntityTooLarge, fmt.Sprintf("body larger than %d bytes", maxCreateBodyBytes))
+		return false
+	}
+	body, err := prepare(raw)
+	if err != nil {
+		p.deny(conn, req, http.StatusForbidden, err.Error())
+		return false
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.ContentLength = int64(len(body))
+	req.TransferEncoding = nil
+	return true
 }
 
-func (p *Proxy) forwardRequest(clientConn net.Conn, req *http.Request, clientReader *bufio.Reader) {
+// deny logs and answers a request refused by ownership or policy.
+func (p *Proxy) deny(conn net.Conn, req *http.Request, status int, why string) {
+	reason := fmt.Sprintf("docker proxy: %s %s blocked (%s)", req.Method, req.URL.Path, why)
+	p.logInfo("request denied: %s %s - %s", req.Method, req.URL.Path, reason)
+	p.sendError(conn, status, reason)
+}
+
+// forwardRequest sends req to the daemon and relays the response. onResponse,
+// when set, may inspect or replace the response body before it is relayed.
+func (p *Proxy) forwardRequest(clientConn net.Conn, req *http.Request, clientReader *bufio.Reader, onResponse func(*http.Response)) {
 	// Connect to Docker daemon
 	dockerConn, err := net.Dial("unix", p.hostSocket)
 	if err != nil {
@@ -118,6 +242,10 @@ func (p *Proxy) forwardRequest(clientConn net.Conn, req *http.Request, clientRea
 		return
 	}
 
+	if onResponse != nil {
+		onResponse(resp)
+	}
+
 	// Write response to client
 	if err := resp.Write(clientConn); err != nil {
 		p.logError("failed to write response for %s %s: %v", req.Method, req.URL.Path, err)
@@ -2,11 +2,16 @@ package dockerproxy
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"io"
 	"net"
 	"net/http"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -139,3 +144,453 @@ func TestProxy_BlocksPOST(t *testing.T) {
 		t.Errorf("expected 403, got %d", resp.StatusCode)
 	}
 }
+
+// fakeDaemon serves the Docker API calls the proxy makes and forwards on a
+// unix socket: container create, inspect, list, start, exec and remove, and
+// volume inspect and remove. Containers and volumes are kept with their
+// labels, and a create makes the named volumes its binds name;
+// "hostdb", "shared" and the "hostdata" volume exist from the start, like the
+// user's own outside the sandbox.
+type fakeDaemon struct {
+	mu         sync.Mutex
+	containers map[string]map[string]string // id -> labels
+	volumes    map[string]map[string]string // name -> labels
+	started    []string
+	removed    []string
+	removedVol []string
+	nextID     int
+}
+
+func startFakeDaemon(t *testing.T, path string) *fakeDaemon {
+	t.Helper()
+	d := &fakeDaemon{containers: map[string]map[string]string{
+		"hostdb": {"com.example": "user"},
+		"shared": {"devsandbox.shared": "true"},
+	}, volumes: map[string]map[string]string{
+		"hostdata": {},
+		"labelled": {OwnerLabel: "session-1"},
+	}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /containers/create", func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Labels     map[string]string
+			HostConfig struct{ Binds []string }
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		d.mu.Lock()
+		d.nextID++
+		id := fmt.Sprintf("c%d", d.nextID)
+		d.containers[id] = body.Labels
+		for _, bind := range body.HostConfig.Binds {
+			if name, _, _ := strings.Cut(bind, ":"); !strings.HasPrefix(name, "/") && d.volumes[name] == nil {
+				d.volumes[name] = map[string]string{}
+			}
+		}
+		d.mu.Unlock()
+		w.WriteHeader(http.StatusCreated)
+		_, _ = fmt.Fprintf(w, `{"Id":%q,"Warnings":[]}`, id)
+	})
+	mux.HandleFunc("GET /containers/{id}/json", func(w http.ResponseWriter, r *http.Request) {
+		d.mu.Lock()
+		labels, ok := d.containers[r.PathValue("id")]
+		d.mu.Unlock()
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"Id": r.PathValue("id"), "Config": map[string]any{"Labels": labels}})
+	})
+	mux.HandleFunc("POST /containers/{id}/start", func(w http.ResponseWriter, r *http.Request) {
+		d.mu.Lock()
+		d.started = append(d.started, r.PathValue("id"))
+		d.mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("GET /containers/json", func(w http.ResponseWriter, r *http.Request) {
+		var filters struct {
+			Label []string `json:"label"`
+		}
+		_ = json.Unmarshal([]byte(r.URL.Query().Get("filters")), &filters)
+
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		list := []map[string]any{}
+		for id, labels := range d.containers {
+			if len(filters.Label) == 1 {
+				key, value, _ := strings.Cut(filters.Label[0], "=")
+				if labels[key] != value {
+					continue
+				}
+			}
+			list = append(list, map[string]any{"Id": id, "Labels": labels})
+		}
+		_ = json.NewEncoder(w).Encode(list)
+	})
+	mux.HandleFunc("GET /volumes/{name}", func(w http.ResponseWriter, r *http.Request) {
+		d.mu.Lock()
+		labels, ok := d.volumes[r.PathValue("name")]
+		d.mu.Unlock()
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"Name": r.PathValue("name"), "Labels": labels})
+	})
+	mux.HandleFunc("DELETE /volumes/{name}", func(w http.ResponseWriter, r *http.Request) {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		if _, ok := d.volumes[r.PathValue("name")]; !ok {
+			http.NotFound(w, r)
+			return
+		}
+		delete(d.volumes, r.PathValue("name"))
+		d.removedVol = append(d.removedVol, r.PathValue("name"))
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("POST /containers/{id}/exec", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		_, _ = fmt.Fprintf(w, `{"Id":"exec-%s"}`, r.PathValue("id"))
+	})
+	mux.HandleFunc("GET /exec/{id}/json", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, `{"ExitCode":0}`)
+	})
+	mux.HandleFunc("DELETE /containers/{id}", func(w http.ResponseWriter, r *http.Request) {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		if _, ok := d.containers[r.PathValue("id")]; !ok {
+			http.NotFound(w, r)
+			return
+		}
+		delete(d.containers, r.PathValue("id"))
+		d.removed = append(d.removed, r.PathValue("id"))
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Clients send a versioned path; the fake daemon serves every version.
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if rest, ok := strings.CutPrefix(r.URL.Path, "/v1.45"); ok {
+			r.URL.Path = rest
+		}
+		mux.ServeHTTP(w, r)
+	})
+	srv := &http.Server{Handler: handler}
+	go func() { _ = srv.Serve(l) }()
+	t.Cleanup(func() { _ = srv.Close() })
+	return d
+}
+
+func TestProxy_PolicyCreateAndStart(t *testing.T) {
+	tmpDir := t.TempDir()
+	listenPath := filepath.Join(tmpDir, "docker.sock")
+	hostPath := filepath.Join(tmpDir, "host.sock")
+	daemon := startFakeDaemon(t, hostPath)
+
+	p := New(hostPath, listenPath)
+	p.SetPolicy(NewPolicy(PolicyConfig{ProjectDir: tmpDir, Images: []string{"postgres"}, Owner: "session-1"}))
+	if err := p.Start(context.Background()); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer func() { _ = p.Stop() }()
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				return net.Dial("unix", listenPath)
+			},
+		},
+	}
+	post := func(path, body string) int {
+		t.Helper()
+		resp, err := client.Post("http://localhost"+path, "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("POST %s: %v", path, err)
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if got := post("/v1.45/containers/create", `{"Image":"postgres","HostConfig":{"Privileged":true}}`); got != http.StatusForbidden {
+		t.Errorf("privileged create: status %d, want 403", got)
+	}
+	if got := post("/v1.45/containers/create", `{"Image":"postgres:16"}`); got != http.StatusCreated {
+		t.Fatalf("create: status %d, want 201", got)
+	}
+
+	daemon.mu.Lock()
+	labels := daemon.containers["c1"]
+	daemon.mu.Unlock()
+	if labels[OwnerLabel] != "session-1" {
+		t.Fatalf("created container labels = %v, want %s=session-1", labels, OwnerLabel)
+	}
+
+	if got := post("/v1.45/containers/c1/start", ""); got != http.StatusNoContent {
+		t.Errorf("start own container: status %d, want 204", got)
+	}
+	if got := post("/v1.45/containers/hostdb/start", ""); got != http.StatusForbidden {
+		t.Errorf("start host container: status %d, want 403", got)
+	}
+	if got := post("/v1.45/containers/missing/start", ""); got != http.StatusForbidden {
+		t.Errorf("start unknown container: status %d, want 403", got)
+	}
+
+	daemon.mu.Lock()
+	defer daemon.mu.Unlock()
+	if len(daemon.started) != 1 || daemon.started[0] != "c1" {
+		t.Errorf("started = %v, want [c1]", daemon.started)
+	}
+}
+
+// TestProxy_ExecCannotEscalate pins that an exec into an owned container is
+// refused when it asks for more than the create policy let the container
+// have.
+func TestProxy_ExecCannotEscalate(t *testing.T) {
+	tmpDir := t.TempDir()
+	listenPath := filepath.Join(tmpDir, "docker.sock")
+	hostPath := filepath.Join(tmpDir, "host.sock")
+	startFakeDaemon(t, hostPath)
+
+	p := New(hostPath, listenPath)
+	p.SetPolicy(NewPolicy(PolicyConfig{Images: []string{"postgres"}, Owner: "session-1"}))
+	if err := p.Start(context.Background()); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer func() { _ = p.Stop() }()
+	client := proxyClient(listenPath)
+
+	if got, body := do(t, client, "POST", "/v1.45/containers/create", `{"Image":"postgres"}`); got != http.StatusCreated {
+		t.Fatalf("create: status %d (%s)", got, body)
+	}
+
+	tests := []struct {
+		name, body string
+		want       int
+	}{
+		{"privileged", `{"Cmd":["sh"],"Privileged":true}`, http.StatusForbidden},
+		{"privileged, case-folded", `{"Cmd":["sh"],"Privileged":false,"privileged":true}`, http.StatusForbidden},
+		{"root user", `{"Cmd":["sh"],"User":"0"}`, http.StatusForbidden},
+		{"unknown field", `{"Cmd":["sh"],"CapAdd":["SYS_ADMIN"]}`, http.StatusForbidden},
+		{"not an object", `["sh"]`, http.StatusForbidden},
+		{"docker CLI defaults", `{"User":"","Privileged":false,"Tty":true,"AttachStdin":true,"AttachStdout":true,"AttachStderr":true,"DetachKeys":"","Env":null,"WorkingDir":"","Cmd":["sh"]}`, http.StatusCreated},
+	}
+	for _, tt := range tests {
+		if got, body := do(t, client, "POST", "/v1.45/containers/c1/exec", tt.body); got != tt.want {
+			t.Errorf("%s: status %d, want %d (%s)", tt.name, got, tt.want, body)
+		}
+	}
+}
+
+func TestProxy_PolicyNamedVolumes(t *testing.T) {
+	tmpDir := t.TempDir()
+	listenPath := filepath.Join(tmpDir, "docker.sock")
+	hostPath := filepath.Join(tmpDir, "host.sock")
+	daemon := startFakeDaemon(t, hostPath)
+
+	p := New(hostPath, listenPath)
+	p.SetPolicy(NewPolicy(PolicyConfig{Images: []string{"postgres"}, Owner: "session-1"}))
+	if err := p.Start(context.Background()); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer func() { _ = p.Stop() }()
+	client := proxyClient(listenPath)
+
+	tests := []struct {
+		name, body string
+		want       int
+	}{
+		{"user's volume", `{"Image":"postgres","HostConfig":{"Binds":["hostdata:/data"]}}`, http.StatusForbidden},
+		{"user's volume mount", `{"Image":"postgres","HostConfig":{"Mounts":[{"Type":"volume","Source":"hostdata","Target":"/data"}]}}`, http.StatusForbidden},
+		{"new volume", `{"Image":"postgres","HostConfig":{"Binds":["pgdata:/data"]}}`, http.StatusCreated},
+		{"owner-labelled volume", `{"Image":"postgres","HostConfig":{"Binds":["labelled:/data"]}}`, http.StatusCreated},
+	}
+	for _, tt := range tests {
+		if got, body := do(t, client, "POST", "/v1.45/containers/create", tt.body); got != tt.want {
+			t.Errorf("%s: status %d, want %d (%s)", tt.name, got, tt.want, body)
+		}
+	}
+
+	// Once the daemon has made the new volume, later creates may reuse it.
+	daemon.mu.Lock()
+	_, made := daemon.volumes["pgdata"]
+	daemon.mu.Unlock()
+	if !made {
+		t.Fatal("the daemon did not make the new volume")
+	}
+	if got, body := do(t, client, "POST", "/v1.45/containers/create", tests[2].body); got != http.StatusCreated {
+		t.Errorf("reusing the session's volume: status %d (%s)", got, body)
+	}
+}
+
+// proxyClient returns a client that talks to the proxy at listenPath.
+func proxyClient(listenPath string) *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				return net.Dial("unix", listenPath)
+			},
+		},
+	}
+}
+
+// do sends a request through client and returns the status and body.
+func do(t *testing.T, client *http.Client, method, path, body string) (int, string) {
+	t.Helper()
+	req, err := http.NewRequest(method, "http://localhost"+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	data, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(data)
+}
+
+func TestProxy_ScopesToOwnedContainers(t *testing.T) {
+	tmpDir := t.TempDir()
+	listenPath := filepath.Join(tmpDir, "docker.sock")
+	hostPath := filepath.Join(tmpDir, "host.sock")
+	startFakeDaemon(t, hostPath)
+
+	p := New(hostPath, listenPath)
+	p.SetOwnedLabels([]string{"devsandbox.shared=true"})
+	if err := p.Start(context.Background()); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer func() { _ = p.Stop() }()
+	client := proxyClient(listenPath)
+
+	tests := []struct {
+		method, path string
+		want         int
+	}{
+		{"POST", "/v1.45/containers/hostdb/exec", http.StatusForbidden},
+		{"POST", "/v1.45/containers/hostdb/attach", http.StatusForbidden},
+		{"POST", "/v1.45/containers/hostdb/stop", http.StatusForbidden},
+		{"GET", "/v1.45/containers/hostdb/json", http.StatusForbidden},
+		{"GET", "/v1.45/containers/hostdb/logs", http.StatusForbidden},
+		{"GET", "/v1.45/exec/exec-hostdb/json", http.StatusForbidden},
+		{"GET", "/v1.45/containers/shared/json", http.StatusOK},
+		{"POST", "/v1.45/containers/shared/exec", http.StatusCreated},
+		{"GET", "/v1.45/exec/exec-shared/json", http.StatusOK},
+	}
+	for _, tt := range tests {
+		if got, body := do(t, client, tt.method, tt.path, "{}"); got != tt.want {
+			t.Errorf("%s %s: status %d, want %d (%s)", tt.method, tt.path, got, tt.want, body)
+		}
+	}
+
+	status, body := do(t, client, "GET", "/v1.45/containers/json", "")
+	if status != http.StatusOK {
+		t.Fatalf("list: status %d", status)
+	}
+	var list []struct {
+		ID string `json:"Id"`
+	}
+	if err := json.Unmarshal([]byte(body), &list); err != nil {
+		t.Fatalf("list body %q: %v", body, err)
+	}
+	if len(list) != 1 || list[0].ID != "shared" {
+		t.Errorf("list = %+v, want only the shared container", list)
+	}
+}
+
+func TestProxy_StopRemovesCreatedContainers(t *testing.T) {
+	tmpDir := t.TempDir()
+	listenPath := filepath.Join(tmpDir, "docker.sock")
+	hostPath := filepath.Join(tmpDir, "host.sock")
+	daemon := startFakeDaemon(t, hostPath)
+
+	p := New(hostPath, listenPath)
+	p.SetPolicy(NewPolicy(PolicyConfig{Images: []string{"postgres"}}))
+	if err := p.Start(context.Background()); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	client := proxyClient(listenPath)
+
+	for range 2 {
+		if got, body := do(t, client, "POST", "/v1.45/containers/create", `{"Image":"postgres"}`); got != http.StatusCreated {
+			t.Fatalf("create: status %d (%s)", got, body)
+		}
+	}
+	if got, _ := do(t, client, "DELETE", "/v1.45/containers/c1", ""); got != http.StatusNoContent {
+		t.Fatalf("rm own container: status %d", got)
+	}
+	if got, _ := do(t, client, "DELETE", "/v1.45/containers/hostdb", ""); got != http.StatusForbidden {
+		t.Errorf("rm host container: status %d, want 403", got)
+	}
+
+	if err := p.Stop(); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+
+	daemon.mu.Lock()
+	defer daemon.mu.Unlock()
+	if _, ok := daemon.containers["c2"]; ok {
+		t.Error("c2 still exists after Stop")
+	}
+	if !slices.Contains(daemon.removed, "c1") {
+		t.Error("c1 was not removed by the sandbox's rm")
+	}
+	if _, ok := daemon.containers["hostdb"]; !ok {
+		t.Error("Stop removed a container the sandbox did not create")
+	}
+	if len(daemon.containers) != 2 {
+		t.Errorf("containers after Stop = %v, want hostdb and shared", daemon.containers)
+	}
+}
+
+// TestProxy_StopRemovesCreatedVolumes pins that the named volumes the
+// session's creates introduced are deleted when it ends, and no other.
+func TestProxy_StopRemovesCreatedVolumes(t *testing.T) {
+	tmpDir := t.TempDir()
+	listenPath := filepath.Join(tmpDir, "docker.sock")
+	hostPath := filepath.Join(tmpDir, "host.sock")
+	daemon := startFakeDaemon(t, hostPath)
+
+	p := New(hostPath, listenPath)
+	p.SetPolicy(NewPolicy(PolicyConfig{Images: []string{"postgres"}, Owner: "session-1"}))
+	if err := p.Start(context.Background()); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	client := proxyClient(listenPath)
+
+	for _, body := range []string{
+		`{"Image":"postgres","HostConfig":{"Binds":["pgdata:/data"]}}`,
+		`{"Image":"postgres","HostConfig":{"Binds":["labelled:/data"]}}`,
+	} {
+		if got, resp := do(t, client, "POST", "/v1.45/containers/create", body); got != http.StatusCreated {
+			t.Fatalf("create: status %d (%s)", got, resp)
+		}
+	}
+	// Refused for its image, so the volume is never made.
+	if got, _ := do(t, client, "POST", "/v1.45/containers/create", `{"Image":"redis","HostConfig":{"Binds":["never:/data"]}}`); got != http.StatusForbidden {
+		t.Fatalf("create from a disallowed image: status %d, want 403", got)
+	}
+
+	if err := p.Stop(); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+
+	daemon.mu.Lock()
+	defer daemon.mu.Unlock()
+	if _, ok := daemon.volumes["pgdata"]; ok {
+		t.Error("pgdata still exists after Stop")
+	}
+	for _, name := range []string{"hostdata", "labelled"} {
+		if _, ok := daemon.volumes[name]; !ok {
+			t.Errorf("Stop removed volume %s, which the session did not introduce", name)
+		}
+	}
+	if !slices.Equal(daemon.removedVol, []string{"pgdata"}) {
+		t.Errorf("removed volumes = %v, want [pgdata]", daemon.removedVol)
+	}
+}
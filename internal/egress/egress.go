@@ -179,7 +179,9 @@ func RouteCommands(t Tools, gateway, dev string) [][]string {
 // gateway:proxyPort. Everything else - every other host, every other port of
 // the gateway, DNS - has no path out of the namespace. DNS is deliberately not
 // excepted: the proxy resolves hostnames itself and all traffic goes through
-// HTTP(S)_PROXY.
+// HTTP(S)_PROXY. The proxy's optional DNS resolver and SOCKS listener need no
+// exception either: the sandbox reaches both on loopback, which pasta forwards
+// to the host.
 //
 // What this table does NOT do is decide destinations. It reduces the sandbox to
 // one way out; where that way leads is internal/proxy's filter, which allows
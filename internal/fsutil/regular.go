@@ -0,0 +1,86 @@
+package fsutil
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// ErrNotRegularFile reports a path that is not a plain file - a symlink, a
+// directory, a device. ReadRegularFile refuses to read one.
+var ErrNotRegularFile = errors.New("not a regular file")
+
+// ReadRegularFile reads path, refusing to follow a symlink sitting there and
+// refusing to block on anything that is not a plain file.
+//
+// It exists for the host-side commands that rewrite .devsandbox.toml: that
+// path is inside the project directory, which is bind-mounted read-write into
+// the sandbox. A project with no .devsandbox.toml at launch time does not get
+// the protective /dev/null bind, so the sandbox can create that name as a
+// symlink to any host file - and a rewrite would then read that file, edit it
+// as TOML and hand it back.
+//
+// O_NONBLOCK is what makes the non-regular check below reachable. O_NOFOLLOW
+// covers symlinks only, and a FIFO at the same name blocks the open until a
+// writer appears - so the sandbox could create one and hang the command with no
+// output rather than get the refusal this function exists to give.
+func ReadRegularFile(path string) ([]byte, error) {
+	f, err := os.OpenFile(path, os.O_RDONLY|syscall.O_NOFOLLOW|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		if errors.Is(err, syscall.ELOOP) {
+			return nil, fmt.Errorf("%q: %w", path, ErrNotRegularFile)
+		}
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if !fi.Mode().IsRegular() {
+		return nil, fmt.Errorf("%q: %w", path, ErrNotRegularFile)
+	}
+	return io.ReadAll(f)
+}
+
+// ReplaceRegularFile replaces path atomically via a temporary file in the same
+// directory, so the write lands on the name itself rather than through whatever
+// a symlink at that name points to, and a failure part-way leaves the original
+// intact rather than truncated.
+//
+// An existing file keeps the permissions it had: rewriting one setting must not
+// be how a config the user deliberately kept at 0600 becomes world-readable.
+// Only a file this call creates gets 0644.
+func ReplaceRegularFile(path string, content []byte) (retErr error) {
+	perm := os.FileMode(0o644)
+	if fi, err := os.Stat(path); err == nil && fi.Mode().IsRegular() {
+		perm = fi.Mode().Perm()
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".devsandbox-config-*")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	defer func() {
+		if retErr != nil {
+			_ = os.Remove(tmpName)
+		}
+	}()
+
+	if _, err := tmp.Write(content); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpName, perm); err != nil {
+		return err
+	}
+	return os.Rename(tmpName, path)
+}
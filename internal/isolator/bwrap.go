@@ -168,6 +168,12 @@ func (b *BwrapIsolator) Run(ctx context.Context, cfg *RunConfig) error {
 	if cfg.AppCfg.PortForwarding.IsEnabled() {
 		portForwardArgs = sandbox.BuildPastaPortArgs(cfg.AppCfg.PortForwarding.Rules)
 	}
+	if sandboxCfg.NetworkIsolated && sandboxCfg.DNSPort > 0 {
+		portForwardArgs = append(portForwardArgs, sandbox.BuildPastaDNSArgs(sandboxCfg.DNSPort)...)
+	}
+	if sandboxCfg.NetworkIsolated && sandboxCfg.SOCKSPort > 0 {
+		portForwardArgs = append(portForwardArgs, sandbox.BuildPastaSOCKSArgs(sandboxCfg.SOCKSPort)...)
+	}
 
 	return b.launch(cfg, bwrapArgs, shellCmd, portForwardArgs)
 }
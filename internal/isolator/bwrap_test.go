@@ -7,6 +7,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"reflect"
 	"runtime"
 	"slices"
 	"strings"
@@ -141,7 +142,7 @@ func TestBwrapLaunchCarriesTheConfiguredLimits(t *testing.T) {
 			if gotLaunch != tt.wantLaunch {
 				t.Errorf("launch() used %q, want %q", gotLaunch, tt.wantLaunch)
 			}
-			if got != want {
+			if !reflect.DeepEqual(got, want) {
 				t.Errorf("%s received limits %+v, want the configured %+v", gotLaunch, got, want)
 			}
 		})
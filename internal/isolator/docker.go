@@ -5,6 +5,7 @@ import (
 	"crypto/sha256"
 	"errors"
 	"fmt"
+	"math"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -15,6 +16,7 @@ import (
 	"sync"
 	"time"
 
+	"devsandbox/internal/cgroups"
 	"devsandbox/internal/config"
 	"devsandbox/internal/egress"
 	"devsandbox/internal/logging"
@@ -84,6 +86,10 @@ type DockerConfig struct {
 	// PIDsLimit is the max number of processes/threads. Zero means unlimited.
 	// Not enforceable on the krun engine - see buildCommonArgs.
 	PIDsLimit int
+	// IOLimits carries memory_high, memory_swap and the io_* limits, with the
+	// paths whose disks the bandwidth caps apply to. Its Memory, CPUs and PIDs
+	// are unused: those have the fields above.
+	IOLimits cgroups.Limits
 	// KeepContainer keeps the container after exit for fast restarts.
 	KeepContainer bool
 }
@@ -1438,6 +1444,7 @@ func (d *DockerIsolator) buildCommonArgs(cfg *Config) ([]string, error) {
 			args = append(args, "--pids-limit", strconv.Itoa(d.config.PIDsLimit))
 		}
 	}
+	args = append(args, d.ioLimitArgs()...)
 
 	// Read-only bindings (host configs)
 	for _, b := range cfg.Bindings {
@@ -1457,6 +1464,76 @@ func (d *DockerIsolator) buildCommonArgs(cfg *Config) ([]string, error) {
 	return args, nil
 }
 
+// ioLimitArgs translates memory_high, memory_swap and the io_* limits into
+// engine flags. A limit the engine cannot apply is skipped with an alert, as
+// pids is on krun: the config was valid, only this backend cannot honor it,
+// and the user has to know they are not protected by it.
+func (d *DockerIsolator) ioLimitArgs() []string {
+	l := d.config.IOLimits
+	var args []string
+
+	if l.MemoryHigh != "" {
+		// docker has no flag for memory.high. --memory-reservation looks like
+		// one but sets memory.low, a protection floor - the opposite. podman
+		// passes cgroup v2 settings through as they are.
+		if d.engine.binary == "podman" {
+			if n, err := cgroups.ParseBytes(l.MemoryHigh); err == nil {
+				args = append(args, "--cgroup-conf", "memory.high="+strconv.FormatInt(n, 10))
+			}
+		} else {
+			d.logAlert("%s: memory_high = %s was NOT applied. Docker has no flag for a soft memory limit "+
+				"(--memory-reservation sets a protection floor, which is the opposite), so only the hard memory "+
+				"limit applies. Use the bwrap or krun backend for memory_high.", d.engine.binary, l.MemoryHigh)
+		}
+	}
+
+	if l.MemorySwap != "" {
+		// --memory-swap is the memory+swap total, so the allowance is added to
+		// the memory limit. Config validation requires one; krun has a default.
+		mem, memErr := cgroups.ParseBytes(d.config.MemoryLimit)
+		swap, swapErr := cgroups.ParseBytes(l.MemorySwap)
+		if memErr != nil || swapErr != nil || mem == 0 {
+			d.logAlert("%s: memory_swap = %s was NOT applied: it needs a non-zero memory limit to add to.", d.engine.binary, l.MemorySwap)
+		} else {
+			args = append(args, "--memory-swap", strconv.FormatInt(mem+swap, 10))
+		}
+	}
+
+	if l.IOWeight > 0 {
+		args = append(args, "--blkio-weight", strconv.Itoa(blkioWeight(l.IOWeight)))
+	}
+
+	if l.IOReadBPS != "" || l.IOWriteBPS != "" {
+		// The engine takes device nodes, which are resolved here, on this
+		// host. That is the engine's host too unless it runs in a VM (Docker
+		// Desktop) or behind DOCKER_HOST, and BlockDevices fails off Linux.
+		devices, err := cgroups.BlockDevices(l.IOPaths)
+		if err != nil {
+			d.logAlert("%s: io_read_bps/io_write_bps were NOT applied: %v. Disk bandwidth inside this sandbox is unlimited.", d.engine.binary, err)
+			return args
+		}
+		for _, dev := range devices {
+			if l.IOReadBPS != "" {
+				args = append(args, "--device-read-bps", dev+":"+l.IOReadBPS)
+			}
+			if l.IOWriteBPS != "" {
+				args = append(args, "--device-write-bps", dev+":"+l.IOWriteBPS)
+			}
+		}
+	}
+
+	return args
+}
+
+// blkioWeight converts a cgroup v2 io.weight (1-10000) to the engine's
+// --blkio-weight scale (10-1000). On a cgroup v2 host the runtime converts it
+// back with 1+(w-10)*9999/990, so this is that formula's inverse, rounded: the
+// round trip lands within about 1% of the configured weight.
+func blkioWeight(ioWeight int) int {
+	w := 10 + int(math.Round(float64(ioWeight-1)*990/9999))
+	return min(max(w, 10), 1000)
+}
+
 // Cleanup performs any post-sandbox cleanup.
 // Removes the per-session Docker network if one was created.
 //
@@ -1769,6 +1846,9 @@ func (d *DockerIsolator) configHash(cfg *Config) string {
 	_, _ = fmt.Fprintf(h, "mem=%s\n", d.config.MemoryLimit)
 	_, _ = fmt.Fprintf(h, "cpu=%s\n", d.config.CPULimit)
 	_, _ = fmt.Fprintf(h, "pids=%d\n", d.config.PIDsLimit)
+	io := d.config.IOLimits
+	_, _ = fmt.Fprintf(h, "mem_high=%s\nswap=%s\n", io.MemoryHigh, io.MemorySwap)
+	_, _ = fmt.Fprintf(h, "io=%s/%s/%d\n", io.IOReadBPS, io.IOWriteBPS, io.IOWeight)
 
 	// Bindings — volume mounts passed to docker create.
 	_, _ = fmt.Fprintf(h, "mount_mode=%s\n", cfg.DefaultMountMode)
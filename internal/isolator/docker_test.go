@@ -1,17 +1,22 @@
 package isolator
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
 	"slices"
+	"strconv"
 	"strings"
 	"sync"
 	"testing"
 
+	"devsandbox/internal/cgroups"
+	"devsandbox/internal/notice"
 	"devsandbox/internal/sandbox/tools"
 )
 
@@ -908,6 +913,67 @@ func TestDockerIsolator_PIDsLimit(t *testing.T) {
 	}
 }
 
+func TestDockerIsolator_IOLimits(t *testing.T) {
+	var stderr bytes.Buffer
+	if err := notice.Setup("", false, &stderr); err != nil {
+		t.Fatalf("notice.Setup: %v", err)
+	}
+	t.Cleanup(func() { _ = notice.Setup("", false, io.Discard) })
+
+	iso := NewDockerIsolator(DockerConfig{
+		MemoryLimit: "4g",
+		IOLimits:    cgroups.Limits{MemoryHigh: "3g", MemorySwap: "1g", IOWeight: 100},
+	})
+	args := iso.ioLimitArgs()
+
+	assertFlagValue(t, args, "--memory-swap", strconv.FormatInt(5<<30, 10))
+	assertFlagValue(t, args, "--blkio-weight", "20")
+	if slices.Contains(args, "--cgroup-conf") || slices.Contains(args, "--memory-reservation") {
+		t.Errorf("docker has no memory.high flag and must not emit a stand-in, got: %v", args)
+	}
+	if !strings.Contains(stderr.String(), "memory_high = 3g was NOT applied") {
+		t.Errorf("skipped memory_high must be reported, got: %q", stderr.String())
+	}
+
+	krun := NewKrunIsolator(DockerConfig{MemoryLimit: "4g", IOLimits: cgroups.Limits{MemoryHigh: "3g"}})
+	assertFlagValue(t, krun.ioLimitArgs(), "--cgroup-conf", "memory.high="+strconv.FormatInt(3<<30, 10))
+}
+
+func TestDockerIsolator_IOBandwidth(t *testing.T) {
+	var stderr bytes.Buffer
+	if err := notice.Setup("", false, &stderr); err != nil {
+		t.Fatalf("notice.Setup: %v", err)
+	}
+	t.Cleanup(func() { _ = notice.Setup("", false, io.Discard) })
+
+	unresolved := NewDockerIsolator(DockerConfig{IOLimits: cgroups.Limits{IOWriteBPS: "50m"}})
+	if args := unresolved.ioLimitArgs(); len(args) != 0 {
+		t.Errorf("no device could be resolved, want no flags, got %v", args)
+	}
+	if !strings.Contains(stderr.String(), "io_read_bps/io_write_bps were NOT applied") {
+		t.Errorf("skipped bandwidth cap must be reported, got: %q", stderr.String())
+	}
+
+	dir := t.TempDir()
+	devices, err := cgroups.BlockDevices([]string{dir})
+	if err != nil {
+		t.Skipf("no block device behind %s: %v", dir, err)
+	}
+	iso := NewDockerIsolator(DockerConfig{IOLimits: cgroups.Limits{IOReadBPS: "200m", IOWriteBPS: "50m", IOPaths: []string{dir}}})
+	args := iso.ioLimitArgs()
+	assertFlagValue(t, args, "--device-read-bps", devices[0]+":200m")
+	assertFlagValue(t, args, "--device-write-bps", devices[0]+":50m")
+}
+
+func TestBlkioWeight(t *testing.T) {
+	tests := map[int]int{1: 10, 100: 20, 5000: 505, 10000: 1000}
+	for in, want := range tests {
+		if got := blkioWeight(in); got != want {
+			t.Errorf("blkioWeight(%d) = %d, want %d", in, got, want)
+		}
+	}
+}
+
 func TestDockerIsolator_BuildDocker_KeepContainer_ConfigHashLabel(t *testing.T) {
 	skipIfNoDocker(t)
 
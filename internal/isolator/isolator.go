@@ -57,6 +57,12 @@ type RunConfig struct {
 	// the namespace path. Can be nil.
 	OnSandboxStart func(nsPID int, nsPath string)
 
+	// OnCgroupResolved is called with the sandbox's cgroup directory once it
+	// is known, from a background goroutine and possibly after OnSandboxStart.
+	// Only called for a sandbox that has its own cgroup: a bwrap launch with
+	// [sandbox.resources] limits, or a container. Can be nil.
+	OnCgroupResolved func(dir string)
+
 	// SandboxName is the human-readable session name (from --name or auto-generated).
 	SandboxName string
 }
@@ -162,7 +168,18 @@ type options struct {
 	// [sandbox.docker.resources] section, and are read by the container
 	// backends only. See WithResources.
 	containerLimits cgroups.Limits
-	keepContainer   bool
+	// ioPaths complete both limit sets. See WithIOPaths.
+	ioPaths       []string
+	keepContainer bool
+}
+
+// WithIOPaths sets the paths whose backing disks the io_read_bps and
+// io_write_bps caps apply to: a bandwidth cap is per disk, and these are the
+// places the sandbox writes.
+func WithIOPaths(paths ...string) Option {
+	return func(o *options) {
+		o.ioPaths = paths
+	}
 }
 
 // WithDockerConfig sets Docker-specific configuration.
@@ -196,7 +213,9 @@ func WithResources(neutral, container cgroups.Limits) Option {
 
 // bwrapConfig builds the bwrap backend configuration from the options.
 func (o options) bwrapConfig() BwrapConfig {
-	return BwrapConfig{Limits: o.limits}
+	limits := o.limits
+	limits.IOPaths = o.ioPaths
+	return BwrapConfig{Limits: limits}
 }
 
 // dockerConfig builds the docker backend configuration from the options.
@@ -208,10 +227,25 @@ func (o options) dockerConfig() DockerConfig {
 		MemoryLimit:   o.containerLimits.Memory,
 		CPULimit:      o.containerLimits.CPUs,
 		PIDsLimit:     o.containerLimits.PIDs,
+		IOLimits:      o.containerIOLimits(),
 		KeepContainer: o.keepContainer,
 	}
 }
 
+// containerIOLimits picks the container limits beyond memory, cpus and pids,
+// which keep their own DockerConfig fields, for buildCommonArgs to translate.
+func (o options) containerIOLimits() cgroups.Limits {
+	l := o.containerLimits
+	return cgroups.Limits{
+		MemoryHigh: l.MemoryHigh,
+		MemorySwap: l.MemorySwap,
+		IOReadBPS:  l.IOReadBPS,
+		IOWriteBPS: l.IOWriteBPS,
+		IOWeight:   l.IOWeight,
+		IOPaths:    o.ioPaths,
+	}
+}
+
 // krunConfig builds the krun backend configuration from the options.
 //
 // krun runs ephemeral (a fresh microVM per launch) regardless of the
@@ -234,6 +268,7 @@ func (o options) krunConfig() DockerConfig {
 		MemoryLimit:   memLimit,
 		CPULimit:      cpuLimit,
 		PIDsLimit:     o.containerLimits.PIDs,
+		IOLimits:      o.containerIOLimits(),
 		KeepContainer: false,
 	}
 }
@@ -1,7 +1,9 @@
 package isolator
 
 import (
+	"reflect"
 	"runtime"
+	"slices"
 	"testing"
 
 	"devsandbox/internal/cgroups"
@@ -101,7 +103,7 @@ func TestWithResources_BwrapConfig(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			got := applyOptions(tt.opts...).bwrapConfig().Limits
-			if got != tt.want {
+			if !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("bwrapConfig().Limits = %+v, want %+v", got, tt.want)
 			}
 		})
@@ -131,6 +133,24 @@ func TestWithResources_DockerConfig(t *testing.T) {
 	}
 }
 
+// TestWithIOPaths verifies the io paths reach every backend whatever order the
+// options come in, and that io limits reach the container backends.
+func TestWithIOPaths(t *testing.T) {
+	l := cgroups.Limits{MemorySwap: "1g", IOWriteBPS: "50m", IOWeight: 50}
+	o := applyOptions(WithIOPaths("/project", "/state"), WithResources(l, l))
+	want := []string{"/project", "/state"}
+
+	if got := o.bwrapConfig().Limits; !slices.Equal(got.IOPaths, want) || got.IOWriteBPS != "50m" {
+		t.Errorf("bwrap limits = %+v, want the io limit and paths %v", got, want)
+	}
+	for name, cfg := range map[string]DockerConfig{"docker": o.dockerConfig(), "krun": o.krunConfig()} {
+		io := cfg.IOLimits
+		if !slices.Equal(io.IOPaths, want) || io.IOWriteBPS != "50m" || io.IOWeight != 50 || io.MemorySwap != "1g" {
+			t.Errorf("%s io limits = %+v, want the configured ones with paths %v", name, io, want)
+		}
+	}
+}
+
 // The deprecated [sandbox.docker.resources] section is scoped to the container
 // backends. It reaches devsandbox as the container argument of WithResources
 // only, and bwrap must see nothing from it: bwrap never honored that section,
@@ -207,7 +227,7 @@ func TestNew_BwrapReceivesResources(t *testing.T) {
 		t.Fatalf("New(BackendBwrap) returned %T, want *BwrapIsolator", iso)
 	}
 	want := cgroups.Limits{Memory: "512m", CPUs: "0.5", PIDs: 64}
-	if b.config.Limits != want {
+	if !reflect.DeepEqual(b.config.Limits, want) {
 		t.Errorf("bwrap limits = %+v, want %+v", b.config.Limits, want)
 	}
 }
@@ -57,6 +57,7 @@ type oomMonitor struct {
 	sandboxRoot string
 	memoryLimit string
 	limited     bool
+	onResolved  func(dir string)
 
 	// cancel abandons an attach still waiting for the scope, and attached is
 	// closed once the attach attempt is over. finish uses both so devsandbox never
@@ -130,6 +131,7 @@ func newOOMMonitor(cfg *RunConfig, memoryLimit string, limited bool) *oomMonitor
 		sandboxRoot: cfg.SandboxCfg.SandboxRoot,
 		memoryLimit: memoryLimit,
 		limited:     limited,
+		onResolved:  cfg.OnCgroupResolved,
 		cancel:      func() {},
 		attached:    make(chan struct{}),
 	}
@@ -158,6 +160,9 @@ func (m *oomMonitor) attach(timeout time.Duration, own cgroups.Ownership, resolv
 			warnOOMWatchUnavailable(err)
 			return
 		}
+		if m.onResolved != nil {
+			m.onResolved(dir)
+		}
 		watcher, err := cgroups.WatchOOM(dir, own, m.observed)
 		if err != nil {
 			warnOOMWatchUnavailable(err)
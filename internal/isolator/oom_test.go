@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -133,6 +134,29 @@ func TestStartOOMMonitorDoesNotBlockTheLaunch(t *testing.T) {
 	}
 }
 
+// The cgroup directory is handed on as soon as it is resolved, so the session
+// can record it for `devsandbox sessions stats` whether or not the watch itself
+// then attaches.
+func TestAttachReportsTheResolvedCgroup(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "memory.events"), []byte("oom 0\noom_kill 0\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var got string
+	cfg := &RunConfig{
+		SandboxCfg:       &sandbox.Config{SandboxRoot: t.TempDir()},
+		OnCgroupResolved: func(d string) { got = d },
+	}
+
+	m := newOOMMonitor(cfg, "512m", true)
+	m.attach(time.Second, cgroups.CgroupFresh, func(context.Context) (string, error) { return dir, nil })
+	<-m.attached
+	t.Cleanup(func() { m.finish(nil) })
+	if got != dir {
+		t.Errorf("OnCgroupResolved got %q, want %q", got, dir)
+	}
+}
+
 // The unattributable states are silent by design: a launch with no limits, and a
 // sandbox that exited before its cgroup could be resolved, are both normal. A
 // watch that should have worked is not - a user who is told nothing will assume
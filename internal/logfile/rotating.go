@@ -1,4 +1,7 @@
-package proxy
+// Package logfile writes append-only log files that rotate by size, are
+// archived with gzip, and are pruned to a bounded count. Concurrent sessions
+// may share a directory: each active file is claimed with an advisory lock.
+package logfile
 
 import (
 	"bufio"
@@ -52,7 +52,7 @@ func NewDispatcherWithConfig(cfg DispatcherConfig) (*Dispatcher, error) {
 	}
 
 	for i, r := range cfg.Receivers {
-		w, err := newWriterFromConfig(r, cfg.GlobalAttrs, errorLogger)
+		w, err := newWriterFromConfig(r, cfg, errorLogger)
 		if err != nil {
 			// Close any already-created writers
 			_ = d.Close()
@@ -65,7 +65,8 @@ func NewDispatcherWithConfig(cfg DispatcherConfig) (*Dispatcher, error) {
 }
 
 // newWriterFromConfig creates a Writer from a ReceiverConfig.
-func newWriterFromConfig(r config.ReceiverConfig, globalAttrs map[string]string, errorLogger *ErrorLogger) (Writer, error) {
+func newWriterFromConfig(r config.ReceiverConfig, dcfg DispatcherConfig, errorLogger *ErrorLogger) (Writer, error) {
+	globalAttrs := dcfg.GlobalAttrs
 	switch r.Type {
 	case "syslog":
 		return NewSyslogWriter(SyslogConfig{
@@ -102,7 +103,7 @@ func newWriterFromConfig(r config.ReceiverConfig, globalAttrs map[string]string,
 			protocol = "http"
 		}
 
-		headers, err := resolveOTLPHeaders(r.Headers, r.HeaderSources)
+		headers, err := resolveHeaders(r.Type, r.Headers, r.HeaderSources)
 		if err != nil {
 			return nil, err
 		}
@@ -127,16 +128,71 @@ func newWriterFromConfig(r config.ReceiverConfig, globalAttrs map[string]string,
 
 		return NewOTLPWriter(cfg)
 
+	case "file":
+		if r.Path == "" {
+			return nil, fmt.Errorf("path is required for file receiver")
+		}
+		return NewFileWriter(FileConfig{
+			Dir:          source.ExpandHome(r.Path),
+			MaxFileBytes: r.MaxFileBytes,
+			MaxFiles:     r.MaxFiles,
+			Attributes:   globalAttrs,
+			ErrorLogger:  errorLogger,
+		})
+
+	case "webhook":
+		endpoint := r.Endpoint
+		if endpoint == "" {
+			endpoint = r.Address
+		}
+		if endpoint == "" {
+			return nil, fmt.Errorf("endpoint is required for webhook receiver")
+		}
+
+		headers, err := resolveHeaders(r.Type, r.Headers, r.HeaderSources)
+		if err != nil {
+			return nil, err
+		}
+
+		// Spool next to the internal error log unless told otherwise; with
+		// neither, undeliverable batches are dropped.
+		spoolDir := source.ExpandHome(r.SpoolDir)
+		if spoolDir == "" && dcfg.ErrorLogDir != "" {
+			spoolDir = spoolDirFor(dcfg.ErrorLogDir, endpoint)
+		}
+
+		cfg := WebhookConfig{
+			Endpoint:      endpoint,
+			Headers:       headers,
+			BatchSize:     r.BatchSize,
+			MaxRetries:    r.MaxRetries,
+			SpoolDir:      spoolDir,
+			SpoolMaxBytes: r.SpoolMaxBytes,
+			Attributes:    globalAttrs,
+			ErrorLogger:   errorLogger,
+		}
+
+		if r.FlushInterval != "" {
+			d, err := time.ParseDuration(r.FlushInterval)
+			if err != nil {
+				return nil, fmt.Errorf("invalid flush_interval: %w", err)
+			}
+			cfg.FlushInterval = d
+		}
+
+		return NewWebhookWriter(cfg)
+
 	default:
 		return nil, fmt.Errorf("unknown receiver type: %s", r.Type)
 	}
 }
 
-// resolveOTLPHeaders merges static headers with header sources resolved from
-// env/file/value. Sources take precedence on key collisions. An env-sourced
-// header that resolves to an empty string is rejected to avoid silently
-// sending unauthenticated logs to an auth-enforced endpoint.
-func resolveOTLPHeaders(static map[string]string, sources map[string]source.Source) (map[string]string, error) {
+// resolveHeaders merges static headers with header sources resolved from
+// env/file/value for an HTTP receiver of the given type. Sources take
+// precedence on key collisions. An env-sourced header that resolves to an
+// empty string is rejected to avoid silently sending unauthenticated logs to
+// an auth-enforced endpoint.
+func resolveHeaders(receiverType string, static map[string]string, sources map[string]source.Source) (map[string]string, error) {
 	if len(static) == 0 && len(sources) == 0 {
 		return nil, nil
 	}
@@ -147,10 +203,10 @@ func resolveOTLPHeaders(static map[string]string, sources map[string]source.Sour
 	for name, src := range sources {
 		val, err := src.Resolve()
 		if err != nil {
-			return nil, fmt.Errorf("otlp header %q: %w", name, err)
+			return nil, fmt.Errorf("%s header %q: %w", receiverType, name, err)
 		}
 		if val == "" {
-			return nil, fmt.Errorf("otlp header %q: source resolved to empty value (env var unset or file empty)", name)
+			return nil, fmt.Errorf("%s header %q: source resolved to empty value (env var unset or file empty)", receiverType, name)
 		}
 		out[name] = val
 	}
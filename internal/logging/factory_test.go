@@ -11,7 +11,7 @@ import (
 )
 
 func TestResolveOTLPHeaders_StaticOnly(t *testing.T) {
-	headers, err := resolveOTLPHeaders(map[string]string{"X-Team": "platform"}, nil)
+	headers, err := resolveHeaders("otlp", map[string]string{"X-Team": "platform"}, nil)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -23,7 +23,7 @@ func TestResolveOTLPHeaders_StaticOnly(t *testing.T) {
 func TestResolveOTLPHeaders_FromEnv(t *testing.T) {
 	t.Setenv("OTLP_TEST_TOKEN", "Bearer abc123")
 
-	headers, err := resolveOTLPHeaders(nil, map[string]source.Source{
+	headers, err := resolveHeaders("otlp", nil, map[string]source.Source{
 		"Authorization": {Env: "OTLP_TEST_TOKEN"},
 	})
 	if err != nil {
@@ -39,7 +39,7 @@ func TestResolveOTLPHeaders_EnvUnsetIsError(t *testing.T) {
 		t.Fatalf("unsetenv: %v", err)
 	}
 
-	_, err := resolveOTLPHeaders(nil, map[string]source.Source{
+	_, err := resolveHeaders("otlp", nil, map[string]source.Source{
 		"Authorization": {Env: "OTLP_TEST_MISSING"},
 	})
 	if err == nil {
@@ -57,7 +57,7 @@ func TestResolveOTLPHeaders_FromFile(t *testing.T) {
 		t.Fatalf("write token: %v", err)
 	}
 
-	headers, err := resolveOTLPHeaders(nil, map[string]source.Source{
+	headers, err := resolveHeaders("otlp", nil, map[string]source.Source{
 		"Authorization": {File: path},
 	})
 	if err != nil {
@@ -71,7 +71,7 @@ func TestResolveOTLPHeaders_FromFile(t *testing.T) {
 func TestResolveOTLPHeaders_SourceWinsOverStatic(t *testing.T) {
 	t.Setenv("OTLP_TEST_TOKEN", "from-source")
 
-	headers, err := resolveOTLPHeaders(
+	headers, err := resolveHeaders("otlp",
 		map[string]string{"Authorization": "from-static"},
 		map[string]source.Source{"Authorization": {Env: "OTLP_TEST_TOKEN"}},
 	)
@@ -84,7 +84,7 @@ func TestResolveOTLPHeaders_SourceWinsOverStatic(t *testing.T) {
 }
 
 func TestResolveOTLPHeaders_NilWhenEmpty(t *testing.T) {
-	headers, err := resolveOTLPHeaders(nil, nil)
+	headers, err := resolveHeaders("otlp", nil, nil)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -106,7 +106,7 @@ func TestNewWriterFromConfig_OTLPHeaderSources(t *testing.T) {
 		},
 	}
 
-	w, err := newWriterFromConfig(r, nil, nil)
+	w, err := newWriterFromConfig(r, DispatcherConfig{}, nil)
 	if err != nil {
 		t.Fatalf("newWriterFromConfig: %v", err)
 	}
@@ -138,7 +138,7 @@ func TestNewWriterFromConfig_OTLPHeaderSourceUnsetEnv(t *testing.T) {
 		},
 	}
 
-	if _, err := newWriterFromConfig(r, nil, nil); err == nil {
+	if _, err := newWriterFromConfig(r, DispatcherConfig{}, nil); err == nil {
 		t.Fatal("expected error when header source env var is unset")
 	}
 }
@@ -0,0 +1,78 @@
+package logging
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"devsandbox/internal/logfile"
+)
+
+// FileConfig contains file writer configuration.
+type FileConfig struct {
+	// Dir is the directory the JSONL files are written to.
+	Dir string
+
+	// MaxFileBytes is the size at which the active file is rotated
+	// (default: 50 MiB).
+	MaxFileBytes int64
+
+	// MaxFiles is the number of files, active and archived, kept in Dir
+	// (default: 5).
+	MaxFiles int
+
+	// Attributes are custom attributes added to every record.
+	Attributes map[string]string
+
+	// ErrorLogger logs internal errors to a file (optional).
+	ErrorLogger *ErrorLogger
+}
+
+// fileLogPrefix names the receiver's files: audit_<date>_<index>.jsonl.
+const fileLogPrefix = "audit"
+
+// FileWriter writes entries as JSON lines to size-rotated, gzip-archived
+// files. Sessions of different projects may share a directory; each writes
+// its own active file.
+type FileWriter struct {
+	w           *logfile.RotatingFileWriter
+	attrs       map[string]string
+	errorLogger *ErrorLogger
+}
+
+// NewFileWriter creates a new file writer.
+func NewFileWriter(cfg FileConfig) (*FileWriter, error) {
+	if cfg.Dir == "" {
+		return nil, fmt.Errorf("file writer directory is required")
+	}
+	w, err := logfile.NewRotatingFileWriter(logfile.RotatingFileWriterConfig{
+		Dir:           cfg.Dir,
+		Prefix:        fileLogPrefix,
+		Suffix:        ".jsonl",
+		ArchiveSuffix: ".jsonl.gz",
+		MaxSize:       cfg.MaxFileBytes,
+		MaxFiles:      cfg.MaxFiles,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &FileWriter{w: w, attrs: cfg.Attributes, errorLogger: cfg.ErrorLogger}, nil
+}
+
+// Write appends the entry as one JSON line.
+func (w *FileWriter) Write(entry *Entry) error {
+	data, err := json.Marshal(newRecord(entry, w.attrs))
+	if err != nil {
+		w.errorLogger.LogErrorf("file", "failed to encode entry %q: %v", entry.Message, err)
+		return err
+	}
+	if _, err := w.w.Write(append(data, '\n')); err != nil {
+		w.errorLogger.LogErrorf("file", "failed to write entry to %s: %v", w.w.CurrentPath(), err)
+		return err
+	}
+	return nil
+}
+
+// Close flushes and closes the active file.
+func (w *FileWriter) Close() error {
+	return w.w.Close()
+}
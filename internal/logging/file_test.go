@@ -0,0 +1,105 @@
+package logging
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"devsandbox/internal/config"
+)
+
+func readRecords(t *testing.T, path string) []Record {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open %s: %v", path, err)
+	}
+	defer func() { _ = f.Close() }()
+
+	var records []Record
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var r Record
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			t.Fatalf("line %q is not a record: %v", scanner.Text(), err)
+		}
+		records = append(records, r)
+	}
+	return records
+}
+
+func TestFileWriter_WritesJSONLines(t *testing.T) {
+	dir := t.TempDir()
+	w, err := NewFileWriter(FileConfig{Dir: dir, Attributes: map[string]string{"team": "platform"}})
+	if err != nil {
+		t.Fatalf("NewFileWriter: %v", err)
+	}
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	entries := []*Entry{
+		{Timestamp: now, Level: LevelInfo, Message: "GET /x", Fields: map[string]any{"component": "proxy", "session_id": "abc"}},
+		{Timestamp: now, Level: LevelWarn, Message: "session.start", Fields: map[string]any{"session_id": "abc"}},
+	}
+	for _, e := range entries {
+		if err := w.Write(e); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+	path := w.w.CurrentPath()
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	if filepath.Ext(path) != ".jsonl" {
+		t.Errorf("file %s, want a .jsonl file", path)
+	}
+	records := readRecords(t, path)
+	if len(records) != 2 {
+		t.Fatalf("got %d records, want 2", len(records))
+	}
+
+	first := records[0]
+	if first.Message != "GET /x" || first.Level != LevelInfo || !first.Timestamp.Equal(now) {
+		t.Errorf("record = %+v", first)
+	}
+	if first.Scope != "devsandbox.proxy" {
+		t.Errorf("scope = %q, want devsandbox.proxy", first.Scope)
+	}
+	if first.Service.Name != "devsandbox" {
+		t.Errorf("service.name = %q, want devsandbox", first.Service.Name)
+	}
+	if first.Fields["session_id"] != "abc" {
+		t.Errorf("fields = %v, want session_id=abc", first.Fields)
+	}
+	if first.Attributes["team"] != "platform" {
+		t.Errorf("attributes = %v, want team=platform", first.Attributes)
+	}
+	if records[1].Scope != "devsandbox" || records[1].Level != LevelWarn {
+		t.Errorf("second record = %+v", records[1])
+	}
+}
+
+func TestFileWriter_RequiresDir(t *testing.T) {
+	if _, err := NewFileWriter(FileConfig{}); err == nil {
+		t.Fatal("expected error without a directory")
+	}
+}
+
+func TestNewWriterFromConfig_File(t *testing.T) {
+	dir := t.TempDir()
+	w, err := newWriterFromConfig(config.ReceiverConfig{Type: "file", Path: dir, MaxFiles: 2}, DispatcherConfig{}, nil)
+	if err != nil {
+		t.Fatalf("newWriterFromConfig: %v", err)
+	}
+	defer func() { _ = w.Close() }()
+	if _, ok := w.(*FileWriter); !ok {
+		t.Fatalf("expected *FileWriter, got %T", w)
+	}
+
+	if _, err := newWriterFromConfig(config.ReceiverConfig{Type: "file"}, DispatcherConfig{}, nil); err == nil {
+		t.Fatal("expected error without path")
+	}
+}
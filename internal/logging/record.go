@@ -0,0 +1,45 @@
+package logging
+
+import (
+	"time"
+
+	"devsandbox/internal/version"
+)
+
+// Record is the JSON shape the file and webhook receivers emit for an Entry:
+// one object per line in a file, an array of them per webhook request.
+//
+// Fields carries everything the dispatcher attached - the session Context
+// fields (session_id, sandbox_name, ...) as well as the event's own - so a
+// record is self-describing without the OTLP resource envelope. Attributes
+// are the [logging.attributes] that OTLP sends as resource attributes.
+type Record struct {
+	Timestamp  time.Time         `json:"timestamp"`
+	Level      Level             `json:"level"`
+	Message    string            `json:"message"`
+	Scope      string            `json:"scope"`
+	Service    RecordService     `json:"service"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+	Fields     map[string]any    `json:"fields,omitempty"`
+}
+
+// RecordService identifies the devsandbox build that produced a record.
+type RecordService struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	Commit  string `json:"commit,omitempty"`
+}
+
+// newRecord converts an entry. The scope is derived from the entry's
+// component the same way OTLP derives its instrumentation scope.
+func newRecord(e *Entry, attrs map[string]string) Record {
+	return Record{
+		Timestamp:  e.Timestamp,
+		Level:      e.Level,
+		Message:    e.Message,
+		Scope:      scopeNameFor(e),
+		Service:    RecordService{Name: "devsandbox", Version: version.Version, Commit: version.Commit},
+		Attributes: attrs,
+		Fields:     e.Fields,
+	}
+}
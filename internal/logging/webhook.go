@@ -0,0 +1,443 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// WebhookConfig contains webhook writer configuration.
+type WebhookConfig struct {
+	// Endpoint is the URL batches are POSTed to.
+	Endpoint string
+
+	// Headers are sent with every request (for authentication, etc.).
+	Headers map[string]string
+
+	// BatchSize is the number of entries before automatic flush (default: 100).
+	BatchSize int
+
+	// FlushInterval is the maximum time before flushing buffered entries
+	// (default: 5s).
+	FlushInterval time.Duration
+
+	// Timeout is the request timeout (default: 10s).
+	Timeout time.Duration
+
+	// MaxRetries is how many times a failed batch is re-sent before it is
+	// spooled. Nil means 3; zero disables retries.
+	MaxRetries *int
+
+	// SpoolDir holds batches that could not be delivered. Empty disables the
+	// spool: such batches are dropped and the loss is logged.
+	SpoolDir string
+
+	// SpoolMaxBytes bounds SpoolDir; the oldest batches are dropped to stay
+	// under it (default: 10 MiB).
+	SpoolMaxBytes int64
+
+	// Attributes are custom attributes added to every record.
+	Attributes map[string]string
+
+	// ErrorLogger logs internal errors to a file (optional).
+	ErrorLogger *ErrorLogger
+}
+
+const (
+	defaultWebhookMaxRetries    = 3
+	defaultWebhookSpoolMaxBytes = 10 * 1024 * 1024
+
+	// webhookRetryBase is the first backoff; each retry doubles it.
+	webhookRetryBase = time.Second
+	// webhookSpoolRetryInterval is how often the spool is retried while the
+	// endpoint stays down and nothing new arrives to prove it is back.
+	webhookSpoolRetryInterval = 30 * time.Second
+	// webhookQueueSize bounds batches waiting for the sender. A full queue
+	// sends new batches straight to the spool.
+	webhookQueueSize = 16
+)
+
+// WebhookWriter POSTs batches of entries as a JSON array of Records. Batches
+// are delivered in order by one sender; a batch that still fails after its
+// retries is written to the spool directory and re-sent, oldest first, once
+// the endpoint accepts a request again - including by a later session that
+// uses the same spool.
+type WebhookWriter struct {
+	cfg         WebhookConfig
+	maxRetries  int
+	httpClient  *http.Client
+	errorLogger *ErrorLogger
+
+	mu      sync.Mutex
+	buffer  []*Entry
+	closing bool
+
+	queue chan []byte
+	done  chan struct{}
+	wg    sync.WaitGroup
+}
+
+// NewWebhookWriter creates a new webhook writer.
+func NewWebhookWriter(cfg WebhookConfig) (*WebhookWriter, error) {
+	if cfg.Endpoint == "" {
+		return nil, fmt.Errorf("webhook endpoint is required")
+	}
+	if !strings.HasPrefix(cfg.Endpoint, "http://") && !strings.HasPrefix(cfg.Endpoint, "https://") {
+		return nil, fmt.Errorf("webhook endpoint must be an http:// or https:// URL, got %q", cfg.Endpoint)
+	}
+
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = 5 * time.Second
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	if cfg.SpoolMaxBytes <= 0 {
+		cfg.SpoolMaxBytes = defaultWebhookSpoolMaxBytes
+	}
+	maxRetries := defaultWebhookMaxRetries
+	if cfg.MaxRetries != nil {
+		maxRetries = max(*cfg.MaxRetries, 0)
+	}
+	if cfg.SpoolDir != "" {
+		if err := os.MkdirAll(cfg.SpoolDir, 0o700); err != nil {
+			return nil, fmt.Errorf("failed to create spool directory: %w", err)
+		}
+	}
+
+	w := &WebhookWriter{
+		cfg:         cfg,
+		maxRetries:  maxRetries,
+		httpClient:  &http.Client{Timeout: cfg.Timeout},
+		errorLogger: cfg.ErrorLogger,
+		buffer:      make([]*Entry, 0, cfg.BatchSize),
+		queue:       make(chan []byte, webhookQueueSize),
+		done:        make(chan struct{}),
+	}
+
+	w.wg.Add(2)
+	go w.flushLoop()
+	go w.sendLoop()
+
+	return w, nil
+}
+
+// Write buffers a log entry for batched sending.
+func (w *WebhookWriter) Write(entry *Entry) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closing {
+		return fmt.Errorf("writer is closing")
+	}
+
+	w.buffer = append(w.buffer, entry)
+	if len(w.buffer) >= w.cfg.BatchSize {
+		w.flushLocked()
+	}
+	return nil
+}
+
+// Close flushes remaining entries and waits for the sender. Batches it
+// cannot deliver in one attempt are spooled rather than retried, so closing
+// is not held up by an endpoint that is down.
+func (w *WebhookWriter) Close() error {
+	w.mu.Lock()
+	if w.closing {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closing = true
+	close(w.done)
+	w.flushLocked()
+	close(w.queue)
+	w.mu.Unlock()
+
+	w.wg.Wait()
+	return nil
+}
+
+func (w *WebhookWriter) flushLoop() {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(w.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			w.mu.Lock()
+			if !w.closing {
+				w.flushLocked()
+			}
+			w.mu.Unlock()
+		case <-w.done:
+			return
+		}
+	}
+}
+
+// flushLocked hands the buffered entries to the sender as one batch.
+func (w *WebhookWriter) flushLocked() {
+	if len(w.buffer) == 0 {
+		return
+	}
+	entries := w.buffer
+	w.buffer = make([]*Entry, 0, w.cfg.BatchSize)
+
+	records := make([]Record, len(entries))
+	for i, e := range entries {
+		records[i] = newRecord(e, w.cfg.Attributes)
+	}
+	payload, err := json.Marshal(records)
+	if err != nil {
+		w.errorLogger.LogErrorf("webhook", "failed to encode %d entries: %v", len(entries), err)
+		return
+	}
+
+	select {
+	case w.queue <- payload:
+	default:
+		w.spool(payload)
+	}
+}
+
+// sendLoop delivers queued batches in order, and retries the spool whenever
+// a delivery succeeds or the spool retry interval passes.
+func (w *WebhookWriter) sendLoop() {
+	defer w.wg.Done()
+
+	// A previous session may have left batches behind.
+	w.drainSpool()
+
+	ticker := time.NewTicker(webhookSpoolRetryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case payload, ok := <-w.queue:
+			if !ok {
+				return
+			}
+			switch w.deliver(payload) {
+			case sendOK:
+				w.drainSpool()
+			case sendRetry:
+				w.spool(payload)
+			}
+		case <-ticker.C:
+			w.drainSpool()
+		}
+	}
+}
+
+// sendResult classifies one delivery attempt.
+type sendResult int
+
+const (
+	// sendOK means the endpoint accepted the batch.
+	sendOK sendResult = iota
+	// sendRetry means the endpoint or the network failed, not the batch:
+	// transport errors, 5xx, 429, 408, and the auth and routing statuses
+	// (401, 403, 404, 407) a corrected configuration or endpoint would fix.
+	sendRetry
+	// sendRejected means the endpoint refused this batch in particular
+	// (any other 4xx); resending it would be refused again.
+	sendRejected
+)
+
+// deliver sends payload, retrying with exponential backoff while the
+// failure is one a retry can fix. Once the writer is closing, only one
+// attempt is made.
+func (w *WebhookWriter) deliver(payload []byte) sendResult {
+	backoff := webhookRetryBase
+	for attempt := 0; ; attempt++ {
+		result, err := w.post(payload)
+		switch result {
+		case sendOK:
+			return sendOK
+		case sendRejected:
+			w.errorLogger.LogErrorf("webhook", "%s rejected a batch, dropping it: %v", w.cfg.Endpoint, err)
+			return sendRejected
+		}
+		if attempt >= w.maxRetries || w.isClosing() {
+			w.errorLogger.LogErrorf("webhook", "failed to send a batch to %s after %d attempts: %v", w.cfg.Endpoint, attempt+1, err)
+			return sendRetry
+		}
+		select {
+		case <-time.After(backoff):
+		case <-w.done:
+		}
+		backoff *= 2
+	}
+}
+
+// post makes one request.
+func (w *WebhookWriter) post(payload []byte) (sendResult, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), w.cfg.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.cfg.Endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return sendRetry, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range w.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := w.httpClient.Do(req)
+	if err != nil {
+		return sendRetry, err
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	_ = resp.Body.Close()
+
+	switch code := resp.StatusCode; {
+	case code >= 200 && code < 300:
+		return sendOK, nil
+	case code >= 500, code == http.StatusTooManyRequests, code == http.StatusRequestTimeout,
+		code == http.StatusUnauthorized, code == http.StatusForbidden,
+		code == http.StatusNotFound, code == http.StatusProxyAuthRequired:
+		return sendRetry, fmt.Errorf("status %d", code)
+	default:
+		return sendRejected, fmt.Errorf("status %d", code)
+	}
+}
+
+func (w *WebhookWriter) isClosing() bool {
+	select {
+	case <-w.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// spool writes payload to the spool directory, dropping the oldest batches
+// to keep it under SpoolMaxBytes.
+func (w *WebhookWriter) spool(payload []byte) {
+	if w.cfg.SpoolDir == "" {
+		w.errorLogger.LogErrorf("webhook", "dropping a batch for %s: no spool directory", w.cfg.Endpoint)
+		return
+	}
+	if int64(len(payload)) > w.cfg.SpoolMaxBytes {
+		w.errorLogger.LogErrorf("webhook", "dropping a batch of %d bytes for %s: larger than the spool", len(payload), w.cfg.Endpoint)
+		return
+	}
+
+	// Nanosecond names sort in spool order; the pid keeps two sessions
+	// sharing the directory from colliding.
+	name := fmt.Sprintf("%020d-%d.json", time.Now().UnixNano(), os.Getpid())
+	tmp := filepath.Join(w.cfg.SpoolDir, "."+name+".tmp")
+	if err := os.WriteFile(tmp, payload, 0o600); err != nil {
+		w.errorLogger.LogErrorf("webhook", "failed to spool a batch for %s: %v", w.cfg.Endpoint, err)
+		return
+	}
+	if err := os.Rename(tmp, filepath.Join(w.cfg.SpoolDir, name)); err != nil {
+		_ = os.Remove(tmp)
+		w.errorLogger.LogErrorf("webhook", "failed to spool a batch for %s: %v", w.cfg.Endpoint, err)
+		return
+	}
+
+	w.trimSpool()
+}
+
+// spooledBatches lists spooled batch files, oldest first.
+func (w *WebhookWriter) spooledBatches() []string {
+	matches, _ := filepath.Glob(filepath.Join(w.cfg.SpoolDir, "*.json"))
+	sort.Strings(matches)
+	return matches
+}
+
+// trimSpool drops the oldest batches until the spool fits SpoolMaxBytes.
+func (w *WebhookWriter) trimSpool() {
+	files := w.spooledBatches()
+	sizes := make([]int64, len(files))
+	var total int64
+	for i, f := range files {
+		if info, err := os.Stat(f); err == nil {
+			sizes[i] = info.Size()
+			total += sizes[i]
+		}
+	}
+	for i := 0; total > w.cfg.SpoolMaxBytes && i < len(files); i++ {
+		if err := os.Remove(files[i]); err == nil {
+			total -= sizes[i]
+			w.errorLogger.LogErrorf("webhook", "spool for %s is full, dropped %s", w.cfg.Endpoint, filepath.Base(files[i]))
+		}
+	}
+}
+
+// drainSpool re-sends spooled batches oldest first, stopping at the first
+// that still fails so order is kept.
+func (w *WebhookWriter) drainSpool() {
+	if w.cfg.SpoolDir == "" {
+		return
+	}
+	for _, path := range w.spooledBatches() {
+		sent, ok := w.resend(path)
+		if !ok {
+			return
+		}
+		if sent {
+			w.errorLogger.LogInfof("webhook", "delivered spooled batch %s to %s", filepath.Base(path), w.cfg.Endpoint)
+		}
+	}
+}
+
+// resend sends one spooled batch. The file is claimed with an advisory lock
+// for the attempt, so sessions sharing the spool do not both send it; a file
+// another session holds is skipped. ok is false when the endpoint is still
+// failing.
+func (w *WebhookWriter) resend(path string) (sent, ok bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		// Already sent and removed by another session.
+		return false, true
+	}
+	defer func() { _ = f.Close() }()
+	if syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB) != nil {
+		return false, true
+	}
+	// Claimed after the other session removed it: nothing left to send.
+	if _, err := os.Stat(path); err != nil {
+		return false, true
+	}
+	payload, err := io.ReadAll(f)
+	if err != nil {
+		return false, true
+	}
+
+	result, err := w.post(payload)
+	switch result {
+	case sendRetry:
+		return false, false
+	case sendRejected:
+		w.errorLogger.LogErrorf("webhook", "%s rejected spooled batch %s, dropping it: %v", w.cfg.Endpoint, filepath.Base(path), err)
+	}
+	_ = os.Remove(path)
+	return result == sendOK, true
+}
+
+// spoolDirFor names a per-endpoint spool directory under base, so receivers
+// with different endpoints never send each other's batches.
+func spoolDirFor(base, endpoint string) string {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(endpoint))
+	return filepath.Join(base, "webhook-spool", fmt.Sprintf("%08x", h.Sum32()))
+}
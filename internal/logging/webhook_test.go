@@ -0,0 +1,324 @@
+package logging
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"devsandbox/internal/config"
+	"devsandbox/internal/source"
+)
+
+// webhookServer records the batches it accepts and answers with the status
+// returned by respond.
+type webhookServer struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	batches [][]Record
+	headers []http.Header
+	calls   int
+	respond func(call int) int
+}
+
+func newWebhookServer(t *testing.T, respond func(call int) int) *webhookServer {
+	t.Helper()
+	s := &webhookServer{respond: respond}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		s.mu.Lock()
+		s.calls++
+		status := http.StatusOK
+		if s.respond != nil {
+			status = s.respond(s.calls)
+		}
+		if status == http.StatusOK {
+			var records []Record
+			if err := json.Unmarshal(body, &records); err != nil {
+				t.Errorf("body is not a record array: %v", err)
+			}
+			s.batches = append(s.batches, records)
+			s.headers = append(s.headers, r.Header.Clone())
+		}
+		s.mu.Unlock()
+
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *webhookServer) received() ([][]Record, []http.Header) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.batches, s.headers
+}
+
+func spoolFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	matches, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		t.Fatalf("glob: %v", err)
+	}
+	return matches
+}
+
+func writeEntries(t *testing.T, w Writer, messages ...string) {
+	t.Helper()
+	for _, m := range messages {
+		if err := w.Write(&Entry{Timestamp: time.Now(), Level: LevelInfo, Message: m, Fields: map[string]any{"session_id": "abc"}}); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+}
+
+func TestWebhookWriter_BatchesWithHeaders(t *testing.T) {
+	srv := newWebhookServer(t, nil)
+
+	w, err := NewWebhookWriter(WebhookConfig{
+		Endpoint:   srv.URL,
+		Headers:    map[string]string{"Authorization": "Bearer abc"},
+		BatchSize:  2,
+		Attributes: map[string]string{"team": "platform"},
+	})
+	if err != nil {
+		t.Fatalf("NewWebhookWriter: %v", err)
+	}
+	writeEntries(t, w, "one", "two", "three")
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	batches, headers := srv.received()
+	if len(batches) != 2 {
+		t.Fatalf("got %d batches, want 2", len(batches))
+	}
+	if len(batches[0]) != 2 || len(batches[1]) != 1 {
+		t.Errorf("batch sizes = %d, %d; want 2, 1", len(batches[0]), len(batches[1]))
+	}
+	if batches[0][0].Message != "one" || batches[1][0].Message != "three" {
+		t.Errorf("batches out of order: %+v", batches)
+	}
+	if got := batches[0][0].Fields["session_id"]; got != "abc" {
+		t.Errorf("session_id = %v, want abc", got)
+	}
+	if got := batches[0][0].Attributes["team"]; got != "platform" {
+		t.Errorf("team attribute = %q, want platform", got)
+	}
+	for _, h := range headers {
+		if got := h.Get("Authorization"); got != "Bearer abc" {
+			t.Errorf("Authorization = %q, want %q", got, "Bearer abc")
+		}
+		if got := h.Get("Content-Type"); got != "application/json" {
+			t.Errorf("Content-Type = %q, want application/json", got)
+		}
+	}
+}
+
+func TestWebhookWriter_RetriesServerErrors(t *testing.T) {
+	srv := newWebhookServer(t, func(call int) int {
+		if call == 1 {
+			return http.StatusServiceUnavailable
+		}
+		return http.StatusOK
+	})
+	spool := t.TempDir()
+
+	w, err := NewWebhookWriter(WebhookConfig{Endpoint: srv.URL, BatchSize: 1, MaxRetries: new(1), SpoolDir: spool})
+	if err != nil {
+		t.Fatalf("NewWebhookWriter: %v", err)
+	}
+	writeEntries(t, w, "one")
+
+	// The batch is retried while the writer is open; Close would cut the
+	// retry short.
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if batches, _ := srv.received(); len(batches) == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("batch was not retried")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	_ = w.Close()
+
+	if files := spoolFiles(t, spool); len(files) != 0 {
+		t.Errorf("spool = %v, want empty", files)
+	}
+}
+
+func TestWebhookWriter_SpoolsUntilEndpointRecovers(t *testing.T) {
+	var mu sync.Mutex
+	status := http.StatusServiceUnavailable
+	srv := newWebhookServer(t, func(int) int {
+		mu.Lock()
+		defer mu.Unlock()
+		return status
+	})
+	spool := t.TempDir()
+	cfg := WebhookConfig{Endpoint: srv.URL, MaxRetries: new(0), SpoolDir: spool}
+
+	w, err := NewWebhookWriter(cfg)
+	if err != nil {
+		t.Fatalf("NewWebhookWriter: %v", err)
+	}
+	writeEntries(t, w, "one", "two")
+	_ = w.Close()
+
+	if files := spoolFiles(t, spool); len(files) != 1 {
+		t.Fatalf("spool = %v, want one batch", files)
+	}
+	if batches, _ := srv.received(); len(batches) != 0 {
+		t.Fatalf("endpoint accepted %d batches while down", len(batches))
+	}
+
+	mu.Lock()
+	status = http.StatusOK
+	mu.Unlock()
+
+	// A later writer on the same spool sends the leftover batch first.
+	w, err = NewWebhookWriter(cfg)
+	if err != nil {
+		t.Fatalf("NewWebhookWriter: %v", err)
+	}
+	writeEntries(t, w, "three")
+	_ = w.Close()
+
+	batches, _ := srv.received()
+	if len(batches) != 2 {
+		t.Fatalf("got %d batches, want 2", len(batches))
+	}
+	if len(batches[0]) != 2 || batches[0][0].Message != "one" || batches[1][0].Message != "three" {
+		t.Errorf("batches = %+v, want the spooled batch first", batches)
+	}
+	if files := spoolFiles(t, spool); len(files) != 0 {
+		t.Errorf("spool = %v, want empty", files)
+	}
+}
+
+func TestWebhookWriter_DropsRejectedBatch(t *testing.T) {
+	srv := newWebhookServer(t, func(int) int { return http.StatusBadRequest })
+	spool := t.TempDir()
+
+	w, err := NewWebhookWriter(WebhookConfig{Endpoint: srv.URL, SpoolDir: spool})
+	if err != nil {
+		t.Fatalf("NewWebhookWriter: %v", err)
+	}
+	writeEntries(t, w, "bad")
+	_ = w.Close()
+
+	srv.mu.Lock()
+	calls := srv.calls
+	srv.mu.Unlock()
+	if calls != 1 {
+		t.Errorf("endpoint called %d times, want 1 (no retries for 400)", calls)
+	}
+	if files := spoolFiles(t, spool); len(files) != 0 {
+		t.Errorf("spool = %v, want the rejected batch dropped", files)
+	}
+}
+
+func TestWebhookWriter_SpoolKeepsAuthFailures(t *testing.T) {
+	srv := newWebhookServer(t, func(int) int { return http.StatusUnauthorized })
+	spool := t.TempDir()
+
+	w, err := NewWebhookWriter(WebhookConfig{Endpoint: srv.URL, MaxRetries: new(0), SpoolDir: spool})
+	if err != nil {
+		t.Fatalf("NewWebhookWriter: %v", err)
+	}
+	writeEntries(t, w, "one")
+	_ = w.Close()
+
+	if files := spoolFiles(t, spool); len(files) != 1 {
+		t.Errorf("spool = %v, want the batch kept until credentials are fixed", files)
+	}
+}
+
+func TestWebhookWriter_SpoolIsBounded(t *testing.T) {
+	dir := t.TempDir()
+	w := &WebhookWriter{cfg: WebhookConfig{Endpoint: "http://example.invalid", SpoolDir: dir, SpoolMaxBytes: 25}}
+
+	for _, p := range []string{`["aaaaaaa"]`, `["bbbbbbb"]`, `["ccccccc"]`} {
+		w.spool([]byte(p))
+		time.Sleep(time.Millisecond) // distinct, ordered names
+	}
+	w.spool([]byte(`["this batch is larger than the whole spool"]`))
+
+	files := spoolFiles(t, dir)
+	if len(files) != 2 {
+		t.Fatalf("spool = %v, want the two newest batches", files)
+	}
+	for i, want := range []string{`["bbbbbbb"]`, `["ccccccc"]`} {
+		data, err := os.ReadFile(files[i])
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		if string(data) != want {
+			t.Errorf("spool[%d] = %s, want %s", i, data, want)
+		}
+	}
+}
+
+func TestWebhookWriter_InvalidEndpoint(t *testing.T) {
+	for _, endpoint := range []string{"", "collector:8080", "ftp://collector"} {
+		if _, err := NewWebhookWriter(WebhookConfig{Endpoint: endpoint}); err == nil {
+			t.Errorf("endpoint %q: expected error", endpoint)
+		}
+	}
+}
+
+func TestSpoolDirFor(t *testing.T) {
+	a := spoolDirFor("/base", "https://a.example/logs")
+	b := spoolDirFor("/base", "https://b.example/logs")
+	if a == b {
+		t.Errorf("endpoints share spool %s", a)
+	}
+	if a != spoolDirFor("/base", "https://a.example/logs") {
+		t.Error("spool directory is not stable")
+	}
+	if filepath.Dir(filepath.Dir(a)) != "/base" {
+		t.Errorf("spool %s is not under /base", a)
+	}
+}
+
+func TestNewWriterFromConfig_Webhook(t *testing.T) {
+	t.Setenv("WEBHOOK_TEST_TOKEN", "Bearer xyz")
+	errDir := t.TempDir()
+
+	r := config.ReceiverConfig{
+		Type:          "webhook",
+		Endpoint:      "http://127.0.0.1:1/logs",
+		FlushInterval: "1s",
+		HeaderSources: map[string]source.Source{
+			"Authorization": {Env: "WEBHOOK_TEST_TOKEN"},
+		},
+	}
+	w, err := newWriterFromConfig(r, DispatcherConfig{ErrorLogDir: errDir}, nil)
+	if err != nil {
+		t.Fatalf("newWriterFromConfig: %v", err)
+	}
+	defer func() { _ = w.Close() }()
+
+	webhook, ok := w.(*WebhookWriter)
+	if !ok {
+		t.Fatalf("expected *WebhookWriter, got %T", w)
+	}
+	if got := webhook.cfg.Headers["Authorization"]; got != "Bearer xyz" {
+		t.Errorf("Authorization header = %q, want %q", got, "Bearer xyz")
+	}
+	if got := webhook.cfg.FlushInterval; got != time.Second {
+		t.Errorf("FlushInterval = %v, want 1s", got)
+	}
+	if want := spoolDirFor(errDir, r.Endpoint); webhook.cfg.SpoolDir != want {
+		t.Errorf("SpoolDir = %q, want %q", webhook.cfg.SpoolDir, want)
+	}
+}
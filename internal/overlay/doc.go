@@ -6,6 +6,11 @@
 //	<sandboxHome>/overlay/<safePath>/upper            — primary persistent upper
 //	<sandboxHome>/overlay/sessions/<sid>/<safePath>/upper — per-session upper
 //
+// Overlays of custom mount rules live the same way under an extra "custom"
+// directory: overlay/custom/<safePath>/upper and
+// overlay/sessions/<sid>/custom/<safePath>/upper. A project mounted with
+// --project-overlay has its upper at overlay/project/<safePath>/upper.
+//
 // where safePath is the destination mount path with the leading "/" stripped
 // and remaining "/" replaced with "_".
 package overlay
@@ -33,10 +33,15 @@ func LocateUppers(sandboxHome, hostPath string, primaryOnly bool) ([]UpperSource
 	if err != nil {
 		return nil, err
 	}
+	return locateUppers(sandboxHome, "", safe, primaryOnly)
+}
 
+// locateUppers is LocateUppers for an overlay already named by its safePath,
+// under subdir ("" or "custom") like the builder lays it out.
+func locateUppers(sandboxHome, subdir, safe string, primaryOnly bool) ([]UpperSource, error) {
 	var sources []UpperSource
 
-	primary := filepath.Join(sandboxHome, "overlay", safe, "upper")
+	primary := filepath.Join(sandboxHome, "overlay", subdir, safe, "upper")
 	if fi, err := os.Stat(primary); err == nil && fi.IsDir() {
 		sources = append(sources, UpperSource{
 			Kind:    UpperPrimary,
@@ -63,7 +68,7 @@ func LocateUppers(sandboxHome, hostPath string, primaryOnly bool) ([]UpperSource
 		if !e.IsDir() {
 			continue
 		}
-		sessionDir := filepath.Join(sessionsRoot, e.Name(), safe)
+		sessionDir := filepath.Join(sessionsRoot, e.Name(), subdir, safe)
 		upper := filepath.Join(sessionDir, "upper")
 		fi, err := os.Stat(upper)
 		if err != nil || !fi.IsDir() {
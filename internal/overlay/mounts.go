@@ -0,0 +1,201 @@
+package overlay
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// CustomSubdir is the directory under <sandboxHome>/overlay/ that holds the
+// uppers of overlay custom mounts, laid out the same way as the tool ones.
+const CustomSubdir = "custom"
+
+// ProjectSubdir is the directory under <sandboxHome>/overlay/ that holds the
+// upper of a project mounted with --project-overlay. It is not a tool or mount
+// rule overlay, so LocateMounts leaves it to LocateProjectMounts.
+const ProjectSubdir = "project"
+
+// sessionsSubdir holds per-session uppers; see the package doc.
+const sessionsSubdir = "sessions"
+
+// Mount is one overlay-mounted host path of a sandbox, with the uppers that
+// record what the sandbox changed under it.
+type Mount struct {
+	// HostPath is the host directory the overlay was mounted over.
+	HostPath string
+	// Custom is set for a [[sandbox.mounts.rules]] overlay rather than a tool's.
+	Custom  bool
+	Sources []UpperSource
+}
+
+// LocateMounts returns every overlay that has an upper under sandboxHome,
+// primary or per-session, sorted by host path.
+//
+// The upper directory names are SafePath spellings, which lose the difference
+// between "/" and "_", so they cannot simply be turned back into a path. Each
+// is matched against candidates first - the mount destinations the caller
+// knows, such as every tool binding - and otherwise against the host
+// filesystem, looking for the existing path that spells the same. An upper that
+// matches neither way (its host path has since been removed) is returned in
+// unresolved by its directory name under overlay/.
+func LocateMounts(sandboxHome string, candidates []string, primaryOnly bool) (mounts []Mount, unresolved []string, err error) {
+	type key struct{ subdir, safe string }
+	seen := map[key]bool{}
+	var keys []key
+	collect := func(dir, subdir string) error {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		for _, e := range entries {
+			if !e.IsDir() {
+				continue
+			}
+			name := e.Name()
+			if subdir == "" && (name == CustomSubdir || name == ProjectSubdir || name == sessionsSubdir) {
+				continue
+			}
+			if fi, err := os.Stat(filepath.Join(dir, name, "upper")); err != nil || !fi.IsDir() {
+				continue
+			}
+			k := key{subdir, name}
+			if !seen[k] {
+				seen[k] = true
+				keys = append(keys, k)
+			}
+		}
+		return nil
+	}
+
+	overlayRoot := filepath.Join(sandboxHome, "overlay")
+	roots := []string{overlayRoot}
+	if !primaryOnly {
+		sessions, err := os.ReadDir(filepath.Join(overlayRoot, sessionsSubdir))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, nil, err
+		}
+		for _, s := range sessions {
+			if s.IsDir() {
+				roots = append(roots, filepath.Join(overlayRoot, sessionsSubdir, s.Name()))
+			}
+		}
+	}
+	for _, root := range roots {
+		if err := collect(root, ""); err != nil {
+			return nil, nil, err
+		}
+		if err := collect(filepath.Join(root, CustomSubdir), CustomSubdir); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	resolve := hostPathResolver(candidates)
+	for _, k := range keys {
+		hostPath, ok := resolve(k.safe)
+		if !ok {
+			unresolved = append(unresolved, filepath.Join(k.subdir, k.safe))
+			continue
+		}
+		sources, err := locateUppers(sandboxHome, k.subdir, k.safe, primaryOnly)
+		if err != nil {
+			return nil, nil, err
+		}
+		mounts = append(mounts, Mount{HostPath: hostPath, Custom: k.subdir == CustomSubdir, Sources: sources})
+	}
+
+	sort.Slice(mounts, func(i, j int) bool {
+		if mounts[i].HostPath != mounts[j].HostPath {
+			return mounts[i].HostPath < mounts[j].HostPath
+		}
+		return !mounts[i].Custom && mounts[j].Custom
+	})
+	sort.Strings(unresolved)
+	return mounts, unresolved, nil
+}
+
+// LocateProjectMounts returns the project overlays under sandboxHome, left by
+// sessions started with --project-overlay. There is normally one; worktrees of
+// a repository share a sandbox, so each can add its own. Host paths are
+// resolved as in LocateMounts, with candidates typically the project
+// directories the caller expects. A project overlay is only mounted by the
+// sandbox's sole session, so each has only a primary upper.
+func LocateProjectMounts(sandboxHome string, candidates []string) (mounts []Mount, unresolved []string, err error) {
+	entries, err := os.ReadDir(filepath.Join(sandboxHome, "overlay", ProjectSubdir))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil, nil
+		}
+		return nil, nil, err
+	}
+	resolve := hostPathResolver(candidates)
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		hostPath, ok := resolve(e.Name())
+		if !ok {
+			unresolved = append(unresolved, filepath.Join(ProjectSubdir, e.Name()))
+			continue
+		}
+		sources, err := locateUppers(sandboxHome, ProjectSubdir, e.Name(), true)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(sources) > 0 {
+			mounts = append(mounts, Mount{HostPath: hostPath, Sources: sources})
+		}
+	}
+	return mounts, unresolved, nil
+}
+
+// hostPathResolver maps an upper directory name back to a host path: one of
+// candidates when it spells the same, otherwise whatever resolveSafePath finds.
+func hostPathResolver(candidates []string) func(safe string) (string, bool) {
+	bySafe := map[string]string{}
+	for _, c := range candidates {
+		if safe, err := SafePath(c); err == nil {
+			bySafe[safe] = filepath.Clean(c)
+		}
+	}
+	return func(safe string) (string, bool) {
+		if hostPath, ok := bySafe[safe]; ok {
+			return hostPath, true
+		}
+		return resolveSafePath(safe)
+	}
+}
+
+// resolveSafePath finds the existing host path whose SafePath is safe. Each "_"
+// is either a separator or part of a name, so the search tries both, shortest
+// name first, descending only into directories that exist.
+func resolveSafePath(safe string) (string, bool) {
+	if safe == "" {
+		return "", false
+	}
+	return resolveParts("/", strings.Split(safe, "_"))
+}
+
+func resolveParts(dir string, parts []string) (string, bool) {
+	for j := 1; j <= len(parts); j++ {
+		p := filepath.Join(dir, strings.Join(parts[:j], "_"))
+		fi, err := os.Stat(p)
+		if err != nil {
+			continue
+		}
+		if j == len(parts) {
+			return p, true
+		}
+		if fi.IsDir() {
+			if r, ok := resolveParts(p, parts[j:]); ok {
+				return r, true
+			}
+		}
+	}
+	return "", false
+}
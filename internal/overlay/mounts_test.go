@@ -0,0 +1,118 @@
+package overlay
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLocateMounts(t *testing.T) {
+	sandboxHome := t.TempDir()
+	host := t.TempDir()
+	// A host path with "_" in a name: its upper directory name is ambiguous
+	// and has to be resolved against the filesystem.
+	tool := filepath.Join(host, "tool_state", "data")
+	custom := filepath.Join(host, "work")
+	for _, d := range []string{tool, custom} {
+		if err := os.MkdirAll(d, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	safe := func(p string) string {
+		s, err := SafePath(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	claude := "/home/nobody-devsandbox/.claude"
+
+	for _, dir := range []string{
+		filepath.Join("overlay", safe(claude), "upper"),
+		filepath.Join("overlay", safe(tool), "upper"),
+		filepath.Join("overlay", "custom", safe(custom), "upper"),
+		filepath.Join("overlay", "sessions", "s1", safe(claude), "upper"),
+		filepath.Join("overlay", "sessions", "s1", "custom", safe(custom), "upper"),
+		filepath.Join("overlay", "gone_nowhere_x", "upper"),
+	} {
+		if err := os.MkdirAll(filepath.Join(sandboxHome, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	mounts, unresolved, err := LocateMounts(sandboxHome, []string{claude}, false)
+	if err != nil {
+		t.Fatalf("LocateMounts() error = %v", err)
+	}
+	type got struct {
+		host    string
+		custom  bool
+		sources int
+	}
+	want := map[got]bool{
+		{claude, false, 2}: true,
+		{tool, false, 1}:   true,
+		{custom, true, 2}:  true,
+	}
+	if len(mounts) != len(want) {
+		t.Fatalf("LocateMounts() = %+v, want %d mounts", mounts, len(want))
+	}
+	for _, m := range mounts {
+		if !want[got{m.HostPath, m.Custom, len(m.Sources)}] {
+			t.Errorf("unexpected mount %s (custom=%v, %d sources)", m.HostPath, m.Custom, len(m.Sources))
+		}
+	}
+	if len(unresolved) != 1 || unresolved[0] != "gone_nowhere_x" {
+		t.Errorf("unresolved = %v, want [gone_nowhere_x]", unresolved)
+	}
+
+	mounts, _, err = LocateMounts(sandboxHome, []string{claude}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range mounts {
+		if len(m.Sources) != 1 || m.Sources[0].Kind != UpperPrimary {
+			t.Errorf("primaryOnly: %s has sources %+v", m.HostPath, m.Sources)
+		}
+	}
+}
+
+func TestLocateMounts_NoOverlayDir(t *testing.T) {
+	mounts, unresolved, err := LocateMounts(t.TempDir(), nil, false)
+	if err != nil || len(mounts) != 0 || len(unresolved) != 0 {
+		t.Errorf("LocateMounts() on an empty home = %v, %v, %v", mounts, unresolved, err)
+	}
+}
+
+func TestLocateProjectMounts(t *testing.T) {
+	sandboxHome := t.TempDir()
+	project := filepath.Join(t.TempDir(), "my_project")
+	if err := os.MkdirAll(project, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	safe, err := SafePath(project)
+	if err != nil {
+		t.Fatal(err)
+	}
+	upper := filepath.Join(sandboxHome, "overlay", ProjectSubdir, safe, "upper")
+	if err := os.MkdirAll(upper, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	// Resolved from the filesystem, "_" in the name and all.
+	mounts, unresolved, err := LocateProjectMounts(sandboxHome, nil)
+	if err != nil {
+		t.Fatalf("LocateProjectMounts() error = %v", err)
+	}
+	if len(mounts) != 1 || mounts[0].HostPath != project || len(unresolved) != 0 {
+		t.Fatalf("LocateProjectMounts() = %+v, %v; want the one project overlay of %s", mounts, unresolved, project)
+	}
+	if len(mounts[0].Sources) != 1 || mounts[0].Sources[0].Path != upper {
+		t.Errorf("sources = %+v, want the primary upper %s", mounts[0].Sources, upper)
+	}
+
+	// A project overlay is not a tool overlay.
+	if tools, _, err := LocateMounts(sandboxHome, nil, false); err != nil || len(tools) != 0 {
+		t.Errorf("LocateMounts() = %+v, %v; want the project overlay left out", tools, err)
+	}
+}
@@ -46,6 +46,12 @@ type Operation struct {
 	// ordinary file overwrite while the apply deletes a whole host subtree,
 	// including entries no operation in the plan puts back.
 	ReplacesHostDir bool
+
+	// Opaque marks a directory the upper recreated after deleting it. Inside the
+	// sandbox it hides everything the host holds beneath it, not just what the
+	// upper lists; the plan does not delete those host entries, so a reader
+	// auditing the changes needs the flag to see that the sandbox dropped them.
+	Opaque bool
 }
 
 // Plan is the full set of operations to apply, plus per-sandbox grouping
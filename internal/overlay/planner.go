@@ -226,6 +226,7 @@ func BuildPlan(sources []UpperSource, hostPath string) (Plan, error) {
 			op.Kind, op.ReplacesHostDir = kindForTarget(hostFull)
 		case e.fi.IsDir():
 			op.IsDir = true
+			op.Opaque = e.isOpaque
 			op.Mode = e.fi.Mode()
 			// A directory over a directory is merged, not replaced.
 			op.Kind, _ = kindForTarget(hostFull)
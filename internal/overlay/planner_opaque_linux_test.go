@@ -59,6 +59,11 @@ func TestBuildPlan_OpaqueDirHidesEarlierDescendants(t *testing.T) {
 	if !slices.Contains(got, "d") {
 		t.Errorf("opaque directory itself missing; ops=%v", got)
 	}
+	for _, op := range plan.Operations {
+		if op.Opaque != (op.RelPath == "d") {
+			t.Errorf("op %s: Opaque = %v, want it set on the opaque directory only", op.RelPath, op.Opaque)
+		}
+	}
 }
 
 func TestBuildPlan_NonOpaqueDirMergesWithEarlier(t *testing.T) {
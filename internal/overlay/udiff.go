@@ -0,0 +1,275 @@
+package overlay
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+const (
+	// diffContext is the number of unchanged lines shown around each change.
+	diffContext = 3
+	// maxDiffBytes caps the size of either side of a text diff; larger files are
+	// reported as differing without their contents.
+	maxDiffBytes = 1 << 20
+	// maxDiffCells caps the LCS table of the lines left after trimming the
+	// common head and tail. Past it the differing region is shown as removed
+	// and re-added whole, which is correct if not minimal.
+	maxDiffCells = 4 << 20
+	// binarySniffLen is how far a file is scanned for a NUL byte, as git does.
+	binarySniffLen = 8000
+)
+
+// WriteFileDiff writes a unified diff of what op does to a regular file: the
+// host file against the upper's copy, with /dev/null standing in for the side
+// that does not exist. Both headers name the host path, so the output applies
+// with `patch -p0`. Directories and symlinks have no content to diff and
+// write nothing; binary and oversized files get a one-line note instead.
+func WriteFileDiff(w io.Writer, op Operation) error {
+	if op.IsDir || op.IsSymlink {
+		return nil
+	}
+	oldName, newName := "/dev/null", "/dev/null"
+	var oldData, newData []byte
+	tooLarge := false
+
+	if op.Kind != OpCreate && !op.ReplacesHostDir {
+		data, ok, large, err := readDiffSide(op.HostPath)
+		if err != nil {
+			return err
+		}
+		if ok {
+			oldName, oldData, tooLarge = op.HostPath, data, large
+		}
+	}
+	if op.Kind != OpDelete {
+		data, ok, large, err := readDiffSide(op.Source)
+		if err != nil {
+			return err
+		}
+		if ok {
+			newName, newData, tooLarge = op.HostPath, data, tooLarge || large
+		}
+	}
+	if oldName == newName && oldName == "/dev/null" {
+		return nil
+	}
+
+	if tooLarge {
+		_, err := fmt.Fprintf(w, "Files %s and %s differ (larger than %s, not diffed)\n", oldName, newName, formatBytes(maxDiffBytes))
+		return err
+	}
+	if isBinary(oldData) || isBinary(newData) {
+		_, err := fmt.Fprintf(w, "Binary files %s and %s differ\n", oldName, newName)
+		return err
+	}
+	return WriteUnified(w, oldName, newName, oldData, newData)
+}
+
+// readDiffSide reads a regular file for diffing. ok is false when path holds no
+// regular file - nothing, a directory, a FIFO - which is never opened. A file
+// over maxDiffBytes is reported as large and not read.
+func readDiffSide(path string) (data []byte, ok, large bool, err error) {
+	fi, err := os.Lstat(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, false, false, nil
+		}
+		return nil, false, false, err
+	}
+	if !fi.Mode().IsRegular() {
+		return nil, false, false, nil
+	}
+	if fi.Size() > maxDiffBytes {
+		return nil, true, true, nil
+	}
+	data, err = os.ReadFile(path)
+	return data, err == nil, false, err
+}
+
+func isBinary(data []byte) bool {
+	if len(data) > binarySniffLen {
+		data = data[:binarySniffLen]
+	}
+	return bytes.IndexByte(data, 0) >= 0
+}
+
+// diffLine is one line of an edit script: ' ' kept, '-' removed, '+' added.
+type diffLine struct {
+	op   byte
+	text string
+}
+
+// WriteUnified writes the unified diff of a and b, or nothing when they are
+// equal. It takes any text, not only files: `devsandbox logs proxy replay`
+// diffs response bodies with it.
+func WriteUnified(w io.Writer, aName, bName string, a, b []byte) error {
+	script := diffLines(splitLines(a), splitLines(b))
+	changed := false
+	for _, l := range script {
+		if l.op != ' ' {
+			changed = true
+			break
+		}
+	}
+	if !changed {
+		return nil
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", aName, bName)
+
+	// Walk the script, opening a hunk at the first change and extending it while
+	// the next change is within two contexts' worth of kept lines.
+	aLine, bLine := 1, 1 // 1-based line numbers at script[i]
+	for i := 0; i < len(script); {
+		if script[i].op == ' ' {
+			i++
+			aLine++
+			bLine++
+			continue
+		}
+		start := max(i-diffContext, 0)
+		end := i
+		for end < len(script) {
+			if script[end].op != ' ' {
+				end++
+				continue
+			}
+			run := end
+			for run < len(script) && script[run].op == ' ' {
+				run++
+			}
+			if run == len(script) || run-end > 2*diffContext {
+				end = min(end+diffContext, len(script))
+				break
+			}
+			end = run
+		}
+
+		hunkA, hunkB := aLine-(i-start), bLine-(i-start)
+		var countA, countB int
+		for _, l := range script[start:end] {
+			if l.op != '+' {
+				countA++
+			}
+			if l.op != '-' {
+				countB++
+			}
+		}
+		fmt.Fprintf(&sb, "@@ -%s +%s @@\n", hunkRange(hunkA, countA), hunkRange(hunkB, countB))
+		for _, l := range script[start:end] {
+			sb.WriteByte(l.op)
+			sb.WriteString(l.text)
+			if !strings.HasSuffix(l.text, "\n") {
+				sb.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+
+		for _, l := range script[i:end] {
+			if l.op != '+' {
+				aLine++
+			}
+			if l.op != '-' {
+				bLine++
+			}
+		}
+		i = end
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// hunkRange formats one side of a hunk header. An empty side names the line
+// before the hunk, as diff(1) does.
+func hunkRange(start, count int) string {
+	switch count {
+	case 0:
+		return fmt.Sprintf("%d,0", start-1)
+	case 1:
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
+
+// splitLines splits data after each newline; a final line without one is kept
+// as is.
+func splitLines(data []byte) []string {
+	if len(data) == 0 {
+		return nil
+	}
+	lines := strings.SplitAfter(string(data), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffLines returns an edit script turning a into b, from a longest common
+// subsequence of the lines between their common head and tail.
+func diffLines(a, b []string) []diffLine {
+	var script []diffLine
+	head := 0
+	for head < len(a) && head < len(b) && a[head] == b[head] {
+		script = append(script, diffLine{' ', a[head]})
+		head++
+	}
+	tail := 0
+	for tail < len(a)-head && tail < len(b)-head && a[len(a)-1-tail] == b[len(b)-1-tail] {
+		tail++
+	}
+	midA, midB := a[head:len(a)-tail], b[head:len(b)-tail]
+
+	n, m := len(midA), len(midB)
+	if n*m > maxDiffCells {
+		for _, l := range midA {
+			script = append(script, diffLine{'-', l})
+		}
+		for _, l := range midB {
+			script = append(script, diffLine{'+', l})
+		}
+	} else {
+		// lcs[i*(m+1)+j] is the LCS length of midA[i:] and midB[j:].
+		lcs := make([]int32, (n+1)*(m+1))
+		for i := n - 1; i >= 0; i-- {
+			for j := m - 1; j >= 0; j-- {
+				if midA[i] == midB[j] {
+					lcs[i*(m+1)+j] = lcs[(i+1)*(m+1)+j+1] + 1
+				} else {
+					lcs[i*(m+1)+j] = max(lcs[(i+1)*(m+1)+j], lcs[i*(m+1)+j+1])
+				}
+			}
+		}
+		i, j := 0, 0
+		for i < n && j < m {
+			switch {
+			case midA[i] == midB[j]:
+				script = append(script, diffLine{' ', midA[i]})
+				i++
+				j++
+			case lcs[(i+1)*(m+1)+j] >= lcs[i*(m+1)+j+1]:
+				script = append(script, diffLine{'-', midA[i]})
+				i++
+			default:
+				script = append(script, diffLine{'+', midB[j]})
+				j++
+			}
+		}
+		for ; i < n; i++ {
+			script = append(script, diffLine{'-', midA[i]})
+		}
+		for ; j < m; j++ {
+			script = append(script, diffLine{'+', midB[j]})
+		}
+	}
+
+	for _, l := range a[len(a)-tail:] {
+		script = append(script, diffLine{' ', l})
+	}
+	return script
+}
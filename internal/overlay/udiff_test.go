@@ -0,0 +1,125 @@
+package overlay
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteUnified(t *testing.T) {
+	a := "one\ntwo\nthree\nfour\nfive\nsix\nseven\neight\nnine\nten\n"
+	b := "one\ntwo\nTHREE\nfour\nfive\nsix\nseven\neight\nnine\nten\neleven"
+	var buf bytes.Buffer
+	if err := WriteUnified(&buf, "a", "b", []byte(a), []byte(b)); err != nil {
+		t.Fatal(err)
+	}
+	want := `--- a
++++ b
+@@ -1,6 +1,6 @@
+ one
+ two
+-three
++THREE
+ four
+ five
+ six
+@@ -8,3 +8,4 @@
+ eight
+ nine
+ ten
++eleven
+\ No newline at end of file
+`
+	if buf.String() != want {
+		t.Errorf("WriteUnified() =\n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
+func TestWriteUnified_MergesCloseChanges(t *testing.T) {
+	a := "1\n2\n3\n4\n5\n"
+	b := "1\nX\n3\n4\nY\n"
+	var buf bytes.Buffer
+	if err := WriteUnified(&buf, "a", "b", []byte(a), []byte(b)); err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(buf.String(), "@@ -"); n != 1 {
+		t.Errorf("want one hunk for changes three lines apart, got %d:\n%s", n, buf.String())
+	}
+	if !strings.Contains(buf.String(), "@@ -1,5 +1,5 @@") {
+		t.Errorf("hunk header wrong:\n%s", buf.String())
+	}
+}
+
+func TestWriteUnified_Equal(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteUnified(&buf, "a", "b", []byte("same\n"), []byte("same\n")); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("equal inputs produced output:\n%s", buf.String())
+	}
+}
+
+func TestWriteFileDiff(t *testing.T) {
+	tmp := t.TempDir()
+	host := filepath.Join(tmp, "host.txt")
+	upper := filepath.Join(tmp, "upper.txt")
+	writeFile(t, host, "old\n")
+	writeFile(t, upper, "new\n")
+
+	tests := []struct {
+		name string
+		op   Operation
+		want string
+	}{
+		{
+			name: "overwrite",
+			op:   Operation{Kind: OpOverwrite, HostPath: host, Source: upper},
+			want: "--- " + host + "\n+++ " + host + "\n@@ -1 +1 @@\n-old\n+new\n",
+		},
+		{
+			name: "create",
+			op:   Operation{Kind: OpCreate, HostPath: filepath.Join(tmp, "absent"), Source: upper},
+			want: "--- /dev/null\n+++ " + filepath.Join(tmp, "absent") + "\n@@ -0,0 +1 @@\n+new\n",
+		},
+		{
+			name: "delete",
+			op:   Operation{Kind: OpDelete, HostPath: host},
+			want: "--- " + host + "\n+++ /dev/null\n@@ -1 +0,0 @@\n-old\n",
+		},
+		{
+			name: "directory",
+			op:   Operation{Kind: OpCreate, HostPath: tmp, Source: tmp, IsDir: true},
+			want: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := WriteFileDiff(&buf, tt.op); err != nil {
+				t.Fatal(err)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("WriteFileDiff() =\n%q\nwant\n%q", buf.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteFileDiff_Binary(t *testing.T) {
+	tmp := t.TempDir()
+	upper := filepath.Join(tmp, "upper.bin")
+	if err := os.WriteFile(upper, []byte{0x7f, 'E', 'L', 'F', 0}, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	op := Operation{Kind: OpCreate, HostPath: "/host/bin", Source: upper}
+	if err := WriteFileDiff(&buf, op); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "Binary files /dev/null and /host/bin differ\n" {
+		t.Errorf("WriteFileDiff() = %q", buf.String())
+	}
+}
@@ -6,28 +6,50 @@ import (
 	"fmt"
 	"io"
 	"net"
+	"strconv"
 	"sync"
 	"sync/atomic"
 	"syscall"
+	"time"
 )
 
 const maxConnections = 1024
 
-// Forwarder proxies TCP connections from a host-side listener through a Dialer
-// into a sandbox. HostPort=0 lets the OS assign an ephemeral port; call
-// ActualHostPort after Start to retrieve it.
+// udpSessionIdle is how long a UDP client may stay silent, in both
+// directions, before its session into the sandbox is closed.
+const udpSessionIdle = 2 * time.Minute
+
+// Forwarder proxies TCP connections, or UDP datagrams, from a host-side
+// listener through a Dialer into a sandbox. HostPort=0 lets the OS assign an
+// ephemeral port; call ActualHostPort after Start to retrieve it.
+//
+// UDP is relayed per client: the first datagram from a host address opens a
+// session - one dialed "udp" connection into the sandbox - that carries that
+// client's datagrams both ways until it has been idle for udpSessionIdle.
 type Forwarder struct {
 	HostPort    int    // Desired host port (0 = auto-assign)
 	SandboxPort int    // Target port inside the sandbox
+	SandboxHost string // Target address inside the sandbox (default "127.0.0.1")
 	Bind        string // Host bind address (default "127.0.0.1")
+	Protocol    string // ProtocolTCP (default) or ProtocolUDP
 	Dialer      Dialer // Namespace-aware dialer
 
 	listener   net.Listener
+	packetConn net.PacketConn
 	actualPort int
 	cancel     context.CancelFunc
 	wg         sync.WaitGroup
 	sem        chan struct{}
 	active     atomic.Int64
+
+	udpMu       sync.Mutex
+	udpSessions map[string]*udpSession
+}
+
+// udpSession is one host client's relay into the sandbox.
+type udpSession struct {
+	conn     net.Conn
+	lastSeen atomic.Int64 // unix nanoseconds
 }
 
 // Start opens the host listener and begins accepting connections. It returns
@@ -39,7 +61,25 @@ func (f *Forwarder) Start(ctx context.Context) error {
 		bind = "127.0.0.1"
 	}
 
-	addr := fmt.Sprintf("%s:%d", bind, f.HostPort)
+	addr := net.JoinHostPort(bind, strconv.Itoa(f.HostPort))
+	f.sem = make(chan struct{}, maxConnections)
+
+	if f.Protocol == ProtocolUDP {
+		pc, err := net.ListenPacket("udp", addr)
+		if err != nil {
+			return fmt.Errorf("listen udp %s: %w", addr, err)
+		}
+		f.packetConn = pc
+		f.actualPort = pc.LocalAddr().(*net.UDPAddr).Port
+		f.udpSessions = make(map[string]*udpSession)
+
+		loopCtx, cancel := context.WithCancel(ctx)
+		f.cancel = cancel
+		f.wg.Go(func() { f.udpLoop(loopCtx) })
+		f.wg.Go(func() { f.reapUDPSessions(loopCtx) })
+		return nil
+	}
+
 	ln, err := net.Listen("tcp", addr)
 	if err != nil {
 		return fmt.Errorf("listen %s: %w", addr, err)
@@ -47,7 +87,6 @@ func (f *Forwarder) Start(ctx context.Context) error {
 
 	f.listener = ln
 	f.actualPort = ln.Addr().(*net.TCPAddr).Port
-	f.sem = make(chan struct{}, maxConnections)
 
 	loopCtx, cancel := context.WithCancel(ctx)
 	f.cancel = cancel
@@ -82,7 +121,8 @@ func (f *Forwarder) StartWithFallback(ctx context.Context) (actualPort int, fell
 }
 
 // Stop cancels the accept loop, closes the listener, and waits for all
-// in-flight connections to finish.
+// in-flight connections to finish. UDP sessions are closed outright: a
+// datagram relay has no end of stream to wait for.
 func (f *Forwarder) Stop() {
 	if f.cancel != nil {
 		f.cancel()
@@ -90,6 +130,14 @@ func (f *Forwarder) Stop() {
 	if f.listener != nil {
 		_ = f.listener.Close()
 	}
+	if f.packetConn != nil {
+		_ = f.packetConn.Close()
+		f.udpMu.Lock()
+		for _, sess := range f.udpSessions {
+			_ = sess.conn.Close()
+		}
+		f.udpMu.Unlock()
+	}
 	f.wg.Wait()
 }
 
@@ -99,7 +147,8 @@ func (f *Forwarder) ActualHostPort() int {
 	return f.actualPort
 }
 
-// ActiveConnections returns the number of connections currently being proxied.
+// ActiveConnections returns the number of connections - or, for UDP, client
+// sessions - currently being proxied.
 func (f *Forwarder) ActiveConnections() int64 {
 	return f.active.Load()
 }
@@ -139,13 +188,18 @@ func (f *Forwarder) handleConn(ctx context.Context, hostConn net.Conn) {
 	f.active.Add(1)
 	defer f.active.Add(-1)
 
-	sandboxAddr := fmt.Sprintf("127.0.0.1:%d", f.SandboxPort)
-	sandboxConn, err := f.Dialer.DialContext(ctx, "tcp", sandboxAddr)
+	sandboxConn, err := f.Dialer.DialContext(ctx, "tcp", f.sandboxAddr())
 	if err != nil {
 		return
 	}
 	defer func() { _ = sandboxConn.Close() }()
 
+	relay(hostConn, sandboxConn)
+}
+
+// relay copies between host and sandbox in both directions until both sides
+// have finished, half-closing each side as the other reaches EOF.
+func relay(hostConn, sandboxConn net.Conn) {
 	var wg sync.WaitGroup
 	wg.Add(2)
 
@@ -166,6 +220,127 @@ func (f *Forwarder) handleConn(ctx context.Context, hostConn net.Conn) {
 	wg.Wait()
 }
 
+// sandboxAddr is the address dialed inside the sandbox.
+func (f *Forwarder) sandboxAddr() string {
+	host := f.SandboxHost
+	if host == "" {
+		host = "127.0.0.1"
+	}
+	return net.JoinHostPort(host, strconv.Itoa(f.SandboxPort))
+}
+
+// udpLoop reads datagrams from host clients and hands each to its client's
+// session, opening one for a client not seen before.
+func (f *Forwarder) udpLoop(ctx context.Context) {
+	buf := make([]byte, 64*1024)
+	for {
+		n, addr, err := f.packetConn.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) || ctx.Err() != nil {
+				return
+			}
+			continue
+		}
+		sess := f.udpSessionFor(ctx, addr)
+		if sess == nil {
+			continue
+		}
+		sess.lastSeen.Store(time.Now().UnixNano())
+		if _, err := sess.conn.Write(buf[:n]); err != nil {
+			f.closeUDPSession(addr.String(), sess)
+		}
+	}
+}
+
+// udpSessionFor returns addr's session, dialing a new one into the sandbox
+// when there is none. It returns nil when the session cannot be opened or
+// the session limit is reached; the datagram is then dropped, as UDP allows.
+func (f *Forwarder) udpSessionFor(ctx context.Context, addr net.Addr) *udpSession {
+	key := addr.String()
+	f.udpMu.Lock()
+	sess := f.udpSessions[key]
+	f.udpMu.Unlock()
+	if sess != nil {
+		return sess
+	}
+
+	select {
+	case f.sem <- struct{}{}:
+	default:
+		return nil
+	}
+	conn, err := f.Dialer.DialContext(ctx, "udp", f.sandboxAddr())
+	if err != nil {
+		<-f.sem
+		return nil
+	}
+	sess = &udpSession{conn: conn}
+	sess.lastSeen.Store(time.Now().UnixNano())
+
+	f.udpMu.Lock()
+	f.udpSessions[key] = sess
+	f.udpMu.Unlock()
+	f.active.Add(1)
+
+	f.wg.Go(func() {
+		defer func() { <-f.sem }()
+		defer f.active.Add(-1)
+		defer f.closeUDPSession(key, sess)
+
+		// sandbox → host
+		buf := make([]byte, 64*1024)
+		for {
+			n, err := conn.Read(buf)
+			if err != nil {
+				return
+			}
+			sess.lastSeen.Store(time.Now().UnixNano())
+			if _, err := f.packetConn.WriteTo(buf[:n], addr); err != nil {
+				return
+			}
+		}
+	})
+	return sess
+}
+
+// closeUDPSession closes sess and forgets it, unless key has since been
+// given a new session.
+func (f *Forwarder) closeUDPSession(key string, sess *udpSession) {
+	f.udpMu.Lock()
+	if f.udpSessions[key] == sess {
+		delete(f.udpSessions, key)
+	}
+	f.udpMu.Unlock()
+	_ = sess.conn.Close()
+}
+
+// reapUDPSessions closes sessions idle for longer than udpSessionIdle. A
+// pipe-backed namespace connection has no read deadline to do it instead.
+func (f *Forwarder) reapUDPSessions(ctx context.Context) {
+	ticker := time.NewTicker(udpSessionIdle / 4)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		cutoff := time.Now().Add(-udpSessionIdle).UnixNano()
+		f.udpMu.Lock()
+		var idle []*udpSession
+		for key, sess := range f.udpSessions {
+			if sess.lastSeen.Load() < cutoff {
+				idle = append(idle, sess)
+				delete(f.udpSessions, key)
+			}
+		}
+		f.udpMu.Unlock()
+		for _, sess := range idle {
+			_ = sess.conn.Close()
+		}
+	}
+}
+
 // closeWrite signals EOF on the write side of a connection so the remote
 // peer receives a FIN instead of waiting for the connection to close entirely.
 // Works for *net.TCPConn and any conn that exposes CloseWrite() (e.g. our
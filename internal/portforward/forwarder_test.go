@@ -198,3 +198,154 @@ func TestForwarder_StopClosesListener(t *testing.T) {
 		t.Fatal("expected connection to fail after Stop, but it succeeded")
 	}
 }
+
+// startUDPEchoServer starts a UDP echo server on a random port and returns
+// the conn (caller must close) and the port it is bound to.
+func startUDPEchoServer(t *testing.T) (net.PacketConn, int) {
+	t.Helper()
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("udp echo server listen: %v", err)
+	}
+
+	go func() {
+		buf := make([]byte, 64*1024)
+		for {
+			n, addr, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			_, _ = pc.WriteTo(buf[:n], addr)
+		}
+	}()
+
+	return pc, pc.LocalAddr().(*net.UDPAddr).Port
+}
+
+// udpExchange sends msg over conn and returns the reply.
+func udpExchange(t *testing.T, conn net.Conn, msg string) string {
+	t.Helper()
+	_ = conn.SetDeadline(time.Now().Add(2 * time.Second))
+	if _, err := conn.Write([]byte(msg)); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	buf := make([]byte, 1024)
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatalf("read echo: %v", err)
+	}
+	return string(buf[:n])
+}
+
+func TestForwarder_ProxiesUDP(t *testing.T) {
+	echo, echoPort := startUDPEchoServer(t)
+	defer echo.Close() //nolint:errcheck
+
+	f := &Forwarder{
+		SandboxPort: echoPort,
+		Protocol:    ProtocolUDP,
+		Dialer:      &localDialer{},
+	}
+	if err := f.Start(context.Background()); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer f.Stop()
+
+	addr := net.JoinHostPort("127.0.0.1", strconv.Itoa(f.ActualHostPort()))
+	a, err := net.Dial("udp", addr)
+	if err != nil {
+		t.Fatalf("dial a: %v", err)
+	}
+	defer a.Close() //nolint:errcheck
+	b, err := net.Dial("udp", addr)
+	if err != nil {
+		t.Fatalf("dial b: %v", err)
+	}
+	defer b.Close() //nolint:errcheck
+
+	// Each client gets its own session, and its replies only.
+	if got := udpExchange(t, a, "from a"); got != "from a" {
+		t.Errorf("a echo = %q, want %q", got, "from a")
+	}
+	if got := udpExchange(t, b, "from b"); got != "from b" {
+		t.Errorf("b echo = %q, want %q", got, "from b")
+	}
+	if got := udpExchange(t, a, "again"); got != "again" {
+		t.Errorf("a second echo = %q, want %q", got, "again")
+	}
+	if n := f.ActiveConnections(); n != 2 {
+		t.Errorf("ActiveConnections = %d, want 2 sessions", n)
+	}
+}
+
+func TestForwarder_StopClosesUDPSessions(t *testing.T) {
+	echo, echoPort := startUDPEchoServer(t)
+	defer echo.Close() //nolint:errcheck
+
+	f := &Forwarder{
+		SandboxPort: echoPort,
+		Protocol:    ProtocolUDP,
+		Dialer:      &localDialer{},
+	}
+	if err := f.Start(context.Background()); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	conn, err := net.Dial("udp", net.JoinHostPort("127.0.0.1", strconv.Itoa(f.ActualHostPort())))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close() //nolint:errcheck
+	_ = udpExchange(t, conn, "ping")
+
+	done := make(chan struct{})
+	go func() {
+		f.Stop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Stop did not return with a UDP session open")
+	}
+	if n := f.ActiveConnections(); n != 0 {
+		t.Errorf("ActiveConnections after Stop = %d, want 0", n)
+	}
+}
+
+func TestForwarder_SandboxHost(t *testing.T) {
+	ln, err := net.Listen("tcp", "[::1]:0")
+	if err != nil {
+		t.Skipf("no IPv6 loopback: %v", err)
+	}
+	defer ln.Close() //nolint:errcheck
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		_, _ = conn.Write([]byte("v6"))
+		_ = conn.Close()
+	}()
+
+	f := &Forwarder{
+		SandboxPort: ln.Addr().(*net.TCPAddr).Port,
+		SandboxHost: "::1",
+		Dialer:      &localDialer{},
+	}
+	if err := f.Start(context.Background()); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer f.Stop()
+
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(f.ActualHostPort())), 2*time.Second)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close() //nolint:errcheck
+	_ = conn.SetDeadline(time.Now().Add(2 * time.Second))
+	got, _ := io.ReadAll(conn)
+	if string(got) != "v6" {
+		t.Errorf("got %q, want %q from the IPv6-only listener", got, "v6")
+	}
+}
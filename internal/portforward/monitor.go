@@ -7,23 +7,44 @@ import (
 )
 
 // Monitor periodically scans for listening ports and fires callbacks when
-// ports appear or disappear.
+// ports appear or disappear. A TCP and a UDP socket on the same port are
+// separate ports; the same port on IPv4 and IPv6 is one.
+//
+// A UDP socket is reported only once it has been seen in two consecutive
+// scans: UDP has no listen state, and a client's short-lived socket would
+// otherwise be forwarded as if it were a server.
 type Monitor struct {
 	Scanner       PortScanner // From procnet.go
 	Interval      time.Duration
 	ExcludePorts  map[int]bool // Ports to never auto-forward
 	ManualPorts   map[int]bool // Ports already manually forwarded (skip these)
-	OnPortAdded   func(port int)
-	OnPortRemoved func(port int)
+	OnPortAdded   func(entry ListenEntry)
+	OnPortRemoved func(entry ListenEntry)
 
-	mu    sync.Mutex
-	known map[int]bool
-	wg    sync.WaitGroup
+	mu      sync.Mutex
+	known   map[portKey]ListenEntry
+	pending map[portKey]bool // UDP ports seen in the last scan, not yet reported
+	wg      sync.WaitGroup
+}
+
+// portKey identifies a port per protocol.
+type portKey struct {
+	protocol string
+	port     int
+}
+
+func keyOf(e ListenEntry) portKey {
+	protocol := e.Protocol
+	if protocol == "" {
+		protocol = ProtocolTCP
+	}
+	return portKey{protocol: protocol, port: e.Port}
 }
 
 // Start initialises the known port set and starts the background scanning goroutine.
 func (m *Monitor) Start(ctx context.Context) {
-	m.known = make(map[int]bool)
+	m.known = make(map[portKey]ListenEntry)
+	m.pending = make(map[portKey]bool)
 	m.wg.Go(func() {
 		m.loop(ctx)
 	})
@@ -74,33 +95,46 @@ func (m *Monitor) scan() {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	current := make(map[int]bool, len(entries))
+	// One entry per port and protocol. An IPv4 socket wins over an IPv6 one,
+	// so the port is reached at 127.0.0.1 whenever it is bound there at all.
+	current := make(map[portKey]ListenEntry, len(entries))
 	for _, e := range entries {
-		current[e.Port] = true
+		key := keyOf(e)
+		e.Protocol = key.protocol
+		if prev, ok := current[key]; ok && prev.DialHost() == "127.0.0.1" {
+			continue
+		}
+		current[key] = e
 	}
 
 	// Detect new ports.
-	for port := range current {
-		if m.known[port] {
+	pending := make(map[portKey]bool)
+	for key, e := range current {
+		if _, ok := m.known[key]; ok {
+			continue
+		}
+		if m.ExcludePorts[key.port] || m.ManualPorts[key.port] {
 			continue
 		}
-		if m.ExcludePorts[port] || m.ManualPorts[port] {
+		if key.protocol == ProtocolUDP && !m.pending[key] {
+			pending[key] = true
 			continue
 		}
-		m.known[port] = true
+		m.known[key] = e
 		if m.OnPortAdded != nil {
-			m.OnPortAdded(port)
+			m.OnPortAdded(e)
 		}
 	}
+	m.pending = pending
 
 	// Detect removed ports.
-	for port := range m.known {
-		if current[port] {
+	for key, e := range m.known {
+		if _, ok := current[key]; ok {
 			continue
 		}
-		delete(m.known, port)
+		delete(m.known, key)
 		if m.OnPortRemoved != nil {
-			m.OnPortRemoved(port)
+			m.OnPortRemoved(e)
 		}
 	}
 }
@@ -37,8 +37,8 @@ func TestMonitor_DetectsNewPort(t *testing.T) {
 	mon := &Monitor{
 		Scanner:  scanner,
 		Interval: 50 * time.Millisecond,
-		OnPortAdded: func(port int) {
-			if port == 3000 {
+		OnPortAdded: func(e ListenEntry) {
+			if e.Port == 3000 {
 				added.Add(1)
 			}
 		},
@@ -75,9 +75,9 @@ func TestMonitor_DetectsRemovedPort(t *testing.T) {
 	mon := &Monitor{
 		Scanner:     scanner,
 		Interval:    50 * time.Millisecond,
-		OnPortAdded: func(port int) {},
-		OnPortRemoved: func(port int) {
-			if port == 3000 {
+		OnPortAdded: func(e ListenEntry) {},
+		OnPortRemoved: func(e ListenEntry) {
+			if e.Port == 3000 {
 				removed.Add(1)
 			}
 		},
@@ -117,9 +117,9 @@ func TestMonitor_ExcludePorts(t *testing.T) {
 		Scanner:      scanner,
 		Interval:     50 * time.Millisecond,
 		ExcludePorts: map[int]bool{22: true},
-		OnPortAdded: func(port int) {
+		OnPortAdded: func(e ListenEntry) {
 			mu.Lock()
-			addedPorts = append(addedPorts, port)
+			addedPorts = append(addedPorts, e.Port)
 			mu.Unlock()
 		},
 	}
@@ -161,7 +161,7 @@ func TestMonitor_SkipsManuallyForwarded(t *testing.T) {
 		Scanner:     scanner,
 		Interval:    50 * time.Millisecond,
 		ManualPorts: map[int]bool{3000: true},
-		OnPortAdded: func(port int) {
+		OnPortAdded: func(e ListenEntry) {
 			added.Add(1)
 		},
 	}
@@ -175,3 +175,68 @@ func TestMonitor_SkipsManuallyForwarded(t *testing.T) {
 		t.Fatalf("expected no callbacks for manually-forwarded port, got %d", added.Load())
 	}
 }
+
+func TestMonitor_SeparatesProtocols(t *testing.T) {
+	scanner := &mockScanner{
+		ports: []ListenEntry{
+			{IP: "0.0.0.0", Port: 5353, Protocol: ProtocolTCP},
+			{IP: "::", Port: 5353, Protocol: ProtocolTCP},
+			{IP: "0.0.0.0", Port: 5353, Protocol: ProtocolUDP},
+		},
+	}
+
+	var mu sync.Mutex
+	var added []ListenEntry
+	mon := &Monitor{
+		Scanner:  scanner,
+		Interval: time.Hour,
+		OnPortAdded: func(e ListenEntry) {
+			mu.Lock()
+			added = append(added, e)
+			mu.Unlock()
+		},
+	}
+	mon.known = make(map[portKey]ListenEntry)
+
+	// The UDP socket is held back until it outlives a scan.
+	mon.scan()
+	if len(added) != 1 || added[0].Protocol != ProtocolTCP || added[0].DialHost() != "127.0.0.1" {
+		t.Fatalf("after first scan: %+v, want only tcp/5353 dialed at 127.0.0.1", added)
+	}
+	mon.scan()
+	if len(added) != 2 || added[1].Protocol != ProtocolUDP {
+		t.Fatalf("after second scan: %+v, want udp/5353 added", added)
+	}
+}
+
+func TestMonitor_IgnoresShortLivedUDP(t *testing.T) {
+	scanner := &mockScanner{ports: []ListenEntry{{IP: "0.0.0.0", Port: 41000, Protocol: ProtocolUDP}}}
+
+	var added atomic.Int32
+	mon := &Monitor{
+		Scanner:     scanner,
+		Interval:    time.Hour,
+		OnPortAdded: func(ListenEntry) { added.Add(1) },
+	}
+	mon.known = make(map[portKey]ListenEntry)
+
+	mon.scan()
+	scanner.setPorts(nil)
+	mon.scan()
+	if added.Load() != 0 {
+		t.Fatalf("a UDP socket seen in one scan was forwarded")
+	}
+}
+
+func TestListenEntry_DialHost(t *testing.T) {
+	for ip, want := range map[string]string{
+		"0.0.0.0":   "127.0.0.1",
+		"127.0.0.1": "127.0.0.1",
+		"::":        "::1",
+		"::1":       "::1",
+	} {
+		if got := (ListenEntry{IP: ip}).DialHost(); got != want {
+			t.Errorf("DialHost(%s) = %s, want %s", ip, got, want)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package portforward
 
 import (
 	"context"
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
@@ -17,8 +18,8 @@ type Dialer interface {
 	DialContext(ctx context.Context, network, address string) (net.Conn, error)
 }
 
-// NamespaceDialer dials TCP connections inside the network namespace of the
-// given target PID by delegating to an external helper process.
+// NamespaceDialer dials TCP and UDP connections inside the network namespace
+// of the given target PID by delegating to an external helper process.
 //
 // Why a subprocess?  Entering a user namespace via setns(2) with
 // CLONE_NEWUSER requires the calling process to be single-threaded, which Go
@@ -27,6 +28,10 @@ type Dialer interface {
 // a short-lived helper subcommand (`devsandbox __nsdial <host:port>`) that
 // opens the TCP connection and bridges stdin<->socket<->stdout. The parent
 // wraps the subprocess's pipes as a net.Conn.
+//
+// UDP goes through the same pipes with `__nsdial --udp`. A pipe is a byte
+// stream, so each datagram is framed with a length prefix (see
+// WriteDatagram) and the returned conn keeps datagram boundaries.
 type NamespaceDialer struct {
 	pid int
 
@@ -50,36 +55,23 @@ func NewNamespaceDialer(pid int) *NamespaceDialer {
 // DialContext launches the namespace-entering helper and returns a net.Conn
 // backed by its stdio. Closing the returned conn kills the helper.
 func (d *NamespaceDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
-	if network != "tcp" && network != "tcp4" && network != "tcp6" {
-		return nil, fmt.Errorf("namespace dialer only supports tcp, got %q", network)
-	}
-
-	nsenter := d.NsenterPath
-	if nsenter == "" {
-		path, err := exec.LookPath("nsenter")
-		if err != nil {
-			return nil, fmt.Errorf("nsenter not found on PATH (required for port forwarding): %w", err)
-		}
-		nsenter = path
+	var udp bool
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+	case "udp", "udp4", "udp6":
+		udp = true
+	default:
+		return nil, fmt.Errorf("namespace dialer only supports tcp and udp, got %q", network)
 	}
 
-	helper := d.HelperBinary
-	if helper == "" {
-		self, err := os.Executable()
-		if err != nil {
-			return nil, fmt.Errorf("resolve own executable: %w", err)
-		}
-		helper = self
+	helperArgs := []string{"__nsdial"}
+	if udp {
+		helperArgs = append(helperArgs, "--udp")
 	}
-
-	args := []string{
-		"--target", fmt.Sprintf("%d", d.pid),
-		"--user", "--net",
-		"--preserve-credentials",
-		"--",
-		helper, "__nsdial", address,
+	cmd, err := d.helperCommand(ctx, append(helperArgs, address)...)
+	if err != nil {
+		return nil, err
 	}
-	cmd := exec.CommandContext(ctx, nsenter, args...)
 
 	stdin, err := cmd.StdinPipe()
 	if err != nil {
@@ -100,7 +92,7 @@ func (d *NamespaceDialer) DialContext(ctx context.Context, network, address stri
 		return nil, fmt.Errorf("start helper: %w", err)
 	}
 
-	return &helperConn{
+	conn := &helperConn{
 		cmd:       cmd,
 		stdin:     stdin,
 		stdout:    stdout,
@@ -109,7 +101,107 @@ func (d *NamespaceDialer) DialContext(ctx context.Context, network, address stri
 		remoteAddr: helperAddr{
 			label: fmt.Sprintf("pid=%d %s", d.pid, address),
 		},
-	}, nil
+	}
+	if udp {
+		return &datagramConn{helperConn: conn}, nil
+	}
+	return conn, nil
+}
+
+// helperCommand builds the nsenter command that runs the helper binary with
+// args inside the target's user and network namespaces.
+func (d *NamespaceDialer) helperCommand(ctx context.Context, args ...string) (*exec.Cmd, error) {
+	nsenter := d.NsenterPath
+	if nsenter == "" {
+		path, err := exec.LookPath("nsenter")
+		if err != nil {
+			return nil, fmt.Errorf("nsenter not found on PATH (required for port forwarding): %w", err)
+		}
+		nsenter = path
+	}
+
+	helper := d.HelperBinary
+	if helper == "" {
+		self, err := os.Executable()
+		if err != nil {
+			return nil, fmt.Errorf("resolve own executable: %w", err)
+		}
+		helper = self
+	}
+
+	nsArgs := []string{
+		"--target", fmt.Sprintf("%d", d.pid),
+		"--user", "--net",
+		"--preserve-credentials",
+		"--",
+		helper,
+	}
+	return exec.CommandContext(ctx, nsenter, append(nsArgs, args...)...), nil
+}
+
+// MaxDatagramSize is the largest datagram the length prefix can carry.
+const MaxDatagramSize = 0xffff
+
+// WriteDatagram writes p to w as one frame: a two-byte big-endian length,
+// then the payload. It is the wire format between NamespaceDialer and the
+// `__nsdial --udp` helper.
+func WriteDatagram(w io.Writer, p []byte) error {
+	if len(p) > MaxDatagramSize {
+		return fmt.Errorf("datagram of %d bytes exceeds %d", len(p), MaxDatagramSize)
+	}
+	frame := make([]byte, 2+len(p))
+	binary.BigEndian.PutUint16(frame, uint16(len(p)))
+	copy(frame[2:], p)
+	_, err := w.Write(frame)
+	return err
+}
+
+// ReadDatagram reads one frame written by WriteDatagram into buf and returns
+// the payload length. A payload longer than buf is truncated to fit, as a
+// UDP read into a short buffer would be.
+func ReadDatagram(r io.Reader, buf []byte) (int, error) {
+	var hdr [2]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return 0, err
+	}
+	size := int(binary.BigEndian.Uint16(hdr[:]))
+	n := min(size, len(buf))
+	if _, err := io.ReadFull(r, buf[:n]); err != nil {
+		return 0, noEOF(err)
+	}
+	if size > n {
+		if _, err := io.CopyN(io.Discard, r, int64(size-n)); err != nil {
+			return 0, noEOF(err)
+		}
+	}
+	return n, nil
+}
+
+// noEOF turns an EOF in the middle of a frame into ErrUnexpectedEOF.
+func noEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// datagramConn is a helperConn carrying framed datagrams: each Write sends
+// one datagram and each Read returns one.
+type datagramConn struct {
+	*helperConn
+}
+
+// Read reads through helperConn.Read, so a helper that failed to dial
+// surfaces its stderr.
+func (c *datagramConn) Read(b []byte) (int, error) {
+	return ReadDatagram(c.helperConn, b)
+}
+
+func (c *datagramConn) Write(b []byte) (int, error) {
+	if err := WriteDatagram(c.helperConn.stdin, b); err != nil {
+		return 0, err
+	}
+	return len(b), nil
 }
 
 // helperConn adapts a running helper subprocess's stdio to the net.Conn
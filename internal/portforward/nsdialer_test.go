@@ -1,7 +1,9 @@
 package portforward
 
 import (
+	"bytes"
 	"context"
+	"io"
 	"net"
 	"runtime"
 	"strings"
@@ -15,12 +17,65 @@ func TestNamespaceDialer_UnsupportedNetwork(t *testing.T) {
 		t.Skip("Linux-only test")
 	}
 	d := NewNamespaceDialer(1)
-	_, err := d.DialContext(context.Background(), "udp", "127.0.0.1:1")
+	_, err := d.DialContext(context.Background(), "unix", "/tmp/sock")
 	if err == nil {
-		t.Fatal("expected error for non-tcp network")
+		t.Fatal("expected error for a non-tcp, non-udp network")
 	}
-	if !strings.Contains(err.Error(), "only supports tcp") {
-		t.Errorf("expected a 'tcp only' error, got: %v", err)
+	if !strings.Contains(err.Error(), "only supports tcp and udp") {
+		t.Errorf("expected a 'tcp and udp only' error, got: %v", err)
+	}
+}
+
+func TestDatagramFraming(t *testing.T) {
+	var stream bytes.Buffer
+	for _, p := range []string{"first", "", "third datagram"} {
+		if err := WriteDatagram(&stream, []byte(p)); err != nil {
+			t.Fatalf("WriteDatagram(%q): %v", p, err)
+		}
+	}
+
+	buf := make([]byte, 64)
+	for _, want := range []string{"first", "", "third datagram"} {
+		n, err := ReadDatagram(&stream, buf)
+		if err != nil {
+			t.Fatalf("ReadDatagram: %v", err)
+		}
+		if got := string(buf[:n]); got != want {
+			t.Errorf("datagram = %q, want %q", got, want)
+		}
+	}
+	if _, err := ReadDatagram(&stream, buf); err != io.EOF {
+		t.Errorf("read past the last frame: err = %v, want io.EOF", err)
+	}
+}
+
+func TestReadDatagram_TruncatesToBuffer(t *testing.T) {
+	var stream bytes.Buffer
+	_ = WriteDatagram(&stream, []byte("0123456789"))
+	_ = WriteDatagram(&stream, []byte("next"))
+
+	buf := make([]byte, 4)
+	n, err := ReadDatagram(&stream, buf)
+	if err != nil || string(buf[:n]) != "0123" {
+		t.Fatalf("truncated read = %q, %v; want \"0123\", nil", buf[:n], err)
+	}
+	// The rest of the long frame is discarded, not read as the next one.
+	n, err = ReadDatagram(&stream, buf)
+	if err != nil || string(buf[:n]) != "next" {
+		t.Errorf("next read = %q, %v; want \"next\", nil", buf[:n], err)
+	}
+}
+
+func TestReadDatagram_CutFrame(t *testing.T) {
+	stream := bytes.NewReader([]byte{0x00, 0x05, 'a', 'b'})
+	if _, err := ReadDatagram(stream, make([]byte, 16)); err != io.ErrUnexpectedEOF {
+		t.Errorf("err = %v, want io.ErrUnexpectedEOF", err)
+	}
+}
+
+func TestWriteDatagram_TooLarge(t *testing.T) {
+	if err := WriteDatagram(io.Discard, make([]byte, MaxDatagramSize+1)); err == nil {
+		t.Error("expected an error for a datagram over MaxDatagramSize")
 	}
 }
 
@@ -0,0 +1,171 @@
+package portforward
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ListenReady is the line the `__nsdial --listen` helper prints on stdout
+// once its listener is bound.
+const ListenReady = "ready"
+
+// listenReadyTimeout bounds how long Listen waits for the helper to bind.
+const listenReadyTimeout = 10 * time.Second
+
+// Listen opens a TCP listener inside the target's network namespace and
+// returns its host-side end: each connection accepted in the sandbox is
+// handed out by the returned listener's Accept.
+//
+// The helper (`__nsdial --listen <host:port> --bridge <socket>`) enters only
+// the user and network namespaces, so it still sees the host filesystem. For
+// every connection it accepts it dials a unix socket that Listen created in a
+// private temporary directory, and the host side accepts there - no
+// multiplexing over the helper's stdio is needed. Closing the listener, or
+// cancelling ctx, stops the helper and removes the directory.
+func (d *NamespaceDialer) Listen(ctx context.Context, network, address string) (net.Listener, error) {
+	if network != "tcp" && network != "tcp4" && network != "tcp6" {
+		return nil, fmt.Errorf("namespace listener only supports tcp, got %q", network)
+	}
+
+	dir, err := os.MkdirTemp("", "devsandbox-nslisten-")
+	if err != nil {
+		return nil, fmt.Errorf("create bridge directory: %w", err)
+	}
+	bridgePath := filepath.Join(dir, "bridge.sock")
+	bridge, err := net.Listen("unix", bridgePath)
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		return nil, fmt.Errorf("listen on bridge socket: %w", err)
+	}
+	cleanup := func() {
+		_ = bridge.Close()
+		_ = os.RemoveAll(dir)
+	}
+
+	cmd, err := d.helperCommand(ctx, "__nsdial", "--listen", "--bridge", bridgePath, address)
+	if err != nil {
+		cleanup()
+		return nil, err
+	}
+	// The helper exits when its stdin closes, so a parent that dies without
+	// calling Close does not leave a listener behind in the sandbox.
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		cleanup()
+		return nil, fmt.Errorf("stdin pipe: %w", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		cleanup()
+		return nil, fmt.Errorf("stdout pipe: %w", err)
+	}
+	stderrBuf := &cappedBuffer{max: 4096}
+	cmd.Stderr = stderrBuf
+
+	if err := cmd.Start(); err != nil {
+		cleanup()
+		return nil, fmt.Errorf("start helper: %w", err)
+	}
+
+	if err := waitReady(ctx, stdout); err != nil {
+		_ = stdin.Close()
+		if cmd.Process != nil {
+			_ = cmd.Process.Kill()
+		}
+		_ = cmd.Wait()
+		cleanup()
+		if msg := strings.TrimSpace(stderrBuf.String()); msg != "" {
+			return nil, errors.New(msg)
+		}
+		return nil, fmt.Errorf("listen %s in sandbox: %w", address, err)
+	}
+
+	l := &nsListener{
+		bridge:  bridge,
+		stdin:   stdin,
+		proc:    cmd.Process,
+		cleanup: cleanup,
+		addr:    helperAddr{label: fmt.Sprintf("pid=%d %s", d.pid, address)},
+		exited:  make(chan struct{}),
+	}
+	// A helper that dies on its own must not leave Accept blocked forever.
+	go func() {
+		_, _ = io.Copy(io.Discard, stdout)
+		_ = cmd.Wait()
+		_ = bridge.Close()
+		close(l.exited)
+	}()
+	return l, nil
+}
+
+// waitReady reads the helper's first line and checks that it is ListenReady.
+func waitReady(ctx context.Context, stdout io.Reader) error {
+	line := make(chan error, 1)
+	go func() {
+		s, err := bufio.NewReader(stdout).ReadString('\n')
+		if err != nil {
+			line <- fmt.Errorf("helper exited before listening: %w", err)
+			return
+		}
+		if strings.TrimSpace(s) != ListenReady {
+			line <- fmt.Errorf("unexpected helper output %q", strings.TrimSpace(s))
+			return
+		}
+		line <- nil
+	}()
+
+	timer := time.NewTimer(listenReadyTimeout)
+	defer timer.Stop()
+	select {
+	case err := <-line:
+		return err
+	case <-timer.C:
+		return errors.New("timed out waiting for the helper")
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// nsListener is the host-side end of a listener opened by Listen.
+type nsListener struct {
+	bridge  net.Listener
+	stdin   io.WriteCloser
+	proc    *os.Process
+	cleanup func()
+	addr    net.Addr
+	exited  chan struct{}
+
+	closeOnce sync.Once
+}
+
+func (l *nsListener) Accept() (net.Conn, error) {
+	return l.bridge.Accept()
+}
+
+// Close stops the helper - closing the listener in the sandbox along with
+// every connection relayed through it - and removes the bridge socket.
+func (l *nsListener) Close() error {
+	l.closeOnce.Do(func() {
+		_ = l.stdin.Close()
+		select {
+		case <-l.exited:
+		case <-time.After(time.Second):
+			_ = l.proc.Kill()
+			<-l.exited
+		}
+		l.cleanup()
+	})
+	return nil
+}
+
+// Addr describes the listener's address inside the sandbox.
+func (l *nsListener) Addr() net.Addr { return l.addr }
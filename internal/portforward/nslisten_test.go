@@ -0,0 +1,93 @@
+package portforward
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeNsenter writes a stand-in for nsenter that drops every argument up to
+// "--" and execs the rest, so the helper runs in the current namespaces.
+func fakeNsenter(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "nsenter")
+	script := "#!/bin/sh\nwhile [ \"$1\" != \"--\" ]; do shift; done\nshift\nexec \"$@\"\n"
+	if err := os.WriteFile(path, []byte(script), 0o755); err != nil {
+		t.Fatalf("write fake nsenter: %v", err)
+	}
+	return path
+}
+
+// fakeHelper writes a shell script standing in for `devsandbox __nsdial`.
+func fakeHelper(t *testing.T, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "helper")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+body+"\n"), 0o755); err != nil {
+		t.Fatalf("write fake helper: %v", err)
+	}
+	return path
+}
+
+func TestNamespaceDialer_ListenReadyAndClose(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("Linux-only test")
+	}
+	d := NewNamespaceDialer(1)
+	d.NsenterPath = fakeNsenter(t)
+	// Report ready, then hold the listener until stdin closes.
+	d.HelperBinary = fakeHelper(t, "echo "+ListenReady+"\ncat >/dev/null")
+
+	ln, err := d.Listen(context.Background(), "tcp", "127.0.0.1:5432")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	if got := ln.Addr().String(); !strings.Contains(got, "127.0.0.1:5432") {
+		t.Errorf("Addr = %q, want it to name the sandbox address", got)
+	}
+	bridgeDir := filepath.Dir(ln.(*nsListener).bridge.Addr().String())
+
+	done := make(chan struct{})
+	go func() {
+		_ = ln.Close()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close did not stop the helper")
+	}
+	if _, err := os.Stat(bridgeDir); !os.IsNotExist(err) {
+		t.Errorf("bridge directory %s still exists after Close (err = %v)", bridgeDir, err)
+	}
+	if _, err := ln.Accept(); err == nil {
+		t.Error("Accept succeeded after Close")
+	}
+}
+
+func TestNamespaceDialer_ListenSurfacesHelperError(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("Linux-only test")
+	}
+	d := NewNamespaceDialer(1)
+	d.NsenterPath = fakeNsenter(t)
+	d.HelperBinary = fakeHelper(t, "echo 'listen tcp 127.0.0.1:80: bind: permission denied' >&2\nexit 1")
+
+	_, err := d.Listen(context.Background(), "tcp", "127.0.0.1:80")
+	if err == nil {
+		t.Fatal("expected an error from a helper that cannot listen")
+	}
+	if !strings.Contains(err.Error(), "permission denied") {
+		t.Errorf("error = %v, want the helper's stderr", err)
+	}
+}
+
+func TestNamespaceDialer_ListenUnsupportedNetwork(t *testing.T) {
+	d := NewNamespaceDialer(1)
+	if _, err := d.Listen(context.Background(), "udp", "127.0.0.1:53"); err == nil {
+		t.Fatal("expected an error for a udp listener")
+	}
+}
@@ -1,20 +1,41 @@
-// Package portforward provides utilities for detecting listening TCP sockets
-// inside sandbox network namespaces via /proc/<pid>/net/tcp.
+// Package portforward provides utilities for detecting listening TCP and UDP
+// sockets inside sandbox network namespaces via /proc/<pid>/net/{tcp,tcp6,udp,udp6}.
 package portforward
 
 import (
 	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
+	"net"
 	"os"
 	"strconv"
 	"strings"
 )
 
-// ListenEntry represents a listening socket found in /proc/net/tcp.
+// Socket protocols a ListenEntry can carry.
+const (
+	ProtocolTCP = "tcp"
+	ProtocolUDP = "udp"
+)
+
+// ListenEntry represents a listening socket found in /proc/net/{tcp,udp}[6].
 type ListenEntry struct {
-	IP   string
-	Port int
+	IP       string
+	Port     int
+	Protocol string // ProtocolTCP or ProtocolUDP
+}
+
+// DialHost returns the loopback address a forwarder reaches this socket at
+// inside the sandbox: 127.0.0.1, unless the socket is bound to IPv6 only.
+func (e ListenEntry) DialHost() string {
+	if ip := net.ParseIP(e.IP); ip != nil && ip.To4() == nil {
+		return "::1"
+	}
+	return "127.0.0.1"
 }
 
 // PortScanner detects listening ports in a network namespace.
@@ -22,44 +43,94 @@ type PortScanner interface {
 	ListeningPorts() ([]ListenEntry, error)
 }
 
-// ProcNetScanner reads /proc/<pid>/net/tcp to find listening sockets.
+// ProcNetScanner reads /proc/<pid>/net/{tcp,tcp6,udp,udp6} to find listening
+// sockets. An empty path is not read.
 type ProcNetScanner struct {
-	ProcNetTCPPath string // e.g., "/proc/12345/net/tcp"
+	ProcNetTCPPath  string // e.g., "/proc/12345/net/tcp"
+	ProcNetTCP6Path string // e.g., "/proc/12345/net/tcp6"
+	ProcNetUDPPath  string // e.g., "/proc/12345/net/udp"
+	ProcNetUDP6Path string // e.g., "/proc/12345/net/udp6"
 }
 
 // NewProcNetScanner creates a ProcNetScanner for the given PID.
 func NewProcNetScanner(pid int) *ProcNetScanner {
+	dir := fmt.Sprintf("/proc/%d/net", pid)
 	return &ProcNetScanner{
-		ProcNetTCPPath: fmt.Sprintf("/proc/%d/net/tcp", pid),
+		ProcNetTCPPath:  dir + "/tcp",
+		ProcNetTCP6Path: dir + "/tcp6",
+		ProcNetUDPPath:  dir + "/udp",
+		ProcNetUDP6Path: dir + "/udp6",
 	}
 }
 
-// ListeningPorts reads ProcNetTCPPath, parses listening sockets, and returns
-// only those on non-privileged ports (>= 1024).
+// ListeningPorts reads the scanner's files, parses listening sockets, and
+// returns only those on non-privileged ports (>= 1024). The IPv4 TCP file must
+// exist - its absence means the sandbox is gone; the others are missing on a
+// kernel without IPv6 and are then skipped.
 func (s *ProcNetScanner) ListeningPorts() ([]ListenEntry, error) {
-	f, err := os.Open(s.ProcNetTCPPath)
-	if err != nil {
-		return nil, fmt.Errorf("open %s: %w", s.ProcNetTCPPath, err)
+	sources := []struct {
+		path     string
+		parse    func(io.Reader) ([]ListenEntry, error)
+		required bool
+	}{
+		{s.ProcNetTCPPath, parseProcNetTCP, true},
+		{s.ProcNetTCP6Path, parseProcNetTCP, false},
+		{s.ProcNetUDPPath, parseProcNetUDP, false},
+		{s.ProcNetUDP6Path, parseProcNetUDP, false},
 	}
-	defer func() { _ = f.Close() }()
 
-	all, err := parseProcNetTCP(f)
-	if err != nil {
-		return nil, err
-	}
-
-	filtered := make([]ListenEntry, 0, len(all))
-	for _, e := range all {
-		if e.Port >= 1024 {
-			filtered = append(filtered, e)
+	var filtered []ListenEntry
+	for _, src := range sources {
+		if src.path == "" {
+			continue
+		}
+		all, err := readProcNet(src.path, src.parse)
+		if err != nil {
+			if !src.required && errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+		for _, e := range all {
+			if e.Port >= 1024 {
+				filtered = append(filtered, e)
+			}
 		}
 	}
 	return filtered, nil
 }
 
+func readProcNet(path string, parse func(io.Reader) ([]ListenEntry, error)) ([]ListenEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", path, err)
+	}
+	defer func() { _ = f.Close() }()
+	return parse(f)
+}
+
 // parseProcNetTCP parses the contents of /proc/net/tcp (or /proc/net/tcp6)
 // and returns all sockets in the LISTEN state (0A).
 func parseProcNetTCP(r io.Reader) ([]ListenEntry, error) {
+	return parseProcNetSockets(r, ProtocolTCP, func(state, _ string) bool {
+		return state == "0A"
+	})
+}
+
+// parseProcNetUDP parses the contents of /proc/net/udp (or /proc/net/udp6)
+// and returns every bound socket with no peer: state 07 (UNCONN) and a zero
+// remote port. UDP has no listen state, so a client socket sending with
+// sendto(2) looks the same - Monitor waits for a UDP socket to outlive a scan
+// before forwarding it.
+func parseProcNetUDP(r io.Reader) ([]ListenEntry, error) {
+	return parseProcNetSockets(r, ProtocolUDP, func(state, remote string) bool {
+		return state == "07" && strings.HasSuffix(remote, ":0000")
+	})
+}
+
+// parseProcNetSockets parses a /proc/net socket table, keeping the rows keep
+// accepts given their st and rem_address columns.
+func parseProcNetSockets(r io.Reader, protocol string, keep func(state, remote string) bool) ([]ListenEntry, error) {
 	var entries []ListenEntry
 	scanner := bufio.NewScanner(r)
 
@@ -80,8 +151,7 @@ func parseProcNetTCP(r io.Reader) ([]ListenEntry, error) {
 			continue
 		}
 
-		state := fields[3]
-		if state != "0A" {
+		if !keep(fields[3], fields[2]) {
 			continue
 		}
 
@@ -90,19 +160,20 @@ func parseProcNetTCP(r io.Reader) ([]ListenEntry, error) {
 			return nil, fmt.Errorf("parse local_address %q: %w", fields[1], err)
 		}
 
-		entries = append(entries, ListenEntry{IP: ip, Port: port})
+		entries = append(entries, ListenEntry{IP: ip, Port: port, Protocol: protocol})
 	}
 
 	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("reading /proc/net/tcp: %w", err)
+		return nil, fmt.Errorf("reading /proc/net/%s: %w", protocol, err)
 	}
 
 	return entries, nil
 }
 
-// parseHexAddr parses a "HHHHHHHH:PPPP" address field from /proc/net/tcp.
-// The IP is encoded as a little-endian hex uint32 (x86 byte order),
-// and the port is a big-endian hex uint16.
+// parseHexAddr parses a "HHHHHHHH:PPPP" (IPv4) or 32-digit "HHHH...:PPPP"
+// (IPv6) address field from /proc/net/{tcp,udp}[6]. The IP is stored as
+// 32-bit words in host byte order (little-endian on x86), and the port is a
+// big-endian hex uint16.
 func parseHexAddr(s string) (string, int, error) {
 	parts := strings.SplitN(s, ":", 2)
 	if len(parts) != 2 {
@@ -112,9 +183,9 @@ func parseHexAddr(s string) (string, int, error) {
 	ipHex := parts[0]
 	portHex := parts[1]
 
-	ipInt, err := strconv.ParseUint(ipHex, 16, 32)
-	if err != nil {
-		return "", 0, fmt.Errorf("parse IP hex %q: %w", ipHex, err)
+	raw, err := hex.DecodeString(ipHex)
+	if err != nil || (len(raw) != net.IPv4len && len(raw) != net.IPv6len) {
+		return "", 0, fmt.Errorf("parse IP hex %q: want 8 or 32 hex digits", ipHex)
 	}
 
 	portInt, err := strconv.ParseUint(portHex, 16, 16)
@@ -122,14 +193,12 @@ func parseHexAddr(s string) (string, int, error) {
 		return "", 0, fmt.Errorf("parse port hex %q: %w", portHex, err)
 	}
 
-	// Bytes are stored in little-endian order on x86:
-	// lowest byte of uint32 is the first octet of the IP.
-	ip := fmt.Sprintf("%d.%d.%d.%d",
-		ipInt&0xFF,
-		(ipInt>>8)&0xFF,
-		(ipInt>>16)&0xFF,
-		(ipInt>>24)&0xFF,
-	)
+	// Each 32-bit word was printed as a little-endian integer: reverse its
+	// bytes to recover network order.
+	ip := make(net.IP, len(raw))
+	for i := 0; i < len(raw); i += 4 {
+		binary.BigEndian.PutUint32(ip[i:], binary.LittleEndian.Uint32(raw[i:]))
+	}
 
-	return ip, int(portInt), nil
+	return ip.String(), int(portInt), nil
 }
@@ -103,3 +103,79 @@ func TestProcNetScanner_ListeningPorts(t *testing.T) {
 		t.Error("expected port 8080 in entries")
 	}
 }
+
+func TestParseProcNetTCP6(t *testing.T) {
+	// "::" on 3000 and "::1" on 8080, both listening.
+	data := `  sl  local_address                         remote_address                        st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 00000000000000000000000000000000:0BB8 00000000000000000000000000000000:0000 0A 00000000:00000000 00:00000000 00000000  1000        0 1 1 0000000000000000 100 0 0 10 0
+   1: 00000000000000000000000001000000:1F90 00000000000000000000000000000000:0000 0A 00000000:00000000 00:00000000 00000000  1000        0 2 1 0000000000000000 100 0 0 10 0
+`
+	entries, err := parseProcNetTCP(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("parseProcNetTCP returned error: %v", err)
+	}
+	want := []ListenEntry{
+		{IP: "::", Port: 3000, Protocol: ProtocolTCP},
+		{IP: "::1", Port: 8080, Protocol: ProtocolTCP},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("expected %d entries, got %v", len(want), entries)
+	}
+	for i := range want {
+		if entries[i] != want[i] {
+			t.Errorf("entry[%d] = %+v, want %+v", i, entries[i], want[i])
+		}
+	}
+}
+
+func TestParseProcNetUDP(t *testing.T) {
+	// A bound socket with no peer (5353), and a connected one (st 01, remote
+	// port set) that is a client, not a server.
+	data := `   sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode ref pointer drops
+  100: 00000000:14E9 00000000:0000 07 00000000:00000000 00:00000000 00000000  1000        0 1 2 0000000000000000 0
+  101: 0100007F:A1B2 0100007F:0035 01 00000000:00000000 00:00000000 00000000  1000        0 2 2 0000000000000000 0
+`
+	entries, err := parseProcNetUDP(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("parseProcNetUDP returned error: %v", err)
+	}
+	if len(entries) != 1 || entries[0] != (ListenEntry{IP: "0.0.0.0", Port: 5353, Protocol: ProtocolUDP}) {
+		t.Errorf("entries = %+v, want only udp 0.0.0.0:5353", entries)
+	}
+}
+
+func TestProcNetScanner_AllFamilies(t *testing.T) {
+	header := "  sl  local_address rem_address   st\n"
+	dir := t.TempDir()
+	files := map[string]string{
+		"tcp":  header + "   0: 00000000:0BB8 00000000:0000 0A\n",
+		"tcp6": header + "   0: 00000000000000000000000000000000:0BB9 00000000000000000000000000000000:0000 0A\n",
+		"udp":  header + "   0: 00000000:14E9 00000000:0000 07\n",
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// udp6 is missing, as on a kernel without IPv6: skipped, not an error.
+	scanner := &ProcNetScanner{
+		ProcNetTCPPath:  filepath.Join(dir, "tcp"),
+		ProcNetTCP6Path: filepath.Join(dir, "tcp6"),
+		ProcNetUDPPath:  filepath.Join(dir, "udp"),
+		ProcNetUDP6Path: filepath.Join(dir, "udp6"),
+	}
+	entries, err := scanner.ListeningPorts()
+	if err != nil {
+		t.Fatalf("ListeningPorts returned error: %v", err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 entries, got %v", entries)
+	}
+
+	// The IPv4 TCP table is required: its absence means the sandbox is gone.
+	scanner.ProcNetTCPPath = filepath.Join(dir, "missing")
+	if _, err := scanner.ListeningPorts(); err == nil {
+		t.Error("expected an error without the tcp table")
+	}
+}
@@ -0,0 +1,120 @@
+package portforward
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// NamespaceListener opens listeners, optionally in a different network
+// namespace. NamespaceDialer implements it.
+type NamespaceListener interface {
+	Listen(ctx context.Context, network, address string) (net.Listener, error)
+}
+
+// reverseDialTimeout bounds each connection to the host target.
+const reverseDialTimeout = 10 * time.Second
+
+// ReverseForwarder is the mirror image of Forwarder: it listens on a port
+// inside the sandbox and relays every connection to a target on the host - a
+// TCP port or a unix socket, such as a local Postgres socket or an IDE debug
+// adapter.
+type ReverseForwarder struct {
+	SandboxPort   int               // Port to listen on inside the sandbox
+	SandboxHost   string            // Bind address inside the sandbox (default "127.0.0.1")
+	TargetNetwork string            // "tcp" or "unix"
+	TargetAddress string            // Host "host:port" or unix socket path
+	Listener      NamespaceListener // Namespace-aware listener
+
+	listener net.Listener
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+	sem      chan struct{}
+	active   atomic.Int64
+}
+
+// Start opens the sandbox-side listener and begins relaying. The listener
+// lives until Stop or until ctx is cancelled.
+func (r *ReverseForwarder) Start(ctx context.Context) error {
+	if r.TargetNetwork != "tcp" && r.TargetNetwork != "unix" {
+		return fmt.Errorf("reverse forward target must be tcp or unix, got %q", r.TargetNetwork)
+	}
+	host := r.SandboxHost
+	if host == "" {
+		host = "127.0.0.1"
+	}
+	addr := net.JoinHostPort(host, strconv.Itoa(r.SandboxPort))
+
+	loopCtx, cancel := context.WithCancel(ctx)
+	ln, err := r.Listener.Listen(loopCtx, "tcp", addr)
+	if err != nil {
+		cancel()
+		return fmt.Errorf("listen %s in sandbox: %w", addr, err)
+	}
+	r.listener = ln
+	r.cancel = cancel
+	r.sem = make(chan struct{}, maxConnections)
+
+	r.wg.Go(func() {
+		r.acceptLoop(loopCtx)
+	})
+	return nil
+}
+
+// Stop closes the sandbox-side listener and waits for in-flight connections
+// to finish.
+func (r *ReverseForwarder) Stop() {
+	if r.cancel != nil {
+		r.cancel()
+	}
+	if r.listener != nil {
+		_ = r.listener.Close()
+	}
+	r.wg.Wait()
+}
+
+// ActiveConnections returns the number of connections currently being proxied.
+func (r *ReverseForwarder) ActiveConnections() int64 {
+	return r.active.Load()
+}
+
+func (r *ReverseForwarder) acceptLoop(ctx context.Context) {
+	for {
+		conn, err := r.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		select {
+		case r.sem <- struct{}{}:
+		case <-ctx.Done():
+			_ = conn.Close()
+			return
+		}
+
+		r.wg.Go(func() {
+			defer func() { <-r.sem }()
+			r.handleConn(ctx, conn)
+		})
+	}
+}
+
+func (r *ReverseForwarder) handleConn(ctx context.Context, sandboxConn net.Conn) {
+	defer func() { _ = sandboxConn.Close() }()
+
+	r.active.Add(1)
+	defer r.active.Add(-1)
+
+	dialer := net.Dialer{Timeout: reverseDialTimeout}
+	hostConn, err := dialer.DialContext(ctx, r.TargetNetwork, r.TargetAddress)
+	if err != nil {
+		return
+	}
+	defer func() { _ = hostConn.Close() }()
+
+	relay(hostConn, sandboxConn)
+}
@@ -0,0 +1,153 @@
+package portforward
+
+import (
+	"context"
+	"io"
+	"net"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// localListener listens in the same network namespace, used to test the
+// reverse forwarder without requiring Linux namespace privileges.
+type localListener struct{}
+
+func (l *localListener) Listen(ctx context.Context, network, address string) (net.Listener, error) {
+	var lc net.ListenConfig
+	return lc.Listen(ctx, network, address)
+}
+
+// freePort returns a loopback TCP port that was free a moment ago.
+func freePort(t *testing.T) int {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("reserve port: %v", err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	_ = ln.Close()
+	return port
+}
+
+// exchange dials port, writes msg, half-closes and returns what came back.
+func exchange(t *testing.T, port int, msg string) string {
+	t.Helper()
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(port)), 2*time.Second)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close() //nolint:errcheck
+	_ = conn.SetDeadline(time.Now().Add(2 * time.Second))
+	if _, err := conn.Write([]byte(msg)); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	_ = conn.(*net.TCPConn).CloseWrite()
+	got, err := io.ReadAll(conn)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	return string(got)
+}
+
+func TestReverseForwarder_TCPTarget(t *testing.T) {
+	echoLn, echoPort := startEchoServer(t)
+	defer echoLn.Close() //nolint:errcheck
+
+	r := &ReverseForwarder{
+		SandboxPort:   freePort(t),
+		TargetNetwork: "tcp",
+		TargetAddress: net.JoinHostPort("127.0.0.1", strconv.Itoa(echoPort)),
+		Listener:      &localListener{},
+	}
+	if err := r.Start(context.Background()); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer r.Stop()
+
+	if got := exchange(t, r.SandboxPort, "hello"); got != "hello" {
+		t.Errorf("echo = %q, want %q", got, "hello")
+	}
+}
+
+func TestReverseForwarder_UnixTarget(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "echo.sock")
+	ln, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatalf("listen unix: %v", err)
+	}
+	defer ln.Close() //nolint:errcheck
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(c net.Conn) {
+				defer c.Close() //nolint:errcheck
+				_, _ = io.Copy(c, c)
+			}(conn)
+		}
+	}()
+
+	r := &ReverseForwarder{
+		SandboxPort:   freePort(t),
+		TargetNetwork: "unix",
+		TargetAddress: sock,
+		Listener:      &localListener{},
+	}
+	if err := r.Start(context.Background()); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer r.Stop()
+
+	if got := exchange(t, r.SandboxPort, "over unix"); got != "over unix" {
+		t.Errorf("echo = %q, want %q", got, "over unix")
+	}
+}
+
+func TestReverseForwarder_UnreachableTargetClosesConn(t *testing.T) {
+	r := &ReverseForwarder{
+		SandboxPort:   freePort(t),
+		TargetNetwork: "unix",
+		TargetAddress: filepath.Join(t.TempDir(), "missing.sock"),
+		Listener:      &localListener{},
+	}
+	if err := r.Start(context.Background()); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer r.Stop()
+
+	// The sandbox client sees the connection close instead of hanging.
+	if got := exchange(t, r.SandboxPort, "anyone?"); got != "" {
+		t.Errorf("got %q from an unreachable target, want nothing", got)
+	}
+}
+
+func TestReverseForwarder_RejectsTargetNetwork(t *testing.T) {
+	r := &ReverseForwarder{TargetNetwork: "udp", TargetAddress: "127.0.0.1:53", Listener: &localListener{}}
+	if err := r.Start(context.Background()); err == nil {
+		r.Stop()
+		t.Fatal("expected an error for a udp target")
+	}
+}
+
+func TestReverseForwarder_StopClosesListener(t *testing.T) {
+	r := &ReverseForwarder{
+		SandboxPort:   freePort(t),
+		TargetNetwork: "tcp",
+		TargetAddress: "127.0.0.1:1",
+		Listener:      &localListener{},
+	}
+	if err := r.Start(context.Background()); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	r.Stop()
+
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(r.SandboxPort)), 500*time.Millisecond)
+	if err == nil {
+		conn.Close() //nolint:errcheck
+		t.Fatal("expected connection to fail after Stop, but it succeeded")
+	}
+}
@@ -5,9 +5,15 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net"
+	"net/url"
 	"os"
+	"path"
+	"slices"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -27,7 +33,14 @@ const (
 	AskModeClient AskMode = "client"
 )
 
+// AskTopicLimits is the subscription topic for [proxy.limits] counters.
+const AskTopicLimits = "limits"
+
 // AskRequest is sent from the proxy to the monitor for user approval.
+//
+// A message with a Type is not a request: it carries no ID and expects no
+// response, and is sent only to a monitor that subscribed to its topic, so a
+// monitor predating the topic never mistakes it for a prompt.
 type AskRequest struct {
 	ID      string            `json:"id"`
 	Method  string            `json:"method"`
@@ -37,6 +50,26 @@ type AskRequest struct {
 	Headers map[string]string `json:"headers,omitempty"`
 	Body    string            `json:"body,omitempty"`
 	Timeout int               `json:"timeout,omitempty"` // Seconds until auto-reject; 0 = 30s default
+
+	// Limit is set when the request is over a [proxy.limits] rule, naming
+	// the limit. Such an approval covers this request only: it is never
+	// remembered for the host.
+	Limit string `json:"limit,omitempty"`
+
+	// Project is the directory whose .devsandbox.toml the session loaded, for
+	// a monitor to write a permanent decision to. Empty when none was loaded.
+	Project string `json:"project,omitempty"`
+
+	// HostRulesOnly is set when the proxy cannot enforce path or URL rules
+	// (MITM is off), so a permanent decision can only name the host.
+	HostRulesOnly bool `json:"host_rules_only,omitempty"`
+
+	// Session names the sandbox session the request came from, for a monitor
+	// serving several. Empty until the session is named.
+	Session string `json:"session,omitempty"`
+
+	Type   string          `json:"type,omitempty"`   // "" for a request; otherwise a topic
+	Limits []LimitCounters `json:"limits,omitempty"` // Type == AskTopicLimits
 }
 
 // AskResponse is sent from the monitor back to the proxy.
@@ -44,7 +77,22 @@ type AskResponse struct {
 	ID        string       `json:"id"`
 	Action    FilterAction `json:"action"`
 	Remember  bool         `json:"remember"`  // Remember for session
-	Permanent bool         `json:"permanent"` // Add to config (future)
+	Permanent bool         `json:"permanent"` // Rule was added to config
+
+	// Scope is how much of the request the rule a permanent decision wrote
+	// to config covers. The proxy rebuilds that rule from its own copy of the
+	// request with PermanentRule and applies it for the rest of the session
+	// (see FilterEngine.AddSessionRule), so a monitor can widen nothing past
+	// the request it was asked about.
+	Scope PermanentScope `json:"scope,omitempty"`
+
+	// RememberFor remembers the decision for the host for this many seconds.
+	// It is sent without Remember, so a proxy predating it takes the answer
+	// for this request only rather than for the whole session.
+	RememberFor int `json:"remember_for,omitempty"`
+
+	// Subscribe, sent without an ID, asks for the messages of these topics.
+	Subscribe []string `json:"subscribe,omitempty"`
 }
 
 // monitorConn represents a connected monitor client.
@@ -52,6 +100,7 @@ type monitorConn struct {
 	conn    net.Conn
 	encoder *json.Encoder
 	decoder *json.Decoder
+	limits  atomic.Bool // subscribed to AskTopicLimits
 }
 
 // AskServer manages connections from monitor clients and routes approval requests.
@@ -70,10 +119,19 @@ type AskServer struct {
 	decoder  *json.Decoder
 	clientMu sync.Mutex
 
+	clientLimits atomic.Bool // the monitor subscribed to AskTopicLimits
+
 	// Shared fields
 	pending   map[string]chan AskResponse
 	pendingMu sync.Mutex
 
+	// subscriptions counts subscribe messages received, so a publisher can
+	// tell a monitor has just asked for state it has not been sent yet.
+	subscriptions atomic.Uint64
+
+	// notifier asks when no monitor can; see SetNotifier.
+	notifier AskNotifier
+
 	closed bool
 	mu     sync.Mutex
 }
@@ -190,6 +248,13 @@ func (s *AskServer) handleMonitor(monitor *monitorConn) {
 		if err := monitor.decoder.Decode(&resp); err != nil {
 			return
 		}
+		if resp.ID == "" {
+			if slices.Contains(resp.Subscribe, AskTopicLimits) {
+				monitor.limits.Store(true)
+				s.subscriptions.Add(1)
+			}
+			continue
+		}
 
 		// Deliver response to waiting request
 		s.pendingMu.Lock()
@@ -226,6 +291,13 @@ func (s *AskServer) handleClientResponses() {
 				}
 				break // Connection lost, enter reconnect loop
 			}
+			if resp.ID == "" {
+				if slices.Contains(resp.Subscribe, AskTopicLimits) {
+					s.clientLimits.Store(true)
+					s.subscriptions.Add(1)
+				}
+				continue
+			}
 
 			s.pendingMu.Lock()
 			ch, ok := s.pending[resp.ID]
@@ -247,6 +319,7 @@ func (s *AskServer) handleClientResponses() {
 		s.mu.Lock()
 		s.conn = nil
 		s.mu.Unlock()
+		s.clientLimits.Store(false)
 
 		s.pendingMu.Lock()
 		for id, ch := range s.pending {
@@ -305,12 +378,32 @@ func (s *AskServer) removeMonitor(monitor *monitorConn) {
 	}
 }
 
-// Ask sends a request to connected monitors and waits for a response.
+// SetNotifier sets where requests go when no monitor is connected, or the
+// monitor disconnects before answering. Call it before the first Ask. The
+// server closes it on Close if it is an io.Closer.
+func (s *AskServer) SetNotifier(n AskNotifier) {
+	s.notifier = n
+}
+
+// Ask sends a request to connected monitors and waits for a response. With
+// none connected it falls back to the notifier, if one is set.
 func (s *AskServer) Ask(ctx context.Context, req *AskRequest) (AskResponse, error) {
+	var resp AskResponse
+	var err error
 	if s.mode == AskModeClient {
-		return s.askClient(ctx, req)
+		resp, err = s.askClient(ctx, req)
+	} else {
+		resp, err = s.askServer(ctx, req)
 	}
-	return s.askServer(ctx, req)
+	if errors.Is(err, ErrNoMonitor) && s.notifier != nil && ctx.Err() == nil {
+		s.mu.Lock()
+		closed := s.closed
+		s.mu.Unlock()
+		if !closed {
+			return s.notifier.Ask(ctx, req)
+		}
+	}
+	return resp, err
 }
 
 // askClient sends a request to the monitor over the client connection and waits for a response.
@@ -396,12 +489,51 @@ func (s *AskServer) askServer(ctx context.Context, req *AskRequest) (AskResponse
 	}
 }
 
+// Subscriptions returns how many subscribe messages monitors have sent. It
+// changes whenever a monitor (re)subscribes.
+func (s *AskServer) Subscriptions() uint64 {
+	return s.subscriptions.Load()
+}
+
+// Publish sends a topic message to every monitor subscribed to its topic.
+// Delivery is best effort: a monitor that cannot take it is dropped by its
+// reader, as on any other write failure.
+func (s *AskServer) Publish(msg *AskRequest) {
+	if msg.Type != AskTopicLimits {
+		return
+	}
+	if s.mode == AskModeClient {
+		if !s.clientLimits.Load() {
+			return
+		}
+		s.clientMu.Lock()
+		_ = s.encoder.Encode(msg)
+		s.clientMu.Unlock()
+		return
+	}
+
+	s.monitorsMu.RLock()
+	monitors := make([]*monitorConn, len(s.monitors))
+	copy(monitors, s.monitors)
+	s.monitorsMu.RUnlock()
+
+	for _, monitor := range monitors {
+		if monitor.limits.Load() {
+			_ = monitor.encoder.Encode(msg)
+		}
+	}
+}
+
 // Close shuts down the ask server.
 func (s *AskServer) Close() error {
 	s.mu.Lock()
 	s.closed = true
 	s.mu.Unlock()
 
+	if c, ok := s.notifier.(io.Closer); ok {
+		_ = c.Close()
+	}
+
 	if s.mode == AskModeClient {
 		if s.conn != nil {
 			_ = s.conn.Close()
@@ -451,7 +583,8 @@ func NewAskQueue(server *AskServer, engine *FilterEngine, timeout time.Duration)
 }
 
 // RequestApproval blocks until user approves or denies the request.
-// Returns ErrNoMonitor if no monitor is connected.
+// Returns ErrNoMonitor if no monitor is connected and there is no notifier
+// to fall back to.
 // Returns ErrTimeout if the request times out.
 func (q *AskQueue) RequestApproval(req *AskRequest) (FilterAction, error) {
 	req.Timeout = int(q.timeout.Seconds())
@@ -465,14 +598,101 @@ func (q *AskQueue) RequestApproval(req *AskRequest) (FilterAction, error) {
 		return FilterActionBlock, err
 	}
 
-	// Cache decision if requested
-	if resp.Remember && q.filterEngine != nil {
+	// Cache decision if requested. An over-limit approval is about this
+	// request, not the host: caching it would answer the filter's own
+	// prompts for the host from then on.
+	if resp.Remember && q.filterEngine != nil && req.Limit == "" {
 		q.filterEngine.CacheDecision(req.Host, resp.Action)
 	}
+	if resp.RememberFor > 0 && q.filterEngine != nil && req.Limit == "" {
+		q.filterEngine.CacheDecisionFor(req.Host, resp.Action, time.Duration(resp.RememberFor)*time.Second)
+	}
+	if resp.Permanent && resp.Scope != "" && q.filterEngine != nil && req.Limit == "" {
+		// A scope PermanentRule refuses for this request - one naming a path
+		// without MITM, say - adds nothing; this request's answer stands
+		// regardless.
+		if rule, err := PermanentRule(req, resp.Action, resp.Scope); err == nil {
+			_ = q.filterEngine.AddSessionRule(rule)
+		}
+	}
 
 	return resp.Action, nil
 }
 
+// PermanentScope is how much of a request a permanent decision covers.
+type PermanentScope string
+
+const (
+	// PermanentScopeHost covers every request to the host.
+	PermanentScopeHost PermanentScope = "host"
+	// PermanentScopePathPrefix covers the request's directory on the host
+	// and everything below it.
+	PermanentScopePathPrefix PermanentScope = "path-prefix"
+	// PermanentScopeURL covers the exact URL, query included.
+	PermanentScopeURL PermanentScope = "url"
+)
+
+// PermanentRule builds the rule that makes action the answer to req, and to
+// every request scope covers, from now on. The pattern is written the way the
+// filter canonicalizes what it matches, so the rule matches the request it was
+// made for. A host rule for a SOCKS connection names its port: with MITM on,
+// only a rule naming ports lets raw TCP through, and the answer was given for
+// that port alone.
+func PermanentRule(req *AskRequest, action FilterAction, scope PermanentScope) (FilterRule, error) {
+	if action != FilterActionAllow && action != FilterActionBlock {
+		return FilterRule{}, fmt.Errorf("a permanent decision must allow or block, got %q", action)
+	}
+	if scope == PermanentScopeHost {
+		rule := FilterRule{Pattern: NormalizeHost(req.Host), Action: action, Scope: FilterScopeHost, Type: PatternTypeExact}
+		if port := hostPort(req.Host); req.Method == "TCP" && port != 0 {
+			rule.Ports = []int{port}
+		}
+		return rule, nil
+	}
+	if scope != PermanentScopePathPrefix && scope != PermanentScopeURL {
+		return FilterRule{}, fmt.Errorf("unknown permanent decision scope %q", scope)
+	}
+	if req.HostRulesOnly {
+		return FilterRule{}, fmt.Errorf("path and URL rules need MITM; only the host can be remembered")
+	}
+
+	u, err := url.Parse(req.URL)
+	if err != nil || u.Host == "" || u.Path == "" {
+		return FilterRule{}, fmt.Errorf("request URL %q names no path; only the host can be remembered", req.URL)
+	}
+	if scope == PermanentScopeURL {
+		return FilterRule{Pattern: canonicalizeURL(u), Action: action, Scope: FilterScopeURL, Type: PatternTypeExact}, nil
+	}
+
+	dir := u.Path
+	if !strings.HasSuffix(dir, "/") {
+		dir = path.Dir(dir)
+	}
+	dir = strings.TrimSuffix(dir, "/")
+	scheme := strings.ToLower(u.Scheme)
+	authority := canonicalizeAuthority(scheme, u.Host)
+	if escapeGlob(authority) != authority {
+		// An IPv6 literal's brackets. The filter canonicalizes a pattern's
+		// authority before matching, which an escaped one does not survive.
+		return FilterRule{}, fmt.Errorf("a path prefix cannot be remembered for %s; remember the host or the URL", authority)
+	}
+	pattern := scheme + "://" + authority + escapeGlob(dir) + "/**"
+	return FilterRule{Pattern: pattern, Action: action, Scope: FilterScopeURL, Type: PatternTypeGlob}, nil
+}
+
+// escapeGlob escapes the glob metacharacters in s, so a path spelling one is
+// matched literally.
+func escapeGlob(s string) string {
+	var sb strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(`*?[]{}\`, r) {
+			sb.WriteByte('\\')
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
 // Close cleans up the ask queue.
 func (q *AskQueue) Close() error {
 	return nil
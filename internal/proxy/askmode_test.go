@@ -5,7 +5,10 @@ import (
 	"encoding/json"
 	"errors"
 	"net"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"slices"
 	"testing"
 	"time"
 )
@@ -299,3 +302,271 @@ func TestAskServer_Close_CancelsPending(t *testing.T) {
 		t.Fatal("Ask did not return after Close — pending request was not cancelled")
 	}
 }
+
+func TestAskServer_PublishOnlyToSubscribers(t *testing.T) {
+	dir := shortTempDir(t)
+	server, err := NewAskServer(dir)
+	if err != nil {
+		t.Fatalf("NewAskServer failed: %v", err)
+	}
+	defer func() { _ = server.Close() }()
+
+	dial := func() (net.Conn, *json.Decoder) {
+		conn, err := net.Dial("unix", AskSocketPath(dir))
+		if err != nil {
+			t.Fatalf("monitor dial failed: %v", err)
+		}
+		t.Cleanup(func() { _ = conn.Close() })
+		return conn, json.NewDecoder(conn)
+	}
+	subscriber, subDec := dial()
+	legacy, legacyDec := dial()
+
+	if err := json.NewEncoder(subscriber).Encode(AskResponse{Subscribe: []string{AskTopicLimits}}); err != nil {
+		t.Fatalf("subscribe failed: %v", err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for server.Subscriptions() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("timeout waiting for subscription")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	server.Publish(&AskRequest{Type: AskTopicLimits, Limits: []LimitCounters{{Pattern: "example.com", Requests: 3}}})
+
+	_ = subscriber.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var got AskRequest
+	if err := subDec.Decode(&got); err != nil {
+		t.Fatalf("subscriber did not receive the message: %v", err)
+	}
+	if got.Type != AskTopicLimits || len(got.Limits) != 1 || got.Limits[0].Requests != 3 {
+		t.Errorf("received %+v", got)
+	}
+
+	// A monitor that never subscribed would read the message as a prompt.
+	_ = legacy.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+	var leaked AskRequest
+	if err := legacyDec.Decode(&leaked); err == nil {
+		t.Errorf("unsubscribed monitor received %+v", leaked)
+	}
+}
+
+func TestAskQueue_LimitApprovalNotCached(t *testing.T) {
+	dir := shortTempDir(t)
+	server, err := NewAskServer(dir)
+	if err != nil {
+		t.Fatalf("NewAskServer failed: %v", err)
+	}
+	defer func() { _ = server.Close() }()
+
+	conn, err := net.Dial("unix", AskSocketPath(dir))
+	if err != nil {
+		t.Fatalf("monitor dial failed: %v", err)
+	}
+	defer func() { _ = conn.Close() }()
+	go func() {
+		dec, enc := json.NewDecoder(conn), json.NewEncoder(conn)
+		for {
+			var req AskRequest
+			if err := dec.Decode(&req); err != nil {
+				return
+			}
+			_ = enc.Encode(AskResponse{ID: req.ID, Action: FilterActionAllow, Remember: true})
+		}
+	}()
+	deadline := time.Now().Add(2 * time.Second)
+	for !server.HasMonitor() {
+		if time.Now().After(deadline) {
+			t.Fatal("timeout waiting for monitor connection")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	engine, err := NewFilterEngine(&FilterConfig{DefaultAction: FilterActionAsk})
+	if err != nil {
+		t.Fatalf("NewFilterEngine failed: %v", err)
+	}
+	queue := NewAskQueue(server, engine, 2*time.Second)
+	action, err := queue.RequestApproval(&AskRequest{ID: "1", Host: "example.com", Limit: "rate limit"})
+	if err != nil || action != FilterActionAllow {
+		t.Fatalf("RequestApproval = %s, %v", action, err)
+	}
+	if cached := engine.getCachedDecision("example.com"); cached != "" {
+		t.Errorf("over-limit approval cached for the host as %q", cached)
+	}
+}
+
+func TestAskQueue_RememberFor(t *testing.T) {
+	dir := shortTempDir(t)
+	server, err := NewAskServer(dir)
+	if err != nil {
+		t.Fatalf("NewAskServer failed: %v", err)
+	}
+	defer func() { _ = server.Close() }()
+
+	conn, err := net.Dial("unix", AskSocketPath(dir))
+	if err != nil {
+		t.Fatalf("monitor dial failed: %v", err)
+	}
+	defer func() { _ = conn.Close() }()
+	go func() {
+		dec, enc := json.NewDecoder(conn), json.NewEncoder(conn)
+		for {
+			var req AskRequest
+			if err := dec.Decode(&req); err != nil {
+				return
+			}
+			_ = enc.Encode(AskResponse{ID: req.ID, Action: FilterActionAllow, RememberFor: 600})
+		}
+	}()
+	deadline := time.Now().Add(2 * time.Second)
+	for !server.HasMonitor() {
+		if time.Now().After(deadline) {
+			t.Fatal("timeout waiting for monitor connection")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	engine, err := NewFilterEngine(&FilterConfig{DefaultAction: FilterActionAsk})
+	if err != nil {
+		t.Fatalf("NewFilterEngine failed: %v", err)
+	}
+	now := time.Now()
+	engine.now = func() time.Time { return now }
+	queue := NewAskQueue(server, engine, 2*time.Second)
+	if action, err := queue.RequestApproval(&AskRequest{ID: "1", Host: "api.example.com"}); err != nil || action != FilterActionAllow {
+		t.Fatalf("RequestApproval = %s, %v", action, err)
+	}
+
+	if d := engine.MatchHost("api.example.com"); d.Action != FilterActionAllow {
+		t.Errorf("within ten minutes, MatchHost = %s, want allow", d.Action)
+	}
+	now = now.Add(601 * time.Second)
+	if d := engine.MatchHost("api.example.com"); d.Action != FilterActionAsk {
+		t.Errorf("after ten minutes, MatchHost = %s, want ask", d.Action)
+	}
+}
+
+func TestPermanentRule(t *testing.T) {
+	req := &AskRequest{
+		URL:  "https://API.Example.com:443/repos/acme/app/issues?state=open",
+		Host: "API.Example.com:443",
+		Path: "/repos/acme/app/issues",
+	}
+	tests := []struct {
+		scope PermanentScope
+		want  FilterRule
+	}{
+		{PermanentScopeHost, FilterRule{Pattern: "api.example.com", Scope: FilterScopeHost, Type: PatternTypeExact}},
+		{PermanentScopePathPrefix, FilterRule{Pattern: "https://api.example.com/repos/acme/app/**", Scope: FilterScopeURL, Type: PatternTypeGlob}},
+		{PermanentScopeURL, FilterRule{Pattern: "https://api.example.com/repos/acme/app/issues?state=open", Scope: FilterScopeURL, Type: PatternTypeExact}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.scope), func(t *testing.T) {
+			got, err := PermanentRule(req, FilterActionAllow, tt.scope)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.want.Action = FilterActionAllow
+			if got.Pattern != tt.want.Pattern || got.Scope != tt.want.Scope || got.Type != tt.want.Type || got.Action != tt.want.Action {
+				t.Errorf("PermanentRule = %+v, want %+v", got, tt.want)
+			}
+
+			// The rule matches the request it was made for.
+			engine, err := NewFilterEngine(&FilterConfig{DefaultAction: FilterActionBlock, Rules: []FilterRule{got}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := httptest.NewRequest(http.MethodGet, req.URL, nil)
+			if d := engine.Match(r, nil); d.Action != FilterActionAllow {
+				t.Errorf("rule %q does not match %s", got.Pattern, req.URL)
+			}
+		})
+	}
+
+	if got, err := PermanentRule(&AskRequest{URL: "https://h.example/a*b/c", Host: "h.example", Path: "/a*b/c"}, FilterActionBlock, PermanentScopePathPrefix); err != nil || got.Pattern != `https://h.example/a\*b/**` {
+		t.Errorf("glob metacharacters in the path: %q, %v", got.Pattern, err)
+	}
+	if _, err := PermanentRule(&AskRequest{URL: req.URL, Host: req.Host, Path: req.Path, HostRulesOnly: true}, FilterActionAllow, PermanentScopeURL); err == nil {
+		t.Error("URL rule built for a proxy that cannot enforce one")
+	}
+	if _, err := PermanentRule(req, FilterActionAsk, PermanentScopeHost); err == nil {
+		t.Error("permanent ask accepted")
+	}
+
+	// A SOCKS connection's host rule names its port, so it can decide the
+	// connection again with MITM on.
+	tcp := &AskRequest{Method: "TCP", URL: "tcp://db.example.com:5432", Host: "db.example.com:5432"}
+	got, err := PermanentRule(tcp, FilterActionAllow, PermanentScopeHost)
+	if err != nil || got.Pattern != "db.example.com" || !slices.Equal(got.Ports, []int{5432}) {
+		t.Errorf("TCP host rule = %+v, %v", got, err)
+	}
+	engine, err := NewFilterEngine(&FilterConfig{DefaultAction: FilterActionBlock, Rules: []FilterRule{got}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := engine.MatchSOCKS(tcp.Host); d.Action != FilterActionAllow {
+		t.Errorf("remembered TCP rule does not decide %s: %s", tcp.Host, d.Action)
+	}
+}
+
+func TestAskQueue_PermanentRuleApplied(t *testing.T) {
+	dir := shortTempDir(t)
+	server, err := NewAskServer(dir)
+	if err != nil {
+		t.Fatalf("NewAskServer failed: %v", err)
+	}
+	defer func() { _ = server.Close() }()
+
+	conn, err := net.Dial("unix", AskSocketPath(dir))
+	if err != nil {
+		t.Fatalf("monitor dial failed: %v", err)
+	}
+	defer func() { _ = conn.Close() }()
+	go func() {
+		dec, enc := json.NewDecoder(conn), json.NewEncoder(conn)
+		for {
+			var req AskRequest
+			if err := dec.Decode(&req); err != nil {
+				return
+			}
+			if req.HostRulesOnly {
+				// A scope PermanentRule refuses for the request.
+				_ = enc.Encode(AskResponse{ID: req.ID, Action: FilterActionAllow, Permanent: true, Scope: PermanentScopeURL})
+				continue
+			}
+			_ = enc.Encode(AskResponse{ID: req.ID, Action: FilterActionBlock, Permanent: true, Scope: PermanentScopeHost})
+		}
+	}()
+	deadline := time.Now().Add(2 * time.Second)
+	for !server.HasMonitor() {
+		if time.Now().After(deadline) {
+			t.Fatal("timeout waiting for monitor connection")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	engine, err := NewFilterEngine(&FilterConfig{DefaultAction: FilterActionAsk})
+	if err != nil {
+		t.Fatalf("NewFilterEngine failed: %v", err)
+	}
+	queue := NewAskQueue(server, engine, 2*time.Second)
+	action, err := queue.RequestApproval(&AskRequest{ID: "1", Host: "tracker.example.com", URL: "https://tracker.example.com/"})
+	if err != nil || action != FilterActionBlock {
+		t.Fatalf("RequestApproval = %s, %v", action, err)
+	}
+	if d := engine.MatchHost("tracker.example.com:443"); d.Action != FilterActionBlock {
+		t.Errorf("after a permanent block, MatchHost = %s, want block", d.Action)
+	}
+
+	// The answer to a request still stands when its scope does not fit it,
+	// but no rule is applied for the rest of the session.
+	action, err = queue.RequestApproval(&AskRequest{ID: "2", Host: "api.example.com", URL: "https://api.example.com/v1", HostRulesOnly: true})
+	if err != nil || action != FilterActionAllow {
+		t.Fatalf("RequestApproval = %s, %v", action, err)
+	}
+	if d := engine.MatchHost("api.example.com:443"); d.Action != FilterActionAsk {
+		t.Errorf("after a refused scope, MatchHost = %s, want ask", d.Action)
+	}
+}
@@ -0,0 +1,239 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"devsandbox/internal/dbus"
+)
+
+// ErrNotificationClosed indicates a desktop notification was closed without
+// Allow or Block being picked.
+var ErrNotificationClosed = errors.New("notification closed without a decision")
+
+// AskNotifier puts an ask prompt in front of the user some way other than a
+// monitor. The AskServer uses it for requests no monitor is connected to
+// answer; an error it returns other than a decision leaves the request
+// unanswered, as with no monitor at all.
+type AskNotifier interface {
+	Ask(ctx context.Context, req *AskRequest) (AskResponse, error)
+}
+
+const (
+	notificationsName      = "org.freedesktop.Notifications"
+	notificationsPath      = dbus.ObjectPath("/org/freedesktop/Notifications")
+	notificationsInterface = "org.freedesktop.Notifications"
+
+	// notificationActionAllow and notificationActionBlock are the action keys
+	// of the notification's buttons.
+	notificationActionAllow = "allow"
+	notificationActionBlock = "block"
+)
+
+// DesktopNotifier asks through desktop notifications carrying Allow and Block
+// buttons, over the org.freedesktop.Notifications D-Bus API on the host's
+// session bus. It connects on first use and again after the bus goes away.
+type DesktopNotifier struct {
+	address string
+
+	mu      sync.Mutex
+	conn    *dbus.Conn
+	waiting map[uint32]notificationWaiter // by notification ID
+}
+
+// notificationWaiter is an Ask call waiting on a notification.
+type notificationWaiter struct {
+	conn   *dbus.Conn
+	server string      // unique name of the server that showed it; signals from anyone else are ignored
+	ch     chan string // the action key picked; "" when the notification closed
+}
+
+// NewDesktopNotifier returns a notifier for the session bus at address.
+func NewDesktopNotifier(address string) *DesktopNotifier {
+	return &DesktopNotifier{address: address, waiting: make(map[uint32]notificationWaiter)}
+}
+
+// Ask shows req as a notification and waits for a button, the notification
+// closing, or ctx to end - in which case the notification is withdrawn.
+// Without a session bus, or a notification server that can show buttons, it
+// returns ErrNoMonitor.
+func (n *DesktopNotifier) Ask(ctx context.Context, req *AskRequest) (AskResponse, error) {
+	n.mu.Lock()
+	conn, err := n.connect(ctx)
+	if err != nil {
+		n.mu.Unlock()
+		return AskResponse{}, fmt.Errorf("%w (desktop notifications: %v)", ErrNoMonitor, err)
+	}
+	// Held across Notify so the dispatcher cannot see the notification's
+	// signals before it is registered to receive them.
+	reply, err := conn.Call(ctx, notificationsName, notificationsPath, notificationsInterface, "Notify", "susssasa{sv}i",
+		"devsandbox",
+		uint32(0),
+		"network-wireless",
+		notificationSummary(req),
+		notificationBody(req),
+		[]string{notificationActionAllow, "Allow", notificationActionBlock, "Block"},
+		map[string]any{
+			"urgency":  dbus.Variant{Sig: "y", Value: byte(2)}, // critical: stays until answered
+			"category": dbus.Variant{Sig: "s", Value: "network"},
+		},
+		int32(0), // never expires; withdrawn on timeout instead
+	)
+	if err == nil && len(reply.Body) != 1 {
+		err = errors.New("Notify returned no ID")
+	}
+	if err != nil {
+		n.mu.Unlock()
+		return AskResponse{}, fmt.Errorf("%w (desktop notifications: %v)", ErrNoMonitor, err)
+	}
+	id, _ := reply.Body[0].(uint32)
+	ch := make(chan string, 1)
+	n.waiting[id] = notificationWaiter{conn: conn, server: reply.Sender, ch: ch}
+	n.mu.Unlock()
+
+	defer func() {
+		n.mu.Lock()
+		delete(n.waiting, id)
+		n.mu.Unlock()
+	}()
+
+	select {
+	case action, ok := <-ch:
+		switch {
+		case !ok:
+			return AskResponse{}, fmt.Errorf("%w (desktop notifications: the session bus went away)", ErrNoMonitor)
+		case action == notificationActionAllow:
+			return AskResponse{ID: req.ID, Action: FilterActionAllow}, nil
+		case action == notificationActionBlock:
+			return AskResponse{ID: req.ID, Action: FilterActionBlock}, nil
+		}
+		return AskResponse{}, ErrNotificationClosed
+	case <-ctx.Done():
+		closeCtx, cancel := context.WithTimeout(context.Background(), time.Second)
+		_, _ = conn.Call(closeCtx, notificationsName, notificationsPath, notificationsInterface, "CloseNotification", "u", id)
+		cancel()
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return AskResponse{}, ErrTimeout
+		}
+		return AskResponse{}, ctx.Err()
+	}
+}
+
+// connect returns the bus connection, dialing it if there is none. A server
+// that cannot show buttons is refused: a notification the user cannot answer
+// would only delay the same rejection. Called with n.mu held.
+func (n *DesktopNotifier) connect(ctx context.Context) (*dbus.Conn, error) {
+	if n.conn != nil {
+		return n.conn, nil
+	}
+	if n.address == "" {
+		return nil, errors.New("no session bus")
+	}
+	conn, err := dbus.Dial(ctx, n.address)
+	if err != nil {
+		return nil, err
+	}
+
+	caps, err := conn.Call(ctx, notificationsName, notificationsPath, notificationsInterface, "GetCapabilities", "")
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	var list []any
+	if len(caps.Body) == 1 {
+		list, _ = caps.Body[0].([]any)
+	}
+	if !slices.Contains(list, any("actions")) {
+		_ = conn.Close()
+		return nil, errors.New("the notification server does not support actions")
+	}
+	for _, member := range []string{"ActionInvoked", "NotificationClosed"} {
+		rule := fmt.Sprintf("type='signal',sender='%s',interface='%s',member='%s'", notificationsName, notificationsInterface, member)
+		if err := conn.AddMatch(ctx, rule); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+	}
+
+	n.conn = conn
+	go n.dispatch(conn)
+	return conn, nil
+}
+
+// dispatch routes the notification server's signals to the Ask calls waiting
+// on them, until the connection closes.
+func (n *DesktopNotifier) dispatch(conn *dbus.Conn) {
+	for m := range conn.Messages() {
+		if m.Type != dbus.TypeSignal || m.Interface != notificationsInterface || len(m.Body) != 2 {
+			continue
+		}
+		id, _ := m.Body[0].(uint32)
+		var action string
+		switch m.Member {
+		case "ActionInvoked":
+			action, _ = m.Body[1].(string)
+		case "NotificationClosed":
+		default:
+			continue
+		}
+
+		n.mu.Lock()
+		w, ok := n.waiting[id]
+		if ok && w.conn == conn && w.server == m.Sender {
+			delete(n.waiting, id)
+			w.ch <- action
+		}
+		n.mu.Unlock()
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.conn == conn {
+		n.conn = nil
+	}
+	for id, w := range n.waiting {
+		if w.conn == conn {
+			close(w.ch)
+			delete(n.waiting, id)
+		}
+	}
+}
+
+// Close drops the bus connection.
+func (n *DesktopNotifier) Close() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.conn == nil {
+		return nil
+	}
+	return n.conn.Close()
+}
+
+// notificationSummary is the notification's title.
+func notificationSummary(req *AskRequest) string {
+	if req.Limit != "" {
+		return "devsandbox: request over limit"
+	}
+	return "devsandbox: allow request?"
+}
+
+// notificationBody describes req. It names the host and path but not the
+// query, which notification history keeps long after the prompt; and it is
+// escaped, as servers may read the body as markup.
+func notificationBody(req *AskRequest) string {
+	lines := []string{req.Method + " " + req.Host + req.Path}
+	if req.Session != "" {
+		lines = append(lines, "Session: "+req.Session)
+	}
+	if req.Limit != "" {
+		lines = append(lines, "Over limit: "+req.Limit)
+	}
+	return notificationEscaper.Replace(strings.Join(lines, "\n"))
+}
+
+var notificationEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
@@ -0,0 +1,198 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"devsandbox/internal/dbus"
+	"devsandbox/internal/dbus/dbustest"
+)
+
+// fakeNotifications is a stand-in org.freedesktop.Notifications server.
+type fakeNotifications struct {
+	conn   *dbus.Conn
+	shown  chan shownNotification
+	closed chan uint32 // CloseNotification calls
+}
+
+// shownNotification is a Notify call and the ID it was given.
+type shownNotification struct {
+	id   uint32
+	call *dbus.Message
+}
+
+func startFakeNotifications(t *testing.T, addr string, caps ...string) *fakeNotifications {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := dbus.Dial(ctx, addr)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	if err := conn.RequestName(ctx, notificationsName); err != nil {
+		t.Fatalf("RequestName: %v", err)
+	}
+
+	f := &fakeNotifications{conn: conn, shown: make(chan shownNotification, 10), closed: make(chan uint32, 10)}
+	go func() {
+		var nextID uint32
+		for m := range conn.Messages() {
+			if m.Type != dbus.TypeMethodCall {
+				continue
+			}
+			switch m.Member {
+			case "GetCapabilities":
+				_ = conn.Reply(m, "as", caps)
+			case "Notify":
+				nextID++
+				_ = conn.Reply(m, "u", nextID)
+				f.shown <- shownNotification{id: nextID, call: m}
+			case "CloseNotification":
+				_ = conn.Reply(m, "")
+				f.closed <- m.Body[0].(uint32)
+			}
+		}
+	}()
+	return f
+}
+
+// next waits for the next notification, returning its ID.
+func (f *fakeNotifications) next(t *testing.T) (uint32, *dbus.Message) {
+	t.Helper()
+	select {
+	case n := <-f.shown:
+		return n.id, n.call
+	case <-time.After(5 * time.Second):
+		t.Fatal("no notification shown")
+	}
+	return 0, nil
+}
+
+func TestDesktopNotifier(t *testing.T) {
+	addr := dbustest.StartBus(t)
+	server := startFakeNotifications(t, addr, "body", "actions")
+
+	notifier := NewDesktopNotifier(addr)
+	defer func() { _ = notifier.Close() }()
+	req := &AskRequest{ID: "1", Method: "GET", Host: "api.example.com", Path: "/v1/<users>", URL: "https://api.example.com/v1/<users>?token=secret", Session: "web"}
+
+	ask := func(ctx context.Context) chan error {
+		done := make(chan error, 1)
+		go func() {
+			resp, err := notifier.Ask(ctx, req)
+			if err == nil && resp.Action != FilterActionAllow {
+				err = errors.New("answer was " + string(resp.Action))
+			}
+			done <- err
+		}()
+		return done
+	}
+
+	t.Run("allow", func(t *testing.T) {
+		done := ask(context.Background())
+		id, m := server.next(t)
+		if body := m.Body[4].(string); body != "GET api.example.com/v1/&lt;users&gt;\nSession: web" {
+			t.Errorf("notification body = %q", body)
+		}
+		if actions := m.Body[5].([]any); !slices.Contains(actions, any("allow")) || !slices.Contains(actions, any("block")) {
+			t.Errorf("actions = %v", actions)
+		}
+
+		// A signal from anyone but the notification server is not an answer.
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		spoofer, err := dbus.Dial(ctx, addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = spoofer.Close() }()
+		_ = spoofer.Emit(notificationsPath, notificationsInterface, "ActionInvoked", "us", id, "block")
+		time.Sleep(100 * time.Millisecond)
+
+		_ = server.conn.Emit(notificationsPath, notificationsInterface, "ActionInvoked", "us", id, "allow")
+		if err := <-done; err != nil {
+			t.Errorf("Ask: %v", err)
+		}
+	})
+
+	t.Run("closed", func(t *testing.T) {
+		done := ask(context.Background())
+		id, _ := server.next(t)
+		_ = server.conn.Emit(notificationsPath, notificationsInterface, "NotificationClosed", "uu", id, uint32(2))
+		if err := <-done; !errors.Is(err, ErrNotificationClosed) {
+			t.Errorf("Ask = %v, want ErrNotificationClosed", err)
+		}
+	})
+
+	t.Run("timeout withdraws", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		defer cancel()
+		done := ask(ctx)
+		id, _ := server.next(t)
+		if err := <-done; !errors.Is(err, ErrTimeout) {
+			t.Errorf("Ask = %v, want ErrTimeout", err)
+		}
+		select {
+		case closed := <-server.closed:
+			if closed != id {
+				t.Errorf("closed notification %d, want %d", closed, id)
+			}
+		case <-time.After(5 * time.Second):
+			t.Error("notification not withdrawn")
+		}
+	})
+}
+
+func TestDesktopNotifier_Unavailable(t *testing.T) {
+	req := &AskRequest{ID: "1", Host: "example.com"}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := NewDesktopNotifier("").Ask(ctx, req); !errors.Is(err, ErrNoMonitor) {
+		t.Errorf("no bus: %v, want ErrNoMonitor", err)
+	}
+
+	addr := dbustest.StartBus(t)
+	if _, err := NewDesktopNotifier(addr).Ask(ctx, req); !errors.Is(err, ErrNoMonitor) {
+		t.Errorf("no notification server: %v, want ErrNoMonitor", err)
+	}
+
+	startFakeNotifications(t, addr, "body")
+	if _, err := NewDesktopNotifier(addr).Ask(ctx, req); !errors.Is(err, ErrNoMonitor) {
+		t.Errorf("server without actions: %v, want ErrNoMonitor", err)
+	}
+}
+
+// stubNotifier answers every request with its action.
+type stubNotifier struct {
+	action FilterAction
+	asked  []string
+}
+
+func (n *stubNotifier) Ask(_ context.Context, req *AskRequest) (AskResponse, error) {
+	n.asked = append(n.asked, req.ID)
+	return AskResponse{ID: req.ID, Action: n.action}, nil
+}
+
+func TestAskServer_FallsBackToNotifier(t *testing.T) {
+	server, err := NewAskServer(shortTempDir(t))
+	if err != nil {
+		t.Fatalf("NewAskServer failed: %v", err)
+	}
+	defer func() { _ = server.Close() }()
+	notifier := &stubNotifier{action: FilterActionAllow}
+	server.SetNotifier(notifier)
+
+	queue := NewAskQueue(server, nil, time.Second)
+	action, err := queue.RequestApproval(&AskRequest{ID: "1", Host: "example.com"})
+	if err != nil || action != FilterActionAllow {
+		t.Fatalf("RequestApproval = %s, %v", action, err)
+	}
+	if len(notifier.asked) != 1 {
+		t.Errorf("notifier asked %v, want once", notifier.asked)
+	}
+}
@@ -111,3 +111,51 @@ func (s *Server) emitMITMBypass(host string) {
 		"reason": "global",
 	})
 }
+
+// emitLimitExceeded sends a proxy.limit.exceeded event when a request is found
+// over a [proxy.limits] rule. allowed records whether the user approved it
+// anyway through ask mode. Always emitted, like a filter block: a limit being
+// hit is exactly what the audit trail is for.
+func (s *Server) emitLimitExceeded(req *http.Request, exceeded *LimitExceeded, allowed bool) {
+	if s == nil || s.dispatcher == nil {
+		return
+	}
+	outcome := string(FilterActionLimited)
+	if allowed {
+		outcome = string(FilterActionAllow)
+	}
+	_ = s.dispatcher.Event(logging.LevelWarn, "proxy.limit.exceeded", map[string]any{
+		"host":      NormalizeHost(RequestHost(req)),
+		"method":    req.Method,
+		"path":      pathOnly(req.URL),
+		"limit":     exceeded.Limit,
+		"rule_id":   exceeded.Rule.Pattern,
+		"on_exceed": string(exceeded.Rule.GetOnExceed()),
+		"outcome":   outcome,
+	})
+}
+
+// emitDNSQuery sends a proxy.dns.query event for every query the resolver
+// decides - answered at info, refused at warn - so a tool probing for names
+// it may not reach is visible even though no connection follows.
+func (s *Server) emitDNSQuery(q DNSQuery) {
+	if s == nil || s.dispatcher == nil {
+		return
+	}
+	level := logging.LevelInfo
+	action := FilterActionAllow
+	if !q.Answered {
+		level = logging.LevelWarn
+		action = FilterActionBlock
+	}
+	fields := map[string]any{
+		"host":                q.Host,
+		"qtype":               q.Type,
+		"rule_action":         string(action),
+		"default_action_used": q.Decision.IsDefault,
+	}
+	if q.Decision.Rule != nil {
+		fields["rule_id"] = q.Decision.Rule.Pattern
+	}
+	_ = s.dispatcher.Event(level, "proxy.dns.query", fields)
+}
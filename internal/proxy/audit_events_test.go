@@ -261,6 +261,97 @@ func TestEmitCredentialInjected_NilDispatcherIsNoop(t *testing.T) {
 	s.emitCredentialInjected("h", "i", "H")
 }
 
+func TestEmitLimitExceeded_PayloadShape(t *testing.T) {
+	s, mw := newServerWithAuditWriter(t, false)
+	req := reqFor(t, "POST", "https://API.example.com:443/v1/chat?key=secret")
+	exceeded := &LimitExceeded{
+		Rule:  LimitRule{Pattern: "*.example.com", RequestsPerMinute: 1, OnExceed: FilterActionAsk},
+		Limit: LimitRequestsPerMinute,
+	}
+
+	s.emitLimitExceeded(req, exceeded, true)
+
+	got := mw.snapshot()
+	if len(got) != 1 {
+		t.Fatalf("got %d entries, want 1", len(got))
+	}
+	e := got[0]
+	if e.Level != logging.LevelWarn {
+		t.Errorf("level = %v, want warn", e.Level)
+	}
+	want := map[string]any{
+		"event":     "proxy.limit.exceeded",
+		"host":      "api.example.com",
+		"method":    "POST",
+		"path":      "/v1/chat",
+		"limit":     LimitRequestsPerMinute,
+		"rule_id":   "*.example.com",
+		"on_exceed": "ask",
+		"outcome":   "allow",
+	}
+	for k, v := range want {
+		if e.Fields[k] != v {
+			t.Errorf("%s = %v, want %v", k, e.Fields[k], v)
+		}
+	}
+}
+
+func TestEmitLimitExceeded_NilDispatcherIsNoop(t *testing.T) {
+	s := &Server{config: &Config{}}
+	s.emitLimitExceeded(reqFor(t, "GET", "https://h/"), &LimitExceeded{}, false)
+}
+
+func TestEmitDNSQuery_PayloadShape(t *testing.T) {
+	s, mw := newServerWithAuditWriter(t, false)
+	s.emitDNSQuery(DNSQuery{
+		Host:     "evil.example.com",
+		Type:     "A",
+		Decision: FilterDecision{Action: FilterActionBlock, Rule: &FilterRule{Pattern: "*.example.com"}},
+	})
+	s.emitDNSQuery(DNSQuery{
+		Host:     "api.github.com",
+		Type:     "AAAA",
+		Decision: FilterDecision{Action: FilterActionAllow, IsDefault: true},
+		Answered: true,
+	})
+
+	// Allowed queries are not gated by log_filter_decisions: every query is audited.
+	got := mw.snapshot()
+	if len(got) != 2 {
+		t.Fatalf("got %d entries, want 2", len(got))
+	}
+	if got[0].Level != logging.LevelWarn {
+		t.Errorf("refused level = %v, want warn", got[0].Level)
+	}
+	want := map[string]any{
+		"event":               "proxy.dns.query",
+		"host":                "evil.example.com",
+		"qtype":               "A",
+		"rule_action":         "block",
+		"default_action_used": false,
+		"rule_id":             "*.example.com",
+	}
+	for k, v := range want {
+		if got[0].Fields[k] != v {
+			t.Errorf("%s = %v, want %v", k, got[0].Fields[k], v)
+		}
+	}
+	if got[1].Level != logging.LevelInfo {
+		t.Errorf("answered level = %v, want info", got[1].Level)
+	}
+	if got[1].Fields["rule_action"] != "allow" || got[1].Fields["default_action_used"] != true {
+		t.Errorf("answered fields = %v", got[1].Fields)
+	}
+	if _, ok := got[1].Fields["rule_id"]; ok {
+		t.Errorf("rule_id set without a rule: %v", got[1].Fields["rule_id"])
+	}
+}
+
+func TestEmitDNSQuery_NilDispatcherIsNoop(t *testing.T) {
+	s := &Server{config: &Config{}}
+	s.emitDNSQuery(DNSQuery{Host: "h", Type: "A"})
+}
+
 func TestEmitMITMBypass_PayloadShape(t *testing.T) {
 	s, mw := newServerWithAuditWriter(t, false)
 	s.emitMITMBypass("api.example.com")
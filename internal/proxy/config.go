@@ -58,6 +58,22 @@ type Config struct {
 	// dispatchers. nil/empty → no entries are skipped.
 	LogSkip *LogSkipConfig
 
+	// GitPush restricts which refs a git push over smart HTTP may change.
+	// nil/empty → pushes are not inspected.
+	GitPush *GitPushConfig
+
+	// Limits caps requests per minute, concurrent requests and uploaded bytes
+	// per host pattern. nil → no limits.
+	Limits *LimitsConfig
+
+	// DNS runs a resolver for the sandbox that answers only for names the
+	// filter allows. nil → no resolver.
+	DNS *DNSConfig
+
+	// SOCKS runs a SOCKS5 listener for TCP egress that is not HTTP, decided
+	// by the filter per host:port. nil → no listener.
+	SOCKS *SOCKSConfig
+
 	// CredentialInjectors add authentication to requests for specific domains.
 	// Built by BuildCredentialInjectors() from [proxy.credentials] config.
 	// If nil/empty, no credential injection is performed.
@@ -73,6 +89,11 @@ type Config struct {
 	// ProjectDir is the project directory for resolving .env files.
 	ProjectDir string
 
+	// LocalConfigDir is the directory whose .devsandbox.toml the session reads,
+	// offered to the monitor for permanent ask decisions. It differs from
+	// ProjectDir in worktree mode; empty when the local config is skipped.
+	LocalConfigDir string
+
 	// MaxLogBodyBytes bounds how many bytes of a request or response body the
 	// request logger records. nil → config.DefaultMaxLogBodyBytes, 0 → no
 	// bodies. Read through GetMaxLogBodyBytes.
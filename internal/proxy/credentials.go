@@ -1,6 +1,7 @@
 package proxy
 
 import (
+	"encoding/base64"
 	"fmt"
 	"net/http"
 	"os"
@@ -22,6 +23,7 @@ type Preset struct {
 	Host          string
 	Header        string
 	ValueFormat   string
+	Username      string
 	DefaultSource *source.Source
 }
 
@@ -41,9 +43,20 @@ func init() {
 		ValueFormat:   "Bearer {token}",
 		DefaultSource: &source.Source{Env: "GITHUB_TOKEN"},
 		// GH_TOKEN fallback handled by applyGitHubFallback in
-		// BuildCredentialInjectors, gated on preset name == "github" and
+		// BuildCredentialInjectors, gated on the github presets and
 		// the user not setting an explicit [...source] sub-table.
 	})
+
+	// Git smart-HTTP to github.com authenticates with Basic auth, not the
+	// Bearer scheme the REST API takes. The username is ignored for personal
+	// access tokens and required by name for app installation tokens.
+	RegisterPreset("github-git", Preset{
+		Host:          "github.com",
+		Header:        "Authorization",
+		ValueFormat:   "Basic {basic}",
+		Username:      "x-access-token",
+		DefaultSource: &source.Source{Env: "GITHUB_TOKEN"},
+	})
 }
 
 // credentialConfig is a [proxy.credentials.<name>] section. Every field is
@@ -57,8 +70,12 @@ type credentialConfig struct {
 	// Header is the header to write on a match.
 	Header string `toml:"header"`
 	// ValueFormat renders the header value, with `{token}` standing for the
-	// resolved credential. Defaults to the token alone.
+	// resolved credential and `{basic}` for the base64 of
+	// "<username>:<token>". Defaults to the token alone.
 	ValueFormat string `toml:"value_format"`
+	// Username is the user half of `{basic}`. Required when value_format
+	// uses it.
+	Username string `toml:"username"`
 	// Overwrite replaces a header the request already carries.
 	Overwrite bool `toml:"overwrite"`
 	// Enabled turns the injector on. Injectors are off by default.
@@ -87,7 +104,8 @@ func lookupPreset(name string) (Preset, bool) {
 // GenericInjector is the universal credential injector. It matches a request
 // against a host pattern (exact or doublestar glob), and on match writes a
 // configured header whose value is rendered from a `value_format` template
-// with the literal placeholder `{token}` replaced by the resolved credential.
+// with the literal placeholder `{token}` replaced by the resolved credential
+// (and `{basic}` by the Basic-auth encoding of username and credential).
 //
 // Fields are unexported because the canonical construction path is
 // BuildCredentialInjectors; tests construct instances directly via the
@@ -98,7 +116,8 @@ type GenericInjector struct {
 	matcher     func(string) bool // compiled from host (exact == or doublestar.Match)
 	isExact     bool              // true when host has no glob metachars
 	header      string            // already canonicalized via http.CanonicalHeaderKey
-	valueFormat string            // contains literal `{token}` placeholder
+	valueFormat string            // contains literal `{token}` and/or `{basic}` placeholders
+	username    string            // user half of the `{basic}` credential
 	overwrite   bool
 	token       string
 	enabled     bool
@@ -120,10 +139,18 @@ func (g *GenericInjector) Inject(req *http.Request) bool {
 	if !g.overwrite && req.Header.Get(g.header) != "" {
 		return false
 	}
-	req.Header.Set(g.header, strings.ReplaceAll(g.valueFormat, "{token}", g.token))
+	req.Header.Set(g.header, g.renderValue())
 	return true
 }
 
+// renderValue substitutes the placeholders in the value format. Both are
+// replaced in one pass, so a token that happens to contain the text of the
+// other placeholder is not expanded a second time.
+func (g *GenericInjector) renderValue() string {
+	basic := base64.StdEncoding.EncodeToString([]byte(g.username + ":" + g.token))
+	return strings.NewReplacer("{token}", g.token, "{basic}", basic).Replace(g.valueFormat)
+}
+
 // Header returns the configured header name (e.g., "Authorization"). Used by
 // audit events.
 func (g *GenericInjector) Header() string { return g.header }
@@ -178,11 +205,11 @@ type CredentialInjector interface {
 }
 
 // applyGitHubFallback returns the GH_TOKEN environment value when the
-// resolved token is empty AND the preset is "github" AND the user did not
-// explicitly configure a [...source] sub-table. It is a small,
-// scope-limited fallback documented in the github preset.
+// resolved token is empty AND the preset is "github" or "github-git" AND the
+// user did not explicitly configure a [...source] sub-table. It is a small,
+// scope-limited fallback documented in the github presets.
 func applyGitHubFallback(presetName string, userSetSource bool, token string) string {
-	if token != "" || presetName != "github" || userSetSource {
+	if token != "" || (presetName != "github" && presetName != "github-git") || userSetSource {
 		return token
 	}
 	return os.Getenv("GH_TOKEN")
@@ -283,6 +310,7 @@ func buildOne(name string, raw map[string]any) (*GenericInjector, error) {
 	host := preset.Host
 	header := preset.Header
 	valueFormat := preset.ValueFormat
+	username := preset.Username
 	overwrite := cfg.Overwrite
 
 	if cfg.Host != "" {
@@ -294,6 +322,9 @@ func buildOne(name string, raw map[string]any) (*GenericInjector, error) {
 	if cfg.ValueFormat != "" {
 		valueFormat = cfg.ValueFormat
 	}
+	if cfg.Username != "" {
+		username = cfg.Username
+	}
 	if valueFormat == "" {
 		valueFormat = "{token}"
 	}
@@ -310,6 +341,9 @@ func buildOne(name string, raw map[string]any) (*GenericInjector, error) {
 	if header == "" {
 		return nil, fmt.Errorf("credential injector %q: header is required when enabled = true", name)
 	}
+	if strings.Contains(valueFormat, "{basic}") && username == "" {
+		return nil, fmt.Errorf("credential injector %q: username is required when value_format uses {basic}", name)
+	}
 
 	// Step 5: resolve [...source]. User source wins; otherwise preset
 	// default; otherwise nil. An empty [...source] sub-table decodes to a
@@ -349,6 +383,7 @@ func buildOne(name string, raw map[string]any) (*GenericInjector, error) {
 		host:        host,
 		header:      http.CanonicalHeaderKey(header),
 		valueFormat: valueFormat,
+		username:    username,
 		overwrite:   overwrite,
 		token:       token,
 		enabled:     true,
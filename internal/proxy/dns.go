@@ -0,0 +1,320 @@
+package proxy
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"sync"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// DNSConfig configures the proxy's DNS resolver: a host-side resolver the
+// sandbox's /etc/resolv.conf points at, which answers only for names the
+// filter would let a request reach.
+type DNSConfig struct {
+	// Enabled starts the resolver. Default: false.
+	Enabled *bool `toml:"enabled"`
+
+	// AnswerHostlessRules answers every name while an allow rule names no
+	// host - a path rule, say - instead of only the names rules name.
+	// Default: false. See FilterEngine.MatchDomain.
+	AnswerHostlessRules *bool `toml:"answer_hostless_rules"`
+}
+
+// IsEnabled returns true if the DNS resolver should run.
+func (c *DNSConfig) IsEnabled() bool {
+	return c != nil && c.Enabled != nil && *c.Enabled
+}
+
+// AnswersHostlessRules returns true if a rule naming no host answers every
+// name.
+func (c *DNSConfig) AnswersHostlessRules() bool {
+	return c != nil && c.AnswerHostlessRules != nil && *c.AnswerHostlessRules
+}
+
+const (
+	// dnsLookupTimeout bounds one upstream lookup. A resolver that has not
+	// answered by then is reported as SERVFAIL rather than held open.
+	dnsLookupTimeout = 5 * time.Second
+	// dnsAnswerTTL is the TTL on every answer. Short, so a rule change or an
+	// ask-mode decision is not outlived by a cached answer for long.
+	dnsAnswerTTL = 30
+	// dnsMaxAnswers caps the records in one answer, which keeps every
+	// response under the 512 bytes a UDP reply may take without EDNS - so
+	// nothing is ever truncated and no client has to retry over TCP.
+	dnsMaxAnswers = 8
+	// dnsMaxInFlight bounds the queries resolved at once. The resolver runs
+	// on the host, outside the sandbox's resource limits.
+	dnsMaxInFlight = 64
+	// dnsTCPIdleTimeout closes a TCP connection that sends nothing further.
+	dnsTCPIdleTimeout = 10 * time.Second
+)
+
+// DNSQuery describes one query the resolver decided on.
+type DNSQuery struct {
+	// Host is the canonical name queried.
+	Host string
+	// Type is the record type, e.g. "A".
+	Type string
+	// Decision is the filter's decision for Host.
+	Decision FilterDecision
+	// Answered is false when the query was refused.
+	Answered bool
+	// Duration is how long the query took to answer.
+	Duration time.Duration
+}
+
+// DNSResolver answers DNS queries from the sandbox. Each name is put to decide
+// first: a name it refuses gets NXDOMAIN without any upstream lookup, so a
+// tool that resolves before connecting fails at once with "could not
+// resolve" instead of timing out against the egress lockdown. A name it
+// allows is resolved by the host's own resolver. Only A and AAAA are
+// answered; other types get an empty answer.
+type DNSResolver struct {
+	decide  func(host string) FilterDecision
+	onQuery func(DNSQuery)
+	lookup  func(ctx context.Context, network, host string) ([]netip.Addr, error)
+
+	mu  sync.Mutex
+	udp net.PacketConn
+	tcp net.Listener
+	sem chan struct{}
+	wg  sync.WaitGroup
+}
+
+// NewDNSResolver creates a resolver. decide chooses which names are answered;
+// onQuery, if set, is called once for every query decided.
+func NewDNSResolver(decide func(host string) FilterDecision, onQuery func(DNSQuery)) *DNSResolver {
+	return &DNSResolver{
+		decide:  decide,
+		onQuery: onQuery,
+		lookup:  net.DefaultResolver.LookupNetIP,
+		sem:     make(chan struct{}, dnsMaxInFlight),
+	}
+}
+
+// Start listens for UDP and TCP queries on the same port of addr. A port of
+// 0 picks a free one; Port reports it.
+func (r *DNSResolver) Start(addr string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	udp, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return fmt.Errorf("dns: listen udp %s: %w", addr, err)
+	}
+	// TCP on the port UDP was given, so one port serves both.
+	tcpAddr := udp.LocalAddr().(*net.UDPAddr)
+	tcp, err := net.Listen("tcp", tcpAddr.String())
+	if err != nil {
+		_ = udp.Close()
+		return fmt.Errorf("dns: listen tcp %s: %w", tcpAddr, err)
+	}
+	r.udp, r.tcp = udp, tcp
+
+	r.wg.Go(func() { r.serveUDP(udp) })
+	r.wg.Go(func() { r.serveTCP(tcp) })
+	return nil
+}
+
+// Port returns the port the resolver listens on, or 0 before Start.
+func (r *DNSResolver) Port() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.udp == nil {
+		return 0
+	}
+	return r.udp.LocalAddr().(*net.UDPAddr).Port
+}
+
+// Close stops the resolver and waits for in-flight queries.
+func (r *DNSResolver) Close() error {
+	r.mu.Lock()
+	udp, tcp := r.udp, r.tcp
+	r.mu.Unlock()
+	if udp != nil {
+		_ = udp.Close()
+	}
+	if tcp != nil {
+		_ = tcp.Close()
+	}
+	r.wg.Wait()
+	return nil
+}
+
+func (r *DNSResolver) serveUDP(conn net.PacketConn) {
+	buf := make([]byte, 512)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue
+		}
+		query := append([]byte(nil), buf[:n]...)
+		r.sem <- struct{}{}
+		r.wg.Go(func() {
+			defer func() { <-r.sem }()
+			if resp := r.handle(query); resp != nil {
+				_, _ = conn.WriteTo(resp, addr)
+			}
+		})
+	}
+}
+
+func (r *DNSResolver) serveTCP(l net.Listener) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue
+		}
+		r.wg.Go(func() { r.serveTCPConn(conn) })
+	}
+}
+
+// serveTCPConn answers length-prefixed queries on one connection in turn.
+func (r *DNSResolver) serveTCPConn(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+	for {
+		_ = conn.SetReadDeadline(time.Now().Add(dnsTCPIdleTimeout))
+		var length uint16
+		if err := binary.Read(conn, binary.BigEndian, &length); err != nil {
+			return
+		}
+		query := make([]byte, length)
+		if _, err := io.ReadFull(conn, query); err != nil {
+			return
+		}
+		r.sem <- struct{}{}
+		resp := r.handle(query)
+		<-r.sem
+		if resp == nil {
+			return
+		}
+		out := binary.BigEndian.AppendUint16(make([]byte, 0, 2+len(resp)), uint16(len(resp)))
+		if _, err := conn.Write(append(out, resp...)); err != nil {
+			return
+		}
+	}
+}
+
+// handle answers one query message, or returns nil for one too malformed to
+// answer at all.
+func (r *DNSResolver) handle(query []byte) []byte {
+	start := time.Now()
+
+	var p dnsmessage.Parser
+	hdr, err := p.Start(query)
+	if err != nil {
+		return nil
+	}
+	resp := dnsmessage.Header{
+		ID:                 hdr.ID,
+		Response:           true,
+		OpCode:             hdr.OpCode,
+		RecursionDesired:   hdr.RecursionDesired,
+		RecursionAvailable: true,
+	}
+	if hdr.Response {
+		return nil
+	}
+	if hdr.OpCode != 0 {
+		resp.RCode = dnsmessage.RCodeNotImplemented
+		return packDNS(resp, nil, nil)
+	}
+	q, err := p.Question()
+	if err != nil {
+		resp.RCode = dnsmessage.RCodeFormatError
+		return packDNS(resp, nil, nil)
+	}
+
+	host := canonicalizeHost(q.Name.String())
+	decision := r.decide(host)
+	record := DNSQuery{Host: host, Type: dnsTypeName(q.Type), Decision: decision}
+	defer func() {
+		record.Duration = time.Since(start)
+		if r.onQuery != nil {
+			r.onQuery(record)
+		}
+	}()
+
+	if decision.Action == FilterActionBlock {
+		resp.RCode = dnsmessage.RCodeNameError
+		return packDNS(resp, &q, nil)
+	}
+	record.Answered = true
+
+	var network string
+	switch q.Type {
+	case dnsmessage.TypeA:
+		network = "ip4"
+	case dnsmessage.TypeAAAA:
+		network = "ip6"
+	default:
+		return packDNS(resp, &q, nil)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), dnsLookupTimeout)
+	defer cancel()
+	addrs, err := r.lookup(ctx, network, host)
+	if err != nil {
+		if dnsErr, ok := errors.AsType[*net.DNSError](err); ok && dnsErr.IsNotFound {
+			resp.RCode = dnsmessage.RCodeNameError
+		} else {
+			resp.RCode = dnsmessage.RCodeServerFailure
+		}
+		return packDNS(resp, &q, nil)
+	}
+	if len(addrs) > dnsMaxAnswers {
+		addrs = addrs[:dnsMaxAnswers]
+	}
+	return packDNS(resp, &q, addrs)
+}
+
+// packDNS builds a response echoing q, with one record per address.
+func packDNS(hdr dnsmessage.Header, q *dnsmessage.Question, addrs []netip.Addr) []byte {
+	b := dnsmessage.NewBuilder(nil, hdr)
+	b.EnableCompression()
+	if q == nil {
+		out, _ := b.Finish()
+		return out
+	}
+	_ = b.StartQuestions()
+	_ = b.Question(*q)
+	_ = b.StartAnswers()
+	rh := dnsmessage.ResourceHeader{Name: q.Name, Class: dnsmessage.ClassINET, TTL: dnsAnswerTTL}
+	for _, a := range addrs {
+		a = a.Unmap()
+		switch {
+		case q.Type == dnsmessage.TypeA && a.Is4():
+			_ = b.AResource(rh, dnsmessage.AResource{A: a.As4()})
+		case q.Type == dnsmessage.TypeAAAA && a.Is6():
+			_ = b.AAAAResource(rh, dnsmessage.AAAAResource{AAAA: a.As16()})
+		}
+	}
+	out, err := b.Finish()
+	if err != nil {
+		return nil
+	}
+	return out
+}
+
+// dnsTypeName names a record type the way it is written in zone files.
+func dnsTypeName(t dnsmessage.Type) string {
+	s := t.String()
+	const prefix = "Type"
+	if len(s) > len(prefix) && s[:len(prefix)] == prefix {
+		return s[len(prefix):]
+	}
+	return s
+}
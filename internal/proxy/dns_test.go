@@ -0,0 +1,360 @@
+package proxy
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/netip"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// startTestResolver starts a resolver on loopback that blocks every name
+// except those in allowed, resolving through lookup. The returned function
+// reports the queries it has decided so far.
+func startTestResolver(t *testing.T, allowed []string, lookup func(ctx context.Context, network, host string) ([]netip.Addr, error)) (*DNSResolver, func() []DNSQuery) {
+	t.Helper()
+	var mu sync.Mutex
+	var queries []DNSQuery
+	decide := func(host string) FilterDecision {
+		for _, a := range allowed {
+			if host == a {
+				return FilterDecision{Action: FilterActionAllow}
+			}
+		}
+		return FilterDecision{Action: FilterActionBlock, IsDefault: true, Reason: "default action"}
+	}
+	r := NewDNSResolver(decide, func(q DNSQuery) {
+		mu.Lock()
+		defer mu.Unlock()
+		queries = append(queries, q)
+	})
+	r.lookup = lookup
+	if err := r.Start("127.0.0.1:0"); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	t.Cleanup(func() { _ = r.Close() })
+	return r, func() []DNSQuery {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]DNSQuery(nil), queries...)
+	}
+}
+
+func buildDNSQuery(t *testing.T, name string, qtype dnsmessage.Type) []byte {
+	t.Helper()
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: 0x1234, RecursionDesired: true})
+	_ = b.StartQuestions()
+	if err := b.Question(dnsmessage.Question{
+		Name:  dnsmessage.MustNewName(name),
+		Type:  qtype,
+		Class: dnsmessage.ClassINET,
+	}); err != nil {
+		t.Fatalf("build question: %v", err)
+	}
+	msg, err := b.Finish()
+	if err != nil {
+		t.Fatalf("build query: %v", err)
+	}
+	return msg
+}
+
+// queryUDP sends one query to the resolver over UDP and parses the reply.
+func queryUDP(t *testing.T, port int, name string, qtype dnsmessage.Type) dnsmessage.Message {
+	t.Helper()
+	conn, err := net.Dial("udp", net.JoinHostPort("127.0.0.1", strconv.Itoa(port)))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer func() { _ = conn.Close() }()
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := conn.Write(buildDNSQuery(t, name, qtype)); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	buf := make([]byte, 512)
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	var msg dnsmessage.Message
+	if err := msg.Unpack(buf[:n]); err != nil {
+		t.Fatalf("unpack: %v", err)
+	}
+	return msg
+}
+
+func stubLookup(addrs map[string][]netip.Addr) func(ctx context.Context, network, host string) ([]netip.Addr, error) {
+	return func(_ context.Context, network, host string) ([]netip.Addr, error) {
+		var out []netip.Addr
+		for _, a := range addrs[host] {
+			if (network == "ip4") == a.Is4() {
+				out = append(out, a)
+			}
+		}
+		if len(out) == 0 {
+			return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+		}
+		return out, nil
+	}
+}
+
+func TestDNSResolver_BlockedNameIsNXDOMAIN(t *testing.T) {
+	lookups := 0
+	r, queries := startTestResolver(t, nil, func(context.Context, string, string) ([]netip.Addr, error) {
+		lookups++
+		return nil, nil
+	})
+
+	msg := queryUDP(t, r.Port(), "Evil.Example.com.", dnsmessage.TypeA)
+	if msg.RCode != dnsmessage.RCodeNameError {
+		t.Errorf("rcode = %v, want NXDOMAIN", msg.RCode)
+	}
+	if msg.ID != 0x1234 || !msg.Response {
+		t.Errorf("header = %+v, want a response echoing the query ID", msg.Header)
+	}
+	if lookups != 0 {
+		t.Errorf("blocked name was looked up %d times, want 0", lookups)
+	}
+	got := queries()
+	if len(got) != 1 || got[0].Host != "evil.example.com" || got[0].Type != "A" || got[0].Answered {
+		t.Errorf("queries = %+v, want one refused A query for evil.example.com", got)
+	}
+}
+
+func TestDNSResolver_AnswersAllowedName(t *testing.T) {
+	r, queries := startTestResolver(t, []string{"api.github.com"}, stubLookup(map[string][]netip.Addr{
+		"api.github.com": {netip.MustParseAddr("140.82.112.6"), netip.MustParseAddr("2606:50c0::1")},
+	}))
+
+	msg := queryUDP(t, r.Port(), "api.github.com.", dnsmessage.TypeA)
+	if msg.RCode != dnsmessage.RCodeSuccess {
+		t.Fatalf("rcode = %v, want NOERROR", msg.RCode)
+	}
+	if len(msg.Answers) != 1 {
+		t.Fatalf("got %d answers, want 1", len(msg.Answers))
+	}
+	a, ok := msg.Answers[0].Body.(*dnsmessage.AResource)
+	if !ok || netip.AddrFrom4(a.A) != netip.MustParseAddr("140.82.112.6") {
+		t.Errorf("answer = %v, want A 140.82.112.6", msg.Answers[0].Body)
+	}
+	if msg.Answers[0].Header.TTL != dnsAnswerTTL {
+		t.Errorf("TTL = %d, want %d", msg.Answers[0].Header.TTL, dnsAnswerTTL)
+	}
+
+	msg = queryUDP(t, r.Port(), "api.github.com.", dnsmessage.TypeAAAA)
+	if len(msg.Answers) != 1 {
+		t.Fatalf("got %d AAAA answers, want 1", len(msg.Answers))
+	}
+	if _, ok := msg.Answers[0].Body.(*dnsmessage.AAAAResource); !ok {
+		t.Errorf("answer = %v, want AAAA", msg.Answers[0].Body)
+	}
+
+	got := queries()
+	if len(got) != 2 || !got[0].Answered || !got[1].Answered {
+		t.Errorf("queries = %+v, want two answered", got)
+	}
+}
+
+func TestDNSResolver_OtherTypesGetEmptyAnswer(t *testing.T) {
+	r, queries := startTestResolver(t, []string{"example.com"}, func(context.Context, string, string) ([]netip.Addr, error) {
+		t.Error("MX query must not be looked up")
+		return nil, nil
+	})
+
+	msg := queryUDP(t, r.Port(), "example.com.", dnsmessage.TypeMX)
+	if msg.RCode != dnsmessage.RCodeSuccess || len(msg.Answers) != 0 {
+		t.Errorf("rcode = %v, %d answers; want NOERROR with none", msg.RCode, len(msg.Answers))
+	}
+	if got := queries(); len(got) != 1 || got[0].Type != "MX" {
+		t.Errorf("queries = %+v, want one MX query", got)
+	}
+}
+
+func TestDNSResolver_LookupFailures(t *testing.T) {
+	r, _ := startTestResolver(t, []string{"gone.example.com", "broken.example.com"},
+		func(_ context.Context, _, host string) ([]netip.Addr, error) {
+			if host == "gone.example.com" {
+				return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+			}
+			return nil, errors.New("upstream unreachable")
+		})
+
+	if msg := queryUDP(t, r.Port(), "gone.example.com.", dnsmessage.TypeA); msg.RCode != dnsmessage.RCodeNameError {
+		t.Errorf("not found: rcode = %v, want NXDOMAIN", msg.RCode)
+	}
+	if msg := queryUDP(t, r.Port(), "broken.example.com.", dnsmessage.TypeA); msg.RCode != dnsmessage.RCodeServerFailure {
+		t.Errorf("lookup error: rcode = %v, want SERVFAIL", msg.RCode)
+	}
+}
+
+func TestDNSResolver_CapsAnswers(t *testing.T) {
+	var addrs []netip.Addr
+	for i := range 20 {
+		addrs = append(addrs, netip.AddrFrom4([4]byte{10, 0, 0, byte(i + 1)}))
+	}
+	r, _ := startTestResolver(t, []string{"many.example.com"}, stubLookup(map[string][]netip.Addr{"many.example.com": addrs}))
+
+	msg := queryUDP(t, r.Port(), "many.example.com.", dnsmessage.TypeA)
+	if len(msg.Answers) != dnsMaxAnswers {
+		t.Errorf("got %d answers, want %d", len(msg.Answers), dnsMaxAnswers)
+	}
+	if msg.Truncated {
+		t.Error("capped answer must fit without truncation")
+	}
+}
+
+func TestDNSResolver_TCP(t *testing.T) {
+	r, _ := startTestResolver(t, []string{"api.github.com"}, stubLookup(map[string][]netip.Addr{
+		"api.github.com": {netip.MustParseAddr("140.82.112.6")},
+	}))
+
+	conn, err := net.Dial("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(r.Port())))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer func() { _ = conn.Close() }()
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	// Two queries on one connection: both are answered in turn.
+	for _, name := range []string{"api.github.com.", "evil.example.com."} {
+		query := buildDNSQuery(t, name, dnsmessage.TypeA)
+		out := binary.BigEndian.AppendUint16(nil, uint16(len(query)))
+		if _, err := conn.Write(append(out, query...)); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		var length uint16
+		if err := binary.Read(conn, binary.BigEndian, &length); err != nil {
+			t.Fatalf("read length: %v", err)
+		}
+		resp := make([]byte, length)
+		if _, err := io.ReadFull(conn, resp); err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		var msg dnsmessage.Message
+		if err := msg.Unpack(resp); err != nil {
+			t.Fatalf("unpack: %v", err)
+		}
+		want := dnsmessage.RCodeSuccess
+		if name == "evil.example.com." {
+			want = dnsmessage.RCodeNameError
+		}
+		if msg.RCode != want {
+			t.Errorf("%s: rcode = %v, want %v", name, msg.RCode, want)
+		}
+	}
+}
+
+func TestDNSResolver_MalformedQueryIgnored(t *testing.T) {
+	r, queries := startTestResolver(t, nil, nil)
+
+	conn, err := net.Dial("udp", net.JoinHostPort("127.0.0.1", strconv.Itoa(r.Port())))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer func() { _ = conn.Close() }()
+	_, _ = conn.Write([]byte{0x01})
+
+	// The resolver keeps serving after a datagram it cannot parse.
+	if msg := queryUDP(t, r.Port(), "evil.example.com.", dnsmessage.TypeA); msg.RCode != dnsmessage.RCodeNameError {
+		t.Errorf("rcode = %v, want NXDOMAIN", msg.RCode)
+	}
+	if got := queries(); len(got) != 1 {
+		t.Errorf("got %d queries, want 1", len(got))
+	}
+}
+
+func TestServer_DNSDisabledByDefault(t *testing.T) {
+	cfg := NewConfig(shortTempDir(t), 0)
+	server, err := NewServer(cfg)
+	if err != nil {
+		t.Fatalf("NewServer failed: %v", err)
+	}
+	if err := server.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer func() { _ = server.Stop() }()
+	if port := server.DNSPort(); port != 0 {
+		t.Errorf("DNSPort = %d, want 0 when the resolver is off", port)
+	}
+}
+
+// TestServer_DNSRefusedQueryLogged pins that a refused lookup reaches the
+// request log, so "devsandbox logs proxy" shows it beside the HTTP entries.
+func TestServer_DNSRefusedQueryLogged(t *testing.T) {
+	dir := shortTempDir(t)
+	enabled := true
+	cfg := NewConfig(dir, 0)
+	cfg.DNS = &DNSConfig{Enabled: &enabled}
+	cfg.Filter = &FilterConfig{
+		DefaultAction: FilterActionBlock,
+		Rules:         []FilterRule{{Pattern: "*.github.com", Action: FilterActionAllow}},
+	}
+	server, err := NewServer(cfg)
+	if err != nil {
+		t.Fatalf("NewServer failed: %v", err)
+	}
+	if err := server.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer func() { _ = server.Stop() }()
+	if server.DNSPort() == 0 {
+		t.Fatal("DNSPort = 0 with the resolver enabled")
+	}
+
+	msg := queryUDP(t, server.DNSPort(), "evil.example.com.", dnsmessage.TypeA)
+	if msg.RCode != dnsmessage.RCodeNameError {
+		t.Errorf("rcode = %v, want NXDOMAIN", msg.RCode)
+	}
+
+	entries := readLoggedEntries(t, filepath.Join(dir, LogBaseDirName, ProxyLogDirName), 1)
+	e := entries[0]
+	if e.Method != "DNS" || e.URL != "dns://evil.example.com?type=A" {
+		t.Errorf("entry = %s %s, want DNS dns://evil.example.com?type=A", e.Method, e.URL)
+	}
+	if e.FilterAction != string(FilterActionBlock) || e.FilterReason == "" {
+		t.Errorf("filter = %q (%q), want block with a reason", e.FilterAction, e.FilterReason)
+	}
+}
+
+// TestServer_DNSAskRefusedUntilAllowed pins that an ask decision is not
+// answered - the lookup would leak the name before anyone was asked - until
+// the host is allowed and remembered, and that answered queries are logged
+// too.
+func TestServer_DNSAskRefusedUntilAllowed(t *testing.T) {
+	dir := shortTempDir(t)
+	enabled := true
+	cfg := NewConfig(dir, 0)
+	cfg.DNS = &DNSConfig{Enabled: &enabled}
+	cfg.Filter = &FilterConfig{DefaultAction: FilterActionAsk}
+	server, err := NewServer(cfg)
+	if err != nil {
+		t.Fatalf("NewServer failed: %v", err)
+	}
+	server.dns.lookup = func(context.Context, string, string) ([]netip.Addr, error) {
+		return []netip.Addr{netip.MustParseAddr("192.0.2.1")}, nil
+	}
+	if err := server.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer func() { _ = server.Stop() }()
+
+	if msg := queryUDP(t, server.DNSPort(), "api.example.com.", dnsmessage.TypeA); msg.RCode != dnsmessage.RCodeNameError {
+		t.Errorf("before approval: rcode = %v, want NXDOMAIN", msg.RCode)
+	}
+	server.filterEngine.CacheDecision("api.example.com", FilterActionAllow)
+	if msg := queryUDP(t, server.DNSPort(), "api.example.com.", dnsmessage.TypeA); msg.RCode != dnsmessage.RCodeSuccess || len(msg.Answers) != 1 {
+		t.Errorf("after approval: rcode = %v with %d answers, want one answer", msg.RCode, len(msg.Answers))
+	}
+
+	entries := readLoggedEntries(t, filepath.Join(dir, LogBaseDirName, ProxyLogDirName), 2)
+	if entries[0].FilterAction != string(FilterActionBlock) || entries[1].FilterAction != string(FilterActionAllow) {
+		t.Errorf("logged actions = %q, %q; want block, allow", entries[0].FilterAction, entries[1].FilterAction)
+	}
+}
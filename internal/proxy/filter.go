@@ -1,14 +1,21 @@
 package proxy
 
 import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
 	"net/url"
 	"regexp"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/bmatcuk/doublestar/v4"
 )
@@ -19,20 +26,56 @@ import (
 // engine is published, and are immutable afterwards - so the readers need no
 // lock. A future config-reload path would have to introduce one and cover
 // every reader of both fields; there is deliberately no mutex here implying
-// that work is already done. cacheMu guards decisionCache, which is mutable.
+// that work is already done. cacheMu guards decisionCache and sessionRules,
+// which are mutable.
 type FilterEngine struct {
 	config        *FilterConfig
 	compiledRules []compiledRule
 
 	// Decision cache for ask mode (host -> action)
-	decisionCache map[string]FilterAction
-	cacheMu       sync.RWMutex
+	decisionCache map[string]cachedDecision
+	// sessionRules are permanent ask-mode decisions made during the session.
+	sessionRules []compiledRule
+	cacheMu      sync.RWMutex
+
+	now func() time.Time // for cache expiry; a field so tests can move it
+}
+
+// cachedDecision is a remembered ask-mode answer.
+type cachedDecision struct {
+	action  FilterAction
+	expires time.Time // zero: for the rest of the session
 }
 
 // compiledRule is a filter rule with a pre-compiled matcher.
 type compiledRule struct {
 	rule    FilterRule
 	matcher func(string) bool
+
+	// methods, headers and body are the request matchers, nil when the rule
+	// has none. All of them must match, alongside matcher, for the rule to.
+	methods map[string]struct{}
+	headers []compiledHeaderMatch
+	body    []compiledBodyMatch
+
+	// ports is the destination port set, nil when the rule matches any port.
+	ports map[int]struct{}
+
+	// domain matches the hosts the rule names, for MatchDomain; nil when it
+	// names none. See compileRuleDomain.
+	domain func(string) bool
+}
+
+type compiledHeaderMatch struct {
+	name    string // canonical header key
+	matcher func(string) bool
+}
+
+type compiledBodyMatch struct {
+	path    jsonPath
+	in      map[string]struct{}
+	notIn   map[string]struct{}
+	matcher func(string) bool
 }
 
 // NewFilterEngine creates a new filter engine with the given configuration.
@@ -47,7 +90,8 @@ func NewFilterEngine(cfg *FilterConfig) (*FilterEngine, error) {
 
 	engine := &FilterEngine{
 		config:        cfg,
-		decisionCache: make(map[string]FilterAction),
+		decisionCache: make(map[string]cachedDecision),
+		now:           time.Now,
 	}
 
 	// Compile all rules
@@ -68,7 +112,262 @@ func compileRule(rule FilterRule) (compiledRule, error) {
 	if err != nil {
 		return compiledRule{}, err
 	}
-	return compiledRule{rule: rule, matcher: matcher}, nil
+	compiled := compiledRule{rule: rule, matcher: matcher}
+	if compiled.domain, err = compileRuleDomain(rule, matcher); err != nil {
+		return compiledRule{}, err
+	}
+
+	if len(rule.Methods) > 0 {
+		compiled.methods = make(map[string]struct{}, len(rule.Methods))
+		for _, m := range rule.Methods {
+			compiled.methods[strings.ToUpper(m)] = struct{}{}
+		}
+	}
+
+	// Sorted so a rule's matchers are evaluated, and displayed, in a stable
+	// order rather than the map's.
+	names := make([]string, 0, len(rule.Headers))
+	for name := range rule.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		pattern := rule.Headers[name]
+		m, err := compilePattern(pattern, detectPatternType(pattern))
+		if err != nil {
+			return compiledRule{}, fmt.Errorf("header %q: %w", name, err)
+		}
+		compiled.headers = append(compiled.headers, compiledHeaderMatch{
+			name:    http.CanonicalHeaderKey(name),
+			matcher: m,
+		})
+	}
+
+	if len(rule.Ports) > 0 {
+		compiled.ports = make(map[int]struct{}, len(rule.Ports))
+		for _, port := range rule.Ports {
+			compiled.ports[port] = struct{}{}
+		}
+	}
+
+	for _, bm := range rule.Body {
+		path, err := compileJSONPath(bm.Path)
+		if err != nil {
+			return compiledRule{}, err
+		}
+		cb := compiledBodyMatch{path: path, in: stringSet(bm.In), notIn: stringSet(bm.NotIn)}
+		if bm.Pattern != "" {
+			cb.matcher, err = compilePattern(bm.Pattern, detectPatternType(bm.Pattern))
+			if err != nil {
+				return compiledRule{}, fmt.Errorf("body %q: %w", bm.Path, err)
+			}
+		}
+		compiled.body = append(compiled.body, cb)
+	}
+
+	return compiled, nil
+}
+
+func stringSet(values []string) map[string]struct{} {
+	if len(values) == 0 {
+		return nil
+	}
+	set := make(map[string]struct{}, len(values))
+	for _, v := range values {
+		set[v] = struct{}{}
+	}
+	return set
+}
+
+// matchesRequest reports whether the rule's pattern, ports, methods and
+// headers all match - everything but the body.
+func (e *FilterEngine) matchesRequest(c *compiledRule, req *http.Request) bool {
+	if !c.matcher(e.getMatchTarget(req, c.rule.GetScope())) {
+		return false
+	}
+	if c.ports != nil && !c.matchesPort(requestPort(req)) {
+		return false
+	}
+	if c.methods != nil {
+		if _, ok := c.methods[strings.ToUpper(req.Method)]; !ok {
+			return false
+		}
+	}
+	for _, h := range c.headers {
+		if !matchesAnyValue(req.Header.Values(h.name), h.matcher) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchesPort reports whether port is one the rule is restricted to. A rule
+// without ports matches any.
+func (c *compiledRule) matchesPort(port int) bool {
+	if c.ports == nil {
+		return true
+	}
+	_, ok := c.ports[port]
+	return ok
+}
+
+// requestPort returns the port a request is sent to: the one its authority
+// names, or its scheme's default. 0 when neither says.
+func requestPort(req *http.Request) int {
+	scheme := ""
+	if req.URL != nil {
+		scheme = strings.ToLower(req.URL.Scheme)
+	}
+	if port := hostPort(RequestHost(req)); port != 0 {
+		return port
+	}
+	port, _ := strconv.Atoi(defaultSchemePorts[scheme])
+	return port
+}
+
+// hostPort returns the port of a "host:port" authority, or 0 when it names
+// none.
+func hostPort(hostport string) int {
+	_, p, err := net.SplitHostPort(hostport)
+	if err != nil {
+		return 0
+	}
+	port, err := strconv.Atoi(p)
+	if err != nil {
+		return 0
+	}
+	return port
+}
+
+func matchesAnyValue(values []string, matcher func(string) bool) bool {
+	for _, v := range values {
+		if matcher(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// requestBody is a request body as body matchers see it: its
+// Content-Encoding undone and its JSON parsed, once, by the first rule that
+// looks at it.
+type requestBody struct {
+	raw      []byte
+	encoding string
+	limit    int
+
+	parsed      bool
+	doc         any
+	isJSON      bool
+	undecodable bool
+}
+
+func newRequestBody(req *http.Request, raw []byte, limit int) *requestBody {
+	return &requestBody{raw: raw, encoding: req.Header.Get("Content-Encoding"), limit: limit}
+}
+
+func (b *requestBody) parse() {
+	if b.parsed {
+		return
+	}
+	b.parsed = true
+	if b.raw == nil {
+		return
+	}
+	decoded, err := decodeContentEncoding(b.raw, b.encoding, b.limit)
+	if err != nil {
+		b.undecodable = true
+		return
+	}
+	b.doc, b.isJSON = parseJSONBody(decoded)
+}
+
+// decodeContentEncoding undoes the codings listed in a Content-Encoding
+// header, last applied first, and fails once the result passes limit bytes:
+// a few kilobytes of gzip can expand to gigabytes. gzip and deflate are
+// supported; any other coding is an error.
+func decodeContentEncoding(body []byte, encoding string, limit int) ([]byte, error) {
+	codings := strings.Split(encoding, ",")
+	for i := len(codings) - 1; i >= 0; i-- {
+		var r io.Reader
+		var err error
+		switch coding := strings.ToLower(strings.TrimSpace(codings[i])); coding {
+		case "", "identity":
+			continue
+		case "gzip", "x-gzip":
+			r, err = gzip.NewReader(bytes.NewReader(body))
+		case "deflate":
+			r, err = zlib.NewReader(bytes.NewReader(body))
+		default:
+			return nil, fmt.Errorf("unsupported Content-Encoding %q", coding)
+		}
+		if err != nil {
+			return nil, err
+		}
+		// limit+1 tells a body that exactly fills the limit from one that
+		// exceeds it.
+		decoded, err := io.ReadAll(io.LimitReader(r, int64(limit)+1))
+		if err != nil {
+			return nil, err
+		}
+		if len(decoded) > limit {
+			return nil, fmt.Errorf("decoded body exceeds %d bytes", limit)
+		}
+		body = decoded
+	}
+	return body, nil
+}
+
+// matchesBody reports whether every body matcher of the rule matches. A body
+// whose Content-Encoding cannot be undone within the limit matches a block
+// rule and nothing else: a rule that cannot see the body must not let it
+// through, and must not block what it was never meant to.
+func (c *compiledRule) matchesBody(body *requestBody) bool {
+	if len(c.body) == 0 {
+		return true
+	}
+	body.parse()
+	if body.undecodable {
+		return c.rule.Action == FilterActionBlock
+	}
+	for _, bm := range c.body {
+		var values []string
+		if body.isJSON {
+			for _, v := range bm.path.eval(body.doc) {
+				values = append(values, jsonValueString(v))
+			}
+		}
+		if !bm.matches(values) {
+			return false
+		}
+	}
+	return true
+}
+
+func (bm *compiledBodyMatch) matches(values []string) bool {
+	switch {
+	case bm.notIn != nil:
+		// Nothing selected counts as outside the list: see FilterBodyMatch.NotIn.
+		if len(values) == 0 {
+			return true
+		}
+		for _, v := range values {
+			if _, ok := bm.notIn[v]; !ok {
+				return true
+			}
+		}
+		return false
+	case bm.in != nil:
+		for _, v := range values {
+			if _, ok := bm.in[v]; ok {
+				return true
+			}
+		}
+		return false
+	case bm.matcher != nil:
+		return matchesAnyValue(values, bm.matcher)
+	}
+	return len(values) > 0
 }
 
 // compileScopedPattern compiles a pattern for the given scope. Exact and glob
@@ -103,6 +402,39 @@ func compileScopedPattern(pattern string, t PatternType, scope FilterScope) (fun
 	return compilePattern(pattern, t)
 }
 
+// compileRuleDomain returns a matcher for the hosts a rule names: a host
+// rule's own matcher, or one for the authority of a url rule's exact or glob
+// pattern. It returns nil for a rule that names no host - a path rule, a url
+// regex or a url pattern without a scheme, or a glob of nothing but `*`, which
+// names every host.
+func compileRuleDomain(rule FilterRule, matcher func(string) bool) (func(string) bool, error) {
+	t := rule.DetectPatternType()
+	switch rule.GetScope() {
+	case FilterScopeHost:
+		if t == PatternTypeGlob && strings.Trim(rule.Pattern, "*") == "" {
+			return nil, nil
+		}
+		return matcher, nil
+	case FilterScopeURL:
+		if t == PatternTypeRegex {
+			return nil, nil
+		}
+		_, rest, ok := strings.Cut(canonicalizeURLPattern(rule.Pattern), "://")
+		if !ok {
+			return nil, nil
+		}
+		if end := strings.IndexAny(rest, "/?#"); end >= 0 {
+			rest = rest[:end]
+		}
+		host := NormalizeHost(rest)
+		if strings.Trim(host, "*") == "" {
+			return nil, nil
+		}
+		return compilePattern(host, t)
+	}
+	return nil, nil
+}
+
 // compilePattern returns a matcher function for the given pattern and type.
 // Shared by FilterEngine and LogSkipEngine to avoid duplicating the
 // exact/glob/regex compilation switch.
@@ -137,8 +469,33 @@ func compilePattern(pattern string, t PatternType) (func(string) bool, error) {
 	}
 }
 
+// NeedsBody reports whether deciding req requires its body: whether the first
+// rule to match everything but the body has body matchers. Rules are
+// first-match-wins, so a body-free rule ahead of it decides the request
+// without the body ever being read, and uploads no body rule could reach are
+// not buffered.
+func (e *FilterEngine) NeedsBody(req *http.Request) bool {
+	if !e.config.IsEnabled() {
+		return false
+	}
+	for i := range e.compiledRules {
+		compiled := &e.compiledRules[i]
+		if !e.matchesRequest(compiled, req) {
+			continue
+		}
+		if len(compiled.body) > 0 {
+			return true
+		}
+		return false
+	}
+	return false
+}
+
 // Match evaluates the request against filter rules and returns a decision.
-func (e *FilterEngine) Match(req *http.Request) FilterDecision {
+// body is the request body buffered for body matchers (see NeedsBody), as
+// sent; nil when it was not read, in which case a body matcher sees no JSON.
+// Body matchers see it with its Content-Encoding undone.
+func (e *FilterEngine) Match(req *http.Request, body []byte) FilterDecision {
 	// If filtering is disabled, always allow
 	if !e.config.IsEnabled() {
 		return FilterDecision{
@@ -150,14 +507,19 @@ func (e *FilterEngine) Match(req *http.Request) FilterDecision {
 
 	// Evaluate rules in order, then let a remembered answer stand in only where
 	// a prompt is what would otherwise happen.
-	for _, compiled := range e.compiledRules {
-		target := e.getMatchTarget(req, compiled.rule.GetScope())
-		if compiled.matcher(target) {
-			return e.decisionFor(&compiled.rule, RequestHost(req))
+	reqBody := newRequestBody(req, body, e.config.GetMaxBodyBytes())
+	for i := range e.compiledRules {
+		compiled := &e.compiledRules[i]
+		if e.matchesRequest(compiled, req) && compiled.matchesBody(reqBody) {
+			return e.decisionFor(&compiled.rule, RequestHost(req), func(c *compiledRule) bool {
+				return e.matchesRequest(c, req)
+			})
 		}
 	}
 
-	return e.decisionFor(nil, RequestHost(req))
+	return e.decisionFor(nil, RequestHost(req), func(c *compiledRule) bool {
+		return e.matchesRequest(c, req)
+	})
 }
 
 // MatchHost evaluates a CONNECT target ("example.com:443") against the
@@ -165,10 +527,12 @@ func (e *FilterEngine) Match(req *http.Request) FilterDecision {
 // carries no path or URL, so Match cannot be used: there is no request to
 // build a path or url match target from.
 //
-// Rules of any other scope are skipped rather than guessed at. That cannot
-// silently enforce less than the configuration promises, because NewServer
-// refuses to start with a path- or url-scoped rule while MITM is off - the
-// only configuration in which this method is reached.
+// A rule restricted to ports matches only a target on one of them; a target
+// without a port matches none. Rules of any other scope, and rules matching
+// on method, headers or body, are skipped rather than guessed at. That
+// enforces no less than the configuration promises only while MITM is off,
+// when NewServer refuses to start with such rules; with MITM on, a SOCKS
+// target is decided by MatchSOCKS instead.
 func (e *FilterEngine) MatchHost(hostport string) FilterDecision {
 	if !e.config.IsEnabled() {
 		return FilterDecision{
@@ -179,17 +543,133 @@ func (e *FilterEngine) MatchHost(hostport string) FilterDecision {
 	}
 
 	host := NormalizeHost(hostport)
+	port := hostPort(hostport)
+	matches := func(c *compiledRule) bool {
+		return c.rule.GetScope() == FilterScopeHost && !c.rule.HasRequestMatchers() &&
+			c.matcher(host) && c.matchesPort(port)
+	}
+
+	for i := range e.compiledRules {
+		if matches(&e.compiledRules[i]) {
+			return e.decisionFor(&e.compiledRules[i].rule, host, matches)
+		}
+	}
+
+	return e.decisionFor(nil, host, matches)
+}
+
+// inspectedPorts are the ports the HTTP proxy carries the sandbox's traffic
+// on. With MITM on, traffic to them is decrypted and checked against every
+// rule, redaction, the limits and the git push policy.
+var inspectedPorts = []int{80, 443}
+
+// inspectedPortDecision refuses a SOCKS target on an inspectedPorts port
+// while MITM is on, when no rule explicitly allows it. ok is false for any
+// other port.
+func inspectedPortDecision(hostport string) (decision FilterDecision, ok bool) {
+	port := hostPort(hostport)
+	if !slices.Contains(inspectedPorts, port) {
+		return FilterDecision{}, false
+	}
+	return FilterDecision{
+		Action:    FilterActionBlock,
+		IsDefault: true,
+		Reason:    fmt.Sprintf("port %d goes through the HTTP proxy; a rule listing it in ports allows raw TCP", port),
+	}, true
+}
+
+// MatchSOCKS decides a SOCKS target ("example.com:5432") while MITM is on.
+// A raw TCP connection skips everything the HTTP proxy enforces beyond the
+// host, so it is decided like MatchHost with two differences: only a rule
+// naming ports - or a block - can decide it, as an allow written for a
+// host's HTTPS API must not open raw TCP to it; and with no such rule, a
+// target on an inspectedPorts port is refused whatever the default action,
+// as that traffic belongs on the HTTP proxy. Cached decisions are not
+// consulted, for the same reason: they are kept per host.
+func (e *FilterEngine) MatchSOCKS(hostport string) FilterDecision {
+	if !e.config.IsEnabled() {
+		if decision, ok := inspectedPortDecision(hostport); ok {
+			return decision
+		}
+		return FilterDecision{
+			Action:    FilterActionAllow,
+			IsDefault: true,
+			Reason:    "filtering disabled",
+		}
+	}
+
+	host := NormalizeHost(hostport)
+	port := hostPort(hostport)
+	matches := func(c *compiledRule) bool {
+		return c.rule.GetScope() == FilterScopeHost && !c.rule.HasRequestMatchers() &&
+			(c.ports != nil || c.rule.Action == FilterActionBlock) &&
+			c.matcher(host) && c.matchesPort(port)
+	}
 
+	var rule *FilterRule
+	for i := range e.compiledRules {
+		if matches(&e.compiledRules[i]) {
+			rule = &e.compiledRules[i].rule
+			break
+		}
+	}
+	if rule != nil && rule.Action != FilterActionAsk {
+		return matchedDecision(*rule)
+	}
+	if learned := e.matchSessionRule(matches); learned != nil {
+		return matchedDecision(*learned)
+	}
+	if rule != nil {
+		return matchedDecision(*rule)
+	}
+	if decision, ok := inspectedPortDecision(hostport); ok {
+		return decision
+	}
+	return e.defaultDecision()
+}
+
+// MatchDomain decides whether the DNS resolver answers for host. It is
+// MatchHost, with one difference: where no host rule matched and the default
+// would refuse or ask, an allow rule that decides per request - scoped to a
+// URL or path, matching on method, headers or body, or restricted to ports,
+// which a name without a port never matches - may still allow some request to
+// host, and MatchHost skips those rules. Such a rule answers the name when it
+// names host: a host rule by its pattern, a url rule by the authority in its
+// pattern. A rule naming no host (see compileRuleDomain) answers every name,
+// and only with answerHostless - the query itself carries the name out, so
+// one path rule would otherwise turn the resolver into a channel for any name
+// the sandbox makes up. The request that follows is left to Match.
+func (e *FilterEngine) MatchDomain(host string, answerHostless bool) FilterDecision {
+	decision := e.MatchHost(host)
+	if !decision.IsDefault || decision.Action == FilterActionAllow {
+		return decision
+	}
+	host = NormalizeHost(host)
 	for _, compiled := range e.compiledRules {
-		if compiled.rule.GetScope() != FilterScopeHost {
+		rule := compiled.rule
+		if rule.Action != FilterActionAllow {
+			continue
+		}
+		// A plain host rule was MatchHost's to decide.
+		if rule.GetScope() == FilterScopeHost && !rule.HasRequestMatchers() && len(rule.Ports) == 0 {
+			continue
+		}
+		switch {
+		case compiled.domain != nil:
+			if !compiled.domain(host) {
+				continue
+			}
+		case !answerHostless:
 			continue
 		}
-		if compiled.matcher(host) {
-			return e.decisionFor(&compiled.rule, host)
+		return FilterDecision{
+			Action:    FilterActionAllow,
+			Rule:      &rule,
+			Reason:    fmt.Sprintf("rule %s may allow requests to this host; each request is still filtered", rule.Pattern),
+			IsDefault: false,
 		}
 	}
-
-	return e.decisionFor(nil, host)
+	return decision
 }
 
 // decisionFor turns the matched rule - or its absence - into a decision,
@@ -208,10 +688,17 @@ func (e *FilterEngine) MatchHost(hostport string) FilterDecision {
 //
 // An explicit allow or block therefore wins outright, and "remember" keeps
 // meaning what the prompt offered: do not ask me again about this host.
-func (e *FilterEngine) decisionFor(rule *FilterRule, host string) FilterDecision {
+//
+// A session rule - a permanent decision made during the session - stands in
+// the same way, ahead of the cache since it is the narrower answer. matches
+// reports whether one applies to the request being decided.
+func (e *FilterEngine) decisionFor(rule *FilterRule, host string, matches func(*compiledRule) bool) FilterDecision {
 	if rule != nil && rule.Action != FilterActionAsk {
 		return matchedDecision(*rule)
 	}
+	if learned := e.matchSessionRule(matches); learned != nil {
+		return matchedDecision(*learned)
+	}
 	if e.config.IsCacheEnabled() {
 		if decision := e.getCachedDecision(host); decision != "" {
 			return FilterDecision{
@@ -397,28 +884,77 @@ func canonicalizeHost(host string) string {
 // CacheDecision stores a decision for future requests to the same host.
 // The host is normalized (port removed) to ensure consistent cache keys.
 func (e *FilterEngine) CacheDecision(host string, action FilterAction) {
+	e.CacheDecisionFor(host, action, 0)
+}
+
+// CacheDecisionFor is CacheDecision for a limited time: the decision is
+// forgotten after ttl, and the host prompts again. A ttl of 0 keeps it for the
+// session.
+func (e *FilterEngine) CacheDecisionFor(host string, action FilterAction, ttl time.Duration) {
 	if !e.config.IsCacheEnabled() {
 		return
 	}
 
+	entry := cachedDecision{action: action}
+	if ttl > 0 {
+		entry.expires = e.now().Add(ttl)
+	}
 	e.cacheMu.Lock()
 	defer e.cacheMu.Unlock()
-	e.decisionCache[NormalizeHost(host)] = action
+	e.decisionCache[NormalizeHost(host)] = entry
 }
 
-// getCachedDecision retrieves a cached decision for a host.
+// getCachedDecision retrieves a cached decision for a host, or "" when there
+// is none or it has expired.
 // The host is normalized (port removed) to ensure consistent cache keys.
 func (e *FilterEngine) getCachedDecision(host string) FilterAction {
 	e.cacheMu.RLock()
 	defer e.cacheMu.RUnlock()
-	return e.decisionCache[NormalizeHost(host)]
+	entry := e.decisionCache[NormalizeHost(host)]
+	if !entry.expires.IsZero() && !e.now().Before(entry.expires) {
+		return ""
+	}
+	return entry.action
+}
+
+// AddSessionRule applies a permanent ask-mode decision for the rest of the
+// session. The rule is already in a config file, where it takes effect from
+// the next launch; until then it answers only requests that would otherwise
+// prompt, as a cached decision does, since the rules loaded at startup cannot
+// be reordered around it.
+func (e *FilterEngine) AddSessionRule(rule FilterRule) error {
+	if err := rule.Validate(); err != nil {
+		return err
+	}
+	compiled, err := compileRule(rule)
+	if err != nil {
+		return fmt.Errorf("failed to compile rule %q: %w", rule.Pattern, err)
+	}
+	e.cacheMu.Lock()
+	defer e.cacheMu.Unlock()
+	e.sessionRules = append(e.sessionRules, compiled)
+	return nil
+}
+
+// matchSessionRule returns the most recent session rule matches accepts, or
+// nil.
+func (e *FilterEngine) matchSessionRule(matches func(*compiledRule) bool) *FilterRule {
+	e.cacheMu.RLock()
+	defer e.cacheMu.RUnlock()
+	for i := len(e.sessionRules) - 1; i >= 0; i-- {
+		if matches(&e.sessionRules[i]) {
+			rule := e.sessionRules[i].rule
+			return &rule
+		}
+	}
+	return nil
 }
 
 // ClearCache clears all cached decisions.
 func (e *FilterEngine) ClearCache() {
 	e.cacheMu.Lock()
 	defer e.cacheMu.Unlock()
-	e.decisionCache = make(map[string]FilterAction)
+	e.decisionCache = make(map[string]cachedDecision)
 }
 
 // IsEnabled returns true if filtering is active.
@@ -1,12 +1,16 @@
 package proxy
 
 import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/bmatcuk/doublestar/v4"
 )
@@ -125,7 +129,7 @@ func TestFilterEngine_GlobPattern(t *testing.T) {
 				Host: tt.host,
 				URL:  &url.URL{Host: tt.host, Path: "/"},
 			}
-			decision := engine.Match(req)
+			decision := engine.Match(req, nil)
 			if decision.Action != tt.expected {
 				t.Errorf("got action %s, want %s", decision.Action, tt.expected)
 			}
@@ -163,7 +167,7 @@ func TestFilterEngine_RegexPattern(t *testing.T) {
 				Host: tt.host,
 				URL:  &url.URL{Host: tt.host, Path: "/"},
 			}
-			decision := engine.Match(req)
+			decision := engine.Match(req, nil)
 			if decision.Action != tt.expected {
 				t.Errorf("got action %s, want %s", decision.Action, tt.expected)
 			}
@@ -202,7 +206,7 @@ func TestFilterEngine_BlacklistMode(t *testing.T) {
 				Host: tt.host,
 				URL:  &url.URL{Host: tt.host, Path: "/"},
 			}
-			decision := engine.Match(req)
+			decision := engine.Match(req, nil)
 			if decision.Action != tt.expected {
 				t.Errorf("got action %s, want %s", decision.Action, tt.expected)
 			}
@@ -241,7 +245,7 @@ func TestFilterEngine_PathScope(t *testing.T) {
 				Host: "example.com",
 				URL:  &url.URL{Host: "example.com", Path: tt.path},
 			}
-			decision := engine.Match(req)
+			decision := engine.Match(req, nil)
 			if decision.Action != tt.expected {
 				t.Errorf("got action %s, want %s", decision.Action, tt.expected)
 			}
@@ -266,7 +270,7 @@ func TestFilterEngine_DisabledMode(t *testing.T) {
 		Host: "blocked.com",
 		URL:  &url.URL{Host: "blocked.com", Path: "/"},
 	}
-	decision := engine.Match(req)
+	decision := engine.Match(req, nil)
 
 	if decision.Action != FilterActionAllow {
 		t.Errorf("disabled mode should allow all, got %s", decision.Action)
@@ -293,7 +297,7 @@ func TestFilterEngine_DecisionCache(t *testing.T) {
 		Host: "cached.example.com",
 		URL:  &url.URL{Host: "cached.example.com", Path: "/"},
 	}
-	decision := engine.Match(req)
+	decision := engine.Match(req, nil)
 
 	if decision.Action != FilterActionAllow {
 		t.Errorf("cached decision should be allow, got %s", decision.Action)
@@ -303,12 +307,31 @@ func TestFilterEngine_DecisionCache(t *testing.T) {
 	engine.ClearCache()
 
 	// Should now return ask (default for ask mode)
-	decision = engine.Match(req)
+	decision = engine.Match(req, nil)
 	if decision.Action != FilterActionAsk {
 		t.Errorf("after cache clear, should return ask, got %s", decision.Action)
 	}
 }
 
+func TestFilterEngine_DecisionCacheExpiry(t *testing.T) {
+	engine, err := NewFilterEngine(&FilterConfig{DefaultAction: FilterActionAsk})
+	if err != nil {
+		t.Fatalf("failed to create filter engine: %v", err)
+	}
+	now := time.Now()
+	engine.now = func() time.Time { return now }
+
+	engine.CacheDecisionFor("timed.example.com:443", FilterActionAllow, 10*time.Minute)
+	if d := engine.MatchHost("timed.example.com"); d.Action != FilterActionAllow {
+		t.Errorf("within ttl: got %s, want allow", d.Action)
+	}
+
+	now = now.Add(10 * time.Minute)
+	if d := engine.MatchHost("timed.example.com"); d.Action != FilterActionAsk {
+		t.Errorf("after ttl: got %s, want ask", d.Action)
+	}
+}
+
 func TestFilterConfig_Validate(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -528,7 +551,7 @@ func TestFilterEngine_HostAliasing_Blacklist(t *testing.T) {
 				Host: tt.host,
 				URL:  &url.URL{Host: tt.host, Path: "/"},
 			}
-			decision := engine.Match(req)
+			decision := engine.Match(req, nil)
 			if decision.Action != FilterActionBlock {
 				t.Errorf("host %q: got action %s, want %s", tt.host, decision.Action, FilterActionBlock)
 			}
@@ -582,7 +605,7 @@ func TestFilterEngine_HostAliasing_NarrowBlockFirst(t *testing.T) {
 				Host: tt.host,
 				URL:  &url.URL{Host: tt.host, Path: "/"},
 			}
-			decision := engine.Match(req)
+			decision := engine.Match(req, nil)
 			if decision.Action != tt.expected {
 				t.Errorf("host %q: got action %s, want %s", tt.host, decision.Action, tt.expected)
 			}
@@ -626,7 +649,7 @@ func TestFilterEngine_CacheHostAliasing(t *testing.T) {
 		Host: "Example.COM.:443",
 		URL:  &url.URL{Host: "Example.COM.:443", Path: "/"},
 	}
-	if decision := engine.Match(req); decision.Action != FilterActionAllow {
+	if decision := engine.Match(req, nil); decision.Action != FilterActionAllow {
 		t.Errorf("Match on aliased host: got %s, want %s", decision.Action, FilterActionAllow)
 	}
 }
@@ -661,19 +684,19 @@ func TestFilterEngine_CacheDoesNotOverrideExplicitRules(t *testing.T) {
 		return &http.Request{Host: host, URL: &url.URL{Host: host, Path: path}}
 	}
 
-	if d := engine.Match(req("blocked.example.com", "/other")); d.Action != FilterActionBlock {
+	if d := engine.Match(req("blocked.example.com", "/other"), nil); d.Action != FilterActionBlock {
 		t.Errorf("Match on a blocked host with a remembered allow = %s, want %s", d.Action, FilterActionBlock)
 	}
 	if d := engine.MatchHost("blocked.example.com:443"); d.Action != FilterActionBlock {
 		t.Errorf("MatchHost on a blocked host with a remembered allow = %s, want %s", d.Action, FilterActionBlock)
 	}
 	// The mirror case: a remembered block must not retire an explicit allow.
-	if d := engine.Match(req("allowed.example.com", "/other")); d.Action != FilterActionAllow {
+	if d := engine.Match(req("allowed.example.com", "/other"), nil); d.Action != FilterActionAllow {
 		t.Errorf("Match on an allowed host with a remembered block = %s, want %s", d.Action, FilterActionAllow)
 	}
 	// Where the matched rule is the `ask` itself, the remembered answer is
 	// exactly what it is for and must still stand in.
-	if d := engine.Match(req("blocked.example.com", "/secret")); d.Action != FilterActionAllow {
+	if d := engine.Match(req("blocked.example.com", "/secret"), nil); d.Action != FilterActionAllow {
 		t.Errorf("Match on the asking rule = %s, want the remembered %s", d.Action, FilterActionAllow)
 	}
 }
@@ -747,7 +770,7 @@ func TestFilterEngine_ConcurrentReaders(t *testing.T) {
 			for n := range iterations {
 				switch worker % 4 {
 				case 0:
-					if decision := engine.Match(req); decision.Action != FilterActionBlock {
+					if decision := engine.Match(req, nil); decision.Action != FilterActionBlock {
 						errs <- "Match: got " + string(decision.Action) + ", want " + string(FilterActionBlock)
 						return
 					}
@@ -832,14 +855,14 @@ func TestURLScopeCanonicalizesAuthority(t *testing.T) {
 		"https://api.example.com./secret",
 	} {
 		req := httptest.NewRequest(http.MethodGet, raw, nil)
-		if got := engine.Match(req).Action; got != FilterActionBlock {
+		if got := engine.Match(req, nil).Action; got != FilterActionBlock {
 			t.Errorf("Match(%q) = %q, want block", raw, got)
 		}
 	}
 
 	// A different host is still unaffected, and the path half stays literal.
 	req := httptest.NewRequest(http.MethodGet, "https://other.example.com/secret", nil)
-	if got := engine.Match(req).Action; got != FilterActionAllow {
+	if got := engine.Match(req, nil).Action; got != FilterActionAllow {
 		t.Errorf("Match(other host) = %q, want allow", got)
 	}
 }
@@ -859,7 +882,7 @@ func TestURLScopeCanonicalizesPattern(t *testing.T) {
 		t.Fatal(err)
 	}
 	req := httptest.NewRequest(http.MethodGet, "https://api.example.com/secret", nil)
-	if got := engine.Match(req).Action; got != FilterActionBlock {
+	if got := engine.Match(req, nil).Action; got != FilterActionBlock {
 		t.Errorf("Match = %q, want block", got)
 	}
 }
@@ -885,14 +908,14 @@ func TestURLScopeDropsDefaultPort(t *testing.T) {
 		"https://api.example.com:443/v1/users",
 	} {
 		req := httptest.NewRequest(http.MethodGet, raw, nil)
-		if got := engine.Match(req).Action; got != FilterActionBlock {
+		if got := engine.Match(req, nil).Action; got != FilterActionBlock {
 			t.Errorf("Match(%q) = %q, want block", raw, got)
 		}
 	}
 
 	// A non-default port is a different endpoint and stays outside the rule.
 	req := httptest.NewRequest(http.MethodGet, "https://api.example.com:8443/v1/users", nil)
-	if got := engine.Match(req).Action; got != FilterActionAllow {
+	if got := engine.Match(req, nil).Action; got != FilterActionAllow {
 		t.Errorf("Match(explicit non-default port) = %q, want allow", got)
 	}
 }
@@ -917,14 +940,14 @@ func TestHostScopeMatchesConnectTargetNotHostHeader(t *testing.T) {
 
 	req := httptest.NewRequest(http.MethodGet, "https://evil.example.com:443/x", nil)
 	req.Host = "allowed.example.com"
-	if got := engine.Match(req).Action; got != FilterActionBlock {
+	if got := engine.Match(req, nil).Action; got != FilterActionBlock {
 		t.Errorf("Match = %q, want block (matched the Host header, not the connect target)", got)
 	}
 
 	// And the mirror: a forged Host header must not pull an unrelated rule in.
 	req = httptest.NewRequest(http.MethodGet, "https://allowed.example.com:443/x", nil)
 	req.Host = "evil.example.com"
-	if got := engine.Match(req).Action; got != FilterActionAllow {
+	if got := engine.Match(req, nil).Action; got != FilterActionAllow {
 		t.Errorf("Match = %q, want allow", got)
 	}
 }
@@ -963,3 +986,526 @@ func TestUsesAskAction(t *testing.T) {
 		})
 	}
 }
+
+func TestFilterEngine_MethodMatcher(t *testing.T) {
+	engine, err := NewFilterEngine(&FilterConfig{
+		DefaultAction: FilterActionAllow,
+		Rules: []FilterRule{
+			{Pattern: "*.amazonaws.com", Action: FilterActionBlock, Methods: []string{"delete"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewFilterEngine: %v", err)
+	}
+
+	del := httptest.NewRequest(http.MethodDelete, "https://s3.amazonaws.com/bucket/key", nil)
+	if got := engine.Match(del, nil).Action; got != FilterActionBlock {
+		t.Errorf("DELETE = %s, want block", got)
+	}
+	get := httptest.NewRequest(http.MethodGet, "https://s3.amazonaws.com/bucket/key", nil)
+	if got := engine.Match(get, nil).Action; got != FilterActionAllow {
+		t.Errorf("GET = %s, want allow", got)
+	}
+}
+
+func TestFilterEngine_HeaderMatcher(t *testing.T) {
+	engine, err := NewFilterEngine(&FilterConfig{
+		DefaultAction: FilterActionAllow,
+		Rules: []FilterRule{
+			{Pattern: "api.example.com", Action: FilterActionBlock, Headers: map[string]string{
+				"content-type": "application/json*",
+				"X-Agent":      `^claude-.*$`,
+			}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewFilterEngine: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "https://api.example.com/v1", nil)
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("X-Agent", "claude-7")
+	if got := engine.Match(req, nil).Action; got != FilterActionBlock {
+		t.Errorf("all headers matching = %s, want block", got)
+	}
+
+	req.Header.Del("X-Agent")
+	if got := engine.Match(req, nil).Action; got != FilterActionAllow {
+		t.Errorf("missing header = %s, want allow", got)
+	}
+}
+
+func TestFilterEngine_BodyMatchers(t *testing.T) {
+	engine, err := NewFilterEngine(&FilterConfig{
+		DefaultAction: FilterActionAllow,
+		Rules: []FilterRule{{
+			Pattern: "api.openai.com",
+			Action:  FilterActionBlock,
+			Methods: []string{"POST"},
+			Body:    []FilterBodyMatch{{Path: "$.model", NotIn: []string{"gpt-4.1-mini", "gpt-4.1"}}},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("NewFilterEngine: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		body string
+		want FilterAction
+	}{
+		{"listed model", `{"model":"gpt-4.1"}`, FilterActionAllow},
+		{"unlisted model", `{"model":"o3"}`, FilterActionBlock},
+		{"missing field", `{"messages":[]}`, FilterActionBlock},
+		{"not json", `model=o3`, FilterActionBlock},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "https://api.openai.com/v1/chat/completions", nil)
+			if got := engine.Match(req, []byte(tt.body)).Action; got != tt.want {
+				t.Errorf("Match = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterEngine_BodyMatchersContentEncoding(t *testing.T) {
+	gzipped := func(s string) []byte {
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		_, _ = w.Write([]byte(s))
+		_ = w.Close()
+		return buf.Bytes()
+	}
+	deflated := func(s string) []byte {
+		var buf bytes.Buffer
+		w := zlib.NewWriter(&buf)
+		_, _ = w.Write([]byte(s))
+		_ = w.Close()
+		return buf.Bytes()
+	}
+
+	engine, err := NewFilterEngine(&FilterConfig{
+		DefaultAction: FilterActionAllow,
+		MaxBodyBytes:  1024,
+		Rules: []FilterRule{{
+			Pattern: "api.openai.com",
+			Action:  FilterActionBlock,
+			Body:    []FilterBodyMatch{{Path: "$.stream", In: []string{"true"}}},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("NewFilterEngine: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		encoding string
+		body     []byte
+		want     FilterAction
+	}{
+		{"gzip hit", "gzip", gzipped(`{"stream":true}`), FilterActionBlock},
+		{"gzip miss", "gzip", gzipped(`{"stream":false}`), FilterActionAllow},
+		{"x-gzip", "x-gzip", gzipped(`{"stream":true}`), FilterActionBlock},
+		{"deflate", "deflate", deflated(`{"stream":true}`), FilterActionBlock},
+		{"stacked codings", "deflate, gzip", gzipped(string(deflated(`{"stream":true}`))), FilterActionBlock},
+		{"identity", "identity", []byte(`{"stream":false}`), FilterActionAllow},
+		{"unsupported coding", "br", []byte(`{"stream":false}`), FilterActionBlock},
+		{"corrupt gzip", "gzip", []byte(`{"stream":false}`), FilterActionBlock},
+		{"expands past the limit", "gzip", gzipped(`{"stream":false,"pad":"` + strings.Repeat("a", 4096) + `"}`), FilterActionBlock},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "https://api.openai.com/v1/chat/completions", nil)
+			req.Header.Set("Content-Encoding", tt.encoding)
+			if got := engine.Match(req, tt.body).Action; got != tt.want {
+				t.Errorf("Match = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	// An undecodable body matches no allow rule: the default decides.
+	engine, err = NewFilterEngine(&FilterConfig{
+		DefaultAction: FilterActionBlock,
+		Rules: []FilterRule{{
+			Pattern: "api.openai.com",
+			Action:  FilterActionAllow,
+			Body:    []FilterBodyMatch{{Path: "$.model", In: []string{"gpt-4.1"}}},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("NewFilterEngine: %v", err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "https://api.openai.com/v1/chat/completions", nil)
+	req.Header.Set("Content-Encoding", "gzip")
+	if d := engine.Match(req, gzipped(`{"model":"gpt-4.1"}`)); d.Action != FilterActionAllow || d.IsDefault {
+		t.Errorf("gzip allow rule: %+v", d)
+	}
+	req.Header.Set("Content-Encoding", "br")
+	if d := engine.Match(req, []byte(`{"model":"gpt-4.1"}`)); d.Action != FilterActionBlock || !d.IsDefault {
+		t.Errorf("undecodable body under an allow rule: %+v, want the default", d)
+	}
+}
+
+func TestFilterEngine_BodyMatcherKinds(t *testing.T) {
+	tests := []struct {
+		name  string
+		match FilterBodyMatch
+		body  string
+		want  bool
+	}{
+		{"in hit", FilterBodyMatch{Path: "$.stream", In: []string{"true"}}, `{"stream":true}`, true},
+		{"in miss", FilterBodyMatch{Path: "$.stream", In: []string{"true"}}, `{"stream":false}`, false},
+		{"in missing field", FilterBodyMatch{Path: "$.stream", In: []string{"true"}}, `{}`, false},
+		{"pattern glob", FilterBodyMatch{Path: "$.user", Pattern: "agent-*"}, `{"user":"agent-7"}`, true},
+		{"pattern regex", FilterBodyMatch{Path: "$.user", Pattern: `^agent-\d+$`}, `{"user":"agent-x"}`, false},
+		{"exists", FilterBodyMatch{Path: "$.tools"}, `{"tools":[]}`, true},
+		{"not exists", FilterBodyMatch{Path: "$.tools"}, `{}`, false},
+		{"not_in any outside", FilterBodyMatch{Path: "$.messages[*].role", NotIn: []string{"user"}}, `{"messages":[{"role":"user"},{"role":"system"}]}`, true},
+		{"not_in all inside", FilterBodyMatch{Path: "$.messages[*].role", NotIn: []string{"user"}}, `{"messages":[{"role":"user"}]}`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			compiled, err := compileRule(FilterRule{Pattern: "*", Action: FilterActionBlock, Body: []FilterBodyMatch{tt.match}})
+			if err != nil {
+				t.Fatalf("compileRule: %v", err)
+			}
+			if got := compiled.matchesBody(&requestBody{raw: []byte(tt.body), limit: 1 << 20}); got != tt.want {
+				t.Errorf("matchesBody = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterEngine_NeedsBody(t *testing.T) {
+	engine, err := NewFilterEngine(&FilterConfig{
+		DefaultAction: FilterActionAllow,
+		Rules: []FilterRule{
+			{Pattern: "/v1/models", Scope: FilterScopePath, Action: FilterActionAllow},
+			{Pattern: "api.openai.com", Action: FilterActionBlock, Methods: []string{"POST"},
+				Body: []FilterBodyMatch{{Path: "$.model", NotIn: []string{"gpt-4.1"}}}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewFilterEngine: %v", err)
+	}
+
+	tests := []struct {
+		method, url string
+		want        bool
+	}{
+		{http.MethodPost, "https://api.openai.com/v1/chat/completions", true},
+		{http.MethodGet, "https://api.openai.com/v1/chat/completions", false},
+		// A body-free rule ahead of the body rule decides first.
+		{http.MethodPost, "https://api.openai.com/v1/models", false},
+		{http.MethodPost, "https://example.com/upload", false},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.url, nil)
+		if got := engine.NeedsBody(req); got != tt.want {
+			t.Errorf("NeedsBody(%s %s) = %v, want %v", tt.method, tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestFilterRule_ValidateRequestMatchers(t *testing.T) {
+	tests := []struct {
+		name string
+		rule FilterRule
+	}{
+		{"empty method", FilterRule{Pattern: "x", Action: FilterActionBlock, Methods: []string{""}}},
+		{"empty header name", FilterRule{Pattern: "x", Action: FilterActionBlock, Headers: map[string]string{"": "v"}}},
+		{"bad header regex", FilterRule{Pattern: "x", Action: FilterActionBlock, Headers: map[string]string{"X": "(unclosed"}}},
+		{"body without path", FilterRule{Pattern: "x", Action: FilterActionBlock, Body: []FilterBodyMatch{{In: []string{"a"}}}}},
+		{"body bad path", FilterRule{Pattern: "x", Action: FilterActionBlock, Body: []FilterBodyMatch{{Path: "$..a"}}}},
+		{"body two conditions", FilterRule{Pattern: "x", Action: FilterActionBlock,
+			Body: []FilterBodyMatch{{Path: "$.a", In: []string{"a"}, NotIn: []string{"b"}}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.rule.Validate(); err == nil {
+				t.Error("expected validation error")
+			}
+		})
+	}
+}
+
+// TestMatchDomain covers the resolver's view of the rules: it follows
+// MatchHost, except that a default block or ask yields to an allow rule that
+// decides per request and names the host, since refusing the name would leave
+// that rule unreachable.
+func TestMatchDomain(t *testing.T) {
+	tests := []struct {
+		name     string
+		rules    []FilterRule
+		host     string
+		hostless bool
+		def      FilterAction
+		want     FilterAction
+	}{
+		{
+			name:  "host allow rule",
+			rules: []FilterRule{{Pattern: "*.github.com", Action: FilterActionAllow}},
+			host:  "api.github.com",
+			want:  FilterActionAllow,
+		},
+		{
+			name:  "unmatched host falls to default",
+			rules: []FilterRule{{Pattern: "*.github.com", Action: FilterActionAllow}},
+			host:  "evil.example.com",
+			want:  FilterActionBlock,
+		},
+		{
+			name: "host block rule wins over a method-scoped allow",
+			rules: []FilterRule{
+				{Pattern: "api.example.com", Action: FilterActionBlock},
+				{Pattern: "api.example.com", Action: FilterActionAllow, Methods: []string{"GET"}},
+			},
+			host: "api.example.com",
+			want: FilterActionBlock,
+		},
+		{
+			name:  "method-scoped allow answers its own host",
+			rules: []FilterRule{{Pattern: "api.example.com", Action: FilterActionAllow, Methods: []string{"GET"}}},
+			host:  "API.Example.com.",
+			want:  FilterActionAllow,
+		},
+		{
+			name:  "method-scoped allow does not answer other hosts",
+			rules: []FilterRule{{Pattern: "api.example.com", Action: FilterActionAllow, Methods: []string{"GET"}}},
+			host:  "other.example.com",
+			want:  FilterActionBlock,
+		},
+		{
+			name:  "path-scoped allow names no host",
+			rules: []FilterRule{{Pattern: "/v1/*", Scope: FilterScopePath, Action: FilterActionAllow}},
+			host:  "anything.example.com",
+			want:  FilterActionBlock,
+		},
+		{
+			name:     "path-scoped allow answers any host when opted in",
+			rules:    []FilterRule{{Pattern: "/v1/*", Scope: FilterScopePath, Action: FilterActionAllow}},
+			host:     "anything.example.com",
+			hostless: true,
+			want:     FilterActionAllow,
+		},
+		{
+			name:  "url allow answers the host in its pattern",
+			rules: []FilterRule{{Pattern: "https://api.github.com/repos/**", Scope: FilterScopeURL, Action: FilterActionAllow}},
+			host:  "API.GitHub.com.",
+			want:  FilterActionAllow,
+		},
+		{
+			name:  "url allow does not answer an unrelated name",
+			rules: []FilterRule{{Pattern: "https://api.github.com/repos/**", Scope: FilterScopeURL, Action: FilterActionAllow}},
+			host:  "c2VjcmV0.attacker.example",
+			want:  FilterActionBlock,
+		},
+		{
+			name:  "url allow with a host glob and port",
+			rules: []FilterRule{{Pattern: "https://*.example.com:8443/v1/**", Scope: FilterScopeURL, Action: FilterActionAllow}},
+			host:  "api.example.com",
+			want:  FilterActionAllow,
+		},
+		{
+			name:  "url regex names no host",
+			rules: []FilterRule{{Pattern: `^https://api\.github\.com/`, Scope: FilterScopeURL, Action: FilterActionAllow}},
+			host:  "api.github.com",
+			want:  FilterActionBlock,
+		},
+		{
+			name:  "header allow on any host names no host",
+			rules: []FilterRule{{Pattern: "*", Action: FilterActionAllow, Headers: map[string]string{"X-Team": "infra"}}},
+			host:  "anything.example.com",
+			want:  FilterActionBlock,
+		},
+		{
+			name:  "default ask yields to a url allow naming the host",
+			rules: []FilterRule{{Pattern: "https://api.github.com/repos/**", Scope: FilterScopeURL, Action: FilterActionAllow}},
+			host:  "api.github.com",
+			def:   FilterActionAsk,
+			want:  FilterActionAllow,
+		},
+		{
+			name:  "default ask stays ask for other names",
+			rules: []FilterRule{{Pattern: "https://api.github.com/repos/**", Scope: FilterScopeURL, Action: FilterActionAllow}},
+			host:  "other.example.com",
+			def:   FilterActionAsk,
+			want:  FilterActionAsk,
+		},
+		{
+			name:  "port-scoped allow answers its own host",
+			rules: []FilterRule{{Pattern: "db.staging.example.com", Action: FilterActionAllow, Ports: []int{5432}}},
+			host:  "db.staging.example.com",
+			want:  FilterActionAllow,
+		},
+		{
+			name:  "path-scoped block does not answer",
+			rules: []FilterRule{{Pattern: "/v1/*", Scope: FilterScopePath, Action: FilterActionBlock}},
+			host:  "anything.example.com",
+			want:  FilterActionBlock,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			def := tt.def
+			if def == "" {
+				def = FilterActionBlock
+			}
+			engine, err := NewFilterEngine(&FilterConfig{DefaultAction: def, Rules: tt.rules})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := engine.MatchDomain(tt.host, tt.hostless); got.Action != tt.want {
+				t.Errorf("MatchDomain(%q) = %q (%s), want %q", tt.host, got.Action, got.Reason, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterEngine_PortMatcher(t *testing.T) {
+	engine, err := NewFilterEngine(&FilterConfig{
+		DefaultAction: FilterActionBlock,
+		Rules: []FilterRule{
+			{Pattern: "db.staging.example.com", Action: FilterActionAllow, Ports: []int{5432}},
+			{Pattern: "api.example.com", Action: FilterActionAllow, Ports: []int{443}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewFilterEngine: %v", err)
+	}
+
+	for _, tt := range []struct {
+		target string
+		want   FilterAction
+	}{
+		{"db.staging.example.com:5432", FilterActionAllow},
+		{"DB.Staging.Example.com.:5432", FilterActionAllow},
+		{"db.staging.example.com:22", FilterActionBlock},
+		{"db.staging.example.com", FilterActionBlock},
+		{"api.example.com:443", FilterActionAllow},
+	} {
+		if got := engine.MatchHost(tt.target).Action; got != tt.want {
+			t.Errorf("MatchHost(%q) = %s, want %s", tt.target, got, tt.want)
+		}
+	}
+
+	// An HTTP request without an explicit port is on its scheme's default.
+	https := httptest.NewRequest(http.MethodGet, "https://api.example.com/v1", nil)
+	if got := engine.Match(https, nil).Action; got != FilterActionAllow {
+		t.Errorf("https request = %s, want allow", got)
+	}
+	plain := httptest.NewRequest(http.MethodGet, "http://api.example.com/v1", nil)
+	if got := engine.Match(plain, nil).Action; got != FilterActionBlock {
+		t.Errorf("http request = %s, want block", got)
+	}
+}
+
+// TestFilterEngine_MatchSOCKS pins that with MITM on, a host rule without
+// ports cannot open raw TCP - an allow for a host's HTTPS API would
+// otherwise let a SOCKS client past everything the HTTP proxy inspects - and
+// that the ports the HTTP proxy carries stay on it unless a rule names them.
+func TestFilterEngine_MatchSOCKS(t *testing.T) {
+	engine, err := NewFilterEngine(&FilterConfig{
+		DefaultAction: FilterActionAllow,
+		Rules: []FilterRule{
+			{Pattern: "api.example.com", Action: FilterActionAllow},
+			{Pattern: "db.example.com", Action: FilterActionAllow, Ports: []int{5432, 443}},
+			{Pattern: "blocked.example.com", Action: FilterActionBlock},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewFilterEngine: %v", err)
+	}
+
+	for _, tt := range []struct {
+		target string
+		want   FilterAction
+	}{
+		{"api.example.com:443", FilterActionBlock},    // portless allow does not apply
+		{"api.example.com:80", FilterActionBlock},     // nor does the default
+		{"api.example.com:22", FilterActionAllow},     // default action
+		{"db.example.com:5432", FilterActionAllow},    // rule naming the port
+		{"db.example.com:443", FilterActionAllow},     // explicitly opted in
+		{"blocked.example.com:22", FilterActionBlock}, // a portless block still blocks
+		{"other.example.com:8443", FilterActionAllow},
+	} {
+		if got := engine.MatchSOCKS(tt.target).Action; got != tt.want {
+			t.Errorf("MatchSOCKS(%q) = %s, want %s", tt.target, got, tt.want)
+		}
+	}
+
+	// A host remembered from an HTTP request does not carry over to raw TCP.
+	if err := engine.AddSessionRule(FilterRule{Pattern: "learned.example.com", Action: FilterActionAllow, Scope: FilterScopeHost}); err != nil {
+		t.Fatal(err)
+	}
+	engine.CacheDecisionFor("cached.example.com:443", FilterActionAllow, time.Hour)
+	for _, target := range []string{"learned.example.com:443", "cached.example.com:443"} {
+		if got := engine.MatchSOCKS(target).Action; got != FilterActionBlock {
+			t.Errorf("MatchSOCKS(%q) = %s, want block", target, got)
+		}
+	}
+
+	disabled, err := NewFilterEngine(&FilterConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := disabled.MatchSOCKS("example.com:443").Action; got != FilterActionBlock {
+		t.Errorf("filtering off, port 443 = %s, want block", got)
+	}
+	if got := disabled.MatchSOCKS("example.com:5432").Action; got != FilterActionAllow {
+		t.Errorf("filtering off, port 5432 = %s, want allow", got)
+	}
+}
+
+func TestFilterRule_ValidatePorts(t *testing.T) {
+	for _, port := range []int{0, -1, 65536} {
+		rule := FilterRule{Pattern: "example.com", Action: FilterActionAllow, Ports: []int{port}}
+		if err := rule.Validate(); err == nil {
+			t.Errorf("port %d: expected error", port)
+		}
+	}
+	rule := FilterRule{Pattern: "example.com", Action: FilterActionAllow, Ports: []int{1, 65535}}
+	if err := rule.Validate(); err != nil {
+		t.Errorf("valid ports rejected: %v", err)
+	}
+}
+
+func TestFilterEngine_SessionRule(t *testing.T) {
+	engine, err := NewFilterEngine(&FilterConfig{
+		DefaultAction: FilterActionAsk,
+		Rules: []FilterRule{
+			{Pattern: "https://api.example.com/admin/**", Scope: FilterScopeURL, Action: FilterActionBlock},
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to create filter engine: %v", err)
+	}
+	if err := engine.AddSessionRule(FilterRule{Pattern: "https://api.example.com/**", Scope: FilterScopeURL, Type: PatternTypeGlob, Action: FilterActionAllow}); err != nil {
+		t.Fatalf("AddSessionRule: %v", err)
+	}
+	if err := engine.AddSessionRule(FilterRule{Pattern: "tracker.example.com", Type: PatternTypeExact, Action: FilterActionBlock}); err != nil {
+		t.Fatalf("AddSessionRule: %v", err)
+	}
+
+	match := func(raw string) FilterAction {
+		return engine.Match(httptest.NewRequest(http.MethodGet, raw, nil), nil).Action
+	}
+	if got := match("https://api.example.com/v1/users"); got != FilterActionAllow {
+		t.Errorf("request under the session rule = %s, want allow", got)
+	}
+	// Loaded rules keep their precedence over a decision made at a prompt.
+	if got := match("https://api.example.com/admin/users"); got != FilterActionBlock {
+		t.Errorf("explicitly blocked request = %s, want block", got)
+	}
+	if got := match("https://other.example.com/"); got != FilterActionAsk {
+		t.Errorf("uncovered request = %s, want ask", got)
+	}
+	if d := engine.MatchHost("tracker.example.com:443"); d.Action != FilterActionBlock {
+		t.Errorf("MatchHost on a host session rule = %s, want block", d.Action)
+	}
+	// A URL rule says nothing about a CONNECT target.
+	if d := engine.MatchHost("api.example.com:443"); d.Action != FilterActionAsk {
+		t.Errorf("MatchHost on a URL session rule's host = %s, want ask", d.Action)
+	}
+}
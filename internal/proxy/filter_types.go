@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"regexp"
 	"strings"
+
+	"devsandbox/internal/config"
 )
 
 // FilterAction represents the action to take for a request.
@@ -60,6 +62,94 @@ type FilterRule struct {
 
 	// Reason is an optional human-readable explanation shown when blocking.
 	Reason string `toml:"reason"`
+
+	// Methods restricts the rule to these HTTP methods (case-insensitive).
+	// Empty matches any method.
+	Methods []string `toml:"methods"`
+
+	// Headers restricts the rule to requests carrying every named header
+	// with a value matching its pattern (glob, or regex when auto-detected).
+	Headers map[string]string `toml:"headers"`
+
+	// Body restricts the rule to requests whose JSON body satisfies every
+	// matcher. Evaluating it buffers the body, bounded the same way the
+	// redaction scan is.
+	Body []FilterBodyMatch `toml:"body"`
+
+	// Ports restricts the rule to connections to these destination ports.
+	// An HTTP request without an explicit port is on its scheme's default.
+	// Empty matches any port.
+	Ports []int `toml:"ports"`
+}
+
+// FilterBodyMatch selects values from a JSON request body and tests them. At
+// most one of In, NotIn, and Pattern is set; with none, the matcher tests that
+// the path selects anything at all.
+type FilterBodyMatch struct {
+	// Path is a JSONPath expression (`$.model`, `$.messages[*].role`).
+	Path string `toml:"path"`
+
+	// In matches when any selected value is in the list.
+	In []string `toml:"in"`
+
+	// NotIn matches when any selected value is outside the list, when the
+	// path selects nothing, or when the body is not JSON - a rule meant to
+	// refuse everything but the listed values must not be sidestepped by
+	// leaving the field out.
+	NotIn []string `toml:"not_in"`
+
+	// Pattern matches when any selected value matches (glob, or regex when
+	// auto-detected).
+	Pattern string `toml:"pattern"`
+}
+
+// Validate checks a body matcher for errors.
+func (m *FilterBodyMatch) Validate() error {
+	if m.Path == "" {
+		return fmt.Errorf("path is required")
+	}
+	if _, err := compileJSONPath(m.Path); err != nil {
+		return err
+	}
+	set := 0
+	if len(m.In) > 0 {
+		set++
+	}
+	if len(m.NotIn) > 0 {
+		set++
+	}
+	if m.Pattern != "" {
+		set++
+	}
+	if set > 1 {
+		return fmt.Errorf("path %q: set at most one of in, not_in, and pattern", m.Path)
+	}
+	if m.Pattern != "" && detectPatternType(m.Pattern) == PatternTypeRegex {
+		if _, err := regexp.Compile(m.Pattern); err != nil {
+			return fmt.Errorf("path %q: invalid regex pattern: %w", m.Path, err)
+		}
+	}
+	return nil
+}
+
+// String renders the matcher for display.
+func (m FilterBodyMatch) String() string {
+	switch {
+	case len(m.In) > 0:
+		return fmt.Sprintf("%s in [%s]", m.Path, strings.Join(m.In, ", "))
+	case len(m.NotIn) > 0:
+		return fmt.Sprintf("%s not in [%s]", m.Path, strings.Join(m.NotIn, ", "))
+	case m.Pattern != "":
+		return fmt.Sprintf("%s matches %s", m.Path, m.Pattern)
+	}
+	return m.Path + " exists"
+}
+
+// HasRequestMatchers reports whether the rule matches on anything besides
+// its host/path/url pattern. Such a rule needs the request itself, so it
+// cannot be evaluated against a bare CONNECT.
+func (r *FilterRule) HasRequestMatchers() bool {
+	return len(r.Methods) > 0 || len(r.Headers) > 0 || len(r.Body) > 0
 }
 
 // FilterConfig holds the complete filter configuration.
@@ -80,8 +170,28 @@ type FilterConfig struct {
 	// Default: true
 	CacheDecisions *bool `toml:"cache_decisions"`
 
+	// AskNotifications raises a desktop notification for an ask-mode request
+	// no monitor is connected to answer.
+	// Default: true
+	AskNotifications *bool `toml:"ask_notifications"`
+
 	// Rules is the list of filter rules, evaluated in order.
 	Rules []FilterRule `toml:"rules"`
+
+	// MaxBodyBytes bounds a request body once its Content-Encoding is
+	// undone for body matchers. The server sets it to the redaction scan
+	// limit the raw body is read under, so a compressed body cannot expand
+	// past it. 0 → config.DefaultMaxRedactionScanBytes.
+	MaxBodyBytes int `toml:"-"`
+}
+
+// GetMaxBodyBytes returns the decoded body limit, defaulting to
+// config.DefaultMaxRedactionScanBytes when unset or non-positive.
+func (c *FilterConfig) GetMaxBodyBytes() int {
+	if c == nil || c.MaxBodyBytes <= 0 {
+		return config.DefaultMaxRedactionScanBytes
+	}
+	return c.MaxBodyBytes
 }
 
 // IsEnabled returns true if filtering is enabled.
@@ -97,6 +207,16 @@ func (c *FilterConfig) IsCacheEnabled() bool {
 	return *c.CacheDecisions
 }
 
+// IsAskNotificationsEnabled returns whether ask prompts fall back to desktop
+// notifications (default: true). A nil config - ask mode reached only through
+// [proxy.limits] - gets the default too.
+func (c *FilterConfig) IsAskNotificationsEnabled() bool {
+	if c == nil || c.AskNotifications == nil {
+		return true
+	}
+	return *c.AskNotifications
+}
+
 // GetDefaultAction returns the default action.
 func (c *FilterConfig) GetDefaultAction() FilterAction {
 	if c.DefaultAction == "" {
@@ -106,8 +226,10 @@ func (c *FilterConfig) GetDefaultAction() FilterAction {
 }
 
 // GetAskTimeout returns the ask timeout with a default of 30 seconds.
+// A nil config - ask mode reached only through [proxy.limits] - gets the
+// default too.
 func (c *FilterConfig) GetAskTimeout() int {
-	if c.AskTimeout <= 0 {
+	if c == nil || c.AskTimeout <= 0 {
 		return 30
 	}
 	return c.AskTimeout
@@ -186,6 +308,32 @@ func (r *FilterRule) Validate() error {
 		return fmt.Errorf("invalid type: %q (must be exact, glob, or regex)", r.Type)
 	}
 
+	for _, m := range r.Methods {
+		if m == "" {
+			return fmt.Errorf("methods cannot contain an empty method")
+		}
+	}
+	for name, pattern := range r.Headers {
+		if name == "" {
+			return fmt.Errorf("headers: header name cannot be empty")
+		}
+		if detectPatternType(pattern) == PatternTypeRegex {
+			if _, err := regexp.Compile(pattern); err != nil {
+				return fmt.Errorf("headers[%q]: invalid regex pattern: %w", name, err)
+			}
+		}
+	}
+	for i, m := range r.Body {
+		if err := m.Validate(); err != nil {
+			return fmt.Errorf("body[%d]: %w", i, err)
+		}
+	}
+	for _, port := range r.Ports {
+		if port < 1 || port > 65535 {
+			return fmt.Errorf("ports: %d is not a valid port", port)
+		}
+	}
+
 	// Try to compile the pattern
 	patternType := r.DetectPatternType()
 	if patternType == PatternTypeRegex {
@@ -202,11 +350,16 @@ func (r *FilterRule) DetectPatternType() PatternType {
 	if r.Type != "" {
 		return r.Type
 	}
+	return detectPatternType(r.Pattern)
+}
 
+// detectPatternType auto-detects a pattern without a declared type: regex
+// when it contains a regex metacharacter, glob otherwise.
+func detectPatternType(pattern string) PatternType {
 	// Check for regex indicators - if found, use regex
 	regexChars := []string{"^", "$", "|", "(", ")", "[", "]", "{", "}", "+", "\\"}
 	for _, ch := range regexChars {
-		if strings.Contains(r.Pattern, ch) {
+		if strings.Contains(pattern, ch) {
 			return PatternTypeRegex
 		}
 	}
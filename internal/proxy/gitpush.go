@@ -0,0 +1,272 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// GitPushConfig restricts what a git push over smart HTTP may change.
+// Enforcement is active when either list is non-empty.
+type GitPushConfig struct {
+	// AllowRefs lists the refs a push may create, update, or delete, as
+	// doublestar globs (`refs/heads/devsandbox/*`). A push touching any other
+	// ref is refused whole. Empty → every ref not protected below is allowed.
+	AllowRefs []string
+
+	// ProtectedRefs lists refs that may be created but never moved or deleted,
+	// even when AllowRefs matches them.
+	ProtectedRefs []string
+}
+
+// IsEnabled returns true if any push restriction is configured.
+func (c *GitPushConfig) IsEnabled() bool {
+	return c != nil && (len(c.AllowRefs) > 0 || len(c.ProtectedRefs) > 0)
+}
+
+// Validate checks the git push configuration for errors.
+func (c *GitPushConfig) Validate() error {
+	if c == nil {
+		return nil
+	}
+	for i, p := range c.AllowRefs {
+		if err := validateRefPattern(p); err != nil {
+			return fmt.Errorf("allow_refs[%d]: %w", i, err)
+		}
+	}
+	for i, p := range c.ProtectedRefs {
+		if err := validateRefPattern(p); err != nil {
+			return fmt.Errorf("protected_refs[%d]: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func validateRefPattern(p string) error {
+	if p == "" {
+		return errors.New("pattern cannot be empty")
+	}
+	if !doublestar.ValidatePattern(p) {
+		return fmt.Errorf("invalid glob pattern %q", p)
+	}
+	return nil
+}
+
+// maxGitPushCommandBytes bounds how much of a receive-pack request is read to
+// find its ref updates. The updates come before the pack, one pkt-line each,
+// so this is a cap on the number of refs in one push, not on the push size.
+const maxGitPushCommandBytes = 1 << 20
+
+// gitPushReadTimeout bounds how long the proxy waits for the ref updates of a
+// receive-pack request to arrive. Same rationale as
+// defaultRedactionScanTimeout: the proxy runs outside the sandbox's limits, so
+// a client that stops sending must not hold a handler goroutine open.
+const gitPushReadTimeout = 30 * time.Second
+
+// RefUpdate is one ref update command of a git push: the ref moves from Old
+// to New. An all-zero Old creates the ref; an all-zero New deletes it.
+type RefUpdate struct {
+	Old string
+	New string
+	Ref string
+}
+
+// IsCreate reports whether the update creates a ref that did not exist.
+func (u RefUpdate) IsCreate() bool { return isZeroOID(u.Old) }
+
+// IsDelete reports whether the update deletes the ref.
+func (u RefUpdate) IsDelete() bool { return isZeroOID(u.New) }
+
+func isZeroOID(oid string) bool {
+	return strings.Trim(oid, "0") == ""
+}
+
+// GitPushPolicy decides whether the ref updates of a git push may proceed.
+type GitPushPolicy struct {
+	allow     []string
+	protected []string
+}
+
+// NewGitPushPolicy creates a policy from cfg. A nil or empty config returns a
+// policy that is not enabled.
+func NewGitPushPolicy(cfg *GitPushConfig) (*GitPushPolicy, error) {
+	if !cfg.IsEnabled() {
+		return &GitPushPolicy{}, nil
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid git_push config: %w", err)
+	}
+	return &GitPushPolicy{
+		allow:     append([]string(nil), cfg.AllowRefs...),
+		protected: append([]string(nil), cfg.ProtectedRefs...),
+	}, nil
+}
+
+// IsEnabled returns true if the policy restricts anything.
+func (p *GitPushPolicy) IsEnabled() bool {
+	return p != nil && (len(p.allow) > 0 || len(p.protected) > 0)
+}
+
+// Check returns whether every update may proceed, with the reason recorded in
+// the request log. One refused update refuses the push: receive-pack may
+// apply the rest otherwise, and a half-applied push is harder to reason about
+// than a refused one.
+//
+// A protected ref is refused for any update but its creation. The proxy sees
+// the old and new object IDs, not the remote's history, so a fast-forward and
+// a force push look the same; refusing both is the only way to guarantee the
+// ref is never rewritten.
+func (p *GitPushPolicy) Check(updates []RefUpdate) (bool, string) {
+	if len(updates) == 0 {
+		return true, "git push: no ref updates"
+	}
+	for _, u := range updates {
+		if len(p.allow) > 0 && !matchAnyRef(p.allow, u.Ref) {
+			return false, fmt.Sprintf("git push to %s refused: ref not in proxy.git_push.allow_refs", u.Ref)
+		}
+		if matchAnyRef(p.protected, u.Ref) {
+			switch {
+			case u.IsDelete():
+				return false, fmt.Sprintf("git push refused: %s is protected and cannot be deleted", u.Ref)
+			case !u.IsCreate():
+				return false, fmt.Sprintf("git push refused: %s is protected and cannot be moved (force pushes cannot be told apart from fast-forwards)", u.Ref)
+			}
+		}
+	}
+	refs := make([]string, len(updates))
+	for i, u := range updates {
+		refs[i] = u.Ref
+	}
+	return true, "git push allowed: " + strings.Join(refs, ", ")
+}
+
+func matchAnyRef(patterns []string, ref string) bool {
+	for _, p := range patterns {
+		if ok, _ := doublestar.Match(p, ref); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// isGitReceivePack reports whether req is the POST that carries a git push
+// over smart HTTP. It keys on the path, not the Content-Type: the header is
+// the client's to omit, and a push it carries without one must not slip past.
+func isGitReceivePack(req *http.Request) bool {
+	return req.Method == http.MethodPost && req.URL != nil &&
+		strings.HasSuffix(req.URL.Path, "/git-receive-pack")
+}
+
+// ReadRefUpdates reads the ref update commands at the head of a receive-pack
+// request, bounded in bytes and time, and leaves req.Body replaying them ahead
+// of the pack so the request stays forwardable. The pack itself is never
+// buffered.
+func (p *GitPushPolicy) ReadRefUpdates(req *http.Request) ([]RefUpdate, error) {
+	if req.Body == nil {
+		return nil, nil
+	}
+	if enc := req.Header.Get("Content-Encoding"); enc != "" && enc != "identity" {
+		return nil, fmt.Errorf("unsupported Content-Encoding %q", enc)
+	}
+
+	type result struct {
+		updates []RefUpdate
+		err     error
+	}
+	var consumed bytes.Buffer
+	body := req.Body
+	done := make(chan result, 1)
+	go func() {
+		r := io.TeeReader(io.LimitReader(body, maxGitPushCommandBytes), &consumed)
+		updates, err := parseRefUpdates(r)
+		done <- result{updates: updates, err: err}
+	}()
+
+	timer := time.NewTimer(gitPushReadTimeout)
+	defer timer.Stop()
+
+	// As in ReadScanBody, leaving early abandons the read goroutine until
+	// net/http closes the body behind the denial.
+	select {
+	case r := <-done:
+		if r.err != nil {
+			return nil, r.err
+		}
+		req.Body = readCloser{io.MultiReader(bytes.NewReader(consumed.Bytes()), body), body}
+		return r.updates, nil
+	case <-req.Context().Done():
+		return nil, req.Context().Err()
+	case <-timer.C:
+		return nil, fmt.Errorf("timed out after %s reading ref updates", gitPushReadTimeout)
+	}
+}
+
+// readCloser replays a reader while closing the original body.
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// parseRefUpdates reads pkt-lines up to the flush packet that ends the
+// command list of a receive-pack request. The first command carries the
+// client capabilities after a NUL; shallow lines may precede the commands.
+func parseRefUpdates(r io.Reader) ([]RefUpdate, error) {
+	var updates []RefUpdate
+	header := make([]byte, 4)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			return nil, fmt.Errorf("reading pkt-line: %w", err)
+		}
+		n, err := strconv.ParseUint(string(header), 16, 16)
+		if err != nil {
+			return nil, fmt.Errorf("malformed pkt-line length %q", header)
+		}
+		if n == 0 {
+			return updates, nil
+		}
+		if n < 4 {
+			return nil, fmt.Errorf("unexpected pkt-line %q in ref updates", header)
+		}
+		line := make([]byte, n-4)
+		if _, err := io.ReadFull(r, line); err != nil {
+			return nil, fmt.Errorf("reading pkt-line: %w", err)
+		}
+		cmd, _, _ := strings.Cut(strings.TrimSuffix(string(line), "\n"), "\x00")
+		if strings.HasPrefix(cmd, "shallow ") {
+			continue
+		}
+		if strings.HasPrefix(cmd, "push-cert") {
+			return nil, errors.New("signed pushes are not supported")
+		}
+		u, err := parseRefUpdate(cmd)
+		if err != nil {
+			return nil, err
+		}
+		updates = append(updates, u)
+	}
+}
+
+func parseRefUpdate(cmd string) (RefUpdate, error) {
+	fields := strings.SplitN(cmd, " ", 3)
+	if len(fields) != 3 || !isOID(fields[0]) || !isOID(fields[1]) || fields[2] == "" {
+		return RefUpdate{}, fmt.Errorf("malformed ref update %q", cmd)
+	}
+	return RefUpdate{Old: fields[0], New: fields[1], Ref: fields[2]}, nil
+}
+
+// isOID accepts SHA-1 and SHA-256 object IDs.
+func isOID(s string) bool {
+	if len(s) != 40 && len(s) != 64 {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
@@ -0,0 +1,299 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	zeroOID = "0000000000000000000000000000000000000000"
+	oidA    = "1111111111111111111111111111111111111111"
+	oidB    = "2222222222222222222222222222222222222222"
+)
+
+// pktLine encodes s as one git pkt-line.
+func pktLine(s string) string {
+	return fmt.Sprintf("%04x%s", len(s)+4, s)
+}
+
+// receivePackBody builds a receive-pack request body: the ref update commands,
+// a flush packet, and a stand-in for the pack.
+func receivePackBody(cmds ...string) string {
+	var b strings.Builder
+	for i, c := range cmds {
+		if i == 0 {
+			c += "\x00report-status side-band-64k agent=git/2.45.0"
+		}
+		b.WriteString(pktLine(c + "\n"))
+	}
+	b.WriteString("0000")
+	b.WriteString("PACK\x00\x00\x00\x02fake-pack-data")
+	return b.String()
+}
+
+func TestParseRefUpdates(t *testing.T) {
+	t.Run("commands up to flush", func(t *testing.T) {
+		body := receivePackBody(
+			oidA+" "+oidB+" refs/heads/devsandbox/feature",
+			zeroOID+" "+oidB+" refs/tags/v1",
+		)
+		got, err := parseRefUpdates(strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("parseRefUpdates: %v", err)
+		}
+		want := []RefUpdate{
+			{Old: oidA, New: oidB, Ref: "refs/heads/devsandbox/feature"},
+			{Old: zeroOID, New: oidB, Ref: "refs/tags/v1"},
+		}
+		if len(got) != len(want) {
+			t.Fatalf("got %d updates, want %d: %+v", len(got), len(want), got)
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("update %d = %+v, want %+v", i, got[i], want[i])
+			}
+		}
+	})
+
+	t.Run("shallow lines skipped", func(t *testing.T) {
+		body := pktLine("shallow "+oidA) + pktLine(oidA+" "+oidB+" refs/heads/x\x00caps") + "0000"
+		got, err := parseRefUpdates(strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("parseRefUpdates: %v", err)
+		}
+		if len(got) != 1 || got[0].Ref != "refs/heads/x" {
+			t.Errorf("got %+v", got)
+		}
+	})
+
+	t.Run("sha256 object ids", func(t *testing.T) {
+		old := strings.Repeat("a", 64)
+		body := pktLine(old+" "+strings.Repeat("b", 64)+" refs/heads/x") + "0000"
+		if _, err := parseRefUpdates(strings.NewReader(body)); err != nil {
+			t.Errorf("parseRefUpdates: %v", err)
+		}
+	})
+
+	for name, body := range map[string]string{
+		"truncated":        pktLine(oidA + " " + oidB + " refs/heads/x"),
+		"bad length":       "zzzz",
+		"short length":     "0003",
+		"malformed update": pktLine("not a command") + "0000",
+		"short oid":        pktLine("abc "+oidB+" refs/heads/x") + "0000",
+		"signed push":      pktLine("push-cert\x00caps") + "0000",
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := parseRefUpdates(strings.NewReader(body)); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+func TestGitPushPolicy_Check(t *testing.T) {
+	policy, err := NewGitPushPolicy(&GitPushConfig{
+		AllowRefs:     []string{"refs/heads/devsandbox/**", "refs/heads/main"},
+		ProtectedRefs: []string{"refs/heads/main"},
+	})
+	if err != nil {
+		t.Fatalf("NewGitPushPolicy: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		updates []RefUpdate
+		allowed bool
+		reason  string
+	}{
+		{"allowed branch update", []RefUpdate{{oidA, oidB, "refs/heads/devsandbox/a"}}, true, "refs/heads/devsandbox/a"},
+		{"nested allowed branch", []RefUpdate{{oidA, oidB, "refs/heads/devsandbox/a/b"}}, true, ""},
+		{"allowed branch delete", []RefUpdate{{oidA, zeroOID, "refs/heads/devsandbox/a"}}, true, ""},
+		{"ref outside allowlist", []RefUpdate{{oidA, oidB, "refs/tags/v1"}}, false, "not in proxy.git_push.allow_refs"},
+		{"protected create", []RefUpdate{{zeroOID, oidB, "refs/heads/main"}}, true, ""},
+		{"protected move", []RefUpdate{{oidA, oidB, "refs/heads/main"}}, false, "cannot be moved"},
+		{"protected delete", []RefUpdate{{oidA, zeroOID, "refs/heads/main"}}, false, "cannot be deleted"},
+		{"one bad update refuses all", []RefUpdate{
+			{oidA, oidB, "refs/heads/devsandbox/a"},
+			{oidA, oidB, "refs/heads/other"},
+		}, false, "refs/heads/other"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			allowed, reason := policy.Check(tt.updates)
+			if allowed != tt.allowed {
+				t.Errorf("allowed = %v, want %v (reason %q)", allowed, tt.allowed, reason)
+			}
+			if !strings.Contains(reason, tt.reason) {
+				t.Errorf("reason %q does not mention %q", reason, tt.reason)
+			}
+		})
+	}
+}
+
+func TestGitPushPolicy_ProtectedOnly(t *testing.T) {
+	policy, err := NewGitPushPolicy(&GitPushConfig{ProtectedRefs: []string{"refs/tags/**"}})
+	if err != nil {
+		t.Fatalf("NewGitPushPolicy: %v", err)
+	}
+	if ok, reason := policy.Check([]RefUpdate{{oidA, oidB, "refs/heads/anything"}}); !ok {
+		t.Errorf("unprotected ref refused without an allowlist: %s", reason)
+	}
+	if ok, _ := policy.Check([]RefUpdate{{oidA, oidB, "refs/tags/v1"}}); ok {
+		t.Error("moving a protected tag was allowed")
+	}
+}
+
+func TestGitPushConfig_Validate(t *testing.T) {
+	if _, err := NewGitPushPolicy(&GitPushConfig{AllowRefs: []string{""}}); err == nil {
+		t.Error("expected error for empty pattern")
+	}
+	if _, err := NewGitPushPolicy(&GitPushConfig{ProtectedRefs: []string{"refs/heads/[main"}}); err == nil {
+		t.Error("expected error for invalid glob")
+	}
+	policy, err := NewGitPushPolicy(nil)
+	if err != nil {
+		t.Fatalf("NewGitPushPolicy(nil): %v", err)
+	}
+	if policy.IsEnabled() {
+		t.Error("nil config produced an enabled policy")
+	}
+}
+
+func TestIsGitReceivePack(t *testing.T) {
+	tests := []struct {
+		method, url string
+		want        bool
+	}{
+		{http.MethodPost, "https://github.com/o/r.git/git-receive-pack", true},
+		{http.MethodPost, "https://github.com/o/r/git-receive-pack", true},
+		{http.MethodPost, "https://github.com/o/r.git/git-upload-pack", false},
+		{http.MethodGet, "https://github.com/o/r.git/info/refs?service=git-receive-pack", false},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.url, nil)
+		if got := isGitReceivePack(req); got != tt.want {
+			t.Errorf("isGitReceivePack(%s %s) = %v, want %v", tt.method, tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestGitPushPolicy_ReadRefUpdatesReplaysBody(t *testing.T) {
+	policy, _ := NewGitPushPolicy(&GitPushConfig{AllowRefs: []string{"refs/heads/*"}})
+	body := receivePackBody(oidA + " " + oidB + " refs/heads/x")
+	req := httptest.NewRequest(http.MethodPost, "http://example.com/r.git/git-receive-pack", strings.NewReader(body))
+
+	updates, err := policy.ReadRefUpdates(req)
+	if err != nil {
+		t.Fatalf("ReadRefUpdates: %v", err)
+	}
+	if len(updates) != 1 {
+		t.Fatalf("got %d updates, want 1", len(updates))
+	}
+	replayed, _ := io.ReadAll(req.Body)
+	if string(replayed) != body {
+		t.Errorf("body not replayed whole:\n got %q\nwant %q", replayed, body)
+	}
+}
+
+func TestGitPushPolicy_ReadRefUpdatesRefusesEncodedBody(t *testing.T) {
+	policy, _ := NewGitPushPolicy(&GitPushConfig{AllowRefs: []string{"refs/heads/*"}})
+	req := httptest.NewRequest(http.MethodPost, "http://example.com/r.git/git-receive-pack", strings.NewReader("x"))
+	req.Header.Set("Content-Encoding", "gzip")
+	if _, err := policy.ReadRefUpdates(req); err == nil {
+		t.Error("expected error for gzip-encoded body")
+	}
+}
+
+func TestNewServer_GitPushRequiresMITM(t *testing.T) {
+	cfg := NewConfig(t.TempDir(), 0)
+	cfg.MITM = false
+	cfg.GitPush = &GitPushConfig{AllowRefs: []string{"refs/heads/*"}}
+	if _, err := NewServer(cfg); !errors.Is(err, ErrUnenforceableFilterScope) {
+		t.Errorf("NewServer error = %v, want ErrUnenforceableFilterScope", err)
+	}
+}
+
+func TestServer_GitPushPolicy(t *testing.T) {
+	tests := []struct {
+		name       string
+		ref        string
+		wantStatus int
+		wantAction string
+		wantReason string
+	}{
+		{"allowed ref forwarded", "refs/heads/devsandbox/work", http.StatusOK, "allow", "git push allowed"},
+		{"protected ref refused", "refs/heads/main", http.StatusForbidden, "block", "not in proxy.git_push.allow_refs"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			received := make(chan string, 1)
+			upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				b, _ := io.ReadAll(r.Body)
+				received <- string(b)
+				w.WriteHeader(http.StatusOK)
+			}))
+			defer upstream.Close()
+
+			tmpDir := t.TempDir()
+			cfg := NewConfig(tmpDir, 0)
+			cfg.GitPush = &GitPushConfig{
+				AllowRefs:     []string{"refs/heads/devsandbox/*"},
+				ProtectedRefs: []string{"refs/heads/main"},
+			}
+			proxyServer, err := NewServer(cfg)
+			if err != nil {
+				t.Fatalf("NewServer failed: %v", err)
+			}
+			if err := proxyServer.Start(); err != nil {
+				t.Fatalf("Start failed: %v", err)
+			}
+			defer func() { _ = proxyServer.Stop() }()
+
+			proxyURL, _ := url.Parse(fmt.Sprintf("http://%s", proxyServer.Addr()))
+			client := &http.Client{
+				Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)},
+				Timeout:   10 * time.Second,
+			}
+
+			body := receivePackBody(oidA + " " + oidB + " " + tt.ref)
+			resp, err := client.Post(upstream.URL+"/repo.git/git-receive-pack",
+				"application/x-git-receive-pack-request", strings.NewReader(body))
+			if err != nil {
+				t.Fatalf("request through proxy failed: %v", err)
+			}
+			_, _ = io.ReadAll(resp.Body)
+			_ = resp.Body.Close()
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusOK {
+				if got := <-received; got != body {
+					t.Errorf("upstream received %q, want the whole push %q", got, body)
+				}
+			} else {
+				select {
+				case <-received:
+					t.Error("refused push reached upstream")
+				default:
+				}
+			}
+
+			entry := waitForLoggedEntry(t, filepath.Join(tmpDir, LogBaseDirName, ProxyLogDirName))
+			if entry.FilterAction != tt.wantAction {
+				t.Errorf("filter_action = %q, want %q", entry.FilterAction, tt.wantAction)
+			}
+			if !strings.Contains(entry.FilterReason, tt.wantReason) {
+				t.Errorf("filter_reason = %q, want it to mention %q", entry.FilterReason, tt.wantReason)
+			}
+		})
+	}
+}
@@ -0,0 +1,271 @@
+package proxy
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// HAR is an HTTP Archive 1.2 document, the format browser devtools, Charles
+// and most HTTP debugging tools import and export. Only the parts a
+// RequestLog can fill are modelled; what HAR has no field for - truncation,
+// filter decisions, errors - goes in "_"-prefixed custom fields, which the
+// format reserves for that.
+type HAR struct {
+	Log HARLog `json:"log"`
+}
+
+// HARLog is the archive's root object.
+type HARLog struct {
+	Version string     `json:"version"`
+	Creator HARCreator `json:"creator"`
+	Entries []HAREntry `json:"entries"`
+}
+
+// HARCreator names the tool that wrote the archive.
+type HARCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// HAREntry is one request and its response.
+type HAREntry struct {
+	StartedDateTime time.Time   `json:"startedDateTime"`
+	Time            float64     `json:"time"` // total, in milliseconds
+	Request         HARRequest  `json:"request"`
+	Response        HARResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         HARTimings  `json:"timings"`
+
+	ID           string `json:"_id,omitempty"` // the log entry's, for `logs proxy replay`
+	Error        string `json:"_error,omitempty"`
+	FilterAction string `json:"_filterAction,omitempty"`
+	FilterReason string `json:"_filterReason,omitempty"`
+}
+
+// HARRequest is an entry's request. BodySize is -1 when the recorded body was
+// cut short and the real size is unknown.
+type HARRequest struct {
+	Method           string         `json:"method"`
+	URL              string         `json:"url"`
+	HTTPVersion      string         `json:"httpVersion"`
+	Cookies          []HARNameValue `json:"cookies"`
+	Headers          []HARNameValue `json:"headers"`
+	QueryString      []HARNameValue `json:"queryString"`
+	PostData         *HARPostData   `json:"postData,omitempty"`
+	HeadersSize      int            `json:"headersSize"`
+	BodySize         int            `json:"bodySize"`
+	HeadersTruncated bool           `json:"_headersTruncated,omitempty"`
+}
+
+// HARResponse is an entry's response. A request that never got one - blocked
+// by the filter, or failed upstream - has status 0, as browsers record it.
+type HARResponse struct {
+	Status           int            `json:"status"`
+	StatusText       string         `json:"statusText"`
+	HTTPVersion      string         `json:"httpVersion"`
+	Cookies          []HARNameValue `json:"cookies"`
+	Headers          []HARNameValue `json:"headers"`
+	Content          HARContent     `json:"content"`
+	RedirectURL      string         `json:"redirectURL"`
+	HeadersSize      int            `json:"headersSize"`
+	BodySize         int            `json:"bodySize"`
+	HeadersTruncated bool           `json:"_headersTruncated,omitempty"`
+}
+
+// HARNameValue is a header, cookie or query parameter.
+type HARNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// HARPostData is a request body. HAR has no encoding field here, unlike
+// HARContent, so a body that is not UTF-8 is marked with a custom one.
+type HARPostData struct {
+	MimeType  string `json:"mimeType"`
+	Text      string `json:"text"`
+	Encoding  string `json:"_encoding,omitempty"`
+	Truncated bool   `json:"_truncated,omitempty"`
+}
+
+// HARContent is a response body.
+type HARContent struct {
+	Size      int    `json:"size"`
+	MimeType  string `json:"mimeType"`
+	Text      string `json:"text,omitempty"`
+	Encoding  string `json:"encoding,omitempty"`
+	Truncated bool   `json:"_truncated,omitempty"`
+}
+
+// HARTimings splits an entry's time. The proxy measures only the whole
+// round trip, so it is all put down as waiting; the phases HAR allows to be
+// unknown are -1.
+type HARTimings struct {
+	Blocked float64 `json:"blocked"`
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	SSL     float64 `json:"ssl"`
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+// NewHAR builds an archive of entries, naming version as the creator's.
+func NewHAR(entries []RequestLog, version string) *HAR {
+	h := &HAR{Log: HARLog{
+		Version: "1.2",
+		Creator: HARCreator{Name: "devsandbox", Version: version},
+		Entries: make([]HAREntry, 0, len(entries)),
+	}}
+	for i := range entries {
+		h.Log.Entries = append(h.Log.Entries, harEntry(&entries[i]))
+	}
+	return h
+}
+
+func harEntry(e *RequestLog) HAREntry {
+	ms := float64(e.Duration) / float64(time.Millisecond)
+	entry := HAREntry{
+		StartedDateTime: e.Timestamp,
+		Time:            ms,
+		Request: HARRequest{
+			Method:           e.Method,
+			URL:              e.URL,
+			Cookies:          []HARNameValue{},
+			Headers:          harHeaders(e.RequestHeaders),
+			QueryString:      harQuery(e.URL),
+			HeadersSize:      -1,
+			BodySize:         len(e.RequestBody),
+			HeadersTruncated: e.RequestHeadersTruncated,
+		},
+		Response: HARResponse{
+			Status:           e.StatusCode,
+			StatusText:       http.StatusText(e.StatusCode),
+			Cookies:          []HARNameValue{},
+			Headers:          harHeaders(e.ResponseHeaders),
+			RedirectURL:      firstHeader(e.ResponseHeaders, "Location"),
+			HeadersSize:      -1,
+			BodySize:         len(e.ResponseBody),
+			HeadersTruncated: e.ResponseHeadersTruncated,
+			Content: HARContent{
+				Size:      len(e.ResponseBody),
+				MimeType:  firstHeader(e.ResponseHeaders, "Content-Type"),
+				Truncated: e.ResponseBodyTruncated,
+			},
+		},
+		Timings:      HARTimings{Blocked: -1, DNS: -1, Connect: -1, SSL: -1, Wait: ms},
+		ID:           e.LogID(),
+		Error:        e.Error,
+		FilterAction: e.FilterAction,
+		FilterReason: e.FilterReason,
+	}
+
+	if len(e.RequestBody) > 0 || e.RequestBodyTruncated {
+		text, encoding := harText(e.RequestBody)
+		entry.Request.PostData = &HARPostData{
+			MimeType:  firstHeader(e.RequestHeaders, "Content-Type"),
+			Text:      text,
+			Encoding:  encoding,
+			Truncated: e.RequestBodyTruncated,
+		}
+	}
+	if e.RequestBodyTruncated {
+		entry.Request.BodySize = -1
+	}
+	if e.ResponseBodyTruncated {
+		entry.Response.BodySize = -1
+	}
+	entry.Response.Content.Text, entry.Response.Content.Encoding = harText(e.ResponseBody)
+	return entry
+}
+
+// harHeaders flattens headers into name/value pairs, sorted by name so the
+// same log always gives the same archive.
+func harHeaders(headers map[string][]string) []HARNameValue {
+	pairs := []HARNameValue{}
+	for _, name := range slices.Sorted(maps.Keys(headers)) {
+		for _, v := range headers[name] {
+			pairs = append(pairs, HARNameValue{Name: name, Value: v})
+		}
+	}
+	return pairs
+}
+
+// harQuery lists rawURL's query parameters in the order they appear.
+func harQuery(rawURL string) []HARNameValue {
+	pairs := []HARNameValue{}
+	u, err := url.Parse(rawURL)
+	if err != nil || u.RawQuery == "" {
+		return pairs
+	}
+	for kv := range strings.SplitSeq(u.RawQuery, "&") {
+		name, value, _ := strings.Cut(kv, "=")
+		if n, err := url.QueryUnescape(name); err == nil {
+			name = n
+		}
+		if v, err := url.QueryUnescape(value); err == nil {
+			value = v
+		}
+		pairs = append(pairs, HARNameValue{Name: name, Value: value})
+	}
+	return pairs
+}
+
+// firstHeader returns the first value of a header, matching the name
+// case-insensitively as the logged map keeps names as they arrived.
+func firstHeader(headers map[string][]string, name string) string {
+	for k, vs := range headers {
+		if strings.EqualFold(k, name) && len(vs) > 0 {
+			return vs[0]
+		}
+	}
+	return ""
+}
+
+// harText returns body as HAR text: as is when it is UTF-8, base64 otherwise.
+func harText(body []byte) (text, encoding string) {
+	if utf8.Valid(body) {
+		return string(body), ""
+	}
+	return base64.StdEncoding.EncodeToString(body), "base64"
+}
+
+// ReadHAR decodes an HTTP Archive, as written by browsers and other tools.
+func ReadHAR(r io.Reader) (*HAR, error) {
+	var raw struct {
+		Log *struct {
+			Version string            `json:"version"`
+			Creator HARCreator        `json:"creator"`
+			Entries []json.RawMessage `json:"entries"`
+		} `json:"log"`
+	}
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return nil, fmt.Errorf("invalid HAR: %w", err)
+	}
+	if raw.Log == nil {
+		return nil, errors.New("invalid HAR: no log object")
+	}
+
+	h := &HAR{Log: HARLog{
+		Version: raw.Log.Version,
+		Creator: raw.Log.Creator,
+		Entries: make([]HAREntry, 0, len(raw.Log.Entries)),
+	}}
+	for i, data := range raw.Log.Entries {
+		var entry HAREntry
+		if err := json.Unmarshal(data, &entry); err != nil {
+			return nil, fmt.Errorf("invalid HAR entry %d: %w", i, err)
+		}
+		h.Log.Entries = append(h.Log.Entries, entry)
+	}
+	return h, nil
+}
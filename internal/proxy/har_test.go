@@ -0,0 +1,129 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewHAR(t *testing.T) {
+	start := time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)
+	entries := []RequestLog{
+		{
+			Timestamp:                start,
+			Method:                   "POST",
+			URL:                      "https://api.example.com/v1/items?q=a%20b&q=c&flag",
+			RequestHeaders:           map[string][]string{"content-type": {"application/json"}, "Accept": {"*/*"}},
+			RequestBody:              []byte(`{"name":`),
+			RequestBodyTruncated:     true,
+			StatusCode:               201,
+			ResponseHeaders:          map[string][]string{"Content-Type": {"application/octet-stream"}, "Location": {"/v1/items/1"}},
+			ResponseHeadersTruncated: true,
+			ResponseBody:             []byte{0xff, 0x00},
+			Duration:                 1500 * time.Microsecond,
+		},
+		{
+			Timestamp:    start.Add(time.Second),
+			Method:       "GET",
+			URL:          "https://blocked.example.com/",
+			Error:        "blocked by filter",
+			FilterAction: "block",
+			FilterReason: "default action",
+		},
+	}
+
+	h := NewHAR(entries, "1.2.3")
+	if h.Log.Version != "1.2" || h.Log.Creator != (HARCreator{Name: "devsandbox", Version: "1.2.3"}) {
+		t.Errorf("log = %q by %+v", h.Log.Version, h.Log.Creator)
+	}
+	if len(h.Log.Entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(h.Log.Entries))
+	}
+
+	e := h.Log.Entries[0]
+	if !e.StartedDateTime.Equal(start) || e.Time != 1.5 || e.Timings.Wait != 1.5 || e.Timings.DNS != -1 {
+		t.Errorf("timing: started %v, time %v, timings %+v", e.StartedDateTime, e.Time, e.Timings)
+	}
+	wantHeaders := []HARNameValue{{Name: "Accept", Value: "*/*"}, {Name: "content-type", Value: "application/json"}}
+	if !reflect.DeepEqual(e.Request.Headers, wantHeaders) {
+		t.Errorf("request headers = %+v", e.Request.Headers)
+	}
+	wantQuery := []HARNameValue{{Name: "q", Value: "a b"}, {Name: "q", Value: "c"}, {Name: "flag", Value: ""}}
+	if !reflect.DeepEqual(e.Request.QueryString, wantQuery) {
+		t.Errorf("query = %+v", e.Request.QueryString)
+	}
+	wantPost := &HARPostData{MimeType: "application/json", Text: `{"name":`, Truncated: true}
+	if !reflect.DeepEqual(e.Request.PostData, wantPost) || e.Request.BodySize != -1 {
+		t.Errorf("post data = %+v, body size %d", e.Request.PostData, e.Request.BodySize)
+	}
+	if e.Response.Status != 201 || e.Response.StatusText != "Created" || e.Response.RedirectURL != "/v1/items/1" || !e.Response.HeadersTruncated {
+		t.Errorf("response = %+v", e.Response)
+	}
+	wantContent := HARContent{Size: 2, MimeType: "application/octet-stream", Text: "/wA=", Encoding: "base64"}
+	if e.Response.Content != wantContent {
+		t.Errorf("content = %+v", e.Response.Content)
+	}
+
+	blocked := h.Log.Entries[1]
+	if blocked.Response.Status != 0 || blocked.Request.PostData != nil || blocked.Error != "blocked by filter" || blocked.FilterAction != "block" {
+		t.Errorf("blocked entry = %+v", blocked)
+	}
+}
+
+func TestNewHAR_JSONShape(t *testing.T) {
+	data, err := json.Marshal(NewHAR([]RequestLog{{Method: "GET", URL: "https://example.com/"}}, "dev"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Fields HAR requires must be present even when empty, as importers
+	// reject entries without them.
+	for _, field := range []string{`"cookies":[]`, `"headers":[]`, `"queryString":[]`, `"cache":{}`, `"content":{"size":0,"mimeType":""}`} {
+		if !strings.Contains(string(data), field) {
+			t.Errorf("missing %s in %s", field, data)
+		}
+	}
+}
+
+func TestReadHAR(t *testing.T) {
+	var buf bytes.Buffer
+	want := NewHAR([]RequestLog{{
+		Timestamp:  time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC),
+		Method:     "GET",
+		URL:        "https://example.com/a?b=c",
+		StatusCode: 200,
+		Duration:   time.Millisecond,
+	}}, "dev")
+	if err := json.NewEncoder(&buf).Encode(want); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadHAR(&buf)
+	if err != nil {
+		t.Fatalf("ReadHAR: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("round trip:\n got %+v\nwant %+v", got, want)
+	}
+
+	// A browser export carries fields of its own, which are ignored.
+	browser := `{"log":{"version":"1.2","creator":{"name":"WebInspector","version":"537.36"},"pages":[],
+		"entries":[{"startedDateTime":"2024-01-15T10:30:00.123Z","time":12.5,"_initiator":{"type":"script"},
+		"request":{"method":"GET","url":"https://cdn.example.com/app.js","headers":[]},
+		"response":{"status":304,"content":{"size":0,"mimeType":"text/javascript"}},
+		"cache":{},"timings":{"send":0.1,"wait":12,"receive":0.4}}]}}`
+	got, err = ReadHAR(strings.NewReader(browser))
+	if err != nil {
+		t.Fatalf("ReadHAR(browser export): %v", err)
+	}
+	if len(got.Log.Entries) != 1 || got.Log.Entries[0].Request.URL != "https://cdn.example.com/app.js" || got.Log.Entries[0].Response.Status != 304 {
+		t.Errorf("browser export = %+v", got.Log.Entries)
+	}
+
+	for _, bad := range []string{`not json`, `{}`, `{"log":{"entries":[{"time":"slow"}]}}`} {
+		if _, err := ReadHAR(strings.NewReader(bad)); err == nil {
+			t.Errorf("ReadHAR(%s): no error", bad)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// jsonPath is a compiled JSONPath expression.
+//
+// The supported subset is what a filter rule needs to pick a field out of an
+// API request body: the root `$`, child names (`.model`, `['model']`), array
+// indices (`[0]`, `[-1]` counts from the end) and wildcards (`.*`, `[*]`).
+// Filter expressions, slices and recursive descent are refused when the
+// config is loaded rather than half-supported: a rule that silently matched
+// less than it reads as matching is a hole in the filter.
+type jsonPath []jsonPathStep
+
+type jsonPathStepKind int
+
+const (
+	jsonPathName jsonPathStepKind = iota
+	jsonPathIndex
+	jsonPathWildcard
+)
+
+type jsonPathStep struct {
+	kind  jsonPathStepKind
+	name  string
+	index int
+}
+
+// compileJSONPath parses a JSONPath expression in the supported subset.
+func compileJSONPath(expr string) (jsonPath, error) {
+	if !strings.HasPrefix(expr, "$") {
+		return nil, fmt.Errorf("JSONPath %q must start with $", expr)
+	}
+	var path jsonPath
+	rest := expr[1:]
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			switch {
+			case strings.HasPrefix(rest, "."):
+				return nil, fmt.Errorf("JSONPath %q: recursive descent (..) is not supported", expr)
+			case strings.HasPrefix(rest, "*"):
+				path = append(path, jsonPathStep{kind: jsonPathWildcard})
+				rest = rest[1:]
+			default:
+				end := strings.IndexAny(rest, ".[")
+				if end < 0 {
+					end = len(rest)
+				}
+				if end == 0 {
+					return nil, fmt.Errorf("JSONPath %q: empty field name", expr)
+				}
+				path = append(path, jsonPathStep{kind: jsonPathName, name: rest[:end]})
+				rest = rest[end:]
+			}
+
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("JSONPath %q: unterminated [", expr)
+			}
+			inner := rest[1:end]
+			rest = rest[end+1:]
+			switch {
+			case inner == "*":
+				path = append(path, jsonPathStep{kind: jsonPathWildcard})
+			case len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0]:
+				path = append(path, jsonPathStep{kind: jsonPathName, name: inner[1 : len(inner)-1]})
+			default:
+				n, err := strconv.Atoi(inner)
+				if err != nil {
+					return nil, fmt.Errorf("JSONPath %q: unsupported selector [%s] (use a name, an index, or *)", expr, inner)
+				}
+				path = append(path, jsonPathStep{kind: jsonPathIndex, index: n})
+			}
+
+		default:
+			return nil, fmt.Errorf("JSONPath %q: unexpected %q", expr, rest[0])
+		}
+	}
+	return path, nil
+}
+
+// eval returns every value the path selects in doc.
+func (p jsonPath) eval(doc any) []any {
+	nodes := []any{doc}
+	for _, step := range p {
+		var next []any
+		for _, n := range nodes {
+			switch v := n.(type) {
+			case map[string]any:
+				switch step.kind {
+				case jsonPathName:
+					if child, ok := v[step.name]; ok {
+						next = append(next, child)
+					}
+				case jsonPathWildcard:
+					for _, child := range v {
+						next = append(next, child)
+					}
+				}
+			case []any:
+				switch step.kind {
+				case jsonPathIndex:
+					i := step.index
+					if i < 0 {
+						i += len(v)
+					}
+					if i >= 0 && i < len(v) {
+						next = append(next, v[i])
+					}
+				case jsonPathWildcard:
+					next = append(next, v...)
+				}
+			}
+		}
+		nodes = next
+	}
+	return nodes
+}
+
+// parseJSONBody decodes a request body for JSONPath evaluation. Numbers are
+// kept as written so a rule comparing `1e3` or `1000` sees the client's
+// spelling rather than a float64 rendering of it.
+func parseJSONBody(body []byte) (any, bool) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		return nil, false
+	}
+	return doc, true
+}
+
+// jsonValueString renders a selected value for comparison: strings as their
+// contents, everything else as compact JSON (`true`, `null`, `42`, `{"a":1}`).
+func jsonValueString(v any) string {
+	switch t := v.(type) {
+	case string:
+		return t
+	case json.Number:
+		return t.String()
+	default:
+		b, err := json.Marshal(t)
+		if err != nil {
+			return ""
+		}
+		return string(b)
+	}
+}
@@ -0,0 +1,91 @@
+package proxy
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCompileJSONPath(t *testing.T) {
+	valid := []string{
+		"$",
+		"$.model",
+		"$.messages[0].role",
+		"$.messages[-1]",
+		"$['odd key'].x",
+		`$["x"]`,
+		"$.tools[*].name",
+		"$.*",
+	}
+	for _, expr := range valid {
+		if _, err := compileJSONPath(expr); err != nil {
+			t.Errorf("compileJSONPath(%q): %v", expr, err)
+		}
+	}
+
+	invalid := []string{
+		"",
+		"model",
+		"$..model",
+		"$.",
+		"$.a[",
+		"$.a[?(@.x)]",
+		"$.a[1:2]",
+		"$x",
+	}
+	for _, expr := range invalid {
+		if _, err := compileJSONPath(expr); err == nil {
+			t.Errorf("compileJSONPath(%q) succeeded, want error", expr)
+		}
+	}
+}
+
+func TestJSONPathEval(t *testing.T) {
+	doc, ok := parseJSONBody([]byte(`{
+		"model": "gpt-4.1",
+		"max_tokens": 1000,
+		"stream": true,
+		"odd key": {"x": null},
+		"messages": [{"role": "system"}, {"role": "user"}],
+		"meta": {"a": 1}
+	}`))
+	if !ok {
+		t.Fatal("parseJSONBody failed")
+	}
+
+	tests := []struct {
+		expr string
+		want []string
+	}{
+		{"$.model", []string{"gpt-4.1"}},
+		{"$.max_tokens", []string{"1000"}},
+		{"$.stream", []string{"true"}},
+		{"$['odd key'].x", []string{"null"}},
+		{"$.messages[*].role", []string{"system", "user"}},
+		{"$.messages[-1].role", []string{"user"}},
+		{"$.messages[5].role", nil},
+		{"$.meta", []string{`{"a":1}`}},
+		{"$.missing", nil},
+		{"$.model.deeper", nil},
+	}
+	for _, tt := range tests {
+		path, err := compileJSONPath(tt.expr)
+		if err != nil {
+			t.Fatalf("compileJSONPath(%q): %v", tt.expr, err)
+		}
+		var got []string
+		for _, v := range path.eval(doc) {
+			got = append(got, jsonValueString(v))
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s = %q, want %q", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestParseJSONBody_RejectsNonJSON(t *testing.T) {
+	for _, body := range []string{"", "model=gpt-4", "{"} {
+		if _, ok := parseJSONBody([]byte(body)); ok {
+			t.Errorf("parseJSONBody(%q) succeeded", body)
+		}
+	}
+}
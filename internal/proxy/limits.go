@@ -0,0 +1,412 @@
+package proxy
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// FilterActionLimited is recorded as a log entry's filter_action when a
+// [proxy.limits] rule refused the request. It is an outcome, not an action a
+// filter rule can name.
+const FilterActionLimited FilterAction = "limited"
+
+// Limit names, as reported in LimitExceeded and the proxy.limit.exceeded event.
+const (
+	LimitRequestsPerMinute = "requests_per_minute"
+	LimitMaxConcurrent     = "max_concurrent"
+	LimitMaxUploadBytes    = "max_upload_bytes"
+)
+
+// limitWindow is the sliding window RequestsPerMinute is counted over.
+const limitWindow = time.Minute
+
+// LimitsConfig caps the traffic the sandbox may send to matching hosts.
+// Enforcement is active when any rule is set.
+type LimitsConfig struct {
+	Rules []LimitRule
+}
+
+// LimitRule caps requests to the hosts matching Pattern. A zero limit is
+// unset. Every rule matching a host applies, each with its own counters
+// shared by all the hosts it matches, so adding a rule can only tighten what
+// another rule allows.
+type LimitRule struct {
+	// Pattern matches the request host (glob, or regex when auto-detected).
+	Pattern string
+
+	// RequestsPerMinute caps the requests admitted in any 60-second window.
+	RequestsPerMinute int
+
+	// MaxConcurrent caps the requests in flight at once: from admission until
+	// the response has been relayed, or the upstream exchange has failed.
+	MaxConcurrent int
+
+	// MaxUploadBytes caps the request body bytes sent over the session.
+	MaxUploadBytes int64
+
+	// OnExceed is what happens to a request over a limit: "block" answers 429,
+	// "ask" prompts through `devsandbox proxy monitor`. Default: block.
+	OnExceed FilterAction
+}
+
+// IsEnabled returns true if any limit rule is configured.
+func (c *LimitsConfig) IsEnabled() bool {
+	return c != nil && len(c.Rules) > 0
+}
+
+// usesAskAction reports whether any rule escalates to ask mode.
+func (c *LimitsConfig) usesAskAction() bool {
+	if c == nil {
+		return false
+	}
+	for _, rule := range c.Rules {
+		if rule.OnExceed == FilterActionAsk {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks the limits configuration for errors.
+func (c *LimitsConfig) Validate() error {
+	if c == nil {
+		return nil
+	}
+	for i, rule := range c.Rules {
+		if err := rule.Validate(); err != nil {
+			return fmt.Errorf("rule %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// Validate checks a limit rule for errors.
+func (r *LimitRule) Validate() error {
+	if r.Pattern == "" {
+		return fmt.Errorf("pattern is required")
+	}
+	if r.RequestsPerMinute < 0 || r.MaxConcurrent < 0 || r.MaxUploadBytes < 0 {
+		return fmt.Errorf("pattern %q: limits cannot be negative", r.Pattern)
+	}
+	if r.RequestsPerMinute == 0 && r.MaxConcurrent == 0 && r.MaxUploadBytes == 0 {
+		return fmt.Errorf("pattern %q: set at least one of requests_per_minute, max_concurrent, and max_upload_bytes", r.Pattern)
+	}
+	switch r.OnExceed {
+	case FilterActionBlock, FilterActionAsk, "":
+		// Valid
+	default:
+		return fmt.Errorf("invalid on_exceed: %q (must be block or ask)", r.OnExceed)
+	}
+	if _, err := compileScopedPattern(r.Pattern, detectPatternType(r.Pattern), FilterScopeHost); err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetOnExceed returns the over-limit action with a default of block.
+func (r *LimitRule) GetOnExceed() FilterAction {
+	if r.OnExceed == "" {
+		return FilterActionBlock
+	}
+	return r.OnExceed
+}
+
+// LimitExceeded describes the limit a request was refused under.
+type LimitExceeded struct {
+	// Rule is the rule whose limit was reached.
+	Rule LimitRule
+
+	// Limit names the limit reached (LimitRequestsPerMinute, ...).
+	Limit string
+
+	// Reason is a human-readable explanation, recorded as filter_reason.
+	Reason string
+
+	// RetryAfter is how long until the request would be admitted, or zero
+	// when that cannot be known.
+	RetryAfter time.Duration
+}
+
+// LimitCounters is a snapshot of one rule's counters, sent to
+// `devsandbox proxy monitor`. The Max fields are the rule's limits; zero is
+// unset.
+type LimitCounters struct {
+	Pattern           string `json:"pattern"`
+	Requests          int    `json:"requests"` // admitted in the last minute
+	RequestsPerMinute int    `json:"requests_per_minute,omitempty"`
+	InFlight          int    `json:"in_flight"`
+	MaxConcurrent     int    `json:"max_concurrent,omitempty"`
+	UploadedBytes     int64  `json:"uploaded_bytes"`
+	MaxUploadBytes    int64  `json:"max_upload_bytes,omitempty"`
+	Exceeded          int64  `json:"exceeded"` // requests found over a limit
+}
+
+// LimitEngine tracks per-rule counters and admits requests against them.
+type LimitEngine struct {
+	counters []*limitCounter
+	now      func() time.Time
+}
+
+type limitCounter struct {
+	rule  LimitRule
+	match func(string) bool
+
+	mu       sync.Mutex
+	admitted []time.Time // admission times within limitWindow, oldest first
+	inFlight int
+	exceeded int64
+
+	uploaded atomic.Int64 // updated as request bodies are read, outside mu
+}
+
+// NewLimitEngine creates a limit engine from cfg. A nil or empty config
+// returns an engine that is not enabled.
+func NewLimitEngine(cfg *LimitsConfig) (*LimitEngine, error) {
+	e := &LimitEngine{now: time.Now}
+	if !cfg.IsEnabled() {
+		return e, nil
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid limits config: %w", err)
+	}
+	for _, rule := range cfg.Rules {
+		match, err := compileScopedPattern(rule.Pattern, detectPatternType(rule.Pattern), FilterScopeHost)
+		if err != nil {
+			return nil, fmt.Errorf("invalid limits config: %w", err)
+		}
+		e.counters = append(e.counters, &limitCounter{rule: rule, match: match})
+	}
+	return e, nil
+}
+
+// IsEnabled returns true if any limit rule is configured.
+func (e *LimitEngine) IsEnabled() bool {
+	return e != nil && len(e.counters) > 0
+}
+
+// Acquire admits req against every rule matching its host. On admission it
+// returns a release function, to be called once the exchange is over, and
+// wraps req.Body so the bytes actually sent count against upload budgets. A
+// request no rule matches is admitted with a nil release and left untouched.
+// Over a limit, it returns nil and the limit reached; nothing is counted but
+// the refusal. With override set the request is admitted regardless - the
+// user approved it over the limit after the refusal was counted - and its
+// admission counted like any other.
+//
+// Where more than one limit is reached, one whose rule blocks is reported
+// over one whose rule asks: the user must not be prompted to approve a
+// request another rule refuses outright.
+func (e *LimitEngine) Acquire(req *http.Request, override bool) (func(), *LimitExceeded) {
+	host := NormalizeHost(RequestHost(req))
+	var matched []*limitCounter
+	for _, c := range e.counters {
+		if c.match(host) {
+			matched = append(matched, c)
+		}
+	}
+	if len(matched) == 0 {
+		return nil, nil
+	}
+
+	// Lock every matching counter, in rule order so concurrent acquisitions
+	// cannot deadlock, and admit against all of them or none.
+	for _, c := range matched {
+		c.mu.Lock()
+	}
+	now := e.now()
+	var exceeded *LimitExceeded
+	for _, c := range matched {
+		c.prune(now)
+		over := c.check(req, host, now)
+		if over == nil {
+			continue
+		}
+		if !override {
+			c.exceeded++
+		}
+		if exceeded == nil || (exceeded.Rule.GetOnExceed() == FilterActionAsk && over.Rule.GetOnExceed() == FilterActionBlock) {
+			exceeded = over
+		}
+	}
+	if exceeded != nil && !override {
+		for _, c := range matched {
+			c.mu.Unlock()
+		}
+		return nil, exceeded
+	}
+	for _, c := range matched {
+		c.admitted = append(c.admitted, now)
+		c.inFlight++
+		c.mu.Unlock()
+	}
+
+	if req.Body != nil && req.Body != http.NoBody {
+		req.Body = &countingBody{ReadCloser: req.Body, counters: matched}
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			for _, c := range matched {
+				c.mu.Lock()
+				c.inFlight--
+				c.mu.Unlock()
+			}
+		})
+	}, nil
+}
+
+// prune drops admissions that have left the window. Caller holds c.mu.
+func (c *limitCounter) prune(now time.Time) {
+	cutoff := now.Add(-limitWindow)
+	i := 0
+	for i < len(c.admitted) && !c.admitted[i].After(cutoff) {
+		i++
+	}
+	c.admitted = c.admitted[i:]
+}
+
+// check returns the first limit of c that req would exceed, or nil. Caller
+// holds c.mu.
+func (c *limitCounter) check(req *http.Request, host string, now time.Time) *LimitExceeded {
+	r := c.rule
+	if r.MaxConcurrent > 0 && c.inFlight >= r.MaxConcurrent {
+		return &LimitExceeded{
+			Rule:   r,
+			Limit:  LimitMaxConcurrent,
+			Reason: fmt.Sprintf("concurrency limit: %d requests in flight to %s (limit rule %q)", r.MaxConcurrent, host, r.Pattern),
+		}
+	}
+	if r.RequestsPerMinute > 0 && len(c.admitted) >= r.RequestsPerMinute {
+		oldest := c.admitted[len(c.admitted)-r.RequestsPerMinute]
+		return &LimitExceeded{
+			Rule:       r,
+			Limit:      LimitRequestsPerMinute,
+			Reason:     fmt.Sprintf("rate limit: %d requests per minute to %s (limit rule %q)", r.RequestsPerMinute, host, r.Pattern),
+			RetryAfter: oldest.Add(limitWindow).Sub(now),
+		}
+	}
+	if r.MaxUploadBytes > 0 {
+		// A body of unknown length is refused only once the budget is spent:
+		// its size is not known until it has been sent. One that fits at
+		// admission but grows is counted as sent and refuses what follows.
+		uploaded := c.uploaded.Load()
+		if (req.ContentLength > 0 && uploaded+req.ContentLength > r.MaxUploadBytes) ||
+			(req.ContentLength < 0 && uploaded >= r.MaxUploadBytes) {
+			return &LimitExceeded{
+				Rule:   r,
+				Limit:  LimitMaxUploadBytes,
+				Reason: fmt.Sprintf("upload budget: %d of %d bytes already sent to %s this session (limit rule %q)", uploaded, r.MaxUploadBytes, host, r.Pattern),
+			}
+		}
+	}
+	return nil
+}
+
+// Counters returns a snapshot of every rule's counters, in rule order.
+func (e *LimitEngine) Counters() []LimitCounters {
+	if !e.IsEnabled() {
+		return nil
+	}
+	now := e.now()
+	out := make([]LimitCounters, 0, len(e.counters))
+	for _, c := range e.counters {
+		c.mu.Lock()
+		c.prune(now)
+		out = append(out, LimitCounters{
+			Pattern:           c.rule.Pattern,
+			Requests:          len(c.admitted),
+			RequestsPerMinute: c.rule.RequestsPerMinute,
+			InFlight:          c.inFlight,
+			MaxConcurrent:     c.rule.MaxConcurrent,
+			UploadedBytes:     c.uploaded.Load(),
+			MaxUploadBytes:    c.rule.MaxUploadBytes,
+			Exceeded:          c.exceeded,
+		})
+		c.mu.Unlock()
+	}
+	return out
+}
+
+// countingBody counts the request body bytes read from it - that is, sent
+// upstream - against the upload budgets of the rules that admitted it.
+type countingBody struct {
+	io.ReadCloser
+	counters []*limitCounter
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		for _, c := range b.counters {
+			c.uploaded.Add(int64(n))
+		}
+	}
+	return n, err
+}
+
+// releaseOnClose wraps a response body so release runs once the body is
+// closed. A 101 Switching Protocols body is the upgraded connection itself,
+// which goproxy type-asserts to io.ReadWriteCloser; the wrapper keeps that
+// interface so an upgrade holds its slot for as long as it stays open.
+func releaseOnClose(body io.ReadCloser, release func()) io.ReadCloser {
+	if rwc, ok := body.(io.ReadWriteCloser); ok {
+		return &releaseConn{ReadWriteCloser: rwc, release: release}
+	}
+	return &releaseBody{ReadCloser: body, release: release}
+}
+
+type releaseBody struct {
+	io.ReadCloser
+	release func()
+}
+
+func (b *releaseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.release()
+	return err
+}
+
+type releaseConn struct {
+	io.ReadWriteCloser
+	release func()
+}
+
+func (b *releaseConn) Close() error {
+	err := b.ReadWriteCloser.Close()
+	b.release()
+	return err
+}
+
+// LimitResponse creates an HTTP 429 response for a request refused by a
+// [proxy.limits] rule. Retry-After is set when the wait is known.
+func LimitResponse(req *http.Request, exceeded *LimitExceeded) *http.Response {
+	body := fmt.Sprintf("Request refused by devsandbox: %s\n", exceeded.Reason)
+
+	header := http.Header{
+		"Content-Type":   []string{"text/plain; charset=utf-8"},
+		"Content-Length": []string{fmt.Sprintf("%d", len(body))},
+		"X-Blocked-By":   []string{"devsandbox"},
+	}
+	if exceeded.RetryAfter > 0 {
+		// Rounded up: a client retrying on the dot must find the slot free.
+		header.Set("Retry-After", fmt.Sprintf("%d", int64((exceeded.RetryAfter+time.Second-1)/time.Second)))
+	}
+
+	return &http.Response{
+		StatusCode:    http.StatusTooManyRequests,
+		Status:        "429 Too Many Requests",
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
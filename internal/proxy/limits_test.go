@@ -0,0 +1,525 @@
+package proxy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestLimitEngine builds an engine on a fake clock the test advances.
+func newTestLimitEngine(t *testing.T, rules ...LimitRule) (*LimitEngine, *time.Time) {
+	t.Helper()
+	e, err := NewLimitEngine(&LimitsConfig{Rules: rules})
+	if err != nil {
+		t.Fatalf("NewLimitEngine: %v", err)
+	}
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	e.now = func() time.Time { return now }
+	return e, &now
+}
+
+func TestLimitRule_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    LimitRule
+		wantErr string
+	}{
+		{"valid", LimitRule{Pattern: "*.example.com", RequestsPerMinute: 10}, ""},
+		{"valid ask", LimitRule{Pattern: "example.com", MaxConcurrent: 1, OnExceed: FilterActionAsk}, ""},
+		{"empty pattern", LimitRule{RequestsPerMinute: 1}, "pattern is required"},
+		{"no limit", LimitRule{Pattern: "example.com"}, "set at least one"},
+		{"negative", LimitRule{Pattern: "example.com", MaxUploadBytes: -1}, "cannot be negative"},
+		{"allow is not an over-limit action", LimitRule{Pattern: "example.com", MaxConcurrent: 1, OnExceed: FilterActionAllow}, "invalid on_exceed"},
+		{"bad regex", LimitRule{Pattern: "^(example", MaxConcurrent: 1}, "invalid regex"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.rule.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want it to mention %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLimitEngine_Disabled(t *testing.T) {
+	e, err := NewLimitEngine(nil)
+	if err != nil {
+		t.Fatalf("NewLimitEngine(nil): %v", err)
+	}
+	if e.IsEnabled() {
+		t.Error("nil config produced an enabled engine")
+	}
+	if e.Counters() != nil {
+		t.Error("disabled engine reported counters")
+	}
+}
+
+func TestLimitEngine_UnmatchedHostUntouched(t *testing.T) {
+	e, _ := newTestLimitEngine(t, LimitRule{Pattern: "api.example.com", MaxConcurrent: 1})
+	req := httptest.NewRequest(http.MethodPost, "https://other.example.com/", strings.NewReader("x"))
+	body := req.Body
+
+	release, exceeded := e.Acquire(req, false)
+	if release != nil || exceeded != nil {
+		t.Errorf("Acquire = (%v, %+v), want (nil, nil)", release != nil, exceeded)
+	}
+	if req.Body != body {
+		t.Error("unmatched request body was wrapped")
+	}
+}
+
+func TestLimitEngine_RequestsPerMinute(t *testing.T) {
+	e, now := newTestLimitEngine(t, LimitRule{Pattern: "api.example.com", RequestsPerMinute: 2})
+	get := func() *http.Request { return httptest.NewRequest(http.MethodGet, "https://API.example.com/", nil) }
+
+	for i := range 2 {
+		release, exceeded := e.Acquire(get(), false)
+		if exceeded != nil {
+			t.Fatalf("request %d refused: %s", i, exceeded.Reason)
+		}
+		release()
+		*now = now.Add(10 * time.Second)
+	}
+
+	_, exceeded := e.Acquire(get(), false)
+	if exceeded == nil {
+		t.Fatal("third request within a minute was admitted")
+	}
+	if exceeded.Limit != LimitRequestsPerMinute {
+		t.Errorf("limit = %q, want %q", exceeded.Limit, LimitRequestsPerMinute)
+	}
+	// The first admission leaves the window 60s after it, 40s from now.
+	if exceeded.RetryAfter != 40*time.Second {
+		t.Errorf("RetryAfter = %s, want 40s", exceeded.RetryAfter)
+	}
+
+	*now = now.Add(41 * time.Second)
+	if _, exceeded := e.Acquire(get(), false); exceeded != nil {
+		t.Errorf("request refused after the window moved: %s", exceeded.Reason)
+	}
+
+	c := e.Counters()[0]
+	if c.Requests != 2 || c.Exceeded != 1 {
+		t.Errorf("counters = %+v, want 2 requests in window, 1 exceeded", c)
+	}
+}
+
+func TestLimitEngine_MaxConcurrent(t *testing.T) {
+	e, _ := newTestLimitEngine(t, LimitRule{Pattern: "*.example.com", MaxConcurrent: 1})
+
+	release, exceeded := e.Acquire(httptest.NewRequest(http.MethodGet, "https://a.example.com/", nil), false)
+	if exceeded != nil {
+		t.Fatalf("first request refused: %s", exceeded.Reason)
+	}
+	// The counter is shared by every host the rule matches.
+	if _, exceeded := e.Acquire(httptest.NewRequest(http.MethodGet, "https://b.example.com/", nil), false); exceeded == nil ||
+		exceeded.Limit != LimitMaxConcurrent {
+		t.Fatalf("second concurrent request = %+v, want max_concurrent refusal", exceeded)
+	}
+
+	release()
+	release() // idempotent
+	if got := e.Counters()[0].InFlight; got != 0 {
+		t.Fatalf("in flight after release = %d, want 0", got)
+	}
+	if _, exceeded := e.Acquire(httptest.NewRequest(http.MethodGet, "https://b.example.com/", nil), false); exceeded != nil {
+		t.Errorf("request refused after release: %s", exceeded.Reason)
+	}
+}
+
+func TestLimitEngine_UploadBudget(t *testing.T) {
+	e, _ := newTestLimitEngine(t, LimitRule{Pattern: "api.example.com", MaxUploadBytes: 10})
+	post := func(body string) *http.Request {
+		return httptest.NewRequest(http.MethodPost, "https://api.example.com/", strings.NewReader(body))
+	}
+
+	req := post("12345678")
+	release, exceeded := e.Acquire(req, false)
+	if exceeded != nil {
+		t.Fatalf("first upload refused: %s", exceeded.Reason)
+	}
+	// Only bytes actually read - sent upstream - count.
+	if got := e.Counters()[0].UploadedBytes; got != 0 {
+		t.Errorf("uploaded before the body was read = %d, want 0", got)
+	}
+	_, _ = io.ReadAll(req.Body)
+	release()
+	if got := e.Counters()[0].UploadedBytes; got != 8 {
+		t.Errorf("uploaded = %d, want 8", got)
+	}
+
+	if _, exceeded := e.Acquire(post("12345678"), false); exceeded == nil || exceeded.Limit != LimitMaxUploadBytes {
+		t.Errorf("upload past the budget = %+v, want max_upload_bytes refusal", exceeded)
+	}
+	if _, exceeded := e.Acquire(post("12"), false); exceeded != nil {
+		t.Errorf("upload within the remaining budget refused: %s", exceeded.Reason)
+	}
+	if _, exceeded := e.Acquire(httptest.NewRequest(http.MethodGet, "https://api.example.com/", nil), false); exceeded != nil {
+		t.Errorf("bodiless request refused: %s", exceeded.Reason)
+	}
+}
+
+func TestLimitEngine_UploadBudgetUnknownLength(t *testing.T) {
+	e, _ := newTestLimitEngine(t, LimitRule{Pattern: "api.example.com", MaxUploadBytes: 4})
+	chunked := func() *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "https://api.example.com/", strings.NewReader("123456"))
+		req.ContentLength = -1
+		return req
+	}
+
+	req := chunked()
+	if _, exceeded := e.Acquire(req, false); exceeded != nil {
+		t.Fatalf("unknown-length upload refused before the budget was spent: %s", exceeded.Reason)
+	}
+	_, _ = io.ReadAll(req.Body)
+
+	if _, exceeded := e.Acquire(chunked(), false); exceeded == nil {
+		t.Error("unknown-length upload admitted after the budget was spent")
+	}
+}
+
+func TestLimitEngine_EveryMatchingRuleApplies(t *testing.T) {
+	e, _ := newTestLimitEngine(t,
+		LimitRule{Pattern: "*", RequestsPerMinute: 100, OnExceed: FilterActionAsk},
+		LimitRule{Pattern: "api.example.com", RequestsPerMinute: 1},
+	)
+	get := func() *http.Request { return httptest.NewRequest(http.MethodGet, "https://api.example.com/", nil) }
+
+	if _, exceeded := e.Acquire(get(), false); exceeded != nil {
+		t.Fatalf("first request refused: %s", exceeded.Reason)
+	}
+	_, exceeded := e.Acquire(get(), false)
+	if exceeded == nil || exceeded.Rule.Pattern != "api.example.com" {
+		t.Fatalf("exceeded = %+v, want the stricter rule", exceeded)
+	}
+
+	counters := e.Counters()
+	if counters[0].Requests != 1 || counters[1].Requests != 1 {
+		t.Errorf("a refused request was counted as admitted: %+v", counters)
+	}
+}
+
+func TestLimitEngine_BlockReportedOverAsk(t *testing.T) {
+	e, _ := newTestLimitEngine(t,
+		LimitRule{Pattern: "*", MaxConcurrent: 1, OnExceed: FilterActionAsk},
+		LimitRule{Pattern: "api.example.com", MaxConcurrent: 1},
+	)
+	get := func() *http.Request { return httptest.NewRequest(http.MethodGet, "https://api.example.com/", nil) }
+
+	if _, exceeded := e.Acquire(get(), false); exceeded != nil {
+		t.Fatalf("first request refused: %s", exceeded.Reason)
+	}
+	_, exceeded := e.Acquire(get(), false)
+	if exceeded == nil || exceeded.Rule.GetOnExceed() != FilterActionBlock {
+		t.Errorf("exceeded = %+v, want the blocking rule reported", exceeded)
+	}
+}
+
+func TestLimitEngine_Override(t *testing.T) {
+	e, _ := newTestLimitEngine(t, LimitRule{Pattern: "api.example.com", MaxConcurrent: 1, OnExceed: FilterActionAsk})
+	get := func() *http.Request { return httptest.NewRequest(http.MethodGet, "https://api.example.com/", nil) }
+
+	first, _ := e.Acquire(get(), false)
+	if _, exceeded := e.Acquire(get(), false); exceeded == nil {
+		t.Fatal("second request admitted without override")
+	}
+	second, exceeded := e.Acquire(get(), true)
+	if exceeded != nil || second == nil {
+		t.Fatalf("override not admitted: %+v", exceeded)
+	}
+
+	c := e.Counters()[0]
+	if c.InFlight != 2 || c.Exceeded != 1 {
+		t.Errorf("counters = %+v, want 2 in flight, 1 exceeded", c)
+	}
+	first()
+	second()
+}
+
+func TestLimitResponse(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "https://api.example.com/", nil)
+	resp := LimitResponse(req, &LimitExceeded{Reason: "rate limit", RetryAfter: 1500 * time.Millisecond})
+
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("status = %d, want 429", resp.StatusCode)
+	}
+	if got := resp.Header.Get("Retry-After"); got != "2" {
+		t.Errorf("Retry-After = %q, want 2 (rounded up)", got)
+	}
+	if got := resp.Header.Get("X-Blocked-By"); got != "devsandbox" {
+		t.Errorf("X-Blocked-By = %q, want devsandbox", got)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if !strings.Contains(string(body), "rate limit") {
+		t.Errorf("body %q does not carry the reason", body)
+	}
+
+	if got := LimitResponse(req, &LimitExceeded{Reason: "x"}).Header.Get("Retry-After"); got != "" {
+		t.Errorf("Retry-After = %q for an unknown wait, want unset", got)
+	}
+}
+
+func TestNewServer_LimitsRequireMITM(t *testing.T) {
+	cfg := NewConfig(shortTempDir(t), 0)
+	cfg.MITM = false
+	cfg.Limits = &LimitsConfig{Rules: []LimitRule{{Pattern: "*", RequestsPerMinute: 1}}}
+	if _, err := NewServer(cfg); !errors.Is(err, ErrUnenforceableFilterScope) {
+		t.Errorf("NewServer error = %v, want ErrUnenforceableFilterScope", err)
+	}
+}
+
+// startLimitedProxy starts a proxy with the given limit rules in front of
+// upstream and returns a client using it, and the request log directory.
+func startLimitedProxy(t *testing.T, rules ...LimitRule) (*http.Client, string, *Server) {
+	t.Helper()
+	dir := shortTempDir(t)
+	cfg := NewConfig(dir, 0)
+	cfg.Limits = &LimitsConfig{Rules: rules}
+	proxyServer, err := NewServer(cfg)
+	if err != nil {
+		t.Fatalf("NewServer failed: %v", err)
+	}
+	if err := proxyServer.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	t.Cleanup(func() { _ = proxyServer.Stop() })
+
+	proxyURL, _ := url.Parse(fmt.Sprintf("http://%s", proxyServer.Addr()))
+	client := &http.Client{
+		Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)},
+		Timeout:   10 * time.Second,
+	}
+	return client, filepath.Join(dir, LogBaseDirName, ProxyLogDirName), proxyServer
+}
+
+// readLoggedEntries waits for n request log entries and returns them.
+func readLoggedEntries(t *testing.T, dir string, n int) []RequestLog {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var entries []RequestLog
+		for line := range strings.SplitSeq(readActiveLogFile(t, dir), "\n") {
+			if line == "" {
+				continue
+			}
+			var entry RequestLog
+			if err := json.Unmarshal([]byte(line), &entry); err != nil {
+				t.Fatalf("parse log entry %q: %v", line, err)
+			}
+			entries = append(entries, entry)
+		}
+		if len(entries) >= n {
+			return entries
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("got %d request log entries, want %d", len(entries), n)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestServer_LimitRefusedWith429(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.ReadAll(r.Body)
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer upstream.Close()
+
+	client, logDir, _ := startLimitedProxy(t, LimitRule{Pattern: "127.0.0.1", RequestsPerMinute: 1})
+
+	var statuses []int
+	for range 2 {
+		resp, err := client.Get(upstream.URL)
+		if err != nil {
+			t.Fatalf("request through proxy failed: %v", err)
+		}
+		_, _ = io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		statuses = append(statuses, resp.StatusCode)
+	}
+	if statuses[0] != http.StatusOK || statuses[1] != http.StatusTooManyRequests {
+		t.Fatalf("statuses = %v, want [200 429]", statuses)
+	}
+
+	entries := readLoggedEntries(t, logDir, 2)
+	var limited *RequestLog
+	for i := range entries {
+		if entries[i].FilterAction == string(FilterActionLimited) {
+			limited = &entries[i]
+		}
+	}
+	if limited == nil {
+		t.Fatalf("no entry logged with filter_action %q: %+v", FilterActionLimited, entries)
+	}
+	if !strings.Contains(limited.FilterReason, "rate limit") {
+		t.Errorf("filter_reason = %q, want it to name the rate limit", limited.FilterReason)
+	}
+	if limited.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("logged status = %d, want 429", limited.StatusCode)
+	}
+}
+
+func TestServer_LimitSlotReleased(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(strings.Repeat("x", 64<<10)))
+	}))
+	defer upstream.Close()
+
+	client, _, proxyServer := startLimitedProxy(t, LimitRule{Pattern: "127.0.0.1", MaxConcurrent: 1})
+
+	// Each response is relayed and closed before the next request: a slot
+	// that leaked would refuse the second.
+	for i := range 3 {
+		resp, err := client.Get(upstream.URL)
+		if err != nil {
+			t.Fatalf("request %d through proxy failed: %v", i, err)
+		}
+		_, _ = io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("request %d status = %d, want 200", i, resp.StatusCode)
+		}
+	}
+
+	// A failed round trip releases its slot too.
+	dead, _ := net.Listen("tcp", "127.0.0.1:0")
+	deadURL := "http://" + dead.Addr().String()
+	_ = dead.Close()
+	for i := range 2 {
+		resp, err := client.Get(deadURL)
+		if err != nil {
+			t.Fatalf("request %d through proxy failed: %v", i, err)
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode == http.StatusTooManyRequests {
+			t.Fatalf("request %d refused: the failed round trip before it kept its slot", i)
+		}
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for proxyServer.limits.Counters()[0].InFlight != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("in flight = %d after every exchange ended, want 0", proxyServer.limits.Counters()[0].InFlight)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestServer_LimitUploadBudget(t *testing.T) {
+	received := make(chan int, 4)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		received <- len(b)
+	}))
+	defer upstream.Close()
+
+	client, _, _ := startLimitedProxy(t, LimitRule{Pattern: "127.0.0.1", MaxUploadBytes: 10})
+
+	var statuses []int
+	for range 2 {
+		resp, err := client.Post(upstream.URL, "text/plain", strings.NewReader("12345678"))
+		if err != nil {
+			t.Fatalf("request through proxy failed: %v", err)
+		}
+		_ = resp.Body.Close()
+		statuses = append(statuses, resp.StatusCode)
+	}
+	if statuses[0] != http.StatusOK || statuses[1] != http.StatusTooManyRequests {
+		t.Fatalf("statuses = %v, want [200 429]", statuses)
+	}
+	if got := <-received; got != 8 {
+		t.Errorf("upstream received %d bytes, want the whole body", got)
+	}
+	select {
+	case <-received:
+		t.Error("upload over the budget reached upstream")
+	default:
+	}
+}
+
+func TestServer_LimitEscalatesToAsk(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer upstream.Close()
+
+	client, logDir, proxyServer := startLimitedProxy(t,
+		LimitRule{Pattern: "127.0.0.1", RequestsPerMinute: 1, OnExceed: FilterActionAsk})
+	if proxyServer.askQueue == nil {
+		t.Fatal("on_exceed = \"ask\" did not set up the ask queue")
+	}
+
+	conn, err := net.Dial("unix", proxyServer.askServer.SocketPath())
+	if err != nil {
+		t.Fatalf("monitor dial failed: %v", err)
+	}
+	defer func() { _ = conn.Close() }()
+	prompts := make(chan AskRequest, 4)
+	go func() {
+		dec, enc := json.NewDecoder(conn), json.NewEncoder(conn)
+		for {
+			var req AskRequest
+			if err := dec.Decode(&req); err != nil {
+				return
+			}
+			prompts <- req
+			// Remember is ignored for an over-limit prompt.
+			_ = enc.Encode(AskResponse{ID: req.ID, Action: FilterActionAllow, Remember: true})
+		}
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for !proxyServer.askServer.HasMonitor() {
+		if time.Now().After(deadline) {
+			t.Fatal("timeout waiting for monitor to be registered")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	for i := range 2 {
+		resp, err := client.Get(upstream.URL)
+		if err != nil {
+			t.Fatalf("request %d through proxy failed: %v", i, err)
+		}
+		_, _ = io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("request %d status = %d, want 200 (approved over the limit)", i, resp.StatusCode)
+		}
+	}
+
+	select {
+	case p := <-prompts:
+		if !strings.Contains(p.Limit, "rate limit") {
+			t.Errorf("prompt Limit = %q, want the limit named", p.Limit)
+		}
+	default:
+		t.Fatal("over-limit request was not put to the monitor")
+	}
+
+	entries := readLoggedEntries(t, logDir, 2)
+	found := false
+	for _, e := range entries {
+		if strings.Contains(e.FilterReason, "allowed by user decision") {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("no entry records the approval over the limit: %+v", entries)
+	}
+}
@@ -0,0 +1,15 @@
+package proxy
+
+import (
+	"os"
+	"testing"
+)
+
+// TestMain keeps the package's tests off the session bus of whoever runs
+// them: a server in ask mode with no monitor would otherwise put its requests
+// up as desktop notifications. Tests of the notifier start their own bus.
+func TestMain(m *testing.M) {
+	_ = os.Unsetenv("DBUS_SESSION_BUS_ADDRESS")
+	_ = os.Unsetenv("XDG_RUNTIME_DIR")
+	os.Exit(m.Run())
+}
@@ -17,16 +17,20 @@ import (
 	"devsandbox/internal/source"
 )
 
-// RedactionEngine scans outgoing requests for secrets.
+// RedactionEngine scans outgoing requests, and upstream responses, for secrets.
 type RedactionEngine struct {
 	config        *RedactionConfig
 	compiledRules []compiledRedactionRule
+	responseRules []*compiledResponseRule
 
 	// maxScanBytes and scanTimeout bound the body ReadScanBody buffers. Both
 	// are fields rather than constants so tests can drive the limits without
 	// moving megabytes or waiting out the real deadline.
 	maxScanBytes int
 	scanTimeout  time.Duration
+
+	// maxResponseScanBytes is the separate budget of the response scan.
+	maxResponseScanBytes int
 }
 
 type compiledRedactionRule struct {
@@ -49,13 +53,21 @@ func NewRedactionEngine(cfg *RedactionConfig, projectDir string) (*RedactionEngi
 	}
 
 	engine := &RedactionEngine{
-		config:       cfg,
-		maxScanBytes: cfg.GetMaxScanBytes(),
-		scanTimeout:  defaultRedactionScanTimeout,
+		config:               cfg,
+		maxScanBytes:         cfg.GetMaxScanBytes(),
+		scanTimeout:          defaultRedactionScanTimeout,
+		maxResponseScanBytes: cfg.GetMaxResponseScanBytes(),
 	}
 
 	// Pre-resolve env file values if any rule uses env_file_key
-	envFileValues := resolveEnvFileValues(cfg.Rules, projectDir)
+	var sources []*RedactionSource
+	for _, r := range cfg.Rules {
+		sources = append(sources, r.Source)
+	}
+	for _, r := range cfg.ResponseRules {
+		sources = append(sources, r.Source)
+	}
+	envFileValues := resolveEnvFileValues(sources, projectDir)
 
 	for i, rule := range cfg.Rules {
 		name := rule.GetName(i)
@@ -89,14 +101,27 @@ func NewRedactionEngine(cfg *RedactionConfig, projectDir string) (*RedactionEngi
 		})
 	}
 
+	for i, rule := range cfg.ResponseRules {
+		cr, err := compileResponseRule(rule, rule.GetName(i), rule.GetAction(cfg.GetDefaultAction()), envFileValues)
+		if err != nil {
+			return nil, err
+		}
+		engine.responseRules = append(engine.responseRules, cr)
+	}
+
 	return engine, nil
 }
 
-// IsEnabled returns true if the redaction engine is active.
+// IsEnabled returns true if the redaction engine scans requests.
 func (e *RedactionEngine) IsEnabled() bool {
 	return e.config.IsEnabled() && len(e.compiledRules) > 0
 }
 
+// IsResponseEnabled returns true if the redaction engine scans responses.
+func (e *RedactionEngine) IsResponseEnabled() bool {
+	return e.config.IsEnabled() && len(e.responseRules) > 0
+}
+
 // defaultRedactionScanTimeout bounds how long the redaction scan waits for a
 // request body. Unlike the log capture's deadline, passing this one cannot
 // release the request: the scan has no decision without the whole body, so the
@@ -124,11 +149,16 @@ var ErrRedactionBodyTimeout = errors.New("timed out reading the request body for
 // indefinitely or grow host memory by the size of a body it chose - and the
 // proxy runs outside the sandbox's resource limits, so nothing else caps it.
 func (e *RedactionEngine) ReadScanBody(req *http.Request) ([]byte, error) {
+	return readScanBody(req, e.maxScanBytes, e.scanTimeout)
+}
+
+// readScanBody is ReadScanBody with explicit limits, for the body-aware filter
+// rules that need the same bounded read when redaction is not enabled.
+func readScanBody(req *http.Request, limit int, timeout time.Duration) ([]byte, error) {
 	if req.Body == nil {
 		return nil, nil
 	}
 
-	limit := e.maxScanBytes
 	type result struct {
 		body []byte
 		err  error
@@ -141,7 +171,7 @@ func (e *RedactionEngine) ReadScanBody(req *http.Request) ([]byte, error) {
 		done <- result{body: body, err: err}
 	}()
 
-	timer := time.NewTimer(e.scanTimeout)
+	timer := time.NewTimer(timeout)
 	defer timer.Stop()
 
 	// Leaving early abandons the read goroutine but does not leak it: net/http
@@ -160,7 +190,7 @@ func (e *RedactionEngine) ReadScanBody(req *http.Request) ([]byte, error) {
 	case <-req.Context().Done():
 		return nil, req.Context().Err()
 	case <-timer.C:
-		return nil, fmt.Errorf("%w after %s", ErrRedactionBodyTimeout, e.scanTimeout)
+		return nil, fmt.Errorf("%w after %s", ErrRedactionBodyTimeout, timeout)
 	}
 }
 
@@ -189,6 +219,16 @@ func (e *RedactionEngine) MatchesValue(value string) []string {
 	return names
 }
 
+// RedactString replaces every secret a request rule detects in s with the
+// rule's placeholder, whatever the rule's action. It is for display: a monitor
+// prompt must not show a secret the request log would not.
+func (e *RedactionEngine) RedactString(s string) string {
+	for _, cr := range e.compiledRules {
+		s = redactRule(s, cr)
+	}
+	return s
+}
+
 // redactionReadBlockReason names why the scan refused a request, so the sandbox
 // sees which bound it hit - and, for the size bound, the key that raises it.
 func redactionReadBlockReason(err error) string {
@@ -287,13 +327,19 @@ func (e *RedactionEngine) matchTarget(cr compiledRedactionRule, target string) b
 // redactStringFiltered replaces secret values only for rules at the given indices.
 func (e *RedactionEngine) redactStringFiltered(s string, indices []int) string {
 	for _, i := range indices {
-		cr := e.compiledRules[i]
-		placeholder := fmt.Sprintf("[REDACTED:%s]", cr.name)
-		if cr.compiledRegex != nil {
-			s = cr.compiledRegex.ReplaceAllString(s, placeholder)
-		} else if cr.resolvedValue != "" {
-			s = strings.ReplaceAll(s, cr.resolvedValue, placeholder)
-		}
+		s = redactRule(s, e.compiledRules[i])
+	}
+	return s
+}
+
+// redactRule replaces every match of one rule in s with its placeholder.
+func redactRule(s string, cr compiledRedactionRule) string {
+	placeholder := fmt.Sprintf("[REDACTED:%s]", cr.name)
+	if cr.compiledRegex != nil {
+		return cr.compiledRegex.ReplaceAllString(s, placeholder)
+	}
+	if cr.resolvedValue != "" {
+		return strings.ReplaceAll(s, cr.resolvedValue, placeholder)
 	}
 	return s
 }
@@ -362,7 +408,7 @@ func resolveSource(src *RedactionSource, envFileValues map[string]string) string
 }
 
 // resolveEnvFileValues finds .env files in projectDir and extracts all key=value pairs.
-func resolveEnvFileValues(rules []RedactionRule, projectDir string) map[string]string {
+func resolveEnvFileValues(sources []*RedactionSource, projectDir string) map[string]string {
 	values := make(map[string]string)
 	if projectDir == "" {
 		return values
@@ -370,8 +416,8 @@ func resolveEnvFileValues(rules []RedactionRule, projectDir string) map[string]s
 
 	// Check if any rule uses env_file_key
 	needsEnvFile := false
-	for _, r := range rules {
-		if r.Source != nil && r.Source.EnvFileKey != "" {
+	for _, src := range sources {
+		if src != nil && src.EnvFileKey != "" {
 			needsEnvFile = true
 			break
 		}
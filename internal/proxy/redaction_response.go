@@ -0,0 +1,398 @@
+package proxy
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// compiledResponseRule is a ResponseRedactionRule ready to apply. The embedded
+// rule scans like a request rule; a rule that only strips headers leaves both
+// its regex and its resolved value empty.
+type compiledResponseRule struct {
+	compiledRedactionRule
+	hosts        []func(string) bool // empty: every host
+	stripHeaders []string            // canonical header names
+}
+
+// compileResponseRule compiles one response rule. Like a request rule, a
+// source that resolves to nothing is a startup error rather than a rule that
+// silently never matches.
+func compileResponseRule(rule ResponseRedactionRule, name string, action RedactionAction, envFileValues map[string]string) (*compiledResponseRule, error) {
+	cr := &compiledResponseRule{
+		compiledRedactionRule: compiledRedactionRule{name: name, action: action},
+	}
+	for _, h := range rule.Hosts {
+		m, err := compilePattern(h, detectPatternType(h))
+		if err != nil {
+			return nil, fmt.Errorf("response rule %q: invalid host pattern %q: %w", name, h, err)
+		}
+		cr.hosts = append(cr.hosts, m)
+	}
+	for _, h := range rule.StripHeaders {
+		cr.stripHeaders = append(cr.stripHeaders, http.CanonicalHeaderKey(h))
+	}
+
+	switch {
+	case rule.Pattern != "":
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("response rule %q: invalid regex: %w", name, err)
+		}
+		cr.compiledRegex = re
+	case rule.Source != nil:
+		cr.resolvedValue = resolveSource(rule.Source, envFileValues)
+		if cr.resolvedValue == "" {
+			return nil, fmt.Errorf("response redaction rule %q: could not resolve secret value from source", name)
+		}
+	}
+	return cr, nil
+}
+
+// scansContent reports whether the rule looks inside header values and the
+// body, rather than only stripping headers.
+func (cr *compiledResponseRule) scansContent() bool {
+	return cr.compiledRegex != nil || cr.resolvedValue != ""
+}
+
+// matchHost reports whether the rule applies to responses from host.
+func (cr *compiledResponseRule) matchHost(host string) bool {
+	if len(cr.hosts) == 0 {
+		return true
+	}
+	for _, m := range cr.hosts {
+		if m(host) {
+			return true
+		}
+	}
+	return false
+}
+
+// ErrRedactionResponseTooLarge reports a response body, or a line of a
+// streamed one, past the response scan limit. Like its request counterpart it
+// is a denial: passing on what could not be scanned whole would let a secret
+// past the limit through.
+var ErrRedactionResponseTooLarge = errors.New("response exceeds the response redaction scan limit")
+
+// ErrRedactionResponseEncoded reports a response body in a content encoding
+// the scan cannot read. The proxy lets the transport negotiate the encoding
+// and decode it, so this is an upstream sending one nobody asked for.
+var ErrRedactionResponseEncoded = errors.New("response body has a content encoding the redaction scan cannot read")
+
+// errRedactionResponseBlocked ends a streamed response whose later line
+// matched a block rule, after the status line has already gone out.
+var errRedactionResponseBlocked = errors.New("response stream blocked: secret pattern detected in upstream response")
+
+// responseRedactionBlockReason names why the scan refused a response, and for
+// the size bound, the key that raises it.
+func responseRedactionBlockReason(err error) string {
+	switch {
+	case errors.Is(err, ErrRedactionResponseTooLarge):
+		return fmt.Sprintf("response blocked: %v; raise proxy.redaction.max_response_scan_bytes to scan responses this large", err)
+	case errors.Is(err, ErrRedactionResponseEncoded):
+		return fmt.Sprintf("response blocked: %v", err)
+	}
+	return "failed to read response body for redaction scan"
+}
+
+// ResponseBlockResponse creates the 502 that replaces an upstream response
+// the response scan refused. The request already reached upstream, so the
+// proxy answers as a gateway that could not pass the reply on.
+func ResponseBlockResponse(req *http.Request, reason string) *http.Response {
+	body := fmt.Sprintf("Response blocked by devsandbox: %s\n", reason)
+
+	return &http.Response{
+		StatusCode: http.StatusBadGateway,
+		Status:     "502 Bad Gateway",
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header: http.Header{
+			"Content-Type":   []string{"text/plain; charset=utf-8"},
+			"Content-Length": []string{fmt.Sprintf("%d", len(body))},
+			"X-Blocked-By":   []string{"devsandbox"},
+		},
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+// ScanResponse applies the response rules matching host to resp, in place,
+// before the response reaches the sandbox.
+//
+// Headers named by a rule are stripped, and header values are scanned. A body
+// of known length within the scan limit is buffered and scanned whole. A
+// streamed body - an event stream, or any body without a Content-Length - is
+// never buffered whole: it is wrapped to be scanned a line at a time as it
+// flows, and matches found there are reported through onStream as they
+// happen rather than in the returned result.
+//
+// With a result of block the caller must discard resp. With redact, resp
+// already carries the redacted headers and body. An error means the body
+// cannot be scanned - too large, or in an encoding the scan cannot read - and
+// resp must be refused.
+func (e *RedactionEngine) ScanResponse(resp *http.Response, host string, onStream func(*RedactionResult)) (*RedactionResult, error) {
+	result := &RedactionResult{}
+	var rules, content []*compiledResponseRule
+	for _, cr := range e.responseRules {
+		if !cr.matchHost(host) {
+			continue
+		}
+		rules = append(rules, cr)
+		if cr.scansContent() {
+			content = append(content, cr)
+		}
+	}
+	if len(rules) == 0 {
+		return result, nil
+	}
+
+	for _, cr := range rules {
+		for _, name := range cr.stripHeaders {
+			if _, ok := resp.Header[name]; !ok {
+				continue
+			}
+			resp.Header.Del(name)
+			result.Matches = append(result.Matches, RedactionMatch{
+				RuleName: cr.name,
+				Location: "response_header:" + name,
+				Action:   RedactionActionRedact,
+			})
+		}
+	}
+
+	var headerMatched []*compiledResponseRule
+	for _, cr := range content {
+		matched := false
+		for name, values := range resp.Header {
+			for _, v := range values {
+				if e.matchTarget(cr.compiledRedactionRule, v) {
+					result.Matches = append(result.Matches, RedactionMatch{
+						RuleName: cr.name,
+						Location: "response_header:" + name,
+						Action:   cr.action,
+					})
+					matched = true
+					break // one match per header name is enough
+				}
+			}
+		}
+		if matched {
+			headerMatched = append(headerMatched, cr)
+		}
+	}
+
+	var body []byte
+	var bodyMatched []*compiledResponseRule
+	if len(content) > 0 && responseHasBody(resp) {
+		if enc := resp.Header.Get("Content-Encoding"); enc != "" && !strings.EqualFold(enc, "identity") {
+			return result, fmt.Errorf("%w: %q", ErrRedactionResponseEncoded, enc)
+		}
+		if isStreamingResponse(resp) || resp.ContentLength < 0 {
+			resp.Body = &redactingStreamBody{
+				src:     resp.Body,
+				engine:  e,
+				rules:   content,
+				limit:   e.maxResponseScanBytes,
+				onMatch: onStream,
+			}
+		} else {
+			var err error
+			if body, err = e.readResponseBody(resp); err != nil {
+				return result, err
+			}
+			bodyStr := string(body)
+			for _, cr := range content {
+				if e.matchTarget(cr.compiledRedactionRule, bodyStr) {
+					result.Matches = append(result.Matches, RedactionMatch{
+						RuleName: cr.name,
+						Location: "response_body",
+						Action:   cr.action,
+					})
+					bodyMatched = append(bodyMatched, cr)
+				}
+			}
+		}
+	}
+
+	if len(result.Matches) == 0 {
+		return result, nil
+	}
+	result.Matched = true
+	result.Action = highestSeverityAction(result.Matches)
+
+	// As with requests, a redact or block outcome redacts every match,
+	// log-only rules included, so the log entry never records a detected
+	// secret. Stripped headers are already gone either way.
+	if result.Action == RedactionActionRedact || result.Action == RedactionActionBlock {
+		for name, values := range resp.Header {
+			for i, v := range values {
+				for _, cr := range headerMatched {
+					v = redactRule(v, cr.compiledRedactionRule)
+				}
+				values[i] = v
+			}
+			resp.Header[name] = values
+		}
+		if len(bodyMatched) > 0 {
+			redacted := string(body)
+			for _, cr := range bodyMatched {
+				redacted = redactRule(redacted, cr.compiledRedactionRule)
+			}
+			body = []byte(redacted)
+			result.Body = body
+			resp.Body = io.NopCloser(bytes.NewReader(body))
+			resp.ContentLength = int64(len(body))
+			resp.Header.Del("Content-Length") // Let Go derive from ContentLength
+		}
+	}
+	return result, nil
+}
+
+// responseHasBody reports whether resp carries a body the scan should read.
+// A 101 body is the upgraded connection, not content.
+func responseHasBody(resp *http.Response) bool {
+	if resp.Body == nil || resp.Body == http.NoBody {
+		return false
+	}
+	if resp.Request != nil && resp.Request.Method == http.MethodHead {
+		return false
+	}
+	return resp.StatusCode >= http.StatusOK &&
+		resp.StatusCode != http.StatusNoContent &&
+		resp.StatusCode != http.StatusNotModified
+}
+
+// readResponseBody buffers a response body of known length, within the scan
+// limit, and leaves resp.Body replaying it. Unlike the request read there is
+// no deadline: the handler is waiting on upstream either way, and the bytes
+// are bounded by the Content-Length checked before reading.
+func (e *RedactionEngine) readResponseBody(resp *http.Response) ([]byte, error) {
+	limit := e.maxResponseScanBytes
+	if resp.ContentLength > int64(limit) {
+		return nil, fmt.Errorf("%w of %d bytes", ErrRedactionResponseTooLarge, limit)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, int64(limit)+1))
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > limit {
+		return nil, fmt.Errorf("%w of %d bytes", ErrRedactionResponseTooLarge, limit)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+// redactingStreamBody scans a streamed response a line at a time, so an event
+// stream keeps flowing: each line is released once its newline arrives and it
+// has been scanned, and nothing past the current line is held. A pattern
+// spanning lines is therefore not seen - the price of not buffering a stream
+// that may never end. A line longer than the limit cannot be scanned whole
+// and ends the stream, as does a line matching a block rule; neither line is
+// released.
+type redactingStreamBody struct {
+	src     io.ReadCloser
+	engine  *RedactionEngine
+	rules   []*compiledResponseRule
+	limit   int
+	onMatch func(*RedactionResult)
+
+	buf     []byte
+	pending []byte // an unterminated line, not yet scanned
+	out     []byte // scanned bytes not yet read
+	err     error
+}
+
+func (b *redactingStreamBody) Read(p []byte) (int, error) {
+	for len(b.out) == 0 && b.err == nil {
+		if b.buf == nil {
+			b.buf = make([]byte, 32*1024)
+		}
+		n, err := b.src.Read(b.buf)
+		b.pending = append(b.pending, b.buf[:n]...)
+		if i := bytes.LastIndexByte(b.pending, '\n'); i >= 0 {
+			b.release(b.pending[:i+1])
+			b.pending = append([]byte(nil), b.pending[i+1:]...)
+		}
+		switch {
+		case b.err != nil:
+		case err == io.EOF:
+			if len(b.pending) > 0 {
+				b.release(b.pending)
+				b.pending = nil
+			}
+			if b.err == nil {
+				b.err = io.EOF
+			}
+		case err != nil:
+			b.err = err
+		case len(b.pending) > b.limit:
+			b.err = fmt.Errorf("%w: a line of the stream exceeds %d bytes", ErrRedactionResponseTooLarge, b.limit)
+		}
+	}
+	if len(b.out) > 0 {
+		n := copy(p, b.out)
+		b.out = b.out[n:]
+		return n, nil
+	}
+	return 0, b.err
+}
+
+// release scans complete lines and queues them for reading, stopping the
+// stream at a line a block rule matches.
+func (b *redactingStreamBody) release(chunk []byte) {
+	for len(chunk) > 0 && b.err == nil {
+		line := chunk
+		if i := bytes.IndexByte(chunk, '\n'); i >= 0 {
+			line = chunk[:i+1]
+		}
+		chunk = chunk[len(line):]
+		b.out = append(b.out, b.scanLine(line)...)
+	}
+}
+
+func (b *redactingStreamBody) scanLine(line []byte) []byte {
+	s := string(line)
+	result := &RedactionResult{}
+	var matched []*compiledResponseRule
+	for _, cr := range b.rules {
+		if b.engine.matchTarget(cr.compiledRedactionRule, s) {
+			result.Matches = append(result.Matches, RedactionMatch{
+				RuleName: cr.name,
+				Location: "response_body",
+				Action:   cr.action,
+			})
+			matched = append(matched, cr)
+		}
+	}
+	if len(matched) == 0 {
+		return line
+	}
+	result.Matched = true
+	result.Action = highestSeverityAction(result.Matches)
+	if b.onMatch != nil {
+		b.onMatch(result)
+	}
+
+	switch result.Action {
+	case RedactionActionBlock:
+		b.err = errRedactionResponseBlocked
+		return nil
+	case RedactionActionRedact:
+		for _, cr := range matched {
+			s = redactRule(s, cr.compiledRedactionRule)
+		}
+		return []byte(s)
+	}
+	return line
+}
+
+func (b *redactingStreamBody) Close() error {
+	return b.src.Close()
+}
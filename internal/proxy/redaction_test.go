@@ -350,6 +350,26 @@ func TestRedactionEngine_Scan_Redact(t *testing.T) {
 	}
 }
 
+func TestRedactionEngine_RedactString(t *testing.T) {
+	cfg := &RedactionConfig{
+		Enabled:       new(true),
+		DefaultAction: RedactionActionLog,
+		Rules: []RedactionRule{
+			{Name: "api-key", Source: &RedactionSource{Value: "sk-secret123"}},
+			{Name: "token", Pattern: `ghp_[A-Za-z0-9]{8}`},
+		},
+	}
+	engine, err := NewRedactionEngine(cfg, "")
+	if err != nil {
+		t.Fatalf("NewRedactionEngine: %v", err)
+	}
+
+	got := engine.RedactString("key=sk-secret123&gh=ghp_abcdEFGH")
+	if want := "key=[REDACTED:api-key]&gh=[REDACTED:token]"; got != want {
+		t.Errorf("RedactString = %q, want %q", got, want)
+	}
+}
+
 func TestRedactionEngine_Disabled(t *testing.T) {
 	cfg := &RedactionConfig{
 		Enabled:       new(false),
@@ -30,6 +30,13 @@ type RedactionConfig struct {
 	// MaxScanBytes bounds the request body the scan buffers before deciding.
 	// 0 → config.DefaultMaxRedactionScanBytes. Read through GetMaxScanBytes.
 	MaxScanBytes int `toml:"max_scan_bytes"`
+	// ResponseRules scan upstream responses before they reach the sandbox.
+	ResponseRules []ResponseRedactionRule `toml:"response_rules"`
+	// MaxResponseScanBytes bounds the response body, or the single line of a
+	// streamed one, the response scan holds while deciding.
+	// 0 → config.DefaultMaxResponseRedactionScanBytes. Read through
+	// GetMaxResponseScanBytes.
+	MaxResponseScanBytes int `toml:"max_response_scan_bytes"`
 }
 
 // IsEnabled returns true if redaction scanning is enabled.
@@ -49,6 +56,15 @@ func (c *RedactionConfig) GetMaxScanBytes() int {
 	return c.MaxScanBytes
 }
 
+// GetMaxResponseScanBytes returns the response scan limit, defaulting to
+// config.DefaultMaxResponseRedactionScanBytes when unset or non-positive.
+func (c *RedactionConfig) GetMaxResponseScanBytes() int {
+	if c == nil || c.MaxResponseScanBytes <= 0 {
+		return config.DefaultMaxResponseRedactionScanBytes
+	}
+	return c.MaxResponseScanBytes
+}
+
 // GetDefaultAction returns the default action, defaulting to block.
 func (c *RedactionConfig) GetDefaultAction() RedactionAction {
 	if c.DefaultAction == "" {
@@ -73,6 +89,11 @@ func (c *RedactionConfig) Validate() error {
 			return fmt.Errorf("rule %d: %w", i+1, err)
 		}
 	}
+	for i := range c.ResponseRules {
+		if err := c.ResponseRules[i].Validate(); err != nil {
+			return fmt.Errorf("response rule %d: %w", i+1, err)
+		}
+	}
 	return nil
 }
 
@@ -140,6 +161,72 @@ func (r *RedactionRule) Validate() error {
 	return nil
 }
 
+// ResponseRedactionRule defines a rule applied to upstream responses. It
+// scans like a RedactionRule - Source or Pattern, over headers and body - and
+// can also drop whole headers, which needs neither.
+type ResponseRedactionRule struct {
+	// Name is a human-readable identifier for this rule.
+	// Optional; auto-generated as "response-rule-<index>" if omitted.
+	Name string `toml:"name"`
+	// Hosts limits the rule to responses from matching hosts (exact, glob,
+	// or regex, as in filter rules). Empty applies it to every host.
+	Hosts []string `toml:"hosts"`
+	// Action overrides the default action for this rule's Source or Pattern
+	// matches. Optional.
+	Action RedactionAction `toml:"action"`
+	// Source resolves the secret value to scan for. Mutually exclusive with Pattern.
+	Source *RedactionSource `toml:"source"`
+	// Pattern is a regex pattern to scan for. Mutually exclusive with Source.
+	Pattern string `toml:"pattern"`
+	// StripHeaders names response headers removed before the response
+	// reaches the sandbox, e.g. "Set-Cookie".
+	StripHeaders []string `toml:"strip_headers"`
+}
+
+// GetName returns the rule name, generating one from the index if not set.
+func (r *ResponseRedactionRule) GetName(index int) string {
+	if r.Name != "" {
+		return r.Name
+	}
+	return fmt.Sprintf("response-rule-%d", index+1)
+}
+
+// GetAction returns the rule action, falling back to the given default.
+func (r *ResponseRedactionRule) GetAction(defaultAction RedactionAction) RedactionAction {
+	if r.Action != "" {
+		return r.Action
+	}
+	return defaultAction
+}
+
+// Validate checks a response redaction rule for errors.
+func (r *ResponseRedactionRule) Validate() error {
+	for _, h := range r.Hosts {
+		if h == "" {
+			return fmt.Errorf("hosts must not contain an empty pattern")
+		}
+		if _, err := compilePattern(h, detectPatternType(h)); err != nil {
+			return fmt.Errorf("invalid host pattern %q: %w", h, err)
+		}
+	}
+	for _, h := range r.StripHeaders {
+		if h == "" {
+			return fmt.Errorf("strip_headers must not contain an empty name")
+		}
+	}
+	if r.Source == nil && r.Pattern == "" {
+		if len(r.StripHeaders) == 0 {
+			return fmt.Errorf("one of source, pattern, or strip_headers is required")
+		}
+		if r.Action != "" {
+			return fmt.Errorf("action requires source or pattern")
+		}
+		return nil
+	}
+	scan := RedactionRule{Name: r.Name, Action: r.Action, Source: r.Source, Pattern: r.Pattern}
+	return scan.Validate()
+}
+
 // RedactionSource resolves a secret value from a configured source.
 type RedactionSource struct {
 	// Value is a static secret value.
@@ -152,7 +239,7 @@ type RedactionSource struct {
 	EnvFileKey string `toml:"env_file_key"`
 }
 
-// RedactionResult holds the outcome of scanning a request.
+// RedactionResult holds the outcome of scanning a request or response.
 type RedactionResult struct {
 	// Matched is true if any rule matched.
 	Matched bool
@@ -172,7 +259,9 @@ type RedactionResult struct {
 type RedactionMatch struct {
 	// RuleName identifies which rule matched.
 	RuleName string
-	// Location describes where the match was found: "url", "header:<Name>", or "body".
+	// Location describes where the match was found: "url", "header:<Name>",
+	// or "body" in a request; "response_header:<Name>" or "response_body" in
+	// a response.
 	Location string
 	// Action is this specific rule's action.
 	Action RedactionAction
@@ -0,0 +1,97 @@
+package proxy
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+)
+
+// ErrNotReplayable indicates a logged request whose entry does not hold what
+// was sent, so sending it again would send something else.
+var ErrNotReplayable = errors.New("request cannot be replayed")
+
+// replayDroppedHeaders are not copied from a logged request: Go's transport
+// sets them itself from the request it sends.
+var replayDroppedHeaders = []string{"Connection", "Content-Length", "Host", "Keep-Alive", "Proxy-Connection", "Te", "Trailer", "Transfer-Encoding", "Upgrade"}
+
+// ReplayRequest rebuilds the request a log entry records, to send again.
+// Headers the log withheld - credentials, cookies - are left out and named in
+// withheld; credential injection adds them back where it is configured to.
+//
+// An entry is refused with ErrNotReplayable when its record of the request is
+// incomplete or altered: a truncated body or header set, or a redaction rule
+// having rewritten or blocked it, which leaves the log holding the redacted
+// copy. Tunnels, SOCKS connections and DNS queries carry no request to send.
+func ReplayRequest(e *RequestLog) (req *http.Request, withheld []string, err error) {
+	switch {
+	case e.Method == http.MethodConnect || e.Method == "TCP" || e.Method == "DNS":
+		return nil, nil, fmt.Errorf("%w: a %s entry records a connection, not a request", ErrNotReplayable, e.Method)
+	case e.RequestBodyTruncated:
+		return nil, nil, fmt.Errorf("%w: the logged body was truncated (see proxy.max_log_body_bytes)", ErrNotReplayable)
+	case e.RequestHeadersTruncated:
+		return nil, nil, fmt.Errorf("%w: the logged headers were truncated", ErrNotReplayable)
+	case e.RedactionAction == string(RedactionActionRedact) || e.RedactionAction == string(RedactionActionBlock):
+		return nil, nil, fmt.Errorf("%w: redaction rewrote the logged request", ErrNotReplayable)
+	}
+
+	u, err := url.Parse(e.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, nil, fmt.Errorf("%w: %q is not an HTTP URL", ErrNotReplayable, e.URL)
+	}
+
+	req, err = http.NewRequest(e.Method, e.URL, bytes.NewReader(e.RequestBody))
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrNotReplayable, err)
+	}
+	for name, values := range e.RequestHeaders {
+		canonical := http.CanonicalHeaderKey(name)
+		if slices.Contains(replayDroppedHeaders, canonical) {
+			continue
+		}
+		if slices.Contains(values, redactedHeaderValue) {
+			withheld = append(withheld, canonical)
+			continue
+		}
+		for _, v := range values {
+			req.Header.Add(canonical, v)
+		}
+	}
+	slices.Sort(withheld)
+	return req, withheld, nil
+}
+
+// Client returns an HTTP client that sends its requests through the server
+// as a sandbox does, trusting the server's CA. It follows no redirects and
+// leaves Accept-Encoding alone, so the response is the one a sandbox would
+// have been handed. The server must be running.
+func (s *Server) Client() *http.Client {
+	proxyURL := &url.URL{Scheme: "http", Host: s.Addr()}
+	transport := &http.Transport{
+		Proxy:              http.ProxyURL(proxyURL),
+		DisableCompression: true,
+	}
+	if s.ca != nil {
+		pool := x509.NewCertPool()
+		pool.AddCert(s.ca.Certificate)
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	}
+	return &http.Client{
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// LoggedHeaders returns h as a log entry would record it, with credentials
+// withheld, so a live response's headers compare like for like with logged
+// ones.
+func LoggedHeaders(h http.Header) map[string][]string {
+	out, _ := captureHeaders(h)
+	return out
+}
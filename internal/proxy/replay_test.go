@@ -0,0 +1,160 @@
+package proxy
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestReplayRequest_Refuses(t *testing.T) {
+	tests := []struct {
+		name  string
+		entry RequestLog
+	}{
+		{name: "tunnel", entry: RequestLog{Method: "CONNECT", URL: "https://example.com:443"}},
+		{name: "socks", entry: RequestLog{Method: "TCP", URL: "tcp://example.com:22"}},
+		{name: "dns", entry: RequestLog{Method: "DNS", URL: "dns://example.com?type=A"}},
+		{name: "truncated body", entry: RequestLog{Method: "POST", URL: "https://example.com/", RequestBody: []byte("ab"), RequestBodyTruncated: true}},
+		{name: "truncated headers", entry: RequestLog{Method: "GET", URL: "https://example.com/", RequestHeadersTruncated: true}},
+		{name: "redacted", entry: RequestLog{Method: "POST", URL: "https://example.com/", RedactionAction: "redact"}},
+		{name: "redaction blocked", entry: RequestLog{Method: "POST", URL: "https://example.com/", RedactionAction: "block"}},
+		{name: "not http", entry: RequestLog{Method: "GET", URL: "ftp://example.com/"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := ReplayRequest(&tt.entry); !errors.Is(err, ErrNotReplayable) {
+				t.Errorf("ReplayRequest = %v, want ErrNotReplayable", err)
+			}
+		})
+	}
+
+	// A redaction rule that only logged leaves the request as it was sent.
+	if _, _, err := ReplayRequest(&RequestLog{Method: "GET", URL: "https://example.com/", RedactionAction: "log"}); err != nil {
+		t.Errorf("log-only redaction: %v", err)
+	}
+}
+
+func TestReplayRequest(t *testing.T) {
+	req, withheld, err := ReplayRequest(&RequestLog{
+		Method: "POST",
+		URL:    "https://api.example.com/v1/items?x=1",
+		RequestHeaders: map[string][]string{
+			"content-type":   {"application/json"},
+			"X-Multi":        {"a", "b"},
+			"Authorization":  {redactedHeaderValue},
+			"Cookie":         {redactedHeaderValue},
+			"Content-Length": {"11"},
+			"Connection":     {"keep-alive"},
+		},
+		RequestBody: []byte(`{"name":"a"}`),
+	})
+	if err != nil {
+		t.Fatalf("ReplayRequest: %v", err)
+	}
+	if req.Method != "POST" || req.URL.String() != "https://api.example.com/v1/items?x=1" {
+		t.Errorf("request = %s %s", req.Method, req.URL)
+	}
+	if got := req.Header.Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q", got)
+	}
+	if got := req.Header.Values("X-Multi"); !slices.Equal(got, []string{"a", "b"}) {
+		t.Errorf("X-Multi = %q", got)
+	}
+	for _, name := range []string{"Authorization", "Cookie", "Content-Length", "Connection"} {
+		if v := req.Header.Get(name); v != "" {
+			t.Errorf("%s = %q, want it left out", name, v)
+		}
+	}
+	if !slices.Equal(withheld, []string{"Authorization", "Cookie"}) {
+		t.Errorf("withheld = %v", withheld)
+	}
+	body, _ := io.ReadAll(req.Body)
+	if string(body) != `{"name":"a"}` || req.ContentLength != int64(len(body)) {
+		t.Errorf("body = %q, length %d", body, req.ContentLength)
+	}
+}
+
+func TestServer_ClientReplaysThroughPipeline(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Got-Auth", r.Header.Get("Authorization"))
+		w.Header().Set("X-Got-Trace", r.Header.Get("X-Trace"))
+		body, _ := io.ReadAll(r.Body)
+		_, _ = w.Write(body)
+	}))
+	defer upstream.Close()
+
+	dir := t.TempDir()
+	cfg := NewConfig(dir, 0)
+	injectors, err := BuildCredentialInjectors(map[string]any{
+		"local": map[string]any{
+			"enabled": true,
+			"host":    "127.0.0.1",
+			"header":  "Authorization",
+			"source":  map[string]any{"value": "injected"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.CredentialInjectors = injectors
+	server, err := NewServer(cfg)
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	if err := server.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer func() { _ = server.Stop() }()
+
+	req, withheld, err := ReplayRequest(&RequestLog{
+		Method:         "PUT",
+		URL:            upstream.URL + "/items/1",
+		RequestHeaders: map[string][]string{"X-Trace": {"t-1"}, "Authorization": {redactedHeaderValue}},
+		RequestBody:    []byte("payload"),
+	})
+	if err != nil {
+		t.Fatalf("ReplayRequest: %v", err)
+	}
+	if !slices.Equal(withheld, []string{"Authorization"}) {
+		t.Errorf("withheld = %v", withheld)
+	}
+	resp, err := server.Client().Do(req)
+	if err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+
+	// The log withheld the credential; injection puts it back.
+	if got := resp.Header.Get("X-Got-Auth"); got != "injected" {
+		t.Errorf("upstream saw Authorization %q, want the injected one", got)
+	}
+	if got := resp.Header.Get("X-Got-Trace"); got != "t-1" || string(body) != "payload" {
+		t.Errorf("upstream saw X-Trace %q, body %q", got, body)
+	}
+
+	entry := waitForLoggedEntry(t, filepath.Join(dir, LogBaseDirName, ProxyLogDirName))
+	if entry.ID == "" || !strings.HasSuffix(entry.URL, "/items/1") {
+		t.Errorf("replay logged as %+v", entry)
+	}
+}
+
+func TestRequestLog_LogID(t *testing.T) {
+	if got := (&RequestLog{ID: "abc123"}).LogID(); got != "abc123" {
+		t.Errorf("LogID = %q, want the logged ID", got)
+	}
+	old := RequestLog{Method: "GET", URL: "https://example.com/"}
+	if a, b := old.LogID(), old.LogID(); a != b || len(a) != 12 {
+		t.Errorf("derived IDs %q, %q: want one stable 12-digit ID", a, b)
+	}
+	other := old
+	other.URL = "https://example.com/other"
+	if old.LogID() == other.LogID() {
+		t.Error("different entries derived the same ID")
+	}
+}
@@ -3,6 +3,9 @@ package proxy
 import (
 	"bytes"
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -15,6 +18,7 @@ import (
 	"time"
 
 	"devsandbox/internal/config"
+	"devsandbox/internal/logfile"
 	"devsandbox/internal/logging"
 )
 
@@ -30,6 +34,9 @@ const (
 // whole body - see RequestLogger.maxBodyBytes. The matching Truncated flag
 // distinguishes a body that was cut from one that was short.
 type RequestLog struct {
+	// ID names the entry for `devsandbox logs proxy replay`. Entries written
+	// before IDs were are named by LogID instead.
+	ID                       string              `json:"id,omitempty"`
 	Timestamp                time.Time           `json:"ts"`
 	Method                   string              `json:"method"`
 	URL                      string              `json:"url"`
@@ -48,12 +55,18 @@ type RequestLog struct {
 	FilterReason             string              `json:"filter_reason,omitempty"`
 	RedactionAction          string              `json:"redaction_action,omitempty"`
 	RedactionMatches         []string            `json:"redaction_matches,omitempty"`
+	ResponseRedactionAction  string              `json:"resp_redaction_action,omitempty"`
+	ResponseRedactionMatches []string            `json:"resp_redaction_matches,omitempty"`
+	// BytesSent and BytesReceived count a SOCKS connection's traffic, which
+	// has no request or response body to record.
+	BytesSent     int64 `json:"bytes_sent,omitempty"`
+	BytesReceived int64 `json:"bytes_received,omitempty"`
 }
 
 // RequestLogger writes HTTP request/response logs to rotating gzip-compressed files
 // and optionally forwards them to remote destinations.
 type RequestLogger struct {
-	writer         *RotatingFileWriter
+	writer         *logfile.RotatingFileWriter
 	dispatcher     *logging.Dispatcher
 	ownsDispatcher bool // true if this logger created/owns the dispatcher
 	skipEngine     *LogSkipEngine
@@ -83,7 +96,7 @@ func WithMaxBodyLogBytes(n int) RequestLoggerOption {
 // If ownsDispatcher is true, the dispatcher will be closed when the logger is closed.
 // If skipEngine is non-nil, entries matching its rules are dropped before any I/O.
 func NewRequestLogger(dir string, dispatcher *logging.Dispatcher, ownsDispatcher bool, skipEngine *LogSkipEngine, opts ...RequestLoggerOption) (*RequestLogger, error) {
-	writer, err := NewRotatingFileWriter(RotatingFileWriterConfig{
+	writer, err := logfile.NewRotatingFileWriter(logfile.RotatingFileWriterConfig{
 		Dir:           dir,
 		Prefix:        RequestLogPrefix,
 		Suffix:        RequestLogSuffix,
@@ -131,6 +144,9 @@ func (rl *RequestLogger) Log(entry *RequestLog) error {
 		return nil
 	}
 	rl.requestCount.Add(1)
+	if entry.ID == "" {
+		entry.ID = newRequestID()
+	}
 
 	data, err := json.Marshal(entry)
 	if err != nil {
@@ -153,6 +169,25 @@ func (rl *RequestLogger) Log(entry *RequestLog) error {
 	return writeErr
 }
 
+// newRequestID returns a random ID for a log entry: 12 hex digits, short
+// enough to type and wide enough not to collide within any one log.
+func newRequestID() string {
+	var b [6]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// LogID returns the entry's ID. An entry from before IDs were logged gets one
+// derived from its timestamp, method and URL, which is as stable as the log
+// line itself.
+func (e *RequestLog) LogID() string {
+	if e.ID != "" {
+		return e.ID
+	}
+	sum := sha256.Sum256([]byte(e.Timestamp.UTC().Format(time.RFC3339Nano) + " " + e.Method + " " + e.URL))
+	return hex.EncodeToString(sum[:6])
+}
+
 // toLogEntry converts a RequestLog to a logging.Entry for remote forwarding.
 func (rl *RequestLogger) toLogEntry(req *RequestLog) *logging.Entry {
 	level := logging.LevelInfo
@@ -160,13 +195,14 @@ func (rl *RequestLogger) toLogEntry(req *RequestLog) *logging.Entry {
 		level = logging.LevelError
 	} else if req.FilterAction == "block" {
 		level = logging.LevelWarn
-	} else if req.RedactionAction == "block" {
+	} else if req.RedactionAction == "block" || req.ResponseRedactionAction == "block" {
 		level = logging.LevelWarn
 	} else if req.StatusCode >= 400 {
 		level = logging.LevelWarn
 	}
 
 	fields := map[string]any{
+		"id":          req.ID,
 		"method":      req.Method,
 		"url":         req.URL,
 		"status":      req.StatusCode,
@@ -189,6 +225,18 @@ func (rl *RequestLogger) toLogEntry(req *RequestLog) *logging.Entry {
 	if len(req.RedactionMatches) > 0 {
 		fields["redaction_matches"] = req.RedactionMatches
 	}
+	if req.ResponseRedactionAction != "" {
+		fields["resp_redaction_action"] = req.ResponseRedactionAction
+	}
+	if len(req.ResponseRedactionMatches) > 0 {
+		fields["resp_redaction_matches"] = req.ResponseRedactionMatches
+	}
+
+	// Add traffic counts for SOCKS connections
+	if req.BytesSent > 0 || req.BytesReceived > 0 {
+		fields["bytes_sent"] = req.BytesSent
+		fields["bytes_received"] = req.BytesReceived
+	}
 
 	return &logging.Entry{
 		Timestamp: req.Timestamp,
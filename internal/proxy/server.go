@@ -12,6 +12,7 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"slices"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -20,6 +21,8 @@ import (
 
 	"github.com/elazarl/goproxy"
 
+	"devsandbox/internal/dbus"
+	"devsandbox/internal/logfile"
 	"devsandbox/internal/logging"
 	"devsandbox/internal/notice"
 )
@@ -43,9 +46,13 @@ type Server struct {
 	listener            net.Listener
 	server              *http.Server
 	reqLogger           *RequestLogger
-	proxyLogger         *RotatingFileWriter
+	proxyLogger         *logfile.RotatingFileWriter
 	filterEngine        *FilterEngine
 	redactionEngine     *RedactionEngine
+	gitPush             *GitPushPolicy
+	limits              *LimitEngine
+	dns                 *DNSResolver
+	socks               *SOCKSServer
 	askServer           *AskServer
 	askQueue            *AskQueue
 	credentialInjectors []CredentialInjector
@@ -54,8 +61,10 @@ type Server struct {
 	wg                  sync.WaitGroup
 	mu                  sync.Mutex
 	running             bool
+	stopPublish         chan struct{} // closed by Stop to end publishLimitCounters
 	requestID           atomic.Uint64
-	debug               bool // DEVSANDBOX_DEBUG: log per-request lifecycle to the internal proxy log
+	sessionName         atomic.Value // string; see SetSessionName
+	debug               bool         // DEVSANDBOX_DEBUG: log per-request lifecycle to the internal proxy log
 }
 
 // RequestCount returns the count of non-skipped requests handled by this
@@ -107,7 +116,8 @@ var ErrUnenforceableFilterScope = errors.New("filter rule scope cannot be enforc
 // validateFilterScopes refuses a configuration whose filter rules the proxy
 // cannot honor. With MITM off, HTTPS never becomes an HTTP request the proxy
 // can inspect: all it sees is CONNECT host:port. Host-scoped rules are
-// enforceable there, path- and url-scoped ones are not. Starting anyway would
+// enforceable there; path- and url-scoped ones, and any rule matching on
+// method, headers or body, are not. Starting anyway would
 // enforce less than the config file says, so the launch is refused naming the
 // rule instead.
 func validateFilterScopes(cfg *Config) error {
@@ -115,6 +125,13 @@ func validateFilterScopes(cfg *Config) error {
 		return nil
 	}
 	for i, rule := range cfg.Filter.Rules {
+		if rule.HasRequestMatchers() {
+			return fmt.Errorf(
+				"%w: rule %d (pattern %q): an HTTPS CONNECT carries only host:port, "+
+					"so methods, headers and body matchers cannot be evaluated while MITM is disabled; "+
+					"remove them or enable MITM",
+				ErrUnenforceableFilterScope, i+1, rule.Pattern)
+		}
 		scope := rule.GetScope()
 		if scope == FilterScopeHost {
 			continue
@@ -128,11 +145,42 @@ func validateFilterScopes(cfg *Config) error {
 	return nil
 }
 
+// validateLimitsMITM refuses [proxy.limits] with MITM off. A CONNECT tunnel
+// carries any number of requests and bodies the proxy never sees, so counting
+// tunnels against a requests-per-minute or upload budget would enforce a
+// different limit than the one configured.
+func validateLimitsMITM(cfg *Config) error {
+	if cfg.MITM || !cfg.Limits.IsEnabled() {
+		return nil
+	}
+	return fmt.Errorf("%w: proxy.limits counts requests and request bodies, "+
+		"which an HTTPS CONNECT tunnel hides while MITM is disabled; enable MITM or remove proxy.limits",
+		ErrUnenforceableFilterScope)
+}
+
+// validateGitPushMITM refuses a git push policy with MITM off: a push to an
+// HTTPS remote is then a CONNECT tunnel whose ref updates the proxy never
+// sees, so the policy would bind only the plain-HTTP remotes nobody uses.
+func validateGitPushMITM(cfg *Config) error {
+	if cfg.MITM || !cfg.GitPush.IsEnabled() {
+		return nil
+	}
+	return fmt.Errorf("%w: proxy.git_push inspects the body of a push, "+
+		"which an HTTPS CONNECT tunnel hides while MITM is disabled; enable MITM or remove proxy.git_push",
+		ErrUnenforceableFilterScope)
+}
+
 func NewServer(cfg *Config) (*Server, error) {
 	// Refuse an unenforceable configuration before creating anything.
 	if err := validateFilterScopes(cfg); err != nil {
 		return nil, err
 	}
+	if err := validateGitPushMITM(cfg); err != nil {
+		return nil, err
+	}
+	if err := validateLimitsMITM(cfg); err != nil {
+		return nil, err
+	}
 
 	var ca *CA
 	if cfg.MITM {
@@ -146,7 +194,7 @@ func NewServer(cfg *Config) (*Server, error) {
 	proxy := goproxy.NewProxyHttpServer()
 
 	// Create rotating file writer for goproxy's internal logs (warnings, errors)
-	proxyLogger, err := NewRotatingFileWriter(RotatingFileWriterConfig{
+	proxyLogger, err := logfile.NewRotatingFileWriter(logfile.RotatingFileWriterConfig{
 		Dir:           cfg.InternalLogDir,
 		Prefix:        ProxyLogPrefix,
 		Suffix:        ProxyLogSuffix,
@@ -197,7 +245,9 @@ func NewServer(cfg *Config) (*Server, error) {
 	// Create filter engine if configured
 	var filterEngine *FilterEngine
 	if cfg.Filter != nil && cfg.Filter.IsEnabled() {
-		filterEngine, err = NewFilterEngine(cfg.Filter)
+		filterCfg := *cfg.Filter
+		filterCfg.MaxBodyBytes = cfg.Redaction.GetMaxScanBytes()
+		filterEngine, err = NewFilterEngine(&filterCfg)
 		if err != nil {
 			_ = proxyLogger.Close()
 			_ = reqLogger.Close()
@@ -216,6 +266,20 @@ func NewServer(cfg *Config) (*Server, error) {
 		}
 	}
 
+	gitPush, err := NewGitPushPolicy(cfg.GitPush)
+	if err != nil {
+		_ = proxyLogger.Close()
+		_ = reqLogger.Close()
+		return nil, fmt.Errorf("failed to create git push policy: %w", err)
+	}
+
+	limits, err := NewLimitEngine(cfg.Limits)
+	if err != nil {
+		_ = proxyLogger.Close()
+		_ = reqLogger.Close()
+		return nil, fmt.Errorf("failed to create limit engine: %w", err)
+	}
+
 	// Cross-validate: credential injectors must not conflict with redaction rules
 	if err := validateCredentialRedactionConflicts(cfg.CredentialInjectors, redactionEngine); err != nil {
 		_ = proxyLogger.Close()
@@ -235,18 +299,26 @@ func NewServer(cfg *Config) (*Server, error) {
 	// so an ask server built for it is a socket and an accept goroutine nothing
 	// can reach - and a failure creating it would abort a launch that was never
 	// going to ask anything.
+	//
+	// [proxy.limits] reaches it too: a rule escalating to ask needs the queue,
+	// and any rule publishes its counters to the monitor over the same socket.
 	var askServer *AskServer
 	var askQueue *AskQueue
-	if cfg.Filter.IsEnabled() && cfg.Filter.usesAskAction() {
+	usesAsk := (cfg.Filter.IsEnabled() && cfg.Filter.usesAskAction()) || cfg.Limits.usesAskAction()
+	if usesAsk || cfg.Limits.IsEnabled() {
 		askServer, err = NewAskServer(cfg.SandboxBase)
 		if err != nil {
 			_ = proxyLogger.Close()
 			_ = reqLogger.Close()
 			return nil, fmt.Errorf("failed to create ask server: %w", err)
 		}
-
+	}
+	if usesAsk {
 		timeout := time.Duration(cfg.Filter.GetAskTimeout()) * time.Second
 		askQueue = NewAskQueue(askServer, filterEngine, timeout)
+		if cfg.Filter.IsAskNotificationsEnabled() {
+			askServer.SetNotifier(NewDesktopNotifier(dbus.SessionBusAddress()))
+		}
 	}
 
 	s := &Server{
@@ -257,6 +329,8 @@ func NewServer(cfg *Config) (*Server, error) {
 		proxyLogger:         proxyLogger,
 		filterEngine:        filterEngine,
 		redactionEngine:     redactionEngine,
+		gitPush:             gitPush,
+		limits:              limits,
 		askServer:           askServer,
 		askQueue:            askQueue,
 		credentialInjectors: cfg.CredentialInjectors,
@@ -264,6 +338,13 @@ func NewServer(cfg *Config) (*Server, error) {
 		debug:               os.Getenv("DEVSANDBOX_DEBUG") != "",
 	}
 
+	if cfg.DNS.IsEnabled() {
+		s.dns = NewDNSResolver(s.dnsDecision, s.recordDNSQuery)
+	}
+	if cfg.SOCKS.IsEnabled() {
+		s.socks = NewSOCKSServer(s.authorizeTCP)
+	}
+
 	s.setupMITM()
 	s.setupLogging()
 
@@ -386,7 +467,7 @@ func (s *Server) applyFilterDecision(req *http.Request, entry *RequestLog, reqBo
 			}
 			return nil
 		}
-		if s.handleAskMode(req, entry, reqBody) == FilterActionBlock {
+		if s.handleAskMode(req, entry, reqBody, "") == FilterActionBlock {
 			if entry != nil {
 				entry.FilterAction = string(FilterActionBlock)
 				entry.FilterReason = "blocked by user decision"
@@ -403,6 +484,64 @@ func (s *Server) applyFilterDecision(req *http.Request, entry *RequestLog, reqBo
 	return nil
 }
 
+// readScanBody buffers the request body for body-aware filter rules. With
+// redaction enabled it is the redaction engine's read, so the body is held
+// once under one limit; without it the same bounds apply from config.
+func (s *Server) readScanBody(req *http.Request) ([]byte, error) {
+	if s.redactionEngine != nil && s.redactionEngine.IsEnabled() {
+		return s.redactionEngine.ReadScanBody(req)
+	}
+	return readScanBody(req, s.config.Redaction.GetMaxScanBytes(), defaultRedactionScanTimeout)
+}
+
+// filterBodyReadBlockReason explains a request refused because a body-aware
+// filter rule could not read its body. Like the redaction scan, a rule that
+// has not seen the whole body cannot say the request is allowed.
+func filterBodyReadBlockReason(err error) string {
+	if errors.Is(err, ErrRedactionBodyTooLarge) {
+		return fmt.Sprintf("request blocked: body-matching filter rule: %v; raise proxy.redaction.max_scan_bytes to match bodies this large", err)
+	}
+	return fmt.Sprintf("request blocked: body-matching filter rule could not read the body: %v", err)
+}
+
+// applyGitPushPolicy checks the ref updates of a git push against the
+// configured policy and records the outcome as the entry's filter decision,
+// returning the response to send when the push is refused and nil when it may
+// proceed. A request whose updates cannot be read is refused: a policy that
+// cannot see what a push changes cannot allow it.
+func (s *Server) applyGitPushPolicy(req *http.Request, entry *RequestLog) *http.Response {
+	allowed := false
+	var reason string
+	updates, err := s.gitPush.ReadRefUpdates(req)
+	if err != nil {
+		reason = "git push refused: cannot read ref updates: " + err.Error()
+	} else {
+		allowed, reason = s.gitPush.Check(updates)
+	}
+
+	decision := FilterDecision{Action: FilterActionAllow, Reason: reason}
+	if !allowed {
+		decision.Action = FilterActionBlock
+	}
+	s.emitFilterDecision(req, decision)
+
+	if entry != nil {
+		entry.FilterAction = string(decision.Action)
+		// Keep what the filter said about the host alongside the push verdict,
+		// so an ask approval is not erased from the log by the check after it.
+		if entry.FilterReason != "" && allowed {
+			entry.FilterReason += "; " + reason
+		} else {
+			entry.FilterReason = reason
+		}
+	}
+
+	if !allowed {
+		return BlockResponse(req, reason)
+	}
+	return nil
+}
+
 // finalizeEntry detaches the request-log entry from the goproxy context after a
 // request hook has already written it.
 //
@@ -456,9 +595,43 @@ func (s *Server) setupLogging() {
 			}
 		}
 
-		// Apply filter rules if configured
+		// Apply filter rules if configured. A rule matching on the body gets
+		// the whole body, read under the redaction scan's bounds; the read is
+		// kept for the redaction scan below rather than repeated.
+		var scanBody []byte
+		bodyBuffered := false
 		if s.filterEngine != nil && s.filterEngine.IsEnabled() {
-			if resp := s.applyFilterDecision(req, entry, reqBody, s.filterEngine.Match(req)); resp != nil {
+			var filterBody []byte
+			if req.Body != nil && s.filterEngine.NeedsBody(req) {
+				body, err := s.readScanBody(req)
+				if err != nil {
+					reason := filterBodyReadBlockReason(err)
+					resp := BlockResponse(req, reason)
+					if entry != nil {
+						entry.FilterAction = string(FilterActionBlock)
+						entry.FilterReason = reason
+						s.reqLogger.LogResponse(entry, resp, entry.Timestamp)
+						_ = s.reqLogger.Log(entry)
+					}
+					finalizeEntry(ctx)
+					return nil, resp
+				}
+				filterBody, scanBody, bodyBuffered = body, body, true
+			}
+			if resp := s.applyFilterDecision(req, entry, reqBody, s.filterEngine.Match(req, filterBody)); resp != nil {
+				if entry != nil {
+					s.reqLogger.LogResponse(entry, resp, entry.Timestamp)
+					_ = s.reqLogger.Log(entry)
+				}
+				finalizeEntry(ctx)
+				return nil, resp
+			}
+		}
+
+		// Git push policy (after filter allows the host). Reads only the ref
+		// updates at the head of the body; the pack streams through untouched.
+		if s.gitPush.IsEnabled() && isGitReceivePack(req) {
+			if resp := s.applyGitPushPolicy(req, entry); resp != nil {
 				if entry != nil {
 					s.reqLogger.LogResponse(entry, resp, entry.Timestamp)
 					_ = s.reqLogger.Log(entry)
@@ -474,8 +647,10 @@ func (s *Server) setupLogging() {
 		// in bytes and time, and a body it cannot take whole is blocked - a scan
 		// of part of a body proves nothing about the rest of it.
 		if s.redactionEngine != nil && s.redactionEngine.IsEnabled() {
-			scanBody := reqBody
-			if req.Body != nil {
+			if !bodyBuffered {
+				scanBody = reqBody
+			}
+			if req.Body != nil && !bodyBuffered {
 				freshBody, err := s.redactionEngine.ReadScanBody(req)
 				if err != nil {
 					resp := BlockResponse(req, redactionReadBlockReason(err))
@@ -580,6 +755,19 @@ func (s *Server) setupLogging() {
 			}
 		}
 
+		// Limits last, once nothing else can refuse the request: a slot is
+		// only taken by a request that is actually sent.
+		if s.limits.IsEnabled() {
+			if resp := s.applyLimits(req, ctx, entry, reqBody); resp != nil {
+				if entry != nil {
+					s.reqLogger.LogResponse(entry, resp, entry.Timestamp)
+					_ = s.reqLogger.Log(entry)
+				}
+				finalizeEntry(ctx)
+				return nil, resp
+			}
+		}
+
 		return req, nil
 	})
 
@@ -599,6 +787,12 @@ func (s *Server) setupLogging() {
 			streaming = isStreamingResponse(resp)
 		}
 
+		// Response rules run before the capture wraps the body, so the log
+		// records what the sandbox receives, not what upstream sent.
+		if resp != nil && s.redactionEngine != nil && s.redactionEngine.IsResponseEnabled() {
+			resp = s.redactResponse(resp, ctx.Req, entry)
+		}
+
 		// Records status/headers now and streams the body through to the client
 		// while capturing a bounded prefix; the log entry is written when the
 		// body closes. Never buffers the body before relaying headers.
@@ -614,6 +808,61 @@ func (s *Server) setupLogging() {
 	})
 }
 
+// redactResponse applies the response redaction rules to resp and returns the
+// response to relay: resp itself, redacted in place where a rule said so, or
+// a 502 in its place when a rule blocks it or its body cannot be scanned.
+// Matches found later in a streamed body reach the entry as they are found.
+func (s *Server) redactResponse(resp *http.Response, req *http.Request, entry *RequestLog) *http.Response {
+	if req == nil {
+		req = resp.Request
+	}
+	result, err := s.redactionEngine.ScanResponse(resp, NormalizeHost(RequestHost(req)), func(r *RedactionResult) {
+		s.recordResponseRedaction(req, entry, r)
+	})
+	if err != nil {
+		if resp.Body != nil {
+			_ = resp.Body.Close()
+		}
+		s.recordResponseRedaction(req, entry, result)
+		entry.ResponseRedactionAction = string(RedactionActionBlock)
+		entry.Error = "response redaction read error: " + err.Error()
+		return ResponseBlockResponse(req, responseRedactionBlockReason(err))
+	}
+	if !result.Matched {
+		return resp
+	}
+	s.recordResponseRedaction(req, entry, result)
+	if result.Action == RedactionActionBlock {
+		if resp.Body != nil {
+			_ = resp.Body.Close()
+		}
+		return ResponseBlockResponse(req, "response blocked: secret pattern detected in upstream response")
+	}
+	return resp
+}
+
+// recordResponseRedaction folds a response scan result into the log entry -
+// once for the headers and buffered body, then again for each matching line of
+// a streamed body - and emits its audit events.
+func (s *Server) recordResponseRedaction(req *http.Request, entry *RequestLog, result *RedactionResult) {
+	if result == nil || !result.Matched {
+		return
+	}
+	s.emitRedactionApplied(req, result)
+	if entry == nil {
+		return
+	}
+	for _, m := range result.Matches {
+		if !slices.Contains(entry.ResponseRedactionMatches, m.RuleName) {
+			entry.ResponseRedactionMatches = append(entry.ResponseRedactionMatches, m.RuleName)
+		}
+	}
+	if entry.ResponseRedactionAction == "" ||
+		actionSeverity[result.Action] > actionSeverity[RedactionAction(entry.ResponseRedactionAction)] {
+		entry.ResponseRedactionAction = string(result.Action)
+	}
+}
+
 // debugf writes a per-request lifecycle line to the internal proxy log when
 // DEVSANDBOX_DEBUG is set. Lines carry timestamps (log.LstdFlags) so request
 // and response events can be correlated and timed. View with
@@ -681,8 +930,34 @@ func (s *Server) Start() error {
 		IdleTimeout:       120 * time.Second,
 	}
 
+	// Loopback only: the sandbox reaches the resolver through a pasta
+	// forward into the host's loopback, never through the proxy's bind
+	// address.
+	if s.dns != nil {
+		if err := s.dns.Start("127.0.0.1:0"); err != nil {
+			_ = listener.Close()
+			return err
+		}
+	}
+	// Loopback only, for the same reason.
+	if s.socks != nil {
+		if err := s.socks.Start("127.0.0.1:0"); err != nil {
+			if s.dns != nil {
+				_ = s.dns.Close()
+			}
+			_ = listener.Close()
+			return err
+		}
+	}
+
 	s.running = true
 
+	if s.limits.IsEnabled() && s.askServer != nil {
+		stop := make(chan struct{})
+		s.stopPublish = stop
+		s.wg.Go(func() { s.publishLimitCounters(stop) })
+	}
+
 	s.wg.Go(func() {
 		if err := s.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) && !errors.Is(err, net.ErrClosed) {
 			notice.Error("proxy server error: %v", err)
@@ -715,6 +990,16 @@ func (s *Server) Stop() error {
 	if s.listener != nil {
 		_ = s.listener.Close()
 	}
+	if s.stopPublish != nil {
+		close(s.stopPublish)
+		s.stopPublish = nil
+	}
+	if s.dns != nil {
+		_ = s.dns.Close()
+	}
+	if s.socks != nil {
+		_ = s.socks.Close()
+	}
 
 	s.wg.Wait()
 
@@ -737,9 +1022,109 @@ func (s *Server) Stop() error {
 	return nil
 }
 
+// applyLimits admits req under [proxy.limits]. An admitted request holds its
+// slot until the response has been relayed; one over a limit is refused with
+// 429 or, where its rule says so, put to the user first. Either way the limit
+// is named in the log entry's filter_reason.
+func (s *Server) applyLimits(req *http.Request, ctx *goproxy.ProxyCtx, entry *RequestLog, reqBody []byte) *http.Response {
+	release, exceeded := s.limits.Acquire(req, false)
+	if exceeded != nil {
+		reason := exceeded.Reason
+		allowed := false
+		if exceeded.Rule.GetOnExceed() == FilterActionAsk && s.askQueue != nil {
+			// handleAskMode records an unanswered prompt as the entry's
+			// reason; here it follows the limit instead of replacing it.
+			var filterReason string
+			if entry != nil {
+				filterReason, entry.FilterReason = entry.FilterReason, ""
+			}
+			allowed = s.handleAskMode(req, entry, reqBody, exceeded.Reason) == FilterActionAllow
+			switch {
+			case allowed:
+				reason += "; allowed by user decision"
+			case entry != nil && entry.FilterReason != "":
+				reason += "; " + entry.FilterReason
+			default:
+				reason += "; blocked by user decision"
+			}
+			if entry != nil {
+				entry.FilterReason = filterReason
+			}
+		}
+		s.emitLimitExceeded(req, exceeded, allowed)
+
+		if !allowed {
+			if entry != nil {
+				entry.FilterAction = string(FilterActionLimited)
+				entry.FilterReason = reason
+			}
+			return LimitResponse(req, exceeded)
+		}
+		release, _ = s.limits.Acquire(req, true)
+		if entry != nil {
+			if entry.FilterReason != "" {
+				entry.FilterReason += "; " + reason
+			} else {
+				entry.FilterReason = reason
+			}
+		}
+	}
+	if release != nil {
+		ctx.RoundTripper = limitRoundTripper(release)
+	}
+	return nil
+}
+
+// limitRoundTripper forwards a request admitted under [proxy.limits] and
+// releases its slot when the exchange ends: when the round trip fails, or
+// when goproxy closes the response body after relaying it. goproxy does not
+// run response handlers for a failed round trip on the MITM path, so the
+// release cannot live there.
+func limitRoundTripper(release func()) goproxy.RoundTripper {
+	return goproxy.RoundTripperFunc(func(req *http.Request, ctx *goproxy.ProxyCtx) (*http.Response, error) {
+		resp, err := ctx.Proxy.Tr.RoundTrip(req)
+		if err != nil || resp.Body == nil || resp.Body == http.NoBody {
+			release()
+			return resp, err
+		}
+		resp.Body = releaseOnClose(resp.Body, release)
+		return resp, nil
+	})
+}
+
+// limitPublishInterval is how often changed [proxy.limits] counters are sent
+// to subscribed monitors.
+const limitPublishInterval = time.Second
+
+// publishLimitCounters sends the [proxy.limits] counters to subscribed
+// monitors whenever they change, and to a monitor that has just subscribed,
+// until stop is closed.
+func (s *Server) publishLimitCounters(stop <-chan struct{}) {
+	ticker := time.NewTicker(limitPublishInterval)
+	defer ticker.Stop()
+
+	var last []LimitCounters
+	var subscriptions uint64
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		counters := s.limits.Counters()
+		n := s.askServer.Subscriptions()
+		if n == subscriptions && slices.Equal(counters, last) {
+			continue
+		}
+		subscriptions, last = n, counters
+		s.askServer.Publish(&AskRequest{Type: AskTopicLimits, Limits: counters})
+	}
+}
+
 // handleAskMode prompts the user for a decision on the request.
 // Returns the filter action and logs unanswered requests to internal logs.
-func (s *Server) handleAskMode(req *http.Request, entry *RequestLog, reqBody []byte) FilterAction {
+// limit is set when the prompt is for a request over a [proxy.limits] rule.
+func (s *Server) handleAskMode(req *http.Request, entry *RequestLog, reqBody []byte, limit string) FilterAction {
 	// Generate unique request ID
 	id := s.requestID.Add(1)
 
@@ -751,28 +1136,23 @@ func (s *Server) handleAskMode(req *http.Request, entry *RequestLog, reqBody []b
 		// The authority the request is actually sent to, not the Host header
 		// the sandbox wrote: the prompt must name the destination the user is
 		// being asked to approve. See RequestHost.
-		Host: RequestHost(req),
-		Path: req.URL.Path,
-	}
-
-	// Add selected headers
-	if req.Header != nil {
-		askReq.Headers = make(map[string]string)
-		for _, h := range []string{"Content-Type", "Authorization", "User-Agent"} {
-			if v := req.Header.Get(h); v != "" {
-				// Redact sensitive headers
-				if h == "Authorization" {
-					askReq.Headers[h] = "[REDACTED]"
-				} else {
-					askReq.Headers[h] = v
-				}
-			}
-		}
+		Host:          RequestHost(req),
+		Path:          req.URL.Path,
+		Limit:         limit,
+		Project:       s.config.LocalConfigDir,
+		HostRulesOnly: !s.config.MITM,
+	}
+	if name, ok := s.sessionName.Load().(string); ok {
+		askReq.Session = name
 	}
 
-	// Add body preview (first 200 bytes)
+	askReq.Headers = s.askHeaders(req.Header)
+
+	// Add body preview (first 200 bytes). It is redacted before it is cut, so
+	// a secret straddling the cut is still recognized.
 	if len(reqBody) > 0 {
-		preview := string(reqBody)
+		preview := string(reqBody[:min(len(reqBody), askBodyRedactWindow)])
+		preview = s.redactForDisplay(preview)
 		if len(preview) > 200 {
 			preview = preview[:200] + "..."
 		}
@@ -785,7 +1165,9 @@ func (s *Server) handleAskMode(req *http.Request, entry *RequestLog, reqBody []b
 		// Log unanswered request to internal logs
 		var reason string
 		if errors.Is(err, ErrNoMonitor) {
-			reason = "no monitor connected"
+			// Carries why a desktop notification could not stand in, if
+			// one was tried.
+			reason = err.Error()
 		} else if errors.Is(err, ErrTimeout) {
 			reason = "request timed out (30s) waiting for user response"
 		} else {
@@ -806,6 +1188,60 @@ func (s *Server) handleAskMode(req *http.Request, entry *RequestLog, reqBody []b
 	return action
 }
 
+// askHeaders returns every header of h for a monitor prompt, with the rest
+// redacted and credentials withheld: the headers the request log withholds,
+// and every header a credential injector writes. The prompt is built after
+// injection, unlike the log entry, so an injected token on a header of the
+// user's choosing - PRIVATE-TOKEN, say - would otherwise reach the monitor.
+func (s *Server) askHeaders(h http.Header) map[string]string {
+	if len(h) == 0 {
+		return nil
+	}
+	out := make(map[string]string, len(h))
+	for name, values := range h {
+		if s.withholdsHeader(name) {
+			out[name] = redactedHeaderValue
+			continue
+		}
+		out[name] = s.redactForDisplay(strings.Join(values, ", "))
+	}
+	return out
+}
+
+// withholdsHeader reports whether a monitor prompt must not show the header
+// name at all.
+func (s *Server) withholdsHeader(name string) bool {
+	if sensitiveHeaders[http.CanonicalHeaderKey(name)] {
+		return true
+	}
+	for _, injector := range s.credentialInjectors {
+		if strings.EqualFold(injector.Header(), name) {
+			return true
+		}
+	}
+	return false
+}
+
+// askBodyRedactWindow is how much of a request body is redacted for the
+// prompt's preview: enough past the 200 bytes shown for a secret that starts
+// inside them to be matched whole.
+const askBodyRedactWindow = 4096
+
+// redactForDisplay applies the redaction rules to v, when there are any.
+func (s *Server) redactForDisplay(v string) string {
+	if s.redactionEngine == nil || !s.redactionEngine.IsEnabled() {
+		return v
+	}
+	return s.redactionEngine.RedactString(v)
+}
+
+// SetSessionName names the session in ask prompts, so a monitor serving
+// several sessions can tell them apart. The name is settled only after the
+// proxy has started, hence a setter.
+func (s *Server) SetSessionName(name string) {
+	s.sessionName.Store(name)
+}
+
 // AskServer returns the ask server if ask mode is enabled.
 func (s *Server) AskServer() *AskServer {
 	return s.askServer
@@ -822,6 +1258,126 @@ func (s *Server) Port() int {
 	return s.config.Port
 }
 
+// DNSPort returns the port of the DNS resolver on the host's loopback, or 0
+// when it is not running.
+func (s *Server) DNSPort() int {
+	if s.dns == nil {
+		return 0
+	}
+	return s.dns.Port()
+}
+
+// dnsDecision is the filter's answer for a DNS name. Without a filter every
+// name is answered. An ask decision is refused: the lookup would carry the
+// name out before anyone was asked. A host the user has allowed since - a
+// remembered answer or a permanent decision - is answered, since MatchHost
+// returns it.
+func (s *Server) dnsDecision(host string) FilterDecision {
+	if s.filterEngine == nil || !s.filterEngine.IsEnabled() {
+		return FilterDecision{Action: FilterActionAllow, IsDefault: true, Reason: "filtering disabled"}
+	}
+	decision := s.filterEngine.MatchDomain(host, s.config.DNS.AnswersHostlessRules())
+	if decision.Action == FilterActionAsk {
+		decision.Action = FilterActionBlock
+		decision.Reason += "; the name is answered once a request to it is allowed and remembered"
+	}
+	return decision
+}
+
+// recordDNSQuery audits a resolver query, and writes it to the request log so
+// it shows in "devsandbox logs proxy" beside the HTTP entries - an answered
+// one as much as a refused one, since the query alone reaches the upstream
+// resolver.
+func (s *Server) recordDNSQuery(q DNSQuery) {
+	s.emitDNSQuery(q)
+	if s.reqLogger == nil {
+		return
+	}
+	action := FilterActionAllow
+	if !q.Answered {
+		action = FilterActionBlock
+	}
+	entry := &RequestLog{
+		Timestamp:    time.Now().Add(-q.Duration),
+		Method:       "DNS",
+		URL:          fmt.Sprintf("dns://%s?type=%s", q.Host, q.Type),
+		Duration:     q.Duration,
+		FilterAction: string(action),
+		FilterReason: q.Decision.Reason,
+	}
+	_ = s.reqLogger.Log(entry)
+}
+
+// SOCKSPort returns the port of the SOCKS5 listener on the host's loopback,
+// or 0 when it is not running.
+func (s *Server) SOCKSPort() int {
+	if s.socks == nil {
+		return 0
+	}
+	return s.socks.Port()
+}
+
+// tcpRequest builds the request that represents a SOCKS connection, so
+// filtering, audit events, ask mode and the request log describe it the way
+// they describe a CONNECT tunnel. Its method is "TCP": it is never sent.
+func tcpRequest(target string) *http.Request {
+	return &http.Request{
+		Method: "TCP",
+		Host:   target,
+		URL:    &url.URL{Scheme: "tcp", Host: target},
+	}
+}
+
+// authorizeTCP decides a SOCKS target with the filter's host rules, as a
+// CONNECT is decided, including ask mode - or, with MITM on, as MatchSOCKS
+// decides it, so raw TCP cannot bypass what the HTTP proxy inspects. A
+// refused target is logged at once; an allowed one returns the function that
+// logs the connection once it closes, with its byte counts and duration.
+func (s *Server) authorizeTCP(target string) (func(TCPConnection), bool) {
+	req := tcpRequest(target)
+	entry := &RequestLog{
+		Timestamp: time.Now(),
+		Method:    req.Method,
+		URL:       req.URL.String(),
+	}
+
+	var decision *FilterDecision
+	switch {
+	case s.config.MITM && s.filterEngine != nil:
+		d := s.filterEngine.MatchSOCKS(target)
+		decision = &d
+	case s.config.MITM:
+		if d, ok := inspectedPortDecision(target); ok {
+			decision = &d
+		}
+	case s.filterEngine != nil && s.filterEngine.IsEnabled():
+		d := s.filterEngine.MatchHost(target)
+		decision = &d
+	}
+	if decision != nil {
+		if resp := s.applyFilterDecision(req, entry, nil, *decision); resp != nil {
+			entry.Duration = time.Since(entry.Timestamp)
+			if s.reqLogger != nil {
+				_ = s.reqLogger.Log(entry)
+			}
+			return nil, false
+		}
+	}
+
+	return func(c TCPConnection) {
+		if s.reqLogger == nil {
+			return
+		}
+		entry.Duration = c.Duration
+		entry.BytesSent = c.BytesSent
+		entry.BytesReceived = c.BytesReceived
+		if c.Err != nil {
+			entry.Error = c.Err.Error()
+		}
+		_ = s.reqLogger.Log(entry)
+	}, true
+}
+
 func (s *Server) Config() *Config {
 	return s.config
 }
@@ -170,6 +170,17 @@ func TestNewServer_NoMITM_RefusesUnenforceableScopes(t *testing.T) {
 			rules:   []FilterRule{{Pattern: "*.example.com", Action: FilterActionAllow}},
 			wantErr: false,
 		},
+		{
+			name:    "method matcher without MITM",
+			rules:   []FilterRule{{Pattern: "*.amazonaws.com", Action: FilterActionBlock, Methods: []string{"DELETE"}}},
+			wantErr: true,
+		},
+		{
+			name: "body matcher without MITM",
+			rules: []FilterRule{{Pattern: "api.openai.com", Action: FilterActionBlock,
+				Body: []FilterBodyMatch{{Path: "$.model", NotIn: []string{"a"}}}}},
+			wantErr: true,
+		},
 		{
 			name:    "path scope with MITM enabled",
 			mitm:    true,
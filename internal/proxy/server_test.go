@@ -1330,3 +1330,118 @@ func TestServer_CredentialInjector_SpecificityOrder(t *testing.T) {
 		t.Errorf("wide injector must not run when specific matched")
 	}
 }
+
+// An ask prompt is built after credential injection, so a token an injector
+// wrote on a header the request log does not know as sensitive must still be
+// withheld from the monitor.
+func TestServer_AskHeadersWithholdInjectedCredentials(t *testing.T) {
+	injs, err := BuildCredentialInjectors(map[string]any{
+		"gitlab": map[string]any{
+			"enabled":      true,
+			"host":         "gitlab.example.com",
+			"header":       "PRIVATE-TOKEN",
+			"value_format": "{token}",
+			"source":       map[string]any{"value": "glpat-secret"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Server{config: &Config{}, credentialInjectors: injs}
+
+	req := httptest.NewRequest("GET", "https://gitlab.example.com/api/v4/user", nil)
+	req.Header.Set("X-Api-Key", "key-secret")
+	req.Header.Set("Accept", "application/json")
+	if !injs[0].Match(req) || !injs[0].Inject(req) {
+		t.Fatal("injector did not write its header")
+	}
+
+	headers := s.askHeaders(req.Header)
+	for name, v := range headers {
+		if strings.Contains(v, "secret") {
+			t.Errorf("prompt shows %s: %q", name, v)
+		}
+	}
+	if got := headers["Private-Token"]; got != redactedHeaderValue {
+		t.Errorf("Private-Token = %q, want it withheld", got)
+	}
+	if got := headers["X-Api-Key"]; got != redactedHeaderValue {
+		t.Errorf("X-Api-Key = %q, want it withheld", got)
+	}
+	if got := headers["Accept"]; got != "application/json" {
+		t.Errorf("Accept = %q, want it shown", got)
+	}
+}
+
+func TestServerHTTPProxy_BodyAwareFilterRule(t *testing.T) {
+	received := make(chan string, 1)
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		received <- string(b)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer testServer.Close()
+
+	tmpDir := t.TempDir()
+	cfg := NewConfig(tmpDir, 0)
+	cfg.Filter = &FilterConfig{
+		DefaultAction: FilterActionAllow,
+		Rules: []FilterRule{{
+			Pattern: "127.0.0.1",
+			Action:  FilterActionBlock,
+			Methods: []string{"POST"},
+			Body:    []FilterBodyMatch{{Path: "$.model", NotIn: []string{"small"}}},
+			Reason:  "model not approved",
+		}},
+	}
+	// Bounds the body read when redaction itself is off.
+	cfg.Redaction = &RedactionConfig{MaxScanBytes: 64}
+
+	proxyServer, err := NewServer(cfg)
+	if err != nil {
+		t.Fatalf("NewServer failed: %v", err)
+	}
+	if err := proxyServer.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer func() { _ = proxyServer.Stop() }()
+
+	proxyURL, _ := url.Parse(fmt.Sprintf("http://%s", proxyServer.Addr()))
+	client := &http.Client{
+		Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)},
+		Timeout:   10 * time.Second,
+	}
+	post := func(body string) (int, string) {
+		t.Helper()
+		resp, err := client.Post(testServer.URL, "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("request through proxy failed: %v", err)
+		}
+		b, _ := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		return resp.StatusCode, string(b)
+	}
+
+	allowed := `{"model":"small"}`
+	if status, _ := post(allowed); status != http.StatusOK {
+		t.Errorf("approved model: status %d, want 200", status)
+	}
+	if got := <-received; got != allowed {
+		t.Errorf("upstream received %q, want %q", got, allowed)
+	}
+
+	if status, body := post(`{"model":"large"}`); status != http.StatusForbidden || !strings.Contains(body, "model not approved") {
+		t.Errorf("unapproved model: status %d body %q, want 403 naming the rule", status, body)
+	}
+
+	if status, body := post(`{"model":"small","pad":"` + strings.Repeat("x", 128) + `"}`); status != http.StatusForbidden ||
+		!strings.Contains(body, "max_scan_bytes") {
+		t.Errorf("oversized body: status %d body %q, want 403 naming the limit", status, body)
+	}
+
+	select {
+	case got := <-received:
+		t.Errorf("blocked request reached upstream: %q", got)
+	default:
+	}
+}
@@ -0,0 +1,360 @@
+package proxy
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// SOCKSConfig configures the proxy's SOCKS5 listener: raw TCP egress for
+// clients that do not speak HTTP, decided by the filter per host:port.
+type SOCKSConfig struct {
+	// Enabled starts the listener. Default: false.
+	Enabled *bool `toml:"enabled"`
+}
+
+// IsEnabled returns true if the SOCKS5 listener should run.
+func (c *SOCKSConfig) IsEnabled() bool {
+	return c != nil && c.Enabled != nil && *c.Enabled
+}
+
+const (
+	// socksHandshakeTimeout bounds the greeting and the request. A client
+	// that has not named its target by then is dropped.
+	socksHandshakeTimeout = 10 * time.Second
+	// socksDialTimeout bounds the connection to the target.
+	socksDialTimeout = 15 * time.Second
+)
+
+// SOCKS5 protocol constants (RFC 1928).
+const (
+	socksVersion = 0x05
+
+	socksMethodNoAuth       = 0x00
+	socksMethodNoAcceptable = 0xff
+
+	socksCmdConnect = 0x01
+
+	socksAtypIPv4   = 0x01
+	socksAtypDomain = 0x03
+	socksAtypIPv6   = 0x04
+
+	socksReplySucceeded           = 0x00
+	socksReplyGeneralFailure      = 0x01
+	socksReplyNotAllowed          = 0x02
+	socksReplyNetworkUnreachable  = 0x03
+	socksReplyHostUnreachable     = 0x04
+	socksReplyConnectionRefused   = 0x05
+	socksReplyCommandNotSupported = 0x07
+	socksReplyAddressNotSupported = 0x08
+)
+
+// TCPConnection describes one connection the SOCKS listener relayed, or
+// refused.
+type TCPConnection struct {
+	// Target is the "host:port" the client asked for, as it spelled it.
+	Target string
+	// Start is when the target was named.
+	Start time.Time
+	// Duration is how long the connection was open, or how long it took to
+	// refuse.
+	Duration time.Duration
+	// BytesSent counts bytes from the sandbox to the target.
+	BytesSent int64
+	// BytesReceived counts bytes from the target to the sandbox.
+	BytesReceived int64
+	// Err is set when the target could not be reached.
+	Err error
+}
+
+// SOCKSServer is a SOCKS5 listener for TCP egress that HTTP_PROXY cannot
+// carry - a database, an SMTP test server, h2c. Only CONNECT without
+// authentication is supported: the listener is reachable from the sandbox
+// alone, so there is nobody to authenticate. Each target is put to authorize
+// before it is dialed; a refused one gets "connection not allowed by
+// ruleset" and nothing leaves the host.
+type SOCKSServer struct {
+	// authorize decides whether target may be reached. When it allows, it
+	// returns done, which is called once the connection is over.
+	authorize func(target string) (done func(TCPConnection), ok bool)
+	dial      func(ctx context.Context, network, addr string) (net.Conn, error)
+
+	mu       sync.Mutex
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+	wg       sync.WaitGroup
+}
+
+// NewSOCKSServer creates a SOCKS5 server. authorize is called for every
+// target a client names.
+func NewSOCKSServer(authorize func(target string) (func(TCPConnection), bool)) *SOCKSServer {
+	dialer := &net.Dialer{Timeout: socksDialTimeout}
+	return &SOCKSServer{
+		authorize: authorize,
+		dial:      dialer.DialContext,
+		conns:     make(map[net.Conn]struct{}),
+	}
+}
+
+// Start listens on addr. A port of 0 picks a free one; Port reports it.
+func (s *SOCKSServer) Start(addr string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("socks: listen %s: %w", addr, err)
+	}
+	s.listener = l
+	s.wg.Go(func() { s.serve(l) })
+	return nil
+}
+
+// Port returns the port the server listens on, or 0 before Start.
+func (s *SOCKSServer) Port() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.listener == nil {
+		return 0
+	}
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+// Close stops the listener, closes every open connection and waits for them
+// to be recorded.
+func (s *SOCKSServer) Close() error {
+	s.mu.Lock()
+	l := s.listener
+	for c := range s.conns {
+		_ = c.Close()
+	}
+	s.conns = nil
+	s.mu.Unlock()
+	if l != nil {
+		_ = l.Close()
+	}
+	s.wg.Wait()
+	return nil
+}
+
+func (s *SOCKSServer) serve(l net.Listener) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue
+		}
+		if !s.track(conn) {
+			_ = conn.Close()
+			return
+		}
+		s.wg.Go(func() {
+			defer s.untrack(conn)
+			s.serveConn(conn)
+		})
+	}
+}
+
+// track registers conn so Close can reach it. It fails once the listener has
+// been closed.
+func (s *SOCKSServer) track(conn net.Conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conns == nil {
+		return false
+	}
+	s.conns[conn] = struct{}{}
+	return true
+}
+
+func (s *SOCKSServer) untrack(conn net.Conn) {
+	s.mu.Lock()
+	delete(s.conns, conn)
+	s.mu.Unlock()
+	_ = conn.Close()
+}
+
+// serveConn runs one SOCKS5 exchange and, when the target is allowed and
+// reached, relays until either side closes.
+func (s *SOCKSServer) serveConn(conn net.Conn) {
+	_ = conn.SetDeadline(time.Now().Add(socksHandshakeTimeout))
+	if err := socksNegotiate(conn); err != nil {
+		return
+	}
+	target, reply := socksReadRequest(conn)
+	if reply != socksReplySucceeded {
+		_ = socksWriteReply(conn, reply, nil)
+		return
+	}
+	// authorize may wait on an ask-mode prompt far longer than the
+	// handshake is allowed to take.
+	_ = conn.SetDeadline(time.Time{})
+
+	done, ok := s.authorize(target)
+	if !ok {
+		_ = socksWriteReply(conn, socksReplyNotAllowed, nil)
+		return
+	}
+	record := TCPConnection{Target: target, Start: time.Now()}
+	defer func() {
+		record.Duration = time.Since(record.Start)
+		if done != nil {
+			done(record)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), socksDialTimeout)
+	upstream, err := s.dial(ctx, "tcp", target)
+	cancel()
+	if err != nil {
+		record.Err = err
+		_ = socksWriteReply(conn, socksDialErrorReply(err), nil)
+		return
+	}
+	if !s.track(upstream) {
+		_ = upstream.Close()
+		return
+	}
+	defer s.untrack(upstream)
+
+	if err := socksWriteReply(conn, socksReplySucceeded, upstream.LocalAddr()); err != nil {
+		return
+	}
+
+	var sent, received atomic.Int64
+	var relay sync.WaitGroup
+	relay.Go(func() { socksCopy(upstream, conn, &sent) })
+	relay.Go(func() { socksCopy(conn, upstream, &received) })
+	relay.Wait()
+	record.BytesSent, record.BytesReceived = sent.Load(), received.Load()
+}
+
+// socksCopy copies src to dst, counting into n, then half-closes dst so the
+// other direction can drain.
+func socksCopy(dst, src net.Conn, n *atomic.Int64) {
+	written, _ := io.Copy(dst, src)
+	n.Add(written)
+	if tc, ok := dst.(interface{ CloseWrite() error }); ok {
+		_ = tc.CloseWrite()
+	} else {
+		_ = dst.Close()
+	}
+}
+
+// socksNegotiate reads the client greeting and selects "no authentication",
+// the only method offered.
+func socksNegotiate(conn net.Conn) error {
+	var hdr [2]byte
+	if _, err := io.ReadFull(conn, hdr[:]); err != nil {
+		return err
+	}
+	if hdr[0] != socksVersion {
+		return fmt.Errorf("socks: unsupported version %d", hdr[0])
+	}
+	methods := make([]byte, hdr[1])
+	if _, err := io.ReadFull(conn, methods); err != nil {
+		return err
+	}
+	for _, m := range methods {
+		if m == socksMethodNoAuth {
+			_, err := conn.Write([]byte{socksVersion, socksMethodNoAuth})
+			return err
+		}
+	}
+	_, _ = conn.Write([]byte{socksVersion, socksMethodNoAcceptable})
+	return errors.New("socks: client offers no acceptable method")
+}
+
+// socksReadRequest reads a request and returns its target as "host:port",
+// or the reply refusing it.
+func socksReadRequest(conn net.Conn) (string, byte) {
+	var hdr [4]byte
+	if _, err := io.ReadFull(conn, hdr[:]); err != nil {
+		return "", socksReplyGeneralFailure
+	}
+	if hdr[0] != socksVersion {
+		return "", socksReplyGeneralFailure
+	}
+
+	var host string
+	switch hdr[3] {
+	case socksAtypIPv4, socksAtypIPv6:
+		addr := make([]byte, net.IPv4len)
+		if hdr[3] == socksAtypIPv6 {
+			addr = make([]byte, net.IPv6len)
+		}
+		if _, err := io.ReadFull(conn, addr); err != nil {
+			return "", socksReplyGeneralFailure
+		}
+		host = net.IP(addr).String()
+	case socksAtypDomain:
+		var n [1]byte
+		if _, err := io.ReadFull(conn, n[:]); err != nil {
+			return "", socksReplyGeneralFailure
+		}
+		name := make([]byte, n[0])
+		if _, err := io.ReadFull(conn, name); err != nil {
+			return "", socksReplyGeneralFailure
+		}
+		host = string(name)
+	default:
+		return "", socksReplyAddressNotSupported
+	}
+
+	var port [2]byte
+	if _, err := io.ReadFull(conn, port[:]); err != nil {
+		return "", socksReplyGeneralFailure
+	}
+	// The command is checked only now, once the whole request is read, so
+	// the reply is not written over unread bytes.
+	if hdr[1] != socksCmdConnect {
+		return "", socksReplyCommandNotSupported
+	}
+	if host == "" {
+		return "", socksReplyAddressNotSupported
+	}
+	return net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port[:])))), socksReplySucceeded
+}
+
+// socksWriteReply writes a reply. bound is the address the connection to the
+// target was made from; nil writes 0.0.0.0:0.
+func socksWriteReply(conn net.Conn, reply byte, bound net.Addr) error {
+	ip, port := net.IPv4zero.To4(), 0
+	if tcp, ok := bound.(*net.TCPAddr); ok {
+		ip, port = tcp.IP, tcp.Port
+	}
+	atyp := byte(socksAtypIPv6)
+	if ip4 := ip.To4(); ip4 != nil {
+		ip, atyp = ip4, socksAtypIPv4
+	}
+	msg := append([]byte{socksVersion, reply, 0x00, atyp}, ip...)
+	msg = binary.BigEndian.AppendUint16(msg, uint16(port))
+	_, err := conn.Write(msg)
+	return err
+}
+
+// socksDialErrorReply maps a dial error to the closest SOCKS5 reply.
+func socksDialErrorReply(err error) byte {
+	switch {
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return socksReplyConnectionRefused
+	case errors.Is(err, syscall.ENETUNREACH):
+		return socksReplyNetworkUnreachable
+	case errors.Is(err, syscall.EHOSTUNREACH):
+		return socksReplyHostUnreachable
+	}
+	if _, ok := errors.AsType[*net.DNSError](err); ok {
+		return socksReplyHostUnreachable
+	}
+	return socksReplyGeneralFailure
+}
@@ -0,0 +1,285 @@
+package proxy
+
+import (
+	"io"
+	"net"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	netproxy "golang.org/x/net/proxy"
+)
+
+// startEchoServer starts a TCP server on loopback that echoes what it reads
+// until the client half-closes. It returns the server's address.
+func startEchoServer(t *testing.T) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { _ = l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer func() { _ = conn.Close() }()
+				_, _ = io.Copy(conn, conn)
+			}()
+		}
+	}()
+	return l.Addr().String()
+}
+
+// startTestSOCKS starts a SOCKS server on loopback that allows only the
+// targets in allowed. The returned function reports the connections closed
+// so far.
+func startTestSOCKS(t *testing.T, allowed ...string) (*SOCKSServer, func() []TCPConnection) {
+	t.Helper()
+	var mu sync.Mutex
+	var closed []TCPConnection
+	s := NewSOCKSServer(func(target string) (func(TCPConnection), bool) {
+		for _, a := range allowed {
+			if target == a {
+				return func(c TCPConnection) {
+					mu.Lock()
+					defer mu.Unlock()
+					closed = append(closed, c)
+				}, true
+			}
+		}
+		return nil, false
+	})
+	if err := s.Start("127.0.0.1:0"); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+	return s, func() []TCPConnection {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]TCPConnection(nil), closed...)
+	}
+}
+
+func socksDial(t *testing.T, port int, target string) (net.Conn, error) {
+	t.Helper()
+	dialer, err := netproxy.SOCKS5("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(port)), nil, netproxy.Direct)
+	if err != nil {
+		t.Fatalf("SOCKS5: %v", err)
+	}
+	return dialer.Dial("tcp", target)
+}
+
+// waitConnections polls until n connections are recorded: the record is made
+// after the relay ends, which the client does not wait for.
+func waitConnections(t *testing.T, get func() []TCPConnection, n int) []TCPConnection {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if got := get(); len(got) >= n {
+			return got
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("got %d connections, want %d", len(get()), n)
+	return nil
+}
+
+func TestSOCKSServer_RelaysAllowedTarget(t *testing.T) {
+	echo := startEchoServer(t)
+	s, closed := startTestSOCKS(t, echo)
+
+	conn, err := socksDial(t, s.Port(), echo)
+	if err != nil {
+		t.Fatalf("dial through socks: %v", err)
+	}
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := conn.Write([]byte("hello")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	_ = conn.(*net.TCPConn).CloseWrite()
+	got, err := io.ReadAll(conn)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	_ = conn.Close()
+	if string(got) != "hello" {
+		t.Errorf("echo = %q, want hello", got)
+	}
+
+	c := waitConnections(t, closed, 1)[0]
+	if c.Target != echo {
+		t.Errorf("target = %q, want %q", c.Target, echo)
+	}
+	if c.BytesSent != 5 || c.BytesReceived != 5 {
+		t.Errorf("bytes = %d sent / %d received, want 5 / 5", c.BytesSent, c.BytesReceived)
+	}
+	if c.Err != nil {
+		t.Errorf("err = %v, want nil", c.Err)
+	}
+}
+
+func TestSOCKSServer_RefusesTarget(t *testing.T) {
+	echo := startEchoServer(t)
+	s, closed := startTestSOCKS(t)
+
+	if conn, err := socksDial(t, s.Port(), echo); err == nil {
+		_ = conn.Close()
+		t.Fatal("dial to a refused target succeeded")
+	}
+	if got := closed(); len(got) != 0 {
+		t.Errorf("refused target recorded as a connection: %v", got)
+	}
+}
+
+func TestSOCKSServer_UnreachableTarget(t *testing.T) {
+	// A port nothing listens on: reserve one, then free it.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	target := l.Addr().String()
+	_ = l.Close()
+
+	s, closed := startTestSOCKS(t, target)
+	if conn, err := socksDial(t, s.Port(), target); err == nil {
+		_ = conn.Close()
+		t.Fatal("dial to a closed port succeeded")
+	}
+	if c := waitConnections(t, closed, 1)[0]; c.Err == nil {
+		t.Error("unreachable target recorded without an error")
+	}
+}
+
+func TestSOCKSServer_RejectsAuthOnlyClient(t *testing.T) {
+	s, _ := startTestSOCKS(t)
+	conn, err := net.Dial("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(s.Port())))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer func() { _ = conn.Close() }()
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	// Offers only username/password.
+	if _, err := conn.Write([]byte{socksVersion, 1, 0x02}); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	var reply [2]byte
+	if _, err := io.ReadFull(conn, reply[:]); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if reply[1] != socksMethodNoAcceptable {
+		t.Errorf("method = %#x, want %#x", reply[1], socksMethodNoAcceptable)
+	}
+}
+
+func TestServer_SOCKSDisabledByDefault(t *testing.T) {
+	cfg := NewConfig(shortTempDir(t), 0)
+	server, err := NewServer(cfg)
+	if err != nil {
+		t.Fatalf("NewServer failed: %v", err)
+	}
+	if err := server.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer func() { _ = server.Stop() }()
+	if port := server.SOCKSPort(); port != 0 {
+		t.Errorf("SOCKSPort = %d, want 0 when the listener is off", port)
+	}
+}
+
+// TestServer_SOCKSFiltersAndLogs pins that the listener decides targets with
+// the filter's host:port rules and writes one request-log entry per
+// connection - the refused one at once, the relayed one with its byte counts.
+func TestServer_SOCKSFiltersAndLogs(t *testing.T) {
+	echo := startEchoServer(t)
+	_, echoPort, _ := net.SplitHostPort(echo)
+	port, _ := strconv.Atoi(echoPort)
+
+	dir := shortTempDir(t)
+	enabled := true
+	cfg := NewConfig(dir, 0)
+	cfg.SOCKS = &SOCKSConfig{Enabled: &enabled}
+	cfg.Filter = &FilterConfig{
+		DefaultAction: FilterActionBlock,
+		Rules:         []FilterRule{{Pattern: "127.0.0.1", Action: FilterActionAllow, Ports: []int{port}}},
+	}
+	server, err := NewServer(cfg)
+	if err != nil {
+		t.Fatalf("NewServer failed: %v", err)
+	}
+	if err := server.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer func() { _ = server.Stop() }()
+	if server.SOCKSPort() == 0 {
+		t.Fatal("SOCKSPort = 0 with the listener enabled")
+	}
+
+	if conn, err := socksDial(t, server.SOCKSPort(), "127.0.0.1:1"); err == nil {
+		_ = conn.Close()
+		t.Fatal("dial to a port no rule allows succeeded")
+	}
+	entries := readLoggedEntries(t, filepath.Join(dir, LogBaseDirName, ProxyLogDirName), 1)
+	if e := entries[0]; e.Method != "TCP" || e.URL != "tcp://127.0.0.1:1" || e.FilterAction != string(FilterActionBlock) {
+		t.Errorf("refused entry = %s %s %s, want TCP tcp://127.0.0.1:1 block", e.Method, e.URL, e.FilterAction)
+	}
+
+	conn, err := socksDial(t, server.SOCKSPort(), echo)
+	if err != nil {
+		t.Fatalf("dial to the allowed port: %v", err)
+	}
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+	_, _ = conn.Write([]byte("ping"))
+	_ = conn.(*net.TCPConn).CloseWrite()
+	_, _ = io.ReadAll(conn)
+	_ = conn.Close()
+
+	entries = readLoggedEntries(t, filepath.Join(dir, LogBaseDirName, ProxyLogDirName), 2)
+	e := entries[1]
+	if e.URL != "tcp://"+echo || e.FilterAction != string(FilterActionAllow) {
+		t.Errorf("relayed entry = %s %s, want tcp://%s allow", e.URL, e.FilterAction, echo)
+	}
+	if e.BytesSent != 4 || e.BytesReceived != 4 {
+		t.Errorf("bytes = %d sent / %d received, want 4 / 4", e.BytesSent, e.BytesReceived)
+	}
+}
+
+// TestServer_SOCKSWithMITM pins that with MITM on, a SOCKS target is decided
+// by MatchSOCKS: a host allow without ports, written for HTTPS, opens no raw
+// TCP, and ports 80 and 443 stay on the HTTP proxy even with filtering off.
+func TestServer_SOCKSWithMITM(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		filter  *FilterConfig
+		mitm    bool
+		target  string
+		allowed bool
+	}{
+		{"portless allow", &FilterConfig{DefaultAction: FilterActionBlock, Rules: []FilterRule{{Pattern: "api.example.com", Action: FilterActionAllow}}}, true, "api.example.com:443", false},
+		{"portless allow, other port", &FilterConfig{DefaultAction: FilterActionBlock, Rules: []FilterRule{{Pattern: "api.example.com", Action: FilterActionAllow}}}, true, "api.example.com:22", false},
+		{"allow naming the port", &FilterConfig{DefaultAction: FilterActionBlock, Rules: []FilterRule{{Pattern: "api.example.com", Action: FilterActionAllow, Ports: []int{22}}}}, true, "api.example.com:22", true},
+		{"filtering off", nil, true, "api.example.com:443", false},
+		{"filtering off, other port", nil, true, "api.example.com:22", true},
+		{"MITM off", &FilterConfig{DefaultAction: FilterActionBlock, Rules: []FilterRule{{Pattern: "api.example.com", Action: FilterActionAllow}}}, false, "api.example.com:443", true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := NewConfig(shortTempDir(t), 0)
+			cfg.MITM = tt.mitm
+			cfg.Filter = tt.filter
+			server, err := NewServer(cfg)
+			if err != nil {
+				t.Fatalf("NewServer failed: %v", err)
+			}
+			defer func() { _ = server.Stop() }()
+			if _, ok := server.authorizeTCP(tt.target); ok != tt.allowed {
+				t.Errorf("authorizeTCP(%q) allowed = %v, want %v", tt.target, ok, tt.allowed)
+			}
+		})
+	}
+}
@@ -88,3 +88,15 @@ func CAVars(caPath string, extraCAEnv []string) []Var {
 
 	return vars
 }
+
+// SOCKSVars returns the variables that point SOCKS-aware tools at the proxy's
+// SOCKS5 listener, given the URL the sandbox reaches it at. ALL_PROXY is
+// consulted only for protocols without a variable of their own, so HTTP(S)
+// keeps going through HTTP_PROXY/HTTPS_PROXY. bwrap is the only backend that
+// runs the listener, so this is not part of Vars.
+func SOCKSVars(socksURL string) []Var {
+	return []Var{
+		{Name: "ALL_PROXY", Value: socksURL},
+		{Name: "all_proxy", Value: socksURL},
+	}
+}
@@ -10,6 +10,7 @@ import (
 
 	"devsandbox/internal/config"
 	"devsandbox/internal/notice"
+	"devsandbox/internal/overlay"
 	"devsandbox/internal/proxyenv"
 	"devsandbox/internal/sandbox/mounts"
 	"devsandbox/internal/sandbox/tools"
@@ -394,12 +395,32 @@ func (b *Builder) AddNetworkBindings() *Builder {
 	}
 
 	for _, f := range networkFiles {
+		if f == "/etc/resolv.conf" && b.cfg.NetworkIsolated && b.cfg.DNSPort > 0 {
+			b.addResolvConf()
+			continue
+		}
 		b.ROBindIfExists(f, f)
 	}
 
 	return b
 }
 
+// dnsResolvConf points the sandbox at the proxy's DNS resolver. resolv.conf
+// cannot name a port, so pasta forwards port 53 on the namespace's loopback to
+// the resolver (see BuildPastaDNSArgs).
+const dnsResolvConf = "# Generated by devsandbox: queries go to the proxy's DNS resolver.\nnameserver 127.0.0.1\n"
+
+// addResolvConf writes the generated resolv.conf under the sandbox root and
+// mounts it over /etc/resolv.conf.
+func (b *Builder) addResolvConf() {
+	path := filepath.Join(b.cfg.SandboxRoot, "resolv.conf")
+	if err := os.WriteFile(path, []byte(dnsResolvConf), 0o644); err != nil {
+		b.err = fmt.Errorf("write resolv.conf: %w", err)
+		return
+	}
+	b.ROBind(path, "/etc/resolv.conf")
+}
+
 func (b *Builder) AddLocaleBindings() *Builder {
 	localeFiles := []string{
 		"/etc/locale.gen",
@@ -935,7 +956,11 @@ func (b *Builder) applyCustomOverlay(path string, rule mounts.Rule, tmpfs bool)
 }
 
 func (b *Builder) AddProjectBindings() *Builder {
-	b.Bind(b.cfg.ProjectDir, b.cfg.ProjectDir)
+	if b.cfg.ProjectOverlay {
+		b.addProjectOverlay()
+	} else {
+		b.Bind(b.cfg.ProjectDir, b.cfg.ProjectDir)
+	}
 	b.Chdir(b.cfg.ProjectDir)
 
 	// Handle .devsandbox.toml visibility
@@ -969,6 +994,30 @@ func (b *Builder) AddProjectBindings() *Builder {
 	return b
 }
 
+// addProjectOverlay mounts the project directory as a persistent overlay, so
+// writes land in an upper under the sandbox home instead of the real tree.
+//
+// .git is bound back over it from the host. Git metadata is governed by the
+// git tool's mode, whose read-only bind goes on top of this one, and promoting
+// object files and refs one by one through a review would be no way to land a
+// commit. A failure to set the overlay up is fatal: falling back to the
+// read-write bind would hand the sandbox the real tree it was asked to keep
+// out of reach.
+func (b *Builder) addProjectOverlay() {
+	upperDir, workDir, err := createOverlayDirs(b.cfg.SandboxHome, b.cfg.ProjectDir, overlay.ProjectSubdir, "")
+	if err != nil {
+		b.err = fmt.Errorf("project overlay: %w", err)
+		return
+	}
+	b.OverlaySrc(b.cfg.ProjectDir)
+	b.Overlay(upperDir, workDir, b.cfg.ProjectDir)
+
+	gitPath := filepath.Join(b.cfg.ProjectDir, ".git")
+	if _, err := os.Lstat(gitPath); err == nil {
+		b.Bind(gitPath, gitPath)
+	}
+}
+
 // applyProjectCustomMounts applies custom mount rules for paths inside the project directory.
 // Called after the project is bound to avoid mount ordering conflicts.
 func (b *Builder) applyProjectCustomMounts() {
@@ -1156,6 +1205,11 @@ func (b *Builder) AddProxyEnvironment() *Builder {
 		vars = append(vars, proxyenv.CAVars(bwrapCACertPath, b.cfg.ProxyExtraCAEnv)...)
 	}
 
+	// SOCKS listener, forwarded onto the sandbox's loopback by pasta
+	if b.cfg.NetworkIsolated && b.cfg.SOCKSPort > 0 {
+		vars = append(vars, proxyenv.SOCKSVars(fmt.Sprintf("socks5h://127.0.0.1:%d", SOCKSSandboxPort))...)
+	}
+
 	for _, v := range vars {
 		// A Default must not clobber a value applied earlier (env passthrough and
 		// config.sandbox.environment both run before this).
@@ -1189,6 +1189,47 @@ func TestBuilder_AddProjectBindings_WorktreeLeavesMainRepoAlone(t *testing.T) {
 	}
 }
 
+// TestBuilder_AddProjectBindings_ProjectOverlay verifies that --project-overlay
+// mounts the project as an overlay with its upper under the sandbox home, never
+// as a read-write bind, and binds .git back from the host over it.
+func TestBuilder_AddProjectBindings_ProjectOverlay(t *testing.T) {
+	project := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(project, ".git"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	sandboxHome := t.TempDir()
+
+	cfg := &Config{
+		HomeDir:        t.TempDir(),
+		ProjectDir:     project,
+		SandboxHome:    sandboxHome,
+		XDGRuntime:     filepath.Join(t.TempDir(), "runtime"),
+		ProjectOverlay: true,
+	}
+	b := NewBuilder(cfg)
+	b.AddProjectBindings()
+	if err := b.Err(); err != nil {
+		t.Fatalf("AddProjectBindings() error = %v", err)
+	}
+	joined := strings.Join(b.Build(), " ")
+
+	dir, err := ProjectOverlayDir(sandboxHome, project)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantOverlay := "--overlay-src " + project + " --overlay " + filepath.Join(dir, "upper") + " " + filepath.Join(dir, "work") + " " + project
+	if !strings.Contains(joined, wantOverlay) {
+		t.Errorf("expected %q in args; got:\n%s", wantOverlay, joined)
+	}
+	if strings.Contains(joined, "--bind "+project+" "+project) {
+		t.Errorf("project bound read-write despite ProjectOverlay:\n%s", joined)
+	}
+	gitDir := filepath.Join(project, ".git")
+	if !strings.Contains(joined, "--bind "+gitDir+" "+gitDir) {
+		t.Errorf(".git not bound back from the host:\n%s", joined)
+	}
+}
+
 // TestBuilder_HiddenDirectoryWarnsOnTerminal covers the security-relevant case of
 // a "hidden" rule that resolves to a directory: nothing is hidden, so the user has
 // to hear about it at launch. The warning goes through notice, not the sandbox log
@@ -1313,3 +1354,62 @@ func TestBuilder_HiddenFilesInsideDirectoryAreDevNulled(t *testing.T) {
 		}
 	}
 }
+
+func TestBuilder_AddNetworkBindings_DNSResolver(t *testing.T) {
+	root := t.TempDir()
+	b := NewBuilder(&Config{SandboxRoot: root, NetworkIsolated: true, DNSPort: 41234})
+	b.AddNetworkBindings()
+	if err := b.Err(); err != nil {
+		t.Fatalf("AddNetworkBindings: %v", err)
+	}
+
+	generated := filepath.Join(root, "resolv.conf")
+	data, err := os.ReadFile(generated)
+	if err != nil {
+		t.Fatalf("read generated resolv.conf: %v", err)
+	}
+	if !strings.Contains(string(data), "nameserver 127.0.0.1\n") {
+		t.Errorf("resolv.conf = %q, want nameserver 127.0.0.1", data)
+	}
+
+	args := strings.Join(b.Build(), " ")
+	if !strings.Contains(args, "--ro-bind "+generated+" /etc/resolv.conf") {
+		t.Errorf("generated resolv.conf not mounted: %s", args)
+	}
+	if strings.Contains(args, "--ro-bind /etc/resolv.conf /etc/resolv.conf") {
+		t.Errorf("host resolv.conf still mounted: %s", args)
+	}
+}
+
+func TestBuilder_AddNetworkBindings_NoDNSResolver(t *testing.T) {
+	root := t.TempDir()
+	b := NewBuilder(&Config{SandboxRoot: root, NetworkIsolated: true})
+	b.AddNetworkBindings()
+
+	if _, err := os.Stat(filepath.Join(root, "resolv.conf")); !os.IsNotExist(err) {
+		t.Errorf("resolv.conf generated without a resolver: %v", err)
+	}
+	if pathExists("/etc/resolv.conf") {
+		if args := strings.Join(b.Build(), " "); !strings.Contains(args, "--ro-bind /etc/resolv.conf /etc/resolv.conf") {
+			t.Errorf("host resolv.conf not mounted: %s", args)
+		}
+	}
+}
+
+func TestBuilder_AddProxyEnvironment_SOCKS(t *testing.T) {
+	base := Config{ProxyEnabled: true, ProxyPort: 8080, GatewayIP: "10.0.2.2", NetworkIsolated: true}
+
+	on := base
+	on.SOCKSPort = 41235
+	args := strings.Join(NewBuilder(&on).AddProxyEnvironment().Build(), " ")
+	for _, name := range []string{"ALL_PROXY", "all_proxy"} {
+		if !strings.Contains(args, "--setenv "+name+" socks5h://127.0.0.1:1080") {
+			t.Errorf("%s not set to the forwarded SOCKS listener: %s", name, args)
+		}
+	}
+
+	off := strings.Join(NewBuilder(&base).AddProxyEnvironment().Build(), " ")
+	if strings.Contains(off, "ALL_PROXY") {
+		t.Errorf("ALL_PROXY set without a SOCKS listener: %s", off)
+	}
+}
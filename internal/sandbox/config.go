@@ -49,6 +49,12 @@ type Config struct {
 	ProxyCAPath  string
 	ProxyMITM    bool
 	GatewayIP    string
+	// DNSPort is the host loopback port of the proxy's DNS resolver, or 0 when
+	// it is off. The sandbox's resolv.conf then points at it.
+	DNSPort int
+	// SOCKSPort is the host loopback port of the proxy's SOCKS5 listener, or
+	// 0 when it is off. The sandbox then reaches it at SOCKSSandboxAddr.
+	SOCKSPort int
 	// ProxyExtraEnv is a list of additional env var names set to the proxy URL.
 	ProxyExtraEnv []string
 	// ProxyExtraCAEnv is a list of additional env var names set to the CA cert path.
@@ -82,6 +88,11 @@ type Config struct {
 	// Default: true
 	HideEnvFiles bool
 
+	// ProjectOverlay mounts the project directory as a persistent overlay
+	// instead of binding it read-write, so the sandbox's writes wait in an
+	// upper under SandboxHome for 'devsandbox review'. bwrap only.
+	ProjectOverlay bool
+
 	// Logger for reporting warnings and errors during sandbox setup.
 	// If nil, log messages are silently dropped.
 	Logger Logger
@@ -486,6 +486,15 @@ func sameFileAt(path string, f *os.File) bool {
 	return os.SameFile(atPath, held)
 }
 
+// LockIdle takes the sandbox's session lock exclusively, for work on the
+// sandbox's state that must not overlap a session: it fails with
+// ErrSandboxBusy while one is running, and a session starting while the lock
+// is held waits for it (see acquireSharedLock). Close the returned file to
+// release it.
+func LockIdle(sandboxRoot string) (*os.File, error) {
+	return acquireExclusiveLock(filepath.Join(sandboxRoot, LockFileName))
+}
+
 // acquireExclusiveLock opens path and takes a non-blocking exclusive flock on
 // it, reporting ErrSandboxBusy when another holder has it.
 func acquireExclusiveLock(path string) (*os.File, error) {
@@ -40,9 +40,11 @@ type Metadata struct {
 	// Computed fields (not persisted)
 	SandboxRoot string `json:"-"`
 	SizeBytes   int64  `json:"-"`
-	Orphaned    bool   `json:"-"` // True if project_dir no longer exists
-	Active      bool   `json:"-"` // Session currently running (lock held)
-	State       string `json:"-"` // For Docker: "running", "stopped", "exited"
+	// SnapshotBytes is the part of SizeBytes taken by the sandbox's snapshots.
+	SnapshotBytes int64  `json:"-"`
+	Orphaned      bool   `json:"-"` // True if project_dir no longer exists
+	Active        bool   `json:"-"` // Session currently running (lock held)
+	State         string `json:"-"` // For Docker: "running", "stopped", "exited"
 }
 
 // OOMRecord is the OOM activity observed for a sandbox session. It exists so an
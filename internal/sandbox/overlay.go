@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
+	"devsandbox/internal/fsutil"
 	"devsandbox/internal/overlay"
 )
 
@@ -59,3 +61,81 @@ func persistentOverlayUpperDir(sandboxHome, dest, subdir string) (string, error)
 	}
 	return filepath.Join(sandboxHome, "overlay", safePath, "upper"), nil
 }
+
+// ProjectOverlayDir returns the directory holding the upper and work dirs of
+// projectDir's --project-overlay mount. Does not create it.
+func ProjectOverlayDir(sandboxHome, projectDir string) (string, error) {
+	upper, err := persistentOverlayUpperDir(sandboxHome, projectDir, overlay.ProjectSubdir)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Dir(upper), nil
+}
+
+// HasProjectOverlayChanges reports whether a --project-overlay session left
+// writes in projectDir's upper that have been neither applied nor discarded.
+func HasProjectOverlayChanges(sandboxHome, projectDir string) bool {
+	upper, err := persistentOverlayUpperDir(sandboxHome, projectDir, overlay.ProjectSubdir)
+	if err != nil {
+		return false
+	}
+	entries, err := os.ReadDir(upper)
+	return err == nil && len(entries) > 0
+}
+
+// RemoveProjectOverlay deletes projectDir's project overlay, dropping every
+// change recorded in it. A missing overlay is not an error.
+//
+// The upper can hold read-only directories the agent created (module caches,
+// vendored trees), so this goes through fsutil.RemoveAllForce.
+func RemoveProjectOverlay(sandboxHome, projectDir string) error {
+	dir, err := ProjectOverlayDir(sandboxHome, projectDir)
+	if err != nil {
+		return err
+	}
+	if err := fsutil.RemoveAllForce(dir); err != nil {
+		return fmt.Errorf("remove project overlay: %w", err)
+	}
+	return nil
+}
+
+// DiscardProjectOverlayPaths drops the changes recorded at and beneath each of
+// rels - paths relative to projectDir - from its project overlay, leaving the
+// rest for a later review. A path the upper has nothing at is skipped.
+//
+// The upper is sandbox-writable, so a directory on the way to a path may have
+// been replaced with a symlink to anywhere on the host. Each component is
+// checked to be a real directory before descending, so the removal cannot be
+// steered outside the upper.
+func DiscardProjectOverlayPaths(sandboxHome, projectDir string, rels []string) error {
+	dir, err := ProjectOverlayDir(sandboxHome, projectDir)
+	if err != nil {
+		return err
+	}
+	upper := filepath.Join(dir, "upper")
+	for _, rel := range rels {
+		rel = filepath.Clean(rel)
+		if rel == "." || filepath.IsAbs(rel) || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return fmt.Errorf("discard %q: not a path inside the project", rel)
+		}
+		parent := upper
+		reachable := true
+		for _, part := range strings.Split(filepath.Dir(rel), string(filepath.Separator)) {
+			if part == "." {
+				continue
+			}
+			parent = filepath.Join(parent, part)
+			if fi, err := os.Lstat(parent); err != nil || !fi.IsDir() {
+				reachable = false
+				break
+			}
+		}
+		if !reachable {
+			continue
+		}
+		if err := fsutil.RemoveAllForce(filepath.Join(parent, filepath.Base(rel))); err != nil {
+			return fmt.Errorf("discard %s: %w", rel, err)
+		}
+	}
+	return nil
+}
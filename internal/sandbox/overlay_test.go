@@ -144,3 +144,77 @@ func TestCreateOverlayDirs_SessionWithSubdir(t *testing.T) {
 		t.Errorf("unexpected upper path: %s, expected: %s", upper, filepath.Join(expectedBase, "upper"))
 	}
 }
+
+func TestProjectOverlayChanges(t *testing.T) {
+	sandboxHome := t.TempDir()
+	project := "/home/user/project"
+
+	if HasProjectOverlayChanges(sandboxHome, project) {
+		t.Error("HasProjectOverlayChanges() = true with no project overlay")
+	}
+	upper, _, err := createOverlayDirs(sandboxHome, project, "project", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if HasProjectOverlayChanges(sandboxHome, project) {
+		t.Error("HasProjectOverlayChanges() = true for an empty upper")
+	}
+	if err := os.WriteFile(filepath.Join(upper, "main.go"), []byte("package main\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if !HasProjectOverlayChanges(sandboxHome, project) {
+		t.Error("HasProjectOverlayChanges() = false with a file in the upper")
+	}
+
+	if err := RemoveProjectOverlay(sandboxHome, project); err != nil {
+		t.Fatalf("RemoveProjectOverlay() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Dir(upper)); !os.IsNotExist(err) {
+		t.Errorf("project overlay dir still present: %v", err)
+	}
+	if err := RemoveProjectOverlay(sandboxHome, project); err != nil {
+		t.Errorf("RemoveProjectOverlay() on a missing overlay error = %v", err)
+	}
+}
+
+func TestDiscardProjectOverlayPaths(t *testing.T) {
+	sandboxHome := t.TempDir()
+	project := "/home/user/project"
+	upper, _, err := createOverlayDirs(sandboxHome, project, "project", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	outside := t.TempDir()
+	victim := filepath.Join(outside, "keep.txt")
+	for _, p := range []string{filepath.Join(upper, "a.go"), filepath.Join(upper, "pkg", "b.go"), filepath.Join(upper, "pkg", "c.go"), victim} {
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte("x"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The sandbox replaced a directory with a link out of the upper.
+	if err := os.Symlink(outside, filepath.Join(upper, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := DiscardProjectOverlayPaths(sandboxHome, project, []string{"pkg/b.go", "a.go", "missing/x", "link/keep.txt"}); err != nil {
+		t.Fatalf("DiscardProjectOverlayPaths() error = %v", err)
+	}
+	for _, gone := range []string{"a.go", "pkg/b.go"} {
+		if _, err := os.Lstat(filepath.Join(upper, gone)); !os.IsNotExist(err) {
+			t.Errorf("%s still in the upper: %v", gone, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(upper, "pkg", "c.go")); err != nil {
+		t.Errorf("unselected change discarded: %v", err)
+	}
+	if _, err := os.Stat(victim); err != nil {
+		t.Errorf("discard followed a symlink out of the upper: %v", err)
+	}
+
+	if err := DiscardProjectOverlayPaths(sandboxHome, project, []string{"../escape"}); err == nil {
+		t.Error("DiscardProjectOverlayPaths() accepted a path outside the project")
+	}
+}
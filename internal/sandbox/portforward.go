@@ -41,3 +41,23 @@ func BuildPastaPortArgs(rules []config.PortForwardingRule) []string {
 	}
 	return args
 }
+
+// BuildPastaDNSArgs returns the pasta options that carry the sandbox's DNS
+// traffic to the proxy's resolver: port 53 on the namespace's loopback is
+// forwarded, over UDP and TCP, to hostPort on the host's loopback.
+func BuildPastaDNSArgs(hostPort int) []string {
+	spec := fmt.Sprintf("53:%d", hostPort)
+	return []string{"-U", spec, "-T", spec}
+}
+
+// SOCKSSandboxPort is the port the proxy's SOCKS5 listener is reached at on
+// the sandbox's loopback - the conventional SOCKS port, since the host-side
+// one is picked free at each start.
+const SOCKSSandboxPort = 1080
+
+// BuildPastaSOCKSArgs returns the pasta option that carries the sandbox's
+// SOCKS traffic to the proxy's listener: SOCKSSandboxPort on the namespace's
+// loopback is forwarded to hostPort on the host's loopback.
+func BuildPastaSOCKSArgs(hostPort int) []string {
+	return []string{"-T", fmt.Sprintf("%d:%d", SOCKSSandboxPort, hostPort)}
+}
@@ -75,3 +75,24 @@ func TestBuildPastaPortArgs(t *testing.T) {
 		})
 	}
 }
+
+func TestBuildPastaDNSArgs(t *testing.T) {
+	got := BuildPastaDNSArgs(41234)
+	want := []string{"-U", "53:41234", "-T", "53:41234"}
+	if len(got) != len(want) {
+		t.Fatalf("BuildPastaDNSArgs = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("arg[%d]: expected %q, got %q", i, want[i], got[i])
+		}
+	}
+}
+
+func TestBuildPastaSOCKSArgs(t *testing.T) {
+	got := BuildPastaSOCKSArgs(41235)
+	want := []string{"-T", "1080:41235"}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("BuildPastaSOCKSArgs = %v, want %v", got, want)
+	}
+}
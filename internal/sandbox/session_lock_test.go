@@ -1,6 +1,7 @@
 package sandbox
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
 	"strings"
@@ -188,6 +189,28 @@ func TestAcquireSession_WaitsOutAnExclusiveHolder(t *testing.T) {
 	}
 }
 
+func TestLockIdle(t *testing.T) {
+	root := t.TempDir()
+
+	handle, err := AcquireSession(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LockIdle(root); !errors.Is(err, ErrSandboxBusy) {
+		t.Errorf("LockIdle with a running session: want ErrSandboxBusy, got %v", err)
+	}
+	_ = handle.Release()
+
+	lock, err := LockIdle(root)
+	if err != nil {
+		t.Fatalf("LockIdle on an idle sandbox: %v", err)
+	}
+	defer func() { _ = lock.Close() }()
+	if !IsSessionActive(root) {
+		t.Error("lock held by LockIdle not visible to IsSessionActive")
+	}
+}
+
 func TestSessionHandle_ReleaseFreesPrimaryAndIsIdempotent(t *testing.T) {
 	root := t.TempDir()
 
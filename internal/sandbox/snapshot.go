@@ -0,0 +1,506 @@
+package sandbox
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"devsandbox/internal/fsutil"
+)
+
+// SnapshotsDirName is the directory under a sandbox root that holds its
+// snapshots. It sits beside home rather than inside it, so a snapshot is never
+// visible to the sandbox and never captured by the next snapshot.
+const SnapshotsDirName = "snapshots"
+
+const (
+	// snapshotMetaFile records when a snapshot was taken, next to its copy of
+	// the home.
+	snapshotMetaFile = "snapshot.json"
+	snapshotHomeDir  = "home"
+	// partialPrefix names a snapshot or restore still being copied. Snapshot
+	// names cannot start with a dot, so a partial never collides with one, and
+	// ListSnapshots skips it.
+	partialPrefix = ".partial-"
+)
+
+var (
+	// ErrSnapshotExists reports that a snapshot of that name already exists.
+	ErrSnapshotExists = errors.New("snapshot already exists")
+	// ErrSnapshotNotFound reports that the sandbox has no snapshot of that name.
+	ErrSnapshotNotFound = errors.New("snapshot not found")
+)
+
+var snapshotNameRe = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+
+// Snapshot is a saved copy of a sandbox home.
+type Snapshot struct {
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	// Computed fields (not persisted)
+	Path      string `json:"-"`
+	SizeBytes int64  `json:"-"`
+}
+
+// ValidateSnapshotName rejects names that are not a single, visible path
+// element: letters, digits, '.', '_' and '-', starting with a letter or digit.
+func ValidateSnapshotName(name string) error {
+	if !snapshotNameRe.MatchString(name) {
+		return fmt.Errorf("invalid snapshot name %q: use letters, digits, '.', '_' and '-', starting with a letter or digit (max 64)", name)
+	}
+	return nil
+}
+
+// SnapshotsDir returns the directory holding the snapshots of the sandbox
+// rooted at sandboxRoot.
+func SnapshotsDir(sandboxRoot string) string {
+	return filepath.Join(sandboxRoot, SnapshotsDirName)
+}
+
+// CreateSnapshot copies the sandbox home into a new snapshot called name.
+//
+// The copy is assembled under a partial name and renamed into place, so a
+// snapshot that exists is always complete. It does not take the sandbox lock:
+// a snapshot of a running sandbox is allowed, and is as consistent as the files
+// the sandbox happened to be writing at the time.
+func CreateSnapshot(sandboxRoot, name string) (*Snapshot, error) {
+	if err := ValidateSnapshotName(name); err != nil {
+		return nil, err
+	}
+	home := filepath.Join(sandboxRoot, snapshotHomeDir)
+	if _, err := os.Stat(home); err != nil {
+		return nil, fmt.Errorf("sandbox has no home to snapshot: %w", err)
+	}
+
+	dir := filepath.Join(SnapshotsDir(sandboxRoot), name)
+	if _, err := os.Lstat(dir); err == nil {
+		return nil, fmt.Errorf("%w: %s", ErrSnapshotExists, name)
+	}
+
+	partial := filepath.Join(SnapshotsDir(sandboxRoot), partialPrefix+name)
+	if err := fsutil.RemoveAllForce(partial); err != nil {
+		return nil, fmt.Errorf("failed to clear interrupted snapshot: %w", err)
+	}
+	if err := os.MkdirAll(partial, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+
+	if err := copyHome(home, filepath.Join(partial, snapshotHomeDir)); err != nil {
+		_ = fsutil.RemoveAllForce(partial)
+		return nil, fmt.Errorf("failed to copy sandbox home: %w", err)
+	}
+
+	snap := &Snapshot{Name: name, CreatedAt: time.Now()}
+	data, err := json.MarshalIndent(snap, "", "  ")
+	if err != nil {
+		_ = fsutil.RemoveAllForce(partial)
+		return nil, fmt.Errorf("failed to marshal snapshot metadata: %w", err)
+	}
+	if err := fsutil.WriteFileAtomic(filepath.Join(partial, snapshotMetaFile), data, 0o644); err != nil {
+		_ = fsutil.RemoveAllForce(partial)
+		return nil, fmt.Errorf("failed to write snapshot metadata: %w", err)
+	}
+
+	if err := os.Rename(partial, dir); err != nil {
+		_ = fsutil.RemoveAllForce(partial)
+		if errors.Is(err, fs.ErrExist) || errors.Is(err, os.ErrExist) {
+			return nil, fmt.Errorf("%w: %s", ErrSnapshotExists, name)
+		}
+		return nil, fmt.Errorf("failed to finalize snapshot: %w", err)
+	}
+	snap.Path = dir
+	return snap, nil
+}
+
+// ListSnapshots returns the snapshots of the sandbox rooted at sandboxRoot,
+// oldest first. A sandbox with no snapshots yields none and no error.
+func ListSnapshots(sandboxRoot string) ([]*Snapshot, error) {
+	entries, err := os.ReadDir(SnapshotsDir(sandboxRoot))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read snapshots directory: %w", err)
+	}
+
+	var snaps []*Snapshot
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		snap, err := loadSnapshot(sandboxRoot, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		snaps = append(snaps, snap)
+	}
+
+	sort.Slice(snaps, func(i, j int) bool {
+		return snaps[i].CreatedAt.Before(snaps[j].CreatedAt)
+	})
+	return snaps, nil
+}
+
+// GetSnapshot returns the snapshot called name, or ErrSnapshotNotFound.
+func GetSnapshot(sandboxRoot, name string) (*Snapshot, error) {
+	if err := ValidateSnapshotName(name); err != nil {
+		return nil, err
+	}
+	return loadSnapshot(sandboxRoot, name)
+}
+
+func loadSnapshot(sandboxRoot, name string) (*Snapshot, error) {
+	dir := filepath.Join(SnapshotsDir(sandboxRoot), name)
+	data, err := os.ReadFile(filepath.Join(dir, snapshotMetaFile))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("%w: %s", ErrSnapshotNotFound, name)
+		}
+		return nil, fmt.Errorf("failed to read snapshot %s: %w", name, err)
+	}
+	var snap Snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return nil, fmt.Errorf("failed to parse snapshot %s: %w", name, err)
+	}
+	// The directory is authoritative: it is what restore and rm address.
+	snap.Name = name
+	snap.Path = dir
+	return &snap, nil
+}
+
+// RestoreSnapshot replaces the sandbox home with the snapshot called name.
+//
+// It refuses with ErrSandboxBusy while any session holds the sandbox - the
+// same lock IsSessionActive probes - and keeps holding it exclusively until the
+// swap is done, so no session can start against a half-restored home. The
+// snapshot is copied beside the live home first and swapped in by rename, so an
+// interrupted restore leaves the old home in place rather than a mix of both.
+func RestoreSnapshot(sandboxRoot, name string) error {
+	snap, err := GetSnapshot(sandboxRoot, name)
+	if err != nil {
+		return err
+	}
+
+	lock, err := acquireExclusiveLock(filepath.Join(sandboxRoot, LockFileName))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = lock.Close() }()
+
+	home := filepath.Join(sandboxRoot, snapshotHomeDir)
+	incoming := filepath.Join(sandboxRoot, partialPrefix+"restore")
+	outgoing := filepath.Join(sandboxRoot, partialPrefix+"replaced")
+	for _, p := range []string{incoming, outgoing} {
+		if err := fsutil.RemoveAllForce(p); err != nil {
+			return fmt.Errorf("failed to clear interrupted restore: %w", err)
+		}
+	}
+
+	if err := copyHome(filepath.Join(snap.Path, snapshotHomeDir), incoming); err != nil {
+		_ = fsutil.RemoveAllForce(incoming)
+		return fmt.Errorf("failed to copy snapshot %s: %w", name, err)
+	}
+
+	if err := os.Rename(home, outgoing); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		_ = fsutil.RemoveAllForce(incoming)
+		return fmt.Errorf("failed to move current home aside: %w", err)
+	}
+	if err := os.Rename(incoming, home); err != nil {
+		_ = os.Rename(outgoing, home)
+		_ = fsutil.RemoveAllForce(incoming)
+		return fmt.Errorf("failed to put restored home in place: %w", err)
+	}
+	if err := fsutil.RemoveAllForce(outgoing); err != nil {
+		return fmt.Errorf("restored, but failed to remove the previous home at %s: %w", outgoing, err)
+	}
+	return nil
+}
+
+// RemoveSnapshot deletes the snapshot called name.
+func RemoveSnapshot(sandboxRoot, name string) error {
+	snap, err := GetSnapshot(sandboxRoot, name)
+	if err != nil {
+		return err
+	}
+	return fsutil.RemoveAllForce(snap.Path)
+}
+
+// GetSnapshotsSize returns the bytes the sandbox's snapshots occupy. It is part
+// of GetSandboxSize, reported apart so a listing can say how much of a
+// sandbox's size is snapshots.
+func GetSnapshotsSize(sandboxRoot string) (int64, error) {
+	dir := SnapshotsDir(sandboxRoot)
+	if _, err := os.Stat(dir); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return GetSandboxSize(dir)
+}
+
+// SnapshotChangeKind is how a path differs between a snapshot and the live home.
+type SnapshotChangeKind string
+
+const (
+	SnapshotAdded    SnapshotChangeKind = "added"
+	SnapshotRemoved  SnapshotChangeKind = "removed"
+	SnapshotModified SnapshotChangeKind = "modified"
+)
+
+// SnapshotChange is one difference reported by DiffSnapshot. Path is relative
+// to the home.
+type SnapshotChange struct {
+	Kind SnapshotChangeKind `json:"kind"`
+	Path string             `json:"path"`
+}
+
+// DiffSnapshot reports what changed in the sandbox home since the snapshot
+// called name was taken: what restoring it would undo.
+//
+// Files are compared by type, size and modification time, which the snapshot
+// preserves, not by content. A directory that was added or removed is reported
+// once, without its contents, so a whole new module cache is one line.
+func DiffSnapshot(sandboxRoot, name string) ([]SnapshotChange, error) {
+	snap, err := GetSnapshot(sandboxRoot, name)
+	if err != nil {
+		return nil, err
+	}
+	before, err := scanHome(filepath.Join(snap.Path, snapshotHomeDir))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot %s: %w", name, err)
+	}
+	after, err := scanHome(filepath.Join(sandboxRoot, snapshotHomeDir))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sandbox home: %w", err)
+	}
+
+	paths := make([]string, 0, len(before)+len(after))
+	for p := range before {
+		paths = append(paths, p)
+	}
+	for p := range after {
+		if _, ok := before[p]; !ok {
+			paths = append(paths, p)
+		}
+	}
+	sort.Strings(paths)
+
+	var (
+		changes   []SnapshotChange
+		collapsed string
+	)
+	for _, p := range paths {
+		if collapsed != "" && strings.HasPrefix(p, collapsed+"/") {
+			continue
+		}
+		collapsed = ""
+		b, inBefore := before[p]
+		a, inAfter := after[p]
+		switch {
+		case !inBefore:
+			changes = append(changes, SnapshotChange{Kind: SnapshotAdded, Path: p})
+			if a.mode.IsDir() {
+				collapsed = p
+			}
+		case !inAfter:
+			changes = append(changes, SnapshotChange{Kind: SnapshotRemoved, Path: p})
+			if b.mode.IsDir() {
+				collapsed = p
+			}
+		case b.differs(a):
+			changes = append(changes, SnapshotChange{Kind: SnapshotModified, Path: p})
+		}
+	}
+	return changes, nil
+}
+
+// homeEntry is what DiffSnapshot compares for one path.
+type homeEntry struct {
+	mode    fs.FileMode
+	size    int64
+	modTime time.Time
+	target  string
+}
+
+func (e homeEntry) differs(o homeEntry) bool {
+	if e.mode.Type() != o.mode.Type() {
+		return true
+	}
+	switch {
+	case e.mode.IsRegular():
+		return e.size != o.size || !e.modTime.Equal(o.modTime)
+	case e.mode&fs.ModeSymlink != 0:
+		return e.target != o.target
+	}
+	return false
+}
+
+// scanHome indexes a home tree by slash-separated relative path, skipping what
+// a snapshot does not capture.
+func scanHome(root string) (map[string]homeEntry, error) {
+	entries := make(map[string]homeEntry)
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == root {
+			return nil
+		}
+		rel := filepath.ToSlash(strings.TrimPrefix(path, root+string(filepath.Separator)))
+		if skipInSnapshot(path, rel, d) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		e := homeEntry{mode: info.Mode(), size: info.Size(), modTime: info.ModTime()}
+		if info.Mode()&fs.ModeSymlink != 0 {
+			e.target, _ = os.Readlink(path)
+		}
+		entries[rel] = e
+		return nil
+	})
+	return entries, err
+}
+
+// skipInSnapshot reports whether a home entry is left out of a snapshot:
+//
+//   - overlay/sessions holds the uppers of concurrent sessions, which are
+//     discarded when those sessions exit and are not sandbox state;
+//   - an overlay's work directory is overlayfs scratch space, recreated on every
+//     mount, and its kernel-created contents are unreadable;
+//   - sockets and named pipes belong to processes that are no longer running.
+func skipInSnapshot(path, rel string, d fs.DirEntry) bool {
+	if rel == "overlay/sessions" {
+		return true
+	}
+	if d.IsDir() {
+		if d.Name() == "work" && strings.HasPrefix(rel, "overlay/") {
+			if _, err := os.Lstat(filepath.Join(filepath.Dir(path), "upper")); err == nil {
+				return true
+			}
+		}
+		return false
+	}
+	t := d.Type()
+	return t&fs.ModeSocket != 0 || t&fs.ModeNamedPipe != 0
+}
+
+// copyHome copies the home tree at src to dst, which must not exist. Modes and
+// modification times are preserved, and so are the overlayfs markers in the
+// uppers - whiteouts and opaque directories - without which a restored upper
+// would bring back the files the sandbox deleted.
+func copyHome(src, dst string) error {
+	type dirAttrs struct {
+		path    string
+		mode    fs.FileMode
+		modTime time.Time
+	}
+	// Directory modes are applied last, deepest first: the Go module cache
+	// lays down 0555 directories that could not be filled after a chmod.
+	var dirs []dirAttrs
+
+	err := filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel := strings.TrimPrefix(path, src)
+		rel = strings.TrimPrefix(rel, string(filepath.Separator))
+		if rel != "" && skipInSnapshot(path, filepath.ToSlash(rel), d) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		target := filepath.Join(dst, rel)
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		switch mode := info.Mode(); {
+		case mode.IsDir():
+			if err := os.Mkdir(target, 0o700); err != nil {
+				return err
+			}
+			if err := copyOverlayXattrs(path, target); err != nil {
+				return err
+			}
+			dirs = append(dirs, dirAttrs{target, mode.Perm(), info.ModTime()})
+		case mode.IsRegular():
+			if err := copyFile(path, target, mode.Perm()); err != nil {
+				return err
+			}
+			if err := os.Chtimes(target, info.ModTime(), info.ModTime()); err != nil {
+				return err
+			}
+		case mode&fs.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			if err := os.Symlink(link, target); err != nil {
+				return err
+			}
+		case isWhiteout(info):
+			if err := makeWhiteout(target); err != nil {
+				return fmt.Errorf("recreate overlay whiteout %s: %w", rel, err)
+			}
+		default:
+			// Other device nodes have no business in a sandbox home, and an
+			// unprivileged process could not recreate them anyway.
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := os.Chmod(dirs[i].path, dirs[i].mode); err != nil {
+			return err
+		}
+		if err := os.Chtimes(dirs[i].path, dirs[i].modTime, dirs[i].modTime); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyFile copies a regular file. io.Copy between two files uses
+// copy_file_range on Linux, which shares extents instead of copying them on
+// filesystems that support it (btrfs, XFS), so a snapshot there costs little
+// space until the home diverges from it.
+func copyFile(src, dst string, perm fs.FileMode) (err error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = in.Close() }()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := out.Close(); err == nil {
+			err = cerr
+		}
+	}()
+
+	_, err = io.Copy(out, in)
+	return err
+}
@@ -0,0 +1,64 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"errors"
+	"io/fs"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// isWhiteout reports whether info is an overlayfs whiteout: a character device
+// with device number 0/0.
+func isWhiteout(info fs.FileInfo) bool {
+	if info.Mode()&fs.ModeCharDevice == 0 {
+		return false
+	}
+	st, ok := info.Sys().(*unix.Stat_t)
+	return ok && st.Rdev == 0
+}
+
+// makeWhiteout recreates a whiteout. The kernel lets an unprivileged process
+// create a 0/0 character device for exactly this purpose (Linux 5.8+).
+func makeWhiteout(path string) error {
+	return unix.Mknod(path, unix.S_IFCHR|0o600, 0)
+}
+
+// copyOverlayXattrs copies the overlayfs markers an unprivileged overlay writes
+// on its upper directories (user.overlay.opaque and friends). The trusted
+// spelling, written by a privileged mount, needs CAP_SYS_ADMIN to read and is
+// not produced for bwrap sandbox homes, so it is not attempted.
+func copyOverlayXattrs(src, dst string) error {
+	size, err := unix.Llistxattr(src, nil)
+	if err != nil || size == 0 {
+		// No xattr support on this filesystem means no markers to lose.
+		return nil
+	}
+	buf := make([]byte, size)
+	size, err = unix.Llistxattr(src, buf)
+	if err != nil {
+		return nil
+	}
+	for name := range strings.SplitSeq(string(buf[:size]), "\x00") {
+		if !strings.HasPrefix(name, "user.overlay.") {
+			continue
+		}
+		n, err := unix.Lgetxattr(src, name, nil)
+		if err != nil {
+			if errors.Is(err, unix.ENODATA) {
+				continue
+			}
+			return err
+		}
+		val := make([]byte, n)
+		if n, err = unix.Lgetxattr(src, name, val); err != nil {
+			return err
+		}
+		if err := unix.Lsetxattr(dst, name, val[:n], 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
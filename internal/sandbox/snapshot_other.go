@@ -0,0 +1,19 @@
+//go:build !linux
+
+package sandbox
+
+import (
+	"errors"
+	"io/fs"
+)
+
+// overlayfs is Linux-only, so a home outside Linux has no whiteouts or opaque
+// markers to carry over.
+
+func isWhiteout(fs.FileInfo) bool { return false }
+
+func makeWhiteout(string) error {
+	return errors.New("overlay whiteouts are only supported on Linux")
+}
+
+func copyOverlayXattrs(string, string) error { return nil }
@@ -0,0 +1,258 @@
+package sandbox
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// newSnapshotSandbox lays out a sandbox root with a small home: a config file,
+// a read-only module cache directory, a symlink, and an overlay upper with its
+// work directory beside it.
+func newSnapshotSandbox(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	home := filepath.Join(root, "home")
+	files := map[string]string{
+		".config/tool/config.toml":                 "theme = \"dark\"\n",
+		"go/pkg/mod/example.com/m@v1/go.mod":       "module example.com/m\n",
+		"overlay/home_user_.cache_tool/upper/data": "cached\n",
+		"overlay/sessions/abc/x/upper/scratch":     "session only\n",
+	}
+	for rel, content := range files {
+		p := filepath.Join(home, rel)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.MkdirAll(filepath.Join(home, "overlay/home_user_.cache_tool/work/work"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(".config/tool/config.toml", filepath.Join(home, "link")); err != nil {
+		t.Fatal(err)
+	}
+	modDir := filepath.Join(home, "go/pkg/mod/example.com/m@v1")
+	if err := os.Chmod(modDir, 0o555); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.Chmod(modDir, 0o755) })
+	return root
+}
+
+func TestCreateSnapshot(t *testing.T) {
+	root := newSnapshotSandbox(t)
+
+	snap, err := CreateSnapshot(root, "before-upgrade")
+	if err != nil {
+		t.Fatalf("CreateSnapshot() error = %v", err)
+	}
+	copied := filepath.Join(snap.Path, "home")
+	t.Cleanup(func() { _ = os.Chmod(filepath.Join(copied, "go/pkg/mod/example.com/m@v1"), 0o755) })
+
+	if data, err := os.ReadFile(filepath.Join(copied, ".config/tool/config.toml")); err != nil || string(data) != "theme = \"dark\"\n" {
+		t.Errorf("config not copied: %q, %v", data, err)
+	}
+	if target, err := os.Readlink(filepath.Join(copied, "link")); err != nil || target != ".config/tool/config.toml" {
+		t.Errorf("symlink not preserved: %q, %v", target, err)
+	}
+	info, err := os.Stat(filepath.Join(copied, "go/pkg/mod/example.com/m@v1"))
+	if err != nil || info.Mode().Perm() != 0o555 {
+		t.Errorf("read-only directory mode not preserved: %v, %v", info, err)
+	}
+	for _, skipped := range []string{"overlay/sessions", "overlay/home_user_.cache_tool/work"} {
+		if _, err := os.Lstat(filepath.Join(copied, skipped)); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("%s must not be captured, got err = %v", skipped, err)
+		}
+	}
+
+	if _, err := CreateSnapshot(root, "before-upgrade"); !errors.Is(err, ErrSnapshotExists) {
+		t.Errorf("second CreateSnapshot() error = %v, want ErrSnapshotExists", err)
+	}
+}
+
+func TestCreateSnapshot_RejectsBadNames(t *testing.T) {
+	root := newSnapshotSandbox(t)
+	for _, name := range []string{"", ".hidden", "a/b", "..", "-flag"} {
+		if _, err := CreateSnapshot(root, name); err == nil {
+			t.Errorf("CreateSnapshot(%q) succeeded, want an invalid name error", name)
+		}
+	}
+}
+
+func TestListSnapshots(t *testing.T) {
+	root := newSnapshotSandbox(t)
+
+	if snaps, err := ListSnapshots(root); err != nil || len(snaps) != 0 {
+		t.Fatalf("ListSnapshots() on a sandbox without snapshots = %v, %v", snaps, err)
+	}
+	for _, name := range []string{"first", "second"} {
+		if _, err := CreateSnapshot(root, name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// An interrupted create is not a snapshot.
+	if err := os.MkdirAll(filepath.Join(SnapshotsDir(root), partialPrefix+"third"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { chmodTreeWritable(root) })
+
+	snaps, err := ListSnapshots(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, s := range snaps {
+		names = append(names, s.Name)
+	}
+	if !slices.Equal(names, []string{"first", "second"}) {
+		t.Errorf("ListSnapshots() = %v, want [first second]", names)
+	}
+}
+
+func TestRestoreSnapshot(t *testing.T) {
+	root := newSnapshotSandbox(t)
+	home := filepath.Join(root, "home")
+	if _, err := CreateSnapshot(root, "good"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { chmodTreeWritable(root) })
+
+	// The agent wrecks its config and leaves junk behind.
+	if err := os.WriteFile(filepath.Join(home, ".config/tool/config.toml"), []byte("broken"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(home, "junk"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := RestoreSnapshot(root, "good"); err != nil {
+		t.Fatalf("RestoreSnapshot() error = %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(home, ".config/tool/config.toml")); string(data) != "theme = \"dark\"\n" {
+		t.Errorf("config after restore = %q", data)
+	}
+	if _, err := os.Lstat(filepath.Join(home, "junk")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("file created after the snapshot survived the restore: %v", err)
+	}
+	for _, leftover := range []string{partialPrefix + "restore", partialPrefix + "replaced"} {
+		if _, err := os.Lstat(filepath.Join(root, leftover)); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("%s left behind: %v", leftover, err)
+		}
+	}
+	// The snapshot itself is untouched and can be restored again.
+	if _, err := GetSnapshot(root, "good"); err != nil {
+		t.Errorf("snapshot gone after restore: %v", err)
+	}
+}
+
+func TestRestoreSnapshot_RefusesWhileSessionActive(t *testing.T) {
+	root := newSnapshotSandbox(t)
+	if _, err := CreateSnapshot(root, "good"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { chmodTreeWritable(root) })
+
+	handle, err := AcquireSession(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = handle.Release() }()
+
+	if err := RestoreSnapshot(root, "good"); !errors.Is(err, ErrSandboxBusy) {
+		t.Errorf("RestoreSnapshot() during a session error = %v, want ErrSandboxBusy", err)
+	}
+}
+
+func TestRemoveSnapshot(t *testing.T) {
+	root := newSnapshotSandbox(t)
+	if _, err := CreateSnapshot(root, "old"); err != nil {
+		t.Fatal(err)
+	}
+	if err := RemoveSnapshot(root, "old"); err != nil {
+		t.Fatalf("RemoveSnapshot() error = %v", err)
+	}
+	if err := RemoveSnapshot(root, "old"); !errors.Is(err, ErrSnapshotNotFound) {
+		t.Errorf("second RemoveSnapshot() error = %v, want ErrSnapshotNotFound", err)
+	}
+}
+
+func TestDiffSnapshot(t *testing.T) {
+	root := newSnapshotSandbox(t)
+	home := filepath.Join(root, "home")
+	if _, err := CreateSnapshot(root, "base"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { chmodTreeWritable(root) })
+
+	later := time.Now().Add(time.Hour)
+	cfg := filepath.Join(home, ".config/tool/config.toml")
+	if err := os.WriteFile(cfg, []byte("theme = \"light\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(cfg, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(home, ".cache/new/deep"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(home, ".cache/new/deep/f"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(home, "link")); err != nil {
+		t.Fatal(err)
+	}
+	// Scratch space a snapshot does not capture is not a change either.
+	if err := os.WriteFile(filepath.Join(home, "overlay/sessions/abc/x/upper/more"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	changes, err := DiffSnapshot(root, "base")
+	if err != nil {
+		t.Fatalf("DiffSnapshot() error = %v", err)
+	}
+	want := []SnapshotChange{
+		{SnapshotAdded, ".cache"},
+		{SnapshotModified, ".config/tool/config.toml"},
+		{SnapshotRemoved, "link"},
+	}
+	if !slices.Equal(changes, want) {
+		t.Errorf("DiffSnapshot() = %v, want %v", changes, want)
+	}
+}
+
+func TestGetSnapshotsSize(t *testing.T) {
+	root := newSnapshotSandbox(t)
+	if size, err := GetSnapshotsSize(root); err != nil || size != 0 {
+		t.Fatalf("GetSnapshotsSize() without snapshots = %d, %v", size, err)
+	}
+	if _, err := CreateSnapshot(root, "s"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { chmodTreeWritable(root) })
+
+	size, err := GetSnapshotsSize(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	total, _ := GetSandboxSize(root)
+	if size == 0 || size >= total {
+		t.Errorf("GetSnapshotsSize() = %d, want a nonzero part of the sandbox's %d", size, total)
+	}
+}
+
+// chmodTreeWritable undoes the read-only module cache directories a snapshot
+// copies, so t.TempDir can clean up.
+func chmodTreeWritable(root string) {
+	_ = filepath.WalkDir(root, func(p string, d os.DirEntry, err error) error {
+		if err == nil && d.IsDir() {
+			_ = os.Chmod(p, 0o755)
+		}
+		return nil
+	})
+}
@@ -60,12 +60,16 @@ func resolveDockerSocket(goos, homeDir, userSocket string) string {
 }
 
 // Docker provides filtered access to the Docker socket.
-// Only read operations and exec/attach are allowed.
+// Only read operations and exec/attach are allowed, plus container creation
+// and lifecycle under [tools.docker.policy].
 type Docker struct {
-	enabled    bool
-	hostSocket string
-	proxy      *dockerproxy.Proxy
-	logger     ErrorLogger
+	enabled     bool
+	hostSocket  string
+	ownedLabels []string
+	policy      dockerPolicyConfig
+	projectDir  string
+	proxy       *dockerproxy.Proxy
+	logger      ErrorLogger
 }
 
 // SetLogger sets the logger for Docker proxy errors.
@@ -79,6 +83,9 @@ func (d *Docker) Name() string {
 }
 
 func (d *Docker) Description() string {
+	if d.enabled && d.policy.Enabled {
+		return "Docker socket proxy (read-only + exec + policy-checked containers)"
+	}
 	if d.enabled {
 		return "Docker socket proxy (read-only + exec)"
 	}
@@ -109,6 +116,17 @@ func (d *Docker) socketPath(sandboxHome string) string {
 type dockerConfig struct {
 	Enabled bool   `toml:"enabled"`
 	Socket  string `toml:"socket"`
+	// OwnedLabels grants the sandbox existing containers carrying any of
+	// these labels ("key" or "key=value"), besides the ones it created.
+	OwnedLabels []string           `toml:"owned_labels"`
+	Policy      dockerPolicyConfig `toml:"policy"`
+}
+
+// dockerPolicyConfig is the [tools.docker.policy] section: it lets the sandbox
+// create containers from the listed images and manage the ones it created.
+type dockerPolicyConfig struct {
+	Enabled bool     `toml:"enabled"`
+	Images  []string `toml:"images"`
 }
 
 // ConfigType implements ToolWithConfigType.
@@ -121,6 +139,9 @@ func (d *Docker) Configure(globalCfg GlobalConfig, toolCfg map[string]any) {
 
 	d.enabled = cfg.Enabled
 	d.hostSocket = resolveDockerSocket(runtime.GOOS, globalCfg.HomeDir, cfg.Socket)
+	d.ownedLabels = cfg.OwnedLabels
+	d.policy = cfg.Policy
+	d.projectDir = globalCfg.ProjectDir
 }
 
 func (d *Docker) Bindings(homeDir, sandboxHome string) []Binding {
@@ -152,7 +173,10 @@ func (d *Docker) Start(ctx context.Context, homeDir, sandboxHome string) error {
 		return nil
 	}
 
-	notice.Info("Docker socket proxy enabled. The sandbox can access ALL existing Docker containers on this host.")
+	notice.Info("Docker socket proxy enabled. The sandbox can read images, volumes and networks on this host.")
+	if len(d.ownedLabels) > 0 {
+		notice.Info("It can also inspect, exec into and stop existing containers labelled %s.", strings.Join(d.ownedLabels, ", "))
+	}
 	notice.Info("This might allow accessing host resources. Ensure you trust the sandbox content.")
 
 	if _, err := ensureRunDir(sandboxHome); err != nil {
@@ -168,6 +192,19 @@ func (d *Docker) Start(ctx context.Context, homeDir, sandboxHome string) error {
 	if d.logger != nil {
 		d.proxy.SetLogger(d.logger)
 	}
+	d.proxy.SetOwnedLabels(d.ownedLabels)
+	if d.policy.Enabled {
+		if len(d.policy.Images) == 0 {
+			notice.Warn("[tools.docker.policy] lists no images; container creation will be denied.")
+		} else {
+			notice.Info("Docker policy enabled: the sandbox can create containers from %s and manage the ones it created.",
+				strings.Join(d.policy.Images, ", "))
+		}
+		d.proxy.SetPolicy(dockerproxy.NewPolicy(dockerproxy.PolicyConfig{
+			ProjectDir: d.projectDir,
+			Images:     d.policy.Images,
+		}))
+	}
 	return d.proxy.Start(ctx)
 }
 
@@ -209,9 +246,16 @@ func (d *Docker) Check(homeDir string) CheckResult {
 	result.ConfigPaths = []string{socket}
 
 	// Add mode info
-	if d.enabled {
+	if d.enabled && d.policy.Enabled {
+		result.AddInfo("mode: enabled (read-only + exec + policy-checked containers)")
+		result.AddInfo("allowed images: " + strings.Join(d.policy.Images, ", "))
+	} else if d.enabled {
 		result.AddInfo("mode: enabled (read-only + exec)")
-	} else {
+	}
+	if d.enabled && len(d.ownedLabels) > 0 {
+		result.AddInfo("owned labels: " + strings.Join(d.ownedLabels, ", "))
+	}
+	if !d.enabled {
 		result.AddInfo("mode: disabled (add [tools.docker] enabled=true to config)")
 	}
 
@@ -76,6 +76,31 @@ func TestDocker_Configure_CustomSocket(t *testing.T) {
 	}
 }
 
+func TestDocker_Configure_Policy(t *testing.T) {
+	d := &Docker{}
+	d.Configure(GlobalConfig{ProjectDir: "/home/user/project"}, map[string]any{
+		"enabled":      true,
+		"owned_labels": []any{"devsandbox.shared=true"},
+		"policy": map[string]any{
+			"enabled": true,
+			"images":  []any{"postgres", "redis:7*"},
+		},
+	})
+
+	if !d.policy.Enabled {
+		t.Error("expected policy enabled")
+	}
+	if len(d.policy.Images) != 2 || d.policy.Images[1] != "redis:7*" {
+		t.Errorf("images = %v", d.policy.Images)
+	}
+	if len(d.ownedLabels) != 1 || d.ownedLabels[0] != "devsandbox.shared=true" {
+		t.Errorf("ownedLabels = %v", d.ownedLabels)
+	}
+	if d.projectDir != "/home/user/project" {
+		t.Errorf("projectDir = %q", d.projectDir)
+	}
+}
+
 func TestDocker_Environment_Disabled(t *testing.T) {
 	d := &Docker{enabled: false}
 	env := d.Environment("/home/user", "/sandbox/home")
@@ -39,20 +39,63 @@ const (
 	// Git commands will work but without any user configuration.
 	// .git remains writable (controlled by project bindings).
 	GitModeDisabled GitMode = "disabled"
+
+	// GitModePushViaProxy makes .git writable so commits land, and carries the
+	// same safe gitconfig as readonly mode. Nothing that authenticates - SSH
+	// keys, the agent socket, credential helpers, tokens - enters the sandbox:
+	// SSH remotes are rewritten to HTTPS in the sanitized .git/config, and the
+	// MITM proxy's credential injectors authenticate push and fetch on the way
+	// out. Requires proxy mode with MITM enabled.
+	GitModePushViaProxy GitMode = "push-via-proxy"
 )
 
 // ValidGitMode returns true if the given string is a valid git mode value.
 func ValidGitMode(mode string) bool {
 	switch strings.ToLower(mode) {
-	case "readonly", "readwrite", "disabled":
+	case "readonly", "readwrite", "disabled", "push-via-proxy":
 		return true
 	default:
 		return false
 	}
 }
 
+// ParseGitMode maps a configured mode string, including the accepted aliases,
+// to its GitMode. Anything unrecognized is readonly, the safe default.
+func ParseGitMode(mode string) GitMode {
+	switch strings.ToLower(mode) {
+	case "readwrite", "read-write", "rw":
+		return GitModeReadWrite
+	case "disabled", "none", "off":
+		return GitModeDisabled
+	case "push-via-proxy", "push":
+		return GitModePushViaProxy
+	default:
+		return GitModeReadOnly
+	}
+}
+
+// CheckGitModeProxy reports why mode cannot run with the given proxy settings,
+// or nil when it can. Only push-via-proxy has a requirement: without the proxy
+// a push is unauthenticated, and without MITM the proxy never sees the HTTPS
+// request it would add the credential to.
+func CheckGitModeProxy(mode GitMode, proxyEnabled, mitm bool) error {
+	if mode != GitModePushViaProxy {
+		return nil
+	}
+	if !proxyEnabled {
+		return errors.New("git mode push-via-proxy requires proxy mode (--proxy or [proxy] enabled = true): " +
+			"pushes are authenticated by the proxy's credential injectors")
+	}
+	if !mitm {
+		return errors.New("git mode push-via-proxy requires MITM (remove --no-mitm): " +
+			"credentials cannot be injected into a tunneled HTTPS connection")
+	}
+	return nil
+}
+
 // Git provides configurable git configuration.
-// Supports three modes: readonly (default), readwrite, and disabled.
+// Supports four modes: readonly (default), readwrite, push-via-proxy, and
+// disabled.
 type Git struct {
 	mode        GitMode
 	projectDir  string
@@ -69,6 +112,8 @@ func (g *Git) Description() string {
 		return "Git configuration (full access with credentials)"
 	case GitModeDisabled:
 		return "Git configuration (disabled)"
+	case GitModePushViaProxy:
+		return "Git configuration (commits allowed, push authenticated by proxy)"
 	default:
 		return "Git configuration (read-only, no commits)"
 	}
@@ -92,19 +137,13 @@ func (g *Git) ConfigType() reflect.Type { return reflect.TypeFor[gitConfig]() }
 
 // Configure implements ToolWithConfig.
 func (g *Git) Configure(globalCfg GlobalConfig, toolCfg map[string]any) {
-	g.mode = GitModeReadOnly // default
 	g.projectDir = globalCfg.ProjectDir
 	g.gitRepoRoot = globalCfg.GitRepoRoot
 
 	var cfg gitConfig
 	decodeConfig(g.Name(), toolCfg, &cfg)
 
-	switch strings.ToLower(cfg.Mode) {
-	case "readwrite", "read-write", "rw":
-		g.mode = GitModeReadWrite
-	case "disabled", "none", "off":
-		g.mode = GitModeDisabled
-	}
+	g.mode = ParseGitMode(cfg.Mode)
 }
 
 func (g *Git) Bindings(homeDir, sandboxHome string) []Binding {
@@ -115,6 +154,9 @@ func (g *Git) Bindings(homeDir, sandboxHome string) []Binding {
 	case GitModeReadWrite:
 		return g.readWriteBindings(homeDir, sandboxHome)
 
+	case GitModePushViaProxy:
+		return g.pushViaProxyBindings(homeDir, sandboxHome)
+
 	default: // GitModeReadOnly
 		return g.readOnlyBindings(homeDir, sandboxHome)
 	}
@@ -129,8 +171,10 @@ func (g *Git) gitDirSource() string {
 	return g.projectDir
 }
 
-// readOnlyBindings returns bindings for readonly mode (safe gitconfig + read-only .git).
-func (g *Git) readOnlyBindings(homeDir, sandboxHome string) []Binding {
+// safeGitconfigBindings returns the bindings for the generated ~/.gitconfig
+// and the global ignore/attributes copies, shared by every mode that carries
+// the safe config instead of the host's own.
+func (g *Git) safeGitconfigBindings(homeDir, sandboxHome string) []Binding {
 	safeGitconfig := filepath.Join(sandboxHome, ".gitconfig.safe")
 
 	bindings := []Binding{
@@ -164,6 +208,31 @@ func (g *Git) readOnlyBindings(homeDir, sandboxHome string) []Binding {
 		})
 	}
 
+	return bindings
+}
+
+// safeRepoConfigBinding overlays gitDir/config with the sanitized copy Setup
+// wrote, or reports false when the repo has no regular config file to cover.
+func safeRepoConfigBinding(gitDir, sandboxHome string) (Binding, bool) {
+	gitConfig := filepath.Join(gitDir, "config")
+	info, err := os.Stat(gitConfig)
+	if err != nil || !info.Mode().IsRegular() {
+		return Binding{}, false
+	}
+	return Binding{
+		Source:   filepath.Join(sandboxHome, ".git-config.safe"),
+		Dest:     gitConfig,
+		Type:     MountBind,
+		ReadOnly: true,
+		Optional: true, // Setup may have skipped if source unreadable
+		Category: CategoryConfig,
+	}, true
+}
+
+// readOnlyBindings returns bindings for readonly mode (safe gitconfig + read-only .git).
+func (g *Git) readOnlyBindings(homeDir, sandboxHome string) []Binding {
+	bindings := g.safeGitconfigBindings(homeDir, sandboxHome)
+
 	// Mount .git as read-only to prevent commits. In worktree mode the
 	// worktree's .git is a regular file pointing at the main repo's
 	// .git/worktrees/<name>; we mount the main repo's .git so the
@@ -194,16 +263,8 @@ func (g *Git) readOnlyBindings(homeDir, sandboxHome string) []Binding {
 			// is preserved verbatim so git itself can still read the repo —
 			// otherwise even `git log` and pre-commit hooks fail with "unable to
 			// access '.git/config': Permission denied".
-			gitConfig := filepath.Join(gitDir, "config")
-			if info, err := os.Stat(gitConfig); err == nil && info.Mode().IsRegular() {
-				bindings = append(bindings, Binding{
-					Source:   filepath.Join(sandboxHome, ".git-config.safe"),
-					Dest:     gitConfig,
-					Type:     MountBind,
-					ReadOnly: true,
-					Optional: true, // Setup may have skipped if source unreadable
-					Category: CategoryConfig,
-				})
+			if b, ok := safeRepoConfigBinding(gitDir, sandboxHome); ok {
+				bindings = append(bindings, b)
 			}
 		}
 	}
@@ -211,6 +272,45 @@ func (g *Git) readOnlyBindings(homeDir, sandboxHome string) []Binding {
 	return bindings
 }
 
+// pushViaProxyBindings returns bindings for push-via-proxy mode: the safe
+// gitconfig of readonly mode, a writable .git, and the sanitized .git/config
+// whose SSH remotes Setup rewrote to HTTPS so pushes go through the proxy.
+//
+// The config overlay stays read-only. It is a single-file bind, and git
+// updates its config by renaming a lock file over it, which a mount point
+// refuses either way - so `git push -u` pushes but cannot record the upstream.
+func (g *Git) pushViaProxyBindings(homeDir, sandboxHome string) []Binding {
+	bindings := g.safeGitconfigBindings(homeDir, sandboxHome)
+
+	gitDirHost := g.gitDirSource()
+	if gitDirHost == "" {
+		return bindings
+	}
+	gitDir := filepath.Join(gitDirHost, ".git")
+	if info, err := os.Stat(gitDir); err != nil || !info.IsDir() {
+		return bindings
+	}
+
+	// Outside worktree mode .git is part of the read-write project mount
+	// already. In worktree mode the main repo's .git has to be mounted
+	// writable at its host path, as readwrite mode does, so the worktree's
+	// gitdir: pointer resolves and commits can land.
+	if g.gitRepoRoot != "" && g.gitRepoRoot != g.projectDir {
+		bindings = append(bindings, Binding{
+			Source:   gitDir,
+			Dest:     gitDir,
+			Type:     MountBind,
+			Category: CategoryConfig,
+		})
+	}
+
+	if b, ok := safeRepoConfigBinding(gitDir, sandboxHome); ok {
+		bindings = append(bindings, b)
+	}
+
+	return bindings
+}
+
 // readWriteBindings returns bindings for readwrite mode (full git access).
 func (g *Git) readWriteBindings(homeDir, _ string) []Binding {
 	bindings := []Binding{
@@ -260,12 +360,18 @@ func (g *Git) Environment(homeDir, sandboxHome string) []EnvVar {
 		return nil
 	}
 
-	// Pass through SSH auth socket for ssh-agent
-	if g.mode == GitModeReadWrite {
+	switch g.mode {
+	case GitModeReadWrite:
+		// Pass through SSH auth socket for ssh-agent
 		return []EnvVar{
 			{Name: "SSH_AUTH_SOCK", FromHost: true},
 			{Name: "GPG_TTY", FromHost: true},
 		}
+	case GitModePushViaProxy:
+		// There is no credential in the sandbox to prompt for. A push the
+		// proxy did not authenticate must fail with the server's 401, not
+		// hang on a username prompt nobody can answer.
+		return []EnvVar{{Name: "GIT_TERMINAL_PROMPT", Value: "0"}}
 	}
 
 	return nil
@@ -276,10 +382,10 @@ func (g *Git) ShellInit(shell string) string {
 }
 
 // Setup implements ToolWithSetup to generate the safe gitconfig and the
-// sanitized per-repo .git/config used by readonly mode.
+// sanitized per-repo .git/config used by readonly and push-via-proxy modes.
 func (g *Git) Setup(homeDir, sandboxHome string) error {
-	// Only generate safe configs for readonly mode
-	if g.mode != GitModeReadOnly {
+	// Only the modes that carry the safe configs generate them
+	if g.mode != GitModeReadOnly && g.mode != GitModePushViaProxy {
 		return nil
 	}
 
@@ -469,7 +575,7 @@ func (g *Git) setupRepoGitconfig(sandboxHome string) error {
 	// comparison short-circuited) mounted into the sandbox at .git/config.
 	// generateSafeRepoConfig writes through a temp file and a rename, which
 	// replaces such a link rather than following it.
-	return generateSafeRepoConfig(repoConfigPath, safeRepoConfigPath)
+	return generateSafeRepoConfig(repoConfigPath, safeRepoConfigPath, g.mode == GitModePushViaProxy)
 }
 
 // generateSafeRepoConfig writes a sanitized copy of a repo's .git/config to dst.
@@ -479,7 +585,11 @@ func (g *Git) setupRepoGitconfig(sandboxHome string) error {
 // [branch], [remote] (minus credentials), and any custom sections — so that
 // git operations like `git log`, `git status`, and pre-commit hooks continue
 // to function inside the sandbox.
-func generateSafeRepoConfig(src, dst string) error {
+//
+// With rewriteSSH set, remote URLs that use SSH are rewritten to their HTTPS
+// form and [url] sections are dropped: an insteadOf rule there could turn the
+// rewritten URL back into an SSH one, which has no key to authenticate with.
+func generateSafeRepoConfig(src, dst string, rewriteSSH bool) error {
 	file, err := os.Open(src)
 	if err != nil {
 		return err
@@ -490,7 +600,7 @@ func generateSafeRepoConfig(src, dst string) error {
 	scanner := bufio.NewScanner(file)
 
 	inRemote := false
-	inCredential := false
+	inDropped := false
 
 	for scanner.Scan() {
 		raw := scanner.Text()
@@ -499,8 +609,9 @@ func generateSafeRepoConfig(src, dst string) error {
 		if strings.HasPrefix(trimmed, "[") {
 			lower := strings.ToLower(trimmed)
 			inRemote = strings.HasPrefix(lower, "[remote")
-			inCredential = strings.HasPrefix(lower, "[credential")
-			if inCredential {
+			inDropped = strings.HasPrefix(lower, "[credential") ||
+				(rewriteSSH && (strings.HasPrefix(lower, "[url ") || strings.HasPrefix(lower, "[url\"")))
+			if inDropped {
 				continue // drop the section header itself
 			}
 			out.WriteString(raw)
@@ -508,7 +619,7 @@ func generateSafeRepoConfig(src, dst string) error {
 			continue
 		}
 
-		if inCredential {
+		if inDropped {
 			continue // drop section body
 		}
 
@@ -520,7 +631,11 @@ func generateSafeRepoConfig(src, dst string) error {
 					out.WriteString(indent)
 					out.WriteString(key)
 					out.WriteString(" = ")
-					out.WriteString(stripURLCredentials(value))
+					value = stripURLCredentials(value)
+					if rewriteSSH {
+						value = sshToHTTPS(value)
+					}
+					out.WriteString(value)
 					out.WriteByte('\n')
 					continue
 				}
@@ -564,6 +679,39 @@ func stripURLCredentials(rawURL string) string {
 	return rawURL
 }
 
+// sshToHTTPS rewrites an SSH remote URL - either ssh://[user@]host[:port]/path
+// or the scp-like user@host:path form - to https://host/path. The SSH port is
+// dropped because it says nothing about where the host serves HTTPS. Anything
+// else, including URLs that already use HTTP(S) and local paths, is returned
+// unchanged.
+func sshToHTTPS(rawURL string) string {
+	lower := strings.ToLower(rawURL)
+	if strings.HasPrefix(lower, "ssh://") || strings.HasPrefix(lower, "git+ssh://") || strings.HasPrefix(lower, "ssh+git://") {
+		u, err := url.Parse(rawURL)
+		if err != nil || u.Hostname() == "" {
+			return rawURL
+		}
+		return (&url.URL{Scheme: "https", Host: u.Hostname(), Path: u.Path}).String()
+	}
+	if strings.Contains(rawURL, "://") {
+		return rawURL
+	}
+
+	// scp-like syntax: git only reads it as SSH when a colon comes before
+	// any slash, which is what keeps ./dir:with:colons a local path.
+	hostPart, path, ok := strings.Cut(rawURL, ":")
+	if !ok || strings.Contains(hostPart, "/") || path == "" {
+		return rawURL
+	}
+	if _, host, hasUser := strings.Cut(hostPart, "@"); hasUser {
+		hostPart = host
+	}
+	if hostPart == "" {
+		return rawURL
+	}
+	return "https://" + hostPart + "/" + strings.TrimPrefix(path, "/")
+}
+
 // gitConfigEntry is one record of `git config --list --show-scope --show-origin -z`.
 //
 // origin is retained because a global-scope value is not automatically
@@ -1192,6 +1340,8 @@ func (g *Git) Check(homeDir string) CheckResult {
 		result.AddIssue("mode: readwrite (full access)")
 	case GitModeDisabled:
 		result.AddIssue("mode: disabled")
+	case GitModePushViaProxy:
+		result.AddIssue("mode: push-via-proxy (commits allowed, push authenticated by proxy credentials)")
 	default:
 		result.AddIssue("mode: readonly (safe, default)")
 	}
@@ -1207,8 +1357,8 @@ func (g *Git) Check(homeDir string) CheckResult {
 	// The global ignore and attributes files readonly mode carries into the
 	// sandbox. Their location follows the resolved config, so a core.excludesFile
 	// set from an included file is reported at the path it actually names.
-	// Only readonly mode carries them, and resolving costs a git subprocess, so
-	// the other two modes neither report nor pay for it.
+	// Only readonly and push-via-proxy carry them, and resolving costs a git
+	// subprocess, so readwrite and disabled neither report nor pay for it.
 	//
 	// Tested the same way the mode line above is - by what the mode is *not*.
 	// `tools check` and `tools info` call Check on the registry singleton
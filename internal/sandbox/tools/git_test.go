@@ -51,6 +51,11 @@ func TestGit_Configure(t *testing.T) {
 		{"none", map[string]any{"mode": "none"}, GitModeDisabled},
 		{"off", map[string]any{"mode": "off"}, GitModeDisabled},
 		{"disabled uppercase", map[string]any{"mode": "DISABLED"}, GitModeDisabled},
+
+		// Push-via-proxy variants
+		{"push-via-proxy", map[string]any{"mode": "push-via-proxy"}, GitModePushViaProxy},
+		{"push", map[string]any{"mode": "push"}, GitModePushViaProxy},
+		{"push-via-proxy uppercase", map[string]any{"mode": "PUSH-VIA-PROXY"}, GitModePushViaProxy},
 	}
 
 	for _, tt := range tests {
@@ -283,6 +288,178 @@ func TestGit_Environment_ReadWrite(t *testing.T) {
 	}
 }
 
+func TestGit_Environment_PushViaProxy(t *testing.T) {
+	g := &Git{}
+	g.Configure(GlobalConfig{}, map[string]any{"mode": "push-via-proxy"})
+
+	env := g.Environment("/home/user", "/sandbox/home")
+
+	// Nothing that authenticates is passed through - only the prompt guard.
+	if len(env) != 1 || env[0].Name != "GIT_TERMINAL_PROMPT" || env[0].Value != "0" || env[0].FromHost {
+		t.Fatalf("expected only GIT_TERMINAL_PROMPT=0, got %+v", env)
+	}
+}
+
+func TestGit_Bindings_PushViaProxy(t *testing.T) {
+	projectDir := t.TempDir()
+	gitDir := filepath.Join(projectDir, ".git")
+	if err := os.MkdirAll(gitDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(gitDir, "config"), []byte("[core]\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	g := &Git{}
+	g.Configure(GlobalConfig{ProjectDir: projectDir}, map[string]any{"mode": "push-via-proxy"})
+	bindings := g.Bindings("/home/user", "/sandbox/home")
+
+	var sawSafeGitconfig, sawRepoConfig bool
+	for _, b := range bindings {
+		switch {
+		case strings.HasPrefix(b.Source, "/home/user/.ssh"), strings.HasPrefix(b.Source, "/home/user/.gnupg"),
+			b.Source == "/home/user/.git-credentials", b.Source == "/home/user/.gitconfig":
+			t.Errorf("push-via-proxy must not expose host credentials, got binding %+v", b)
+		case b.Source == gitDir:
+			t.Errorf(".git must stay writable through the project mount, got binding %+v", b)
+		case b.Source == "/sandbox/home/.gitconfig.safe":
+			sawSafeGitconfig = true
+		case b.Dest == filepath.Join(gitDir, "config"):
+			sawRepoConfig = true
+			if b.Source != "/sandbox/home/.git-config.safe" || !b.ReadOnly {
+				t.Errorf("repo config overlay = %+v, want read-only bind of the safe copy", b)
+			}
+		}
+	}
+	if !sawSafeGitconfig {
+		t.Error("expected the safe gitconfig binding")
+	}
+	if !sawRepoConfig {
+		t.Error("expected the sanitized .git/config overlay")
+	}
+}
+
+func TestGit_Bindings_PushViaProxy_WorktreeMountsMainGitDirWritable(t *testing.T) {
+	repoRoot := t.TempDir()
+	gitDir := filepath.Join(repoRoot, ".git")
+	if err := os.MkdirAll(gitDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	worktreeDir := t.TempDir()
+
+	g := &Git{}
+	g.Configure(GlobalConfig{ProjectDir: worktreeDir, GitRepoRoot: repoRoot}, map[string]any{"mode": "push-via-proxy"})
+
+	for _, b := range g.Bindings("/home/user", "/sandbox/home") {
+		if b.Source == gitDir {
+			if b.ReadOnly || b.Dest != gitDir {
+				t.Errorf("main .git binding = %+v, want writable at its host path", b)
+			}
+			return
+		}
+	}
+	t.Fatal("expected the main repo's .git to be mounted")
+}
+
+func TestCheckGitModeProxy(t *testing.T) {
+	tests := []struct {
+		name    string
+		mode    GitMode
+		proxy   bool
+		mitm    bool
+		wantErr string
+	}{
+		{"readonly without proxy", GitModeReadOnly, false, false, ""},
+		{"readwrite without proxy", GitModeReadWrite, false, false, ""},
+		{"push with proxy and mitm", GitModePushViaProxy, true, true, ""},
+		{"push without proxy", GitModePushViaProxy, false, true, "requires proxy mode"},
+		{"push without mitm", GitModePushViaProxy, true, false, "requires MITM"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckGitModeProxy(tt.mode, tt.proxy, tt.mitm)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSSHToHTTPS(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"git@github.com:user/repo.git", "https://github.com/user/repo.git"},
+		{"github.com:user/repo.git", "https://github.com/user/repo.git"},
+		{"ssh://git@github.com/user/repo.git", "https://github.com/user/repo.git"},
+		{"ssh://git@gitlab.example.com:2222/group/repo.git", "https://gitlab.example.com/group/repo.git"},
+		{"git+ssh://git@github.com/user/repo.git", "https://github.com/user/repo.git"},
+		{"https://github.com/user/repo.git", "https://github.com/user/repo.git"},
+		{"git://github.com/user/repo.git", "git://github.com/user/repo.git"},
+		{"/srv/git/repo.git", "/srv/git/repo.git"},
+		{"./dir/with:colon", "./dir/with:colon"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			if got := sshToHTTPS(tt.in); got != tt.want {
+				t.Errorf("sshToHTTPS(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerateSafeRepoConfig_RewriteSSH(t *testing.T) {
+	tmp := t.TempDir()
+	src := filepath.Join(tmp, "config")
+	dst := filepath.Join(tmp, "config.safe")
+	input := `[remote "origin"]
+	url = git@github.com:user/repo.git
+	pushurl = ssh://git@github.com/user/repo.git
+	fetch = +refs/heads/*:refs/remotes/origin/*
+[url "git@github.com:"]
+	insteadOf = https://github.com/
+[credential]
+	helper = store
+[branch "main"]
+	remote = origin
+`
+	if err := os.WriteFile(src, []byte(input), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := generateSafeRepoConfig(src, dst, true); err != nil {
+		t.Fatalf("generateSafeRepoConfig: %v", err)
+	}
+	data, err := os.ReadFile(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(data)
+
+	for _, want := range []string{
+		"url = https://github.com/user/repo.git",
+		"pushurl = https://github.com/user/repo.git",
+		"fetch = +refs/heads/*:refs/remotes/origin/*",
+		`[branch "main"]`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output missing %q\nfull output:\n%s", want, got)
+		}
+	}
+	for _, forbidden := range []string{"git@github.com", "insteadOf", "[url", "[credential", "helper"} {
+		if strings.Contains(got, forbidden) {
+			t.Errorf("output contains %q\nfull output:\n%s", forbidden, got)
+		}
+	}
+}
+
 func TestGit_Setup_DisabledMode(t *testing.T) {
 	tmpDir := t.TempDir()
 	homeDir := filepath.Join(tmpDir, "home")
@@ -862,7 +1039,7 @@ func TestGenerateSafeRepoConfig(t *testing.T) {
 				t.Fatal(err)
 			}
 
-			if err := generateSafeRepoConfig(src, dst); err != nil {
+			if err := generateSafeRepoConfig(src, dst, false); err != nil {
 				t.Fatalf("generateSafeRepoConfig: %v", err)
 			}
 
@@ -9,10 +9,12 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 
 	"devsandbox/internal/cmdpattern"
 	"devsandbox/internal/fsutil"
+	"devsandbox/internal/notice"
 )
 
 // sharedTmpRelPath is the root of the per-session directory shared read-write
@@ -120,6 +122,33 @@ func launchBoundsFor(homeDir, sandboxHome, projectDir string) cmdpattern.LaunchB
 	}
 }
 
+// commandPatterns turns a multiplexer proxy's configured commands into launch
+// patterns. Each command is a program name followed by the arguments every
+// launch of it must start with. The program is pinned to the binary the host
+// resolves now, and one resolving into a directory the sandbox can write is
+// dropped - the sandbox would choose what the host runs.
+func commandPatterns(tool string, commands []string, bounds cmdpattern.LaunchBounds) []cmdpattern.CommandPattern {
+	var patterns []cmdpattern.CommandPattern
+	for _, c := range commands {
+		fields := strings.Fields(c)
+		resolved, err := cmdpattern.ResolveProgram(fields[0])
+		if err != nil {
+			notice.Warn("%s: ignoring command %q: %v", tool, c, err)
+			continue
+		}
+		if cmdpattern.PathUnder(resolved, bounds.UntrustedRoots()) {
+			notice.Warn("%s: ignoring command %q: the host resolves it to %s, which the sandbox can write", tool, c, resolved)
+			continue
+		}
+		patterns = append(patterns, cmdpattern.CommandPattern{
+			Program:     fields[0],
+			ResolvedBin: resolved,
+			ArgsMatcher: cmdpattern.MatchPrefix(fields[1:]...),
+		})
+	}
+	return patterns
+}
+
 // SharedTmpRoot returns the directory holding one entry per sandbox home. It is
 // bind-mounted into the sandbox at this same path, so anything the host must be
 // able to trust has to live somewhere else.
@@ -1,18 +1,106 @@
 package tools
 
 import (
+	"context"
+	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"devsandbox/internal/cmdpattern"
+	"devsandbox/internal/notice"
+	"devsandbox/internal/tmuxproxy"
 )
 
 func init() {
 	Register(&Tmux{})
 }
 
-// Tmux provides tmux terminal multiplexer configuration.
-// Can display sandbox indicator in status bar.
-type Tmux struct{ Mounting }
+const (
+	tmuxProxySocketName = "tmux.sock"
+	tmuxModeAuto        = "auto"
+	tmuxModeDisabled    = "disabled"
+	tmuxModeEnforce     = "enforce"
+)
+
+// tmuxCommandCapabilities are granted whenever commands are configured: open a
+// pane running one of them, then drive, list and close the panes opened.
+var tmuxCommandCapabilities = []tmuxproxy.Capability{
+	tmuxproxy.CapSplitWindow,
+	tmuxproxy.CapNewWindow,
+	tmuxproxy.CapSendKeysOwned,
+	tmuxproxy.CapKillPaneOwned,
+	tmuxproxy.CapListOwned,
+}
+
+// Tmux provides tmux terminal multiplexer configuration and can display a
+// sandbox indicator in the status bar.
+//
+// Inside a tmux session it also runs a filtering proxy for the host's tmux
+// server, which the sandbox reaches through `devsandbox tmux`. The host socket
+// is NOT bind-mounted into the sandbox; only the proxy socket is.
+type Tmux struct {
+	mode              string
+	commands          []string
+	extraCapabilities []tmuxproxy.Capability
+	projectDir        string
+
+	logger ErrorLogger
+	proxy  *tmuxproxy.Proxy
+}
+
+// tmuxConfig is the [tools.tmux] section.
+type tmuxConfig struct {
+	MountModeConfig
+	Mode              string   `toml:"mode"`
+	Commands          []string `toml:"commands"`
+	ExtraCapabilities []string `toml:"extra_capabilities"`
+}
+
+// ConfigType implements ToolWithConfigType.
+func (t *Tmux) ConfigType() reflect.Type { return reflect.TypeFor[tmuxConfig]() }
+
+// Configure implements ToolWithConfig.
+func (t *Tmux) Configure(globalCfg GlobalConfig, toolCfg map[string]any) {
+	t.mode = tmuxModeAuto
+	t.commands = nil
+	t.extraCapabilities = nil
+	t.projectDir = globalCfg.ProjectDir
+
+	var cfg tmuxConfig
+	decodeConfig(t.Name(), toolCfg, &cfg)
+
+	switch cfg.Mode {
+	case "":
+	case tmuxModeAuto, tmuxModeDisabled, tmuxModeEnforce:
+		t.mode = cfg.Mode
+	default:
+		notice.Warn("tmux: ignoring unknown mode %q; using %q", cfg.Mode, tmuxModeAuto)
+	}
+	for _, c := range cfg.Commands {
+		if strings.TrimSpace(c) != "" {
+			t.commands = append(t.commands, c)
+		}
+	}
+	for _, name := range cfg.ExtraCapabilities {
+		capability := tmuxproxy.Capability(name)
+		switch {
+		case !tmuxproxy.IsKnown(capability):
+			notice.Warn("tmux: ignoring unknown extra_capabilities entry %q", name)
+		case tmuxproxy.IsLaunch(capability):
+			notice.Warn("tmux: ignoring extra_capabilities entry %q (launch capabilities come from commands)", name)
+		default:
+			t.extraCapabilities = append(t.extraCapabilities, capability)
+		}
+	}
+}
+
+// SetLogger implements ToolWithLogger.
+func (t *Tmux) SetLogger(l ErrorLogger) { t.logger = l }
 
 func (t *Tmux) Name() string {
 	return "tmux"
@@ -23,10 +111,13 @@ func (t *Tmux) Description() string {
 }
 
 func (t *Tmux) Available(homeDir string) bool {
-	// Check if tmux is installed and user has a config
+	// Check if tmux is installed and user has a config or a session to proxy
 	if _, err := exec.LookPath("tmux"); err != nil {
 		return false
 	}
+	if tmuxHostSocket() != "" {
+		return true
+	}
 
 	// Check for tmux config
 	paths := []string{
@@ -68,11 +159,33 @@ func (t *Tmux) Bindings(homeDir, sandboxHome string) []Binding {
 		)
 	}
 
+	// The proxy's client is `devsandbox tmux`, so the sandbox needs this
+	// binary. One under the user's home is already reachable; see
+	// binaryNeedsBind.
+	if t.proxy != nil {
+		if bin, err := os.Executable(); err == nil && binaryNeedsBind(bin, homeDir) {
+			bindings = append(bindings, Binding{
+				Source: bin, Dest: bin,
+				Category: CategoryRuntime,
+				ReadOnly: true, Optional: true,
+			})
+		}
+	}
+
 	return bindings
 }
 
-func (t *Tmux) Environment(homeDir, sandboxHome string) []EnvVar {
-	return nil
+// Environment points `devsandbox tmux` at the proxy socket, and only when the
+// proxy is actually running. TMUX itself is not exported: it names the host
+// socket, and a tmux started inside the sandbox must not think it is nested.
+func (t *Tmux) Environment(homeDir, _ string) []EnvVar {
+	if t.proxy == nil {
+		return nil
+	}
+	return []EnvVar{
+		{Name: "DEVSANDBOX_TMUX_SOCKET", Value: filepath.Join(runDir(homeDir), tmuxProxySocketName)},
+		{Name: "TMUX_PANE", FromHost: true},
+	}
 }
 
 func (t *Tmux) ShellInit(shell string) string {
@@ -92,14 +205,125 @@ func (t *Tmux) Check(homeDir string) CheckResult {
 		filepath.Join(homeDir, ".tmux", "plugins"),
 	)
 
-	if len(result.ConfigPaths) == 0 {
+	sock := tmuxHostSocket()
+	if len(result.ConfigPaths) == 0 && sock == "" {
 		result.Available = false
 		result.AddIssue("no tmux config found")
 	}
+	if sock != "" {
+		result.AddInfo("server socket: " + sock)
+		result.AddInfo("proxy mode: " + t.mode)
+	}
 
 	return result
 }
 
+// tmuxHostSocket returns the host server socket path from TMUX, which tmux
+// sets to "<socket>,<pid>,<session>" inside a session.
+func tmuxHostSocket() string {
+	sock, _, _ := strings.Cut(os.Getenv("TMUX"), ",")
+	return sock
+}
+
+// capabilities returns the proxy capabilities and launch patterns the
+// configuration grants.
+func (t *Tmux) capabilities(bounds cmdpattern.LaunchBounds) ([]tmuxproxy.Capability, []cmdpattern.CommandPattern) {
+	patterns := commandPatterns(t.Name(), t.commands, bounds)
+	caps := append([]tmuxproxy.Capability(nil), t.extraCapabilities...)
+	if len(patterns) > 0 {
+		caps = append(caps, tmuxCommandCapabilities...)
+	}
+	return caps, patterns
+}
+
+// Start implements ActiveTool.
+func (t *Tmux) Start(ctx context.Context, homeDir, sandboxHome string) error {
+	if t.mode == tmuxModeDisabled {
+		return nil
+	}
+	hostSock := tmuxHostSocket()
+	if hostSock == "" {
+		return nil
+	}
+
+	caps, patterns := t.capabilities(launchBoundsFor(homeDir, sandboxHome, t.projectDir))
+	if len(caps) == 0 && t.mode == tmuxModeAuto {
+		return nil
+	}
+	// In enforce mode an empty allowlist still starts the proxy, and every
+	// request is denied.
+
+	bin, err := exec.LookPath("tmux")
+	if err != nil {
+		return fmt.Errorf("tmux: %w", err)
+	}
+	if err := checkTmuxVersion(bin); err != nil {
+		return fmt.Errorf("tmux: %w", err)
+	}
+	if _, err := os.Stat(hostSock); err != nil {
+		return fmt.Errorf("tmux: host socket %s not reachable: %w", hostSock, err)
+	}
+
+	owned := tmuxproxy.NewOwnedSet()
+	filter := tmuxproxy.NewFilter(tmuxproxy.FilterConfig{
+		Capabilities:   caps,
+		LaunchPatterns: patterns,
+		Owned:          owned,
+		// Host-side value: it anchors where a launch opens its pane. The same
+		// value is exported into the sandbox by Environment.
+		HostPaneID: os.Getenv("TMUX_PANE"),
+	})
+
+	if _, err := ensureRunDir(sandboxHome); err != nil {
+		return fmt.Errorf("tmux: %w", err)
+	}
+	listenPath := filepath.Join(runDir(sandboxHome), tmuxProxySocketName)
+	if err := checkSocketPath(listenPath); err != nil {
+		return fmt.Errorf("tmux: %w", err)
+	}
+
+	t.proxy = tmuxproxy.New(bin, hostSock, listenPath, filter, owned)
+	if t.logger != nil {
+		t.proxy.SetLogger(t.logger)
+	}
+	if err := t.proxy.Start(ctx); err != nil {
+		t.proxy = nil
+		return fmt.Errorf("tmux: start proxy: %w", err)
+	}
+	notice.Info("tmux proxy active. Capabilities: %v", caps)
+	return nil
+}
+
+// Stop implements ActiveTool.
+func (t *Tmux) Stop() error {
+	if t.proxy == nil {
+		return nil
+	}
+	err := t.proxy.Stop()
+	t.proxy = nil
+	return err
+}
+
+var tmuxVersionPattern = regexp.MustCompile(`([0-9]+)\.[0-9]+`)
+
+// checkTmuxVersion refuses tmux releases before 3.0. Those join a launch's
+// argv with spaces and hand it to the shell, so the argv the filter approved
+// is not the one that runs.
+func checkTmuxVersion(bin string) error {
+	out, err := exec.Command(bin, "-V").Output()
+	if err != nil {
+		return fmt.Errorf("read version: %w", err)
+	}
+	m := tmuxVersionPattern.FindStringSubmatch(string(out))
+	if m == nil {
+		return fmt.Errorf("cannot parse version %q; the proxy needs tmux 3.0 or later", strings.TrimSpace(string(out)))
+	}
+	if major, _ := strconv.Atoi(m[1]); major < 3 {
+		return fmt.Errorf("%s is too old; the proxy needs tmux 3.0 or later", strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
 // Setup implements ToolWithSetup to generate the sandbox-aware tmux config.
 func (t *Tmux) Setup(homeDir, sandboxHome string) error {
 	// Find existing tmux config
@@ -133,3 +357,13 @@ if-shell '[ -n "$DEVSANDBOX" ]' {
 `
 	return SetupConfigWithSuffix(srcPath, destPath, indicator)
 }
+
+// Ensure interfaces are implemented.
+var (
+	_ Tool           = (*Tmux)(nil)
+	_ ToolWithConfig = (*Tmux)(nil)
+	_ ToolWithCheck  = (*Tmux)(nil)
+	_ ToolWithSetup  = (*Tmux)(nil)
+	_ ActiveTool     = (*Tmux)(nil)
+	_ ToolWithLogger = (*Tmux)(nil)
+)
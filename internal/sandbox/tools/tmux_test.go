@@ -0,0 +1,152 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"devsandbox/internal/cmdpattern"
+	"devsandbox/internal/tmuxproxy"
+)
+
+func TestTmuxHostSocket(t *testing.T) {
+	t.Setenv("TMUX", "/tmp/tmux-1000/default,4242,0")
+	if got := tmuxHostSocket(); got != "/tmp/tmux-1000/default" {
+		t.Errorf("tmuxHostSocket() = %q", got)
+	}
+	t.Setenv("TMUX", "")
+	if got := tmuxHostSocket(); got != "" {
+		t.Errorf("tmuxHostSocket() = %q, want empty outside tmux", got)
+	}
+}
+
+func TestTmux_Configure(t *testing.T) {
+	tm := &Tmux{}
+	tm.Configure(GlobalConfig{}, map[string]any{
+		"mode":               "enforce",
+		"commands":           []any{"revdiff", " "},
+		"extra_capabilities": []any{"capture_pane_owned", "split_window", "bogus"},
+	})
+	if tm.mode != tmuxModeEnforce {
+		t.Errorf("mode = %q", tm.mode)
+	}
+	if !slices.Equal(tm.commands, []string{"revdiff"}) {
+		t.Errorf("commands = %q", tm.commands)
+	}
+	if !slices.Equal(tm.extraCapabilities, []tmuxproxy.Capability{tmuxproxy.CapCapturePaneOwned}) {
+		t.Errorf("extra capabilities = %v; launch and unknown entries must be dropped", tm.extraCapabilities)
+	}
+
+	tm.Configure(GlobalConfig{}, map[string]any{"mode": "sometimes"})
+	if tm.mode != tmuxModeAuto {
+		t.Errorf("unknown mode should fall back to auto, got %q", tm.mode)
+	}
+}
+
+func TestTmux_Capabilities(t *testing.T) {
+	projectDir := t.TempDir()
+	planted := filepath.Join(projectDir, "bin", "watcher")
+	if err := os.MkdirAll(filepath.Dir(planted), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(planted, []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	sleepBin, err := cmdpattern.ResolveProgram("sleep")
+	if err != nil {
+		t.Skip("sleep not found")
+	}
+
+	tm := &Tmux{}
+	tm.Configure(GlobalConfig{ProjectDir: projectDir}, map[string]any{
+		"commands": []any{"sleep 60", planted, "no-such-program-xyz"},
+	})
+	caps, patterns := tm.capabilities(cmdpattern.LaunchBounds{ProjectDir: projectDir})
+
+	if len(patterns) != 1 {
+		t.Fatalf("got %d patterns, want only the sleep one", len(patterns))
+	}
+	if patterns[0].ResolvedBin != sleepBin {
+		t.Errorf("ResolvedBin = %q, want %q", patterns[0].ResolvedBin, sleepBin)
+	}
+	if !patterns[0].MatchesArgv([]string{sleepBin, "60", "extra"}) || patterns[0].MatchesArgv([]string{sleepBin, "1"}) {
+		t.Error("pattern should require the configured argument prefix")
+	}
+	for _, c := range tmuxCommandCapabilities {
+		if !slices.Contains(caps, c) {
+			t.Errorf("capability %s not granted by commands", c)
+		}
+	}
+
+	tm.Configure(GlobalConfig{}, nil)
+	if caps, _ := tm.capabilities(cmdpattern.LaunchBounds{}); len(caps) != 0 {
+		t.Errorf("no commands should grant nothing, got %v", caps)
+	}
+}
+
+func TestTmux_Start_AutoModeInactiveWithoutCommands(t *testing.T) {
+	t.Setenv("TMUX", "/tmp/tmux-test/default,1,0")
+	tm := &Tmux{}
+	tm.Configure(GlobalConfig{}, nil)
+
+	dir := t.TempDir()
+	if err := tm.Start(context.Background(), dir, dir); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer func() { _ = tm.Stop() }()
+
+	if tm.proxy != nil {
+		t.Error("auto mode with no commands should not start a proxy")
+	}
+	if env := tm.Environment(dir, dir); len(env) != 0 {
+		t.Errorf("inactive proxy must export nothing, got %+v", env)
+	}
+}
+
+func TestTmux_Start_RunsProxy(t *testing.T) {
+	bin, err := exec.LookPath("tmux")
+	if err != nil {
+		t.Skip("tmux not installed")
+	}
+	dir := shortSocketDir(t)
+	hostSock := filepath.Join(dir, "host.sock")
+	if out, err := exec.Command(bin, "-S", hostSock, "-f", "/dev/null", "new-session", "-d", "sleep 600").CombinedOutput(); err != nil {
+		t.Skipf("cannot start tmux server: %v: %s", err, out)
+	}
+	t.Cleanup(func() { _ = exec.Command(bin, "-S", hostSock, "kill-server").Run() })
+
+	t.Setenv("TMUX", hostSock+",1,0")
+	t.Setenv("TMUX_PANE", "%0")
+	tm := &Tmux{}
+	tm.Configure(GlobalConfig{}, map[string]any{"commands": []any{"sleep"}})
+
+	sandboxHome := shortSocketDir(t)
+	if err := tm.Start(context.Background(), sandboxHome, sandboxHome); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer func() { _ = tm.Stop() }()
+	if tm.proxy == nil {
+		t.Fatal("expected proxy to start when commands are configured")
+	}
+
+	var sock string
+	for _, e := range tm.Environment(sandboxHome, sandboxHome) {
+		if e.Name == "DEVSANDBOX_TMUX_SOCKET" {
+			sock = e.Value
+		}
+	}
+	if want := filepath.Join(runDir(sandboxHome), tmuxProxySocketName); sock != want {
+		t.Fatalf("DEVSANDBOX_TMUX_SOCKET = %q, want %q", sock, want)
+	}
+
+	resp, err := tmuxproxy.Call(sock, []string{"split-window", "-d", "sleep", "600"})
+	if err != nil || !resp.OK {
+		t.Fatalf("split-window through the proxy: %+v, %v", resp, err)
+	}
+	if resp, _ := tmuxproxy.Call(sock, []string{"kill-pane", "-t", "%0"}); resp.OK {
+		t.Error("kill-pane of the host pane was allowed")
+	}
+}
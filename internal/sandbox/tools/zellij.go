@@ -1,27 +1,68 @@
 package tools
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"reflect"
+	"regexp"
+	"strconv"
 	"strings"
+
+	"devsandbox/internal/cmdpattern"
+	"devsandbox/internal/notice"
+	"devsandbox/internal/zellijproxy"
 )
 
 func init() {
 	Register(&Zellij{})
 }
 
-// Zellij forwards the Zellij session environment into the sandbox.
-// Mounts the Zellij socket directory and binary so the CLI works inside the sandbox.
+const (
+	zellijProxySocketName = "zellij.sock"
+	zellijShimName        = "zellij"
+	zellijModeAuto        = "auto"
+	zellijModeDisabled    = "disabled"
+	zellijModeEnforce     = "enforce"
+)
+
+// zellijCommandCapabilities are granted whenever commands are configured: open
+// a floating pane running one of them, then write to and close the panes
+// opened.
+var zellijCommandCapabilities = []zellijproxy.Capability{
+	zellijproxy.CapRunFloating,
+	zellijproxy.CapWriteCharsOwned,
+	zellijproxy.CapClosePaneOwned,
+}
+
+// Zellij integrates with the host's Zellij session in one of two ways.
 //
-// Disabled by default: zellij has no socket-level filtering, so exposing the
-// session socket gives sandboxed code unrestricted control over the host
-// multiplexer (run arbitrary commands in any pane, read pane contents, etc.).
-// Set [tools.zellij] enabled = true to opt in.
+// With commands configured it runs a filtering proxy for the session, which
+// the sandbox reaches through `devsandbox zellij` - or through `zellij`
+// itself, which is a script relaying to it. The socket directory is NOT
+// bind-mounted into the sandbox; only the proxy socket is.
+//
+// With [tools.zellij] enabled = true it instead mounts the socket directory
+// and binary so the zellij CLI works inside the sandbox. That is off by
+// default: the socket has no filtering, so exposing it gives sandboxed code
+// unrestricted control over the host multiplexer (run arbitrary commands in
+// any pane, read pane contents, etc.).
 type Zellij struct {
-	enabled bool
+	enabled           bool
+	mode              string
+	commands          []string
+	extraCapabilities []zellijproxy.Capability
+	projectDir        string
+
+	logger ErrorLogger
+	proxy  *zellijproxy.Proxy
+	// bin is the host zellij binary and shim the script standing in for it
+	// inside the sandbox while the proxy runs; shim is empty when bin lives
+	// under home (see binaryNeedsBind).
+	bin  string
+	shim string
 }
 
 func (z *Zellij) Name() string              { return "zellij" }
@@ -31,17 +72,63 @@ func (z *Zellij) ShellInit(_ string) string { return "" }
 // zellijConfig is the [tools.zellij] section.
 type zellijConfig struct {
 	MountModeConfig
-	Enabled bool `toml:"enabled"`
+	Enabled           bool     `toml:"enabled"`
+	Mode              string   `toml:"mode"`
+	Commands          []string `toml:"commands"`
+	ExtraCapabilities []string `toml:"extra_capabilities"`
 }
 
 // ConfigType implements ToolWithConfigType.
 func (z *Zellij) ConfigType() reflect.Type { return reflect.TypeFor[zellijConfig]() }
 
 // Configure implements ToolWithConfig.
-func (z *Zellij) Configure(_ GlobalConfig, toolCfg map[string]any) {
+func (z *Zellij) Configure(globalCfg GlobalConfig, toolCfg map[string]any) {
+	z.mode = zellijModeAuto
+	z.commands = nil
+	z.extraCapabilities = nil
+	z.projectDir = globalCfg.ProjectDir
+
 	var cfg zellijConfig
 	decodeConfig(z.Name(), toolCfg, &cfg)
 	z.enabled = cfg.Enabled
+
+	switch cfg.Mode {
+	case "":
+	case zellijModeAuto, zellijModeDisabled, zellijModeEnforce:
+		z.mode = cfg.Mode
+	default:
+		notice.Warn("zellij: ignoring unknown mode %q; using %q", cfg.Mode, zellijModeAuto)
+	}
+	for _, c := range cfg.Commands {
+		if strings.TrimSpace(c) != "" {
+			z.commands = append(z.commands, c)
+		}
+	}
+	for _, name := range cfg.ExtraCapabilities {
+		capability := zellijproxy.Capability(name)
+		switch {
+		case !zellijproxy.IsKnown(capability):
+			notice.Warn("zellij: ignoring unknown extra_capabilities entry %q", name)
+		case zellijproxy.IsLaunch(capability):
+			notice.Warn("zellij: ignoring extra_capabilities entry %q (launch capabilities come from commands)", name)
+		default:
+			z.extraCapabilities = append(z.extraCapabilities, capability)
+		}
+	}
+}
+
+// SetLogger implements ToolWithLogger.
+func (z *Zellij) SetLogger(l ErrorLogger) { z.logger = l }
+
+// proxyConfigured reports whether the configuration asks for the proxy.
+func (z *Zellij) proxyConfigured() bool {
+	switch z.mode {
+	case zellijModeDisabled:
+		return false
+	case zellijModeEnforce:
+		return true
+	}
+	return len(z.commands) > 0 || len(z.extraCapabilities) > 0
 }
 
 // Available returns true when running inside a Zellij session.
@@ -73,7 +160,29 @@ func zellijSocketDirs() []string {
 	return dirs
 }
 
-func (z *Zellij) Bindings(_ string, _ string) []Binding {
+func (z *Zellij) Bindings(homeDir string, _ string) []Binding {
+	// The proxy's client is `devsandbox zellij`, so the sandbox needs this
+	// binary. One under the user's home is already reachable; see
+	// binaryNeedsBind. The shim takes the host zellij's place, so `zellij run`
+	// and `zellij action` resolve to it on the sandbox's PATH.
+	if z.proxy != nil {
+		var bindings []Binding
+		if bin, err := os.Executable(); err == nil && binaryNeedsBind(bin, homeDir) {
+			bindings = append(bindings, Binding{
+				Source: bin, Dest: bin,
+				Category: CategoryRuntime,
+				ReadOnly: true, Optional: true,
+			})
+		}
+		if z.shim != "" {
+			bindings = append(bindings, Binding{
+				Source: z.shim, Dest: z.bin,
+				Category: CategoryRuntime,
+				ReadOnly: true,
+			})
+		}
+		return bindings
+	}
 	if !z.enabled {
 		return nil
 	}
@@ -114,7 +223,19 @@ func (z *Zellij) Bindings(_ string, _ string) []Binding {
 	return bindings
 }
 
-func (z *Zellij) Environment(_, _ string) []EnvVar {
+// Environment points `devsandbox zellij` at the proxy socket while the proxy
+// runs. ZELLIJ and ZELLIJ_SESSION_NAME are exported as well, so tools that
+// look for them to decide whether to open a zellij pane do; the `zellij` they
+// then run is the shim, which cannot start a nested session.
+func (z *Zellij) Environment(homeDir, _ string) []EnvVar {
+	if z.proxy != nil {
+		return []EnvVar{
+			{Name: "DEVSANDBOX_ZELLIJ_SOCKET", Value: filepath.Join(runDir(homeDir), zellijProxySocketName)},
+			{Name: "ZELLIJ", FromHost: true},
+			{Name: "ZELLIJ_SESSION_NAME", FromHost: true},
+			{Name: "ZELLIJ_PANE_ID", FromHost: true},
+		}
+	}
 	if !z.enabled {
 		return nil
 	}
@@ -141,8 +262,18 @@ func (z *Zellij) Check(_ string) CheckResult {
 		result.AddInfo("enabled: true")
 	} else {
 		result.AddInfo("enabled: false")
+		if z.proxyConfigured() {
+			result.AddInfo("proxy mode: " + z.mode)
+			if name := os.Getenv("ZELLIJ_SESSION_NAME"); name != "" {
+				result.AddInfo("session: " + name)
+			} else {
+				result.Available = false
+				result.AddIssue("ZELLIJ_SESSION_NAME not set — the proxy cannot name the host session")
+			}
+			return result
+		}
 		result.Available = false
-		result.AddIssue("zellij forwarding is disabled by default (no socket-level filtering); set [tools.zellij] enabled = true to opt in")
+		result.AddIssue("zellij socket forwarding is disabled by default (no socket-level filtering); set [tools.zellij] commands to use the filtering proxy, or enabled = true to forward the socket")
 		return result
 	}
 
@@ -173,9 +304,141 @@ func (z *Zellij) Check(_ string) CheckResult {
 	return result
 }
 
+// capabilities returns the proxy capabilities and launch patterns the
+// configuration grants.
+func (z *Zellij) capabilities(bounds cmdpattern.LaunchBounds) ([]zellijproxy.Capability, []cmdpattern.CommandPattern) {
+	patterns := commandPatterns(z.Name(), z.commands, bounds)
+	caps := append([]zellijproxy.Capability(nil), z.extraCapabilities...)
+	if len(patterns) > 0 {
+		caps = append(caps, zellijCommandCapabilities...)
+	}
+	return caps, patterns
+}
+
+// Start implements ActiveTool. With enabled = true the sandbox already holds
+// the raw socket, so no proxy is started.
+func (z *Zellij) Start(ctx context.Context, homeDir, sandboxHome string) error {
+	if z.enabled || z.mode == zellijModeDisabled {
+		return nil
+	}
+	session := os.Getenv("ZELLIJ_SESSION_NAME")
+	if os.Getenv("ZELLIJ") == "" || session == "" {
+		return nil
+	}
+
+	caps, patterns := z.capabilities(launchBoundsFor(homeDir, sandboxHome, z.projectDir))
+	if len(caps) == 0 && z.mode == zellijModeAuto {
+		return nil
+	}
+	// In enforce mode an empty allowlist still starts the proxy, and every
+	// request is denied.
+
+	bin, err := exec.LookPath("zellij")
+	if err != nil {
+		return fmt.Errorf("zellij: %w", err)
+	}
+	if err := checkZellijVersion(bin); err != nil {
+		return fmt.Errorf("zellij: %w", err)
+	}
+
+	owned := zellijproxy.NewOwnedSet()
+	filter := zellijproxy.NewFilter(zellijproxy.FilterConfig{
+		Capabilities:   caps,
+		LaunchPatterns: patterns,
+		Owned:          owned,
+	})
+
+	if _, err := ensureRunDir(sandboxHome); err != nil {
+		return fmt.Errorf("zellij: %w", err)
+	}
+	listenPath := filepath.Join(runDir(sandboxHome), zellijProxySocketName)
+	if err := checkSocketPath(listenPath); err != nil {
+		return fmt.Errorf("zellij: %w", err)
+	}
+
+	// A zellij under home is not on the sandbox's PATH, nor can a file be
+	// mounted over it without clashing with the home mounts; the sandbox
+	// then calls `devsandbox zellij` itself.
+	var shim string
+	if binaryNeedsBind(bin, homeDir) {
+		if shim, err = writeZellijShim(runDir(sandboxHome)); err != nil {
+			return fmt.Errorf("zellij: %w", err)
+		}
+	}
+
+	z.proxy = zellijproxy.New(bin, session, listenPath, filter, owned)
+	if z.logger != nil {
+		z.proxy.SetLogger(z.logger)
+	}
+	if err := z.proxy.Start(ctx); err != nil {
+		z.proxy = nil
+		if shim != "" {
+			_ = os.Remove(shim)
+		}
+		return fmt.Errorf("zellij: start proxy: %w", err)
+	}
+	z.bin, z.shim = bin, shim
+	notice.Info("zellij proxy active. Capabilities: %v", caps)
+	return nil
+}
+
+// writeZellijShim writes the script that stands in for zellij inside the
+// sandbox: it runs `devsandbox zellij` with its arguments, so the zellij run
+// and zellij action commands tools already issue reach the proxy unchanged.
+func writeZellijShim(dir string) (string, error) {
+	self, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("locate devsandbox binary: %w", err)
+	}
+	path := filepath.Join(dir, zellijShimName)
+	script := "#!/bin/sh\nexec '" + strings.ReplaceAll(self, "'", `'\''`) + "' zellij \"$@\"\n"
+	if err := os.WriteFile(path, []byte(script), 0o755); err != nil {
+		return "", fmt.Errorf("write shim: %w", err)
+	}
+	return path, nil
+}
+
+// Stop implements ActiveTool.
+func (z *Zellij) Stop() error {
+	if z.proxy == nil {
+		return nil
+	}
+	err := z.proxy.Stop()
+	z.proxy = nil
+	if z.shim != "" {
+		_ = os.Remove(z.shim)
+		z.shim = ""
+	}
+	return err
+}
+
+var zellijVersionPattern = regexp.MustCompile(`([0-9]+)\.([0-9]+)\.[0-9]+`)
+
+// checkZellijVersion refuses zellij releases before 0.44. Those neither print
+// the ID of the pane `zellij run` opens nor accept --pane-id, so the proxy
+// could not tell which panes the sandbox owns.
+func checkZellijVersion(bin string) error {
+	out, err := exec.Command(bin, "--version").Output()
+	if err != nil {
+		return fmt.Errorf("read version: %w", err)
+	}
+	m := zellijVersionPattern.FindStringSubmatch(string(out))
+	if m == nil {
+		return fmt.Errorf("cannot parse version %q; the proxy needs zellij 0.44 or later", strings.TrimSpace(string(out)))
+	}
+	major, _ := strconv.Atoi(m[1])
+	minor, _ := strconv.Atoi(m[2])
+	if major == 0 && minor < 44 {
+		return fmt.Errorf("%s is too old; the proxy needs zellij 0.44 or later", strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
 // Ensure interfaces are implemented.
 var (
 	_ Tool           = (*Zellij)(nil)
 	_ ToolWithConfig = (*Zellij)(nil)
 	_ ToolWithCheck  = (*Zellij)(nil)
+	_ ActiveTool     = (*Zellij)(nil)
+	_ ToolWithLogger = (*Zellij)(nil)
 )
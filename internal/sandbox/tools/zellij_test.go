@@ -1,11 +1,15 @@
 package tools
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
+
+	"devsandbox/internal/zellijproxy"
 )
 
 func TestZellij_Registered(t *testing.T) {
@@ -370,3 +374,167 @@ func TestZellij_Check_OK(t *testing.T) {
 		t.Error("expected info about session name")
 	}
 }
+
+func TestZellij_ConfigureProxy(t *testing.T) {
+	z := &Zellij{}
+	z.Configure(GlobalConfig{}, map[string]any{
+		"mode":               "enforce",
+		"commands":           []any{"revdiff", " "},
+		"extra_capabilities": []any{"close_pane_owned", "run_floating", "bogus"},
+	})
+	if z.mode != zellijModeEnforce {
+		t.Errorf("mode = %q", z.mode)
+	}
+	if !slices.Equal(z.commands, []string{"revdiff"}) {
+		t.Errorf("commands = %q", z.commands)
+	}
+	if !slices.Equal(z.extraCapabilities, []zellijproxy.Capability{zellijproxy.CapClosePaneOwned}) {
+		t.Errorf("extra capabilities = %v; launch and unknown entries must be dropped", z.extraCapabilities)
+	}
+
+	z.Configure(GlobalConfig{}, map[string]any{"mode": "sometimes"})
+	if z.mode != zellijModeAuto {
+		t.Errorf("unknown mode should fall back to auto, got %q", z.mode)
+	}
+}
+
+// fakeZellijBin puts a zellij stand-in on PATH that reports version and
+// prints a pane ID for `run`.
+func fakeZellijBin(t *testing.T, version string) string {
+	t.Helper()
+	dir := t.TempDir()
+	script := "#!/bin/sh\n" +
+		"[ \"$1\" = --version ] && { echo 'zellij " + version + "'; exit 0; }\n" +
+		"[ \"$3\" = run ] && echo terminal_4\n" +
+		"exit 0\n"
+	if err := os.WriteFile(filepath.Join(dir, "zellij"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return filepath.Join(dir, "zellij")
+}
+
+func TestZellij_Start_AutoModeInactiveWithoutCommands(t *testing.T) {
+	t.Setenv("ZELLIJ", "0")
+	t.Setenv("ZELLIJ_SESSION_NAME", "main")
+	z := &Zellij{}
+	z.Configure(GlobalConfig{}, nil)
+
+	dir := t.TempDir()
+	if err := z.Start(context.Background(), dir, dir); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer func() { _ = z.Stop() }()
+
+	if z.proxy != nil {
+		t.Error("auto mode with no commands should not start a proxy")
+	}
+	if env := z.Environment(dir, dir); len(env) != 0 {
+		t.Errorf("inactive proxy must export nothing, got %+v", env)
+	}
+}
+
+func TestZellij_Start_RunsProxy(t *testing.T) {
+	bin := fakeZellijBin(t, "0.44.1")
+	t.Setenv("ZELLIJ", "0")
+	t.Setenv("ZELLIJ_SESSION_NAME", "main")
+	z := &Zellij{}
+	z.Configure(GlobalConfig{}, map[string]any{"commands": []any{"sleep"}})
+
+	sandboxHome := shortSocketDir(t)
+	if err := z.Start(context.Background(), sandboxHome, sandboxHome); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer func() { _ = z.Stop() }()
+	if z.proxy == nil {
+		t.Fatal("expected proxy to start when commands are configured")
+	}
+
+	var sock string
+	exported := map[string]bool{}
+	for _, e := range z.Environment(sandboxHome, sandboxHome) {
+		if e.Name == "DEVSANDBOX_ZELLIJ_SOCKET" {
+			sock = e.Value
+		}
+		exported[e.Name] = true
+	}
+	if want := filepath.Join(runDir(sandboxHome), zellijProxySocketName); sock != want {
+		t.Fatalf("DEVSANDBOX_ZELLIJ_SOCKET = %q, want %q", sock, want)
+	}
+	for _, name := range []string{"ZELLIJ", "ZELLIJ_SESSION_NAME"} {
+		if !exported[name] {
+			t.Errorf("%s not exported alongside the proxy", name)
+		}
+	}
+
+	// The host zellij is replaced by the shim; the socket directory and the
+	// real binary stay outside.
+	shim := filepath.Join(runDir(sandboxHome), zellijShimName)
+	var shimBound bool
+	for _, b := range z.Bindings(sandboxHome, sandboxHome) {
+		switch {
+		case b.Source == shim && b.Dest == bin && b.ReadOnly:
+			shimBound = true
+		case strings.Contains(b.Source, "zellij"):
+			t.Errorf("socket directory or zellij binary mounted alongside the proxy: %+v", b)
+		}
+	}
+	if !shimBound {
+		t.Errorf("shim %s not mounted over %s", shim, bin)
+	}
+	script, err := os.ReadFile(shim)
+	if err != nil {
+		t.Fatalf("read shim: %v", err)
+	}
+	if !strings.HasSuffix(string(script), "' zellij \"$@\"\n") {
+		t.Errorf("shim does not relay to devsandbox zellij:\n%s", script)
+	}
+
+	resp, err := zellijproxy.Call(sock, []string{"run", "--", "sleep", "600"})
+	if err != nil || !resp.OK || resp.Output != "terminal_4\n" {
+		t.Fatalf("run through the proxy: %+v, %v", resp, err)
+	}
+	if resp, _ := zellijproxy.Call(sock, []string{"action", "close-pane", "--pane-id", "terminal_1"}); resp.OK {
+		t.Error("close-pane of a pane the sandbox did not open was allowed")
+	}
+
+	if err := z.Stop(); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	if _, err := os.Stat(shim); !os.IsNotExist(err) {
+		t.Errorf("shim left behind after Stop: %v", err)
+	}
+}
+
+func TestZellij_Start_RefusesOldZellij(t *testing.T) {
+	fakeZellijBin(t, "0.43.1")
+	t.Setenv("ZELLIJ", "0")
+	t.Setenv("ZELLIJ_SESSION_NAME", "main")
+	z := &Zellij{}
+	z.Configure(GlobalConfig{}, map[string]any{"commands": []any{"sleep"}})
+
+	sandboxHome := shortSocketDir(t)
+	err := z.Start(context.Background(), sandboxHome, sandboxHome)
+	if err == nil || !strings.Contains(err.Error(), "0.44") {
+		t.Errorf("Start with zellij 0.43: err = %v", err)
+	}
+	if z.proxy != nil {
+		t.Error("proxy started against an old zellij")
+	}
+}
+
+func TestZellij_Start_EnabledSkipsProxy(t *testing.T) {
+	fakeZellijBin(t, "0.44.1")
+	t.Setenv("ZELLIJ", "0")
+	t.Setenv("ZELLIJ_SESSION_NAME", "main")
+	z := &Zellij{}
+	z.Configure(GlobalConfig{}, map[string]any{"enabled": true, "commands": []any{"sleep"}})
+
+	sandboxHome := shortSocketDir(t)
+	if err := z.Start(context.Background(), sandboxHome, sandboxHome); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if z.proxy != nil {
+		t.Error("proxy started although the raw socket is forwarded")
+	}
+}
@@ -25,6 +25,9 @@ type Session struct {
 	ProxyPort      int             `json:"proxy_port,omitempty"`
 	ForwardedPorts []ForwardedPort `json:"forwarded_ports,omitempty"`
 	Worktree       *WorktreeInfo   `json:"worktree,omitempty"`
+	// CgroupDir is the sandbox's own cgroup, read by `devsandbox sessions
+	// stats`. Empty when the sandbox runs in the caller's cgroup.
+	CgroupDir string `json:"cgroup_dir,omitempty"`
 }
 
 // WorktreeInfo records the git worktree this session is rooted at, if any.
@@ -43,8 +46,18 @@ type ForwardedPort struct {
 	SandboxPort int    `json:"sandbox_port"`
 	Bind        string `json:"bind"`
 	Protocol    string `json:"protocol"`
+	// Direction is DirectionReverse for a sandbox→host forward; empty means
+	// host→sandbox.
+	Direction string `json:"direction,omitempty"`
+	// HostSocket is the host unix socket a reverse forward relays to, in
+	// place of HostPort.
+	HostSocket string `json:"host_socket,omitempty"`
 }
 
+// DirectionReverse marks a ForwardedPort that relays connections made inside
+// the sandbox to the host.
+const DirectionReverse = "reverse"
+
 // Store manages session files in a directory.
 type Store struct {
 	dir string
@@ -0,0 +1,44 @@
+// Package tmuxproxy provides a filtering proxy in front of the host's tmux
+// server.
+//
+// SECURITY MODEL: The tmux client protocol passes file descriptors and gives
+// every client the full command set, so it is not proxied. The sandbox instead
+// sends a tmux command line as an argv over the proxy socket; the filter
+// validates it against an allowlist of capabilities and the proxy runs a
+// rebuilt, canonical argv with the host tmux binary. Commands that open a pane
+// run the host process named in the argv and must match a CommandPattern
+// allowlist; every other mutating command is scoped to panes the sandbox
+// itself opened (ownership tracking). The host tmux socket is NOT bind-mounted
+// into the sandbox; only the proxy socket is.
+package tmuxproxy
+
+// Capability identifies a single tmux operation the proxy may allow.
+// Mutating capabilities are suffixed `_owned` to make ownership scoping explicit.
+type Capability string
+
+const (
+	CapSplitWindow Capability = "split_window" // split-window <command>
+	CapNewWindow   Capability = "new_window"   // new-window <command>
+
+	CapSendKeysOwned    Capability = "send_keys_owned"    // send-keys, scoped to owned panes
+	CapKillPaneOwned    Capability = "kill_pane_owned"    // kill-pane, scoped to owned panes
+	CapSelectPaneOwned  Capability = "select_pane_owned"  // select-pane, scoped to owned panes
+	CapCapturePaneOwned Capability = "capture_pane_owned" // capture-pane -p, scoped to owned panes
+	CapListOwned        Capability = "list_owned"         // list-panes — output filtered to owned panes only
+)
+
+// IsLaunch reports whether c opens a pane. Launch capabilities imply host code
+// execution and must be paired with a CommandPattern allowlist.
+func IsLaunch(c Capability) bool {
+	return c == CapSplitWindow || c == CapNewWindow
+}
+
+// IsKnown reports whether c is a capability this proxy implements.
+func IsKnown(c Capability) bool {
+	switch c {
+	case CapSplitWindow, CapNewWindow,
+		CapSendKeysOwned, CapKillPaneOwned, CapSelectPaneOwned, CapCapturePaneOwned, CapListOwned:
+		return true
+	}
+	return false
+}
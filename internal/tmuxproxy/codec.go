@@ -0,0 +1,115 @@
+package tmuxproxy
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"devsandbox/internal/socketproxy"
+)
+
+// maxRequestBytes caps a single request line, bounding memory use from a
+// malformed or hostile peer. A tmux command line is a few hundred bytes.
+const maxRequestBytes = 1 << 20 // 1 MiB
+
+// maxResponseBytes caps a response the client reads: maxOutputBytes of output,
+// with room for JSON escaping.
+const maxResponseBytes = 4 * maxOutputBytes
+
+// The proxy speaks one newline-terminated JSON object in each direction per
+// connection:
+//
+//	{"argv":["split-window","-h","--","revdiff","main"]}\n
+//	{"ok":true,"output":"%12\n"}\n
+//
+// argv is a tmux command line without the leading `tmux` and without any
+// server-selection flags (-S, -L): the proxy always talks to the host server
+// devsandbox was started from.
+
+// Request is one command the sandbox asks the host tmux server to run.
+type Request struct {
+	Argv []string `json:"argv"`
+}
+
+// Response reports the outcome of a request. Output is the command's standard
+// output; Error carries either the proxy's denial or tmux's own error text.
+type Response struct {
+	OK     bool   `json:"ok"`
+	Output string `json:"output,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// ReadRequest reads and strictly decodes one request line from r.
+func ReadRequest(r *bufio.Reader) (Request, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return Request{}, err
+	}
+	var req Request
+	if err := socketproxy.StrictUnmarshal(line, &req); err != nil {
+		return Request{}, fmt.Errorf("malformed request: %w", err)
+	}
+	return req, nil
+}
+
+// readLine reads one line, refusing to buffer more than maxRequestBytes.
+func readLine(r *bufio.Reader) ([]byte, error) {
+	var out []byte
+	for {
+		chunk, err := r.ReadSlice('\n')
+		out = append(out, chunk...)
+		if len(out) > maxRequestBytes {
+			return nil, fmt.Errorf("request exceeds %d bytes", maxRequestBytes)
+		}
+		switch {
+		case err == nil:
+			return out[:len(out)-1], nil
+		case errors.Is(err, bufio.ErrBufferFull):
+			continue
+		case errors.Is(err, io.EOF) && len(out) > 0:
+			return nil, io.ErrUnexpectedEOF
+		default:
+			return nil, err
+		}
+	}
+}
+
+// WriteMessage writes v as one JSON line.
+func WriteMessage(w io.Writer, v any) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(body, '\n'))
+	return err
+}
+
+// Call sends argv to the proxy listening at socketPath and returns its
+// response. It is the client side of the protocol, used by the in-sandbox
+// `devsandbox tmux` command.
+func Call(socketPath string, argv []string) (Response, error) {
+	conn, err := net.Dial("unix", socketPath)
+	if err != nil {
+		return Response{}, fmt.Errorf("connect to tmux proxy: %w", err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	// The proxy bounds the tmux command it runs with the same timeout, so a
+	// reply arrives well inside this one.
+	if err := conn.SetDeadline(time.Now().Add(2 * ioTimeout)); err != nil {
+		return Response{}, err
+	}
+	if err := WriteMessage(conn, Request{Argv: argv}); err != nil {
+		return Response{}, fmt.Errorf("send request: %w", err)
+	}
+
+	var resp Response
+	if err := json.NewDecoder(io.LimitReader(conn, maxResponseBytes)).Decode(&resp); err != nil {
+		return Response{}, fmt.Errorf("read response: %w", err)
+	}
+	return resp, nil
+}
@@ -0,0 +1,37 @@
+package tmuxproxy
+
+import (
+	"bufio"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestReadRequest(t *testing.T) {
+	req, err := ReadRequest(bufio.NewReader(strings.NewReader(`{"argv":["kill-pane","-t","%1"]}` + "\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(req.Argv, []string{"kill-pane", "-t", "%1"}) {
+		t.Errorf("argv = %q", req.Argv)
+	}
+}
+
+func TestReadRequest_Rejects(t *testing.T) {
+	tests := map[string]string{
+		"unknown field":    `{"argv":["list-panes"],"socket":"/tmp/other"}` + "\n",
+		"case variant":     `{"argv":["list-panes"],"ARGV":["kill-server"]}` + "\n",
+		"trailing data":    `{"argv":["list-panes"]}{}` + "\n",
+		"not an object":    `["list-panes"]` + "\n",
+		"unterminated":     `{"argv":["list-panes"]}`,
+		"oversized":        `{"argv":["` + strings.Repeat("a", maxRequestBytes) + `"]}` + "\n",
+		"empty connection": "",
+	}
+	for name, in := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := ReadRequest(bufio.NewReader(strings.NewReader(in))); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
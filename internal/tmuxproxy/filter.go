@@ -0,0 +1,396 @@
+package tmuxproxy
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"devsandbox/internal/cmdpattern"
+)
+
+// Decision is the outcome of filtering a single tmux command.
+type Decision struct {
+	Allow  bool
+	Reason string // populated for both allow and deny (deny: why; allow: short summary for logs)
+	Cmd    string // canonical command name (best effort)
+
+	// Argv is the command line the proxy runs on allow. It is rebuilt from
+	// the parsed request rather than copied from it, so only flags the filter
+	// modelled reach tmux.
+	Argv []string
+	// Program is argv[0] of the command a launch runs.
+	Program string
+}
+
+// FilterConfig configures a Filter.
+type FilterConfig struct {
+	Capabilities   []Capability
+	LaunchPatterns []cmdpattern.CommandPattern
+	Owned          *OwnedSet
+
+	// HostPaneID is the host's TMUX_PANE, read on the host side. It anchors
+	// where a launch opens its pane: next to the pane devsandbox itself runs
+	// in, or next to an owned one. Empty means a launch must name an owned
+	// pane.
+	HostPaneID string
+}
+
+// Filter decides whether a single tmux command may run against the host
+// server.
+type Filter struct {
+	caps       map[Capability]struct{}
+	patterns   []cmdpattern.CommandPattern
+	owned      *OwnedSet
+	hostPaneID string
+}
+
+func NewFilter(cfg FilterConfig) *Filter {
+	caps := make(map[Capability]struct{}, len(cfg.Capabilities))
+	for _, c := range cfg.Capabilities {
+		caps[c] = struct{}{}
+	}
+	owned := cfg.Owned
+	if owned == nil {
+		owned = NewOwnedSet()
+	}
+	hostPane := strings.TrimSpace(cfg.HostPaneID)
+	if !paneIDPattern.MatchString(hostPane) {
+		hostPane = ""
+	}
+	return &Filter{
+		caps:       caps,
+		patterns:   cfg.LaunchPatterns,
+		owned:      owned,
+		hostPaneID: hostPane,
+	}
+}
+
+func (f *Filter) hasCap(c Capability) bool {
+	_, ok := f.caps[c]
+	return ok
+}
+
+// paneFormat is the -F format the proxy passes to every command that prints
+// panes: a launch prints the pane it opened, list-panes one pane per line.
+const paneFormat = "#{pane_id}"
+
+var (
+	// paneIDPattern is the only target spelling accepted. tmux pane IDs are
+	// never reused while the server runs, so an owned ID cannot come to
+	// name someone else's pane; names, indexes and the session:window.pane
+	// forms all resolve against state the sandbox does not control.
+	paneIDPattern = regexp.MustCompile(`^%[0-9]+$`)
+	sizePattern   = regexp.MustCompile(`^[0-9]{1,5}%?$`)
+	linePattern   = regexp.MustCompile(`^(-|-?[0-9]{1,9})$`)
+)
+
+// aliases maps tmux's documented short command names to the full ones. tmux
+// also accepts any unambiguous prefix; those are refused rather than guessed.
+var aliases = map[string]string{
+	"splitw":   "split-window",
+	"neww":     "new-window",
+	"send":     "send-keys",
+	"killp":    "kill-pane",
+	"selectp":  "select-pane",
+	"capturep": "capture-pane",
+	"lsp":      "list-panes",
+}
+
+// flagSpec lists the flags a command accepts; true means the flag takes a
+// value. Any flag not listed is a denial.
+type flagSpec map[byte]bool
+
+// Decide inspects argv and returns an allow/deny decision.
+func (f *Filter) Decide(argv []string) Decision {
+	if len(argv) == 0 {
+		return Decision{Reason: "empty command"}
+	}
+	// tmux splits a command line on any argument ending in `;`, so one
+	// approved command could carry a second one the filter never saw.
+	for _, a := range argv {
+		if strings.HasSuffix(a, ";") {
+			return Decision{Cmd: argv[0], Reason: "arguments ending in `;` are forbidden (tmux reads them as a command separator)"}
+		}
+	}
+
+	name := argv[0]
+	if full, ok := aliases[name]; ok {
+		name = full
+	}
+	switch name {
+	case "split-window":
+		return f.decideLaunch(name, argv[1:], CapSplitWindow)
+	case "new-window":
+		return f.decideLaunch(name, argv[1:], CapNewWindow)
+	case "send-keys":
+		return f.decideSendKeys(argv[1:])
+	case "kill-pane":
+		return f.decideOwnedPane(name, argv[1:], CapKillPaneOwned)
+	case "select-pane":
+		return f.decideOwnedPane(name, argv[1:], CapSelectPaneOwned)
+	case "capture-pane":
+		return f.decideCapturePane(argv[1:])
+	case "list-panes":
+		return f.decideListPanes(argv[1:])
+	default:
+		return Decision{Cmd: name, Reason: fmt.Sprintf("command %q not supported by proxy", name)}
+	}
+}
+
+// decideLaunch handles split-window and new-window. The pane runs the
+// command on the host, so the command must match a launch pattern; a launch
+// without one would start the host user's default shell.
+//
+// split-window may target the host pane or an owned one. new-window takes no
+// target: the proxy opens the window after the host pane's window, so the
+// sandbox cannot choose which session it lands in.
+func (f *Filter) decideLaunch(name string, args []string, capability Capability) Decision {
+	if !f.hasCap(capability) {
+		return Decision{Cmd: name, Reason: fmt.Sprintf("capability %s not granted", capability)}
+	}
+
+	spec := flagSpec{'d': false}
+	if name == "split-window" {
+		spec = flagSpec{'h': false, 'v': false, 'b': false, 'd': false, 'f': false, 'l': true, 't': true}
+	}
+	flags, order, command, err := parseFlags(args, spec)
+	if err != nil {
+		return Decision{Cmd: name, Reason: err.Error()}
+	}
+	if len(command) == 0 {
+		return Decision{Cmd: name, Reason: "a command is required (the default would be a host shell)"}
+	}
+	if size, ok := flags['l']; ok && !sizePattern.MatchString(size) {
+		return Decision{Cmd: name, Reason: fmt.Sprintf("invalid size %q", size)}
+	}
+
+	target, hasTarget := flags['t']
+	switch {
+	case !hasTarget:
+		if f.hostPaneID == "" {
+			return Decision{Cmd: name, Reason: "no target pane (TMUX_PANE is unset on the host)"}
+		}
+		target = f.hostPaneID
+	case target == f.hostPaneID:
+	case !f.ownsPane(target):
+		return Decision{Cmd: name, Reason: fmt.Sprintf("target %q is neither the host pane nor owned", target)}
+	}
+
+	command, ok := f.matchPattern(command)
+	if !ok {
+		return Decision{Cmd: name, Program: command[0], Reason: fmt.Sprintf("command %q not in allowlist", command[0])}
+	}
+
+	out := []string{name}
+	if name == "new-window" {
+		out = append(out, "-a")
+	}
+	for _, flag := range order {
+		if flag == 't' {
+			continue
+		}
+		out = append(out, "-"+string(flag))
+		if spec[flag] {
+			out = append(out, flags[flag])
+		}
+	}
+	out = append(out, "-t", target, "-P", "-F", paneFormat, "--")
+	// tmux 3.0 and later exec a multi-word command directly, so the argv the
+	// pattern matched is the one that runs. A single word goes through the
+	// default shell instead; quoting it keeps it one literal word there.
+	if len(command) == 1 {
+		out = append(out, shellQuote(command[0]))
+	} else {
+		out = append(out, command...)
+	}
+	return Decision{Allow: true, Cmd: name, Argv: out, Program: command[0], Reason: fmt.Sprintf("launch %q", command[0])}
+}
+
+// matchPattern returns the command to run when a launch pattern accepts it.
+//
+// A pattern pinned to a resolved binary also accepts its bare Program name,
+// and the returned command then names the pinned path: tmux looks a bare name
+// up on the tmux server's PATH, which need not be the one the pattern was
+// resolved against.
+func (f *Filter) matchPattern(command []string) ([]string, bool) {
+	for _, p := range f.patterns {
+		if p.MatchesArgv(command) {
+			return command, true
+		}
+		if p.ResolvedBin != "" && command[0] == p.Program {
+			pinned := append([]string{p.ResolvedBin}, command[1:]...)
+			if p.MatchesArgv(pinned) {
+				return pinned, true
+			}
+		}
+	}
+	return command, false
+}
+
+// decideSendKeys allows send-keys to an owned pane. Only -l (literal) is
+// accepted: -K sends to a client's key table rather than the pane, -X runs
+// copy-mode commands, -R and -M reach past the text a pane receives.
+func (f *Filter) decideSendKeys(args []string) Decision {
+	const name = "send-keys"
+	if !f.hasCap(CapSendKeysOwned) {
+		return Decision{Cmd: name, Reason: fmt.Sprintf("capability %s not granted", CapSendKeysOwned)}
+	}
+	flags, _, keys, err := parseFlags(args, flagSpec{'l': false, 't': true})
+	if err != nil {
+		return Decision{Cmd: name, Reason: err.Error()}
+	}
+	target, d := f.ownedTarget(name, flags)
+	if !d.Allow {
+		return d
+	}
+	if len(keys) == 0 {
+		return Decision{Cmd: name, Reason: "no keys given"}
+	}
+	out := []string{name, "-t", target}
+	if _, ok := flags['l']; ok {
+		out = append(out, "-l")
+	}
+	out = append(out, "--")
+	out = append(out, keys...)
+	return Decision{Allow: true, Cmd: name, Argv: out, Reason: "owned pane " + target}
+}
+
+// decideOwnedPane allows a command that takes nothing but an owned -t.
+// kill-pane -a (every pane but the target) is refused by the same rule.
+func (f *Filter) decideOwnedPane(name string, args []string, capability Capability) Decision {
+	if !f.hasCap(capability) {
+		return Decision{Cmd: name, Reason: fmt.Sprintf("capability %s not granted", capability)}
+	}
+	flags, _, rest, err := parseFlags(args, flagSpec{'t': true})
+	if err != nil {
+		return Decision{Cmd: name, Reason: err.Error()}
+	}
+	if len(rest) > 0 {
+		return Decision{Cmd: name, Reason: "unexpected arguments"}
+	}
+	target, d := f.ownedTarget(name, flags)
+	if !d.Allow {
+		return d
+	}
+	return Decision{Allow: true, Cmd: name, Argv: []string{name, "-t", target}, Reason: "owned pane " + target}
+}
+
+// decideCapturePane allows capture-pane of an owned pane to standard output.
+// -b (into a paste buffer) is refused: buffers are server-wide.
+func (f *Filter) decideCapturePane(args []string) Decision {
+	const name = "capture-pane"
+	if !f.hasCap(CapCapturePaneOwned) {
+		return Decision{Cmd: name, Reason: fmt.Sprintf("capability %s not granted", CapCapturePaneOwned)}
+	}
+	spec := flagSpec{'p': false, 'J': false, 'e': false, 'S': true, 'E': true, 't': true}
+	flags, order, rest, err := parseFlags(args, spec)
+	if err != nil {
+		return Decision{Cmd: name, Reason: err.Error()}
+	}
+	if len(rest) > 0 {
+		return Decision{Cmd: name, Reason: "unexpected arguments"}
+	}
+	target, d := f.ownedTarget(name, flags)
+	if !d.Allow {
+		return d
+	}
+	out := []string{name, "-p", "-t", target}
+	for _, flag := range order {
+		switch flag {
+		case 'p', 't':
+			continue
+		case 'S', 'E':
+			if !linePattern.MatchString(flags[flag]) {
+				return Decision{Cmd: name, Reason: fmt.Sprintf("invalid line %q", flags[flag])}
+			}
+			out = append(out, "-"+string(flag), flags[flag])
+		default:
+			out = append(out, "-"+string(flag))
+		}
+	}
+	return Decision{Allow: true, Cmd: name, Argv: out, Reason: "owned pane " + target}
+}
+
+// decideListPanes allows a bare list-panes. The proxy lists every pane with
+// a fixed format and drops the ones not owned; a caller-supplied format could
+// loop over the server's other sessions (#{S:...}) or run a shell command
+// (#(...)).
+func (f *Filter) decideListPanes(args []string) Decision {
+	const name = "list-panes"
+	if !f.hasCap(CapListOwned) {
+		return Decision{Cmd: name, Reason: fmt.Sprintf("capability %s not granted", CapListOwned)}
+	}
+	if len(args) > 0 {
+		return Decision{Cmd: name, Reason: "list-panes takes no arguments through the proxy"}
+	}
+	return Decision{Allow: true, Cmd: name, Argv: []string{name, "-a", "-F", paneFormat}, Reason: "filtered to owned panes"}
+}
+
+// ownedTarget returns the required -t value when it names an owned pane. The
+// returned Decision is an allow placeholder on success and the denial
+// otherwise.
+func (f *Filter) ownedTarget(name string, flags map[byte]string) (string, Decision) {
+	target, ok := flags['t']
+	if !ok {
+		return "", Decision{Cmd: name, Reason: "-t with an owned pane ID is required"}
+	}
+	if !f.ownsPane(target) {
+		return "", Decision{Cmd: name, Reason: fmt.Sprintf("target %q not owned", target)}
+	}
+	return target, Decision{Allow: true}
+}
+
+func (f *Filter) ownsPane(target string) bool {
+	return paneIDPattern.MatchString(target) && f.owned.Contains(target)
+}
+
+// parseFlags parses args the way tmux's getopt does: flags may be clustered
+// (-hd), a value may be attached (-l50) or follow (-l 50), and `--` or the
+// first non-flag word ends them. It returns the flag values, the order flags
+// appeared in, and the remaining words. A flag not in spec, or given twice,
+// is an error.
+func parseFlags(args []string, spec flagSpec) (map[byte]string, []byte, []string, error) {
+	flags := make(map[byte]string)
+	var order []byte
+	i := 0
+	for ; i < len(args); i++ {
+		a := args[i]
+		if a == "--" {
+			i++
+			break
+		}
+		if len(a) < 2 || a[0] != '-' {
+			break
+		}
+		for j := 1; j < len(a); j++ {
+			flag := a[j]
+			takesValue, known := spec[flag]
+			if !known {
+				return nil, nil, nil, fmt.Errorf("flag -%c is not allowed through the proxy", flag)
+			}
+			if _, dup := flags[flag]; dup {
+				return nil, nil, nil, fmt.Errorf("flag -%c given twice", flag)
+			}
+			order = append(order, flag)
+			if !takesValue {
+				flags[flag] = ""
+				continue
+			}
+			if j+1 < len(a) {
+				flags[flag] = a[j+1:]
+			} else if i+1 < len(args) {
+				i++
+				flags[flag] = args[i]
+			} else {
+				return nil, nil, nil, fmt.Errorf("flag -%c requires a value", flag)
+			}
+			break
+		}
+	}
+	return flags, order, args[i:], nil
+}
+
+// shellQuote quotes s as a single shell word.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
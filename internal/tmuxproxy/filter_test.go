@@ -0,0 +1,197 @@
+package tmuxproxy
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"devsandbox/internal/cmdpattern"
+)
+
+var allCaps = []Capability{
+	CapSplitWindow, CapNewWindow,
+	CapSendKeysOwned, CapKillPaneOwned, CapSelectPaneOwned, CapCapturePaneOwned, CapListOwned,
+}
+
+func newTestFilter(caps ...Capability) (*Filter, *OwnedSet) {
+	owned := NewOwnedSet()
+	owned.Add("%7")
+	f := NewFilter(FilterConfig{
+		Capabilities: caps,
+		LaunchPatterns: []cmdpattern.CommandPattern{
+			{Program: "revdiff", ResolvedBin: "/usr/local/bin/revdiff", ArgsMatcher: cmdpattern.MatchAny()},
+			{Program: "watchexec", ArgsMatcher: cmdpattern.MatchPrefix("-e", "go")},
+		},
+		Owned:      owned,
+		HostPaneID: "%1",
+	})
+	return f, owned
+}
+
+func TestFilter_Launch(t *testing.T) {
+	f, _ := newTestFilter(allCaps...)
+
+	tests := []struct {
+		name string
+		argv []string
+		want []string
+	}{
+		{
+			name: "split next to host pane, bare name runs pinned binary",
+			argv: []string{"split-window", "-h", "-l", "40%", "--", "revdiff", "main"},
+			want: []string{"split-window", "-h", "-l", "40%", "-t", "%1", "-P", "-F", "#{pane_id}", "--", "/usr/local/bin/revdiff", "main"},
+		},
+		{
+			name: "clustered flags and attached value",
+			argv: []string{"splitw", "-dvl20", "-t%7", "watchexec", "-e", "go"},
+			want: []string{"split-window", "-d", "-v", "-l", "20", "-t", "%7", "-P", "-F", "#{pane_id}", "--", "watchexec", "-e", "go"},
+		},
+		{
+			name: "single word is shell-quoted",
+			argv: []string{"split-window", "/usr/local/bin/revdiff"},
+			want: []string{"split-window", "-t", "%1", "-P", "-F", "#{pane_id}", "--", "'/usr/local/bin/revdiff'"},
+		},
+		{
+			name: "new window opens after the host pane's window",
+			argv: []string{"new-window", "-d", "revdiff"},
+			want: []string{"new-window", "-a", "-d", "-t", "%1", "-P", "-F", "#{pane_id}", "--", "'/usr/local/bin/revdiff'"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := f.Decide(tt.argv)
+			if !d.Allow {
+				t.Fatalf("denied: %s", d.Reason)
+			}
+			if !slices.Equal(d.Argv, tt.want) {
+				t.Errorf("argv = %q\nwant %q", d.Argv, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilter_LaunchDenied(t *testing.T) {
+	f, _ := newTestFilter(allCaps...)
+
+	tests := map[string][]string{
+		"no command (host shell)":      {"split-window", "-h"},
+		"command not allowlisted":      {"split-window", "--", "sh", "-c", "id"},
+		"args outside prefix":          {"split-window", "watchexec", "-e", "rs"},
+		"foreign target":               {"split-window", "-t", "%3", "revdiff"},
+		"target by name":               {"split-window", "-t", "main:0.1", "revdiff"},
+		"start directory":              {"split-window", "-c", "/", "revdiff"},
+		"environment":                  {"split-window", "-e", "LD_PRELOAD=/tmp/x.so", "revdiff"},
+		"custom print format":          {"split-window", "-F", "#(id)", "revdiff"},
+		"invalid size":                 {"split-window", "-l", "#(id)", "revdiff"},
+		"new-window target":            {"new-window", "-t", "%7", "revdiff"},
+		"new-window name":              {"new-window", "-n", "x", "revdiff"},
+		"trailing separator":           {"split-window", "revdiff", "main;"},
+		"separator word":               {"split-window", "revdiff", ";", "kill-server"},
+		"repeated flag":                {"split-window", "-t", "%1", "-t", "%3", "revdiff"},
+		"unambiguous prefix not alias": {"split-w", "revdiff"},
+	}
+	for name, argv := range tests {
+		t.Run(name, func(t *testing.T) {
+			if d := f.Decide(argv); d.Allow {
+				t.Errorf("expected deny, got allow: %q", d.Argv)
+			}
+		})
+	}
+}
+
+func TestFilter_LaunchRequiresCapability(t *testing.T) {
+	f, _ := newTestFilter(CapSendKeysOwned)
+	d := f.Decide([]string{"split-window", "revdiff"})
+	if d.Allow || !strings.Contains(d.Reason, string(CapSplitWindow)) {
+		t.Errorf("expected capability denial, got %+v", d)
+	}
+}
+
+func TestFilter_LaunchWithoutHostPane(t *testing.T) {
+	owned := NewOwnedSet()
+	owned.Add("%7")
+	f := NewFilter(FilterConfig{
+		Capabilities:   []Capability{CapSplitWindow},
+		LaunchPatterns: []cmdpattern.CommandPattern{{Program: "revdiff", ArgsMatcher: cmdpattern.MatchAny()}},
+		Owned:          owned,
+		HostPaneID:     "not-a-pane",
+	})
+	if d := f.Decide([]string{"split-window", "revdiff"}); d.Allow {
+		t.Error("launch without a host pane should need an explicit owned target")
+	}
+	if d := f.Decide([]string{"split-window", "-t", "%7", "revdiff"}); !d.Allow {
+		t.Errorf("launch next to an owned pane denied: %s", d.Reason)
+	}
+}
+
+func TestFilter_OwnedCommands(t *testing.T) {
+	f, _ := newTestFilter(allCaps...)
+
+	tests := []struct {
+		argv []string
+		want []string
+	}{
+		{[]string{"send-keys", "-t", "%7", "q"}, []string{"send-keys", "-t", "%7", "--", "q"}},
+		{[]string{"send", "-lt", "%7", "--", "-x", "Enter"}, []string{"send-keys", "-t", "%7", "-l", "--", "-x", "Enter"}},
+		{[]string{"kill-pane", "-t", "%7"}, []string{"kill-pane", "-t", "%7"}},
+		{[]string{"selectp", "-t%7"}, []string{"select-pane", "-t", "%7"}},
+		{[]string{"capture-pane", "-J", "-S", "-", "-E", "-10", "-t", "%7"}, []string{"capture-pane", "-p", "-t", "%7", "-J", "-S", "-", "-E", "-10"}},
+		{[]string{"list-panes"}, []string{"list-panes", "-a", "-F", "#{pane_id}"}},
+	}
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.argv, " "), func(t *testing.T) {
+			d := f.Decide(tt.argv)
+			if !d.Allow {
+				t.Fatalf("denied: %s", d.Reason)
+			}
+			if !slices.Equal(d.Argv, tt.want) {
+				t.Errorf("argv = %q\nwant %q", d.Argv, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilter_OwnedCommandsDenied(t *testing.T) {
+	f, _ := newTestFilter(allCaps...)
+
+	tests := map[string][]string{
+		"send to host pane":      {"send-keys", "-t", "%1", "ls", "Enter"},
+		"send without target":    {"send-keys", "ls"},
+		"send to key table":      {"send-keys", "-K", "-t", "%7", "C-b"},
+		"send copy-mode command": {"send-keys", "-X", "-t", "%7", "cancel"},
+		"send no keys":           {"send-keys", "-t", "%7"},
+		"kill foreign":           {"kill-pane", "-t", "%3"},
+		"kill all others":        {"kill-pane", "-a", "-t", "%7"},
+		"kill extra args":        {"kill-pane", "-t", "%7", "x"},
+		"capture foreign":        {"capture-pane", "-p", "-t", "%3"},
+		"capture into buffer":    {"capture-pane", "-b", "x", "-t", "%7"},
+		"capture bad line":       {"capture-pane", "-S", "#(id)", "-t", "%7"},
+		"list with format":       {"list-panes", "-F", "#{S:#{session_name}}"},
+		"list all":               {"list-panes", "-a"},
+		"unsupported command":    {"run-shell", "id"},
+		"kill server":            {"kill-server"},
+		"empty":                  {},
+	}
+	for name, argv := range tests {
+		t.Run(name, func(t *testing.T) {
+			if d := f.Decide(argv); d.Allow {
+				t.Errorf("expected deny, got allow: %q", d.Argv)
+			}
+		})
+	}
+}
+
+func TestFilter_OwnedCommandsRequireCapability(t *testing.T) {
+	f, _ := newTestFilter()
+	for _, argv := range [][]string{
+		{"send-keys", "-t", "%7", "q"},
+		{"kill-pane", "-t", "%7"},
+		{"select-pane", "-t", "%7"},
+		{"capture-pane", "-t", "%7"},
+		{"list-panes"},
+	} {
+		if d := f.Decide(argv); d.Allow {
+			t.Errorf("%q allowed without its capability", argv)
+		}
+	}
+}
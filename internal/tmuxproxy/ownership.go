@@ -0,0 +1,37 @@
+package tmuxproxy
+
+import (
+	"fmt"
+	"strings"
+
+	"devsandbox/internal/cmdpattern"
+)
+
+// OwnedSet is a concurrent-safe set of pane IDs (`%N`) the sandbox opened.
+type OwnedSet = cmdpattern.OwnedSet[string]
+
+func NewOwnedSet() *OwnedSet { return cmdpattern.NewOwnedSet[string]() }
+
+// ExtractPaneID parses the output of a launch run with `-P -F '#{pane_id}'`
+// and returns the new pane's ID.
+func ExtractPaneID(output string) (string, error) {
+	id := strings.TrimSpace(output)
+	if !paneIDPattern.MatchString(id) {
+		return "", fmt.Errorf("launch output is not a pane id: %q", id)
+	}
+	return id, nil
+}
+
+// FilterPaneList keeps the lines of a `list-panes -F '#{pane_id}'` output that
+// name owned panes. A line in any other shape is dropped, not passed through.
+func FilterPaneList(output string, owned *OwnedSet) string {
+	var b strings.Builder
+	for line := range strings.Lines(output) {
+		id := strings.TrimSpace(line)
+		if paneIDPattern.MatchString(id) && owned.Contains(id) {
+			b.WriteString(id)
+			b.WriteByte('\n')
+		}
+	}
+	return b.String()
+}
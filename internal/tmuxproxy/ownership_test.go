@@ -0,0 +1,26 @@
+package tmuxproxy
+
+import "testing"
+
+func TestExtractPaneID(t *testing.T) {
+	id, err := ExtractPaneID("%12\n")
+	if err != nil || id != "%12" {
+		t.Errorf("ExtractPaneID = %q, %v", id, err)
+	}
+	for _, bad := range []string{"", "12", "%1\n%2\n", "no server running"} {
+		if _, err := ExtractPaneID(bad); err == nil {
+			t.Errorf("ExtractPaneID(%q) should fail", bad)
+		}
+	}
+}
+
+func TestFilterPaneList(t *testing.T) {
+	owned := NewOwnedSet()
+	owned.Add("%2")
+	owned.Add("%5")
+
+	got := FilterPaneList("%0\n%2\n%3\n%5\nmain: 1 windows\n%5 extra\n", owned)
+	if want := "%2\n%5\n"; got != want {
+		t.Errorf("FilterPaneList = %q, want %q", got, want)
+	}
+}
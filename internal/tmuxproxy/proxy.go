@@ -0,0 +1,170 @@
+package tmuxproxy
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"os/exec"
+	"strings"
+	"time"
+
+	"devsandbox/internal/socketproxy"
+)
+
+// Logger matches dockerproxy.Logger and the existing tools.ErrorLogger.
+type Logger = socketproxy.Logger
+
+// Proxy accepts tmux commands on a socket the sandbox can reach and runs the
+// approved ones against the host tmux server.
+type Proxy struct {
+	tmuxBin    string
+	hostSocket string
+
+	filter *Filter
+	owned  *OwnedSet
+
+	server *socketproxy.Server
+	logger Logger
+}
+
+// New creates a proxy that listens at listenPath and runs approved commands
+// with tmuxBin against the server at hostSocket. The filter and owned set must
+// be non-nil and shared with the caller (the caller may inspect owned for
+// tests).
+func New(tmuxBin, hostSocket, listenPath string, filter *Filter, owned *OwnedSet) *Proxy {
+	p := &Proxy{
+		tmuxBin:    tmuxBin,
+		hostSocket: hostSocket,
+		filter:     filter,
+		owned:      owned,
+	}
+	p.server = socketproxy.NewServer(listenPath, 0o600, "tmux-proxy", p.handle)
+	return p
+}
+
+// SetLogger sets the logger used for allow/deny records and errors.
+func (p *Proxy) SetLogger(l Logger) {
+	p.logger = l
+	p.server.SetLogger(l)
+}
+
+// Start begins listening. Returns an error if the socket cannot be created.
+func (p *Proxy) Start(ctx context.Context) error { return p.server.Start(ctx) }
+
+// Stop gracefully shuts down the proxy. Panes the sandbox opened are left
+// running: they belong to the user's tmux session now.
+func (p *Proxy) Stop() error { return p.server.Stop() }
+
+func (p *Proxy) logErr(format string, args ...any) {
+	if p.logger != nil {
+		p.logger.LogErrorf("tmux-proxy", format, args...)
+	}
+}
+func (p *Proxy) logInf(format string, args ...any) {
+	if p.logger != nil {
+		p.logger.LogInfof("tmux-proxy", format, args...)
+	}
+}
+
+// ioTimeout bounds the client read and every tmux command the proxy runs.
+// None of the allowed commands waits on anything but the server.
+const ioTimeout = 30 * time.Second
+
+// maxOutputBytes caps the output of a single command; a capture-pane of the
+// whole history is the only one that gets large.
+const maxOutputBytes = 4 << 20 // 4 MiB
+
+func (p *Proxy) handle(ctx context.Context, conn net.Conn) {
+	if err := conn.SetReadDeadline(time.Now().Add(ioTimeout)); err != nil {
+		p.logErr("set client read deadline: %v", err)
+		return
+	}
+	req, err := ReadRequest(bufio.NewReader(conn))
+	if err != nil {
+		if !errors.Is(err, net.ErrClosed) {
+			p.logErr("read request: %v", err)
+		}
+		_ = WriteMessage(conn, denyResponse(err.Error()))
+		return
+	}
+
+	d := p.filter.Decide(req.Argv)
+	// %q on the command name: it comes off the socket, so a denial for an
+	// unsupported command would otherwise write the sandbox's own newlines and
+	// escape sequences into the log file and on to remote receivers.
+	if !d.Allow {
+		p.logInf("deny cmd=%q reason=%s", d.Cmd, d.Reason)
+		_ = WriteMessage(conn, denyResponse(d.Reason))
+		return
+	}
+	p.logInf("allow cmd=%q reason=%s", d.Cmd, d.Reason)
+
+	if err := WriteMessage(conn, p.run(ctx, d)); err != nil {
+		p.logErr("write client: %v", err)
+	}
+}
+
+// run executes an approved command and applies the response-side actions:
+// recording the pane a launch opened and filtering list-panes to owned panes.
+func (p *Proxy) run(ctx context.Context, d Decision) Response {
+	ctx, cancel := context.WithTimeout(ctx, ioTimeout)
+	defer cancel()
+
+	var stdout, stderr limitedBuffer
+	cmd := exec.CommandContext(ctx, p.tmuxBin, append([]string{"-S", p.hostSocket}, d.Argv...)...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		p.logErr("%s: %q", d.Cmd, msg)
+		return Response{Error: msg}
+	}
+	if stdout.overflow {
+		return denyResponse("output exceeds the proxy's limit")
+	}
+
+	output := stdout.String()
+	switch d.Cmd {
+	case "split-window", "new-window":
+		id, err := ExtractPaneID(output)
+		if err != nil {
+			// Not fatal - the pane exists either way - but it never enters
+			// OwnedSet, so every later owned command on it is denied.
+			p.logErr("track launched pane: %v", err)
+			break
+		}
+		p.owned.Add(id)
+		p.logInf("track owned pane=%s", id)
+	case "list-panes":
+		output = FilterPaneList(output, p.owned)
+	}
+	return Response{OK: true, Output: output}
+}
+
+func denyResponse(reason string) Response {
+	return Response{Error: "tmux-proxy: " + reason}
+}
+
+// limitedBuffer is a bytes.Buffer that stops growing at maxOutputBytes and
+// remembers that it did. Writes never fail, so tmux is not killed by a
+// broken pipe halfway through a command.
+type limitedBuffer struct {
+	bytes.Buffer
+	overflow bool
+}
+
+func (b *limitedBuffer) Write(data []byte) (int, error) {
+	if room := maxOutputBytes - b.Len(); len(data) > room {
+		b.overflow = true
+		if room > 0 {
+			b.Buffer.Write(data[:room])
+		}
+		return len(data), nil
+	}
+	return b.Buffer.Write(data)
+}
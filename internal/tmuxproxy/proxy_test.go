@@ -0,0 +1,128 @@
+package tmuxproxy
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"devsandbox/internal/cmdpattern"
+)
+
+// shortSocketDir returns a tempdir whose path is short enough for a UNIX
+// domain socket beneath it to fit within macOS's 104-byte sun_path limit.
+func shortSocketDir(t *testing.T) string {
+	t.Helper()
+	dir, err := os.MkdirTemp("/tmp", "ds")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	return dir
+}
+
+// startTmuxServer starts a private tmux server with one session and returns
+// its socket and the binary.
+func startTmuxServer(t *testing.T, dir string) (bin, sock string) {
+	t.Helper()
+	bin, err := exec.LookPath("tmux")
+	if err != nil {
+		t.Skip("tmux not installed")
+	}
+	sock = filepath.Join(dir, "host.sock")
+	if out, err := exec.Command(bin, "-S", sock, "-f", "/dev/null", "new-session", "-d", "-s", "t", "sleep 600").CombinedOutput(); err != nil {
+		t.Skipf("cannot start tmux server: %v: %s", err, out)
+	}
+	t.Cleanup(func() { _ = exec.Command(bin, "-S", sock, "kill-server").Run() })
+	return bin, sock
+}
+
+func TestProxy_LaunchTrackAndScope(t *testing.T) {
+	dir := shortSocketDir(t)
+	bin, hostSock := startTmuxServer(t, dir)
+
+	sleepBin, err := cmdpattern.ResolveProgram("sleep")
+	if err != nil {
+		t.Skip("sleep not found")
+	}
+	owned := NewOwnedSet()
+	filter := NewFilter(FilterConfig{
+		Capabilities:   []Capability{CapSplitWindow, CapListOwned, CapKillPaneOwned, CapSendKeysOwned},
+		LaunchPatterns: []cmdpattern.CommandPattern{{Program: "sleep", ResolvedBin: sleepBin, ArgsMatcher: cmdpattern.MatchAny()}},
+		Owned:          owned,
+		HostPaneID:     "%0",
+	})
+	listen := filepath.Join(dir, "proxy.sock")
+	p := New(bin, hostSock, listen, filter, owned)
+	if err := p.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = p.Stop() })
+
+	call := func(argv ...string) Response {
+		t.Helper()
+		resp, err := Call(listen, argv)
+		if err != nil {
+			t.Fatalf("%q: %v", argv, err)
+		}
+		return resp
+	}
+
+	resp := call("split-window", "-d", "sleep", "600")
+	if !resp.OK {
+		t.Fatalf("split-window failed: %s", resp.Error)
+	}
+	pane := strings.TrimSpace(resp.Output)
+	if !owned.Contains(pane) {
+		t.Fatalf("launched pane %q not tracked", pane)
+	}
+
+	if resp := call("list-panes"); !resp.OK || resp.Output != pane+"\n" {
+		t.Errorf("list-panes = %+v, want only %s", resp, pane)
+	}
+	if resp := call("send-keys", "-t", "%0", "echo", "Enter"); resp.OK || !strings.Contains(resp.Error, "not owned") {
+		t.Errorf("send-keys to host pane = %+v, want a denial", resp)
+	}
+	if resp := call("split-window", "sh", "-c", "id"); resp.OK {
+		t.Error("launch of a command outside the allowlist succeeded")
+	}
+
+	if resp := call("kill-pane", "-t", pane); !resp.OK {
+		t.Fatalf("kill-pane failed: %s", resp.Error)
+	}
+	if resp := call("list-panes"); !resp.OK || resp.Output != "" {
+		t.Errorf("list-panes after kill = %+v, want empty", resp)
+	}
+
+	// The host pane is untouched.
+	out, err := exec.Command(bin, "-S", hostSock, "list-panes", "-a", "-F", "#{pane_id}").Output()
+	if err != nil || strings.TrimSpace(string(out)) != "%0" {
+		t.Errorf("host panes = %q, %v", out, err)
+	}
+}
+
+func TestProxy_TmuxErrorIsReported(t *testing.T) {
+	dir := shortSocketDir(t)
+	bin, hostSock := startTmuxServer(t, dir)
+
+	owned := NewOwnedSet()
+	// Owned, but never opened: tmux itself rejects the target.
+	owned.Add("%99")
+	filter := NewFilter(FilterConfig{Capabilities: []Capability{CapKillPaneOwned}, Owned: owned})
+	listen := filepath.Join(dir, "proxy.sock")
+	p := New(bin, hostSock, listen, filter, owned)
+	if err := p.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = p.Stop() })
+
+	resp, err := Call(listen, []string{"kill-pane", "-t", "%99"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.OK || resp.Error == "" {
+		t.Errorf("response = %+v, want tmux's error", resp)
+	}
+}
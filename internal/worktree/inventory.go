@@ -0,0 +1,271 @@
+package worktree
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"devsandbox/internal/fsutil"
+)
+
+// baseConfigKey is the per-branch git config variable holding the ref a
+// devsandbox-created branch was cut from.
+const baseConfigKey = "devsandboxBase"
+
+// Worktree is a worktree devsandbox manages, as git records it.
+type Worktree struct {
+	Path     string // absolute host path of the working tree
+	Branch   string // checked-out branch; empty when HEAD is detached
+	Head     string // commit checked out
+	Base     string // ref the branch was created from, when devsandbox created it
+	Prunable bool   // the directory is gone and only git's entry remains
+}
+
+// Status is the state of a worktree's branch against its base.
+type Status struct {
+	Dirty bool // uncommitted changes, untracked files included
+	// Compared is true when Base resolved, and Ahead, Behind and Merged are
+	// meaningful.
+	Compared bool
+	Ahead    int // commits on the branch that are not in the base
+	Behind   int // commits in the base that are not on the branch
+	// Merged means every commit of the branch is reachable from the base. A
+	// branch without commits of its own counts; a squash merge does not.
+	Merged     bool
+	LastCommit time.Time // committer date of the checked-out commit
+	// Unverified is set when the worktree's .git is not the file git wrote,
+	// pointing back at the worktree's git directory: the sandbox replaced it.
+	// Its changes are then not read, and it counts as dirty.
+	Unverified bool
+}
+
+// List returns the worktrees of repoRoot that live under sandboxRoot's
+// worktrees directory - the ones Ensure creates - in git's order. Worktrees
+// added by hand elsewhere are not devsandbox's to manage and are left out.
+func (m *Manager) List(ctx context.Context, repoRoot, sandboxRoot string) ([]Worktree, error) {
+	root, err := normalizePath(sandboxRoot)
+	if err != nil {
+		return nil, fmt.Errorf("worktree: normalize sandbox root: %w", err)
+	}
+	dir := filepath.Join(root, "worktrees") + string(filepath.Separator)
+
+	all, err := m.listWorktrees(ctx, repoRoot)
+	if err != nil {
+		return nil, err
+	}
+	var out []Worktree
+	for _, e := range all {
+		if !strings.HasPrefix(e.Path, dir) {
+			continue
+		}
+		wt := Worktree{Path: e.Path, Branch: e.Branch, Head: e.Head, Prunable: e.Prunable}
+		if e.Branch != "" {
+			wt.Base, err = m.base(ctx, repoRoot, e.Branch)
+			if err != nil {
+				return nil, err
+			}
+		}
+		out = append(out, wt)
+	}
+	return out, nil
+}
+
+// Status inspects wt. Dirtiness is read without taking git's optional locks,
+// so it neither refreshes the worktree's index nor races a running sandbox.
+// A worktree whose directory is gone reports clean.
+//
+// The working tree is writable from the sandbox, so git is not pointed at it
+// the usual way: a .git directory planted in place of the .git file would
+// bring its own config, and core.fsmonitor there is a command git runs. Git
+// is handed the worktree's git directory, which lives in the main repository,
+// and no fsmonitor, hooks or submodules.
+func (m *Manager) Status(ctx context.Context, repoRoot string, wt Worktree) (Status, error) {
+	var st Status
+	if !wt.Prunable {
+		gitDir, err := worktreeGitDir(ctx, repoRoot, wt.Path)
+		if err != nil {
+			return st, err
+		}
+		if gitDir == "" {
+			st.Dirty, st.Unverified = true, true
+		} else {
+			out, err := gitOutputEnv(ctx, []string{"GIT_DIR=" + gitDir, "GIT_WORK_TREE=" + wt.Path},
+				"-c", "core.fsmonitor=false", "-c", "core.hooksPath=/dev/null",
+				"--no-optional-locks", "-C", wt.Path, "status", "--porcelain", "--ignore-submodules=all")
+			if err != nil {
+				return st, err
+			}
+			st.Dirty = strings.TrimSpace(out) != ""
+		}
+	}
+
+	tip := wt.Head
+	if wt.Branch != "" {
+		tip = "refs/heads/" + wt.Branch
+	}
+	if tip == "" {
+		return st, nil
+	}
+	out, err := gitOutput(ctx, "-C", repoRoot, "log", "-1", "--format=%ct", tip)
+	if err != nil {
+		return st, err
+	}
+	if secs, err := strconv.ParseInt(strings.TrimSpace(out), 10, 64); err == nil {
+		st.LastCommit = time.Unix(secs, 0)
+	}
+
+	if wt.Base == "" {
+		return st, nil
+	}
+	// A base that has since been deleted leaves the branch uncompared rather
+	// than failing the whole listing.
+	out, err = gitOutput(ctx, "-C", repoRoot, "rev-list", "--left-right", "--count", wt.Base+"..."+tip, "--")
+	if err != nil {
+		return st, nil
+	}
+	fields := strings.Fields(out)
+	if len(fields) != 2 {
+		return st, fmt.Errorf("worktree: unexpected rev-list output %q", strings.TrimSpace(out))
+	}
+	behind, errB := strconv.Atoi(fields[0])
+	ahead, errA := strconv.Atoi(fields[1])
+	if errB != nil || errA != nil {
+		return st, fmt.Errorf("worktree: unexpected rev-list output %q", strings.TrimSpace(out))
+	}
+	st.Compared, st.Ahead, st.Behind, st.Merged = true, ahead, behind, ahead == 0
+	return st, nil
+}
+
+// worktreeGitDir returns the git directory the main repository keeps for the
+// worktree at path (<common dir>/worktrees/<name>), found by the gitdir file
+// there that names path's .git - not by path's own .git, which the sandbox can
+// rewrite. It returns "" when path's .git is not a regular file pointing back
+// at that directory.
+func worktreeGitDir(ctx context.Context, repoRoot, path string) (string, error) {
+	out, err := gitOutput(ctx, "-C", repoRoot, "rev-parse", "--git-common-dir")
+	if err != nil {
+		return "", err
+	}
+	commonDir := strings.TrimRight(out, "\n")
+	if !filepath.IsAbs(commonDir) {
+		commonDir = filepath.Join(repoRoot, commonDir)
+	}
+	adminRoot := filepath.Join(commonDir, "worktrees")
+	entries, err := os.ReadDir(adminRoot)
+	if err != nil {
+		return "", fmt.Errorf("worktree: read %s: %w", adminRoot, err)
+	}
+
+	dotGit := filepath.Join(path, ".git")
+	gitDir := ""
+	for _, e := range entries {
+		admin := filepath.Join(adminRoot, e.Name())
+		data, err := os.ReadFile(filepath.Join(admin, "gitdir"))
+		if err != nil {
+			continue
+		}
+		if samePath(resolveGitPath(admin, string(data)), dotGit) {
+			gitDir = admin
+			break
+		}
+	}
+	if gitDir == "" {
+		return "", fmt.Errorf("worktree: no git directory in %s belongs to %s", adminRoot, path)
+	}
+
+	data, err := fsutil.ReadRegularFile(dotGit)
+	if err != nil {
+		return "", nil
+	}
+	target, ok := strings.CutPrefix(strings.TrimSpace(string(data)), "gitdir:")
+	if !ok || !samePath(resolveGitPath(path, target), gitDir) {
+		return "", nil
+	}
+	return gitDir, nil
+}
+
+// resolveGitPath resolves a path read from a gitdir file or a .git file,
+// which git writes relative to the directory holding it when
+// worktree.useRelativePaths is set.
+func resolveGitPath(dir, p string) string {
+	p = strings.TrimSpace(p)
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(dir, p)
+	}
+	return filepath.Clean(p)
+}
+
+// samePath reports whether a and b name the same location once symlinks in
+// the parts that exist are resolved.
+func samePath(a, b string) bool {
+	if a == b {
+		return true
+	}
+	ra, errA := normalizePath(a)
+	rb, errB := normalizePath(b)
+	return errA == nil && errB == nil && ra == rb
+}
+
+// recordBase stores the ref branch was created from in the repo's git config,
+// where it lives and dies with the branch. base is resolved to a branch name
+// when it names one (so HEAD becomes the branch checked out in the main tree,
+// and ahead/behind later track that branch as it moves), and to a commit
+// otherwise.
+func (m *Manager) recordBase(ctx context.Context, repoRoot, branch, base string) error {
+	if base == "" {
+		base = "HEAD"
+	}
+	ref, err := gitOutput(ctx, "-C", repoRoot, "rev-parse", "--abbrev-ref", "--verify", base)
+	ref = strings.TrimSpace(ref)
+	if err != nil || ref == "" || ref == "HEAD" {
+		ref, err = gitOutput(ctx, "-C", repoRoot, "rev-parse", "--verify", base+"^{commit}")
+		if err != nil {
+			return err
+		}
+		ref = strings.TrimSpace(ref)
+	}
+	_, err = gitOutput(ctx, "-C", repoRoot, "config", "branch."+branch+"."+baseConfigKey, ref)
+	return err
+}
+
+// base returns the ref recorded by recordBase, or "" when there is none.
+func (m *Manager) base(ctx context.Context, repoRoot, branch string) (string, error) {
+	out, err := gitOutput(ctx, "-C", repoRoot, "config", "--get", "branch."+branch+"."+baseConfigKey)
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// gitOutput runs git with argv and returns its stdout. A failure carries
+// git's stderr and wraps the *exec.ExitError.
+func gitOutput(ctx context.Context, argv ...string) (string, error) {
+	return gitOutputEnv(ctx, nil, argv...)
+}
+
+// gitOutputEnv is gitOutput with env added to git's environment.
+func gitOutputEnv(ctx context.Context, env []string, argv ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", argv...)
+	if env != nil {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return "", fmt.Errorf("worktree: git %s: %w\n%s", strings.Join(argv, " "), err, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", fmt.Errorf("worktree: git %s: %w", strings.Join(argv, " "), err)
+	}
+	return string(out), nil
+}
@@ -0,0 +1,191 @@
+package worktree
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestManagerListRecordsBase(t *testing.T) {
+	requireGit(t)
+	repo := makeRepo(t)
+	sbox := t.TempDir()
+	m := NewManager()
+	ctx := context.Background()
+
+	// A worktree outside the sandbox root is not devsandbox's to list.
+	run(t, repo, "git", "worktree", "add", "-q", "-b", "manual", filepath.Join(t.TempDir(), "manual"))
+
+	h, err := m.Ensure(ctx, EnsureRequest{RepoRoot: repo, SandboxRoot: sbox, Branch: "feat/x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Ensure(ctx, EnsureRequest{RepoRoot: repo, SandboxRoot: sbox, Branch: "from-manual", Base: "manual"}); err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := m.List(ctx, repo, sbox)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(list) != 2 {
+		t.Fatalf("List returned %d worktrees, want 2: %+v", len(list), list)
+	}
+	bases := map[string]string{}
+	for _, wt := range list {
+		bases[wt.Branch] = wt.Base
+		if wt.Branch == "feat/x" && wt.Path != h.Path {
+			t.Errorf("Path = %q, want %q", wt.Path, h.Path)
+		}
+	}
+	// HEAD resolves to the branch the main tree had checked out.
+	if bases["feat/x"] != "main" {
+		t.Errorf("feat/x base = %q, want main", bases["feat/x"])
+	}
+	if bases["from-manual"] != "manual" {
+		t.Errorf("from-manual base = %q, want manual", bases["from-manual"])
+	}
+}
+
+func TestManagerStatus(t *testing.T) {
+	requireGit(t)
+	repo := makeRepo(t)
+	sbox := t.TempDir()
+	m := NewManager()
+	ctx := context.Background()
+
+	h, err := m.Ensure(ctx, EnsureRequest{RepoRoot: repo, SandboxRoot: sbox, Branch: "work"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	status := func() Status {
+		t.Helper()
+		list, err := m.List(ctx, repo, sbox)
+		if err != nil || len(list) != 1 {
+			t.Fatalf("List: %v %+v", err, list)
+		}
+		st, err := m.Status(ctx, repo, list[0])
+		if err != nil {
+			t.Fatalf("Status: %v", err)
+		}
+		return st
+	}
+
+	st := status()
+	if st.Dirty || !st.Compared || st.Ahead != 0 || st.Behind != 0 || !st.Merged || st.LastCommit.IsZero() {
+		t.Errorf("fresh worktree: %+v", st)
+	}
+
+	if err := os.WriteFile(filepath.Join(h.Path, "new"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if st := status(); !st.Dirty {
+		t.Errorf("untracked file: Dirty = false")
+	}
+	run(t, h.Path, "git", "add", "new")
+	run(t, h.Path, "git", "commit", "-q", "-m", "work")
+	if err := os.WriteFile(filepath.Join(repo, "main-only"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	run(t, repo, "git", "add", "main-only")
+	run(t, repo, "git", "commit", "-q", "-m", "main")
+
+	st = status()
+	if st.Dirty || st.Ahead != 1 || st.Behind != 1 || st.Merged {
+		t.Errorf("diverged worktree: %+v", st)
+	}
+
+	run(t, repo, "git", "merge", "-q", "--no-edit", "work")
+	if st := status(); !st.Merged || st.Ahead != 0 {
+		t.Errorf("merged worktree: %+v", st)
+	}
+}
+
+func TestManagerStatusMissingDir(t *testing.T) {
+	requireGit(t)
+	repo := makeRepo(t)
+	sbox := t.TempDir()
+	m := NewManager()
+	ctx := context.Background()
+
+	h, err := m.Ensure(ctx, EnsureRequest{RepoRoot: repo, SandboxRoot: sbox, Branch: "gone"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.RemoveAll(h.Path); err != nil {
+		t.Fatal(err)
+	}
+	list, err := m.List(ctx, repo, sbox)
+	if err != nil || len(list) != 1 {
+		t.Fatalf("List: %v %+v", err, list)
+	}
+	if !list[0].Prunable {
+		t.Errorf("Prunable = false for a removed directory")
+	}
+	if _, err := m.Status(ctx, repo, list[0]); err != nil {
+		t.Errorf("Status: %v", err)
+	}
+	if err := m.Remove(ctx, repo, h.Path); err != nil {
+		t.Errorf("Remove: %v", err)
+	}
+	if list, _ := m.List(ctx, repo, sbox); len(list) != 0 {
+		t.Errorf("entry survived Remove: %+v", list)
+	}
+}
+
+func TestManagerStatusPlantedGitDir(t *testing.T) {
+	requireGit(t)
+	repo := makeRepo(t)
+	sbox := t.TempDir()
+	m := NewManager()
+	ctx := context.Background()
+
+	h, err := m.Ensure(ctx, EnsureRequest{RepoRoot: repo, SandboxRoot: sbox, Branch: "work"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	list, err := m.List(ctx, repo, sbox)
+	if err != nil || len(list) != 1 {
+		t.Fatalf("List: %v %+v", err, list)
+	}
+
+	// Swap the .git file for a repository of the sandbox's own, whose
+	// fsmonitor and hooks would run on the host if git read its config.
+	marker := filepath.Join(t.TempDir(), "ran")
+	dotGit := filepath.Join(h.Path, ".git")
+	if err := os.Remove(dotGit); err != nil {
+		t.Fatal(err)
+	}
+	run(t, h.Path, "git", "init", "-q")
+	hook := "#!/bin/sh\ntouch " + marker + "\n"
+	if err := os.WriteFile(filepath.Join(dotGit, "hooks", "fsmonitor"), []byte(hook), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	run(t, h.Path, "git", "config", "core.fsmonitor", filepath.Join(dotGit, "hooks", "fsmonitor"))
+	run(t, h.Path, "git", "config", "core.hooksPath", filepath.Join(dotGit, "hooks"))
+
+	st, err := m.Status(ctx, repo, list[0])
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	if !st.Unverified || !st.Dirty {
+		t.Errorf("planted .git directory: %+v, want Unverified and Dirty", st)
+	}
+	if _, err := os.Stat(marker); err == nil {
+		t.Error("Status ran the planted repository's fsmonitor")
+	}
+
+	// A .git file redirected to another repository is caught too.
+	if err := os.RemoveAll(dotGit); err != nil {
+		t.Fatal(err)
+	}
+	other := filepath.Join(t.TempDir(), "other.git")
+	run(t, h.Path, "git", "init", "-q", "--bare", other)
+	if err := os.WriteFile(dotGit, []byte("gitdir: "+other+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if st, err := m.Status(ctx, repo, list[0]); err != nil || !st.Unverified {
+		t.Errorf("redirected .git file: %+v, %v", st, err)
+	}
+}
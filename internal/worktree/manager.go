@@ -90,6 +90,11 @@ func (m *Manager) Ensure(ctx context.Context, req EnsureRequest) (*Handle, error
 	if out, err := cmd.CombinedOutput(); err != nil {
 		return nil, fmt.Errorf("worktree: git %s failed: %w\n%s", strings.Join(argv, " "), err, strings.TrimSpace(string(out)))
 	}
+	if !branchExists {
+		// Best-effort: a branch without a recorded base still works, it just
+		// has no ahead/behind or merged status in `devsandbox worktree list`.
+		_ = m.recordBase(ctx, req.RepoRoot, req.Branch, req.Base)
+	}
 
 	return &Handle{Path: path, Branch: req.Branch, Created: true}, nil
 }
@@ -115,8 +120,10 @@ func (m *Manager) Remove(ctx context.Context, repoRoot, path string) error {
 
 // existingWorktree describes an entry returned by `git worktree list --porcelain`.
 type existingWorktree struct {
-	Path   string
-	Branch string
+	Path     string
+	Branch   string // empty when the worktree has a detached HEAD
+	Head     string
+	Prunable bool // git has the entry but its directory is gone
 }
 
 func (m *Manager) findWorktree(ctx context.Context, repoRoot, path string) (*existingWorktree, error) {
@@ -127,11 +134,27 @@ func (m *Manager) findWorktree(ctx context.Context, repoRoot, path string) (*exi
 		return nil, fmt.Errorf("worktree: normalize path %s: %w", path, err)
 	}
 
+	all, err := m.listWorktrees(ctx, repoRoot)
+	if err != nil {
+		return nil, err
+	}
+	for i := range all {
+		if all[i].Path == normPath {
+			return &all[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// listWorktrees parses `git worktree list --porcelain`, main working tree
+// included, with each path symlink-resolved where it still exists.
+func (m *Manager) listWorktrees(ctx context.Context, repoRoot string) ([]existingWorktree, error) {
 	cmd := exec.CommandContext(ctx, "git", "-C", repoRoot, "worktree", "list", "--porcelain")
 	out, err := cmd.Output()
 	if err != nil {
 		return nil, fmt.Errorf("worktree: git worktree list: %w", err)
 	}
+	var all []existingWorktree
 	for block := range strings.SplitSeq(string(out), "\n\n") {
 		var cur existingWorktree
 		for line := range strings.SplitSeq(block, "\n") {
@@ -144,16 +167,20 @@ func (m *Manager) findWorktree(ctx context.Context, repoRoot, path string) (*exi
 				} else {
 					cur.Path = raw
 				}
+			case strings.HasPrefix(line, "HEAD "):
+				cur.Head = strings.TrimPrefix(line, "HEAD ")
 			case strings.HasPrefix(line, "branch "):
 				ref := strings.TrimPrefix(line, "branch ")
 				cur.Branch = strings.TrimPrefix(ref, "refs/heads/")
+			case line == "prunable" || strings.HasPrefix(line, "prunable "):
+				cur.Prunable = true
 			}
 		}
-		if cur.Path == normPath {
-			return &cur, nil
+		if cur.Path != "" {
+			all = append(all, cur)
 		}
 	}
-	return nil, nil
+	return all, nil
 }
 
 func (m *Manager) branchExists(ctx context.Context, repoRoot, branch string) (bool, error) {
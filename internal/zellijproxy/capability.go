@@ -0,0 +1,41 @@
+// Package zellijproxy provides a filtering proxy in front of the host's zellij
+// session.
+//
+// SECURITY MODEL: zellij's client/server IPC is an internal serialization of
+// its Rust message types with no published schema, and every client may send
+// any of them, so it is not proxied. The sandbox instead sends a zellij
+// command line as an argv over the proxy socket; the filter validates it
+// against an allowlist of capabilities and the proxy runs a rebuilt, canonical
+// argv with the host zellij binary against the session devsandbox was started
+// from. Opening a pane runs the host process named in the argv, always in a
+// floating pane, and must match a CommandPattern allowlist; writing to and
+// closing a pane are scoped to panes the sandbox itself opened (ownership
+// tracking). The zellij socket directory is NOT bind-mounted into the sandbox;
+// only the proxy socket is.
+package zellijproxy
+
+// Capability identifies a single zellij operation the proxy may allow.
+// Mutating capabilities are suffixed `_owned` to make ownership scoping explicit.
+type Capability string
+
+const (
+	CapRunFloating Capability = "run_floating" // run --floating <command>
+
+	CapWriteCharsOwned Capability = "write_chars_owned" // action write-chars, scoped to owned panes
+	CapClosePaneOwned  Capability = "close_pane_owned"  // action close-pane, scoped to owned panes
+)
+
+// IsLaunch reports whether c opens a pane. Launch capabilities imply host code
+// execution and must be paired with a CommandPattern allowlist.
+func IsLaunch(c Capability) bool {
+	return c == CapRunFloating
+}
+
+// IsKnown reports whether c is a capability this proxy implements.
+func IsKnown(c Capability) bool {
+	switch c {
+	case CapRunFloating, CapWriteCharsOwned, CapClosePaneOwned:
+		return true
+	}
+	return false
+}
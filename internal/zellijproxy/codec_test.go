@@ -0,0 +1,37 @@
+package zellijproxy
+
+import (
+	"bufio"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestReadRequest(t *testing.T) {
+	req, err := ReadRequest(bufio.NewReader(strings.NewReader(`{"argv":["action","close-pane","--pane-id","terminal_1"]}` + "\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(req.Argv, []string{"action", "close-pane", "--pane-id", "terminal_1"}) {
+		t.Errorf("argv = %q", req.Argv)
+	}
+}
+
+func TestReadRequest_Rejects(t *testing.T) {
+	tests := map[string]string{
+		"unknown field":    `{"argv":["run","revdiff"],"session":"other"}` + "\n",
+		"case variant":     `{"argv":["run","revdiff"],"ARGV":["kill-session"]}` + "\n",
+		"trailing data":    `{"argv":["run","revdiff"]}{}` + "\n",
+		"not an object":    `["run","revdiff"]` + "\n",
+		"unterminated":     `{"argv":["run","revdiff"]}`,
+		"oversized":        `{"argv":["` + strings.Repeat("a", maxRequestBytes) + `"]}` + "\n",
+		"empty connection": "",
+	}
+	for name, in := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := ReadRequest(bufio.NewReader(strings.NewReader(in))); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
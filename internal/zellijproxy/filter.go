@@ -0,0 +1,317 @@
+package zellijproxy
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"devsandbox/internal/cmdpattern"
+)
+
+// Decision is the outcome of filtering a single zellij command.
+type Decision struct {
+	Allow  bool
+	Reason string // populated for both allow and deny (deny: why; allow: short summary for logs)
+	Cmd    string // canonical command name (best effort)
+
+	// Argv is the command line the proxy runs on allow. It is rebuilt from
+	// the parsed request rather than copied from it, so only options the
+	// filter modelled reach zellij.
+	Argv []string
+	// Program is argv[0] of the command a launch runs.
+	Program string
+}
+
+// FilterConfig configures a Filter.
+type FilterConfig struct {
+	Capabilities   []Capability
+	LaunchPatterns []cmdpattern.CommandPattern
+	Owned          *OwnedSet
+}
+
+// Filter decides whether a single zellij command may run against the host
+// session.
+type Filter struct {
+	caps     map[Capability]struct{}
+	patterns []cmdpattern.CommandPattern
+	owned    *OwnedSet
+}
+
+func NewFilter(cfg FilterConfig) *Filter {
+	caps := make(map[Capability]struct{}, len(cfg.Capabilities))
+	for _, c := range cfg.Capabilities {
+		caps[c] = struct{}{}
+	}
+	owned := cfg.Owned
+	if owned == nil {
+		owned = NewOwnedSet()
+	}
+	return &Filter{
+		caps:     caps,
+		patterns: cfg.LaunchPatterns,
+		owned:    owned,
+	}
+}
+
+func (f *Filter) hasCap(c Capability) bool {
+	_, ok := f.caps[c]
+	return ok
+}
+
+// maxPaneNameLen bounds a pane name; zellij shows it in the pane frame.
+const maxPaneNameLen = 128
+
+// option describes one long option a command accepts, with its short
+// spelling (0 for none). Any option not listed is a denial.
+type option struct {
+	short      byte
+	takesValue bool
+}
+
+type optionSpec map[string]option
+
+// Decide inspects argv and returns an allow/deny decision.
+func (f *Filter) Decide(argv []string) Decision {
+	if len(argv) == 0 {
+		return Decision{Reason: "empty command"}
+	}
+	switch argv[0] {
+	case "run":
+		return f.decideRun(argv[1:])
+	case "action":
+		if len(argv) < 2 {
+			return Decision{Cmd: "action", Reason: "action name required"}
+		}
+		name := "action " + argv[1]
+		switch argv[1] {
+		case "write-chars":
+			return f.decideWriteChars(name, argv[2:])
+		case "close-pane":
+			return f.decideClosePane(name, argv[2:])
+		default:
+			return Decision{Cmd: name, Reason: fmt.Sprintf("action %q not supported by proxy", argv[1])}
+		}
+	default:
+		return Decision{Cmd: argv[0], Reason: fmt.Sprintf("command %q not supported by proxy", argv[0])}
+	}
+}
+
+// decideRun handles run. The pane runs the command on the host, so the
+// command must match a launch pattern. The pane is always floating: a tiled
+// pane would take space from the panes around it, and --in-place would
+// replace the one the user is looking at. --cwd is refused; the command runs
+// in the host's working directory for the session.
+func (f *Filter) decideRun(args []string) Decision {
+	const name = "run"
+	if !f.hasCap(CapRunFloating) {
+		return Decision{Cmd: name, Reason: fmt.Sprintf("capability %s not granted", CapRunFloating)}
+	}
+	spec := optionSpec{
+		"floating":      {short: 'f'},
+		"close-on-exit": {short: 'c'},
+		"name":          {short: 'n', takesValue: true},
+	}
+	opts, order, command, err := parseOptions(args, spec)
+	if err != nil {
+		return Decision{Cmd: name, Reason: err.Error()}
+	}
+	if len(command) == 0 {
+		return Decision{Cmd: name, Reason: "a command is required"}
+	}
+	if paneName, ok := opts["name"]; ok && !validPaneName(paneName) {
+		return Decision{Cmd: name, Reason: fmt.Sprintf("invalid pane name %q", paneName)}
+	}
+
+	command, ok := f.matchPattern(command)
+	if !ok {
+		return Decision{Cmd: name, Program: command[0], Reason: fmt.Sprintf("command %q not in allowlist", command[0])}
+	}
+
+	out := []string{name, "--floating"}
+	for _, opt := range order {
+		switch opt {
+		case "floating":
+		case "name":
+			out = append(out, "--name", opts[opt])
+		default:
+			out = append(out, "--"+opt)
+		}
+	}
+	// zellij execs the argv after `--` directly, with no shell in between,
+	// so the argv the pattern matched is the one that runs.
+	out = append(out, "--")
+	out = append(out, command...)
+	return Decision{Allow: true, Cmd: name, Argv: out, Program: command[0], Reason: fmt.Sprintf("launch %q", command[0])}
+}
+
+// matchPattern returns the command to run when a launch pattern accepts it.
+//
+// A pattern pinned to a resolved binary also accepts its bare Program name,
+// and the returned command then names the pinned path: zellij looks a bare
+// name up on the zellij server's PATH, which need not be the one the pattern
+// was resolved against.
+func (f *Filter) matchPattern(command []string) ([]string, bool) {
+	for _, p := range f.patterns {
+		if p.MatchesArgv(command) {
+			return command, true
+		}
+		if p.ResolvedBin != "" && command[0] == p.Program {
+			pinned := append([]string{p.ResolvedBin}, command[1:]...)
+			if p.MatchesArgv(pinned) {
+				return pinned, true
+			}
+		}
+	}
+	return command, false
+}
+
+// decideWriteChars allows write-chars to an owned pane. Without --pane-id
+// zellij writes to the focused pane, which is whatever the user is looking
+// at, so the option is required.
+func (f *Filter) decideWriteChars(name string, args []string) Decision {
+	if !f.hasCap(CapWriteCharsOwned) {
+		return Decision{Cmd: name, Reason: fmt.Sprintf("capability %s not granted", CapWriteCharsOwned)}
+	}
+	opts, _, rest, err := parseOptions(args, optionSpec{"pane-id": {short: 'p', takesValue: true}})
+	if err != nil {
+		return Decision{Cmd: name, Reason: err.Error()}
+	}
+	target, d := f.ownedTarget(name, opts)
+	if !d.Allow {
+		return d
+	}
+	if len(rest) != 1 {
+		return Decision{Cmd: name, Reason: "exactly one string of characters is required"}
+	}
+	out := []string{"action", "write-chars", "--pane-id", target, "--", rest[0]}
+	return Decision{Allow: true, Cmd: name, Argv: out, Reason: "owned pane " + target}
+}
+
+// decideClosePane allows close-pane of an owned pane. As with write-chars,
+// zellij closes the focused pane when no --pane-id is given.
+func (f *Filter) decideClosePane(name string, args []string) Decision {
+	if !f.hasCap(CapClosePaneOwned) {
+		return Decision{Cmd: name, Reason: fmt.Sprintf("capability %s not granted", CapClosePaneOwned)}
+	}
+	opts, _, rest, err := parseOptions(args, optionSpec{"pane-id": {short: 'p', takesValue: true}})
+	if err != nil {
+		return Decision{Cmd: name, Reason: err.Error()}
+	}
+	if len(rest) > 0 {
+		return Decision{Cmd: name, Reason: "unexpected arguments"}
+	}
+	target, d := f.ownedTarget(name, opts)
+	if !d.Allow {
+		return d
+	}
+	return Decision{Allow: true, Cmd: name, Argv: []string{"action", "close-pane", "--pane-id", target}, Reason: "owned pane " + target}
+}
+
+// ownedTarget returns the canonical form of the required --pane-id when it
+// names an owned pane. The returned Decision is an allow placeholder on
+// success and the denial otherwise.
+func (f *Filter) ownedTarget(name string, opts map[string]string) (string, Decision) {
+	raw, ok := opts["pane-id"]
+	if !ok {
+		return "", Decision{Cmd: name, Reason: "--pane-id with an owned pane ID is required"}
+	}
+	id, ok := CanonicalPaneID(raw)
+	if !ok || !f.owned.Contains(id) {
+		return "", Decision{Cmd: name, Reason: fmt.Sprintf("pane %q not owned", raw)}
+	}
+	return id, Decision{Allow: true}
+}
+
+// validPaneName refuses names zellij would draw with control characters in
+// them, or that are long enough to crowd out the pane frame.
+func validPaneName(s string) bool {
+	if s == "" || len(s) > maxPaneNameLen {
+		return false
+	}
+	return !strings.ContainsFunc(s, unicode.IsControl)
+}
+
+// parseOptions parses args the way zellij's command-line parser does: long
+// options as --name value or --name=value, short ones as -n value or -nvalue,
+// short flags clustered (-fc), and `--` or the first non-option word ending
+// them. It returns the option values keyed by long name, the order they
+// appeared in, and the remaining words. An option not in spec, or given
+// twice, is an error.
+func parseOptions(args []string, spec optionSpec) (map[string]string, []string, []string, error) {
+	shorts := make(map[byte]string, len(spec))
+	for long, o := range spec {
+		if o.short != 0 {
+			shorts[o.short] = long
+		}
+	}
+
+	opts := make(map[string]string)
+	var order []string
+	set := func(long, value string) error {
+		if _, dup := opts[long]; dup {
+			return fmt.Errorf("option --%s given twice", long)
+		}
+		opts[long] = value
+		order = append(order, long)
+		return nil
+	}
+
+	i := 0
+	for ; i < len(args); i++ {
+		a := args[i]
+		if a == "--" {
+			i++
+			break
+		}
+		if long, ok := strings.CutPrefix(a, "--"); ok {
+			long, value, hasValue := strings.Cut(long, "=")
+			o, known := spec[long]
+			switch {
+			case !known:
+				return nil, nil, nil, fmt.Errorf("option --%s is not allowed through the proxy", long)
+			case o.takesValue && !hasValue:
+				if i+1 >= len(args) {
+					return nil, nil, nil, fmt.Errorf("option --%s requires a value", long)
+				}
+				i++
+				value = args[i]
+			case !o.takesValue && hasValue:
+				return nil, nil, nil, fmt.Errorf("option --%s takes no value", long)
+			}
+			if err := set(long, value); err != nil {
+				return nil, nil, nil, err
+			}
+			continue
+		}
+		if len(a) < 2 || a[0] != '-' {
+			break
+		}
+		for j := 1; j < len(a); j++ {
+			long, known := shorts[a[j]]
+			if !known {
+				return nil, nil, nil, fmt.Errorf("option -%c is not allowed through the proxy", a[j])
+			}
+			if !spec[long].takesValue {
+				if err := set(long, ""); err != nil {
+					return nil, nil, nil, err
+				}
+				continue
+			}
+			var value string
+			switch {
+			case j+1 < len(a):
+				value = strings.TrimPrefix(a[j+1:], "=")
+			case i+1 < len(args):
+				i++
+				value = args[i]
+			default:
+				return nil, nil, nil, fmt.Errorf("option -%c requires a value", a[j])
+			}
+			if err := set(long, value); err != nil {
+				return nil, nil, nil, err
+			}
+			break
+		}
+	}
+	return opts, order, args[i:], nil
+}
@@ -0,0 +1,130 @@
+package zellijproxy
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"devsandbox/internal/cmdpattern"
+)
+
+var allCaps = []Capability{CapRunFloating, CapWriteCharsOwned, CapClosePaneOwned}
+
+func newTestFilter(caps ...Capability) (*Filter, *OwnedSet) {
+	owned := NewOwnedSet()
+	owned.Add("terminal_7")
+	f := NewFilter(FilterConfig{
+		Capabilities: caps,
+		LaunchPatterns: []cmdpattern.CommandPattern{
+			{Program: "revdiff", ResolvedBin: "/usr/local/bin/revdiff", ArgsMatcher: cmdpattern.MatchAny()},
+			{Program: "watchexec", ArgsMatcher: cmdpattern.MatchPrefix("-e", "go")},
+		},
+		Owned: owned,
+	})
+	return f, owned
+}
+
+func TestFilter_Allowed(t *testing.T) {
+	f, _ := newTestFilter(allCaps...)
+
+	tests := []struct {
+		name string
+		argv []string
+		want []string
+	}{
+		{
+			name: "run is always floating, bare name runs pinned binary",
+			argv: []string{"run", "--", "revdiff", "main"},
+			want: []string{"run", "--floating", "--", "/usr/local/bin/revdiff", "main"},
+		},
+		{
+			name: "long and short options",
+			argv: []string{"run", "-fc", "--name=diff", "watchexec", "-e", "go"},
+			want: []string{"run", "--floating", "--close-on-exit", "--name", "diff", "--", "watchexec", "-e", "go"},
+		},
+		{
+			name: "short value attached",
+			argv: []string{"run", "-ndiff", "--floating", "--", "revdiff"},
+			want: []string{"run", "--floating", "--name", "diff", "--", "/usr/local/bin/revdiff"},
+		},
+		{
+			name: "write to owned pane, bare ID",
+			argv: []string{"action", "write-chars", "--pane-id", "7", "q"},
+			want: []string{"action", "write-chars", "--pane-id", "terminal_7", "--", "q"},
+		},
+		{
+			name: "write after --",
+			argv: []string{"action", "write-chars", "--pane-id=terminal_7", "--", "--help"},
+			want: []string{"action", "write-chars", "--pane-id", "terminal_7", "--", "--help"},
+		},
+		{
+			name: "close owned pane",
+			argv: []string{"action", "close-pane", "-p", "terminal_7"},
+			want: []string{"action", "close-pane", "--pane-id", "terminal_7"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := f.Decide(tt.argv)
+			if !d.Allow {
+				t.Fatalf("denied: %s", d.Reason)
+			}
+			if !slices.Equal(d.Argv, tt.want) {
+				t.Errorf("argv = %q\nwant %q", d.Argv, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilter_Denied(t *testing.T) {
+	f, _ := newTestFilter(allCaps...)
+
+	tests := map[string][]string{
+		"empty":                    {},
+		"no command":               {"run", "--floating"},
+		"command not allowlisted":  {"run", "--", "sh", "-c", "id"},
+		"args outside prefix":      {"run", "watchexec", "-e", "rs"},
+		"tiled placement":          {"run", "--direction", "right", "revdiff"},
+		"in place":                 {"run", "--in-place", "revdiff"},
+		"cwd":                      {"run", "--cwd", "/", "revdiff"},
+		"option twice":             {"run", "-n", "a", "--name", "b", "revdiff"},
+		"value on a flag":          {"run", "--floating=false", "revdiff"},
+		"missing value":            {"run", "--name"},
+		"control characters":       {"run", "--name", "a\x1b]0;x\a", "revdiff"},
+		"session selection":        {"--session", "other", "run", "revdiff"},
+		"other command":            {"kill-session", "main"},
+		"other action":             {"action", "new-pane"},
+		"bare action":              {"action"},
+		"write to focused pane":    {"action", "write-chars", "rm -rf ~\n"},
+		"write to foreign pane":    {"action", "write-chars", "--pane-id", "terminal_1", "x"},
+		"write to plugin pane":     {"action", "write-chars", "--pane-id", "plugin_7", "x"},
+		"write two strings":        {"action", "write-chars", "--pane-id", "terminal_7", "a", "b"},
+		"write nothing":            {"action", "write-chars", "--pane-id", "terminal_7"},
+		"close focused pane":       {"action", "close-pane"},
+		"close foreign pane":       {"action", "close-pane", "--pane-id", "1"},
+		"close with extra args":    {"action", "close-pane", "--pane-id", "terminal_7", "x"},
+		"unknown write option":     {"action", "write-chars", "--pane-id", "terminal_7", "--all", "x"},
+		"unknown short run option": {"run", "-x", "revdiff"},
+	}
+	for name, argv := range tests {
+		t.Run(name, func(t *testing.T) {
+			if d := f.Decide(argv); d.Allow {
+				t.Errorf("allowed %q as %q", argv, d.Argv)
+			}
+		})
+	}
+}
+
+func TestFilter_CapabilityRequired(t *testing.T) {
+	f, _ := newTestFilter()
+	for _, argv := range [][]string{
+		{"run", "revdiff"},
+		{"action", "write-chars", "--pane-id", "terminal_7", "x"},
+		{"action", "close-pane", "--pane-id", "terminal_7"},
+	} {
+		d := f.Decide(argv)
+		if d.Allow || !strings.Contains(d.Reason, "not granted") {
+			t.Errorf("%q without capability: %+v", argv, d)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package zellijproxy
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"devsandbox/internal/cmdpattern"
+)
+
+// OwnedSet is a concurrent-safe set of terminal pane IDs (`terminal_N`) the
+// sandbox opened.
+type OwnedSet = cmdpattern.OwnedSet[string]
+
+func NewOwnedSet() *OwnedSet { return cmdpattern.NewOwnedSet[string]() }
+
+// paneIDPattern matches the pane ID spellings zellij accepts for a terminal
+// pane: `terminal_N`, or a bare N. Plugin panes (`plugin_N`) are never
+// opened by the proxy, so they are never owned.
+var paneIDPattern = regexp.MustCompile(`^(?:terminal_)?([0-9]{1,9})$`)
+
+// CanonicalPaneID returns id in the `terminal_N` form the owned set is keyed
+// on, so the two spellings of one pane cannot be told apart by the filter.
+func CanonicalPaneID(id string) (string, bool) {
+	m := paneIDPattern.FindStringSubmatch(id)
+	if m == nil {
+		return "", false
+	}
+	return "terminal_" + m[1], true
+}
+
+// ExtractPaneID parses the output of `zellij run` and returns the new pane's
+// ID. zellij prints it as the only line of output.
+func ExtractPaneID(output string) (string, error) {
+	id, ok := CanonicalPaneID(strings.TrimSpace(output))
+	if !ok {
+		return "", fmt.Errorf("launch output is not a pane id: %q", strings.TrimSpace(output))
+	}
+	return id, nil
+}
@@ -0,0 +1,28 @@
+package zellijproxy
+
+import "testing"
+
+func TestCanonicalPaneID(t *testing.T) {
+	for in, want := range map[string]string{"terminal_4": "terminal_4", "4": "terminal_4"} {
+		if got, ok := CanonicalPaneID(in); !ok || got != want {
+			t.Errorf("CanonicalPaneID(%q) = %q, %v; want %q", in, got, ok, want)
+		}
+	}
+	for _, bad := range []string{"", "plugin_2", "terminal_", "terminal_1 ", "-1", "terminal_1\nterminal_2"} {
+		if _, ok := CanonicalPaneID(bad); ok {
+			t.Errorf("CanonicalPaneID(%q) accepted", bad)
+		}
+	}
+}
+
+func TestExtractPaneID(t *testing.T) {
+	id, err := ExtractPaneID("terminal_12\n")
+	if err != nil || id != "terminal_12" {
+		t.Errorf("ExtractPaneID = %q, %v", id, err)
+	}
+	for _, bad := range []string{"", "plugin_3\n", "terminal_1\nterminal_2\n", "Session not found"} {
+		if _, err := ExtractPaneID(bad); err == nil {
+			t.Errorf("ExtractPaneID(%q) should fail", bad)
+		}
+	}
+}
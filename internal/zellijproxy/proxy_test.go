@@ -0,0 +1,136 @@
+package zellijproxy
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"devsandbox/internal/cmdpattern"
+)
+
+// shortSocketDir returns a tempdir whose path is short enough for a UNIX
+// domain socket beneath it to fit within macOS's 104-byte sun_path limit.
+func shortSocketDir(t *testing.T) string {
+	t.Helper()
+	dir, err := os.MkdirTemp("/tmp", "ds")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	return dir
+}
+
+// fakeZellij writes a stand-in for the zellij binary that records each argv,
+// one word per line and a blank line after each call, and prints a pane ID
+// for `run`. It returns the binary and the record file.
+func fakeZellij(t *testing.T, dir string) (bin, record string) {
+	t.Helper()
+	bin = filepath.Join(dir, "zellij")
+	record = filepath.Join(dir, "calls")
+	script := `#!/bin/sh
+for a in "$@"; do printf '%s\n' "$a" >> "` + record + `"; done
+echo >> "` + record + `"
+[ "$3" = run ] && echo terminal_9
+[ "$2" = fail ] && { echo "no such session" >&2; exit 1; }
+exit 0
+`
+	if err := os.WriteFile(bin, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return bin, record
+}
+
+func TestProxy_LaunchTrackAndScope(t *testing.T) {
+	dir := shortSocketDir(t)
+	bin, record := fakeZellij(t, dir)
+
+	sleepBin, err := cmdpattern.ResolveProgram("sleep")
+	if err != nil {
+		t.Skip("sleep not found")
+	}
+	owned := NewOwnedSet()
+	filter := NewFilter(FilterConfig{
+		Capabilities:   allCaps,
+		LaunchPatterns: []cmdpattern.CommandPattern{{Program: "sleep", ResolvedBin: sleepBin, ArgsMatcher: cmdpattern.MatchAny()}},
+		Owned:          owned,
+	})
+	listen := filepath.Join(dir, "proxy.sock")
+	p := New(bin, "main", listen, filter, owned)
+	if err := p.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = p.Stop() })
+
+	call := func(argv ...string) Response {
+		t.Helper()
+		resp, err := Call(listen, argv)
+		if err != nil {
+			t.Fatalf("%q: %v", argv, err)
+		}
+		return resp
+	}
+
+	resp := call("run", "--", "sleep", "600")
+	if !resp.OK || resp.Output != "terminal_9\n" {
+		t.Fatalf("run = %+v", resp)
+	}
+	if !owned.Contains("terminal_9") {
+		t.Fatal("launched pane not tracked")
+	}
+	if resp := call("action", "write-chars", "--pane-id", "terminal_1", "x"); resp.OK || !strings.Contains(resp.Error, "not owned") {
+		t.Errorf("write-chars to a foreign pane = %+v, want a denial", resp)
+	}
+	if resp := call("run", "sh", "-c", "id"); resp.OK {
+		t.Error("launch of a command outside the allowlist succeeded")
+	}
+	if resp := call("action", "write-chars", "--pane-id", "9", "q"); !resp.OK {
+		t.Errorf("write-chars to the owned pane: %s", resp.Error)
+	}
+	if resp := call("action", "close-pane", "--pane-id", "terminal_9"); !resp.OK {
+		t.Errorf("close-pane of the owned pane: %s", resp.Error)
+	}
+
+	data, err := os.ReadFile(record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var calls [][]string
+	for c := range strings.SplitSeq(strings.TrimSuffix(string(data), "\n\n"), "\n\n") {
+		calls = append(calls, strings.Split(c, "\n"))
+	}
+	want := [][]string{
+		{"--session", "main", "run", "--floating", "--", sleepBin, "600"},
+		{"--session", "main", "action", "write-chars", "--pane-id", "terminal_9", "--", "q"},
+		{"--session", "main", "action", "close-pane", "--pane-id", "terminal_9"},
+	}
+	if !slices.EqualFunc(calls, want, slices.Equal) {
+		t.Errorf("zellij ran\n%q\nwant\n%q", calls, want)
+	}
+}
+
+func TestProxy_ZellijErrorIsReported(t *testing.T) {
+	dir := shortSocketDir(t)
+	bin, _ := fakeZellij(t, dir)
+
+	owned := NewOwnedSet()
+	owned.Add("terminal_3")
+	filter := NewFilter(FilterConfig{Capabilities: []Capability{CapClosePaneOwned}, Owned: owned})
+	listen := filepath.Join(dir, "proxy.sock")
+	// The fake fails when the session is named "fail".
+	p := New(bin, "fail", listen, filter, owned)
+	if err := p.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = p.Stop() })
+
+	resp, err := Call(listen, []string{"action", "close-pane", "--pane-id", "terminal_3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.OK || resp.Error != "no such session" {
+		t.Errorf("response = %+v, want zellij's error", resp)
+	}
+}